@@ -110,6 +110,7 @@ export interface FeatureToggles {
   grafanaAPIServerWithExperimentalAPIs?: boolean;
   featureToggleAdminPage?: boolean;
   awsAsyncQueryCaching?: boolean;
+  queryCachingPartialHits?: boolean;
   splitScopes?: boolean;
   azureMonitorDataplane?: boolean;
   permissionsFilterRemoveSubquery?: boolean;
@@ -140,6 +141,9 @@ export interface FeatureToggles {
   transformationsVariableSupport?: boolean;
   kubernetesPlaylists?: boolean;
   kubernetesPlaylistsAPI?: boolean;
+  playlistResponseV2?: boolean;
+  playlistPublicLinks?: boolean;
+  playlistMaintenanceMode?: boolean;
   cloudWatchBatchQueries?: boolean;
   navAdminSubsections?: boolean;
   recoveryThreshold?: boolean;
@@ -148,6 +152,15 @@ export interface FeatureToggles {
   cachingOptimizeSerializationMemoryUsage?: boolean;
   panelTitleSearchInV1?: boolean;
   pluginsInstrumentationStatusSource?: boolean;
+  pluginsInstrumentationStatusCode?: boolean;
+  pluginsInstrumentationOverrides?: boolean;
+  pluginsInstrumentationRangeRecency?: boolean;
+  pluginsInstrumentationResponseEncoding?: boolean;
+  pluginsInstrumentationAlertingHistogram?: boolean;
+  pluginsInstrumentationClientClass?: boolean;
+  pluginsInstrumentationRegistryLookup?: boolean;
+  pluginsInstrumentationErrorCategory?: boolean;
+  pluginsInstrumentationDatasourceUID?: boolean;
   costManagementUi?: boolean;
   managedPluginsInstall?: boolean;
   prometheusPromQAIL?: boolean;
@@ -9,6 +9,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/libraryelements"
 	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/playlist"
 	"github.com/grafana/grafana/pkg/services/pluginsintegration/pluginaccesscontrol"
 	"github.com/grafana/grafana/pkg/tsdb/grafanads"
 )
@@ -546,6 +547,33 @@ func (hs *HTTPServer) declareFixedRoles() error {
 		Grants: []string{"Admin"},
 	}
 
+	playlistsReaderRole := ac.RoleRegistration{
+		Role: ac.RoleDTO{
+			Name:        "fixed:playlists:reader",
+			DisplayName: "Playlist reader",
+			Description: "Read and play all playlists.",
+			Group:       "Playlists",
+			Permissions: []ac.Permission{
+				{Action: playlist.ActionPlaylistsRead, Scope: playlist.ScopePlaylistsAll},
+			},
+		},
+		Grants: []string{string(org.RoleViewer)},
+	}
+
+	playlistsWriterRole := ac.RoleRegistration{
+		Role: ac.RoleDTO{
+			Name:        "fixed:playlists:writer",
+			DisplayName: "Playlist writer",
+			Description: "Create, update and delete all playlists.",
+			Group:       "Playlists",
+			Permissions: ac.ConcatPermissions(playlistsReaderRole.Role.Permissions, []ac.Permission{
+				{Action: playlist.ActionPlaylistsWrite, Scope: playlist.ScopePlaylistsAll},
+				{Action: playlist.ActionPlaylistsDelete, Scope: playlist.ScopePlaylistsAll},
+			}),
+		},
+		Grants: []string{string(org.RoleEditor)},
+	}
+
 	roles := []ac.RoleRegistration{provisioningWriterRole, datasourcesReaderRole, builtInDatasourceReader, datasourcesWriterRole,
 		datasourcesIdReaderRole, datasourcesCreatorRole, orgReaderRole, orgWriterRole,
 		orgMaintainerRole, teamsCreatorRole, teamsWriterRole, teamsReaderRole, datasourcesExplorerRole,
@@ -553,7 +581,8 @@ func (hs *HTTPServer) declareFixedRoles() error {
 		dashboardsCreatorRole, dashboardsReaderRole, dashboardsWriterRole,
 		foldersCreatorRole, foldersReaderRole, foldersWriterRole, apikeyReaderRole, apikeyWriterRole,
 		publicDashboardsWriterRole, featuremgmtReaderRole, featuremgmtWriterRole, libraryPanelsCreatorRole,
-		libraryPanelsReaderRole, libraryPanelsWriterRole, libraryPanelsGeneralReaderRole, libraryPanelsGeneralWriterRole}
+		libraryPanelsReaderRole, libraryPanelsWriterRole, libraryPanelsGeneralReaderRole, libraryPanelsGeneralWriterRole,
+		playlistsReaderRole, playlistsWriterRole}
 
 	return hs.accesscontrolService.DeclareFixedRoles(roles...)
 }
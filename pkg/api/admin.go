@@ -10,6 +10,7 @@ import (
 	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
 	"github.com/grafana/grafana/pkg/services/stats"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web"
 )
 
 // swagger:route GET /admin/settings admin adminGetSettings
@@ -164,3 +165,22 @@ type GetStatsResponse struct {
 	// in:body
 	Body stats.AdminStats `json:"body"`
 }
+
+// AdminGetPluginPayloadSamples returns the last plugin request/response payloads captured for debugging.
+// The endpoint query parameter can be used to only return the samples of a single plugin endpoint (e.g. queryData).
+func (hs *HTTPServer) AdminGetPluginPayloadSamples(c *contextmodel.ReqContext) response.Response {
+	if !hs.Cfg.PluginPayloadSamplingEnabled || hs.pluginPayloadSampler == nil {
+		return response.Error(http.StatusNotFound, "Plugin payload sampling is not enabled", nil)
+	}
+	pluginID := web.Params(c.Req)[":pluginId"]
+	return response.JSON(http.StatusOK, hs.pluginPayloadSampler.Samples(pluginID, c.Query("endpoint")))
+}
+
+// AdminGetPluginOrgLatencies returns the latency percentiles of the plugin requests of the most requested orgs,
+// and of all the other orgs together.
+func (hs *HTTPServer) AdminGetPluginOrgLatencies(c *contextmodel.ReqContext) response.Response {
+	if hs.Cfg.PluginOrgLatencyTrackingSize <= 0 || hs.pluginOrgLatencies == nil {
+		return response.Error(http.StatusNotFound, "Plugin org latency tracking is not enabled", nil)
+	}
+	return response.JSON(http.StatusOK, hs.pluginOrgLatencies.Latencies())
+}
@@ -594,6 +594,9 @@ func (hs *HTTPServer) registerRoutes() {
 		adminRoute.Post("/encryption/migrate-secrets/from-plugin", reqGrafanaAdmin, routing.Wrap(hs.AdminMigrateSecretsFromPlugin))
 		adminRoute.Post("/encryption/delete-secretsmanagerplugin-secrets", reqGrafanaAdmin, routing.Wrap(hs.AdminDeleteAllSecretsManagerPluginSecrets))
 
+		adminRoute.Get("/plugins/:pluginId/payload-samples", reqGrafanaAdmin, routing.Wrap(hs.AdminGetPluginPayloadSamples))
+		adminRoute.Get("/plugins/org-latencies", reqGrafanaAdmin, routing.Wrap(hs.AdminGetPluginOrgLatencies))
+
 		adminRoute.Post("/provisioning/dashboards/reload", authorize(ac.EvalPermission(ActionProvisioningReload, ScopeProvisionersDashboards)), routing.Wrap(hs.AdminProvisioningReloadDashboards))
 		adminRoute.Post("/provisioning/plugins/reload", authorize(ac.EvalPermission(ActionProvisioningReload, ScopeProvisionersPlugins)), routing.Wrap(hs.AdminProvisioningReloadPlugins))
 		adminRoute.Post("/provisioning/datasources/reload", authorize(ac.EvalPermission(ActionProvisioningReload, ScopeProvisionersDatasources)), routing.Wrap(hs.AdminProvisioningReloadDatasources))
@@ -634,6 +637,12 @@ func (hs *HTTPServer) registerRoutes() {
 	r.Get("/api/snapshots/:key", routing.Wrap(hs.GetDashboardSnapshot))
 	r.Get("/api/snapshots-delete/:deleteKey", reqSnapshotPublicModeOrSignedIn, routing.Wrap(hs.DeleteDashboardSnapshotByDeleteKey))
 	r.Delete("/api/snapshots/:key", reqSignedIn, routing.Wrap(hs.DeleteDashboardSnapshot))
+
+	// Public playlist links
+	if hs.Features.IsEnabled(featuremgmt.FlagPlaylistPublicLinks) {
+		r.Get("/api/public/playlists/:token", hs.validatePublicPlaylistToken, routing.Wrap(hs.GetPublicPlaylist))
+		r.Get("/api/public/playlists/:token/dashboards/:dashboardUid", hs.validatePublicPlaylistToken, routing.Wrap(hs.GetPublicPlaylistDashboard))
+	}
 }
 
 func evalAuthenticationSettings() ac.Evaluator {
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana/pkg/api/response"
@@ -278,6 +279,10 @@ func SetupAPITestServer(t *testing.T, opts ...APITestServerOption) *webtest.Serv
 		hs.AccessControl = acimpl.ProvideAccessControl(hs.Cfg)
 	}
 
+	if hs.promRegister == nil {
+		hs.promRegister = prometheus.NewRegistry()
+	}
+
 	hs.registerRoutes()
 
 	s := webtest.NewServer(t, hs.RouteRegister)
@@ -1,5 +1,12 @@
 package dtos
 
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/kinds"
+	"github.com/grafana/grafana/pkg/services/playlist"
+)
+
 type PlaylistDashboard struct {
 	Id    int64  `json:"id"`
 	Slug  string `json:"slug"`
@@ -22,3 +29,283 @@ func (slice PlaylistDashboardsSlice) Less(i, j int) bool {
 func (slice PlaylistDashboardsSlice) Swap(i, j int) {
 	slice[i], slice[j] = slice[j], slice[i]
 }
+
+// PlaylistSearchResult is a playlist returned by the search API, with an optional preview of its dashboards
+// and its optional items.
+type PlaylistSearchResult struct {
+	*playlist.Playlist
+
+	// Titles of the first dashboards in the playlist that the user can view.
+	// Only set when a preview is requested.
+	Preview []string `json:"preview,omitempty"`
+	// Items of the playlist, as returned by the items endpoint.
+	// Only set when the items are requested.
+	Items []playlist.PlaylistItemDTO `json:"items,omitempty"`
+}
+
+// PlaylistV2 is the version 2 of the playlist API response.
+// Compared to playlist.PlaylistDTO, it includes metadata about the playlist.
+type PlaylistV2 struct {
+	Uid      string                     `json:"uid"`
+	Name     string                     `json:"name"`
+	Interval string                     `json:"interval"`
+	Items    []playlist.PlaylistItemDTO `json:"items"`
+	Metadata PlaylistMetadata           `json:"metadata"`
+}
+
+type PlaylistMetadata struct {
+	Created   *time.Time `json:"created,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	Updated   *time.Time `json:"updated,omitempty"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	Folder    string     `json:"folder,omitempty"`
+	ItemCount int        `json:"itemCount"`
+}
+
+// NewPlaylistV2 converts a playlist to the version 2 of the API response.
+// The meta is only set for playlists coming from k8s, legacy playlists don't track who changed them. The users of
+// the playlist take precedence over the ones of the meta.
+func NewPlaylistV2(dto *playlist.PlaylistDTO, meta kinds.GrafanaResourceMetadata) PlaylistV2 {
+	items := dto.Items
+	if items == nil {
+		items = []playlist.PlaylistItemDTO{}
+	}
+	createdBy, updatedBy := dto.CreatedBy, dto.UpdatedBy
+	if createdBy == "" {
+		createdBy = meta.GetCreatedBy()
+	}
+	if updatedBy == "" {
+		updatedBy = meta.GetUpdatedBy()
+	}
+	return PlaylistV2{
+		Uid:      dto.Uid,
+		Name:     dto.Name,
+		Interval: dto.Interval,
+		Items:    items,
+		Metadata: PlaylistMetadata{
+			Created:   dto.Created,
+			CreatedBy: createdBy,
+			Updated:   dto.Updated,
+			UpdatedBy: updatedBy,
+			Folder:    meta.GetFolder(),
+			ItemCount: len(dto.Items),
+		},
+	}
+}
+
+// PlaylistShareLink is a link starting the playback of a playlist.
+type PlaylistShareLink struct {
+	URL string `json:"url"`
+}
+
+// PlaylistCount is the number of playlists of an organization.
+type PlaylistCount struct {
+	Count int64 `json:"count"`
+}
+
+// PlaylistSizeDistribution is the number of playlists of an organization per number of items.
+type PlaylistSizeDistribution struct {
+	Total int64                        `json:"total"`
+	Sizes []playlist.PlaylistSizeCount `json:"sizes"`
+}
+
+// ReorderPlaylistItemsCommand sets the order of the items of a playlist.
+type ReorderPlaylistItemsCommand struct {
+	// Keys of all the items of the playlist, in the new order. The key of an item is its type and value,
+	// separated by a colon, e.g. dashboard_by_uid:abc.
+	Order []string `json:"order"`
+}
+
+// MergePlaylistCommand appends the items of the source playlist to another playlist.
+type MergePlaylistCommand struct {
+	// UID of the playlist whose items are appended.
+	SourceUID string `json:"sourceUid"`
+	// Skip the source items with the same type and value as an item already in the playlist.
+	Dedup bool `json:"dedup"`
+	// Delete the source playlist once merged.
+	DeleteSource bool `json:"deleteSource"`
+}
+
+// BulkDeletePlaylistsCommand deletes several playlists at once.
+type BulkDeletePlaylistsCommand struct {
+	// UIDs of the playlists to delete.
+	UIDs []string `json:"uids"`
+}
+
+// BulkDeletePlaylistResult is the result of the deletion of a playlist of a bulk delete.
+type BulkDeletePlaylistResult struct {
+	// HTTP status of the deletion, 200 if the playlist was deleted.
+	Status int `json:"status"`
+	// Why the playlist wasn't deleted, if it wasn't.
+	Message string `json:"message,omitempty"`
+}
+
+// BatchGetPlaylistsCommand gets several playlists at once.
+type BatchGetPlaylistsCommand struct {
+	// UIDs of the playlists to get.
+	UIDs []string `json:"uids"`
+}
+
+// PlaylistBatch is the result of a batch get of playlists.
+type PlaylistBatch struct {
+	// The playlists found, in the order of the request.
+	Playlists []*playlist.PlaylistDTO `json:"playlists"`
+	// Why the other playlists weren't returned, by UID.
+	Skipped map[string]SkippedPlaylist `json:"skipped"`
+}
+
+// SkippedPlaylist is the reason a playlist was left out of a batch get.
+type SkippedPlaylist struct {
+	// HTTP status the playlist would have been returned with on its own, e.g. 403 or 404.
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// PlaylistArchive is an export of all the playlists of an organization, in the format of the playlist API.
+type PlaylistArchive struct {
+	Playlists []playlist.PlaylistDTO `json:"playlists"`
+}
+
+// PlaylistBundleAPIVersion is the version of the format of the playlist bundles.
+const PlaylistBundleAPIVersion = "playlist.grafana.app/bundle/v1"
+
+// PlaylistBundle is a self-contained export of a playlist, to move it to another Grafana instance.
+type PlaylistBundle struct {
+	// APIVersion is the version of the format of the bundle, PlaylistBundleAPIVersion.
+	APIVersion string               `json:"apiVersion"`
+	Playlist   playlist.PlaylistDTO `json:"playlist"`
+	// Dashboards are the dashboards of the dashboard_by_uid items, in the order of the items, with their
+	// title so that they can be mapped to the dashboards of the target instance. The dashboards that don't
+	// exist or that the user can't view are left out.
+	Dashboards []PlaylistBundleDashboard `json:"dashboards"`
+	// Resolved are the dashboards the playlist resolved to when it was exported, the tag items expanded, in the
+	// order of the items. It's only exported on request, for reference: the import ignores it.
+	Resolved []PlaylistResolvedDashboard `json:"resolved,omitempty"`
+	// Warnings are the tags with more dashboards than the resolved ones include.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PlaylistBundleDashboard is a dashboard of a playlist bundle.
+type PlaylistBundleDashboard struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+}
+
+// PlaylistResolvedDashboard is a dashboard a playlist resolved to.
+type PlaylistResolvedDashboard struct {
+	UID   string   `json:"uid"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+// ImportPlaylistBundleCommand is a playlist bundle to import, with the UIDs of the dashboards of the target org
+// to use in place of the dashboards of the bundle.
+type ImportPlaylistBundleCommand struct {
+	PlaylistBundle
+	// DashboardUIDs maps the UIDs of dashboards of the bundle to the UIDs of dashboards of the target org.
+	// The dashboards that aren't mapped are kept if they exist in the target org.
+	DashboardUIDs map[string]string `json:"dashboardUids,omitempty"`
+}
+
+// ImportPlaylistBundleResult is the playlist created from a bundle.
+type ImportPlaylistBundleResult struct {
+	// UID of the created playlist, empty on dry runs.
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+	// Warnings are the items of the bundle that were skipped, because their dashboard couldn't be found
+	// in the target org.
+	Warnings []string `json:"warnings"`
+	// DryRun is whether the import was a dry run, which doesn't create the playlist.
+	DryRun bool `json:"dryRun,omitempty"`
+	// WouldCreate is whether the playlist would be created by the import, on dry runs.
+	WouldCreate bool `json:"wouldCreate,omitempty"`
+	// Errors are why the playlist wouldn't be created, on dry runs.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PlaylistImportResult is the result of the import of a playlist of an archive.
+type PlaylistImportResult struct {
+	// UID of the imported playlist, generated if the archive doesn't set it.
+	UID  string `json:"uid,omitempty"`
+	Name string `json:"name"`
+	// HTTP status of the import, 200 if the playlist was created.
+	Status int `json:"status"`
+	// Why the playlist wasn't imported, if it wasn't.
+	Message string `json:"message,omitempty"`
+	// DryRun is whether the import was a dry run, which doesn't create the playlist. The status is then
+	// the one the import would have, and the UID empty if the archive doesn't set it.
+	DryRun bool `json:"dryRun,omitempty"`
+	// WouldCreate is whether the playlist would be created by the import, on dry runs.
+	WouldCreate bool `json:"wouldCreate,omitempty"`
+}
+
+// PlaylistPlaybackEvents are playback events of a playlist reported by a client.
+type PlaylistPlaybackEvents struct {
+	Events []PlaylistPlaybackEvent `json:"events"`
+}
+
+// PlaylistPlaybackEvent is a playback event of a playlist.
+type PlaylistPlaybackEvent struct {
+	// One of item_shown, item_skipped or rotation.
+	Type string `json:"type"`
+	// Index of the item in the playlist. Unused for rotations.
+	Item int `json:"item"`
+	// How long the item was shown before moving to the next one, in milliseconds, up to a day. Unused for rotations.
+	DwellMs int64 `json:"dwellMs"`
+}
+
+// PlaylistPlaybackStats is the aggregated playback of a playlist.
+type PlaylistPlaybackStats struct {
+	Rotations  int64                       `json:"rotations"`
+	Shown      int64                       `json:"shown"`
+	Skipped    int64                       `json:"skipped"`
+	AvgDwellMs int64                       `json:"avgDwellMs"`
+	Items      []PlaylistItemPlaybackStats `json:"items"`
+}
+
+// PlaylistItemPlaybackStats is the aggregated playback of an item of a playlist.
+// Items that are skipped more often than shown are where viewers drop off.
+type PlaylistItemPlaybackStats struct {
+	Item       int   `json:"item"`
+	Shown      int64 `json:"shown"`
+	Skipped    int64 `json:"skipped"`
+	AvgDwellMs int64 `json:"avgDwellMs"`
+}
+
+// CreatePlaylistPublicLinkCommand creates a public link to a playlist.
+type CreatePlaylistPublicLinkCommand struct {
+	// Lifetime of the link in seconds. Defaults to a day, and can be up to 30 days.
+	ExpiresIn int64 `json:"expiresIn"`
+}
+
+// PlaylistPublicLink is a signed, time-limited link to a playlist that doesn't require authentication.
+type PlaylistPublicLink struct {
+	// ID of the link, to revoke it.
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PublicPlaylist is a playlist read with a public link.
+type PublicPlaylist struct {
+	UID      string `json:"uid"`
+	Name     string `json:"name"`
+	Interval string `json:"interval"`
+	// The dashboards of the playlist, in the order of its items, with the dashboards by tag expanded.
+	Dashboards []PublicPlaylistDashboard `json:"dashboards"`
+	// Warnings are the tags with more dashboards than the playlist includes.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PublicPlaylistDashboard is a dashboard of a playlist read with a public link.
+type PublicPlaylistDashboard struct {
+	UID   string `json:"uid"`
+	Title string `json:"title"`
+	// Interval is the time the dashboard is shown: the interval of its item if it overrides the playlist one,
+	// the interval of the playlist otherwise.
+	Interval string `json:"interval"`
+	// Section is the label of the section item before the dashboard, if there's one, so that the UIs can show
+	// the transitions between sections.
+	Section string `json:"section,omitempty"`
+}
@@ -70,6 +70,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/playlist"
 	"github.com/grafana/grafana/pkg/services/plugindashboards"
+	"github.com/grafana/grafana/pkg/services/pluginsintegration/clientmiddleware"
 	"github.com/grafana/grafana/pkg/services/pluginsintegration/plugincontext"
 	pluginSettings "github.com/grafana/grafana/pkg/services/pluginsintegration/pluginsettings"
 	"github.com/grafana/grafana/pkg/services/pluginsintegration/pluginstore"
@@ -190,6 +191,8 @@ type HTTPServer struct {
 	starService                  star.Service
 	Kinds                        *corekind.Base
 	playlistService              playlist.Service
+	playlistPlayback             *playlistPlaybackRecorder
+	playlistAccessLog            *playlistAccessLogger
 	apiKeyService                apikey.Service
 	kvStore                      kvstore.KVStore
 	pluginsCDNService            *pluginscdn.Service
@@ -208,6 +211,8 @@ type HTTPServer struct {
 	starApi              *starApi.API
 	promRegister         prometheus.Registerer
 	clientConfigProvider grafanaapiserver.DirectRestConfigProvider
+	pluginPayloadSampler *clientmiddleware.PayloadSampler
+	pluginOrgLatencies   *clientmiddleware.OrgLatencyTracker
 }
 
 type ServerOptions struct {
@@ -250,6 +255,8 @@ func ProvideHTTPServer(opts ServerOptions, cfg *setting.Cfg, routeRegister routi
 	annotationRepo annotations.Repository, tagService tag.Service, searchv2HTTPService searchV2.SearchHTTPService, oauthTokenService oauthtoken.OAuthTokenService,
 	statsService stats.Service, authnService authn.Service, pluginsCDNService *pluginscdn.Service,
 	starApi *starApi.API, promRegister prometheus.Registerer, clientConfigProvider grafanaapiserver.DirectRestConfigProvider,
+	pluginPayloadSampler *clientmiddleware.PayloadSampler,
+	pluginOrgLatencies *clientmiddleware.OrgLatencyTracker,
 ) (*HTTPServer, error) {
 	web.Env = cfg.Env
 	m := web.New()
@@ -351,6 +358,8 @@ func ProvideHTTPServer(opts ServerOptions, cfg *setting.Cfg, routeRegister routi
 		starApi:                      starApi,
 		promRegister:                 promRegister,
 		clientConfigProvider:         clientConfigProvider,
+		pluginPayloadSampler:         pluginPayloadSampler,
+		pluginOrgLatencies:           pluginOrgLatencies,
 	}
 	if hs.Listener != nil {
 		hs.log.Debug("Using provided listener")
@@ -576,11 +585,12 @@ func (hs *HTTPServer) addMiddlewaresAndStaticRoutes() {
 	m.Use(requestmeta.SetupRequestMetadata())
 	m.Use(middleware.RequestTracing(hs.tracer))
 	m.Use(middleware.RequestMetrics(hs.Features, hs.Cfg, hs.promRegister))
+	m.Use(middleware.PluginFanOut(hs.promRegister))
 
 	m.UseMiddleware(hs.LoggerMiddleware.Middleware())
 
 	if hs.Cfg.EnableGzip {
-		m.UseMiddleware(middleware.Gziper())
+		m.UseMiddleware(middleware.Gziper(hs.promRegister))
 	}
 
 	m.UseMiddleware(middleware.Recovery(hs.Cfg))
@@ -1,32 +1,73 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"k8s.io/apimachinery/pkg/api/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/apis/playlist/v0alpha1"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/kinds"
 	"github.com/grafana/grafana/pkg/middleware"
+	ac "github.com/grafana/grafana/pkg/services/accesscontrol"
 	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/dashboards"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/grafana-apiserver/endpoints/request"
+	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/util"
 	"github.com/grafana/grafana/pkg/util/errutil/errhttp"
 	"github.com/grafana/grafana/pkg/web"
 )
 
 type playlistAPIHandler struct {
 	SearchPlaylists  []web.Handler
+	CountPlaylists   []web.Handler
 	GetPlaylist      []web.Handler
 	GetPlaylistItems []web.Handler
+	GetBatch         []web.Handler
+	GetShareLink     []web.Handler
+	GetSizes         []web.Handler
+	ReorderPlaylist  []web.Handler
+	ReorderItems     []web.Handler
+	MergePlaylist    []web.Handler
+	BulkDelete       []web.Handler
+	ExportAll        []web.Handler
+	Export           []web.Handler
+	Import           []web.Handler
+	ImportAll        []web.Handler
+	ReportPlayback   []web.Handler
+	GetPlaybackStats []web.Handler
+	CreatePublicLink []web.Handler
+	RevokePublicLink []web.Handler
 	DeletePlaylist   []web.Handler
+	RestorePlaylist  []web.Handler
 	UpdatePlaylist   []web.Handler
 	CreatePlaylist   []web.Handler
 }
@@ -36,13 +77,39 @@ func chainHandlers(h ...web.Handler) []web.Handler {
 }
 
 func (hs *HTTPServer) registerPlaylistAPI(apiRoute routing.RouteRegister) {
+	hs.playlistPlayback = newPlaylistPlaybackRecorder(hs.promRegister)
+	hs.playlistAccessLog = newPlaylistAccessLogger(log.New("playlist.access"))
+	readAny := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsRead))
+	readAll := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsRead, playlist.ScopePlaylistsAll), org.RoleEditor)
+	read := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsRead, playlistUIDScope))
+	writeAll := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsWrite, playlist.ScopePlaylistsAll), org.RoleEditor)
+	write := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsWrite, playlistUIDScope), org.RoleEditor)
+	deleteAny := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsDelete), org.RoleEditor)
+	deleteOne := hs.authorizePlaylist(ac.EvalPermission(playlist.ActionPlaylistsDelete, playlistUIDScope), org.RoleEditor)
 	handler := playlistAPIHandler{
-		SearchPlaylists:  chainHandlers(routing.Wrap(hs.SearchPlaylists)),
-		GetPlaylist:      chainHandlers(hs.validateOrgPlaylist, routing.Wrap(hs.GetPlaylist)),
-		GetPlaylistItems: chainHandlers(hs.validateOrgPlaylist, routing.Wrap(hs.GetPlaylistItems)),
-		DeletePlaylist:   chainHandlers(middleware.ReqEditorRole, hs.validateOrgPlaylist, routing.Wrap(hs.DeletePlaylist)),
-		UpdatePlaylist:   chainHandlers(middleware.ReqEditorRole, hs.validateOrgPlaylist, routing.Wrap(hs.UpdatePlaylist)),
-		CreatePlaylist:   chainHandlers(middleware.ReqEditorRole, routing.Wrap(hs.CreatePlaylist)),
+		SearchPlaylists:  chainHandlers(readAny, routing.Wrap(hs.SearchPlaylists)),
+		CountPlaylists:   chainHandlers(readAny, routing.Wrap(hs.CountPlaylists)),
+		GetPlaylist:      chainHandlers(read, hs.validateOrgPlaylist, routing.Wrap(hs.GetPlaylist)),
+		GetPlaylistItems: chainHandlers(read, hs.validateOrgPlaylist, routing.Wrap(hs.GetPlaylistItems)),
+		GetBatch:         chainHandlers(readAny, routing.Wrap(hs.BatchGetPlaylists)),
+		GetShareLink:     chainHandlers(read, hs.validateOrgPlaylist, routing.Wrap(hs.GetPlaylistShareLink)),
+		GetSizes:         chainHandlers(middleware.ReqOrgAdmin, routing.Wrap(hs.GetPlaylistSizeDistribution)),
+		DeletePlaylist:   chainHandlers(deleteOne, hs.validateOrgPlaylist, routing.Wrap(hs.DeletePlaylist)),
+		RestorePlaylist:  chainHandlers(write, middleware.Quota(hs.QuotaService)(string(playlist.QuotaTargetSrv)), routing.Wrap(hs.RestorePlaylist)),
+		UpdatePlaylist:   chainHandlers(write, hs.validateOrgPlaylist, routing.Wrap(hs.UpdatePlaylist)),
+		ReorderPlaylist:  chainHandlers(write, hs.validateOrgPlaylist, routing.Wrap(hs.ReorderPlaylist)),
+		ReorderItems:     chainHandlers(write, hs.validateOrgPlaylist, routing.Wrap(hs.ReorderPlaylistItems)),
+		MergePlaylist:    chainHandlers(write, hs.validateOrgPlaylist, routing.Wrap(hs.MergePlaylist)),
+		BulkDelete:       chainHandlers(deleteAny, routing.Wrap(hs.BulkDeletePlaylists)),
+		ExportAll:        chainHandlers(readAll, routing.Wrap(hs.ExportAllPlaylists)),
+		Export:           chainHandlers(read, hs.validateOrgPlaylist, routing.Wrap(hs.ExportPlaylist)),
+		ImportAll:        chainHandlers(writeAll, routing.Wrap(hs.ImportAllPlaylists)),
+		Import:           chainHandlers(writeAll, middleware.Quota(hs.QuotaService)(string(playlist.QuotaTargetSrv)), routing.Wrap(hs.ImportPlaylistBundle)),
+		ReportPlayback:   chainHandlers(read, hs.validateOrgPlaylist, routing.Wrap(hs.ReportPlaylistPlayback)),
+		GetPlaybackStats: chainHandlers(read, hs.validateOrgPlaylist, routing.Wrap(hs.GetPlaylistPlaybackStats)),
+		CreatePublicLink: chainHandlers(write, hs.validateOrgPlaylist, routing.Wrap(hs.CreatePlaylistPublicLink)),
+		RevokePublicLink: chainHandlers(write, hs.validateOrgPlaylist, routing.Wrap(hs.RevokePlaylistPublicLink)),
+		CreatePlaylist:   chainHandlers(writeAll, middleware.Quota(hs.QuotaService)(string(playlist.QuotaTargetSrv)), routing.Wrap(hs.CreatePlaylist)),
 	}
 
 	// Alternative implementations for k8s
@@ -54,18 +121,34 @@ func (hs *HTTPServer) registerPlaylistAPI(apiRoute routing.RouteRegister) {
 			Resource: "playlists",
 		}
 
+		// Track the apiserver round-trip separately from the overall request duration
+		clientDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "playlist_k8s_client_duration_seconds",
+			Help:      "Duration of the k8s client calls made by the playlist API",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"verb"})
+		hs.promRegister.MustRegister(clientDuration)
+
+		clients := newPlaylistClientCache()
 		clientGetter := func(c *contextmodel.ReqContext) (dynamic.ResourceInterface, bool) {
-			dyn, err := dynamic.NewForConfig(hs.clientConfigProvider.GetDirectRestConfig(c))
+			cfg := hs.clientConfigProvider.GetDirectRestConfig(c)
+			dyn, err := clients.get(cfg)
 			if err != nil {
 				c.JsonApiErr(500, "client", err)
 				return nil, false
 			}
-			return dyn.Resource(gvr).Namespace(namespacer(c.OrgID)), true
+			// The client calls are made with the request context, which carries the transport of the signed in user
+			c.Req = c.Req.WithContext(withRequestTransport(c.Req.Context(), cfg))
+			return &instrumentedResourceClient{
+				ResourceInterface: dyn.Resource(gvr).Namespace(namespacer(c.OrgID)),
+				duration:          clientDuration,
+			}, true
 		}
 
 		errorWriter := func(c *contextmodel.ReqContext, err error) {
 			//nolint:errorlint
-			statusError, ok := err.(*errors.StatusError)
+			statusError, ok := err.(*apierrors.StatusError)
 			if ok {
 				c.JsonApiErr(int(statusError.Status().Code),
 					statusError.Status().Message, err)
@@ -74,33 +157,158 @@ func (hs *HTTPServer) registerPlaylistAPI(apiRoute routing.RouteRegister) {
 			errhttp.Write(c.Req.Context(), err, c.Resp)
 		}
 
-		handler.SearchPlaylists = []web.Handler{func(c *contextmodel.ReqContext) {
+		handler.SearchPlaylists = []web.Handler{readAny, func(c *contextmodel.ReqContext) {
 			client, ok := clientGetter(c)
 			if !ok {
 				return // error is already sent
 			}
-			out, err := client.List(c.Req.Context(), v1.ListOptions{})
+			limit, err := hs.playlistSearchPerPage(c)
 			if err != nil {
-				errorWriter(c, err)
+				c.JsonApiErr(http.StatusBadRequest, err.Error(), err)
+				return
+			}
+			itemType, err := playlistSearchItemType(c)
+			if err != nil {
+				c.JsonApiErr(http.StatusBadRequest, err.Error(), err)
+				return
+			}
+			order, err := playlistSearchSort(c)
+			if err != nil {
+				c.JsonApiErr(http.StatusBadRequest, err.Error(), err)
+				return
+			}
+			nameRegex, err := playlistSearchNameRegex(c)
+			if err != nil {
+				c.JsonApiErr(http.StatusBadRequest, err.Error(), err)
+				return
+			}
+			includeItems := c.QueryBool("includeItems")
+			options := v1.ListOptions{Continue: c.Query("continue")}
+			if c.QueryBool("includeTrashed") {
+				options.FieldSelector = v0alpha1.IncludeTrashedField + "=true"
+			}
+			// The items are only included for a page of playlists, so that their number is bounded by the limit
+			if c.Query("perPage") != "" || c.Query("limit") != "" || includeItems {
+				options.Limit = int64(limit)
+			}
+			// The apiserver lists the playlists by UID, so the pages can't be in another order
+			if options.Limit > 0 && c.Query("sort") != "" {
+				c.JsonApiErr(http.StatusBadRequest, "The playlists can't be sorted when they're paged with the Kubernetes API", nil)
 				return
 			}
-
 			query := strings.ToUpper(c.Query("query"))
+			preview := c.QueryInt("preview")
 			playlists := []playlist.Playlist{}
-			for _, item := range out.Items {
-				p := v0alpha1.UnstructuredToLegacyPlaylist(item)
-				if p == nil {
-					continue
+			resourceVersions := map[string]string{}
+			items := map[string][]playlist.PlaylistItemDTO{}
+			var out *unstructured.UnstructuredList
+			for {
+				out, err = client.List(c.Req.Context(), options)
+				if err != nil {
+					errorWriter(c, err)
+					return
+				}
+				for _, item := range out.Items {
+					p := v0alpha1.UnstructuredToLegacyPlaylist(item)
+					if p == nil {
+						continue
+					}
+					if nameRegex != nil && !nameRegex.MatchString(p.Name) {
+						continue // query filter
+					}
+					if nameRegex == nil && query != "" && !strings.Contains(strings.ToUpper(p.Name), query) {
+						continue // query filter
+					}
+					var playlistItems []playlist.PlaylistItemDTO
+					if preview > 0 || includeItems || itemType != "" {
+						playlistItems = v0alpha1.UnstructuredToLegacyPlaylistDTO(item).Items
+					}
+					if itemType != "" && !hasPlaylistItemType(playlistItems, itemType) {
+						continue // item type filter
+					}
+					playlists = append(playlists, *p)
+					resourceVersions[p.UID] = item.GetResourceVersion()
+					if preview > 0 || includeItems {
+						items[p.UID] = playlistItems
+					}
+				}
+				// The filters leave pages short, so the next playlists are listed until the page is full. Only
+				// the missing ones are listed, so that the continue token is the one of the last playlist of the page.
+				options.Continue = out.GetContinue()
+				if options.Limit == 0 || options.Continue == "" || len(playlists) == limit {
+					break
+				}
+				options.Limit = int64(limit - len(playlists))
+			}
+			if options.Continue != "" {
+				c.Resp.Header().Set(playlistContinueHeader, options.Continue)
+			}
+			if options.Limit == 0 {
+				sortPlaylists(playlists, order)
+			}
+			// The version of the list changes with any playlist of the namespace, and not only the ones of the page
+			versions := make([]string, 0, len(playlists)+1)
+			versions = append(versions, "list:"+out.GetResourceVersion())
+			for _, p := range playlists {
+				versions = append(versions, p.UID+":"+resourceVersions[p.UID])
+			}
+
+			if preview <= 0 && !includeItems {
+				if checkETag(c, computeETag(versions)) {
+					c.Resp.WriteHeader(http.StatusNotModified)
+					return
+				}
+				c.JSON(http.StatusOK, playlists)
+				return
+			}
+
+			previews := map[string][]string{}
+			if preview > 0 {
+				previews, err = hs.playlistPreviews(c, items, preview)
+				if err != nil {
+					errorWriter(c, err)
+					return
+				}
+			}
+			results := make([]dtos.PlaylistSearchResult, 0, len(playlists))
+			for i := range playlists {
+				result := dtos.PlaylistSearchResult{Playlist: &playlists[i], Preview: previews[playlists[i].UID]}
+				if includeItems {
+					result.Items = items[playlists[i].UID]
+				}
+				results = append(results, result)
+			}
+			if checkETag(c, computeETag(append(versions, previewVersions(results)...))) {
+				c.Resp.WriteHeader(http.StatusNotModified)
+				return
+			}
+			c.JSON(http.StatusOK, results)
+		}}
+
+		handler.CountPlaylists = []web.Handler{readAny, func(c *contextmodel.ReqContext) {
+			client, ok := clientGetter(c)
+			if !ok {
+				return // error is already sent
+			}
+			// The apiserver doesn't count the playlists, so they're listed a page at a time
+			var count int64
+			options := v1.ListOptions{Limit: playlistCountPageSize}
+			for {
+				out, err := client.List(c.Req.Context(), options)
+				if err != nil {
+					errorWriter(c, err)
+					return
 				}
-				if query != "" && !strings.Contains(strings.ToUpper(p.Name), query) {
-					continue // query filter
+				count += int64(len(out.Items))
+				options.Continue = out.GetContinue()
+				if options.Continue == "" {
+					break
 				}
-				playlists = append(playlists, *p)
 			}
-			c.JSON(http.StatusOK, playlists)
+			c.JSON(http.StatusOK, dtos.PlaylistCount{Count: count})
 		}}
 
-		handler.GetPlaylist = []web.Handler{func(c *contextmodel.ReqContext) {
+		handler.GetPlaylist = []web.Handler{read, func(c *contextmodel.ReqContext) {
 			client, ok := clientGetter(c)
 			if !ok {
 				return // error is already sent
@@ -111,10 +319,11 @@ func (hs *HTTPServer) registerPlaylistAPI(apiRoute routing.RouteRegister) {
 				errorWriter(c, err)
 				return
 			}
-			c.JSON(http.StatusOK, v0alpha1.UnstructuredToLegacyPlaylistDTO(*out))
+			meta := kinds.GrafanaResourceMetadata{Annotations: out.GetAnnotations()}
+			playlistJSONWithETag(c, hs.playlistResponse(c, v0alpha1.UnstructuredToLegacyPlaylistDTO(*out), meta)).WriteTo(c)
 		}}
 
-		handler.GetPlaylistItems = []web.Handler{func(c *contextmodel.ReqContext) {
+		handler.GetPlaylistItems = []web.Handler{read, func(c *contextmodel.ReqContext) {
 			client, ok := clientGetter(c)
 			if !ok {
 				return // error is already sent
@@ -125,40 +334,394 @@ func (hs *HTTPServer) registerPlaylistAPI(apiRoute routing.RouteRegister) {
 				errorWriter(c, err)
 				return
 			}
-			c.JSON(http.StatusOK, v0alpha1.UnstructuredToLegacyPlaylistDTO(*out).Items)
+			playlistJSONWithETag(c, v0alpha1.UnstructuredToLegacyPlaylistDTO(*out).Items).WriteTo(c)
+		}}
+
+		handler.ReorderItems = []web.Handler{write, func(c *contextmodel.ReqContext) {
+			client, ok := clientGetter(c)
+			if !ok {
+				return // error is already sent
+			}
+			cmd := dtos.ReorderPlaylistItemsCommand{}
+			if err := web.Bind(c.Req, &cmd); err != nil {
+				c.JsonApiErr(http.StatusBadRequest, "bad request data", err)
+				return
+			}
+			uid := web.Params(c.Req)[":uid"]
+			out, err := client.Get(c.Req.Context(), uid, v1.GetOptions{})
+			if err != nil {
+				errorWriter(c, err)
+				return
+			}
+
+			// The items are moved as they are, and the resource version makes the patch fail on concurrent edits
+			items, _, err := unstructured.NestedSlice(out.Object, "spec", "items")
+			if err != nil {
+				c.JsonApiErr(http.StatusInternalServerError, "Invalid playlist items", err)
+				return
+			}
+			items, err = reorderPlaylistItems(items, cmd.Order, func(item any) string {
+				m, _ := item.(map[string]any)
+				typ, _ := m["type"].(string)
+				value, _ := m["value"].(string)
+				return playlistItemKey(typ, value)
+			})
+			if err != nil {
+				c.JsonApiErr(http.StatusBadRequest, err.Error(), err)
+				return
+			}
+			patch, err := json.Marshal(map[string]any{
+				"metadata": map[string]any{"resourceVersion": out.GetResourceVersion()},
+				"spec":     map[string]any{"items": items},
+			})
+			if err != nil {
+				c.JsonApiErr(http.StatusInternalServerError, "Failed to reorder playlist items", err)
+				return
+			}
+			out, err = client.Patch(c.Req.Context(), uid, types.MergePatchType, patch, v1.PatchOptions{})
+			if err != nil {
+				errorWriter(c, err)
+				return
+			}
+			c.JSON(http.StatusOK, v0alpha1.UnstructuredToLegacyPlaylistDTO(*out))
+		}}
+
+		handler.GetBatch = []web.Handler{readAny, func(c *contextmodel.ReqContext) {
+			client, ok := clientGetter(c)
+			if !ok {
+				return // error is already sent
+			}
+			// The apiserver can't list playlists by name, so they're fetched one at a time
+			hs.batchGetPlaylists(c, func(ctx context.Context, uid string) (*playlist.PlaylistDTO, *dtos.SkippedPlaylist) {
+				out, err := client.Get(ctx, uid, v1.GetOptions{})
+				if err == nil {
+					return v0alpha1.UnstructuredToLegacyPlaylistDTO(*out), nil
+				}
+				//nolint:errorlint
+				if statusError, ok := err.(*apierrors.StatusError); ok {
+					return nil, &dtos.SkippedPlaylist{Status: int(statusError.Status().Code), Message: statusError.Status().Message}
+				}
+				return nil, &dtos.SkippedPlaylist{Status: http.StatusInternalServerError, Message: "Failed to get playlist"}
+			}).WriteTo(c)
+		}}
+
+		handler.BulkDelete = []web.Handler{deleteAny, func(c *contextmodel.ReqContext) {
+			client, ok := clientGetter(c)
+			if !ok {
+				return // error is already sent
+			}
+			// The client is bound to the namespace of the org, so playlists of other orgs are not found
+			hs.bulkDeletePlaylists(c, func(ctx context.Context, uid string) dtos.BulkDeletePlaylistResult {
+				err := client.Delete(ctx, uid, v1.DeleteOptions{})
+				if err == nil {
+					return dtos.BulkDeletePlaylistResult{Status: http.StatusOK}
+				}
+				//nolint:errorlint
+				if statusError, ok := err.(*apierrors.StatusError); ok {
+					return dtos.BulkDeletePlaylistResult{Status: int(statusError.Status().Code), Message: statusError.Status().Message}
+				}
+				return dtos.BulkDeletePlaylistResult{Status: http.StatusInternalServerError, Message: "Failed to delete playlist"}
+			}).WriteTo(c)
+		}}
+
+		handler.Import = []web.Handler{writeAll, middleware.Quota(hs.QuotaService)(string(playlist.QuotaTargetSrv)), func(c *contextmodel.ReqContext) {
+			client, ok := clientGetter(c)
+			if !ok {
+				return // error is already sent
+			}
+			hs.importPlaylistBundle(c, k8sCreatePlaylist(client)).WriteTo(c)
+		}}
+
+		handler.ImportAll = []web.Handler{writeAll, func(c *contextmodel.ReqContext) {
+			client, ok := clientGetter(c)
+			if !ok {
+				return // error is already sent
+			}
+			hs.importAllPlaylists(c, k8sPlaylistExists(client), k8sCreatePlaylist(client)).WriteTo(c)
 		}}
 	}
 
+	// Writes are rejected in maintenance mode, whichever implementation is used
+	handler.DeletePlaylist = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.DeletePlaylist...)
+	handler.RestorePlaylist = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.RestorePlaylist...)
+	handler.UpdatePlaylist = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.UpdatePlaylist...)
+	handler.CreatePlaylist = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.CreatePlaylist...)
+	handler.ReorderPlaylist = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.ReorderPlaylist...)
+	handler.ReorderItems = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.ReorderItems...)
+	handler.MergePlaylist = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.MergePlaylist...)
+	handler.BulkDelete = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.BulkDelete...)
+	handler.ImportAll = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.ImportAll...)
+	handler.Import = append(chainHandlers(hs.rejectPlaylistWritesInMaintenance), handler.Import...)
+
 	// Register the actual handlers
 	apiRoute.Group("/playlists", func(playlistRoute routing.RouteRegister) {
 		playlistRoute.Get("/", handler.SearchPlaylists...)
+		playlistRoute.Get("/count", handler.CountPlaylists...)
+		playlistRoute.Get("/size-distribution", handler.GetSizes...)
+		playlistRoute.Get("/export-all", handler.ExportAll...)
 		playlistRoute.Get("/:uid", handler.GetPlaylist...)
 		playlistRoute.Get("/:uid/items", handler.GetPlaylistItems...)
+		playlistRoute.Get("/:uid/share-link", handler.GetShareLink...)
+		playlistRoute.Get("/:uid/export", handler.Export...)
+		playlistRoute.Get("/:uid/playback-stats", handler.GetPlaybackStats...)
 		playlistRoute.Delete("/:uid", handler.DeletePlaylist...)
+		playlistRoute.Post("/:uid/restore", handler.RestorePlaylist...)
 		playlistRoute.Put("/:uid", handler.UpdatePlaylist...)
+		playlistRoute.Post("/:uid/reorder", handler.ReorderPlaylist...)
+		playlistRoute.Patch("/:uid/items/reorder", handler.ReorderItems...)
+		playlistRoute.Post("/:uid/merge", handler.MergePlaylist...)
+		playlistRoute.Post("/:uid/playback-events", handler.ReportPlayback...)
+		playlistRoute.Post("/batch", handler.GetBatch...)
+		playlistRoute.Post("/bulk-delete", handler.BulkDelete...)
+		playlistRoute.Post("/import-all", handler.ImportAll...)
+		playlistRoute.Post("/import", handler.Import...)
 		playlistRoute.Post("/", handler.CreatePlaylist...)
+		if hs.Features.IsEnabled(featuremgmt.FlagPlaylistPublicLinks) {
+			playlistRoute.Post("/:uid/public-links", handler.CreatePublicLink...)
+			playlistRoute.Delete("/:uid/public-links/:id", handler.RevokePublicLink...)
+		}
 	})
 }
 
+// playlistUIDScope is the scope of the playlist of the :uid route parameter.
+var playlistUIDScope = playlist.ScopePlaylistsProvider.GetResourceScopeUID(ac.Parameter(":uid"))
+
+// authorizePlaylist returns a handler checking that the signed in user has the playlist permissions of the evaluator.
+// The users whose permissions aren't loaded, as when RBAC isn't in effect for the request, are checked against the
+// given role instead, if any.
+func (hs *HTTPServer) authorizePlaylist(evaluator ac.Evaluator, role ...org.RoleType) web.Handler {
+	authorize := ac.Middleware(hs.AccessControl)(evaluator).(func(*contextmodel.ReqContext))
+	return func(c *contextmodel.ReqContext) {
+		if len(c.SignedInUser.GetPermissions()) > 0 {
+			authorize(c)
+			return
+		}
+		if len(role) > 0 && !c.SignedInUser.HasRole(role[0]) {
+			c.JsonApiErr(http.StatusForbidden, "Permission denied", nil)
+		}
+	}
+}
+
+// hasPlaylistPermission returns whether the signed in user has the permission on the playlist with the given UID,
+// for the checks that depend on the request body. It's true for the users whose permissions aren't loaded, whose
+// role is already checked by authorizePlaylist.
+func (hs *HTTPServer) hasPlaylistPermission(c *contextmodel.ReqContext, action, uid string) (bool, error) {
+	if len(c.SignedInUser.GetPermissions()) == 0 {
+		return true, nil
+	}
+	return hs.AccessControl.Evaluate(c.Req.Context(), c.SignedInUser, ac.EvalPermission(action, playlist.ScopePlaylistsProvider.GetResourceScopeUID(uid)))
+}
+
+// playlistMaintenanceRetryAfter is the number of seconds clients are asked to wait before retrying a write in maintenance mode.
+const playlistMaintenanceRetryAfter = 60
+
+// rejectPlaylistWritesInMaintenance responds with a 503 if the playlists are in maintenance mode.
+// The flag is checked for every request, so the maintenance mode can be toggled without restarting.
+// The playlist service and the apiserver storage reject the writes too, this only answers before any work is done.
+func (hs *HTTPServer) rejectPlaylistWritesInMaintenance(c *contextmodel.ReqContext) {
+	if !hs.Features.IsEnabled(featuremgmt.FlagPlaylistMaintenanceMode) {
+		return
+	}
+	c.Resp.Header().Set("Retry-After", strconv.Itoa(playlistMaintenanceRetryAfter))
+	c.JsonApiErr(http.StatusServiceUnavailable, "Playlists are read-only during maintenance, try again later", nil)
+}
+
+// instrumentedResourceClient is a dynamic.ResourceInterface that observes the duration of the
+// List and Get calls, labeled by verb.
+type instrumentedResourceClient struct {
+	dynamic.ResourceInterface
+	duration *prometheus.HistogramVec
+}
+
+func (c *instrumentedResourceClient) List(ctx context.Context, opts v1.ListOptions) (*unstructured.UnstructuredList, error) {
+	defer c.observe("list", time.Now())
+	return c.ResourceInterface.List(ctx, opts)
+}
+
+func (c *instrumentedResourceClient) Get(ctx context.Context, name string, opts v1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	defer c.observe("get", time.Now())
+	return c.ResourceInterface.Get(ctx, name, opts, subresources...)
+}
+
+func (c *instrumentedResourceClient) observe(verb string, start time.Time) {
+	c.duration.WithLabelValues(verb).Observe(time.Since(start).Seconds())
+}
+
 func (hs *HTTPServer) validateOrgPlaylist(c *contextmodel.ReqContext) {
 	uid := web.Params(c.Req)[":uid"]
-	query := playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
-	p, err := hs.playlistService.GetWithoutItems(c.Req.Context(), &query)
+	if status, message, err := hs.checkOrgPlaylist(c.Req.Context(), c.SignedInUser.GetOrgID(), uid); status != 0 {
+		c.JsonApiErr(status, message, err)
+	}
+}
+
+// checkOrgPlaylist returns the status and message of the error response to send if the playlist with the given
+// UID doesn't exist or doesn't belong to the given org, and a 0 status otherwise.
+func (hs *HTTPServer) checkOrgPlaylist(ctx context.Context, orgID int64, uid string) (int, string, error) {
+	query := playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID}
+	p, err := hs.playlistService.GetWithoutItems(ctx, &query)
 
 	if err != nil {
-		c.JsonApiErr(404, "Playlist not found", err)
-		return
+		if errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return http.StatusNotFound, "Playlist not found", err
+		}
+		return http.StatusInternalServerError, "Failed to get playlist", err
 	}
 
 	if p.OrgId == 0 {
-		c.JsonApiErr(404, "Playlist not found", err)
-		return
+		return http.StatusNotFound, "Playlist not found", err
 	}
 
-	if p.OrgId != c.SignedInUser.GetOrgID() {
-		c.JsonApiErr(403, "You are not allowed to edit/view playlist", nil)
-		return
+	if p.OrgId != orgID {
+		return http.StatusForbidden, "You are not allowed to edit/view playlist", nil
+	}
+	return 0, "", nil
+}
+
+const (
+	// playlistSearchDefaultMaxPerPage is the maximum and default number of playlists returned by a search,
+	// when the [playlists] search_max_limit setting isn't set.
+	playlistSearchDefaultMaxPerPage = 1000
+	// playlistTotalCountHeader is the response header with the number of playlists matching a search, across pages.
+	playlistTotalCountHeader = "X-Total-Count"
+	// playlistContinueHeader is the response header with the token of the next page of a search, if there's one.
+	playlistContinueHeader = "X-Continue"
+)
+
+// playlistSearchPerPage returns the number of playlists per page requested with the perPage query parameter,
+// or with the older limit one, capped to the [playlists] search_max_limit setting. The maximum is returned
+// when neither is set or they're zero, and an error is returned when they're negative.
+func (hs *HTTPServer) playlistSearchPerPage(c *contextmodel.ReqContext) (int, error) {
+	maxPerPage := hs.Cfg.Playlist.SearchMaxLimit
+	if maxPerPage <= 0 {
+		maxPerPage = playlistSearchDefaultMaxPerPage
+	}
+	perPage, limit := c.QueryInt("perPage"), c.QueryInt("limit")
+	if perPage < 0 || limit < 0 {
+		return 0, errors.New("perPage and limit must not be negative")
 	}
+	if perPage == 0 {
+		perPage = limit
+	}
+	if perPage == 0 || perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	return perPage, nil
+}
+
+// playlistSearchItemType returns the item type requested with the type query parameter, or an error if it
+// isn't one of the dashboard item types. It's empty if the parameter isn't set.
+func playlistSearchItemType(c *contextmodel.ReqContext) (string, error) {
+	switch itemType := c.Query("type"); itemType {
+	case "", "dashboard_by_tag", "dashboard_by_uid", "dashboard_by_id":
+		return itemType, nil
+	default:
+		return "", fmt.Errorf("invalid item type %q", itemType)
+	}
+}
+
+// playlistSearchNameRegex returns the regular expression of the names requested with the query parameter when
+// queryType is regex, or nil when the names are matched by substring. It returns an error if the pattern isn't valid.
+func playlistSearchNameRegex(c *contextmodel.ReqContext) (*regexp.Regexp, error) {
+	switch queryType := c.Query("queryType"); queryType {
+	case "":
+		return nil, nil
+	case "regex":
+		re, err := regexp.Compile(c.Query("query"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid query regex: %w", err)
+		}
+		return re, nil
+	default:
+		return nil, fmt.Errorf("invalid query type %q", queryType)
+	}
+}
+
+// playlistSearchSort returns the order requested with the sort query parameter, or an error if it isn't
+// one of the playlist.Sort constants. The playlists are ordered by name by default.
+func playlistSearchSort(c *contextmodel.ReqContext) (string, error) {
+	switch order := c.Query("sort"); order {
+	case "":
+		return playlist.SortNameAsc, nil
+	case playlist.SortNameAsc, playlist.SortNameDesc, playlist.SortCreatedAsc, playlist.SortCreatedDesc:
+		return order, nil
+	default:
+		return "", fmt.Errorf("invalid sort %q", order)
+	}
+}
+
+// sortPlaylists sorts the playlists in the given order, as the playlist store does.
+func sortPlaylists(playlists []playlist.Playlist, order string) {
+	sort.SliceStable(playlists, func(i, j int) bool {
+		a, b := playlists[i], playlists[j]
+		if order == playlist.SortNameDesc || order == playlist.SortCreatedDesc {
+			a, b = b, a
+		}
+		switch order {
+		case playlist.SortNameAsc, playlist.SortNameDesc:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case playlist.SortCreatedAsc, playlist.SortCreatedDesc:
+			if a.CreatedAt != b.CreatedAt {
+				return a.CreatedAt < b.CreatedAt
+			}
+		}
+		if a.Id != b.Id {
+			return a.Id < b.Id
+		}
+		return a.UID < b.UID
+	})
+}
+
+// hasPlaylistItemType returns whether some of the items are of the given type.
+func hasPlaylistItemType(items []playlist.PlaylistItemDTO, itemType string) bool {
+	for _, item := range items {
+		if item.Type == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// playlistGetError returns a 404 response if the playlist doesn't exist, and a 500 response for the other errors.
+func playlistGetError(err error) response.Response {
+	if errors.Is(err, playlist.ErrPlaylistNotFound) {
+		return response.Error(http.StatusNotFound, "Playlist not found", err)
+	}
+	return response.Error(http.StatusInternalServerError, "Failed to get playlist", err)
+}
+
+// playlistCreateError returns the error response of a failed playlist creation.
+func playlistCreateError(err error) response.Response {
+	return playlistWriteError(err, "Failed to create playlist")
+}
+
+// playlistWriteError returns the error response of a failed playlist write, with the given message for the
+// unexpected errors.
+func playlistWriteError(err error, message string) response.Response {
+	if errors.Is(err, playlist.ErrMaintenanceMode) {
+		return response.Error(http.StatusServiceUnavailable, "Playlists are read-only during maintenance, try again later", err).
+			SetHeader("Retry-After", strconv.Itoa(playlistMaintenanceRetryAfter))
+	}
+	if isPlaylistValidationError(err) {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	if errors.Is(err, playlist.ErrPlaylistAlreadyExists) {
+		return response.Error(http.StatusConflict, "A playlist with the same UID already exists", err)
+	}
+	//nolint:errorlint
+	if statusError, ok := err.(*apierrors.StatusError); ok {
+		return response.Error(int(statusError.Status().Code), statusError.Status().Message, err)
+	}
+	return response.Error(http.StatusInternalServerError, message, err)
+}
+
+// isPlaylistValidationError returns whether err is a validation error of the playlist or its items.
+func isPlaylistValidationError(err error) bool {
+	return errors.Is(err, playlist.ErrInvalidInterval) || errors.Is(err, playlist.ErrExternalURLNotAllowed) || errors.Is(err, playlist.ErrInvalidItemInterval) ||
+		errors.Is(err, playlist.ErrInvalidRecentlyViewed) || errors.Is(err, playlist.ErrInvalidSectionLabel) ||
+		errors.Is(err, playlist.ErrInvalidPlaylistRef) || errors.Is(err, playlist.ErrInvalidPlaylistUID) ||
+		apierrors.IsInvalid(err) || apierrors.IsBadRequest(err)
 }
 
 // swagger:route GET /playlists playlists searchPlaylists
@@ -167,160 +730,1189 @@ func (hs *HTTPServer) validateOrgPlaylist(c *contextmodel.ReqContext) {
 //
 // Responses:
 // 200: searchPlaylistsResponse
+// 400: badRequestError
 // 500: internalServerError
 func (hs *HTTPServer) SearchPlaylists(c *contextmodel.ReqContext) response.Response {
 	query := c.Query("query")
-	limit := c.QueryInt("limit")
+	limit, err := hs.playlistSearchPerPage(c)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	itemType, err := playlistSearchItemType(c)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	order, err := playlistSearchSort(c)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	nameRegex, err := playlistSearchNameRegex(c)
+	if err != nil {
+		return response.Error(http.StatusBadRequest, err.Error(), err)
+	}
+	page := c.QueryInt("page")
+	preview := c.QueryInt("preview")
+	includeItems := c.QueryBool("includeItems")
+	includeTrashed := c.QueryBool("includeTrashed")
+
+	if page < 1 {
+		page = 1
+	}
+
+	// The previews depend on the dashboards too, so they're only validated with the ETag
+	if preview <= 0 {
+		lastUpdated, err := hs.playlistService.GetLastUpdated(c.Req.Context(), &playlist.GetLastUpdatedQuery{OrgId: c.SignedInUser.GetOrgID()})
+		if err != nil {
+			return response.Error(500, "Search failed", err)
+		}
+		if checkLastModified(c, lastUpdated, time.Now()) {
+			return response.Empty(http.StatusNotModified)
+		}
+	}
+
+	if nameRegex != nil {
+		query = ""
+	}
+	searchQuery := playlist.GetPlaylistsQuery{
+		Name:           query,
+		NameRegex:      nameRegex,
+		Limit:          limit,
+		Page:           page,
+		ItemType:       itemType,
+		Sort:           order,
+		IncludeTrashed: includeTrashed,
+		OrgId:          c.SignedInUser.GetOrgID(),
+	}
+
+	playlists, err := hs.playlistService.Search(c.Req.Context(), &searchQuery)
+	if err != nil {
+		return response.Error(500, "Search failed", err)
+	}
+	stats, err := hs.playlistService.SearchStats(c.Req.Context(), &searchQuery)
+	if err != nil {
+		return response.Error(500, "Search failed", err)
+	}
+	c.Resp.Header().Set(playlistTotalCountHeader, strconv.FormatInt(stats.Count, 10))
+
+	// The ETag covers all the matching playlists, and not only the page, so that it changes with the total count
+	// and with the playlists moving between pages
+	versions := make([]string, 0, len(playlists)+1)
+	versions = append(versions, fmt.Sprintf("stats:%d:%d", stats.Count, stats.LastUpdated))
+	for _, p := range playlists {
+		versions = append(versions, fmt.Sprintf("%s:%d", p.UID, p.UpdatedAt))
+	}
+
+	if preview <= 0 && !includeItems {
+		if checkETag(c, computeETag(versions)) {
+			return response.Empty(http.StatusNotModified)
+		}
+		return response.JSON(http.StatusOK, playlists)
+	}
+
+	// The items are loaded for the playlists of the page only, so they're bounded by the search limit.
+	uids := make([]string, 0, len(playlists))
+	for _, p := range playlists {
+		uids = append(uids, p.UID)
+	}
+	items, err := hs.playlistService.GetItemsByUIDs(c.Req.Context(), &playlist.GetPlaylistsItemsByUidsQuery{
+		PlaylistUIDs:   uids,
+		OrgId:          c.SignedInUser.GetOrgID(),
+		IncludeTrashed: includeTrashed,
+	})
+	if err != nil {
+		return response.Error(500, "Search failed", err)
+	}
+	previews := map[string][]string{}
+	if preview > 0 {
+		previews, err = hs.playlistPreviews(c, items, preview)
+		if err != nil {
+			return response.Error(500, "Search failed", err)
+		}
+	}
+
+	results := make([]dtos.PlaylistSearchResult, 0, len(playlists))
+	for _, p := range playlists {
+		result := dtos.PlaylistSearchResult{Playlist: p, Preview: previews[p.UID]}
+		if includeItems {
+			result.Items = items[p.UID]
+		}
+		results = append(results, result)
+	}
+	// Dashboard titles are not versioned with the playlist, so they're part of the ETag too.
+	if checkETag(c, computeETag(append(versions, previewVersions(results)...))) {
+		return response.Empty(http.StatusNotModified)
+	}
 
-	if limit == 0 {
-		limit = 1000
+	return response.JSON(http.StatusOK, results)
+}
+
+// maxPlaylistPreview is the maximum number of dashboard titles included in a playlist preview.
+const maxPlaylistPreview = 10
+
+// playlistPreviews returns the titles of the first n dashboards of each playlist, keyed by playlist UID.
+// Only the dashboards resolved by playlistDashboards are included.
+func (hs *HTTPServer) playlistPreviews(c *contextmodel.ReqContext, items map[string][]playlist.PlaylistItemDTO, n int) (map[string][]string, error) {
+	if n > maxPlaylistPreview {
+		n = maxPlaylistPreview
+	}
+
+	allItems := []playlist.PlaylistItemDTO{}
+	for _, playlistItems := range items {
+		allItems = append(allItems, playlistItems...)
+	}
+	resolved, err := hs.playlistDashboards(c.Req.Context(), c.SignedInUser, allItems)
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make(map[string][]string, len(items))
+	for playlistUID, playlistItems := range items {
+		titles := []string{}
+		for _, item := range playlistItems {
+			if len(titles) == n {
+				break
+			}
+			if hit, ok := resolved.get(item); ok {
+				titles = append(titles, hit.Title)
+			}
+		}
+		previews[playlistUID] = titles
+	}
+	return previews, nil
+}
+
+// resolvedDashboards are the dashboards of playlist items, keyed by UID and by ID.
+type resolvedDashboards struct {
+	byUID map[string]*model.Hit
+	byID  map[int64]*model.Hit
+}
+
+// get returns the dashboard of the given item. Dashboards by tag are not expanded, and recently viewed
+// dashboards aren't resolved on the backend, as they depend on the user playing the playlist. Sections
+// have no dashboard.
+func (d resolvedDashboards) get(item playlist.PlaylistItemDTO) (*model.Hit, bool) {
+	var hit *model.Hit
+	switch v0alpha1.ItemType(item.Type) {
+	case v0alpha1.ItemTypeDashboardByUid:
+		hit = d.byUID[item.Value]
+	case v0alpha1.ItemTypeDashboardById:
+		if id, err := strconv.ParseInt(item.Value, 10, 64); err == nil {
+			hit = d.byID[id]
+		}
+	}
+	return hit, hit != nil
+}
+
+// playlistDashboards resolves the dashboards of the given items with a single search per identifier type,
+// which only returns the dashboards the given user can view, so any other dashboard is left out.
+func (hs *HTTPServer) playlistDashboards(ctx context.Context, signedInUser *user.SignedInUser, items []playlist.PlaylistItemDTO) (resolvedDashboards, error) {
+	uids := map[string]bool{}
+	ids := map[int64]bool{}
+	for _, item := range items {
+		switch v0alpha1.ItemType(item.Type) {
+		case v0alpha1.ItemTypeDashboardByUid:
+			uids[item.Value] = true
+		case v0alpha1.ItemTypeDashboardById:
+			if id, err := strconv.ParseInt(item.Value, 10, 64); err == nil {
+				ids[id] = true
+			}
+		}
+	}
+
+	searchQuery := func() search.Query {
+		return search.Query{
+			SignedInUser: signedInUser,
+			OrgId:        signedInUser.GetOrgID(),
+			Type:         string(model.DashHitDB),
+			Permission:   dashboards.PERMISSION_VIEW,
+		}
+	}
+	resolved := resolvedDashboards{byUID: map[string]*model.Hit{}, byID: map[int64]*model.Hit{}}
+	if len(uids) > 0 {
+		query := searchQuery()
+		query.Limit = int64(len(uids))
+		for uid := range uids {
+			query.DashboardUIDs = append(query.DashboardUIDs, uid)
+		}
+		hits, err := hs.SearchService.SearchHandler(ctx, &query)
+		if err != nil {
+			return resolved, err
+		}
+		for _, hit := range hits {
+			resolved.byUID[hit.UID] = hit
+		}
+	}
+	if len(ids) > 0 {
+		query := searchQuery()
+		query.Limit = int64(len(ids))
+		for id := range ids {
+			query.DashboardIds = append(query.DashboardIds, id)
+		}
+		hits, err := hs.SearchService.SearchHandler(ctx, &query)
+		if err != nil {
+			return resolved, err
+		}
+		for _, hit := range hits {
+			resolved.byID[hit.ID] = hit
+		}
+	}
+	return resolved, nil
+}
+
+// previewVersions returns the version identifiers of the previews in the given search results, for computeETag.
+func previewVersions(results []dtos.PlaylistSearchResult) []string {
+	versions := make([]string, 0, len(results))
+	for _, r := range results {
+		versions = append(versions, r.UID+":preview:"+strings.Join(r.Preview, "\x00"))
+	}
+	return versions
+}
+
+// computeETag returns a strong ETag for a result set, given the version identifiers of its entries in order.
+func computeETag(versions []string) string {
+	h := sha256.New()
+	for _, v := range versions {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// checkETag sets the ETag header on the response and reports whether the representation
+// the client already has, identified by the If-None-Match request header, is still current.
+func checkETag(c *contextmodel.ReqContext, etag string) bool {
+	c.Resp.Header().Set("ETag", etag)
+	return c.Req.Header.Get("If-None-Match") == etag
+}
+
+// playlistJSONWithETag returns a JSON response with a strong ETag, the hash of the JSON, or a 304 response
+// if it's the representation the client already has. The ETag covers the whole representation, so the
+// different response versions of the same playlist have different ETags.
+func playlistJSONWithETag(c *contextmodel.ReqContext, body any) response.Response {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to marshal the playlist", err)
+	}
+	if checkETag(c, computeETag([]string{string(b)})) {
+		return response.Empty(http.StatusNotModified)
+	}
+	return response.JSON(http.StatusOK, b)
+}
+
+// checkLastModified sets the Last-Modified header on the response, given the last update time in milliseconds,
+// and reports whether the representation the client already has, dated by the If-Modified-Since request header,
+// is still current. If-Modified-Since is ignored when If-None-Match is set, as the ETag is more precise.
+//
+// Last-Modified has a precision of a second, so it's the end of the second of the last update, and it's only
+// set once that second is over: a later update is then necessarily after it, even within the same second.
+func checkLastModified(c *contextmodel.ReqContext, lastUpdated int64, now time.Time) bool {
+	if lastUpdated <= 0 {
+		return false
+	}
+	modified := time.UnixMilli(lastUpdated).UTC().Truncate(time.Second).Add(time.Second)
+	if modified.After(now) {
+		return false
+	}
+	c.Resp.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	if c.Req.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(c.Req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// swagger:route GET /playlists/{uid} playlists getPlaylist
+//
+// Get playlist.
+//
+// Responses:
+// 200: getPlaylistResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) GetPlaylist(c *contextmodel.ReqContext) response.Response {
+	uid := web.Params(c.Req)[":uid"]
+	cmd := playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
+
+	dto, err := hs.playlistService.Get(c.Req.Context(), &cmd)
+	if err != nil {
+		return playlistGetError(err)
+	}
+
+	return playlistJSONWithETag(c, hs.playlistResponse(c, dto, kinds.GrafanaResourceMetadata{}))
+}
+
+// playlistResponse returns the playlist in the response shape requested by the client.
+// The version 2 shape is returned when requested with "Accept: application/json;version=2"
+// and featuremgmt.FlagPlaylistResponseV2 is enabled, the current shape is returned otherwise.
+func (hs *HTTPServer) playlistResponse(c *contextmodel.ReqContext, dto *playlist.PlaylistDTO, meta kinds.GrafanaResourceMetadata) any {
+	if !hs.Features.IsEnabled(featuremgmt.FlagPlaylistResponseV2) {
+		return dto
+	}
+	c.Resp.Header().Add("Vary", "Accept")
+	if requestedResponseVersion(c) == 2 {
+		return dtos.NewPlaylistV2(dto, meta)
+	}
+	return dto
+}
+
+// requestedResponseVersion returns the "version" parameter of the JSON media type in the Accept header.
+// It defaults to 1 when it's not set.
+func requestedResponseVersion(c *contextmodel.ReqContext) int {
+	for _, accept := range strings.Split(c.Req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		if version, err := strconv.Atoi(params["version"]); err == nil {
+			return version
+		}
+	}
+	return 1
+}
+
+// swagger:route GET /playlists/{uid}/items playlists getPlaylistItems
+//
+// Get playlist items.
+//
+// Responses:
+// 200: getPlaylistItemsResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) GetPlaylistItems(c *contextmodel.ReqContext) response.Response {
+	uid := web.Params(c.Req)[":uid"]
+	cmd := playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
+
+	dto, err := hs.playlistService.Get(c.Req.Context(), &cmd)
+	if err != nil {
+		return playlistGetError(err)
+	}
+
+	return playlistJSONWithETag(c, dto.Items)
+}
+
+// Playback modes supported by the playlist share links, matching the modes of the playlist start modal.
+const (
+	playlistModeNormal = "normal"
+	playlistModeTV     = "tv"
+	playlistModeKiosk  = "kiosk"
+)
+
+// swagger:route GET /playlists/{uid}/share-link playlists getPlaylistShareLink
+//
+// Get a link starting the playback of the playlist.
+//
+// Responses:
+// 200: getPlaylistShareLinkResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) GetPlaylistShareLink(c *contextmodel.ReqContext) response.Response {
+	uid := web.Params(c.Req)[":uid"]
+	mode := c.Query("mode")
+	if mode == "" {
+		mode = playlistModeNormal
+	}
+	params := url.Values{}
+	switch mode {
+	case playlistModeNormal:
+	case playlistModeTV:
+		params.Set("kiosk", playlistModeTV)
+	case playlistModeKiosk:
+		params.Set("kiosk", "true")
+	default:
+		return response.Error(http.StatusBadRequest, "Invalid playlist mode, expected one of normal, tv or kiosk", nil)
+	}
+	if c.QueryBool("autofit") {
+		params.Set("autofitpanels", "true")
+	}
+
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()})
+	if err != nil {
+		return playlistGetError(err)
+	}
+
+	// The item is the index of a dashboard of the playback, in which the tag items expand to their dashboards
+	item := 0
+	if c.Query("item") != "" {
+		n, err := hs.playlistPlaybackLength(c.Req.Context(), c.SignedInUser, dto.Items)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+		}
+		item, err = strconv.Atoi(c.Query("item"))
+		if err != nil || item < 0 || item >= n {
+			return response.Error(http.StatusBadRequest, fmt.Sprintf("Invalid playlist item, expected the index of one of its %d dashboards", n), nil)
+		}
+	}
+	params.Set("item", strconv.Itoa(item))
+
+	link := fmt.Sprintf("%splaylists/play/%s?%s", hs.Cfg.AppURL, url.PathEscape(uid), params.Encode())
+	return response.JSON(http.StatusOK, dtos.PlaylistShareLink{URL: link})
+}
+
+// playlistTagDashboardsLimit is the maximum number of dashboards a tag item resolves to in the playback,
+// like in the frontend.
+const playlistTagDashboardsLimit = 1000
+
+// playlistPlaybackLength returns the number of dashboards the playback of the given items goes through for the given
+// user, as the frontend resolves them: the tag items expand to the dashboards with the tag, and the dashboards the
+// user can't view are left out. The recently viewed items count as their maximum number of dashboards, since the
+// dashboards are only known to the browser of the user.
+func (hs *HTTPServer) playlistPlaybackLength(ctx context.Context, signedInUser *user.SignedInUser, items []playlist.PlaylistItemDTO) (int, error) {
+	resolved, err := hs.playlistDashboards(ctx, signedInUser, items)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, item := range items {
+		switch item.Type {
+		case playlist.ItemTypeRecentlyViewed:
+			if max, err := strconv.Atoi(item.Value); err == nil && max > 0 {
+				n += max
+			}
+		case string(v0alpha1.ItemTypeDashboardByTag):
+			tagged, err := hs.playlistTagDashboards(ctx, signedInUser, item.Value, playlistTagDashboardsLimit, "")
+			if err != nil {
+				return 0, err
+			}
+			n += len(tagged)
+		default:
+			if _, ok := resolved.get(item); ok {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// playlistTagDashboards returns up to limit dashboards with the given tag that the given user can view,
+// in the order of the given search sort option, or of their titles if it's empty.
+func (hs *HTTPServer) playlistTagDashboards(ctx context.Context, signedInUser *user.SignedInUser, tag string, limit int64, sort string) (model.HitList, error) {
+	return hs.SearchService.SearchHandler(ctx, &search.Query{
+		SignedInUser: signedInUser,
+		OrgId:        signedInUser.GetOrgID(),
+		Type:         string(model.DashHitDB),
+		Tags:         []string{tag},
+		Limit:        limit,
+		Sort:         sort,
+		Permission:   dashboards.PERMISSION_VIEW,
+	})
+}
+
+// playlistTagMaxDashboardsDefault is the number of dashboards a tag item resolves to on the server when the
+// [playlists] tag_max_dashboards setting isn't set.
+const playlistTagMaxDashboardsDefault = 100
+
+// playlistResolvedTagDashboards returns the dashboards a tag item resolves to on the server for the given user: up to
+// the [playlists] tag_max_dashboards setting, selected in the order of the tag_dashboards_selection one. A warning is
+// returned when the tag has more dashboards, which are left out.
+func (hs *HTTPServer) playlistResolvedTagDashboards(ctx context.Context, signedInUser *user.SignedInUser, tag string) (model.HitList, string, error) {
+	limit := hs.Cfg.Playlist.TagMaxDashboards
+	if limit <= 0 {
+		limit = playlistTagMaxDashboardsDefault
+	}
+	// One more dashboard is searched to tell whether the tag has more
+	tagged, err := hs.playlistTagDashboards(ctx, signedInUser, tag, int64(limit)+1, hs.Cfg.Playlist.TagDashboardsSelection)
+	if err != nil || len(tagged) <= limit {
+		return tagged, "", err
+	}
+	return tagged[:limit], fmt.Sprintf("Tag %q has more than %d dashboards, only %d of them are included", tag, limit, limit), nil
+}
+
+// playlistCountPageSize is the number of playlists listed at a time to count them with the apiserver.
+const playlistCountPageSize = 500
+
+// swagger:route GET /playlists/count playlists countPlaylists
+//
+// Get the number of playlists of the current organization.
+//
+// Responses:
+// 200: countPlaylistsResponse
+// 401: unauthorisedError
+// 500: internalServerError
+func (hs *HTTPServer) CountPlaylists(c *contextmodel.ReqContext) response.Response {
+	count, err := hs.playlistService.SearchCount(c.Req.Context(), &playlist.GetPlaylistsQuery{OrgId: c.SignedInUser.GetOrgID()})
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to count playlists", err)
+	}
+	return response.JSON(http.StatusOK, dtos.PlaylistCount{Count: count})
+}
+
+// swagger:route GET /playlists/size-distribution playlists getPlaylistSizeDistribution
+//
+// Get the number of playlists of the current organization per number of items.
+//
+// Responses:
+// 200: getPlaylistSizeDistributionResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 500: internalServerError
+func (hs *HTTPServer) GetPlaylistSizeDistribution(c *contextmodel.ReqContext) response.Response {
+	sizes, err := hs.playlistService.GetSizeDistribution(c.Req.Context(), &playlist.GetPlaylistSizeDistributionQuery{OrgId: c.SignedInUser.GetOrgID()})
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to get playlist sizes", err)
+	}
+
+	dist := dtos.PlaylistSizeDistribution{Sizes: sizes}
+	for _, size := range sizes {
+		dist.Total += size.Playlists
+	}
+	return response.JSON(http.StatusOK, dist)
+}
+
+// swagger:route DELETE /playlists/{uid} playlists deletePlaylist
+//
+// Delete playlist.
+//
+// Responses:
+// 200: okResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) DeletePlaylist(c *contextmodel.ReqContext) response.Response {
+	uid := web.Params(c.Req)[":uid"]
+
+	cmd := playlist.DeletePlaylistCommand{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
+	if err := hs.playlistService.Delete(c.Req.Context(), &cmd); err != nil {
+		return response.Error(500, "Failed to delete playlist", err)
+	}
+
+	return response.JSON(http.StatusOK, "")
+}
+
+// swagger:route POST /playlists/{uid}/restore playlists restorePlaylist
+//
+// Restore a playlist from the trash.
+//
+// The deleted playlists are moved to the trash when the [playlists] trash_retention setting is set,
+// and can be restored until they're purged, once the retention is over. Their UID stays taken until then,
+// so a playlist can't be created with it.
+//
+// Responses:
+// 200: getPlaylistResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) RestorePlaylist(c *contextmodel.ReqContext) response.Response {
+	uid := web.Params(c.Req)[":uid"]
+
+	// The playlists in the trash are only found by the org they belong to
+	cmd := playlist.RestorePlaylistCommand{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
+	if _, err := hs.playlistService.Restore(c.Req.Context(), &cmd); err != nil {
+		if errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return response.Error(http.StatusNotFound, "Playlist not found in the trash", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to restore playlist", err)
+	}
+
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: cmd.OrgId})
+	if err != nil {
+		return playlistGetError(err)
+	}
+	return response.JSON(http.StatusOK, dto)
+}
+
+// swagger:route POST /playlists/batch playlists batchGetPlaylists
+//
+// Get several playlists.
+//
+// The playlists that the user can't read, or that don't exist, are left out of the playlists of the response,
+// and reported in its skipped playlists with the status they'd have been returned with on their own.
+//
+// Responses:
+// 200: batchGetPlaylistsResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 500: internalServerError
+func (hs *HTTPServer) BatchGetPlaylists(c *contextmodel.ReqContext) response.Response {
+	orgID := c.SignedInUser.GetOrgID()
+	return hs.batchGetPlaylists(c, func(ctx context.Context, uid string) (*playlist.PlaylistDTO, *dtos.SkippedPlaylist) {
+		dto, err := hs.playlistService.Get(ctx, &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID})
+		if errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return nil, &dtos.SkippedPlaylist{Status: http.StatusNotFound, Message: "Playlist not found"}
+		}
+		if err != nil {
+			hs.log.FromContext(ctx).Error("Failed to get playlist", "uid", uid, "error", err)
+			return nil, &dtos.SkippedPlaylist{Status: http.StatusInternalServerError, Message: "Failed to get playlist"}
+		}
+		return dto, nil
+	})
+}
+
+// batchGetPlaylists gets each playlist of the request that the user can read with the given function, and
+// responds with the playlists found and the skipped ones. The number of UIDs is capped like the search limit.
+func (hs *HTTPServer) batchGetPlaylists(c *contextmodel.ReqContext, getPlaylist func(ctx context.Context, uid string) (*playlist.PlaylistDTO, *dtos.SkippedPlaylist)) response.Response {
+	cmd := dtos.BatchGetPlaylistsCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+	if len(cmd.UIDs) == 0 {
+		return response.Error(http.StatusBadRequest, "Missing playlist UIDs", nil)
+	}
+	maxUIDs := hs.Cfg.Playlist.SearchMaxLimit
+	if maxUIDs <= 0 {
+		maxUIDs = playlistSearchDefaultMaxPerPage
+	}
+	if len(cmd.UIDs) > maxUIDs {
+		return response.Error(http.StatusBadRequest, fmt.Sprintf("At most %d playlists can be requested at once", maxUIDs), nil)
+	}
+
+	batch := dtos.PlaylistBatch{Playlists: []*playlist.PlaylistDTO{}, Skipped: map[string]dtos.SkippedPlaylist{}}
+	seen := make(map[string]bool, len(cmd.UIDs))
+	for _, uid := range cmd.UIDs {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		allowed, err := hs.hasPlaylistPermission(c, playlist.ActionPlaylistsRead, uid)
+		if err != nil {
+			hs.log.FromContext(c.Req.Context()).Error("Failed to evaluate playlist permissions", "uid", uid, "error", err)
+			batch.Skipped[uid] = dtos.SkippedPlaylist{Status: http.StatusInternalServerError, Message: "Failed to get playlist"}
+			continue
+		}
+		if !allowed {
+			batch.Skipped[uid] = dtos.SkippedPlaylist{Status: http.StatusForbidden, Message: "Permission denied"}
+			continue
+		}
+		dto, skipped := getPlaylist(c.Req.Context(), uid)
+		if skipped != nil {
+			batch.Skipped[uid] = *skipped
+			continue
+		}
+		batch.Playlists = append(batch.Playlists, dto)
+	}
+	return response.JSON(http.StatusOK, batch)
+}
+
+// swagger:route POST /playlists/bulk-delete playlists bulkDeletePlaylists
+//
+// Delete several playlists.
+//
+// Each playlist is deleted on its own, so the playlists that can't be deleted don't prevent the deletion of the
+// other ones. The response has the result of each deletion by UID, with a 207 status if some of them failed.
+//
+// Responses:
+// 200: bulkDeletePlaylistsResponse
+// 207: bulkDeletePlaylistsResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 500: internalServerError
+func (hs *HTTPServer) BulkDeletePlaylists(c *contextmodel.ReqContext) response.Response {
+	orgID := c.SignedInUser.GetOrgID()
+	return hs.bulkDeletePlaylists(c, func(ctx context.Context, uid string) dtos.BulkDeletePlaylistResult {
+		if status, message, _ := hs.checkOrgPlaylist(ctx, orgID, uid); status != 0 {
+			return dtos.BulkDeletePlaylistResult{Status: status, Message: message}
+		}
+		if err := hs.playlistService.Delete(ctx, &playlist.DeletePlaylistCommand{UID: uid, OrgId: orgID}); err != nil {
+			hs.log.FromContext(ctx).Error("Failed to delete playlist", "uid", uid, "error", err)
+			return dtos.BulkDeletePlaylistResult{Status: http.StatusInternalServerError, Message: "Failed to delete playlist"}
+		}
+		return dtos.BulkDeletePlaylistResult{Status: http.StatusOK}
+	})
+}
+
+// bulkDeletePlaylists deletes each playlist of the request with the given function, and responds with
+// the results by UID.
+func (hs *HTTPServer) bulkDeletePlaylists(c *contextmodel.ReqContext, deletePlaylist func(ctx context.Context, uid string) dtos.BulkDeletePlaylistResult) response.Response {
+	cmd := dtos.BulkDeletePlaylistsCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+	if len(cmd.UIDs) == 0 {
+		return response.Error(http.StatusBadRequest, "Missing playlist UIDs", nil)
+	}
+
+	status := http.StatusOK
+	results := make(map[string]dtos.BulkDeletePlaylistResult, len(cmd.UIDs))
+	for _, uid := range cmd.UIDs {
+		if _, ok := results[uid]; ok {
+			continue
+		}
+		var result dtos.BulkDeletePlaylistResult
+		if allowed, err := hs.hasPlaylistPermission(c, playlist.ActionPlaylistsDelete, uid); err != nil {
+			hs.log.FromContext(c.Req.Context()).Error("Failed to evaluate playlist permissions", "uid", uid, "error", err)
+			result = dtos.BulkDeletePlaylistResult{Status: http.StatusInternalServerError, Message: "Failed to delete playlist"}
+		} else if !allowed {
+			result = dtos.BulkDeletePlaylistResult{Status: http.StatusForbidden, Message: "Permission denied"}
+		} else {
+			result = deletePlaylist(c.Req.Context(), uid)
+		}
+		if result.Status != http.StatusOK {
+			status = http.StatusMultiStatus
+		}
+		results[uid] = result
+	}
+	return response.JSON(status, results)
+}
+
+// swagger:route POST /playlists playlists createPlaylist
+//
+// Create playlist.
+//
+// A playlist can't be created with the UID of a playlist in the trash, which gives a 409 until the playlist is
+// restored and deleted again, or purged once the [playlists] trash_retention is over.
+//
+// Responses:
+// 200: createPlaylistResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 409: conflictError
+// 500: internalServerError
+func (hs *HTTPServer) CreatePlaylist(c *contextmodel.ReqContext) response.Response {
+	cmd := playlist.CreatePlaylistCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
-
-	searchQuery := playlist.GetPlaylistsQuery{
-		Name:  query,
-		Limit: limit,
-		OrgId: c.SignedInUser.GetOrgID(),
+	cmd.OrgId = c.SignedInUser.GetOrgID()
+	if c.QueryBool("dedupe") {
+		cmd.Dedupe = true
+	}
+	if c.QueryBool("validateItems") {
+		if resp := hs.validatePlaylistDashboards(c, cmd.Items); resp != nil {
+			return resp
+		}
 	}
 
-	playlists, err := hs.playlistService.Search(c.Req.Context(), &searchQuery)
+	p, err := hs.playlistService.Create(c.Req.Context(), &cmd)
 	if err != nil {
-		return response.Error(500, "Search failed", err)
+		return playlistCreateError(err)
 	}
 
-	return response.JSON(http.StatusOK, playlists)
+	return response.JSON(http.StatusOK, p)
 }
 
-// swagger:route GET /playlists/{uid} playlists getPlaylist
+// swagger:route PUT /playlists/{uid} playlists updatePlaylist
 //
-// Get playlist.
+// Update playlist.
 //
 // Responses:
-// 200: getPlaylistResponse
+// 200: updatePlaylistResponse
+// 400: badRequestError
 // 401: unauthorisedError
 // 403: forbiddenError
 // 404: notFoundError
+// 409: conflictError
 // 500: internalServerError
-func (hs *HTTPServer) GetPlaylist(c *contextmodel.ReqContext) response.Response {
-	uid := web.Params(c.Req)[":uid"]
-	cmd := playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
+func (hs *HTTPServer) UpdatePlaylist(c *contextmodel.ReqContext) response.Response {
+	cmd := playlist.UpdatePlaylistCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+	cmd.OrgId = c.SignedInUser.GetOrgID()
+	cmd.UID = web.Params(c.Req)[":uid"]
+	if c.QueryBool("dedupe") {
+		cmd.Dedupe = true
+	}
+	if c.QueryBool("validateItems") {
+		if resp := hs.validatePlaylistDashboards(c, cmd.Items); resp != nil {
+			return resp
+		}
+	}
 
-	dto, err := hs.playlistService.Get(c.Req.Context(), &cmd)
+	_, err := hs.playlistService.Update(c.Req.Context(), &cmd)
 	if err != nil {
-		return response.Error(500, "Playlist not found", err)
+		return playlistWriteError(err, "Failed to save playlist")
 	}
 
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{
+		UID:   cmd.UID,
+		OrgId: c.SignedInUser.GetOrgID(),
+	})
+	if err != nil {
+		return response.Error(500, "Failed to load playlist", err)
+	}
 	return response.JSON(http.StatusOK, dto)
 }
 
-// swagger:route GET /playlists/{uid}/items playlists getPlaylistItems
-//
-// Get playlist items.
-//
-// Responses:
-// 200: getPlaylistItemsResponse
-// 401: unauthorisedError
-// 403: forbiddenError
-// 404: notFoundError
-// 500: internalServerError
-func (hs *HTTPServer) GetPlaylistItems(c *contextmodel.ReqContext) response.Response {
-	uid := web.Params(c.Req)[":uid"]
-	cmd := playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
-
-	dto, err := hs.playlistService.Get(c.Req.Context(), &cmd)
+// validatePlaylistDashboards checks that the dashboard_by_uid items resolve to dashboards the signed in user
+// can view, and returns a bad request response listing the other UIDs, or nil if they all resolve. The other
+// items, like dashboards by tag, aren't validated.
+func (hs *HTTPServer) validatePlaylistDashboards(c *contextmodel.ReqContext, items []playlist.PlaylistItem) response.Response {
+	dtoItems := make([]playlist.PlaylistItemDTO, 0, len(items))
+	for _, item := range items {
+		if v0alpha1.ItemType(item.Type) == v0alpha1.ItemTypeDashboardByUid {
+			dtoItems = append(dtoItems, playlist.PlaylistItemDTO{Type: item.Type, Value: item.Value})
+		}
+	}
+	if len(dtoItems) == 0 {
+		return nil
+	}
+	resolved, err := hs.playlistDashboards(c.Req.Context(), c.SignedInUser, dtoItems)
 	if err != nil {
-		return response.Error(500, "Playlist not found", err)
+		return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
 	}
 
-	return response.JSON(http.StatusOK, dto.Items)
+	invalid := []string{}
+	seen := map[string]bool{}
+	for _, item := range dtoItems {
+		if _, ok := resolved.get(item); !ok && !seen[item.Value] {
+			seen[item.Value] = true
+			invalid = append(invalid, item.Value)
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return response.JSON(http.StatusBadRequest, util.DynMap{
+		"message":       "Some dashboards of the playlist don't exist or can't be viewed",
+		"dashboardUids": invalid,
+	})
 }
 
-// swagger:route DELETE /playlists/{uid} playlists deletePlaylist
+// Sort keys and directions supported by ReorderPlaylist.
+const (
+	playlistReorderByTitle   = "title"
+	playlistReorderByUpdated = "updated"
+	playlistReorderAsc       = "asc"
+	playlistReorderDesc      = "desc"
+)
+
+// swagger:route POST /playlists/{uid}/reorder playlists reorderPlaylist
 //
-// Delete playlist.
+// Sort the items of a playlist by dashboard title or last update, and save the new order.
+//
+// Items whose dashboard can't be resolved, like dashboards by tag, are moved to the end in their current order.
 //
 // Responses:
-// 200: okResponse
+// 200: updatePlaylistResponse
+// 400: badRequestError
 // 401: unauthorisedError
 // 403: forbiddenError
 // 404: notFoundError
+// 409: conflictError
 // 500: internalServerError
-func (hs *HTTPServer) DeletePlaylist(c *contextmodel.ReqContext) response.Response {
+func (hs *HTTPServer) ReorderPlaylist(c *contextmodel.ReqContext) response.Response {
 	uid := web.Params(c.Req)[":uid"]
+	by := c.Query("by")
+	if by != playlistReorderByTitle && by != playlistReorderByUpdated {
+		return response.Error(http.StatusBadRequest, "Invalid sort key, expected title or updated", nil)
+	}
+	dir := c.Query("dir")
+	if dir == "" {
+		dir = playlistReorderAsc
+	}
+	if dir != playlistReorderAsc && dir != playlistReorderDesc {
+		return response.Error(http.StatusBadRequest, "Invalid sort direction, expected asc or desc", nil)
+	}
 
-	cmd := playlist.DeletePlaylistCommand{UID: uid, OrgId: c.SignedInUser.GetOrgID()}
-	if err := hs.playlistService.Delete(c.Req.Context(), &cmd); err != nil {
-		return response.Error(500, "Failed to delete playlist", err)
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()})
+	if err != nil {
+		return playlistGetError(err)
+	}
+	resolved, err := hs.playlistDashboards(c.Req.Context(), c.SignedInUser, dto.Items)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
 	}
 
-	return response.JSON(http.StatusOK, "")
+	var updated map[string]time.Time
+	if by == playlistReorderByUpdated {
+		query := &dashboards.GetDashboardsQuery{OrgID: c.SignedInUser.GetOrgID()}
+		for _, hit := range resolved.byUID {
+			query.DashboardUIDs = append(query.DashboardUIDs, hit.UID)
+		}
+		for _, hit := range resolved.byID {
+			query.DashboardUIDs = append(query.DashboardUIDs, hit.UID)
+		}
+		updated = make(map[string]time.Time, len(query.DashboardUIDs))
+		if len(query.DashboardUIDs) > 0 {
+			dashes, err := hs.DashboardService.GetDashboards(c.Req.Context(), query)
+			if err != nil {
+				return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+			}
+			for _, dash := range dashes {
+				updated[dash.UID] = dash.Updated
+			}
+		}
+	}
+
+	// less reports whether a sorts before b, both being resolved
+	less := func(a, b *model.Hit) bool {
+		if by == playlistReorderByUpdated {
+			return updated[a.UID].Before(updated[b.UID])
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	}
+	items := dto.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		a, aOK := resolved.get(items[i])
+		b, bOK := resolved.get(items[j])
+		if !aOK || !bOK {
+			return aOK && !bOK
+		}
+		if dir == playlistReorderDesc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+
+	cmd := playlist.UpdatePlaylistCommand{
+		OrgId:    c.SignedInUser.GetOrgID(),
+		UID:      uid,
+		Name:     dto.Name,
+		Interval: dto.Interval,
+		Items:    playlistItemsFromDTO(items),
+	}
+	if _, err := hs.playlistService.Update(c.Req.Context(), &cmd); err != nil {
+		return playlistWriteError(err, "Failed to save playlist")
+	}
+
+	dto, err = hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()})
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to load playlist", err)
+	}
+	return response.JSON(http.StatusOK, dto)
 }
 
-// swagger:route POST /playlists playlists createPlaylist
+// swagger:route PATCH /playlists/{uid}/items/reorder playlists reorderPlaylistItems
 //
-// Create playlist.
+// Set the order of the items of a playlist, without changing the items.
+//
+// The order must have the key of every item of the playlist, and nothing else, so that items added or
+// removed concurrently are not lost. The key of an item is its type and value, separated by a colon.
 //
 // Responses:
-// 200: createPlaylistResponse
+// 200: updatePlaylistResponse
+// 400: badRequestError
 // 401: unauthorisedError
 // 403: forbiddenError
 // 404: notFoundError
+// 409: conflictError
 // 500: internalServerError
-func (hs *HTTPServer) CreatePlaylist(c *contextmodel.ReqContext) response.Response {
-	cmd := playlist.CreatePlaylistCommand{}
+func (hs *HTTPServer) ReorderPlaylistItems(c *contextmodel.ReqContext) response.Response {
+	cmd := dtos.ReorderPlaylistItemsCommand{}
 	if err := web.Bind(c.Req, &cmd); err != nil {
 		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
-	cmd.OrgId = c.SignedInUser.GetOrgID()
+	uid := web.Params(c.Req)[":uid"]
+	orgID := c.SignedInUser.GetOrgID()
 
-	p, err := hs.playlistService.Create(c.Req.Context(), &cmd)
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID})
+	if err != nil {
+		return playlistGetError(err)
+	}
+	items, err := reorderPlaylistItems(dto.Items, cmd.Order, func(item playlist.PlaylistItemDTO) string {
+		return playlistItemKey(item.Type, item.Value)
+	})
 	if err != nil {
-		return response.Error(500, "Failed to create playlist", err)
+		return response.Error(http.StatusBadRequest, err.Error(), err)
 	}
 
-	return response.JSON(http.StatusOK, p)
+	update := playlist.UpdatePlaylistCommand{
+		OrgId:    orgID,
+		UID:      uid,
+		Name:     dto.Name,
+		Interval: dto.Interval,
+		Items:    playlistItemsFromDTO(items),
+	}
+	if _, err := hs.playlistService.Update(c.Req.Context(), &update); err != nil {
+		return playlistWriteError(err, "Failed to save playlist")
+	}
+
+	dto, err = hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID})
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to load playlist", err)
+	}
+	return response.JSON(http.StatusOK, dto)
 }
 
-// swagger:route PUT /playlists/{uid} playlists updatePlaylist
+// playlistItemKey returns the key identifying an item when reordering the items of a playlist.
+func playlistItemKey(typ, value string) string {
+	return typ + ":" + value
+}
+
+// reorderPlaylistItems returns the items in the given order of keys. The order must have the key of every item,
+// as many times as there are items with that key, and nothing else. Items with the same key keep their
+// relative order.
+func reorderPlaylistItems[T any](items []T, order []string, key func(T) string) ([]T, error) {
+	if len(order) != len(items) {
+		return nil, fmt.Errorf("the order has %d items, the playlist has %d", len(order), len(items))
+	}
+	byKey := make(map[string][]T, len(items))
+	for _, item := range items {
+		byKey[key(item)] = append(byKey[key(item)], item)
+	}
+	out := make([]T, 0, len(items))
+	for _, k := range order {
+		same := byKey[k]
+		if len(same) == 0 {
+			return nil, fmt.Errorf("the playlist has no other item %q", k)
+		}
+		out = append(out, same[0])
+		byKey[k] = same[1:]
+	}
+	return out, nil
+}
+
+// swagger:route POST /playlists/{uid}/merge playlists mergePlaylist
 //
-// Update playlist.
+// Append the items of another playlist of the organization to a playlist.
+//
+// Duplicated items, with the same type and value, can optionally be skipped, and the source playlist deleted once merged.
 //
 // Responses:
 // 200: updatePlaylistResponse
+// 400: badRequestError
 // 401: unauthorisedError
 // 403: forbiddenError
 // 404: notFoundError
+// 409: conflictError
 // 500: internalServerError
-func (hs *HTTPServer) UpdatePlaylist(c *contextmodel.ReqContext) response.Response {
-	cmd := playlist.UpdatePlaylistCommand{}
+func (hs *HTTPServer) MergePlaylist(c *contextmodel.ReqContext) response.Response {
+	cmd := dtos.MergePlaylistCommand{}
 	if err := web.Bind(c.Req, &cmd); err != nil {
 		return response.Error(http.StatusBadRequest, "bad request data", err)
 	}
-	cmd.OrgId = c.SignedInUser.GetOrgID()
-	cmd.UID = web.Params(c.Req)[":uid"]
+	uid := web.Params(c.Req)[":uid"]
+	if cmd.SourceUID == "" {
+		return response.Error(http.StatusBadRequest, "Missing source playlist", nil)
+	}
+	if cmd.SourceUID == uid {
+		return response.Error(http.StatusBadRequest, "A playlist can't be merged into itself", nil)
+	}
 
-	_, err := hs.playlistService.Update(c.Req.Context(), &cmd)
+	// The items of the source are read, and it's deleted if requested
+	sourceActions := []string{playlist.ActionPlaylistsRead}
+	if cmd.DeleteSource {
+		sourceActions = append(sourceActions, playlist.ActionPlaylistsDelete)
+	}
+	for _, action := range sourceActions {
+		if allowed, err := hs.hasPlaylistPermission(c, action, cmd.SourceUID); err != nil || !allowed {
+			return response.Error(http.StatusForbidden, "Permission denied on the source playlist", err)
+		}
+	}
+
+	orgID := c.SignedInUser.GetOrgID()
+	target, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID})
+	if err != nil {
+		return playlistGetError(err)
+	}
+	// The source is looked up in the org of the user, so playlists of other orgs are not found
+	source, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: cmd.SourceUID, OrgId: orgID})
 	if err != nil {
-		return response.Error(500, "Failed to save playlist", err)
+		if errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return response.Error(http.StatusNotFound, "Source playlist not found", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to load source playlist", err)
 	}
 
-	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{
-		UID:   cmd.UID,
-		OrgId: c.SignedInUser.GetOrgID(),
-	})
+	// Items are duplicates if they have the same type and value, whatever their title
+	type itemKey struct{ typ, value string }
+	items := append([]playlist.PlaylistItemDTO{}, target.Items...)
+	seen := make(map[itemKey]bool, len(items))
+	for _, item := range items {
+		seen[itemKey{item.Type, item.Value}] = true
+	}
+	for _, item := range source.Items {
+		key := itemKey{item.Type, item.Value}
+		if cmd.Dedup && seen[key] {
+			continue
+		}
+		seen[key] = true
+		items = append(items, item)
+	}
+
+	update := playlist.UpdatePlaylistCommand{
+		OrgId:    orgID,
+		UID:      uid,
+		Name:     target.Name,
+		Interval: target.Interval,
+		Items:    playlistItemsFromDTO(items),
+	}
+	if _, err := hs.playlistService.Update(c.Req.Context(), &update); err != nil {
+		return playlistWriteError(err, "Failed to save playlist")
+	}
+	if cmd.DeleteSource {
+		if err := hs.playlistService.Delete(c.Req.Context(), &playlist.DeletePlaylistCommand{UID: source.Uid, OrgId: orgID}); err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to delete source playlist", err)
+		}
+	}
+
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID})
 	if err != nil {
-		return response.Error(500, "Failed to load playlist", err)
+		return response.Error(http.StatusInternalServerError, "Failed to load playlist", err)
 	}
 	return response.JSON(http.StatusOK, dto)
 }
 
+// playlistItemsFromDTO converts the items of a playlist.PlaylistDTO back to the items of an update command.
+func playlistItemsFromDTO(items []playlist.PlaylistItemDTO) []playlist.PlaylistItem {
+	out := make([]playlist.PlaylistItem, 0, len(items))
+	for _, item := range items {
+		pi := playlist.PlaylistItem{Type: item.Type, Value: item.Value, Interval: item.Interval}
+		if item.Title != nil {
+			pi.Title = *item.Title
+		}
+		out = append(out, pi)
+	}
+	return out
+}
+
 // swagger:parameters searchPlaylists
 type SearchPlaylistsParams struct {
 	// in:query
 	// required:false
 	Query string `json:"query"`
+	// How the query matches the names of the playlists: a case-insensitive substring by default, or a regular
+	// expression with regex, e.g. ^team-.*-prod$. Invalid regular expressions are rejected.
+	// in:query
+	// required:false
+	// enum: regex
+	QueryType string `json:"queryType"`
+	// The number of playlists to return, capped to the search_max_limit setting. Negative values are rejected.
 	// in:limit
 	// required:false
 	Limit int `json:"limit"`
+	// Include the titles of up to this many dashboards of each playlist in the results.
+	// in:query
+	// required:false
+	Preview int `json:"preview"`
+	// Include the items of each playlist in the results, as returned by the items endpoint.
+	// in:query
+	// required:false
+	IncludeItems bool `json:"includeItems"`
+	// Include the playlists in the trash, with their deletedAt time, in the results.
+	// in:query
+	// required:false
+	IncludeTrashed bool `json:"includeTrashed"`
+	// The page of results to return, starting at 1. The number of matching playlists is returned in the X-Total-Count header.
+	// in:query
+	// required:false
+	Page int `json:"page"`
+	// The number of playlists per page, capped to the search_max_limit setting, which is 1000 by default. It replaces limit.
+	// in:query
+	// required:false
+	PerPage int `json:"perPage"`
+	// The token of the page to return with the Kubernetes playlists API, as returned in the X-Continue header.
+	// in:query
+	// required:false
+	Continue string `json:"continue"`
+	// Only return the playlists with at least one item of this type.
+	// in:query
+	// required:false
+	// enum: dashboard_by_tag,dashboard_by_uid,dashboard_by_id
+	Type string `json:"type"`
+	// The order of the playlists. With the Kubernetes playlists API, the pages are in the order of the playlist UIDs,
+	// and the sort is rejected when the playlists are paged.
+	// in:query
+	// required:false
+	// default: name-asc
+	// enum: name-asc,name-desc,created-asc,created-desc
+	Sort string `json:"sort"`
 }
 
 // swagger:parameters getPlaylist
@@ -337,6 +1929,25 @@ type GetPlaylistItemsParams struct {
 	UID string `json:"uid"`
 }
 
+// swagger:parameters getPlaylistShareLink
+type GetPlaylistShareLinkParams struct {
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+	// Index of the dashboard the playback starts at, among the dashboards the items resolve to.
+	// in:query
+	// required:false
+	Item int `json:"item"`
+	// Playback mode, one of normal, tv or kiosk.
+	// in:query
+	// required:false
+	Mode string `json:"mode"`
+	// Adjust the panel heights to fit the screen.
+	// in:query
+	// required:false
+	Autofit bool `json:"autofit"`
+}
+
 // swagger:parameters getPlaylistDashboards
 type GetPlaylistDashboardsParams struct {
 	// in:path
@@ -344,6 +1955,55 @@ type GetPlaylistDashboardsParams struct {
 	UID string `json:"uid"`
 }
 
+// swagger:parameters reorderPlaylist
+type ReorderPlaylistParams struct {
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+	// Sort key, one of title or updated.
+	// in:query
+	// required:true
+	By string `json:"by"`
+	// Sort direction, one of asc or desc. Defaults to asc.
+	// in:query
+	// required:false
+	Dir string `json:"dir"`
+}
+
+// swagger:parameters reorderPlaylistItems
+type ReorderPlaylistItemsParams struct {
+	// in:body
+	// required:true
+	Body dtos.ReorderPlaylistItemsCommand
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+}
+
+// swagger:parameters mergePlaylist
+type MergePlaylistParams struct {
+	// in:body
+	// required:true
+	Body dtos.MergePlaylistCommand
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+}
+
+// swagger:parameters batchGetPlaylists
+type BatchGetPlaylistsParams struct {
+	// in:body
+	// required:true
+	Body dtos.BatchGetPlaylistsCommand
+}
+
+// swagger:parameters bulkDeletePlaylists
+type BulkDeletePlaylistsParams struct {
+	// in:body
+	// required:true
+	Body dtos.BulkDeletePlaylistsCommand
+}
+
 // swagger:parameters deletePlaylist
 type DeletePlaylistParams struct {
 	// in:path
@@ -351,6 +2011,13 @@ type DeletePlaylistParams struct {
 	UID string `json:"uid"`
 }
 
+// swagger:parameters restorePlaylist
+type RestorePlaylistParams struct {
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+}
+
 // swagger:parameters updatePlaylist
 type UpdatePlaylistParams struct {
 	// in:body
@@ -359,6 +2026,14 @@ type UpdatePlaylistParams struct {
 	// in:path
 	// required:true
 	UID string `json:"uid"`
+	// Remove the items with the same type and value as a previous item, like the dedupe field of the body.
+	// in:query
+	// required:false
+	Dedupe bool `json:"dedupe"`
+	// Reject the playlist if any dashboard_by_uid item doesn't resolve to a dashboard the user can view.
+	// in:query
+	// required:false
+	ValidateItems bool `json:"validateItems"`
 }
 
 // swagger:parameters createPlaylist
@@ -366,6 +2041,14 @@ type CreatePlaylistParams struct {
 	// in:body
 	// required:true
 	Body playlist.CreatePlaylistCommand
+	// Remove the items with the same type and value as a previous item, like the dedupe field of the body.
+	// in:query
+	// required:false
+	Dedupe bool `json:"dedupe"`
+	// Reject the playlist if any dashboard_by_uid item doesn't resolve to a dashboard the user can view.
+	// in:query
+	// required:false
+	ValidateItems bool `json:"validateItems"`
 }
 
 // swagger:response searchPlaylistsResponse
@@ -389,6 +2072,27 @@ type GetPlaylistItemsResponse struct {
 	Body []playlist.PlaylistItemDTO `json:"body"`
 }
 
+// swagger:response getPlaylistShareLinkResponse
+type GetPlaylistShareLinkResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PlaylistShareLink `json:"body"`
+}
+
+// swagger:response countPlaylistsResponse
+type CountPlaylistsResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PlaylistCount `json:"body"`
+}
+
+// swagger:response getPlaylistSizeDistributionResponse
+type GetPlaylistSizeDistributionResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PlaylistSizeDistribution `json:"body"`
+}
+
 // swagger:response getPlaylistDashboardsResponse
 type GetPlaylistDashboardsResponse struct {
 	// The response message
@@ -409,3 +2113,17 @@ type CreatePlaylistResponse struct {
 	// in: body
 	Body *playlist.Playlist `json:"body"`
 }
+
+// swagger:response batchGetPlaylistsResponse
+type BatchGetPlaylistsResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PlaylistBatch `json:"body"`
+}
+
+// swagger:response bulkDeletePlaylistsResponse
+type BulkDeletePlaylistsResponse struct {
+	// The result of the deletion of each playlist, by UID.
+	// in: body
+	Body map[string]dtos.BulkDeletePlaylistResult `json:"body"`
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+)
+
+// Access paths through which the dashboards of a playlist are exposed, as logged by the playlistAccessLogger.
+const (
+	playlistAccessPublicLink          = "public_link"
+	playlistAccessPublicLinkDashboard = "public_link_dashboard"
+	playlistAccessExport              = "export"
+)
+
+const (
+	// playlistAccessLogInterval is the minimum time between two entries of the same actor for the same
+	// playlist and access path, so that a wallboard polling a public link doesn't flood the logs.
+	playlistAccessLogInterval = time.Minute
+	// playlistAccessLogMaxKeys bounds the number of entries remembered for the rate limiting.
+	// Entries are dropped if it's reached and none of the remembered ones has expired.
+	playlistAccessLogMaxKeys = 10000
+)
+
+type playlistAccessKey struct {
+	orgID int64
+	uid   string
+	actor string
+	path  string
+}
+
+// playlistAccessLogger logs which dashboards of a playlist were exposed, to whom and through which
+// access path, for auditing. The entries are structured and rate limited per actor, playlist and path.
+type playlistAccessLogger struct {
+	log log.Logger
+	now func() time.Time
+
+	mu     sync.Mutex
+	logged map[playlistAccessKey]time.Time
+}
+
+func newPlaylistAccessLogger(logger log.Logger) *playlistAccessLogger {
+	return &playlistAccessLogger{
+		log:    logger,
+		now:    time.Now,
+		logged: map[playlistAccessKey]time.Time{},
+	}
+}
+
+// logAccess logs the UIDs of the dashboards of a playlist exposed to the given actor,
+// unless the same access was already logged less than playlistAccessLogInterval ago.
+func (l *playlistAccessLogger) logAccess(key playlistAccessKey, dashboardUIDs []string) {
+	if !l.allow(key) {
+		return
+	}
+	l.log.Info("Playlist dashboards accessed", "orgId", key.orgID, "playlistUid", key.uid, "actor", key.actor,
+		"path", key.path, "dashboardUids", dashboardUIDs)
+}
+
+func (l *playlistAccessLogger) allow(key playlistAccessKey) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if last, ok := l.logged[key]; ok && now.Sub(last) < playlistAccessLogInterval {
+		return false
+	}
+	if len(l.logged) >= playlistAccessLogMaxKeys {
+		for k, last := range l.logged {
+			if now.Sub(last) >= playlistAccessLogInterval {
+				delete(l.logged, k)
+			}
+		}
+		if len(l.logged) >= playlistAccessLogMaxKeys {
+			return false
+		}
+	}
+	l.logged[key] = now
+	return true
+}
+
+// playlistActor returns the namespaced ID of the signed in user, e.g. user:1, for the access log.
+func playlistActor(c *contextmodel.ReqContext) string {
+	namespace, id := c.SignedInUser.GetNamespacedID()
+	return namespace + ":" + id
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/infra/log/logtest"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/playlist/playlisttest"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/services/user/usertest"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web/webtest"
+)
+
+func TestPlaylistAccessLogger(t *testing.T) {
+	logger := &logtest.Fake{}
+	now := time.Now()
+	l := newPlaylistAccessLogger(logger)
+	l.now = func() time.Time { return now }
+	key := playlistAccessKey{orgID: 1, uid: "a", actor: "user:1", path: playlistAccessExport}
+
+	l.logAccess(key, []string{"dash-a"})
+	require.Equal(t, 1, logger.InfoLogs.Calls)
+	require.Equal(t, "Playlist dashboards accessed", logger.InfoLogs.Message)
+
+	t.Run("Should rate limit the entries of the same access", func(t *testing.T) {
+		l.logAccess(key, []string{"dash-a"})
+		require.Equal(t, 1, logger.InfoLogs.Calls)
+
+		other := key
+		other.actor = "user:2"
+		l.logAccess(other, []string{"dash-a"})
+		require.Equal(t, 2, logger.InfoLogs.Calls)
+
+		now = now.Add(playlistAccessLogInterval)
+		l.logAccess(key, []string{"dash-a"})
+		require.Equal(t, 3, logger.InfoLogs.Calls)
+	})
+
+	t.Run("Should bound the number of entries remembered", func(t *testing.T) {
+		for i := 0; i < playlistAccessLogMaxKeys; i++ {
+			l.logAccess(playlistAccessKey{orgID: 1, uid: "many", actor: "user:" + strconv.Itoa(i)}, nil)
+		}
+		require.LessOrEqual(t, len(l.logged), playlistAccessLogMaxKeys)
+
+		// The expired entries make room for the new ones
+		now = now.Add(playlistAccessLogInterval)
+		calls := logger.InfoLogs.Calls
+		l.logAccess(playlistAccessKey{orgID: 1, uid: "new"}, nil)
+		require.Equal(t, calls+1, logger.InfoLogs.Calls)
+		require.Len(t, l.logged, 1)
+	})
+}
+
+func TestAPIEndpoint_PlaylistAccessLog(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "Wallboard", Interval: "1m", Items: []playlist.PlaylistItemDTO{
+		{Type: "dashboard_by_uid", Value: "dash-a"},
+		{Type: "dashboard_by_tag", Value: "status"},
+	}}
+	dashboardService := dashboards.NewFakeDashboardService(t)
+	dashboardService.On("GetDashboard", mock.Anything, mock.Anything).Return(&dashboards.Dashboard{
+		UID: "dash-a", Slug: "dash-a", Data: simplejson.NewFromAny(map[string]any{"title": "Dashboard A"}),
+	}, nil).Maybe()
+
+	cfg := setting.NewCfg()
+	cfg.SecretKey = "secret"
+	kv := kvstore.NewFakeKVStore()
+	var httpServer *HTTPServer
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		httpServer = hs
+		hs.Cfg = cfg
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagPlaylistPublicLinks)
+		hs.playlistService = playlistService
+		hs.DashboardService = dashboardService
+		hs.SearchService = &mockSearchService{ExpectedResult: model.HitList{{UID: "dash-a", Title: "Dashboard A"}, {UID: "dash-b", Title: "Dashboard B"}}}
+		hs.userService = &usertest.FakeUserService{ExpectedSignedInUser: &user.SignedInUser{UserID: 1, OrgID: 1}}
+		hs.accesscontrolService = &actest.FakeService{}
+		hs.kvStore = kv
+	})
+	logger := &logtest.Fake{}
+	httpServer.playlistAccessLog.log = logger
+
+	requireAccessLog := func(t *testing.T, req *http.Request, actor, path string, dashboardUIDs []string) {
+		t.Helper()
+		calls := logger.InfoLogs.Calls
+		res, err := server.Send(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		require.Equal(t, calls+1, logger.InfoLogs.Calls)
+		require.Equal(t, "Playlist dashboards accessed", logger.InfoLogs.Message)
+		require.Equal(t, []any{
+			"orgId", int64(1), "playlistUid", "a", "actor", actor, "path", path, "dashboardUids", dashboardUIDs,
+		}, logger.InfoLogs.Ctx)
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		req := webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists/a/export"), &user.SignedInUser{UserID: 2, OrgID: 1, OrgRole: org.RoleViewer})
+		requireAccessLog(t, req, "user:2", playlistAccessExport, []string{"dash-a"})
+	})
+
+	t.Run("Public link", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		token, err := signPublicPlaylistToken(cfg.SecretKey, publicPlaylistClaims{ID: "link-1", OrgID: 1, UID: "a", CreatedBy: 1, ExpiresAt: expiresAt})
+		require.NoError(t, err)
+		require.NoError(t, publicPlaylistLinks(kv, 1).Set(context.Background(), publicPlaylistLinkKey("a", "link-1"), strconv.FormatInt(expiresAt, 10)))
+
+		requireAccessLog(t, server.NewGetRequest("/api/public/playlists/"+token), "public-link:link-1", playlistAccessPublicLink, []string{"dash-a", "dash-b"})
+		requireAccessLog(t, server.NewGetRequest("/api/public/playlists/"+token+"/dashboards/dash-a"), "public-link:link-1", playlistAccessPublicLinkDashboard, []string{"dash-a"})
+	})
+}
@@ -0,0 +1,498 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/apis/playlist/v0alpha1"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// playlistArchivePageSize is the number of playlists read at once while exporting the playlists of an org.
+const playlistArchivePageSize = 100
+
+// swagger:route GET /playlists/export-all playlists exportAllPlaylists
+//
+// Export all the playlists of the organization.
+//
+// The archive is streamed, a page of playlists at a time, and can be imported with the import-all endpoint.
+//
+// Responses:
+// 200: exportAllPlaylistsResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 500: internalServerError
+func (hs *HTTPServer) ExportAllPlaylists(c *contextmodel.ReqContext) response.Response {
+	// The first page is read before anything is written, so that the failures to list the playlists
+	// are still reported with an error status
+	first, more, err := hs.playlistArchivePage(c, 1)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to export playlists", err)
+	}
+	return &playlistArchiveResponse{hs: hs, first: first, more: more}
+}
+
+// playlistArchivePage returns a page of the playlists of the org of the user, with their items,
+// and whether there may be more pages.
+func (hs *HTTPServer) playlistArchivePage(c *contextmodel.ReqContext, page int) ([]playlist.PlaylistDTO, bool, error) {
+	orgID := c.SignedInUser.GetOrgID()
+	playlists, err := hs.playlistService.Search(c.Req.Context(), &playlist.GetPlaylistsQuery{OrgId: orgID, Limit: playlistArchivePageSize, Page: page})
+	if err != nil {
+		return nil, false, err
+	}
+	out := make([]playlist.PlaylistDTO, 0, len(playlists))
+	for _, p := range playlists {
+		dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID})
+		if err != nil {
+			if errors.Is(err, playlist.ErrPlaylistNotFound) {
+				continue // deleted since the search
+			}
+			return nil, false, err
+		}
+		out = append(out, *dto)
+	}
+	return out, len(playlists) == playlistArchivePageSize, nil
+}
+
+// playlistArchiveResponse streams a dtos.PlaylistArchive, so that the playlists of an org are never
+// all in memory at once.
+type playlistArchiveResponse struct {
+	hs    *HTTPServer
+	first []playlist.PlaylistDTO
+	more  bool
+}
+
+func (r *playlistArchiveResponse) Status() int {
+	return http.StatusOK
+}
+
+func (r *playlistArchiveResponse) Body() []byte {
+	return nil
+}
+
+func (r *playlistArchiveResponse) WriteTo(c *contextmodel.ReqContext) {
+	c.Resp.Header().Set("Content-Type", "application/json")
+	c.Resp.Header().Set("Content-Disposition", `attachment;filename="playlists.json"`)
+	c.Resp.WriteHeader(http.StatusOK)
+
+	// The status is already sent if a later page fails, so the archive is left truncated, which fails its import
+	write := func(b []byte) bool {
+		if _, err := c.Resp.Write(b); err != nil {
+			c.Logger.Error("Failed to write the playlists archive", "error", err)
+			return false
+		}
+		return true
+	}
+	if !write([]byte(`{"playlists":[`)) {
+		return
+	}
+	playlists, more, written := r.first, r.more, 0
+	for page := 1; ; page++ {
+		if page > 1 {
+			var err error
+			if playlists, more, err = r.hs.playlistArchivePage(c, page); err != nil {
+				c.Logger.Error("Failed to export playlists", "page", page, "error", err)
+				return
+			}
+		}
+		for _, p := range playlists {
+			b, err := json.Marshal(p)
+			if err != nil {
+				c.Logger.Error("Failed to export playlist", "uid", p.Uid, "error", err)
+				return
+			}
+			if written > 0 {
+				b = append([]byte(","), b...)
+			}
+			if !write(b) {
+				return
+			}
+			written++
+		}
+		c.Resp.Flush()
+		if !more {
+			break
+		}
+	}
+	write([]byte("]}\n"))
+}
+
+// swagger:route GET /playlists/{uid}/export playlists exportPlaylist
+//
+// Export a playlist as a bundle, to move it to another Grafana instance.
+//
+// The bundle has the titles of the dashboards of the dashboard_by_uid items, so that they can be mapped to
+// the dashboards of the target instance. The other items, such as the dashboards by tag, are kept as they are.
+//
+// With includeResolved=true, the bundle also has a snapshot of the dashboards the playlist resolves to, with
+// their titles and tags, the tag items expanded to the dashboards currently having the tag. The tag items resolve
+// to up to the [playlists] tag_max_dashboards setting, with a warning for the tags having more dashboards.
+//
+// Responses:
+// 200: exportPlaylistResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) ExportPlaylist(c *contextmodel.ReqContext) response.Response {
+	uid := web.Params(c.Req)[":uid"]
+	dto, err := hs.playlistService.Get(c.Req.Context(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: c.SignedInUser.GetOrgID()})
+	if err != nil {
+		return playlistGetError(err)
+	}
+	resolved, err := hs.playlistDashboards(c.Req.Context(), c.SignedInUser, dto.Items)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+	}
+
+	bundle := dtos.PlaylistBundle{
+		APIVersion: dtos.PlaylistBundleAPIVersion,
+		Playlist:   *dto,
+		Dashboards: []dtos.PlaylistBundleDashboard{},
+	}
+	seen := map[string]bool{}
+	for _, item := range dto.Items {
+		if v0alpha1.ItemType(item.Type) != v0alpha1.ItemTypeDashboardByUid || seen[item.Value] {
+			continue
+		}
+		if hit, ok := resolved.get(item); ok {
+			seen[item.Value] = true
+			bundle.Dashboards = append(bundle.Dashboards, dtos.PlaylistBundleDashboard{UID: hit.UID, Title: hit.Title})
+		}
+	}
+	exposed := make([]string, 0, len(bundle.Dashboards))
+	for _, d := range bundle.Dashboards {
+		exposed = append(exposed, d.UID)
+	}
+	if c.QueryBool("includeResolved") {
+		bundle.Resolved, bundle.Warnings, err = hs.playlistResolvedDashboards(c.Req.Context(), c.SignedInUser, dto.Items, resolved)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+		}
+		exposed = exposed[:0]
+		for _, d := range bundle.Resolved {
+			exposed = append(exposed, d.UID)
+		}
+	}
+	hs.playlistAccessLog.logAccess(playlistAccessKey{orgID: c.SignedInUser.GetOrgID(), uid: dto.Uid, actor: playlistActor(c), path: playlistAccessExport}, exposed)
+
+	c.Resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment;filename="playlist-%s.json"`, dto.Uid))
+	return response.JSON(http.StatusOK, bundle)
+}
+
+// playlistResolvedDashboards returns the dashboards the given items resolve to for the given user, once each in
+// the order of the items, the tag items expanded by playlistResolvedTagDashboards, with its warnings. The other
+// dashboards are resolved by playlistDashboards.
+func (hs *HTTPServer) playlistResolvedDashboards(ctx context.Context, signedInUser *user.SignedInUser, items []playlist.PlaylistItemDTO, resolved resolvedDashboards) ([]dtos.PlaylistResolvedDashboard, []string, error) {
+	snapshot := []dtos.PlaylistResolvedDashboard{}
+	var warnings []string
+	seen := map[string]bool{}
+	add := func(hit *model.Hit) {
+		if !seen[hit.UID] {
+			seen[hit.UID] = true
+			tags := hit.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			snapshot = append(snapshot, dtos.PlaylistResolvedDashboard{UID: hit.UID, Title: hit.Title, Tags: tags})
+		}
+	}
+	for _, item := range items {
+		if v0alpha1.ItemType(item.Type) != v0alpha1.ItemTypeDashboardByTag {
+			if hit, ok := resolved.get(item); ok {
+				add(hit)
+			}
+			continue
+		}
+		tagged, warning, err := hs.playlistResolvedTagDashboards(ctx, signedInUser, item.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		for _, hit := range tagged {
+			add(hit)
+		}
+	}
+	return snapshot, warnings, nil
+}
+
+// swagger:route POST /playlists/import playlists importPlaylistBundle
+//
+// Create a playlist from a bundle returned by the export endpoint.
+//
+// The dashboard_by_uid items are mapped to the dashboards of the org with the dashboardUids mapping table,
+// if their dashboard is in it. The items whose dashboard doesn't exist in the org, or that the user can't
+// view, are skipped, with a warning in the response. The playlist gets a new UID.
+//
+// With dryRun=true, the playlist isn't created: the response tells whether it would be, with the warnings
+// of the import and the errors preventing it.
+//
+// Responses:
+// 200: importPlaylistBundleResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 500: internalServerError
+func (hs *HTTPServer) ImportPlaylistBundle(c *contextmodel.ReqContext) response.Response {
+	return hs.importPlaylistBundle(c, hs.playlistService.Create)
+}
+
+// importPlaylistBundle creates the playlist of the bundle of the request with the given function, or only
+// checks that it could if the request is a dry run.
+func (hs *HTTPServer) importPlaylistBundle(c *contextmodel.ReqContext, createPlaylist func(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error)) response.Response {
+	dryRun := c.QueryBool("dryRun")
+	cmd := dtos.ImportPlaylistBundleCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+	if cmd.APIVersion != dtos.PlaylistBundleAPIVersion {
+		return response.Error(http.StatusBadRequest, fmt.Sprintf("Unsupported playlist bundle version %q, expected %q", cmd.APIVersion, dtos.PlaylistBundleAPIVersion), nil)
+	}
+	if cmd.Playlist.Name == "" {
+		return response.Error(http.StatusBadRequest, "Missing playlist name", nil)
+	}
+
+	items := make([]playlist.PlaylistItemDTO, 0, len(cmd.Playlist.Items))
+	for _, item := range cmd.Playlist.Items {
+		if v0alpha1.ItemType(item.Type) == v0alpha1.ItemTypeDashboardByUid {
+			if uid, ok := cmd.DashboardUIDs[item.Value]; ok {
+				item.Value = uid
+			}
+		}
+		items = append(items, item)
+	}
+	resolved, err := hs.playlistDashboards(c.Req.Context(), c.SignedInUser, items)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+	}
+	titles := map[string]string{}
+	for _, d := range cmd.Dashboards {
+		titles[d.UID] = d.Title
+	}
+
+	result := dtos.ImportPlaylistBundleResult{Name: cmd.Playlist.Name, Warnings: []string{}}
+	kept := make([]playlist.PlaylistItemDTO, 0, len(items))
+	for i, item := range items {
+		if v0alpha1.ItemType(item.Type) == v0alpha1.ItemTypeDashboardByUid {
+			if _, ok := resolved.get(item); !ok {
+				source := cmd.Playlist.Items[i].Value
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Dashboard %q (%s) was not found, the item was skipped", titles[source], source))
+				continue
+			}
+		}
+		kept = append(kept, item)
+	}
+
+	p, err := createPlaylist(c.Req.Context(), &playlist.CreatePlaylistCommand{
+		OrgId:    c.SignedInUser.GetOrgID(),
+		Name:     cmd.Playlist.Name,
+		Interval: cmd.Playlist.Interval,
+		Items:    playlistItemsFromDTO(kept),
+		DryRun:   dryRun,
+	})
+	if dryRun {
+		result.DryRun = true
+		if err != nil {
+			if !isPlaylistValidationError(err) && !errors.Is(err, playlist.ErrPlaylistAlreadyExists) {
+				return playlistCreateError(err)
+			}
+			result.Errors = []string{err.Error()}
+		}
+		result.WouldCreate = err == nil
+		return response.JSON(http.StatusOK, result)
+	}
+	if err != nil {
+		return playlistCreateError(err)
+	}
+	result.UID = p.UID
+	return response.JSON(http.StatusOK, result)
+}
+
+// swagger:route POST /playlists/import-all playlists importAllPlaylists
+//
+// Import playlists exported with the export-all endpoint.
+//
+// Each playlist is imported on its own, with its UID if it has one, so the playlists that can't be imported
+// don't prevent the import of the other ones. The response has the result of each import, in the order of
+// the archive, with a 207 status if some of them failed.
+//
+// The playlists with the UID of a playlist in the trash fail with a 409, like their creation, until it's purged.
+//
+// With dryRun=true, no playlist is created: the result of each import is the one it would have.
+//
+// Responses:
+// 200: importAllPlaylistsResponse
+// 207: importAllPlaylistsResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 500: internalServerError
+func (hs *HTTPServer) ImportAllPlaylists(c *contextmodel.ReqContext) response.Response {
+	orgID := c.SignedInUser.GetOrgID()
+	playlistExists := func(ctx context.Context, uid string) (bool, error) {
+		_, err := hs.playlistService.GetWithoutItems(ctx, &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: orgID})
+		if errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return hs.importAllPlaylists(c, playlistExists, hs.playlistService.Create)
+}
+
+// importAllPlaylists creates the playlists of the archive of the request with the given functions, or only
+// checks that it could if the request is a dry run.
+func (hs *HTTPServer) importAllPlaylists(c *contextmodel.ReqContext, playlistExists func(ctx context.Context, uid string) (bool, error),
+	createPlaylist func(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error)) response.Response {
+	archive := dtos.PlaylistArchive{}
+	if err := web.Bind(c.Req, &archive); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+
+	dryRun := c.QueryBool("dryRun")
+	// planned are the UIDs of the playlists a dry run would create, which the next ones of the archive can't take
+	planned := map[string]bool{}
+	status := http.StatusOK
+	results := make([]dtos.PlaylistImportResult, 0, len(archive.Playlists))
+	for _, p := range archive.Playlists {
+		var result dtos.PlaylistImportResult
+		if dryRun && planned[p.Uid] {
+			result = dtos.PlaylistImportResult{UID: p.Uid, Name: p.Name, Status: http.StatusConflict, Message: "A playlist with the same UID already exists"}
+		} else {
+			result = hs.importPlaylist(c, p, dryRun, playlistExists, createPlaylist)
+		}
+		if dryRun {
+			result.DryRun, result.WouldCreate = true, result.Status == http.StatusOK
+			if result.WouldCreate && p.Uid != "" {
+				planned[p.Uid] = true
+			}
+		}
+		if result.Status != http.StatusOK {
+			status = http.StatusMultiStatus
+		}
+		results = append(results, result)
+	}
+	return response.JSON(status, results)
+}
+
+// importPlaylist creates a playlist of an archive in the org of the user, or only checks that it could if dryRun is set.
+func (hs *HTTPServer) importPlaylist(c *contextmodel.ReqContext, p playlist.PlaylistDTO, dryRun bool, playlistExists func(ctx context.Context, uid string) (bool, error),
+	createPlaylist func(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error)) dtos.PlaylistImportResult {
+	ctx, orgID := c.Req.Context(), c.SignedInUser.GetOrgID()
+	result := dtos.PlaylistImportResult{UID: p.Uid, Name: p.Name}
+	fail := func(status int, message string) dtos.PlaylistImportResult {
+		result.Status, result.Message = status, message
+		return result
+	}
+
+	if p.Name == "" {
+		return fail(http.StatusBadRequest, "Missing playlist name")
+	}
+	if p.Uid != "" {
+		exists, err := playlistExists(ctx, p.Uid)
+		if err != nil {
+			hs.log.FromContext(ctx).Error("Failed to get playlist", "uid", p.Uid, "error", err)
+			return fail(http.StatusInternalServerError, "Failed to get playlist")
+		}
+		if exists {
+			return fail(http.StatusConflict, "Playlist already exists")
+		}
+	}
+	reached, err := hs.QuotaService.QuotaReached(c, playlist.QuotaTargetSrv)
+	if err != nil {
+		hs.log.FromContext(ctx).Error("Failed to get quota", "error", err)
+		return fail(http.StatusInternalServerError, "Failed to get quota")
+	}
+	if reached {
+		return fail(http.StatusForbidden, "playlist Quota reached")
+	}
+
+	created, err := createPlaylist(ctx, &playlist.CreatePlaylistCommand{
+		OrgId:    orgID,
+		UID:      p.Uid,
+		Name:     p.Name,
+		Interval: p.Interval,
+		Items:    playlistItemsFromDTO(p.Items),
+		DryRun:   dryRun,
+	})
+	if err != nil {
+		if isPlaylistValidationError(err) {
+			return fail(http.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, playlist.ErrPlaylistAlreadyExists) {
+			return fail(http.StatusConflict, "A playlist with the same UID already exists")
+		}
+		hs.log.FromContext(ctx).Error("Failed to import playlist", "uid", p.Uid, "error", err)
+		return fail(http.StatusInternalServerError, "Failed to create playlist")
+	}
+	result.UID, result.Status = created.UID, http.StatusOK
+	return result
+}
+
+// swagger:parameters importAllPlaylists
+type ImportAllPlaylistsParams struct {
+	// in:body
+	// required:true
+	Body dtos.PlaylistArchive
+	// Only check whether the playlists would be imported, without creating them.
+	// in:query
+	// required:false
+	DryRun bool `json:"dryRun"`
+}
+
+// swagger:parameters importPlaylistBundle
+type ImportPlaylistBundleParams struct {
+	// in:body
+	// required:true
+	Body dtos.ImportPlaylistBundleCommand
+	// Only check whether the playlist would be imported, without creating it.
+	// in:query
+	// required:false
+	DryRun bool `json:"dryRun"`
+}
+
+// swagger:response importPlaylistBundleResponse
+type ImportPlaylistBundleResponse struct {
+	// in: body
+	Body dtos.ImportPlaylistBundleResult `json:"body"`
+}
+
+// swagger:response exportAllPlaylistsResponse
+type ExportAllPlaylistsResponse struct {
+	// in: body
+	Body dtos.PlaylistArchive `json:"body"`
+}
+
+// swagger:parameters exportPlaylist
+type ExportPlaylistParams struct {
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+	// Include a snapshot of the dashboards the playlist resolves to.
+	// in:query
+	// required:false
+	IncludeResolved bool `json:"includeResolved"`
+}
+
+// swagger:response exportPlaylistResponse
+type ExportPlaylistResponse struct {
+	// in: body
+	Body dtos.PlaylistBundle `json:"body"`
+}
+
+// swagger:response importAllPlaylistsResponse
+type ImportAllPlaylistsResponse struct {
+	// The result of the import of each playlist, in the order of the archive.
+	// in: body
+	Body []dtos.PlaylistImportResult `json:"body"`
+}
@@ -0,0 +1,534 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	clientrest "k8s.io/client-go/rest"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/playlist/playlistimpl"
+	"github.com/grafana/grafana/pkg/services/playlist/playlisttest"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web/webtest"
+)
+
+func TestAPIEndpoint_ExportPlaylist(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "Wallboard", Interval: "5m", Items: []playlist.PlaylistItemDTO{
+		{Type: "dashboard_by_uid", Value: "dash-a"},
+		{Type: "dashboard_by_tag", Value: "status", Interval: "30s"},
+		{Type: "dashboard_by_uid", Value: "missing"},
+		{Type: "dashboard_by_uid", Value: "dash-a"},
+	}}
+	searchService := &fakePlaylistSearchService{hits: model.HitList{
+		{UID: "dash-a", Title: "Dashboard A", Tags: []string{"status"}},
+		{UID: "dash-b", Title: "Dashboard B", Tags: []string{"status", "prod"}},
+	}}
+	var httpServer *HTTPServer
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		httpServer = hs
+		hs.playlistService = playlistService
+		hs.SearchService = searchService
+	})
+
+	export := func(t *testing.T, path string) []byte {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, `attachment;filename="playlist-a.json"`, res.Header.Get("Content-Disposition"))
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return body
+	}
+
+	t.Run("Should export the playlist and the titles of its dashboards", func(t *testing.T) {
+		require.JSONEq(t, `{
+			"apiVersion": "playlist.grafana.app/bundle/v1",
+			"playlist": {
+				"uid": "a",
+				"name": "Wallboard",
+				"interval": "5m",
+				"items": [
+					{"type": "dashboard_by_uid", "value": "dash-a"},
+					{"type": "dashboard_by_tag", "value": "status", "interval": "30s"},
+					{"type": "dashboard_by_uid", "value": "missing"},
+					{"type": "dashboard_by_uid", "value": "dash-a"}
+				]
+			},
+			"dashboards": [
+				{"uid": "dash-a", "title": "Dashboard A"}
+			]
+		}`, string(export(t, "/api/playlists/a/export")))
+	})
+
+	t.Run("Should include the dashboards the playlist currently resolves to on request", func(t *testing.T) {
+		var bundle dtos.PlaylistBundle
+		require.NoError(t, json.Unmarshal(export(t, "/api/playlists/a/export?includeResolved=true"), &bundle))
+		require.Equal(t, []dtos.PlaylistResolvedDashboard{
+			{UID: "dash-a", Title: "Dashboard A", Tags: []string{"status"}},
+			{UID: "dash-b", Title: "Dashboard B", Tags: []string{"status", "prod"}},
+		}, bundle.Resolved)
+		require.Equal(t, []dtos.PlaylistBundleDashboard{{UID: "dash-a", Title: "Dashboard A"}}, bundle.Dashboards)
+
+		// The snapshot follows the tags of the dashboards
+		searchService.hits = model.HitList{
+			{UID: "dash-a", Title: "Dashboard A"},
+			{UID: "dash-b", Title: "Dashboard B", Tags: []string{"prod"}},
+			{UID: "dash-c", Title: "Dashboard C", Tags: []string{"status"}},
+		}
+		bundle = dtos.PlaylistBundle{}
+		require.NoError(t, json.Unmarshal(export(t, "/api/playlists/a/export?includeResolved=true"), &bundle))
+		require.Equal(t, []dtos.PlaylistResolvedDashboard{
+			{UID: "dash-a", Title: "Dashboard A", Tags: []string{}},
+			{UID: "dash-c", Title: "Dashboard C", Tags: []string{"status"}},
+		}, bundle.Resolved)
+		require.Empty(t, bundle.Warnings)
+	})
+
+	t.Run("Should cap the dashboards of the tag items with a warning", func(t *testing.T) {
+		searchService.hits = model.HitList{
+			{UID: "dash-a", Title: "Dashboard A", Tags: []string{"status"}},
+			{UID: "dash-b", Title: "Dashboard B", Tags: []string{"status"}},
+			{UID: "dash-c", Title: "Dashboard C", Tags: []string{"status"}},
+		}
+		httpServer.Cfg.Playlist.TagMaxDashboards = 1
+		t.Cleanup(func() { httpServer.Cfg.Playlist = setting.PlaylistSettings{} })
+		resolvedUIDs := func(t *testing.T) ([]string, []string) {
+			t.Helper()
+			var bundle dtos.PlaylistBundle
+			require.NoError(t, json.Unmarshal(export(t, "/api/playlists/a/export?includeResolved=true"), &bundle))
+			uids := []string{}
+			for _, d := range bundle.Resolved {
+				uids = append(uids, d.UID)
+			}
+			return uids, bundle.Warnings
+		}
+
+		for _, selection := range []string{"alpha-asc", "alpha-desc"} {
+			httpServer.Cfg.Playlist.TagDashboardsSelection = selection
+			uids, warnings := resolvedUIDs(t)
+			if selection == "alpha-asc" {
+				// The only dashboard of the tag item is the one of the first item
+				require.Equal(t, []string{"dash-a"}, uids)
+			} else {
+				require.Equal(t, []string{"dash-a", "dash-c"}, uids)
+			}
+			require.Equal(t, []string{`Tag "status" has more than 1 dashboards, only 1 of them are included`}, warnings)
+		}
+
+		httpServer.Cfg.Playlist.TagMaxDashboards = 3
+		uids, warnings := resolvedUIDs(t)
+		require.Equal(t, []string{"dash-a", "dash-c", "dash-b"}, uids)
+		require.Empty(t, warnings)
+	})
+}
+
+// importPlaylistService records the playlists created.
+type importPlaylistService struct {
+	*playlisttest.FakePlaylistService
+	created []playlist.CreatePlaylistCommand
+}
+
+func (s *importPlaylistService) Create(_ context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error) {
+	s.created = append(s.created, *cmd)
+	if s.ExpectedError != nil {
+		return nil, s.ExpectedError
+	}
+	return &playlist.Playlist{UID: "new", Name: cmd.Name, OrgId: cmd.OrgId}, nil
+}
+
+func TestAPIEndpoint_ImportPlaylistBundle(t *testing.T) {
+	playlistService := &importPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+		// The dashboards of the target org
+		hs.SearchService = &mockSearchService{ExpectedResult: model.HitList{{UID: "dash-a", Title: "Dashboard A"}, {UID: "target-b", Title: "Dashboard B"}}}
+	})
+	editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+
+	importBundle := func(t *testing.T, signedInUser *user.SignedInUser, body string, query ...string) (int, dtos.ImportPlaylistBundleResult) {
+		t.Helper()
+		playlistService.created = nil
+		req := server.NewRequest(http.MethodPost, "/api/playlists/import?"+strings.Join(query, "&"), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, signedInUser))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		var result dtos.ImportPlaylistBundleResult
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		}
+		return res.StatusCode, result
+	}
+
+	t.Run("Should create the playlist of a bundle", func(t *testing.T) {
+		status, result := importBundle(t, editor, `{
+			"apiVersion": "playlist.grafana.app/bundle/v1",
+			"playlist": {"uid": "a", "name": "Wallboard", "interval": "5m", "items": [
+				{"type": "dashboard_by_uid", "value": "dash-a"},
+				{"type": "dashboard_by_tag", "value": "status", "interval": "30s"}
+			]},
+			"dashboards": [{"uid": "dash-a", "title": "Dashboard A"}]
+		}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, dtos.ImportPlaylistBundleResult{UID: "new", Name: "Wallboard", Warnings: []string{}}, result)
+		require.Equal(t, []playlist.CreatePlaylistCommand{{
+			OrgId: 1, Name: "Wallboard", Interval: "5m",
+			Items: []playlist.PlaylistItem{
+				{Type: "dashboard_by_uid", Value: "dash-a"},
+				{Type: "dashboard_by_tag", Value: "status", Interval: "30s"},
+			},
+		}}, playlistService.created)
+	})
+
+	t.Run("Should map the dashboards and skip the ones that can't be found", func(t *testing.T) {
+		status, result := importBundle(t, editor, `{
+			"apiVersion": "playlist.grafana.app/bundle/v1",
+			"playlist": {"uid": "a", "name": "Wallboard", "interval": "5m", "items": [
+				{"type": "dashboard_by_uid", "value": "source-b"},
+				{"type": "dashboard_by_uid", "value": "source-c"},
+				{"type": "dashboard_by_tag", "value": "status"}
+			]},
+			"dashboards": [{"uid": "source-b", "title": "Dashboard B"}, {"uid": "source-c", "title": "Dashboard C"}],
+			"dashboardUids": {"source-b": "target-b"}
+		}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{`Dashboard "Dashboard C" (source-c) was not found, the item was skipped`}, result.Warnings)
+		require.Len(t, playlistService.created, 1)
+		require.Equal(t, []playlist.PlaylistItem{
+			{Type: "dashboard_by_uid", Value: "target-b"},
+			{Type: "dashboard_by_tag", Value: "status"},
+		}, playlistService.created[0].Items)
+	})
+
+	t.Run("Should report the warnings and errors of a dry run", func(t *testing.T) {
+		bundle := `{
+			"apiVersion": "playlist.grafana.app/bundle/v1",
+			"playlist": {"name": "Wallboard", "interval": "5m", "items": [
+				{"type": "dashboard_by_uid", "value": "source-c"},
+				{"type": "dashboard_by_tag", "value": "status"}
+			]},
+			"dashboards": [{"uid": "source-c", "title": "Dashboard C"}]
+		}`
+		status, result := importBundle(t, editor, bundle, "dryRun=true")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, dtos.ImportPlaylistBundleResult{
+			Name:        "Wallboard",
+			Warnings:    []string{`Dashboard "Dashboard C" (source-c) was not found, the item was skipped`},
+			DryRun:      true,
+			WouldCreate: true,
+		}, result)
+		require.Len(t, playlistService.created, 1)
+		require.True(t, playlistService.created[0].DryRun)
+
+		playlistService.ExpectedError = fmt.Errorf("%w: %q is not a positive duration", playlist.ErrInvalidInterval, "soon")
+		t.Cleanup(func() { playlistService.ExpectedError = nil })
+		status, result = importBundle(t, editor, bundle, "dryRun=true")
+		require.Equal(t, http.StatusOK, status)
+		require.False(t, result.WouldCreate)
+		require.Equal(t, []string{`invalid playlist interval: "soon" is not a positive duration`}, result.Errors)
+	})
+
+	t.Run("Should reject malformed bundles", func(t *testing.T) {
+		for _, body := range []string{
+			`{"apiVersion": "playlist.grafana.app/bundle/v2", "playlist": {"name": "Wallboard", "interval": "5m"}}`,
+			`{"playlist": {"name": "Wallboard", "interval": "5m"}}`,
+			`{"apiVersion": "playlist.grafana.app/bundle/v1", "playlist": {"interval": "5m"}}`,
+			`{"apiVersion": "playlist.grafana.app/bundle/v1", "playlist": [`,
+		} {
+			status, _ := importBundle(t, editor, body)
+			require.Equal(t, http.StatusBadRequest, status, body)
+			require.Empty(t, playlistService.created, body)
+		}
+	})
+
+	t.Run("Should require the editor role", func(t *testing.T) {
+		status, _ := importBundle(t, userWithPermissions(1, nil), `{"apiVersion": "playlist.grafana.app/bundle/v1", "playlist": {"name": "Wallboard", "interval": "5m"}}`)
+		require.Equal(t, http.StatusForbidden, status)
+	})
+}
+
+func TestAPIEndpoint_ImportPlaylistsK8s(t *testing.T) {
+	const playlistsPath = "/apis/playlist.grafana.app/v0alpha1/namespaces/default/playlists"
+	// The playlists of the namespace by name, and the dry runs of the creations
+	playlists := map[string]map[string]any{"a": nil}
+	var dryRuns []string
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		status := func(code int, reason string) {
+			w.WriteHeader(code)
+			_, err := fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "Status", "status": "Failure", "reason": %q, "code": %d}`, reason, code)
+			require.NoError(t, err)
+		}
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, playlistsPath+"/"):
+			if _, ok := playlists[strings.TrimPrefix(r.URL.Path, playlistsPath+"/")]; !ok {
+				status(http.StatusNotFound, "NotFound")
+				return
+			}
+			_, err := w.Write([]byte(`{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "Playlist", "metadata": {"name": "a"}, "spec": {"title": "A", "interval": "5m"}}`))
+			require.NoError(t, err)
+		case r.Method == http.MethodPost && r.URL.Path == playlistsPath:
+			var obj map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&obj))
+			name := obj["metadata"].(map[string]any)["name"].(string)
+			if _, ok := playlists[name]; ok {
+				status(http.StatusConflict, "AlreadyExists")
+				return
+			}
+			if dryRun := r.URL.Query().Get("dryRun"); dryRun != "" {
+				dryRuns = append(dryRuns, name)
+			} else {
+				playlists[name] = obj["spec"].(map[string]any)
+			}
+			w.WriteHeader(http.StatusCreated)
+			require.NoError(t, json.NewEncoder(w).Encode(obj))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(apiserver.Close)
+
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+		hs.promRegister = prometheus.NewRegistry()
+		hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		hs.SearchService = &mockSearchService{ExpectedResult: model.HitList{{UID: "dash-a", Title: "Dashboard A"}}}
+	})
+	editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+	post := func(t *testing.T, url, body string, result any) int {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, editor))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusMultiStatus {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(result))
+		}
+		return res.StatusCode
+	}
+
+	t.Run("Should create the playlist of a bundle with the apiserver", func(t *testing.T) {
+		var result dtos.ImportPlaylistBundleResult
+		status := post(t, "/api/playlists/import", `{
+			"apiVersion": "playlist.grafana.app/bundle/v1",
+			"playlist": {"uid": "a", "name": "Wallboard", "interval": "5m", "items": [
+				{"type": "dashboard_by_uid", "value": "dash-a"},
+				{"type": "dashboard_by_uid", "value": "dash-b"}
+			]}
+		}`, &result)
+		require.Equal(t, http.StatusOK, status)
+		require.NotEmpty(t, result.UID)
+		require.Len(t, result.Warnings, 1)
+		require.Equal(t, map[string]any{"title": "Wallboard", "interval": "5m", "items": []any{
+			map[string]any{"type": "dashboard_by_uid", "value": "dash-a"},
+		}}, playlists[result.UID])
+	})
+
+	t.Run("Should import the playlists of an archive with the apiserver", func(t *testing.T) {
+		archive := `{"playlists": [
+			{"uid": "a", "name": "A", "interval": "5m"},
+			{"uid": "b", "name": "B", "interval": "5m", "items": [{"type": "dashboard_by_tag", "value": "status"}]}
+		]}`
+		var results []dtos.PlaylistImportResult
+		status := post(t, "/api/playlists/import-all?dryRun=true", archive, &results)
+		require.Equal(t, http.StatusMultiStatus, status)
+		require.Equal(t, []dtos.PlaylistImportResult{
+			{UID: "a", Name: "A", Status: http.StatusConflict, Message: "Playlist already exists", DryRun: true},
+			{UID: "b", Name: "B", Status: http.StatusOK, DryRun: true, WouldCreate: true},
+		}, results)
+		require.Equal(t, []string{"b"}, dryRuns)
+		require.NotContains(t, playlists, "b")
+
+		status = post(t, "/api/playlists/import-all", archive, &results)
+		require.Equal(t, http.StatusMultiStatus, status)
+		require.Equal(t, http.StatusOK, results[1].Status)
+		require.Equal(t, map[string]any{"title": "B", "interval": "5m", "items": []any{
+			map[string]any{"type": "dashboard_by_tag", "value": "status"},
+		}}, playlists["b"])
+	})
+}
+
+func TestIntegrationPlaylistArchive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	cfg := setting.NewCfg()
+	playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.log = log.New("test")
+		hs.playlistService = playlistService
+	})
+	source := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+	target := &user.SignedInUser{OrgID: 2, OrgRole: org.RoleEditor}
+
+	exportAll := func(t *testing.T, signedInUser *user.SignedInUser) (*http.Response, []byte) {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists/export-all"), signedInUser))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, body
+	}
+	importAll := func(t *testing.T, signedInUser *user.SignedInUser, archive []byte, query ...string) (int, []dtos.PlaylistImportResult) {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, "/api/playlists/import-all?"+strings.Join(query, "&"), strings.NewReader(string(archive)))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, signedInUser))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		var results []dtos.PlaylistImportResult
+		if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusMultiStatus {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&results))
+		}
+		return res.StatusCode, results
+	}
+	orgPlaylists := func(t *testing.T, orgID int64) []playlist.PlaylistDTO {
+		t.Helper()
+		found, err := playlistService.Search(context.Background(), &playlist.GetPlaylistsQuery{OrgId: orgID, Limit: 1000})
+		require.NoError(t, err)
+		out := []playlist.PlaylistDTO{}
+		for _, p := range found {
+			dto, err := playlistService.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID})
+			require.NoError(t, err)
+			out = append(out, *dto)
+		}
+		return out
+	}
+
+	// More than a page of playlists, to export several pages
+	for i := 0; i < playlistArchivePageSize+5; i++ {
+		_, err := playlistService.Create(context.Background(), &playlist.CreatePlaylistCommand{
+			OrgId:    1,
+			Name:     fmt.Sprintf("Playlist %d", i),
+			Interval: "5m",
+			Items: []playlist.PlaylistItem{
+				{Type: "dashboard_by_uid", Value: fmt.Sprintf("dash-%d", i), Interval: "30s"},
+				{Type: "dashboard_by_tag", Value: "status"},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("Should reproduce the playlists of an org in a clean org", func(t *testing.T) {
+		res, archive := exportAll(t, source)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+		status, results := importAll(t, target, archive)
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, results, playlistArchivePageSize+5)
+		for _, r := range results {
+			require.Equal(t, http.StatusOK, r.Status, r.Name)
+		}
+
+		exported, imported := orgPlaylists(t, 1), orgPlaylists(t, 2)
+		require.Len(t, exported, playlistArchivePageSize+5)
+		for i := range exported {
+			exported[i].Id, imported[i].Id = 0, 0
+			exported[i].OrgID, imported[i].OrgID = 0, 0
+			exported[i].CreatedAt, imported[i].CreatedAt = 0, 0
+			exported[i].UpdatedAt, imported[i].UpdatedAt = 0, 0
+			exported[i].Created, imported[i].Created = nil, nil
+			exported[i].Updated, imported[i].Updated = nil, nil
+		}
+		require.Equal(t, exported, imported)
+	})
+
+	t.Run("Should report the playlists that can't be imported", func(t *testing.T) {
+		existing := orgPlaylists(t, 1)[0]
+		archive, err := json.Marshal(dtos.PlaylistArchive{Playlists: []playlist.PlaylistDTO{
+			existing,
+			{Name: "New", Interval: "1m", Items: []playlist.PlaylistItemDTO{{Type: "dashboard_by_uid", Value: "dash-a"}}},
+			{Uid: "unnamed", Interval: "1m"},
+			{Name: "Invalid", Interval: "1m", Items: []playlist.PlaylistItemDTO{{Type: "dashboard_by_uid", Value: "dash-a", Interval: "soon"}}},
+		}})
+		require.NoError(t, err)
+
+		status, results := importAll(t, source, archive)
+		require.Equal(t, http.StatusMultiStatus, status)
+		require.Len(t, results, 4)
+		require.Equal(t, dtos.PlaylistImportResult{UID: existing.Uid, Name: existing.Name, Status: http.StatusConflict, Message: "Playlist already exists"}, results[0])
+		require.Equal(t, http.StatusOK, results[1].Status)
+		require.NotEmpty(t, results[1].UID)
+		require.Equal(t, http.StatusBadRequest, results[2].Status)
+		require.Equal(t, http.StatusBadRequest, results[3].Status)
+		require.Len(t, orgPlaylists(t, 1), playlistArchivePageSize+6)
+	})
+
+	t.Run("Should only report what would be imported on dry runs", func(t *testing.T) {
+		dryRunTarget := &user.SignedInUser{OrgID: 3, OrgRole: org.RoleEditor}
+		existing := orgPlaylists(t, 1)[0]
+		archive, err := json.Marshal(dtos.PlaylistArchive{Playlists: []playlist.PlaylistDTO{
+			{Uid: "dry-run", Name: "New", Interval: "1m", Items: []playlist.PlaylistItemDTO{{Type: "dashboard_by_uid", Value: "dash-a"}}},
+			{Uid: "dry-run", Name: "Same UID", Interval: "1m"},
+			{Name: "Invalid", Interval: "1m", Items: []playlist.PlaylistItemDTO{{Type: "dashboard_by_uid", Value: "dash-a", Interval: "soon"}}},
+		}})
+		require.NoError(t, err)
+
+		status, results := importAll(t, dryRunTarget, archive, "dryRun=true")
+		require.Equal(t, http.StatusMultiStatus, status)
+		require.Equal(t, []dtos.PlaylistImportResult{
+			{UID: "dry-run", Name: "New", Status: http.StatusOK, DryRun: true, WouldCreate: true},
+			{UID: "dry-run", Name: "Same UID", Status: http.StatusConflict, Message: "A playlist with the same UID already exists", DryRun: true},
+		}, results[:2])
+		require.Equal(t, http.StatusBadRequest, results[2].Status)
+		require.False(t, results[2].WouldCreate)
+
+		// The UIDs of the org are taken into account
+		archive, err = json.Marshal(dtos.PlaylistArchive{Playlists: []playlist.PlaylistDTO{existing}})
+		require.NoError(t, err)
+		status, results = importAll(t, source, archive, "dryRun=true")
+		require.Equal(t, http.StatusMultiStatus, status)
+		require.Equal(t, http.StatusConflict, results[0].Status)
+
+		req := server.NewRequest(http.MethodPost, "/api/playlists/import?dryRun=true", strings.NewReader(`{
+			"apiVersion": "playlist.grafana.app/bundle/v1",
+			"playlist": {"name": "Wallboard", "interval": "5m", "items": [{"type": "dashboard_by_tag", "value": "status"}]}
+		}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, dryRunTarget))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var result dtos.ImportPlaylistBundleResult
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		require.Equal(t, dtos.ImportPlaylistBundleResult{Name: "Wallboard", Warnings: []string{}, DryRun: true, WouldCreate: true}, result)
+
+		require.Empty(t, orgPlaylists(t, 3))
+		require.Len(t, orgPlaylists(t, 1), playlistArchivePageSize+6)
+	})
+
+	t.Run("Should require the editor role", func(t *testing.T) {
+		viewer := userWithPermissions(1, nil)
+		res, _ := exportAll(t, viewer)
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+		status, _ := importAll(t, viewer, []byte(`{"playlists": []}`))
+		require.Equal(t, http.StatusForbidden, status)
+	})
+}
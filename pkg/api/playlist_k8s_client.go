@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	clientrest "k8s.io/client-go/rest"
+
+	"github.com/grafana/grafana/pkg/apis/playlist/v0alpha1"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// playlistClientCacheMaxKeys bounds the number of dynamic clients kept by the playlistClientCache.
+// The cache is cleared when it's reached, as the clients are cheap to rebuild once in a while.
+const playlistClientCacheMaxKeys = 100
+
+// playlistClientCache reuses the dynamic clients of the Kubernetes playlists API across requests, so that
+// their REST client and transport aren't built again for each request.
+//
+// The clients are keyed on their REST config, without its transport, so the configs with different hosts or
+// credentials get different clients. The direct configs of the in-process apiserver have a transport bound to
+// the signed in user of the request instead, so the clients send their requests with the transport found in
+// the request context, set by withRequestTransport, and never with the one of the request they were built for.
+type playlistClientCache struct {
+	mu      sync.Mutex
+	clients map[string]dynamic.Interface
+}
+
+func newPlaylistClientCache() *playlistClientCache {
+	return &playlistClientCache{clients: map[string]dynamic.Interface{}}
+}
+
+// get returns the dynamic client of the REST config, building it if it's not cached yet.
+func (c *playlistClientCache) get(cfg *clientrest.Config) (dynamic.Interface, error) {
+	key := restConfigKey(cfg)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+
+	shared := clientrest.CopyConfig(cfg)
+	if cfg.Transport != nil {
+		shared.Transport = requestScopedTransport{}
+	}
+	if cfg.RateLimiter == nil && cfg.QPS == 0 {
+		// The default rate limiter of the client would be shared by all the requests, when each of them
+		// had its own before, so it's disabled rather than throttling every user to the default QPS.
+		shared.QPS = -1
+	}
+	client, err := dynamic.NewForConfig(shared)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.clients) >= playlistClientCacheMaxKeys {
+		c.clients = map[string]dynamic.Interface{}
+	}
+	c.clients[key] = client
+	return client, nil
+}
+
+// restConfigKey returns the cache key of a REST config: its host, path, credentials and TLS settings.
+// The transport isn't part of it, as it's taken from the request context.
+func restConfigKey(cfg *clientrest.Config) string {
+	tls := cfg.TLSClientConfig
+	return strings.Join([]string{
+		cfg.Host, cfg.APIPath,
+		cfg.Username, cfg.Password, cfg.BearerToken, cfg.BearerTokenFile, cfg.Impersonate.UserName,
+		tls.ServerName, tls.CertFile, tls.KeyFile, tls.CAFile, string(tls.CertData), string(tls.KeyData), string(tls.CAData),
+		strconv.FormatBool(tls.Insecure), strconv.FormatBool(cfg.Transport != nil),
+	}, "\x00")
+}
+
+type requestTransportKey struct{}
+
+// withRequestTransport returns a copy of ctx with the transport of the REST config of the request, if it has one,
+// for the cached clients to send their requests with.
+func withRequestTransport(ctx context.Context, cfg *clientrest.Config) context.Context {
+	if cfg.Transport == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestTransportKey{}, cfg.Transport)
+}
+
+// requestScopedTransport sends the requests with the transport of their context. It fails if there's none,
+// rather than sending them on behalf of another user.
+type requestScopedTransport struct{}
+
+func (requestScopedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt, ok := req.Context().Value(requestTransportKey{}).(http.RoundTripper)
+	if !ok {
+		return nil, errors.New("no transport in the request context")
+	}
+	return rt.RoundTrip(req)
+}
+
+// k8sPlaylistExists returns a function checking whether a playlist exists with the client, like the legacy
+// service does for the import endpoints.
+func k8sPlaylistExists(client dynamic.ResourceInterface) func(ctx context.Context, uid string) (bool, error) {
+	return func(ctx context.Context, uid string) (bool, error) {
+		_, err := client.Get(ctx, uid, v1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+}
+
+// k8sCreatePlaylist returns a function creating a playlist with the client, like the legacy service does for
+// the import endpoints. The playlists without a UID get a new one, and the dry runs are only validated by the
+// apiserver.
+func k8sCreatePlaylist(client dynamic.ResourceInterface) func(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error) {
+	return func(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error) {
+		p := &v0alpha1.Playlist{
+			TypeMeta:   v1.TypeMeta{APIVersion: v0alpha1.GroupName + "/" + v0alpha1.VersionID, Kind: "Playlist"},
+			ObjectMeta: v1.ObjectMeta{Name: cmd.UID},
+			Spec:       v0alpha1.Spec{Title: cmd.Name, Interval: cmd.Interval},
+		}
+		if p.Name == "" {
+			p.Name = util.GenerateShortUID()
+		}
+		for _, item := range cmd.Items {
+			p.Spec.Items = append(p.Spec.Items, v0alpha1.Item{Type: v0alpha1.ItemType(item.Type), Value: item.Value, Interval: item.Interval})
+		}
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+		if err != nil {
+			return nil, err
+		}
+
+		options := v1.CreateOptions{}
+		if cmd.DryRun {
+			options.DryRun = []string{v1.DryRunAll}
+		}
+		out, err := client.Create(ctx, &unstructured.Unstructured{Object: obj}, options)
+		if apierrors.IsAlreadyExists(err) {
+			return nil, playlist.ErrPlaylistAlreadyExists
+		}
+		if err != nil {
+			return nil, err
+		}
+		return v0alpha1.UnstructuredToLegacyPlaylist(*out), nil
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	clientrest "k8s.io/client-go/rest"
+
+	"github.com/grafana/grafana/pkg/apis/playlist/v0alpha1"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/web/webtest"
+)
+
+var playlistGVR = schema.GroupVersionResource{Group: v0alpha1.GroupName, Version: v0alpha1.VersionID, Resource: "playlists"}
+
+type playlistRoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f playlistRoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// fakePlaylistTransport responds to the requests with a playlist, and records their paths under its name,
+// like the transport of the direct config of a user.
+func fakePlaylistTransport(name string, requests map[string][]string, mu *sync.Mutex) http.RoundTripper {
+	return playlistRoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		requests[name] = append(requests[name], req.URL.Path)
+		mu.Unlock()
+		body := `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "Playlist", "metadata": {"name": "a"}, "spec": {"title": "A", "interval": "5m"}}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestPlaylistClientCache(t *testing.T) {
+	t.Run("Should reuse the clients of the same config", func(t *testing.T) {
+		cache := newPlaylistClientCache()
+		first, err := cache.get(&clientrest.Config{Host: "https://a.example.com", BearerToken: "a"})
+		require.NoError(t, err)
+		again, err := cache.get(&clientrest.Config{Host: "https://a.example.com", BearerToken: "a"})
+		require.NoError(t, err)
+		require.Same(t, first, again)
+
+		otherHost, err := cache.get(&clientrest.Config{Host: "https://b.example.com", BearerToken: "a"})
+		require.NoError(t, err)
+		require.NotSame(t, first, otherHost)
+		otherToken, err := cache.get(&clientrest.Config{Host: "https://a.example.com", BearerToken: "b"})
+		require.NoError(t, err)
+		require.NotSame(t, first, otherToken)
+	})
+
+	t.Run("Should send the requests with the transport of the request", func(t *testing.T) {
+		var mu sync.Mutex
+		requests := map[string][]string{}
+		alice := &clientrest.Config{Transport: fakePlaylistTransport("alice", requests, &mu)}
+		bob := &clientrest.Config{Transport: fakePlaylistTransport("bob", requests, &mu)}
+
+		cache := newPlaylistClientCache()
+		client, err := cache.get(alice)
+		require.NoError(t, err)
+		shared, err := cache.get(bob)
+		require.NoError(t, err)
+		require.Same(t, client, shared)
+
+		_, err = client.Resource(playlistGVR).Namespace("default").Get(withRequestTransport(context.Background(), alice), "a", v1.GetOptions{})
+		require.NoError(t, err)
+		_, err = client.Resource(playlistGVR).Namespace("org-2").Get(withRequestTransport(context.Background(), bob), "a", v1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{
+			"alice": {"/apis/playlist.grafana.app/v0alpha1/namespaces/default/playlists/a"},
+			"bob":   {"/apis/playlist.grafana.app/v0alpha1/namespaces/org-2/playlists/a"},
+		}, requests)
+
+		// The requests without a transport in their context are not sent with the one of another request
+		_, err = client.Resource(playlistGVR).Namespace("default").Get(context.Background(), "a", v1.GetOptions{})
+		require.Error(t, err)
+		require.Len(t, requests["alice"], 1)
+	})
+
+	t.Run("Should not throttle the requests sharing a client", func(t *testing.T) {
+		var mu sync.Mutex
+		cfg := &clientrest.Config{Transport: fakePlaylistTransport("user", map[string][]string{}, &mu)}
+		client, err := newPlaylistClientCache().get(cfg)
+		require.NoError(t, err)
+
+		// Above the burst of the default rate limiter of the clients, which waits 200ms per request after it
+		start := time.Now()
+		for i := 0; i < 20; i++ {
+			_, err := client.Resource(playlistGVR).Namespace("default").Get(withRequestTransport(context.Background(), cfg), "a", v1.GetOptions{})
+			require.NoError(t, err)
+		}
+		require.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestAPIEndpoint_PlaylistK8sClientNamespaces(t *testing.T) {
+	var mu sync.Mutex
+	paths := []string{}
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, err := fmt.Fprint(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "Playlist", "metadata": {"name": "a", "resourceVersion": "1"}, "spec": {"title": "A", "interval": "5m"}}`)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(apiserver.Close)
+
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+		hs.promRegister = prometheus.NewRegistry()
+		hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+	})
+
+	// The client is shared by the orgs, but each request is made in the namespace of its org
+	for _, orgID := range []int64{1, 2, 1} {
+		req := server.NewGetRequest("/api/playlists/a")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: orgID, OrgRole: org.RoleViewer}))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, res.Body.Close())
+	}
+	require.Equal(t, []string{
+		"/apis/playlist.grafana.app/v0alpha1/namespaces/default/playlists/a",
+		"/apis/playlist.grafana.app/v0alpha1/namespaces/org-2/playlists/a",
+		"/apis/playlist.grafana.app/v0alpha1/namespaces/default/playlists/a",
+	}, paths)
+}
+
+func BenchmarkPlaylistK8sClient(b *testing.B) {
+	var mu sync.Mutex
+	cfg := &clientrest.Config{Transport: fakePlaylistTransport("user", map[string][]string{}, &mu)}
+	get := func(b *testing.B, client dynamic.Interface) {
+		_, err := client.Resource(playlistGVR).Namespace("default").Get(withRequestTransport(context.Background(), cfg), "a", v1.GetOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("new client per request", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			client, err := dynamic.NewForConfig(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			get(b, client)
+		}
+	})
+
+	b.Run("cached client", func(b *testing.B) {
+		b.ReportAllocs()
+		cache := newPlaylistClientCache()
+		for i := 0; i < b.N; i++ {
+			client, err := cache.get(cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			get(b, client)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/util"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// Playback event types reported by the clients.
+const (
+	playlistPlaybackItemShown   = "item_shown"
+	playlistPlaybackItemSkipped = "item_skipped"
+	playlistPlaybackRotation    = "rotation"
+)
+
+const (
+	// playlistPlaybackMaxEvents is the maximum number of events in a single report.
+	playlistPlaybackMaxEvents = 100
+	// playlistPlaybackMaxItem bounds the item indexes, and so the size of the aggregates of a playlist.
+	playlistPlaybackMaxItem = 1000
+	// playlistPlaybackMaxPlaylists bounds the number of playlists aggregated by an instance.
+	// Events of other playlists are still counted in the metrics.
+	playlistPlaybackMaxPlaylists = 1000
+	// playlistPlaybackMaxDwell bounds the dwell time of an event, so that the aggregates can't overflow.
+	playlistPlaybackMaxDwell = 24 * time.Hour
+
+	// Reports accepted per second for each user, and burst.
+	playlistPlaybackRPS   = 20
+	playlistPlaybackBurst = 50
+	// playlistPlaybackLimiterExpiration is how long the rate limiter of a user is kept after its last report.
+	// It's long enough for the limiter to be full again, so dropping it doesn't change the rate limiting.
+	playlistPlaybackLimiterExpiration = time.Minute
+)
+
+type playlistPlaybackKey struct {
+	orgID int64
+	uid   string
+}
+
+// playlistItemPlayback is the aggregated playback of an item of a playlist.
+type playlistItemPlayback struct {
+	shown   int64
+	skipped int64
+	dwellMs int64
+}
+
+// playlistPlayback is the aggregated playback of a playlist.
+type playlistPlayback struct {
+	rotations int64
+	items     map[int]*playlistItemPlayback
+}
+
+// playlistPlaybackRecorder records the playback events reported by the clients, in metrics and
+// in per playlist aggregates. The aggregates are kept in memory, so they're per instance and
+// they're reset when Grafana restarts.
+type playlistPlaybackRecorder struct {
+	events *prometheus.CounterVec
+	dwell  prometheus.Histogram
+
+	// limiters are the rate limiters of the users who reported playback recently, keyed by user cache key
+	limiters   *localcache.CacheService
+	limitersMu sync.Mutex
+	limit      rate.Limit
+	burst      int
+
+	mu        sync.Mutex
+	playlists map[playlistPlaybackKey]*playlistPlayback
+}
+
+func newPlaylistPlaybackRecorder(promRegisterer prometheus.Registerer) *playlistPlaybackRecorder {
+	r := &playlistPlaybackRecorder{
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grafana",
+			Name:      "playlist_playback_events_total",
+			Help:      "The total amount of playlist playback events reported by the clients",
+		}, []string{"type"}),
+		dwell: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "playlist_item_dwell_seconds",
+			Help:      "Time playlist items were shown before moving to the next one",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600},
+		}),
+		limiters:  localcache.New(playlistPlaybackLimiterExpiration, 2*playlistPlaybackLimiterExpiration),
+		limit:     rate.Limit(playlistPlaybackRPS),
+		burst:     playlistPlaybackBurst,
+		playlists: map[playlistPlaybackKey]*playlistPlayback{},
+	}
+	promRegisterer.MustRegister(r.events, r.dwell)
+	return r
+}
+
+// allow returns true if the user with the given cache key can report playback events now.
+func (r *playlistPlaybackRecorder) allow(userKey string) bool {
+	r.limitersMu.Lock()
+	defer r.limitersMu.Unlock()
+	limiter, ok := r.limiters.Get(userKey)
+	if !ok {
+		limiter = rate.NewLimiter(r.limit, r.burst)
+	}
+	// Set again to extend the expiration
+	r.limiters.SetDefault(userKey, limiter)
+	return limiter.(*rate.Limiter).Allow()
+}
+
+// record records the given events of a playlist. The events must have been validated.
+func (r *playlistPlaybackRecorder) record(key playlistPlaybackKey, events []dtos.PlaylistPlaybackEvent) {
+	for _, e := range events {
+		r.events.WithLabelValues(e.Type).Inc()
+		if e.Type != playlistPlaybackRotation && e.DwellMs > 0 {
+			r.dwell.Observe(float64(e.DwellMs) / 1000)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.playlists[key]
+	if !ok {
+		if len(r.playlists) >= playlistPlaybackMaxPlaylists {
+			return
+		}
+		p = &playlistPlayback{items: map[int]*playlistItemPlayback{}}
+		r.playlists[key] = p
+	}
+	for _, e := range events {
+		if e.Type == playlistPlaybackRotation {
+			p.rotations++
+			continue
+		}
+		item, ok := p.items[e.Item]
+		if !ok {
+			item = &playlistItemPlayback{}
+			p.items[e.Item] = item
+		}
+		if e.Type == playlistPlaybackItemSkipped {
+			item.skipped++
+		} else {
+			item.shown++
+		}
+		item.dwellMs += e.DwellMs
+	}
+}
+
+// stats returns the aggregated playback of a playlist, with the items ordered by index.
+func (r *playlistPlaybackRecorder) stats(key playlistPlaybackKey) dtos.PlaylistPlaybackStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := dtos.PlaylistPlaybackStats{Items: []dtos.PlaylistItemPlaybackStats{}}
+	p, ok := r.playlists[key]
+	if !ok {
+		return stats
+	}
+	stats.Rotations = p.rotations
+	indexes := make([]int, 0, len(p.items))
+	for i := range p.items {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	var dwellMs int64
+	for _, i := range indexes {
+		item := p.items[i]
+		stats.Items = append(stats.Items, dtos.PlaylistItemPlaybackStats{
+			Item:       i,
+			Shown:      item.shown,
+			Skipped:    item.skipped,
+			AvgDwellMs: average(item.dwellMs, item.shown+item.skipped),
+		})
+		stats.Shown += item.shown
+		stats.Skipped += item.skipped
+		dwellMs += item.dwellMs
+	}
+	stats.AvgDwellMs = average(dwellMs, stats.Shown+stats.Skipped)
+	return stats
+}
+
+func average(total int64, n int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	return total / n
+}
+
+// swagger:route POST /playlists/{uid}/playback-events playlists reportPlaylistPlayback
+//
+// Report playback events of a playlist.
+//
+// Events are item_shown and item_skipped, with the index of the item and how long it was shown,
+// and rotation when the playback went through all the items.
+//
+// Responses:
+// 202: acceptedResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+func (hs *HTTPServer) ReportPlaylistPlayback(c *contextmodel.ReqContext) response.Response {
+	if !hs.playlistPlayback.allow(c.SignedInUser.GetCacheKey()) {
+		return response.Error(http.StatusTooManyRequests, "Too many playback reports", nil)
+	}
+	cmd := dtos.PlaylistPlaybackEvents{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+	if len(cmd.Events) > playlistPlaybackMaxEvents {
+		return response.Error(http.StatusBadRequest, "Too many playback events in a single report", nil)
+	}
+	for _, e := range cmd.Events {
+		switch e.Type {
+		case playlistPlaybackItemShown, playlistPlaybackItemSkipped, playlistPlaybackRotation:
+		default:
+			return response.Error(http.StatusBadRequest, "Invalid playback event type, expected item_shown, item_skipped or rotation", nil)
+		}
+		if e.Item < 0 || e.Item >= playlistPlaybackMaxItem || e.DwellMs < 0 || e.DwellMs > playlistPlaybackMaxDwell.Milliseconds() {
+			return response.Error(http.StatusBadRequest, "Invalid playback event item or dwell time", nil)
+		}
+	}
+
+	key := playlistPlaybackKey{orgID: c.SignedInUser.GetOrgID(), uid: web.Params(c.Req)[":uid"]}
+	hs.playlistPlayback.record(key, cmd.Events)
+	return response.JSON(http.StatusAccepted, util.DynMap{"message": "Playback events recorded"})
+}
+
+// swagger:route GET /playlists/{uid}/playback-stats playlists getPlaylistPlaybackStats
+//
+// Get the playback reported for a playlist since the Grafana instance started.
+//
+// Responses:
+// 200: getPlaylistPlaybackStatsResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+func (hs *HTTPServer) GetPlaylistPlaybackStats(c *contextmodel.ReqContext) response.Response {
+	key := playlistPlaybackKey{orgID: c.SignedInUser.GetOrgID(), uid: web.Params(c.Req)[":uid"]}
+	return response.JSON(http.StatusOK, hs.playlistPlayback.stats(key))
+}
+
+// swagger:parameters reportPlaylistPlayback
+type ReportPlaylistPlaybackParams struct {
+	// in:body
+	// required:true
+	Body dtos.PlaylistPlaybackEvents
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+}
+
+// swagger:parameters getPlaylistPlaybackStats
+type GetPlaylistPlaybackStatsParams struct {
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+}
+
+// swagger:response getPlaylistPlaybackStatsResponse
+type GetPlaylistPlaybackStatsResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PlaylistPlaybackStats `json:"body"`
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/playlist/playlisttest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/web/webtest"
+)
+
+func TestAPIEndpoint_PlaylistPlayback(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	promRegistry := prometheus.NewRegistry()
+	var hs *HTTPServer
+	server := SetupAPITestServer(t, func(s *HTTPServer) {
+		s.playlistService = playlistService
+		s.promRegister = promRegistry
+		hs = s
+	})
+
+	reportAs := func(t *testing.T, usr *user.SignedInUser, uid string, body string) int {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, "/api/playlists/"+uid+"/playback-events", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, usr))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode
+	}
+	report := func(t *testing.T, uid string, body string) int {
+		t.Helper()
+		return reportAs(t, userWithPermissions(1, nil), uid, body)
+	}
+
+	getStats := func(t *testing.T, uid string) dtos.PlaylistPlaybackStats {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists/" + uid + "/playback-stats")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var stats dtos.PlaylistPlaybackStats
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&stats))
+		return stats
+	}
+
+	t.Run("Should record and aggregate playback events", func(t *testing.T) {
+		require.Equal(t, http.StatusAccepted, report(t, "a", `{"events": [
+			{"type": "item_shown", "item": 0, "dwellMs": 30000},
+			{"type": "item_shown", "item": 1, "dwellMs": 30000},
+			{"type": "item_skipped", "item": 2, "dwellMs": 2000},
+			{"type": "rotation"}
+		]}`))
+		require.Equal(t, http.StatusAccepted, report(t, "a", `{"events": [
+			{"type": "item_shown", "item": 0, "dwellMs": 10000},
+			{"type": "item_skipped", "item": 1, "dwellMs": 4000}
+		]}`))
+
+		require.Equal(t, dtos.PlaylistPlaybackStats{
+			Rotations:  1,
+			Shown:      3,
+			Skipped:    2,
+			AvgDwellMs: 15200,
+			Items: []dtos.PlaylistItemPlaybackStats{
+				{Item: 0, Shown: 2, AvgDwellMs: 20000},
+				{Item: 1, Shown: 1, Skipped: 1, AvgDwellMs: 17000},
+				{Item: 2, Skipped: 1, AvgDwellMs: 2000},
+			},
+		}, getStats(t, "a"))
+
+		require.Equal(t, 3.0, testutil.ToFloat64(hs.playlistPlayback.events.WithLabelValues(playlistPlaybackItemShown)))
+		require.Equal(t, 2.0, testutil.ToFloat64(hs.playlistPlayback.events.WithLabelValues(playlistPlaybackItemSkipped)))
+		require.Equal(t, 1.0, testutil.ToFloat64(hs.playlistPlayback.events.WithLabelValues(playlistPlaybackRotation)))
+		var m dto.Metric
+		require.NoError(t, hs.playlistPlayback.dwell.Write(&m))
+		require.Equal(t, uint64(5), m.GetHistogram().GetSampleCount())
+		require.Equal(t, 3, testutil.CollectAndCount(promRegistry, "grafana_playlist_playback_events_total"))
+	})
+
+	t.Run("Should return empty stats for playlists without playback", func(t *testing.T) {
+		stats := getStats(t, "b")
+		require.Zero(t, stats.Rotations)
+		require.Empty(t, stats.Items)
+	})
+
+	t.Run("Should reject invalid events", func(t *testing.T) {
+		before := getStats(t, "a")
+		for _, body := range []string{
+			`{"events": [{"type": "item_clicked", "item": 0}]}`,
+			`{"events": [{"type": "item_shown", "item": -1}]}`,
+			`{"events": [{"type": "item_shown", "item": 0, "dwellMs": -5}]}`,
+			`{"events": [{"type": "item_shown", "item": 0, "dwellMs": 9223372036854775807}]}`,
+			`{"events": [` + strings.Repeat(`{"type": "rotation"},`, playlistPlaybackMaxEvents) + `{"type": "rotation"}]}`,
+		} {
+			require.Equal(t, http.StatusBadRequest, report(t, "a", body), body)
+		}
+		require.Equal(t, before, getStats(t, "a"))
+	})
+
+	t.Run("Should rate limit the reports of each user", func(t *testing.T) {
+		hs.playlistPlayback.limiters.Flush()
+		hs.playlistPlayback.limit = 0
+		hs.playlistPlayback.burst = 1
+		body := `{"events": [{"type": "rotation"}]}`
+		require.Equal(t, http.StatusAccepted, report(t, "a", body))
+		before := getStats(t, "a")
+		require.Equal(t, http.StatusTooManyRequests, report(t, "a", body))
+		require.Equal(t, before, getStats(t, "a"))
+
+		// Other users are not limited by the reports of the first one
+		require.Equal(t, http.StatusAccepted, reportAs(t, &user.SignedInUser{UserID: 2, OrgID: 1, OrgRole: org.RoleViewer}, "a", body))
+	})
+}
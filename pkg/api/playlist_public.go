@@ -0,0 +1,480 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/apis/playlist/v0alpha1"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/util"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+const (
+	// Default and maximum lifetime of the public playlist links.
+	playlistPublicLinkDefaultTTL = 24 * time.Hour
+	playlistPublicLinkMaxTTL     = 30 * 24 * time.Hour
+
+	// playlistPublicLinks is the kvstore namespace of the issued public playlist links. The keys are the playlist
+	// UID and link ID, and the values the expiry of the link. Only the tokens of the links found there are accepted,
+	// so a token signed with a leaked or default secret key but never issued is rejected anyway.
+	playlistPublicLinks = "playlist-public-links"
+)
+
+var (
+	errPublicPlaylistTokenInvalid = errors.New("invalid public playlist token")
+	errPublicPlaylistTokenExpired = errors.New("public playlist token expired")
+)
+
+// publicPlaylistClaims are the claims of a public playlist token.
+// CreatedBy is the user who created the link, whose permissions the dashboards of the playlist are resolved with.
+type publicPlaylistClaims struct {
+	ID        string `json:"id"`
+	OrgID     int64  `json:"org"`
+	UID       string `json:"uid"`
+	CreatedBy int64  `json:"by"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type publicPlaylistClaimsKey struct{}
+
+// signPublicPlaylistToken returns a token holding the given claims, signed with the given key.
+// The token is the base64 encoded JSON claims and their HMAC-SHA256, separated by a dot.
+func signPublicPlaylistToken(key string, claims publicPlaylistClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(publicPlaylistSignature(key, encoded)), nil
+}
+
+// parsePublicPlaylistToken returns the claims of the given token if its signature is valid and it's not expired at now.
+// Tokens expiring later than the maximum lifetime of the links are invalid. Whether the link was issued isn't checked.
+func parsePublicPlaylistToken(key string, token string, now time.Time) (*publicPlaylistClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errPublicPlaylistTokenInvalid
+	}
+	decodedSignature, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(decodedSignature, publicPlaylistSignature(key, encoded)) {
+		return nil, errPublicPlaylistTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errPublicPlaylistTokenInvalid
+	}
+	claims := &publicPlaylistClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil || claims.ID == "" || claims.UID == "" || claims.OrgID == 0 || claims.CreatedBy == 0 {
+		return nil, errPublicPlaylistTokenInvalid
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if expiresAt.After(now.Add(playlistPublicLinkMaxTTL)) {
+		return nil, errPublicPlaylistTokenInvalid
+	}
+	if !now.Before(expiresAt) {
+		return nil, errPublicPlaylistTokenExpired
+	}
+	return claims, nil
+}
+
+func publicPlaylistSignature(key string, encoded string) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte("playlist-public-link." + encoded))
+	return h.Sum(nil)
+}
+
+func publicPlaylistLinks(kv kvstore.KVStore, orgID int64) *kvstore.NamespacedKVStore {
+	return kvstore.WithNamespace(kv, orgID, playlistPublicLinks)
+}
+
+func publicPlaylistLinkKey(uid string, id string) string {
+	return uid + "/" + id
+}
+
+// swagger:route POST /playlists/{uid}/public-links playlists createPlaylistPublicLink
+//
+// Create a public link to a playlist.
+//
+// The link gives read-only access to the playlist and its dashboards without authentication, until it expires
+// or it's revoked. The lifetime of the link is given in seconds, and defaults to a day.
+// Only the dashboards the creator of the link can view are shared, so the link must be created by a user.
+//
+// Responses:
+// 200: createPlaylistPublicLinkResponse
+// 400: badRequestError
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) CreatePlaylistPublicLink(c *contextmodel.ReqContext) response.Response {
+	cmd := dtos.CreatePlaylistPublicLinkCommand{}
+	if err := web.Bind(c.Req, &cmd); err != nil {
+		return response.Error(http.StatusBadRequest, "bad request data", err)
+	}
+	ttl := playlistPublicLinkDefaultTTL
+	if cmd.ExpiresIn != 0 {
+		ttl = time.Duration(cmd.ExpiresIn) * time.Second
+	}
+	if ttl <= 0 || ttl > playlistPublicLinkMaxTTL {
+		return response.Error(http.StatusBadRequest, fmt.Sprintf("Invalid link lifetime, expected between 1 and %d seconds", int64(playlistPublicLinkMaxTTL.Seconds())), nil)
+	}
+	if hs.Cfg.SecretKey == "" {
+		return response.Error(http.StatusInternalServerError, "Public playlist links require a secret key", nil)
+	}
+	createdBy, err := identity.UserIdentifier(c.SignedInUser.GetNamespacedID())
+	if err != nil || createdBy == 0 {
+		return response.Error(http.StatusBadRequest, "Public playlist links can only be created by users", err)
+	}
+
+	uid := web.Params(c.Req)[":uid"]
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	claims := publicPlaylistClaims{
+		ID:        util.GenerateShortUID(),
+		OrgID:     c.SignedInUser.GetOrgID(),
+		UID:       uid,
+		CreatedBy: createdBy,
+		ExpiresAt: expiresAt.Unix(),
+	}
+	token, err := signPublicPlaylistToken(hs.Cfg.SecretKey, claims)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to sign the public playlist link", err)
+	}
+	links := publicPlaylistLinks(hs.kvStore, claims.OrgID)
+	if err := links.Set(c.Req.Context(), publicPlaylistLinkKey(uid, claims.ID), strconv.FormatInt(claims.ExpiresAt, 10)); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to save the public playlist link", err)
+	}
+	hs.prunePublicPlaylistLinks(c, links)
+	return response.JSON(http.StatusOK, dtos.PlaylistPublicLink{
+		ID:        claims.ID,
+		Token:     token,
+		URL:       fmt.Sprintf("%sapi/public/playlists/%s", hs.Cfg.AppURL, url.PathEscape(token)),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// swagger:route DELETE /playlists/{uid}/public-links/{id} playlists revokePlaylistPublicLink
+//
+// Revoke a public link to a playlist.
+//
+// Responses:
+// 200: okResponse
+// 401: unauthorisedError
+// 403: forbiddenError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) RevokePlaylistPublicLink(c *contextmodel.ReqContext) response.Response {
+	ctx := c.Req.Context()
+	key := publicPlaylistLinkKey(web.Params(c.Req)[":uid"], web.Params(c.Req)[":id"])
+	links := publicPlaylistLinks(hs.kvStore, c.SignedInUser.GetOrgID())
+	_, ok, err := links.Get(ctx, key)
+	if err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to revoke the public playlist link", err)
+	}
+	if !ok {
+		return response.Error(http.StatusNotFound, "Public playlist link not found", nil)
+	}
+	if err := links.Del(ctx, key); err != nil {
+		return response.Error(http.StatusInternalServerError, "Failed to revoke the public playlist link", err)
+	}
+	return response.Success("Public playlist link revoked")
+}
+
+// prunePublicPlaylistLinks deletes the links of the org that expired, to keep the issued links bounded.
+func (hs *HTTPServer) prunePublicPlaylistLinks(c *contextmodel.ReqContext, links *kvstore.NamespacedKVStore) {
+	ctx := c.Req.Context()
+	all, err := links.GetAll(ctx)
+	if err != nil {
+		c.Logger.Warn("Failed to prune the expired public playlist links", "error", err)
+		return
+	}
+	now := time.Now().Unix()
+	for key, value := range all[c.SignedInUser.GetOrgID()] {
+		if expiresAt, err := strconv.ParseInt(value, 10, 64); err == nil && now >= expiresAt {
+			if err := links.Del(ctx, key); err != nil {
+				c.Logger.Warn("Failed to prune an expired public playlist link", "key", key, "error", err)
+			}
+		}
+	}
+}
+
+// validatePublicPlaylistToken rejects the requests whose public playlist token isn't valid, has expired or
+// wasn't issued, or whose link has been revoked. The claims of valid tokens are added to the request context.
+func (hs *HTTPServer) validatePublicPlaylistToken(c *contextmodel.ReqContext) {
+	if hs.Cfg.SecretKey == "" {
+		c.JsonApiErr(http.StatusUnauthorized, "Invalid public playlist link", nil)
+		return
+	}
+	claims, err := parsePublicPlaylistToken(hs.Cfg.SecretKey, web.Params(c.Req)[":token"], time.Now())
+	if errors.Is(err, errPublicPlaylistTokenExpired) {
+		c.JsonApiErr(http.StatusUnauthorized, "Public playlist link expired", err)
+		return
+	}
+	if err != nil {
+		c.JsonApiErr(http.StatusUnauthorized, "Invalid public playlist link", err)
+		return
+	}
+	value, issued, err := publicPlaylistLinks(hs.kvStore, claims.OrgID).Get(c.Req.Context(), publicPlaylistLinkKey(claims.UID, claims.ID))
+	if err != nil {
+		c.JsonApiErr(http.StatusInternalServerError, "Failed to check the public playlist link", err)
+		return
+	}
+	if !issued {
+		c.JsonApiErr(http.StatusUnauthorized, "Invalid public playlist link", nil)
+		return
+	}
+	// The expiry of the issued link prevails over the one of the token
+	if expiresAt, err := strconv.ParseInt(value, 10, 64); err != nil || time.Now().Unix() >= expiresAt {
+		c.JsonApiErr(http.StatusUnauthorized, "Public playlist link expired", err)
+		return
+	}
+	c.Req = c.Req.WithContext(context.WithValue(c.Req.Context(), publicPlaylistClaimsKey{}, claims))
+}
+
+// publicPlaylistViewer returns the identity used to resolve the dashboards of a public playlist: the creator of
+// the link, with their current permissions in the org of the playlist. The link never shares a dashboard its
+// creator can't view, and stops sharing the ones they lose access to.
+func (hs *HTTPServer) publicPlaylistViewer(ctx context.Context, claims *publicPlaylistClaims) (*user.SignedInUser, error) {
+	viewer, err := hs.userService.GetSignedInUser(ctx, &user.GetSignedInUserQuery{UserID: claims.CreatedBy, OrgID: claims.OrgID})
+	if err != nil {
+		return nil, err
+	}
+	if viewer.Permissions == nil {
+		viewer.Permissions = make(map[int64]map[string][]string)
+	}
+	if _, ok := viewer.Permissions[claims.OrgID]; !ok {
+		permissions, err := hs.accesscontrolService.GetUserPermissions(ctx, viewer, accesscontrol.Options{})
+		if err != nil {
+			return nil, err
+		}
+		viewer.Permissions[claims.OrgID] = accesscontrol.GroupScopesByAction(permissions)
+	}
+	return viewer, nil
+}
+
+// publicPlaylistHit is a dashboard of a public playlist, with the interval of the item it comes from
+// and the label of its section.
+type publicPlaylistHit struct {
+	*model.Hit
+	interval string
+	section  string
+}
+
+// publicPlaylist returns the playlist of the validated public token, its dashboards in the order of its items,
+// and the warnings of the tag items resolving to fewer dashboards than they have.
+func (hs *HTTPServer) publicPlaylist(c *contextmodel.ReqContext) (*playlist.PlaylistDTO, []publicPlaylistHit, []string, response.Response) {
+	ctx := c.Req.Context()
+	claims := ctx.Value(publicPlaylistClaimsKey{}).(*publicPlaylistClaims)
+	dto, err := hs.playlistService.Get(ctx, &playlist.GetPlaylistByUidQuery{UID: claims.UID, OrgId: claims.OrgID})
+	if err != nil {
+		if errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return nil, nil, nil, response.Error(http.StatusNotFound, "Playlist not found", err)
+		}
+		return nil, nil, nil, response.Error(http.StatusInternalServerError, "Failed to get the playlist", err)
+	}
+
+	viewer, err := hs.publicPlaylistViewer(ctx, claims)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return nil, nil, nil, response.Error(http.StatusUnauthorized, "Invalid public playlist link", err)
+		}
+		return nil, nil, nil, response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+	}
+	resolved, err := hs.playlistDashboards(ctx, viewer, dto.Items)
+	if err != nil {
+		return nil, nil, nil, response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+	}
+	hits := []publicPlaylistHit{}
+	var warnings []string
+	seen := map[string]bool{}
+	section := ""
+	for _, item := range dto.Items {
+		if item.Type == playlist.ItemTypeSection {
+			section = item.Value
+			continue
+		}
+		interval := dto.ItemInterval(item)
+		add := func(hit *model.Hit) {
+			if !seen[hit.UID] {
+				seen[hit.UID] = true
+				hits = append(hits, publicPlaylistHit{Hit: hit, interval: interval, section: section})
+			}
+		}
+		if v0alpha1.ItemType(item.Type) != v0alpha1.ItemTypeDashboardByTag {
+			if hit, ok := resolved.get(item); ok {
+				add(hit)
+			}
+			continue
+		}
+		tagged, warning, err := hs.playlistResolvedTagDashboards(ctx, viewer, item.Value)
+		if err != nil {
+			return nil, nil, nil, response.Error(http.StatusInternalServerError, "Failed to resolve the playlist dashboards", err)
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		for _, hit := range tagged {
+			add(hit)
+		}
+	}
+	return dto, hits, warnings, nil
+}
+
+// swagger:route GET /public/playlists/{token} playlists getPublicPlaylist
+//
+// Get a playlist and its dashboards with a public link.
+//
+// The tag items resolve to up to the [playlists] tag_max_dashboards setting, with a warning for the tags having more
+// dashboards.
+//
+// Responses:
+// 200: getPublicPlaylistResponse
+// 401: unauthorisedError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) GetPublicPlaylist(c *contextmodel.ReqContext) response.Response {
+	dto, hits, warnings, errResp := hs.publicPlaylist(c)
+	if errResp != nil {
+		return errResp
+	}
+	result := dtos.PublicPlaylist{
+		UID:        dto.Uid,
+		Name:       dto.Name,
+		Interval:   dto.Interval,
+		Dashboards: make([]dtos.PublicPlaylistDashboard, 0, len(hits)),
+		Warnings:   warnings,
+	}
+	exposed := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		result.Dashboards = append(result.Dashboards, dtos.PublicPlaylistDashboard{UID: hit.UID, Title: hit.Title, Interval: hit.interval, Section: hit.section})
+		exposed = append(exposed, hit.UID)
+	}
+	hs.logPublicPlaylistAccess(c, playlistAccessPublicLink, exposed)
+	return response.JSON(http.StatusOK, result)
+}
+
+// swagger:route GET /public/playlists/{token}/dashboards/{dashboardUid} playlists getPublicPlaylistDashboard
+//
+// Get a dashboard of a playlist with a public link.
+//
+// Only the dashboards of the playlist can be read.
+//
+// Responses:
+// 200: dashboardResponse
+// 401: unauthorisedError
+// 404: notFoundError
+// 500: internalServerError
+func (hs *HTTPServer) GetPublicPlaylistDashboard(c *contextmodel.ReqContext) response.Response {
+	_, hits, _, errResp := hs.publicPlaylist(c)
+	if errResp != nil {
+		return errResp
+	}
+	dashboardUID := web.Params(c.Req)[":dashboardUid"]
+	found := false
+	for _, h := range hits {
+		if h.UID == dashboardUID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return response.Error(http.StatusNotFound, "Dashboard not found in the playlist", nil)
+	}
+
+	claims := c.Req.Context().Value(publicPlaylistClaimsKey{}).(*publicPlaylistClaims)
+	dash, err := hs.DashboardService.GetDashboard(c.Req.Context(), &dashboards.GetDashboardQuery{UID: dashboardUID, OrgID: claims.OrgID})
+	if err != nil {
+		if errors.Is(err, dashboards.ErrDashboardNotFound) {
+			return response.Error(http.StatusNotFound, "Dashboard not found in the playlist", err)
+		}
+		return response.Error(http.StatusInternalServerError, "Failed to get the dashboard", err)
+	}
+	hs.logPublicPlaylistAccess(c, playlistAccessPublicLinkDashboard, []string{dashboardUID})
+	return response.JSON(http.StatusOK, dtos.DashboardFullWithMeta{
+		Meta: dtos.DashboardMeta{
+			Slug:    dash.Slug,
+			Type:    dashboards.DashTypeDB,
+			Created: dash.Created,
+			Updated: dash.Updated,
+			Version: dash.Version,
+		},
+		Dashboard: dash.Data,
+	})
+}
+
+// logPublicPlaylistAccess logs the dashboards of a playlist exposed through the public link of the validated token.
+// The actor is the public link, since its viewers aren't signed in.
+func (hs *HTTPServer) logPublicPlaylistAccess(c *contextmodel.ReqContext, path string, dashboardUIDs []string) {
+	claims := c.Req.Context().Value(publicPlaylistClaimsKey{}).(*publicPlaylistClaims)
+	hs.playlistAccessLog.logAccess(playlistAccessKey{orgID: claims.OrgID, uid: claims.UID, actor: "public-link:" + claims.ID, path: path}, dashboardUIDs)
+}
+
+// swagger:parameters createPlaylistPublicLink
+type CreatePlaylistPublicLinkParams struct {
+	// in:body
+	// required:true
+	Body dtos.CreatePlaylistPublicLinkCommand
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+}
+
+// swagger:parameters revokePlaylistPublicLink
+type RevokePlaylistPublicLinkParams struct {
+	// in:path
+	// required:true
+	UID string `json:"uid"`
+	// in:path
+	// required:true
+	ID string `json:"id"`
+}
+
+// swagger:parameters getPublicPlaylist
+type GetPublicPlaylistParams struct {
+	// in:path
+	// required:true
+	Token string `json:"token"`
+}
+
+// swagger:parameters getPublicPlaylistDashboard
+type GetPublicPlaylistDashboardParams struct {
+	// in:path
+	// required:true
+	Token string `json:"token"`
+	// in:path
+	// required:true
+	DashboardUID string `json:"dashboardUid"`
+}
+
+// swagger:response createPlaylistPublicLinkResponse
+type CreatePlaylistPublicLinkResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PlaylistPublicLink `json:"body"`
+}
+
+// swagger:response getPublicPlaylistResponse
+type GetPublicPlaylistResponse struct {
+	// The response message
+	// in: body
+	Body dtos.PublicPlaylist `json:"body"`
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/accesscontrol/actest"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/playlist/playlisttest"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/services/user/usertest"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web/webtest"
+)
+
+// viewableSearchService filters the hits of a search service by the dashboards the signed in user of the query
+// can view, like the search service does.
+type viewableSearchService struct {
+	search.Service
+}
+
+func (s *viewableSearchService) SearchHandler(ctx context.Context, q *search.Query) (model.HitList, error) {
+	hits, err := s.Service.SearchHandler(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	viewable := model.HitList{}
+	for _, hit := range hits {
+		evaluator := accesscontrol.EvalPermission(dashboards.ActionDashboardsRead, dashboards.ScopeDashboardsProvider.GetResourceScopeUID(hit.UID))
+		if evaluator.Evaluate(q.SignedInUser.GetPermissions()) {
+			viewable = append(viewable, hit)
+		}
+	}
+	return viewable, nil
+}
+
+func TestAPIEndpoint_PlaylistPublicLinks(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "Wallboard", Interval: "1m", Items: []playlist.PlaylistItemDTO{
+		{Type: "section", Value: "Prod"},
+		{Type: "dashboard_by_uid", Value: "dash-a"},
+		{Type: "section", Value: "Staging"},
+		{Type: "dashboard_by_tag", Value: "status", Interval: "10s"},
+	}}
+	dashboardService := dashboards.NewFakeDashboardService(t)
+	dashboardService.On("GetDashboard", mock.Anything, mock.Anything).Return(&dashboards.Dashboard{
+		UID: "dash-a", Slug: "dash-a", Data: simplejson.NewFromAny(map[string]any{"title": "Dashboard A"}),
+	}, nil).Maybe()
+
+	// The creator of the links can view the first two dashboards tagged status, but not the third one
+	canView := func(uids ...string) []accesscontrol.Permission {
+		permissions := []accesscontrol.Permission{}
+		for _, uid := range uids {
+			permissions = append(permissions, accesscontrol.Permission{Action: dashboards.ActionDashboardsRead, Scope: dashboards.ScopeDashboardsProvider.GetResourceScopeUID(uid)})
+		}
+		return permissions
+	}
+	accessControlService := &actest.FakeService{ExpectedPermissions: canView("dash-a", "dash-b")}
+	userService := &usertest.FakeUserService{GetSignedInUserFn: func(_ context.Context, q *user.GetSignedInUserQuery) (*user.SignedInUser, error) {
+		if q.UserID != 10 {
+			return nil, user.ErrUserNotFound
+		}
+		return &user.SignedInUser{UserID: q.UserID, OrgID: q.OrgID, OrgRole: org.RoleEditor}, nil
+	}}
+	searchService := &viewableSearchService{Service: &fakePlaylistSearchService{hits: model.HitList{
+		{UID: "dash-a", Title: "Dashboard A", Tags: []string{"status"}},
+		{UID: "dash-b", Title: "Dashboard B", Tags: []string{"status"}},
+		{UID: "dash-c", Title: "Dashboard C", Tags: []string{"status"}},
+	}}}
+
+	cfg := setting.NewCfg()
+	cfg.SecretKey = "secret"
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Cfg = cfg
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagPlaylistPublicLinks)
+		hs.playlistService = playlistService
+		hs.DashboardService = dashboardService
+		hs.SearchService = searchService
+		hs.userService = userService
+		hs.accesscontrolService = accessControlService
+		hs.kvStore = kvstore.NewFakeKVStore()
+	})
+	editor := &user.SignedInUser{UserID: 10, OrgID: 1, OrgRole: org.RoleEditor}
+
+	createLink := func(t *testing.T, body string) (*http.Response, dtos.PlaylistPublicLink) {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, "/api/playlists/a/public-links", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, editor))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		var link dtos.PlaylistPublicLink
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&link))
+		}
+		return res, link
+	}
+
+	getPublic := func(t *testing.T, path string) *http.Response {
+		t.Helper()
+		res, err := server.Send(server.NewGetRequest(path))
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, res.Body.Close()) })
+		return res
+	}
+
+	t.Run("Should grant access with a valid token", func(t *testing.T) {
+		res, link := createLink(t, `{"expiresIn": 3600}`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NotEmpty(t, link.ID)
+		require.WithinDuration(t, time.Now().Add(time.Hour), link.ExpiresAt, time.Minute)
+		require.True(t, strings.HasSuffix(link.URL, "api/public/playlists/"+link.Token))
+
+		res = getPublic(t, "/api/public/playlists/"+link.Token)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var public dtos.PublicPlaylist
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&public))
+		require.Equal(t, dtos.PublicPlaylist{
+			UID:      "a",
+			Name:     "Wallboard",
+			Interval: "1m",
+			Dashboards: []dtos.PublicPlaylistDashboard{
+				{UID: "dash-a", Title: "Dashboard A", Interval: "1m", Section: "Prod"},
+				{UID: "dash-b", Title: "Dashboard B", Interval: "10s", Section: "Staging"},
+			},
+		}, public)
+
+		res = getPublic(t, "/api/public/playlists/"+link.Token+"/dashboards/dash-a")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var dash dtos.DashboardFullWithMeta
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&dash))
+		require.Equal(t, "Dashboard A", dash.Dashboard.Get("title").MustString())
+
+		res = getPublic(t, "/api/public/playlists/"+link.Token+"/dashboards/dash-c")
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("Should cap the dashboards of the tag items with a warning", func(t *testing.T) {
+		_, link := createLink(t, `{}`)
+		cfg.Playlist.TagMaxDashboards = 1
+		t.Cleanup(func() { cfg.Playlist.TagMaxDashboards = 0 })
+
+		res := getPublic(t, "/api/public/playlists/"+link.Token)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var public dtos.PublicPlaylist
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&public))
+		require.Equal(t, []dtos.PublicPlaylistDashboard{{UID: "dash-a", Title: "Dashboard A", Interval: "1m", Section: "Prod"}}, public.Dashboards)
+		require.Equal(t, []string{`Tag "status" has more than 1 dashboards, only 1 of them are included`}, public.Warnings)
+	})
+
+	t.Run("Should deny access with an expired token", func(t *testing.T) {
+		token, err := signPublicPlaylistToken(cfg.SecretKey, publicPlaylistClaims{
+			ID: "expired", OrgID: 1, UID: "a", CreatedBy: 10, ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+		})
+		require.NoError(t, err)
+		res := getPublic(t, "/api/public/playlists/"+token)
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		res = getPublic(t, "/api/public/playlists/"+token+"/dashboards/dash-a")
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("Should deny access with a revoked token", func(t *testing.T) {
+		res, link := createLink(t, `{}`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.WithinDuration(t, time.Now().Add(playlistPublicLinkDefaultTTL), link.ExpiresAt, time.Minute)
+		require.Equal(t, http.StatusOK, getPublic(t, "/api/public/playlists/"+link.Token).StatusCode)
+
+		req := server.NewRequest(http.MethodDelete, "/api/playlists/a/public-links/"+link.ID, nil)
+		revoked, err := server.Send(webtest.RequestWithSignedInUser(req, editor))
+		require.NoError(t, err)
+		require.NoError(t, revoked.Body.Close())
+		require.Equal(t, http.StatusOK, revoked.StatusCode)
+
+		require.Equal(t, http.StatusUnauthorized, getPublic(t, "/api/public/playlists/"+link.Token).StatusCode)
+	})
+
+	t.Run("Should deny access with a token that was never issued", func(t *testing.T) {
+		// A token signed with the right key, e.g. the default secret key of an install, but never issued
+		token, err := signPublicPlaylistToken(cfg.SecretKey, publicPlaylistClaims{
+			ID: "forged", OrgID: 1, UID: "a", CreatedBy: 10, ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		})
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, getPublic(t, "/api/public/playlists/"+token).StatusCode)
+		require.Equal(t, http.StatusUnauthorized, getPublic(t, "/api/public/playlists/"+token+"/dashboards/dash-a").StatusCode)
+	})
+
+	t.Run("Should deny access with a token outliving the maximum link lifetime", func(t *testing.T) {
+		_, link := createLink(t, `{}`)
+		claims, err := parsePublicPlaylistToken(cfg.SecretKey, link.Token, time.Now())
+		require.NoError(t, err)
+		claims.ExpiresAt = time.Now().Add(10 * playlistPublicLinkMaxTTL).Unix()
+		token, err := signPublicPlaylistToken(cfg.SecretKey, *claims)
+		require.NoError(t, err)
+
+		_, err = parsePublicPlaylistToken(cfg.SecretKey, token, time.Now())
+		require.ErrorIs(t, err, errPublicPlaylistTokenInvalid)
+		require.Equal(t, http.StatusUnauthorized, getPublic(t, "/api/public/playlists/"+token).StatusCode)
+	})
+
+	t.Run("Should return 404 when revoking a link that wasn't issued", func(t *testing.T) {
+		req := server.NewRequest(http.MethodDelete, "/api/playlists/a/public-links/unknown", nil)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, editor))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("Should deny access with a tampered token", func(t *testing.T) {
+		_, link := createLink(t, `{}`)
+		claims, err := parsePublicPlaylistToken(cfg.SecretKey, link.Token, time.Now())
+		require.NoError(t, err)
+		claims.UID = "b"
+		forged, err := signPublicPlaylistToken("another secret", *claims)
+		require.NoError(t, err)
+
+		for _, token := range []string{forged, strings.TrimSuffix(link.Token, link.Token[len(link.Token)-2:]), "garbage"} {
+			require.Equal(t, http.StatusUnauthorized, getPublic(t, "/api/public/playlists/"+token).StatusCode, token)
+		}
+	})
+
+	t.Run("Should only share the dashboards the creator of the link can view", func(t *testing.T) {
+		_, link := createLink(t, `{}`)
+		accessControlService.ExpectedPermissions = canView("dash-a")
+		t.Cleanup(func() { accessControlService.ExpectedPermissions = canView("dash-a", "dash-b") })
+
+		res := getPublic(t, "/api/public/playlists/"+link.Token)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var public dtos.PublicPlaylist
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&public))
+		require.Equal(t, []dtos.PublicPlaylistDashboard{{UID: "dash-a", Title: "Dashboard A", Interval: "1m", Section: "Prod"}}, public.Dashboards)
+
+		require.Equal(t, http.StatusNotFound, getPublic(t, "/api/public/playlists/"+link.Token+"/dashboards/dash-b").StatusCode)
+		require.Equal(t, http.StatusNotFound, getPublic(t, "/api/public/playlists/"+link.Token+"/dashboards/dash-c").StatusCode)
+	})
+
+	t.Run("Should deny access with a link whose creator was deleted", func(t *testing.T) {
+		_, link := createLink(t, `{}`)
+		claims, err := parsePublicPlaylistToken(cfg.SecretKey, link.Token, time.Now())
+		require.NoError(t, err)
+		claims.CreatedBy = 11
+		token, err := signPublicPlaylistToken(cfg.SecretKey, *claims)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusUnauthorized, getPublic(t, "/api/public/playlists/"+token).StatusCode)
+	})
+
+	t.Run("Should require a user to create links", func(t *testing.T) {
+		req := server.NewRequest(http.MethodPost, "/api/playlists/a/public-links", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor, ApiKeyID: 1}))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("Should validate the link lifetime", func(t *testing.T) {
+		for _, body := range []string{`{"expiresIn": -1}`, `{"expiresIn": 31536000}`} {
+			res, _ := createLink(t, body)
+			require.Equal(t, http.StatusBadRequest, res.StatusCode, body)
+		}
+	})
+
+	t.Run("Should require the editor role to manage links", func(t *testing.T) {
+		req := server.NewRequest(http.MethodPost, "/api/playlists/a/public-links", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
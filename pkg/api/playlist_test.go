@@ -0,0 +1,2518 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+	clientrest "k8s.io/client-go/rest"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/dashboards"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/playlist/playlistimpl"
+	"github.com/grafana/grafana/pkg/services/playlist/playlisttest"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/search/model"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web/webtest"
+)
+
+func TestAPIEndpoint_SearchPlaylists(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylists = playlist.Playlists{
+		{UID: "a", Name: "A", Interval: "5m", OrgId: 1, UpdatedAt: 1},
+		{UID: "b", Name: "B", Interval: "5m", OrgId: 1, UpdatedAt: 2},
+	}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	search := func(t *testing.T, etag string) *http.Response {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+
+	t.Run("ETag is stable across identical queries", func(t *testing.T) {
+		first := search(t, "")
+		require.Equal(t, http.StatusOK, first.StatusCode)
+		require.NotEmpty(t, first.Header.Get("ETag"))
+
+		second := search(t, "")
+		require.Equal(t, http.StatusOK, second.StatusCode)
+		require.Equal(t, first.Header.Get("ETag"), second.Header.Get("ETag"))
+	})
+
+	t.Run("Matching If-None-Match returns 304", func(t *testing.T) {
+		etag := search(t, "").Header.Get("ETag")
+
+		res := search(t, etag)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+		require.Equal(t, etag, res.Header.Get("ETag"))
+	})
+
+	t.Run("ETag changes when a playlist is modified", func(t *testing.T) {
+		etag := search(t, "").Header.Get("ETag")
+
+		playlistService.ExpectedPlaylists[1].UpdatedAt = 3
+		res := search(t, etag)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NotEqual(t, etag, res.Header.Get("ETag"))
+	})
+}
+
+func TestIntegrationSearchPlaylistsPageETag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+	create := func(t *testing.T, name string) *playlist.Playlist {
+		t.Helper()
+		p, err := playlistService.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: name, Interval: "5m", OrgId: 1,
+			Items: []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "status"}}})
+		require.NoError(t, err)
+		return p
+	}
+	create(t, "A")
+	create(t, "B")
+	c := create(t, "C")
+
+	// The first page only has A
+	firstPage := func(t *testing.T, etag string) *http.Response {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists?perPage=1")
+		req.Header.Set("If-None-Match", etag)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+	etag := firstPage(t, "").Header.Get("ETag")
+	require.Equal(t, http.StatusNotModified, firstPage(t, etag).StatusCode)
+
+	t.Run("ETag changes when a playlist outside the page is updated", func(t *testing.T) {
+		// Later than the creation of the playlists, in the same millisecond or not
+		time.Sleep(2 * time.Millisecond)
+		_, err := playlistService.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: c.UID, OrgId: 1, Name: "C renamed", Interval: "1m",
+			Items: []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "status"}}})
+		require.NoError(t, err)
+
+		res := firstPage(t, etag)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NotEqual(t, etag, res.Header.Get("ETag"))
+		etag = res.Header.Get("ETag")
+	})
+
+	t.Run("ETag changes when a playlist outside the page is deleted", func(t *testing.T) {
+		require.NoError(t, playlistService.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: c.UID, OrgId: 1}))
+
+		res := firstPage(t, etag)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "2", res.Header.Get(playlistTotalCountHeader))
+		require.NotEqual(t, etag, res.Header.Get("ETag"))
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsK8sPageETag(t *testing.T) {
+	listVersion := "1"
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "1", r.URL.Query().Get("limit"))
+		w.Header().Set("Content-Type", "application/json")
+		_, err := fmt.Fprintf(w, `{
+			"apiVersion": "playlist.grafana.app/v0alpha1",
+			"kind": "PlaylistList",
+			"metadata": {"resourceVersion": %q, "continue": "next"},
+			"items": [{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "Playlist",
+				"metadata": {"name": "a", "namespace": "default", "resourceVersion": "1"},
+				"spec": {"title": "A", "interval": "5m", "items": []}
+			}]
+		}`, listVersion)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(apiserver.Close)
+
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+		hs.promRegister = prometheus.NewRegistry()
+		hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+	})
+	firstPage := func(t *testing.T, etag string) *http.Response {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists?perPage=1")
+		req.Header.Set("If-None-Match", etag)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+	etag := firstPage(t, "").Header.Get("ETag")
+	require.Equal(t, http.StatusNotModified, firstPage(t, etag).StatusCode)
+
+	// A playlist outside the page changed, so only the version of the list did
+	listVersion = "2"
+	res := firstPage(t, etag)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NotEqual(t, etag, res.Header.Get("ETag"))
+}
+
+func TestAPIEndpoint_SearchTrashedPlaylistsK8s(t *testing.T) {
+	var fieldSelectors, labelSelectors []string
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fieldSelectors = append(fieldSelectors, r.URL.Query().Get("fieldSelector"))
+		labelSelectors = append(labelSelectors, r.URL.Query().Get("labelSelector"))
+		w.Header().Set("Content-Type", "application/json")
+		_, err := fmt.Fprint(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1"}, "items": []}`)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(apiserver.Close)
+
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+		hs.promRegister = prometheus.NewRegistry()
+		hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+	})
+	for _, path := range []string{"/api/playlists", "/api/playlists?includeTrashed=true"} {
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, res.Body.Close())
+	}
+	require.Equal(t, []string{"", "includeTrashed=true"}, fieldSelectors)
+	require.Equal(t, []string{"", ""}, labelSelectors)
+}
+
+func TestAPIEndpoint_SearchPlaylistsLastModified(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 10, 0, 0, 250*int(time.Millisecond), time.UTC)
+	// Last-Modified is the end of the second of the last update
+	lastModified := time.Date(2024, 3, 1, 10, 0, 1, 0, time.UTC).Format(http.TimeFormat)
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylists = playlist.Playlists{
+		{UID: "a", Name: "A", Interval: "5m", OrgId: 1, UpdatedAt: updated.UnixMilli()},
+	}
+	playlistService.ExpectedLastUpdated = updated.UnixMilli()
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	search := func(t *testing.T, since string) *http.Response {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists")
+		if since != "" {
+			req.Header.Set("If-Modified-Since", since)
+		}
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+
+	t.Run("Unchanged playlists return 304", func(t *testing.T) {
+		require.Equal(t, lastModified, search(t, "").Header.Get("Last-Modified"))
+
+		res := search(t, lastModified)
+		require.Equal(t, http.StatusNotModified, res.StatusCode)
+		require.Equal(t, lastModified, res.Header.Get("Last-Modified"))
+	})
+
+	t.Run("Modified playlists return 200 with a fresh Last-Modified", func(t *testing.T) {
+		playlistService.ExpectedLastUpdated = updated.Add(time.Minute).UnixMilli()
+		t.Cleanup(func() { playlistService.ExpectedLastUpdated = updated.UnixMilli() })
+		res := search(t, lastModified)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, time.Date(2024, 3, 1, 10, 1, 1, 0, time.UTC).Format(http.TimeFormat), res.Header.Get("Last-Modified"))
+	})
+
+	t.Run("Updates in the same second as the last update are not hidden", func(t *testing.T) {
+		// The client got the playlists once the second of the last update was over, so a later update is in a later second
+		playlistService.ExpectedLastUpdated = updated.Add(500 * time.Millisecond).UnixMilli()
+		t.Cleanup(func() { playlistService.ExpectedLastUpdated = updated.UnixMilli() })
+		require.Equal(t, http.StatusNotModified, search(t, lastModified).StatusCode)
+
+		playlistService.ExpectedLastUpdated = updated.Add(time.Second).UnixMilli()
+		require.Equal(t, http.StatusOK, search(t, lastModified).StatusCode)
+	})
+
+	t.Run("Last-Modified is not set until the second of the last update is over", func(t *testing.T) {
+		playlistService.ExpectedLastUpdated = time.Now().Add(time.Hour).UnixMilli()
+		t.Cleanup(func() { playlistService.ExpectedLastUpdated = updated.UnixMilli() })
+		res := search(t, lastModified)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.Header.Get("Last-Modified"))
+	})
+
+	t.Run("If-None-Match takes precedence over If-Modified-Since", func(t *testing.T) {
+		req := server.NewGetRequest("/api/playlists")
+		req.Header.Set("If-Modified-Since", lastModified)
+		req.Header.Set("If-None-Match", `"stale"`)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsPreview(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylists = playlist.Playlists{
+		{UID: "a", Name: "A", Interval: "5m", OrgId: 1, UpdatedAt: 1},
+	}
+	playlistService.ExpectedItemsByUID = map[string][]playlist.PlaylistItemDTO{
+		"a": {
+			{Type: "dashboard_by_tag", Value: "graphite"},
+			{Type: "dashboard_by_uid", Value: "private"},
+			{Type: "dashboard_by_uid", Value: "first"},
+			{Type: "dashboard_by_id", Value: "2"},
+			{Type: "dashboard_by_uid", Value: "missing"},
+			{Type: "dashboard_by_uid", Value: "third"},
+		},
+	}
+	// "private" is not returned, as if the user could not view it
+	searchService := &fakePlaylistSearchService{hits: model.HitList{
+		{ID: 1, UID: "first", Title: "First"},
+		{ID: 2, UID: "second", Title: "Second"},
+		{ID: 3, UID: "third", Title: "Third"},
+	}}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+		hs.SearchService = searchService
+	})
+
+	search := func(t *testing.T, url string) []dtos.PlaylistSearchResult {
+		t.Helper()
+		req := server.NewGetRequest(url)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var results []dtos.PlaylistSearchResult
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&results))
+		require.NoError(t, res.Body.Close())
+		return results
+	}
+
+	t.Run("Without preview no titles are returned", func(t *testing.T) {
+		searchService.queries = nil
+		results := search(t, "/api/playlists")
+		require.Len(t, results, 1)
+		require.Nil(t, results[0].Preview)
+		require.Empty(t, searchService.queries)
+	})
+
+	t.Run("Preview contains up to N viewable titles in order", func(t *testing.T) {
+		results := search(t, "/api/playlists?preview=2")
+		require.Len(t, results, 1)
+		require.Equal(t, "a", results[0].UID)
+		require.Equal(t, []string{"First", "Second"}, results[0].Preview)
+
+		results = search(t, "/api/playlists?preview=5")
+		require.Equal(t, []string{"First", "Second", "Third"}, results[0].Preview)
+	})
+
+	t.Run("Dashboards are resolved in batch with view permission", func(t *testing.T) {
+		searchService.queries = nil
+		search(t, "/api/playlists?preview=3")
+		require.Len(t, searchService.queries, 2)
+		for _, q := range searchService.queries {
+			require.NotNil(t, q.SignedInUser)
+			require.Equal(t, dashboards.PERMISSION_VIEW, q.Permission)
+		}
+		require.ElementsMatch(t, []string{"private", "first", "missing", "third"}, searchService.queries[0].DashboardUIDs)
+		require.Equal(t, []int64{2}, searchService.queries[1].DashboardIds)
+	})
+}
+
+// fakePlaylistSearchService returns the hits matching the requested dashboard UIDs, IDs or tags.
+type fakePlaylistSearchService struct {
+	hits    model.HitList
+	queries []*search.Query
+}
+
+func (f *fakePlaylistSearchService) SearchHandler(_ context.Context, q *search.Query) (model.HitList, error) {
+	f.queries = append(f.queries, q)
+	result := model.HitList{}
+	for _, hit := range f.hits {
+		for _, uid := range q.DashboardUIDs {
+			if hit.UID == uid {
+				result = append(result, hit)
+			}
+		}
+		for _, id := range q.DashboardIds {
+			if hit.ID == id {
+				result = append(result, hit)
+			}
+		}
+		for _, tag := range q.Tags {
+			if slices.Contains(hit.Tags, tag) {
+				result = append(result, hit)
+			}
+		}
+	}
+	switch q.Sort {
+	case "alpha-asc":
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Title < result[j].Title })
+	case "alpha-desc":
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Title > result[j].Title })
+	}
+	if q.Limit > 0 && int64(len(result)) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
+
+func (f *fakePlaylistSearchService) SortOptions() []model.SortOption { return nil }
+
+// recordingPlaylistService is a fake playlist service recording the items of the last update, which fails
+// with updateError if it's set.
+type recordingPlaylistService struct {
+	*playlisttest.FakePlaylistService
+	updatedItems []playlist.PlaylistItem
+	updateError  error
+}
+
+func (s *recordingPlaylistService) Update(ctx context.Context, cmd *playlist.UpdatePlaylistCommand) (*playlist.PlaylistDTO, error) {
+	if s.updateError != nil {
+		return nil, s.updateError
+	}
+	s.updatedItems = cmd.Items
+	return s.FakePlaylistService.Update(ctx, cmd)
+}
+
+// playlistUpdateErrors are the update errors of the playlist service, with the status they're mapped to.
+var playlistUpdateErrors = map[error]int{
+	fmt.Errorf("%w: %q is not a positive duration", playlist.ErrInvalidInterval, "soon"): http.StatusBadRequest,
+	playlist.ErrPlaylistAlreadyExists: http.StatusConflict,
+	playlist.ErrMaintenanceMode:       http.StatusServiceUnavailable,
+	errors.New("database is locked"):  http.StatusInternalServerError,
+}
+
+func TestAPIEndpoint_ReorderPlaylist(t *testing.T) {
+	playlistService := &recordingPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{
+		Uid:      "a",
+		Name:     "A",
+		Interval: "5m",
+		Items: []playlist.PlaylistItemDTO{
+			{Type: "dashboard_by_tag", Value: "graphite"},
+			{Type: "dashboard_by_uid", Value: "charlie"},
+			{Type: "dashboard_by_uid", Value: "missing"},
+			{Type: "dashboard_by_id", Value: "1"},
+			{Type: "dashboard_by_uid", Value: "bravo"},
+		},
+	}
+	searchService := &fakePlaylistSearchService{hits: model.HitList{
+		{ID: 1, UID: "alpha", Title: "Alpha"},
+		{ID: 2, UID: "bravo", Title: "bravo"},
+		{ID: 3, UID: "charlie", Title: "Charlie"},
+	}}
+	now := time.Now()
+	dashboardService := dashboards.NewFakeDashboardService(t)
+	dashboardService.On("GetDashboards", mock.Anything, mock.Anything).Return([]*dashboards.Dashboard{
+		{UID: "alpha", Updated: now.Add(-time.Hour)},
+		{UID: "bravo", Updated: now},
+		{UID: "charlie", Updated: now.Add(-2 * time.Hour)},
+	}, nil).Maybe()
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+		hs.SearchService = searchService
+		hs.DashboardService = dashboardService
+	})
+
+	reorder := func(t *testing.T, query string) (int, []string) {
+		t.Helper()
+		// Each reorder starts from the original order
+		items := append([]playlist.PlaylistItemDTO{}, playlistService.ExpectedPlaylistDTO.Items...)
+		defer func() { playlistService.ExpectedPlaylistDTO.Items = items }()
+
+		playlistService.updatedItems = nil
+		req := server.NewRequest(http.MethodPost, "/api/playlists/a/reorder"+query, nil)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		values := []string{}
+		for _, item := range playlistService.updatedItems {
+			values = append(values, item.Value)
+		}
+		return res.StatusCode, values
+	}
+
+	t.Run("Should sort by title", func(t *testing.T) {
+		status, values := reorder(t, "?by=title")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"1", "bravo", "charlie", "graphite", "missing"}, values)
+
+		status, values = reorder(t, "?by=title&dir=desc")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"charlie", "bravo", "1", "graphite", "missing"}, values)
+	})
+
+	t.Run("Should sort by last update", func(t *testing.T) {
+		status, values := reorder(t, "?by=updated")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"charlie", "1", "bravo", "graphite", "missing"}, values)
+
+		status, values = reorder(t, "?by=updated&dir=desc")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"bravo", "1", "charlie", "graphite", "missing"}, values)
+	})
+
+	t.Run("Should reject invalid sorts", func(t *testing.T) {
+		for _, query := range []string{"", "?by=name", "?by=title&dir=up"} {
+			status, values := reorder(t, query)
+			require.Equal(t, http.StatusBadRequest, status, query)
+			require.Empty(t, values)
+		}
+	})
+
+	t.Run("Should map the update errors", func(t *testing.T) {
+		t.Cleanup(func() { playlistService.updateError = nil })
+		for updateError, expStatus := range playlistUpdateErrors {
+			playlistService.updateError = updateError
+			status, _ := reorder(t, "?by=title")
+			require.Equal(t, expStatus, status, updateError.Error())
+		}
+	})
+
+	t.Run("Should require the editor role", func(t *testing.T) {
+		req := server.NewRequest(http.MethodPost, "/api/playlists/a/reorder?by=title", nil)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+}
+
+func TestAPIEndpoint_ReorderPlaylistItems(t *testing.T) {
+	editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+	reorder := func(t *testing.T, server *webtest.Server, body string) *http.Response {
+		t.Helper()
+		req := server.NewRequest(http.MethodPatch, "/api/playlists/a/items/reorder", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, editor))
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, res.Body.Close()) })
+		return res
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &recordingPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+		playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+		playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{
+			Uid:      "a",
+			Name:     "A",
+			Interval: "5m",
+			Items: []playlist.PlaylistItemDTO{
+				{Type: "dashboard_by_uid", Value: "alpha", Interval: "30s"},
+				{Type: "dashboard_by_tag", Value: "status"},
+				{Type: "dashboard_by_uid", Value: "bravo"},
+			},
+		}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		for name, body := range map[string]string{
+			"missing items":   `{"order": ["dashboard_by_uid:bravo", "dashboard_by_uid:alpha"]}`,
+			"extra items":     `{"order": ["dashboard_by_uid:bravo", "dashboard_by_tag:status", "dashboard_by_uid:alpha", "dashboard_by_uid:charlie"]}`,
+			"unknown items":   `{"order": ["dashboard_by_uid:bravo", "dashboard_by_tag:status", "dashboard_by_uid:charlie"]}`,
+			"duplicate items": `{"order": ["dashboard_by_uid:bravo", "dashboard_by_uid:bravo", "dashboard_by_uid:alpha"]}`,
+		} {
+			t.Run("Should reject an order with "+name, func(t *testing.T) {
+				playlistService.updatedItems = nil
+				res := reorder(t, server, body)
+				require.Equal(t, http.StatusBadRequest, res.StatusCode)
+				require.Nil(t, playlistService.updatedItems)
+			})
+		}
+
+		t.Run("Should save the items in the new order", func(t *testing.T) {
+			res := reorder(t, server, `{"order": ["dashboard_by_uid:bravo", "dashboard_by_uid:alpha", "dashboard_by_tag:status"]}`)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			require.Equal(t, []playlist.PlaylistItem{
+				{Type: "dashboard_by_uid", Value: "bravo"},
+				{Type: "dashboard_by_uid", Value: "alpha", Interval: "30s"},
+				{Type: "dashboard_by_tag", Value: "status"},
+			}, playlistService.updatedItems)
+		})
+
+		t.Run("Should map the update errors", func(t *testing.T) {
+			t.Cleanup(func() { playlistService.updateError = nil })
+			for updateError, expStatus := range playlistUpdateErrors {
+				playlistService.updateError = updateError
+				res := reorder(t, server, `{"order": ["dashboard_by_uid:bravo", "dashboard_by_uid:alpha", "dashboard_by_tag:status"]}`)
+				require.Equal(t, expStatus, res.StatusCode, updateError.Error())
+			}
+		})
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		var patch map[string]any
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			items := `[{"type": "dashboard_by_uid", "value": "alpha", "interval": "30s"}, {"type": "dashboard_by_tag", "value": "status"}]`
+			if r.Method == http.MethodPatch {
+				require.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&patch))
+				items = `[{"type": "dashboard_by_tag", "value": "status"}, {"type": "dashboard_by_uid", "value": "alpha", "interval": "30s"}]`
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, err := fmt.Fprintf(w, `{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "Playlist",
+				"metadata": {"name": "a", "namespace": "default", "resourceVersion": "7"},
+				"spec": {"title": "A", "interval": "5m", "items": %s}
+			}`, items)
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		res := reorder(t, server, `{"order": ["dashboard_by_uid:alpha"]}`)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Nil(t, patch)
+
+		res = reorder(t, server, `{"order": ["dashboard_by_tag:status", "dashboard_by_uid:alpha"]}`)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, map[string]any{
+			"metadata": map[string]any{"resourceVersion": "7"},
+			"spec": map[string]any{"items": []any{
+				map[string]any{"type": "dashboard_by_tag", "value": "status"},
+				map[string]any{"type": "dashboard_by_uid", "value": "alpha", "interval": "30s"},
+			}},
+		}, patch)
+		var dto playlist.PlaylistDTO
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&dto))
+		require.Equal(t, "dashboard_by_tag", dto.Items[0].Type)
+	})
+}
+
+// inMemoryPlaylistService is a fake playlist service storing the playlists of org 1 by UID. Its updates fail
+// with updateError if it's set.
+type inMemoryPlaylistService struct {
+	*playlisttest.FakePlaylistService
+	playlists   map[string]*playlist.PlaylistDTO
+	updateError error
+}
+
+func (s *inMemoryPlaylistService) GetWithoutItems(_ context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.Playlist, error) {
+	dto, ok := s.playlists[q.UID]
+	if !ok || q.OrgId != 1 {
+		return nil, playlist.ErrPlaylistNotFound
+	}
+	return &playlist.Playlist{UID: dto.Uid, Name: dto.Name, Interval: dto.Interval, OrgId: 1}, nil
+}
+
+func (s *inMemoryPlaylistService) Get(_ context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.PlaylistDTO, error) {
+	dto, ok := s.playlists[q.UID]
+	if !ok || q.OrgId != 1 {
+		return nil, playlist.ErrPlaylistNotFound
+	}
+	return dto, nil
+}
+
+func (s *inMemoryPlaylistService) Update(_ context.Context, cmd *playlist.UpdatePlaylistCommand) (*playlist.PlaylistDTO, error) {
+	if s.updateError != nil {
+		return nil, s.updateError
+	}
+	dto := &playlist.PlaylistDTO{Uid: cmd.UID, Name: cmd.Name, Interval: cmd.Interval, Items: []playlist.PlaylistItemDTO{}}
+	for _, item := range cmd.Items {
+		dto.Items = append(dto.Items, playlist.PlaylistItemDTO{Type: item.Type, Value: item.Value})
+	}
+	s.playlists[cmd.UID] = dto
+	return dto, nil
+}
+
+func (s *inMemoryPlaylistService) Delete(_ context.Context, cmd *playlist.DeletePlaylistCommand) error {
+	delete(s.playlists, cmd.UID)
+	return nil
+}
+
+func TestAPIEndpoint_MergePlaylist(t *testing.T) {
+	playlistService := &inMemoryPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	reset := func() {
+		playlistService.playlists = map[string]*playlist.PlaylistDTO{
+			"target": {Uid: "target", Name: "Target", Interval: "5m", Items: []playlist.PlaylistItemDTO{
+				{Type: "dashboard_by_uid", Value: "a"},
+				{Type: "dashboard_by_tag", Value: "graphite"},
+			}},
+			"source": {Uid: "source", Name: "Source", Interval: "1m", Items: []playlist.PlaylistItemDTO{
+				{Type: "dashboard_by_uid", Value: "b"},
+				{Type: "dashboard_by_uid", Value: "a"},
+				{Type: "dashboard_by_tag", Value: "influxdb"},
+			}},
+		}
+	}
+
+	merge := func(t *testing.T, u *user.SignedInUser, body string) (int, []string) {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, "/api/playlists/target/merge", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, u))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		if res.StatusCode != http.StatusOK {
+			return res.StatusCode, nil
+		}
+		var dto playlist.PlaylistDTO
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&dto))
+		values := []string{}
+		for _, item := range dto.Items {
+			values = append(values, item.Value)
+		}
+		return res.StatusCode, values
+	}
+	editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+
+	t.Run("Should append the source items", func(t *testing.T) {
+		reset()
+		status, values := merge(t, editor, `{"sourceUid": "source"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"a", "graphite", "b", "a", "influxdb"}, values)
+		require.Contains(t, playlistService.playlists, "source")
+		require.Equal(t, "Target", playlistService.playlists["target"].Name)
+	})
+
+	t.Run("Should skip duplicated items with dedup", func(t *testing.T) {
+		reset()
+		status, values := merge(t, editor, `{"sourceUid": "source", "dedup": true}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"a", "graphite", "b", "influxdb"}, values)
+	})
+
+	t.Run("Should delete the source if requested", func(t *testing.T) {
+		reset()
+		status, values := merge(t, editor, `{"sourceUid": "source", "dedup": true, "deleteSource": true}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"a", "graphite", "b", "influxdb"}, values)
+		require.NotContains(t, playlistService.playlists, "source")
+	})
+
+	t.Run("Should reject invalid sources", func(t *testing.T) {
+		reset()
+		for body, expStatus := range map[string]int{
+			`{}`:                        http.StatusBadRequest,
+			`{"sourceUid": "target"}`:   http.StatusBadRequest,
+			`{"sourceUid": "notfound"}`: http.StatusNotFound,
+		} {
+			status, _ := merge(t, editor, body)
+			require.Equal(t, expStatus, status, body)
+		}
+		require.Len(t, playlistService.playlists["target"].Items, 2)
+	})
+
+	t.Run("Should map the update errors", func(t *testing.T) {
+		t.Cleanup(func() { playlistService.updateError = nil })
+		for updateError, expStatus := range playlistUpdateErrors {
+			reset()
+			playlistService.updateError = updateError
+			status, _ := merge(t, editor, `{"sourceUid": "source", "deleteSource": true}`)
+			require.Equal(t, expStatus, status, updateError.Error())
+			require.Contains(t, playlistService.playlists, "source")
+		}
+	})
+
+	t.Run("Should not find the source in another org", func(t *testing.T) {
+		reset()
+		status, _ := merge(t, &user.SignedInUser{OrgID: 2, OrgRole: org.RoleEditor}, `{"sourceUid": "source"}`)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Len(t, playlistService.playlists["target"].Items, 2)
+	})
+
+	t.Run("Should require the editor role", func(t *testing.T) {
+		reset()
+		status, _ := merge(t, userWithPermissions(1, nil), `{"sourceUid": "source", "deleteSource": true}`)
+		require.Equal(t, http.StatusForbidden, status)
+		require.Contains(t, playlistService.playlists, "source")
+	})
+}
+
+func TestAPIEndpoint_GetPlaylistResponseVersion(t *testing.T) {
+	created, updated := time.UnixMilli(1000).UTC(), time.UnixMilli(2000).UTC()
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{
+		Uid:       "a",
+		Name:      "A",
+		Interval:  "5m",
+		Created:   &created,
+		Updated:   &updated,
+		CreatedBy: "user:1",
+		CreatedAt: 1000,
+		UpdatedAt: 2000,
+		Items: []playlist.PlaylistItemDTO{
+			{Type: "dashboard_by_uid", Value: "first"},
+			{Type: "dashboard_by_tag", Value: "graphite"},
+		},
+	}
+	v1 := `{
+		"uid": "a",
+		"name": "A",
+		"interval": "5m",
+		"items": [
+			{"type": "dashboard_by_uid", "value": "first"},
+			{"type": "dashboard_by_tag", "value": "graphite"}
+		],
+		"created": "1970-01-01T00:00:01Z",
+		"updated": "1970-01-01T00:00:02Z",
+		"createdBy": "user:1"
+	}`
+	v2 := `{
+		"uid": "a",
+		"name": "A",
+		"interval": "5m",
+		"items": [
+			{"type": "dashboard_by_uid", "value": "first"},
+			{"type": "dashboard_by_tag", "value": "graphite"}
+		],
+		"metadata": {
+			"created": "1970-01-01T00:00:01Z",
+			"createdBy": "user:1",
+			"updated": "1970-01-01T00:00:02Z",
+			"itemCount": 2
+		}
+	}`
+
+	get := func(t *testing.T, server *webtest.Server, accept string) string {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists/a")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return string(body)
+	}
+
+	t.Run("Feature flag disabled", func(t *testing.T) {
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+		require.JSONEq(t, v1, get(t, server, ""))
+		require.JSONEq(t, v1, get(t, server, "application/json;version=2"))
+	})
+
+	t.Run("Feature flag enabled", func(t *testing.T) {
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagPlaylistResponseV2)
+		})
+		require.JSONEq(t, v1, get(t, server, ""))
+		require.JSONEq(t, v1, get(t, server, "application/json"))
+		require.JSONEq(t, v2, get(t, server, "application/json;version=2"))
+		require.JSONEq(t, v2, get(t, server, "text/html, application/json; version=2"))
+	})
+}
+
+func TestAPIEndpoint_GetPlaylistETag(t *testing.T) {
+	get := func(t *testing.T, server *webtest.Server, path, etag string) *http.Response {
+		t.Helper()
+		req := server.NewGetRequest(path)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+	requireConditionalGet := func(t *testing.T, server *webtest.Server, change func()) {
+		t.Helper()
+		etags := map[string]string{}
+		for _, path := range []string{"/api/playlists/a", "/api/playlists/a/items"} {
+			res := get(t, server, path, "")
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			etag := res.Header.Get("ETag")
+			require.NotEmpty(t, etag, path)
+			etags[path] = etag
+
+			res = get(t, server, path, etag)
+			require.Equal(t, http.StatusNotModified, res.StatusCode, path)
+			require.Equal(t, etag, res.Header.Get("ETag"), path)
+
+			res = get(t, server, path, `"stale"`)
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			require.Equal(t, etag, res.Header.Get("ETag"), path)
+		}
+		require.NotEqual(t, etags["/api/playlists/a"], etags["/api/playlists/a/items"])
+
+		change()
+		for path, etag := range etags {
+			res := get(t, server, path, etag)
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			require.NotEqual(t, etag, res.Header.Get("ETag"), path)
+		}
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := playlisttest.NewPlaylistServiveFake()
+		playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+		playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "A", Interval: "5m", Items: []playlist.PlaylistItemDTO{
+			{Type: "dashboard_by_uid", Value: "first"},
+		}}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		requireConditionalGet(t, server, func() {
+			playlistService.ExpectedPlaylistDTO.Items = append(playlistService.ExpectedPlaylistDTO.Items, playlist.PlaylistItemDTO{Type: "dashboard_by_tag", Value: "graphite"})
+		})
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		value := "first"
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, err := fmt.Fprintf(w, `{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "Playlist",
+				"metadata": {"name": "a", "namespace": "default", "resourceVersion": "1"},
+				"spec": {"title": "A", "interval": "5m", "items": [{"type": "dashboard_by_uid", "value": %q}]}
+			}`, value)
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		requireConditionalGet(t, server, func() {
+			value = "second"
+		})
+	})
+}
+
+func TestAPIEndpoint_PlaylistExternalURLNotAllowed(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedError = fmt.Errorf("%w: host %q is not allowed", playlist.ErrExternalURLNotAllowed, "evil.example.com")
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	req := server.NewRequest(http.MethodPost, "/api/playlists", strings.NewReader(`{"name": "A", "interval": "5m", "items": [{"type": "external_url", "value": "https://evil.example.com"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestAPIEndpoint_PlaylistInvalidInterval(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedError = fmt.Errorf("%w: %q is not a positive duration, like 5m", playlist.ErrInvalidInterval, "soon")
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	req := server.NewRequest(http.MethodPost, "/api/playlists", strings.NewReader(`{"name": "A", "interval": "soon"}`))
+	req.Header.Set("Content-Type", "application/json")
+	res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+	require.NoError(t, err)
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	require.Equal(t, `invalid playlist interval: "soon" is not a positive duration, like 5m`, body["message"])
+}
+
+func TestAPIEndpoint_CreatePlaylistUID(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	create := func(t *testing.T, orgID int64, uid string) (int, map[string]any) {
+		t.Helper()
+		body := fmt.Sprintf(`{"uid": %q, "name": "Wallboard", "interval": "5m", "items": [{"type": "dashboard_by_tag", "value": "status"}]}`, uid)
+		req := server.NewRequest(http.MethodPost, "/api/playlists", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: orgID, OrgRole: org.RoleEditor}))
+		require.NoError(t, err)
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode, result
+	}
+
+	t.Run("A valid UID is used", func(t *testing.T) {
+		status, body := create(t, 1, "wallboard")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "wallboard", body["uid"])
+	})
+
+	t.Run("An invalid UID is rejected", func(t *testing.T) {
+		status, body := create(t, 1, "lobby wallboard")
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Contains(t, body["message"], `invalid playlist uid "lobby wallboard"`)
+	})
+
+	t.Run("A duplicate UID is a conflict", func(t *testing.T) {
+		status, body := create(t, 1, "wallboard")
+		require.Equal(t, http.StatusConflict, status)
+		require.Equal(t, "A playlist with the same UID already exists", body["message"])
+
+		// The UIDs are unique by org
+		status, _ = create(t, 2, "wallboard")
+		require.Equal(t, http.StatusOK, status)
+	})
+}
+
+func TestAPIEndpoint_PlaylistDedupe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), setting.NewCfg(), featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	const items = `[{"type": "dashboard_by_uid", "value": "a"}, {"type": "dashboard_by_tag", "value": "a"},
+		{"type": "dashboard_by_uid", "value": "a"}, {"type": "dashboard_by_uid", "value": "b"}]`
+	save := func(t *testing.T, method, path, body string) []playlist.PlaylistItemDTO {
+		t.Helper()
+		req := server.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, res.Body.Close())
+
+		dto, err := playlistService.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: "wallboard", OrgId: 1})
+		require.NoError(t, err)
+		return dto.Items
+	}
+	values := func(items []playlist.PlaylistItemDTO) []string {
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Type+":"+item.Value)
+		}
+		return result
+	}
+
+	body := fmt.Sprintf(`{"uid": "wallboard", "name": "Wallboard", "interval": "5m", "items": %s}`, items)
+	created := save(t, http.MethodPost, "/api/playlists?dedupe=true", body)
+	require.Equal(t, []string{"dashboard_by_uid:a", "dashboard_by_tag:a", "dashboard_by_uid:b"}, values(created))
+
+	body = fmt.Sprintf(`{"name": "Wallboard", "interval": "5m", "items": %s}`, items)
+	updated := save(t, http.MethodPut, "/api/playlists/wallboard", body)
+	require.Equal(t, []string{"dashboard_by_uid:a", "dashboard_by_tag:a", "dashboard_by_uid:a", "dashboard_by_uid:b"}, values(updated))
+
+	body = fmt.Sprintf(`{"name": "Wallboard", "interval": "5m", "items": %s, "dedupe": true}`, items)
+	updated = save(t, http.MethodPut, "/api/playlists/wallboard", body)
+	require.Equal(t, []string{"dashboard_by_uid:a", "dashboard_by_tag:a", "dashboard_by_uid:b"}, values(updated))
+}
+
+func TestAPIEndpoint_PlaylistValidateItems(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "A", Interval: "5m"}
+	// The user can't view the other dashboards, or they don't exist
+	searchService := &fakePlaylistSearchService{hits: model.HitList{
+		{ID: 1, UID: "alpha", Title: "Alpha"},
+		{ID: 2, UID: "bravo", Title: "Bravo"},
+	}}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+		hs.SearchService = searchService
+	})
+
+	save := func(t *testing.T, method, path string, items ...string) (int, map[string]any) {
+		t.Helper()
+		body := fmt.Sprintf(`{"name": "A", "interval": "5m", "items": [%s]}`, strings.Join(items, ", "))
+		req := server.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+		require.NoError(t, err)
+		var result map[string]any
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode, result
+	}
+	byUID := func(uid string) string { return fmt.Sprintf(`{"type": "dashboard_by_uid", "value": %q}`, uid) }
+	byTag := func(tag string) string { return fmt.Sprintf(`{"type": "dashboard_by_tag", "value": %q}`, tag) }
+
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		path := "/api/playlists/"
+		if method == http.MethodPut {
+			path += "a"
+		}
+
+		t.Run(method+" with valid dashboards", func(t *testing.T) {
+			status, _ := save(t, method, path+"?validateItems=true", byUID("alpha"), byTag("unknown"), byUID("bravo"))
+			require.Equal(t, http.StatusOK, status)
+		})
+
+		t.Run(method+" with invalid dashboards", func(t *testing.T) {
+			status, body := save(t, method, path+"?validateItems=true", byUID("deleted"), byUID("alpha"), byUID("hidden"), byUID("deleted"))
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Equal(t, []any{"deleted", "hidden"}, body["dashboardUids"])
+		})
+
+		t.Run(method+" without validation", func(t *testing.T) {
+			status, _ := save(t, method, path, byUID("deleted"), byUID("alpha"))
+			require.Equal(t, http.StatusOK, status)
+		})
+	}
+}
+
+func TestAPIEndpoint_PlaylistMaintenanceMode(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "A", Interval: "5m"}
+	editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+
+	send := func(t *testing.T, server *webtest.Server, method string, target string) *http.Response {
+		t.Helper()
+		var body io.Reader
+		if method != http.MethodGet && method != http.MethodDelete {
+			body = strings.NewReader(`{"name": "A", "interval": "5m"}`)
+		}
+		req := server.NewRequest(method, target, body)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, editor))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+
+	t.Run("Writes are allowed when not in maintenance mode", func(t *testing.T) {
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+		require.Equal(t, http.StatusOK, send(t, server, http.MethodPost, "/api/playlists").StatusCode)
+		require.Equal(t, http.StatusOK, send(t, server, http.MethodPut, "/api/playlists/a").StatusCode)
+		require.Equal(t, http.StatusOK, send(t, server, http.MethodDelete, "/api/playlists/a").StatusCode)
+	})
+
+	t.Run("Writes are rejected and reads succeed in maintenance mode", func(t *testing.T) {
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagPlaylistMaintenanceMode)
+		})
+		for _, tc := range []struct {
+			method string
+			target string
+		}{
+			{method: http.MethodPost, target: "/api/playlists"},
+			{method: http.MethodPut, target: "/api/playlists/a"},
+			{method: http.MethodDelete, target: "/api/playlists/a"},
+		} {
+			res := send(t, server, tc.method, tc.target)
+			require.Equal(t, http.StatusServiceUnavailable, res.StatusCode, tc.method)
+			require.Equal(t, "60", res.Header.Get("Retry-After"), tc.method)
+		}
+
+		require.Equal(t, http.StatusOK, send(t, server, http.MethodGet, "/api/playlists").StatusCode)
+		require.Equal(t, http.StatusOK, send(t, server, http.MethodGet, "/api/playlists/a").StatusCode)
+		require.Equal(t, http.StatusOK, send(t, server, http.MethodGet, "/api/playlists/a/items").StatusCode)
+	})
+}
+
+func TestAPIEndpoint_GetPlaylistShareLink(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{
+		Uid: "a",
+		Items: []playlist.PlaylistItemDTO{
+			{Type: "dashboard_by_uid", Value: "first"},
+			{Type: "dashboard_by_tag", Value: "graphite"},
+			{Type: "dashboard_by_uid", Value: "private"},
+		},
+	}
+	// The tag item expands to two dashboards, and "private" is not returned, as if the user could not view it
+	searchService := &fakePlaylistSearchService{hits: model.HitList{
+		{ID: 1, UID: "first", Title: "First"},
+		{ID: 2, UID: "second", Title: "Second", Tags: []string{"graphite"}},
+		{ID: 3, UID: "third", Title: "Third", Tags: []string{"graphite"}},
+	}}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+		hs.SearchService = searchService
+		hs.Cfg = setting.NewCfg()
+		hs.Cfg.AppURL = "https://grafana.example.com/"
+	})
+
+	get := func(t *testing.T, query string) (int, dtos.PlaylistShareLink) {
+		t.Helper()
+		req := server.NewGetRequest("/api/playlists/a/share-link" + query)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		var link dtos.PlaylistShareLink
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&link))
+		}
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode, link
+	}
+
+	for _, tc := range []struct {
+		query  string
+		expURL string
+	}{
+		{query: "", expURL: "https://grafana.example.com/playlists/play/a?item=0"},
+		{query: "?item=1&mode=kiosk", expURL: "https://grafana.example.com/playlists/play/a?item=1&kiosk=true"},
+		{query: "?item=1&mode=tv&autofit=true", expURL: "https://grafana.example.com/playlists/play/a?autofitpanels=true&item=1&kiosk=tv"},
+		{query: "?item=2", expURL: "https://grafana.example.com/playlists/play/a?item=2"},
+		{query: "?mode=normal&autofit=true", expURL: "https://grafana.example.com/playlists/play/a?autofitpanels=true&item=0"},
+	} {
+		status, link := get(t, tc.query)
+		require.Equal(t, http.StatusOK, status, tc.query)
+		require.Equal(t, tc.expURL, link.URL, tc.query)
+	}
+
+	for _, query := range []string{"?item=3", "?item=-1", "?item=first", "?mode=fullscreen"} {
+		status, _ := get(t, query)
+		require.Equal(t, http.StatusBadRequest, status, query)
+	}
+}
+
+func TestAPIEndpoint_GetPlaylistSizeDistribution(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedSizes = []playlist.PlaylistSizeCount{
+		{Items: 1, Playlists: 3},
+		{Items: 4, Playlists: 2},
+	}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	t.Run("Should return the distribution to org admins", func(t *testing.T) {
+		req := server.NewGetRequest("/api/playlists/size-distribution")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleAdmin}))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var dist dtos.PlaylistSizeDistribution
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&dist))
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, int64(5), dist.Total)
+		require.Equal(t, playlistService.ExpectedSizes, dist.Sizes)
+	})
+
+	t.Run("Should be forbidden to other users", func(t *testing.T) {
+		req := server.NewGetRequest("/api/playlists/size-distribution")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+		require.NoError(t, res.Body.Close())
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsK8sClientMetrics(t *testing.T) {
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/apis/playlist.grafana.app/v0alpha1/namespaces/default/playlists", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{
+			"apiVersion": "playlist.grafana.app/v0alpha1",
+			"kind": "PlaylistList",
+			"metadata": {"resourceVersion": "1"},
+			"items": [{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "Playlist",
+				"metadata": {"name": "a", "namespace": "default", "resourceVersion": "1"},
+				"spec": {"title": "A", "interval": "5m", "items": []}
+			}]
+		}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(apiserver.Close)
+
+	promRegistry := prometheus.NewRegistry()
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+		hs.promRegister = promRegistry
+		hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+	})
+
+	req := server.NewGetRequest("/api/playlists")
+	res, err := server.Send(webtest.RequestWithSignedInUser(req, userWithPermissions(1, nil)))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var playlists []playlist.Playlist
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+	require.NoError(t, res.Body.Close())
+	require.Len(t, playlists, 1)
+	require.Equal(t, "a", playlists[0].UID)
+
+	require.Equal(t, 1, testutil.CollectAndCount(promRegistry, "grafana_playlist_k8s_client_duration_seconds"))
+	metrics, err := promRegistry.Gather()
+	require.NoError(t, err)
+	var clientMetrics []*dto.Metric
+	for _, mf := range metrics {
+		if mf.GetName() == "grafana_playlist_k8s_client_duration_seconds" {
+			clientMetrics = mf.Metric
+		}
+	}
+	require.Len(t, clientMetrics, 1)
+	require.Equal(t, "verb", clientMetrics[0].Label[0].GetName())
+	require.Equal(t, "list", clientMetrics[0].Label[0].GetValue())
+	require.Equal(t, uint64(1), clientMetrics[0].Histogram.GetSampleCount())
+}
+
+type fakeRestConfigProvider struct {
+	config *clientrest.Config
+}
+
+func (f fakeRestConfigProvider) GetDirectRestConfig(c *contextmodel.ReqContext) *clientrest.Config {
+	return f.config
+}
+
+// deletedPlaylistService finds the playlists without their items, but not with them,
+// as when a playlist is deleted while it's being read.
+type deletedPlaylistService struct {
+	*playlisttest.FakePlaylistService
+}
+
+func (s deletedPlaylistService) Get(context.Context, *playlist.GetPlaylistByUidQuery) (*playlist.PlaylistDTO, error) {
+	return nil, playlist.ErrPlaylistNotFound
+}
+
+func TestAPIEndpoint_GetPlaylistNotFound(t *testing.T) {
+	get := func(t *testing.T, server *webtest.Server, path string) int {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := playlisttest.NewPlaylistServiveFake()
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		playlistService.ExpectedError = playlist.ErrPlaylistNotFound
+		require.Equal(t, http.StatusNotFound, get(t, server, "/api/playlists/missing"))
+		require.Equal(t, http.StatusNotFound, get(t, server, "/api/playlists/missing/items"))
+
+		playlistService.ExpectedError = fmt.Errorf("database is locked")
+		require.Equal(t, http.StatusInternalServerError, get(t, server, "/api/playlists/a"))
+		require.Equal(t, http.StatusInternalServerError, get(t, server, "/api/playlists/a/items"))
+	})
+
+	t.Run("Legacy API with a playlist deleted while it's read", func(t *testing.T) {
+		playlistService := playlisttest.NewPlaylistServiveFake()
+		playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", OrgId: 1}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = deletedPlaylistService{playlistService}
+		})
+
+		require.Equal(t, http.StatusNotFound, get(t, server, "/api/playlists/a"))
+		require.Equal(t, http.StatusNotFound, get(t, server, "/api/playlists/a/items"))
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, err := w.Write([]byte(`{
+				"apiVersion": "v1",
+				"kind": "Status",
+				"status": "Failure",
+				"message": "playlists.playlist.grafana.app \"missing\" not found",
+				"reason": "NotFound",
+				"code": 404
+			}`))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		require.Equal(t, http.StatusNotFound, get(t, server, "/api/playlists/missing"))
+		require.Equal(t, http.StatusNotFound, get(t, server, "/api/playlists/missing/items"))
+	})
+}
+
+// searchRecordingPlaylistService records the search queries.
+type searchRecordingPlaylistService struct {
+	*playlisttest.FakePlaylistService
+	queries []playlist.GetPlaylistsQuery
+}
+
+func (s *searchRecordingPlaylistService) Search(ctx context.Context, q *playlist.GetPlaylistsQuery) (playlist.Playlists, error) {
+	s.queries = append(s.queries, *q)
+	return s.FakePlaylistService.Search(ctx, q)
+}
+
+func TestAPIEndpoint_SearchPlaylistsLimit(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.Playlist.SearchMaxLimit = 50
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &searchRecordingPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Cfg = cfg
+			hs.playlistService = playlistService
+		})
+
+		for path, expected := range map[string]int{
+			"/api/playlists?limit=20":    20,
+			"/api/playlists?limit=0":     50,
+			"/api/playlists?limit=500":   50,
+			"/api/playlists?perPage=500": 50,
+			"/api/playlists?limit=-1":    http.StatusBadRequest,
+			"/api/playlists?perPage=-5":  http.StatusBadRequest,
+		} {
+			playlistService.queries = nil
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			if expected == http.StatusBadRequest {
+				require.Equal(t, http.StatusBadRequest, res.StatusCode, path)
+				require.Empty(t, playlistService.queries, path)
+				continue
+			}
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			require.Len(t, playlistService.queries, 1, path)
+			require.Equal(t, expected, playlistService.queries[0].Limit, path)
+		}
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		limits := []string{}
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limits = append(limits, r.URL.Query().Get("limit"))
+			w.Header().Set("Content-Type", "application/json")
+			_, err := fmt.Fprint(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1"}, "items": []}`)
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Cfg = cfg
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		for path, expected := range map[string]string{
+			"/api/playlists":           "",
+			"/api/playlists?limit=20":  "20",
+			"/api/playlists?limit=0":   "50",
+			"/api/playlists?limit=500": "50",
+		} {
+			limits = nil
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			require.Equal(t, []string{expected}, limits, path)
+		}
+
+		limits = nil
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?limit=-1"), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Empty(t, limits)
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsPagination(t *testing.T) {
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &searchRecordingPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+		playlistService.ExpectedPlaylists = playlist.Playlists{{UID: "c", Name: "C", OrgId: 1}}
+		playlistService.ExpectedCount = 3
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		for path, expected := range map[string]playlist.GetPlaylistsQuery{
+			"/api/playlists":                     {Limit: 1000, Page: 1, Sort: "name-asc", OrgId: 1},
+			"/api/playlists?limit=5":             {Limit: 5, Page: 1, Sort: "name-asc", OrgId: 1},
+			"/api/playlists?page=3&perPage=1":    {Limit: 1, Page: 3, Sort: "name-asc", OrgId: 1},
+			"/api/playlists?page=0&perPage=1e9":  {Limit: 1000, Page: 1, Sort: "name-asc", OrgId: 1},
+			"/api/playlists?page=2&perPage=5000": {Limit: 1000, Page: 2, Sort: "name-asc", OrgId: 1},
+		} {
+			playlistService.queries = nil
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			require.Equal(t, "3", res.Header.Get("X-Total-Count"), path)
+			require.Equal(t, []playlist.GetPlaylistsQuery{expected}, playlistService.queries, path)
+		}
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		pages := map[string]string{"": "a", "2": "b"}
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("continue")
+			uid, ok := pages[token]
+			require.True(t, ok, token)
+			require.Equal(t, "1", r.URL.Query().Get("limit"))
+			next := ""
+			if token == "" {
+				next = "2"
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, err := fmt.Fprintf(w, `{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "PlaylistList",
+				"metadata": {"resourceVersion": "1", "continue": %q},
+				"items": [{
+					"apiVersion": "playlist.grafana.app/v0alpha1",
+					"kind": "Playlist",
+					"metadata": {"name": %q, "namespace": "default", "resourceVersion": "1"},
+					"spec": {"title": %q, "interval": "5m", "items": []}
+				}]
+			}`, next, uid, strings.ToUpper(uid))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		uids := []string{}
+		path := "/api/playlists?perPage=1"
+		for i := 0; i < 3 && path != ""; i++ {
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			var playlists []playlist.Playlist
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+			require.NoError(t, res.Body.Close())
+			for _, p := range playlists {
+				uids = append(uids, p.UID)
+			}
+			path = ""
+			if next := res.Header.Get("X-Continue"); next != "" {
+				path = "/api/playlists?perPage=1&continue=" + next
+			}
+		}
+		require.Equal(t, []string{"a", "b"}, uids)
+	})
+
+	t.Run("Kubernetes API fills the pages of the filtered searches", func(t *testing.T) {
+		// The playlists of the namespace in the order of the apiserver, whose titles match the query, but for b and e
+		titles := []string{"Prod A", "Dev B", "Prod C", "Prod D", "Dev E"}
+		var limits []string
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limits = append(limits, r.URL.Query().Get("limit"))
+			start, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+			limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+			require.NoError(t, err)
+			end, next := start+limit, ""
+			if end < len(titles) {
+				next = strconv.Itoa(end)
+			} else {
+				end = len(titles)
+			}
+			items := []string{}
+			for i := start; i < end; i++ {
+				items = append(items, fmt.Sprintf(`{
+					"apiVersion": "playlist.grafana.app/v0alpha1",
+					"kind": "Playlist",
+					"metadata": {"name": %q, "namespace": "default", "resourceVersion": "1"},
+					"spec": {"title": %q, "interval": "5m", "items": []}
+				}`, strings.ToLower(titles[i][len(titles[i])-1:]), titles[i]))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, err = fmt.Fprintf(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1", "continue": %q}, "items": [%s]}`,
+				next, strings.Join(items, ","))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+		page := func(t *testing.T, path string) ([]string, string) {
+			t.Helper()
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			var playlists []playlist.Playlist
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+			require.NoError(t, res.Body.Close())
+			uids := []string{}
+			for _, p := range playlists {
+				uids = append(uids, p.UID)
+			}
+			return uids, res.Header.Get("X-Continue")
+		}
+
+		// Only the missing playlists are listed, so that the next page starts after the last one of the page
+		uids, next := page(t, "/api/playlists?perPage=2&query=prod")
+		require.Equal(t, []string{"a", "c"}, uids)
+		require.Equal(t, []string{"2", "1"}, limits)
+		require.Equal(t, "3", next)
+
+		limits = nil
+		uids, next = page(t, "/api/playlists?perPage=2&query=prod&continue="+next)
+		require.Equal(t, []string{"d"}, uids)
+		require.Equal(t, []string{"2"}, limits)
+		require.Empty(t, next)
+	})
+}
+
+func TestAPIEndpoint_CountPlaylists(t *testing.T) {
+	count := func(t *testing.T, server *webtest.Server, orgID int64) int64 {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists/count"), userWithPermissions(orgID, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var result dtos.PlaylistCount
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		require.NoError(t, res.Body.Close())
+		return result.Count
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping integration test")
+		}
+		playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		for _, orgID := range []int64{1, 1, 2} {
+			_, err := playlistService.Create(context.Background(), &playlist.CreatePlaylistCommand{
+				OrgId: orgID, Name: "wallboard", Interval: "5m",
+				Items: []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "status"}},
+			})
+			require.NoError(t, err)
+		}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		require.Equal(t, int64(2), count(t, server, 1))
+		require.Equal(t, int64(1), count(t, server, 2))
+		require.Equal(t, int64(0), count(t, server, 3))
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		// The playlists of each namespace, a page at a time
+		pages := map[string][][]string{
+			"default": {{"a", "b"}, {"c"}},
+			"org-2":   {{"d"}},
+		}
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			namespace := strings.Split(strings.TrimPrefix(r.URL.Path, "/apis/playlist.grafana.app/v0alpha1/namespaces/"), "/")[0]
+			page := 0
+			if token := r.URL.Query().Get("continue"); token != "" {
+				page, _ = strconv.Atoi(token)
+			}
+			next := ""
+			if page+1 < len(pages[namespace]) {
+				next = strconv.Itoa(page + 1)
+			}
+			items := []string{}
+			if page < len(pages[namespace]) {
+				for _, uid := range pages[namespace][page] {
+					items = append(items, fmt.Sprintf(`{
+						"apiVersion": "playlist.grafana.app/v0alpha1",
+						"kind": "Playlist",
+						"metadata": {"name": %q, "namespace": %q, "resourceVersion": "1"},
+						"spec": {"title": %q, "interval": "5m", "items": []}
+					}`, uid, namespace, uid))
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, err := fmt.Fprintf(w, `{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "PlaylistList",
+				"metadata": {"resourceVersion": "1", "continue": %q},
+				"items": [%s]
+			}`, next, strings.Join(items, ","))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		require.Equal(t, int64(3), count(t, server, 1))
+		require.Equal(t, int64(1), count(t, server, 2))
+		require.Equal(t, int64(0), count(t, server, 3))
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsItemType(t *testing.T) {
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &searchRecordingPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		for path, expected := range map[string]string{
+			"/api/playlists":                       "",
+			"/api/playlists?type=dashboard_by_tag": "dashboard_by_tag",
+			"/api/playlists?type=dashboard_by_uid": "dashboard_by_uid",
+			"/api/playlists?type=dashboard_by_id":  "dashboard_by_id",
+		} {
+			playlistService.queries = nil
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			require.Len(t, playlistService.queries, 1, path)
+			require.Equal(t, expected, playlistService.queries[0].ItemType, path)
+		}
+
+		playlistService.queries = nil
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?type=folder"), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Empty(t, playlistService.queries)
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			item := func(name, itemType string) string {
+				return fmt.Sprintf(`{
+					"apiVersion": "playlist.grafana.app/v0alpha1",
+					"kind": "Playlist",
+					"metadata": {"name": %q, "namespace": "default", "resourceVersion": "1"},
+					"spec": {"title": %q, "interval": "5m", "items": [{"type": %q, "value": "1"}]}
+				}`, name, name, itemType)
+			}
+			_, err := fmt.Fprintf(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1"}, "items": [%s, %s, %s]}`,
+				item("tags", "dashboard_by_tag"), item("uids", "dashboard_by_uid"), item("ids", "dashboard_by_id"))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		for path, expected := range map[string][]string{
+			"/api/playlists":                       {"ids", "tags", "uids"},
+			"/api/playlists?type=dashboard_by_tag": {"tags"},
+			"/api/playlists?type=dashboard_by_uid": {"uids"},
+			"/api/playlists?type=dashboard_by_id":  {"ids"},
+		} {
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(path), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode, path)
+			var playlists []playlist.Playlist
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+			require.NoError(t, res.Body.Close())
+			uids := []string{}
+			for _, p := range playlists {
+				uids = append(uids, p.UID)
+			}
+			require.Equal(t, expected, uids, path)
+		}
+
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?type=folder"), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
+
+// itemsPlaylistService is a fake playlist service returning the items of each playlist by UID.
+type itemsPlaylistService struct {
+	*playlisttest.FakePlaylistService
+	items map[string][]playlist.PlaylistItemDTO
+	// batches are the playlist UIDs of each GetItemsByUIDs call
+	batches [][]string
+}
+
+func (s *itemsPlaylistService) GetItemsByUIDs(_ context.Context, q *playlist.GetPlaylistsItemsByUidsQuery) (map[string][]playlist.PlaylistItemDTO, error) {
+	s.batches = append(s.batches, q.PlaylistUIDs)
+	items := map[string][]playlist.PlaylistItemDTO{}
+	for _, uid := range q.PlaylistUIDs {
+		if playlistItems, ok := s.items[uid]; ok {
+			items[uid] = playlistItems
+		}
+	}
+	return items, nil
+}
+
+func (s *itemsPlaylistService) Get(_ context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.PlaylistDTO, error) {
+	return &playlist.PlaylistDTO{Uid: q.UID, Name: q.UID, Interval: "5m", Items: s.items[q.UID]}, nil
+}
+
+func TestAPIEndpoint_SearchPlaylistsIncludeItems(t *testing.T) {
+	items := map[string][]playlist.PlaylistItemDTO{
+		"a": {{Type: "dashboard_by_uid", Value: "first"}, {Type: "dashboard_by_tag", Value: "graphite"}},
+		"b": {{Type: "dashboard_by_id", Value: "2"}},
+	}
+
+	// compareItems checks that the inline items of the search results are the ones of the items endpoint.
+	compareItems := func(t *testing.T, server *webtest.Server, results []dtos.PlaylistSearchResult) {
+		t.Helper()
+		require.Len(t, results, 2)
+		for _, r := range results {
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists/"+r.UID+"/items"), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			var expected []playlist.PlaylistItemDTO
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&expected))
+			require.NoError(t, res.Body.Close())
+			require.NotEmpty(t, expected)
+			require.Equal(t, expected, r.Items, r.UID)
+		}
+	}
+
+	search := func(t *testing.T, server *webtest.Server, url string) []dtos.PlaylistSearchResult {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(url), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var results []dtos.PlaylistSearchResult
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&results))
+		require.NoError(t, res.Body.Close())
+		return results
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &itemsPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake(), items: items}
+		playlistService.ExpectedPlaylists = playlist.Playlists{
+			{UID: "a", Name: "A", Interval: "5m", OrgId: 1, UpdatedAt: 1},
+			{UID: "b", Name: "B", Interval: "5m", OrgId: 1, UpdatedAt: 1},
+		}
+		playlistService.ExpectedPlaylist = &playlist.Playlist{OrgId: 1}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		for _, r := range search(t, server, "/api/playlists") {
+			require.Nil(t, r.Items)
+		}
+		compareItems(t, server, search(t, server, "/api/playlists?includeItems=true"))
+		// The items of the page are fetched at once
+		require.Equal(t, [][]string{{"a", "b"}}, playlistService.batches)
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		resource := func(name string) string {
+			itemsJSON, err := json.Marshal(items[name])
+			require.NoError(t, err)
+			return fmt.Sprintf(`{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "Playlist",
+				"metadata": {"name": %q, "namespace": "default", "resourceVersion": "1"},
+				"spec": {"title": %q, "interval": "5m", "items": %s}
+			}`, name, name, itemsJSON)
+		}
+		limits := []string{}
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			var err error
+			if name := path.Base(r.URL.Path); name != "playlists" {
+				_, err = fmt.Fprint(w, resource(name))
+			} else {
+				limits = append(limits, r.URL.Query().Get("limit"))
+				_, err = fmt.Fprintf(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1"}, "items": [%s, %s]}`,
+					resource("a"), resource("b"))
+			}
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		cfg := setting.NewCfg()
+		cfg.Playlist.SearchMaxLimit = 50
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Cfg = cfg
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		for _, r := range search(t, server, "/api/playlists") {
+			require.Nil(t, r.Items)
+		}
+		compareItems(t, server, search(t, server, "/api/playlists?includeItems=true"))
+		// The items are only included for a page of playlists
+		require.Equal(t, []string{"", "50"}, limits)
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsSort(t *testing.T) {
+	// Created in the order b, c, a, with a and b having the same name, so they are ordered by ID
+	expected := map[string][]string{
+		"":             {"b", "a", "c"},
+		"name-asc":     {"b", "a", "c"},
+		"name-desc":    {"c", "a", "b"},
+		"created-asc":  {"b", "c", "a"},
+		"created-desc": {"a", "c", "b"},
+	}
+	search := func(t *testing.T, server *webtest.Server, order string) []string {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?sort="+order), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode, order)
+		var playlists []playlist.Playlist
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+		require.NoError(t, res.Body.Close())
+		uids := []string{}
+		for _, p := range playlists {
+			uids = append(uids, p.UID)
+		}
+		return uids
+	}
+	requireInvalidSort := func(t *testing.T, server *webtest.Server) {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?sort=id"), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping integration test")
+		}
+		playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		for _, p := range []struct{ uid, name string }{{"b", "Same"}, {"c", "Zulu"}, {"a", "Same"}} {
+			_, err := playlistService.Create(context.Background(), &playlist.CreatePlaylistCommand{
+				OrgId: 1, UID: p.uid, Name: p.name, Interval: "5m",
+				Items: []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "status"}},
+			})
+			require.NoError(t, err)
+			// The creation times are in milliseconds
+			time.Sleep(2 * time.Millisecond)
+		}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		for order, uids := range expected {
+			require.Equal(t, uids, search(t, server, order), order)
+		}
+		requireInvalidSort(t, server)
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			item := func(name, title, created string, id int) string {
+				return fmt.Sprintf(`{
+					"apiVersion": "playlist.grafana.app/v0alpha1",
+					"kind": "Playlist",
+					"metadata": {"name": %q, "namespace": "default", "resourceVersion": "1", "creationTimestamp": %q,
+						"annotations": {"grafana.app/originName": "SQL", "grafana.app/originKey": "%d"}},
+					"spec": {"title": %q, "interval": "5m", "items": []}
+				}`, name, created, id, title)
+			}
+			_, err := fmt.Fprintf(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1"}, "items": [%s, %s, %s]}`,
+				item("a", "Same", "2024-01-03T00:00:00Z", 3), item("b", "Same", "2024-01-01T00:00:00Z", 1), item("c", "Zulu", "2024-01-02T00:00:00Z", 2))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		for order, uids := range expected {
+			require.Equal(t, uids, search(t, server, order), order)
+		}
+		requireInvalidSort(t, server)
+
+		// The pages are in the order of the apiserver, so they can't be sorted
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?perPage=3"), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var playlists []playlist.Playlist
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, []string{"a", "b", "c"}, []string{playlists[0].UID, playlists[1].UID, playlists[2].UID})
+		for _, order := range []string{"name-asc", "name-desc", "created-asc", "created-desc"} {
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?perPage=3&sort="+order), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusBadRequest, res.StatusCode, order)
+		}
+	})
+}
+
+func TestAPIEndpoint_SearchPlaylistsRegex(t *testing.T) {
+	names := map[string]string{"a": "team-a-prod", "b": "team-b-dev", "c": "my-team-c-prod"}
+	expected := map[string][]string{
+		"^team-.*-prod$": {"a"},
+		"team-.*-prod":   {"a", "c"},
+		"-dev$":          {"b"},
+		"^TEAM":          {},
+		"(?i)^TEAM":      {"a", "b"},
+	}
+	search := func(t *testing.T, server *webtest.Server, pattern string) []string {
+		t.Helper()
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?queryType=regex&query="+url.QueryEscape(pattern)), userWithPermissions(1, nil)))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode, pattern)
+		var playlists []playlist.Playlist
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&playlists))
+		require.NoError(t, res.Body.Close())
+		uids := []string{}
+		for _, p := range playlists {
+			uids = append(uids, p.UID)
+		}
+		sort.Strings(uids)
+		return uids
+	}
+	requireBadRequest := func(t *testing.T, server *webtest.Server) {
+		t.Helper()
+		for _, params := range []string{"queryType=regex&query=" + url.QueryEscape("team-(a"), "queryType=glob&query=team"} {
+			res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists?"+params), userWithPermissions(1, nil)))
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			require.Equal(t, http.StatusBadRequest, res.StatusCode, params)
+		}
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("skipping integration test")
+		}
+		playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), setting.NewCfg(), featuremgmt.WithFeatures())
+		require.NoError(t, err)
+		for uid, name := range names {
+			_, err := playlistService.Create(context.Background(), &playlist.CreatePlaylistCommand{
+				OrgId: 1, UID: uid, Name: name, Interval: "5m",
+				Items: []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "status"}},
+			})
+			require.NoError(t, err)
+		}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.playlistService = playlistService
+		})
+
+		for pattern, uids := range expected {
+			require.Equal(t, uids, search(t, server, pattern), pattern)
+		}
+		requireBadRequest(t, server)
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			items := []string{}
+			for uid, name := range names {
+				items = append(items, fmt.Sprintf(`{
+					"apiVersion": "playlist.grafana.app/v0alpha1",
+					"kind": "Playlist",
+					"metadata": {"name": %q, "namespace": "default", "resourceVersion": "1"},
+					"spec": {"title": %q, "interval": "5m", "items": []}
+				}`, uid, name))
+			}
+			_, err := fmt.Fprintf(w, `{"apiVersion": "playlist.grafana.app/v0alpha1", "kind": "PlaylistList", "metadata": {"resourceVersion": "1"}, "items": [%s]}`, strings.Join(items, ", "))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		for pattern, uids := range expected {
+			require.Equal(t, uids, search(t, server, pattern), pattern)
+		}
+		requireBadRequest(t, server)
+	})
+}
+
+// bulkDeletePlaylistService has playlists of several orgs, fails to delete some of them, and records the deletions.
+type bulkDeletePlaylistService struct {
+	*playlisttest.FakePlaylistService
+	orgs    map[string]int64
+	failing map[string]bool
+	deleted []string
+}
+
+func (s *bulkDeletePlaylistService) GetWithoutItems(_ context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.Playlist, error) {
+	orgID, ok := s.orgs[q.UID]
+	if !ok {
+		return nil, playlist.ErrPlaylistNotFound
+	}
+	return &playlist.Playlist{UID: q.UID, OrgId: orgID}, nil
+}
+
+func (s *bulkDeletePlaylistService) Delete(_ context.Context, cmd *playlist.DeletePlaylistCommand) error {
+	if s.failing[cmd.UID] {
+		return errors.New("database is locked")
+	}
+	s.deleted = append(s.deleted, cmd.UID)
+	return nil
+}
+
+func TestAPIEndpoint_BulkDeletePlaylists(t *testing.T) {
+	editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+	bulkDelete := func(t *testing.T, server *webtest.Server, signedInUser *user.SignedInUser, body string) (int, map[string]dtos.BulkDeletePlaylistResult) {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, "/api/playlists/bulk-delete", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, signedInUser))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		results := map[string]dtos.BulkDeletePlaylistResult{}
+		if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusMultiStatus {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&results))
+		}
+		return res.StatusCode, results
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &bulkDeletePlaylistService{
+			FakePlaylistService: playlisttest.NewPlaylistServiveFake(),
+			orgs:                map[string]int64{"a": 1, "b": 1, "locked": 1, "other-org": 2},
+			failing:             map[string]bool{"locked": true},
+		}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.log = log.New("test")
+			hs.playlistService = playlistService
+		})
+
+		t.Run("Should delete the valid playlists and report the other ones", func(t *testing.T) {
+			status, results := bulkDelete(t, server, editor, `{"uids": ["a", "missing", "other-org", "locked", "b", "a"]}`)
+			require.Equal(t, http.StatusMultiStatus, status)
+			require.Equal(t, map[string]dtos.BulkDeletePlaylistResult{
+				"a":         {Status: http.StatusOK},
+				"b":         {Status: http.StatusOK},
+				"missing":   {Status: http.StatusNotFound, Message: "Playlist not found"},
+				"other-org": {Status: http.StatusForbidden, Message: "You are not allowed to edit/view playlist"},
+				"locked":    {Status: http.StatusInternalServerError, Message: "Failed to delete playlist"},
+			}, results)
+			require.Equal(t, []string{"a", "b"}, playlistService.deleted)
+		})
+
+		t.Run("Should respond with 200 if all the playlists are deleted", func(t *testing.T) {
+			status, results := bulkDelete(t, server, editor, `{"uids": ["a"]}`)
+			require.Equal(t, http.StatusOK, status)
+			require.Equal(t, map[string]dtos.BulkDeletePlaylistResult{"a": {Status: http.StatusOK}}, results)
+		})
+
+		t.Run("Should require UIDs", func(t *testing.T) {
+			status, _ := bulkDelete(t, server, editor, `{"uids": []}`)
+			require.Equal(t, http.StatusBadRequest, status)
+		})
+
+		t.Run("Should require the editor role", func(t *testing.T) {
+			playlistService.deleted = nil
+			status, _ := bulkDelete(t, server, userWithPermissions(1, nil), `{"uids": ["a"]}`)
+			require.Equal(t, http.StatusForbidden, status)
+			require.Empty(t, playlistService.deleted)
+		})
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		deleted := []string{}
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodDelete, r.Method)
+			uid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			w.Header().Set("Content-Type", "application/json")
+			if uid != "a" {
+				w.WriteHeader(http.StatusNotFound)
+				_, err := fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "Status", "status": "Failure", "message": "playlists.playlist.grafana.app \"%s\" not found", "reason": "NotFound", "code": 404}`, uid)
+				require.NoError(t, err)
+				return
+			}
+			deleted = append(deleted, uid)
+			_, err := w.Write([]byte(`{"apiVersion": "v1", "kind": "Status", "status": "Success", "code": 200}`))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		status, results := bulkDelete(t, server, editor, `{"uids": ["a", "missing"]}`)
+		require.Equal(t, http.StatusMultiStatus, status)
+		require.Equal(t, map[string]dtos.BulkDeletePlaylistResult{
+			"a":       {Status: http.StatusOK},
+			"missing": {Status: http.StatusNotFound, Message: `playlists.playlist.grafana.app "missing" not found`},
+		}, results)
+		require.Equal(t, []string{"a"}, deleted)
+	})
+}
+
+func TestAPIEndpoint_PlaylistAccessControl(t *testing.T) {
+	playlistService := playlisttest.NewPlaylistServiveFake()
+	playlistService.ExpectedPlaylist = &playlist.Playlist{UID: "a", Name: "A", Interval: "5m", OrgId: 1}
+	playlistService.ExpectedPlaylistDTO = &playlist.PlaylistDTO{Uid: "a", Name: "A", Interval: "5m"}
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+
+	send := func(t *testing.T, method, url, body string, signedInUser *user.SignedInUser) *http.Response {
+		t.Helper()
+		req := server.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, signedInUser))
+		require.NoError(t, err)
+		return res
+	}
+	status := func(t *testing.T, method, url, body string, signedInUser *user.SignedInUser) int {
+		t.Helper()
+		res := send(t, method, url, body, signedInUser)
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode
+	}
+
+	// Unrelated permissions, so that RBAC is in effect for the user
+	otherPermissions := []accesscontrol.Permission{{Action: dashboards.ActionDashboardsRead, Scope: dashboards.ScopeDashboardsAll}}
+	routes := []struct {
+		method, url, body string
+		action, scope     string
+	}{
+		{http.MethodGet, "/api/playlists", "", playlist.ActionPlaylistsRead, "playlists:uid:b"},
+		{http.MethodGet, "/api/playlists/a", "", playlist.ActionPlaylistsRead, "playlists:uid:a"},
+		{http.MethodGet, "/api/playlists/a/items", "", playlist.ActionPlaylistsRead, "playlists:uid:a"},
+		{http.MethodPost, "/api/playlists", `{"name": "A", "interval": "5m"}`, playlist.ActionPlaylistsWrite, playlist.ScopePlaylistsAll},
+		{http.MethodPut, "/api/playlists/a", `{"name": "A", "interval": "5m"}`, playlist.ActionPlaylistsWrite, "playlists:uid:a"},
+		{http.MethodDelete, "/api/playlists/a", "", playlist.ActionPlaylistsDelete, "playlists:uid:a"},
+	}
+
+	t.Run("Routes are allowed with their action", func(t *testing.T) {
+		for _, r := range routes {
+			signedInUser := userWithPermissions(1, append([]accesscontrol.Permission{{Action: r.action, Scope: r.scope}}, otherPermissions...))
+			require.NotEqual(t, http.StatusForbidden, status(t, r.method, r.url, r.body, signedInUser), r.method+" "+r.url)
+		}
+	})
+
+	t.Run("Routes are denied without their action", func(t *testing.T) {
+		for _, r := range routes {
+			require.Equal(t, http.StatusForbidden, status(t, r.method, r.url, r.body, userWithPermissions(1, otherPermissions)), r.method+" "+r.url)
+		}
+	})
+
+	t.Run("Routes of a playlist are denied with the action on another playlist", func(t *testing.T) {
+		for _, r := range routes {
+			if !strings.HasPrefix(r.scope, playlist.ScopePlaylistsPrefix+"a") {
+				continue
+			}
+			signedInUser := userWithPermissions(1, []accesscontrol.Permission{{Action: r.action, Scope: "playlists:uid:b"}})
+			require.Equal(t, http.StatusForbidden, status(t, r.method, r.url, r.body, signedInUser), r.method+" "+r.url)
+		}
+	})
+
+	t.Run("Bulk deletes are authorized by playlist", func(t *testing.T) {
+		signedInUser := userWithPermissions(1, []accesscontrol.Permission{{Action: playlist.ActionPlaylistsDelete, Scope: "playlists:uid:a"}})
+		res := send(t, http.MethodPost, "/api/playlists/bulk-delete", `{"uids": ["a", "b"]}`, signedInUser)
+		var results map[string]dtos.BulkDeletePlaylistResult
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&results))
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusMultiStatus, res.StatusCode)
+		require.Equal(t, http.StatusOK, results["a"].Status)
+		require.Equal(t, http.StatusForbidden, results["b"].Status)
+	})
+
+	t.Run("Roles are checked for the users without permissions", func(t *testing.T) {
+		viewer := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleViewer}
+		editor := &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}
+		for _, r := range routes {
+			expected := http.StatusOK
+			if r.action != playlist.ActionPlaylistsRead {
+				expected = http.StatusForbidden
+			}
+			require.Equal(t, expected, status(t, r.method, r.url, r.body, viewer), r.method+" "+r.url)
+			require.NotEqual(t, http.StatusForbidden, status(t, r.method, r.url, r.body, editor), r.method+" "+r.url)
+		}
+	})
+}
+
+func TestAPIEndpoint_PlaylistAccessControlK8s(t *testing.T) {
+	var calls int
+	apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write([]byte(`{
+			"apiVersion": "playlist.grafana.app/v0alpha1",
+			"kind": "PlaylistList",
+			"metadata": {"resourceVersion": "1"},
+			"items": []
+		}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(apiserver.Close)
+
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+		hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+	})
+
+	// A viewer with unrelated permissions, so that RBAC is in effect for the user
+	viewer := userWithPermissions(1, []accesscontrol.Permission{{Action: dashboards.ActionDashboardsRead, Scope: dashboards.ScopeDashboardsAll}})
+	for _, url := range []string{"/api/playlists", "/api/playlists/count", "/api/playlists/a", "/api/playlists/a/items"} {
+		res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest(url), viewer))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, http.StatusForbidden, res.StatusCode, url)
+	}
+	require.Zero(t, calls, "the apiserver must not be called without the permission")
+
+	reader := userWithPermissions(1, []accesscontrol.Permission{{Action: playlist.ActionPlaylistsRead, Scope: playlist.ScopePlaylistsAll}})
+	res, err := server.Send(webtest.RequestWithSignedInUser(server.NewGetRequest("/api/playlists/count"), reader))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestAPIEndpoint_RestorePlaylist(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	cfg := setting.NewCfg()
+	cfg.Playlist.TrashRetention = 24 * time.Hour
+	playlistService, err := playlistimpl.ProvideService(db.InitTestDB(t), tracing.InitializeTracerForTest(), quotatest.New(false, nil), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	server := SetupAPITestServer(t, func(hs *HTTPServer) {
+		hs.playlistService = playlistService
+	})
+	p, err := playlistService.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "Wallboard", Interval: "5m", OrgId: 1, UID: "wallboard",
+		Items: []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "status"}}})
+	require.NoError(t, err)
+
+	send := func(t *testing.T, method, url string) (int, []byte) {
+		t.Helper()
+		req := server.NewRequest(method, url, nil)
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, &user.SignedInUser{OrgID: 1, OrgRole: org.RoleEditor}))
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res.StatusCode, body
+	}
+	search := func(t *testing.T, url string) []playlist.Playlist {
+		t.Helper()
+		status, body := send(t, http.MethodGet, url)
+		require.Equal(t, http.StatusOK, status)
+		var playlists []playlist.Playlist
+		require.NoError(t, json.Unmarshal(body, &playlists))
+		return playlists
+	}
+
+	status, _ := send(t, http.MethodDelete, "/api/playlists/wallboard")
+	require.Equal(t, http.StatusOK, status)
+
+	t.Run("Trashed playlists are not found", func(t *testing.T) {
+		status, _ := send(t, http.MethodGet, "/api/playlists/wallboard")
+		require.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("Trashed playlists are only searched with includeTrashed", func(t *testing.T) {
+		require.Empty(t, search(t, "/api/playlists"))
+		playlists := search(t, "/api/playlists?includeTrashed=true")
+		require.Len(t, playlists, 1)
+		require.Equal(t, p.UID, playlists[0].UID)
+		require.NotZero(t, playlists[0].DeletedAt)
+	})
+
+	t.Run("Trashed playlists are restored", func(t *testing.T) {
+		status, body := send(t, http.MethodPost, "/api/playlists/wallboard/restore")
+		require.Equal(t, http.StatusOK, status)
+		var dto playlist.PlaylistDTO
+		require.NoError(t, json.Unmarshal(body, &dto))
+		require.Equal(t, "wallboard", dto.Uid)
+		require.Len(t, dto.Items, 1)
+
+		status, _ = send(t, http.MethodGet, "/api/playlists/wallboard")
+		require.Equal(t, http.StatusOK, status)
+		playlists := search(t, "/api/playlists")
+		require.Len(t, playlists, 1)
+		require.Zero(t, playlists[0].DeletedAt)
+	})
+
+	t.Run("Playlists that aren't in the trash are not found", func(t *testing.T) {
+		for _, uid := range []string{"wallboard", "missing"} {
+			status, _ := send(t, http.MethodPost, "/api/playlists/"+uid+"/restore")
+			require.Equal(t, http.StatusNotFound, status, uid)
+		}
+	})
+}
+
+// batchPlaylistService returns the playlists of the map, and ErrPlaylistNotFound for the other ones.
+type batchPlaylistService struct {
+	*playlisttest.FakePlaylistService
+	playlists map[string]*playlist.PlaylistDTO
+}
+
+func (s *batchPlaylistService) Get(_ context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.PlaylistDTO, error) {
+	if dto, ok := s.playlists[q.UID]; ok && dto.OrgID == q.OrgId {
+		return dto, nil
+	}
+	return nil, playlist.ErrPlaylistNotFound
+}
+
+func TestAPIEndpoint_BatchGetPlaylists(t *testing.T) {
+	// The user can read all the playlists but the secret one
+	signedInUser := userWithPermissions(1, []accesscontrol.Permission{
+		{Action: playlist.ActionPlaylistsRead, Scope: "playlists:uid:a"},
+		{Action: playlist.ActionPlaylistsRead, Scope: "playlists:uid:b"},
+		{Action: playlist.ActionPlaylistsRead, Scope: "playlists:uid:missing"},
+		{Action: playlist.ActionPlaylistsRead, Scope: "playlists:uid:other-org"},
+	})
+	batchGet := func(t *testing.T, server *webtest.Server, body string) (int, dtos.PlaylistBatch) {
+		t.Helper()
+		req := server.NewRequest(http.MethodPost, "/api/playlists/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		res, err := server.Send(webtest.RequestWithSignedInUser(req, signedInUser))
+		require.NoError(t, err)
+		defer func() { require.NoError(t, res.Body.Close()) }()
+		var batch dtos.PlaylistBatch
+		if res.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&batch))
+		}
+		return res.StatusCode, batch
+	}
+
+	t.Run("Legacy API", func(t *testing.T) {
+		playlistService := &batchPlaylistService{
+			FakePlaylistService: playlisttest.NewPlaylistServiveFake(),
+			playlists: map[string]*playlist.PlaylistDTO{
+				"a":         {Uid: "a", Name: "A", Interval: "5m", OrgID: 1, Items: []playlist.PlaylistItemDTO{{Type: "dashboard_by_tag", Value: "status"}}},
+				"b":         {Uid: "b", Name: "B", Interval: "1m", OrgID: 1},
+				"secret":    {Uid: "secret", Name: "Secret", Interval: "5m", OrgID: 1},
+				"other-org": {Uid: "other-org", Name: "Other org", Interval: "5m", OrgID: 2},
+			},
+		}
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.log = log.New("test")
+			hs.playlistService = playlistService
+		})
+
+		t.Run("Should return the readable playlists and report the other ones", func(t *testing.T) {
+			status, batch := batchGet(t, server, `{"uids": ["b", "secret", "missing", "a", "other-org", "b"]}`)
+			require.Equal(t, http.StatusOK, status)
+			require.Len(t, batch.Playlists, 2)
+			require.Equal(t, "b", batch.Playlists[0].Uid)
+			require.Equal(t, "a", batch.Playlists[1].Uid)
+			require.Equal(t, []playlist.PlaylistItemDTO{{Type: "dashboard_by_tag", Value: "status"}}, batch.Playlists[1].Items)
+			require.Equal(t, map[string]dtos.SkippedPlaylist{
+				"secret":    {Status: http.StatusForbidden, Message: "Permission denied"},
+				"missing":   {Status: http.StatusNotFound, Message: "Playlist not found"},
+				"other-org": {Status: http.StatusNotFound, Message: "Playlist not found"},
+			}, batch.Skipped)
+		})
+
+		t.Run("Should require UIDs", func(t *testing.T) {
+			status, _ := batchGet(t, server, `{"uids": []}`)
+			require.Equal(t, http.StatusBadRequest, status)
+		})
+
+		t.Run("Should cap the number of UIDs", func(t *testing.T) {
+			cfg := setting.NewCfg()
+			cfg.Playlist.SearchMaxLimit = 2
+			server := SetupAPITestServer(t, func(hs *HTTPServer) {
+				hs.Cfg = cfg
+				hs.playlistService = playlistService
+			})
+			status, _ := batchGet(t, server, `{"uids": ["a", "b", "secret"]}`)
+			require.Equal(t, http.StatusBadRequest, status)
+		})
+	})
+
+	t.Run("Kubernetes API", func(t *testing.T) {
+		apiserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodGet, r.Method)
+			uid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			w.Header().Set("Content-Type", "application/json")
+			if uid != "a" && uid != "secret" {
+				w.WriteHeader(http.StatusNotFound)
+				_, err := fmt.Fprintf(w, `{"apiVersion": "v1", "kind": "Status", "status": "Failure", "message": "playlists.playlist.grafana.app \"%s\" not found", "reason": "NotFound", "code": 404}`, uid)
+				require.NoError(t, err)
+				return
+			}
+			_, err := fmt.Fprintf(w, `{
+				"apiVersion": "playlist.grafana.app/v0alpha1",
+				"kind": "Playlist",
+				"metadata": {"name": %q, "namespace": "default", "resourceVersion": "7"},
+				"spec": {"title": "A", "interval": "5m", "items": [{"type": "dashboard_by_tag", "value": "status"}]}
+			}`, uid)
+			require.NoError(t, err)
+		}))
+		t.Cleanup(apiserver.Close)
+
+		server := SetupAPITestServer(t, func(hs *HTTPServer) {
+			hs.Features = featuremgmt.WithFeatures(featuremgmt.FlagKubernetesPlaylistsAPI)
+			hs.promRegister = prometheus.NewRegistry()
+			hs.clientConfigProvider = fakeRestConfigProvider{config: &clientrest.Config{Host: apiserver.URL}}
+		})
+
+		status, batch := batchGet(t, server, `{"uids": ["a", "secret", "missing"]}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, batch.Playlists, 1)
+		require.Equal(t, "a", batch.Playlists[0].Uid)
+		require.Len(t, batch.Playlists[0].Items, 1)
+		require.Equal(t, map[string]dtos.SkippedPlaylist{
+			"secret":  {Status: http.StatusForbidden, Message: "Permission denied"},
+			"missing": {Status: http.StatusNotFound, Message: `playlists.playlist.grafana.app "missing" not found`},
+		}, batch.Skipped)
+	})
+}
@@ -15,24 +15,55 @@ import (
 	"github.com/grafana/grafana/pkg/services/playlist"
 )
 
+const (
+	// TrashedLabel is the label of the playlists in the trash, set to "true".
+	TrashedLabel = "grafana.app/trashed"
+	// IncludeTrashedField is the field of the list field selector that lists the playlists in the trash too,
+	// with includeTrashed=true. They're not listed otherwise.
+	IncludeTrashedField = "includeTrashed"
+	// annoKeyTrashedTimestamp is the annotation with the time the playlist was moved to the trash, in RFC3339.
+	annoKeyTrashedTimestamp = "grafana.app/trashedTimestamp"
+)
+
 func UnstructuredToLegacyPlaylist(item unstructured.Unstructured) *playlist.Playlist {
 	spec := item.Object["spec"].(map[string]any)
-	return &playlist.Playlist{
-		UID:      item.GetName(),
-		Name:     spec["title"].(string),
-		Interval: spec["interval"].(string),
-		Id:       getLegacyID(&item),
+	p := &playlist.Playlist{
+		UID:       item.GetName(),
+		Name:      spec["title"].(string),
+		Interval:  spec["interval"].(string),
+		Id:        getLegacyID(&item),
+		CreatedAt: item.GetCreationTimestamp().UnixMilli(),
+	}
+	meta := kinds.GrafanaResourceMetadata{Annotations: item.GetAnnotations()}
+	if updated := meta.GetUpdatedTimestamp(); updated != nil {
+		p.UpdatedAt = updated.UnixMilli()
 	}
+	p.DeletedAt = getTrashedTimestamp(&item)
+	return p
 }
 
 func UnstructuredToLegacyPlaylistDTO(item unstructured.Unstructured) *playlist.PlaylistDTO {
 	spec := item.Object["spec"].(map[string]any)
 	dto := &playlist.PlaylistDTO{
-		Uid:      item.GetName(),
-		Name:     spec["title"].(string),
-		Interval: spec["interval"].(string),
-		Id:       getLegacyID(&item),
+		Uid:       item.GetName(),
+		Name:      spec["title"].(string),
+		Interval:  spec["interval"].(string),
+		Id:        getLegacyID(&item),
+		CreatedAt: item.GetCreationTimestamp().UnixMilli(),
 	}
+	meta := kinds.GrafanaResourceMetadata{Annotations: item.GetAnnotations()}
+	if created := item.GetCreationTimestamp(); !created.IsZero() {
+		t := created.UTC()
+		dto.Created = &t
+	}
+	if updated := meta.GetUpdatedTimestamp(); updated != nil {
+		dto.UpdatedAt = updated.UnixMilli()
+		t := updated.UTC()
+		dto.Updated = &t
+	}
+	dto.CreatedBy = meta.GetCreatedBy()
+	dto.UpdatedBy = meta.GetUpdatedBy()
+	dto.DeletedAt = getTrashedTimestamp(&item)
 	items := spec["items"]
 	if items != nil {
 		b, err := json.Marshal(items)
@@ -50,8 +81,9 @@ func convertToK8sResource(v *playlist.PlaylistDTO, namespacer request.NamespaceM
 	}
 	for _, item := range v.Items {
 		spec.Items = append(spec.Items, Item{
-			Type:  ItemType(item.Type),
-			Value: item.Value,
+			Type:     ItemType(item.Type),
+			Value:    item.Value,
+			Interval: item.Interval,
 		})
 	}
 
@@ -63,6 +95,14 @@ func convertToK8sResource(v *playlist.PlaylistDTO, namespacer request.NamespaceM
 			Key:  fmt.Sprintf("%d", v.Id),
 		})
 	}
+	var labels map[string]string
+	if v.DeletedAt > 0 {
+		labels = map[string]string{TrashedLabel: "true"}
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[annoKeyTrashedTimestamp] = time.UnixMilli(v.DeletedAt).UTC().Format(time.RFC3339)
+	}
 	return &Playlist{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:              v.Uid,
@@ -70,6 +110,7 @@ func convertToK8sResource(v *playlist.PlaylistDTO, namespacer request.NamespaceM
 			ResourceVersion:   fmt.Sprintf("%d", v.UpdatedAt),
 			CreationTimestamp: metav1.NewTime(time.UnixMilli(v.CreatedAt)),
 			Namespace:         namespacer(v.OrgID),
+			Labels:            labels,
 			Annotations:       meta.Annotations,
 		},
 		Spec: spec,
@@ -90,3 +131,15 @@ func getLegacyID(item *unstructured.Unstructured) int64 {
 	}
 	return 0
 }
+
+// getTrashedTimestamp returns the time the playlist was moved to the trash, in milliseconds, or zero if it's not in the trash.
+func getTrashedTimestamp(item *unstructured.Unstructured) int64 {
+	if item.GetLabels()[TrashedLabel] != "true" {
+		return 0
+	}
+	t, err := time.Parse(time.RFC3339, item.GetAnnotations()[annoKeyTrashedTimestamp])
+	if err != nil {
+		return 0
+	}
+	return t.UnixMilli()
+}
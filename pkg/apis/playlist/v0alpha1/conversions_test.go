@@ -3,9 +3,13 @@ package v0alpha1
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 
+	"github.com/grafana/grafana/pkg/kinds"
 	"github.com/grafana/grafana/pkg/services/grafana-apiserver/endpoints/request"
 	"github.com/grafana/grafana/pkg/services/playlist"
 )
@@ -21,7 +25,7 @@ func TestPlaylistConversion(t *testing.T) {
 		UpdatedAt: 54321,
 		Items: []playlist.PlaylistItemDTO{
 			{Type: "dashboard_by_uid", Value: "UID0"},
-			{Type: "dashboard_by_tag", Value: "tagA"},
+			{Type: "dashboard_by_tag", Value: "tagA", Interval: "1m"},
 			{Type: "dashboard_by_id", Value: "123"}, // deprecated
 		},
 	}
@@ -57,7 +61,8 @@ func TestPlaylistConversion(t *testing.T) {
 			},
 			{
 			  "type": "dashboard_by_tag",
-			  "value": "tagA"
+			  "value": "tagA",
+			  "interval": "1m"
 			},
 			{
 			  "type": "dashboard_by_id",
@@ -67,3 +72,103 @@ func TestPlaylistConversion(t *testing.T) {
 		}
 	  }`, string(out))
 }
+
+func TestUnstructuredToLegacyPlaylistDTO(t *testing.T) {
+	src := &playlist.PlaylistDTO{
+		Id:        123,
+		OrgID:     3,
+		Uid:       "abc",
+		Name:      "MyPlaylists",
+		Interval:  "10s",
+		CreatedAt: 12000,
+		UpdatedAt: 54000,
+		Items: []playlist.PlaylistItemDTO{
+			{Type: "dashboard_by_uid", Value: "UID0"},
+		},
+	}
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(convertToK8sResource(src, request.GetNamespaceMapper(nil)))
+	require.NoError(t, err)
+
+	dst := UnstructuredToLegacyPlaylistDTO(unstructured.Unstructured{Object: obj})
+	require.Equal(t, src.Uid, dst.Uid)
+	require.Equal(t, src.Id, dst.Id)
+	require.Equal(t, src.CreatedAt, dst.CreatedAt)
+	require.Equal(t, src.UpdatedAt, dst.UpdatedAt)
+	require.Equal(t, src.Items, dst.Items)
+
+	p := UnstructuredToLegacyPlaylist(unstructured.Unstructured{Object: obj})
+	require.Equal(t, src.CreatedAt, p.CreatedAt)
+	require.Equal(t, src.UpdatedAt, p.UpdatedAt)
+}
+
+func TestTrashedPlaylistConversion(t *testing.T) {
+	src := &playlist.PlaylistDTO{
+		OrgID:     3,
+		Uid:       "abc",
+		Name:      "MyPlaylists",
+		Interval:  "10s",
+		CreatedAt: 12000,
+		UpdatedAt: 54000,
+		DeletedAt: 54000,
+	}
+	dst := convertToK8sResource(src, request.GetNamespaceMapper(nil))
+	require.Equal(t, map[string]string{TrashedLabel: "true"}, dst.Labels)
+	require.Equal(t, "1970-01-01T00:00:54Z", dst.Annotations[annoKeyTrashedTimestamp])
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dst)
+	require.NoError(t, err)
+	require.Equal(t, src.DeletedAt, UnstructuredToLegacyPlaylistDTO(unstructured.Unstructured{Object: obj}).DeletedAt)
+	require.Equal(t, src.DeletedAt, UnstructuredToLegacyPlaylist(unstructured.Unstructured{Object: obj}).DeletedAt)
+
+	// The playlists that aren't in the trash have no label
+	src.DeletedAt = 0
+	dst = convertToK8sResource(src, request.GetNamespaceMapper(nil))
+	require.Empty(t, dst.Labels)
+	require.NotContains(t, dst.Annotations, annoKeyTrashedTimestamp)
+}
+
+func TestPlaylistAuthorshipConversion(t *testing.T) {
+	src := &playlist.PlaylistDTO{
+		OrgID:     3,
+		Uid:       "abc",
+		Name:      "MyPlaylists",
+		Interval:  "10s",
+		CreatedAt: 12000,
+		UpdatedAt: 54000,
+	}
+	k8s := convertToK8sResource(src, request.GetNamespaceMapper(nil))
+	meta := kinds.GrafanaResourceMetadata{Annotations: k8s.Annotations}
+	meta.SetCreatedBy("user:1")
+	meta.SetUpdatedBy("user:2")
+	k8s.Annotations = meta.Annotations
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(k8s)
+	require.NoError(t, err)
+
+	dst := UnstructuredToLegacyPlaylistDTO(unstructured.Unstructured{Object: obj})
+	require.Equal(t, time.UnixMilli(12000).UTC(), *dst.Created)
+	require.Equal(t, time.UnixMilli(54000).UTC(), *dst.Updated)
+	require.Equal(t, "user:1", dst.CreatedBy)
+	require.Equal(t, "user:2", dst.UpdatedBy)
+
+	out, err := json.Marshal(dst)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"uid": "abc",
+		"name": "MyPlaylists",
+		"interval": "10s",
+		"created": "1970-01-01T00:00:12Z",
+		"updated": "1970-01-01T00:00:54Z",
+		"createdBy": "user:1",
+		"updatedBy": "user:2"
+	}`, string(out))
+
+	// The objects without timestamps nor authors are converted without them
+	dst = UnstructuredToLegacyPlaylistDTO(unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "abc"},
+		"spec":     map[string]any{"title": "MyPlaylists", "interval": "10s"},
+	}})
+	require.Nil(t, dst.Created)
+	require.Nil(t, dst.Updated)
+	require.Empty(t, dst.CreatedBy)
+	require.Empty(t, dst.UpdatedBy)
+}
@@ -3,10 +3,14 @@ package v0alpha1
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apiserver/pkg/registry/rest"
@@ -66,9 +70,22 @@ func (s *legacyStorage) List(ctx context.Context, options *internalversion.ListO
 	if options.Limit > 0 {
 		limit = int(options.Limit)
 	}
+	// The continue token is the number of the next page
+	page := 1
+	if options.Continue != "" {
+		page, err = strconv.Atoi(options.Continue)
+		if err != nil || page < 1 {
+			return nil, k8serrors.NewBadRequest(fmt.Sprintf("invalid continue token %q", options.Continue))
+		}
+	}
+	// The playlists in the trash are only listed with the IncludeTrashedField field selector. The label selector
+	// can then tell them apart, e.g. grafana.app/trashed=true to only list the trash.
+	includeTrashed := includesTrashed(options.FieldSelector)
 	res, err := s.service.Search(ctx, &playlist.GetPlaylistsQuery{
-		OrgId: info.OrgID,
-		Limit: limit,
+		OrgId:          info.OrgID,
+		Limit:          limit,
+		Page:           page,
+		IncludeTrashed: includeTrashed,
 	})
 	if err != nil {
 		return nil, err
@@ -77,17 +94,30 @@ func (s *legacyStorage) List(ctx context.Context, options *internalversion.ListO
 	list := &PlaylistList{}
 	for _, v := range res {
 		p, err := s.service.Get(ctx, &playlist.GetPlaylistByUidQuery{
-			UID:   v.UID,
-			OrgId: info.OrgID,
+			UID:            v.UID,
+			OrgId:          info.OrgID,
+			IncludeTrashed: includeTrashed,
 		})
 		if err != nil {
 			return nil, err
 		}
-		list.Items = append(list.Items, *convertToK8sResource(p, s.namespacer))
+		item := convertToK8sResource(p, s.namespacer)
+		if options.LabelSelector != nil && !options.LabelSelector.Matches(labels.Set(item.Labels)) {
+			continue
+		}
+		list.Items = append(list.Items, *item)
+	}
+	// The page is full before the label selector filters it
+	if len(res) == limit {
+		list.Continue = strconv.Itoa(page + 1)
 	}
-	if len(list.Items) == limit {
-		list.Continue = "<more>" // TODO?
+	// The version of the list is the last change to the playlists of the org, deletions included, so that it
+	// changes with any of them, like the versions of the lists of the other storages
+	lastUpdated, err := s.service.GetLastUpdated(ctx, &playlist.GetLastUpdatedQuery{OrgId: info.OrgID})
+	if err != nil {
+		return nil, err
 	}
+	list.ResourceVersion = strconv.FormatInt(lastUpdated, 10)
 	return list, nil
 }
 
@@ -110,3 +140,12 @@ func (s *legacyStorage) Get(ctx context.Context, name string, options *metav1.Ge
 
 	return convertToK8sResource(dto, s.namespacer), nil
 }
+
+// includesTrashed returns whether the field selector requires IncludeTrashedField to be true.
+func includesTrashed(selector fields.Selector) bool {
+	if selector == nil {
+		return false
+	}
+	value, ok := selector.RequiresExactMatch(IncludeTrashedField)
+	return ok && value == "true"
+}
@@ -0,0 +1,95 @@
+package v0alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	genericrequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/grafana/grafana/pkg/services/grafana-apiserver/endpoints/request"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/playlist/playlisttest"
+)
+
+// trashPlaylistService serves a playlist and a playlist in the trash, which is only searched with IncludeTrashed.
+type trashPlaylistService struct {
+	*playlisttest.FakePlaylistService
+}
+
+func (s *trashPlaylistService) Search(_ context.Context, q *playlist.GetPlaylistsQuery) (playlist.Playlists, error) {
+	res := playlist.Playlists{{UID: "a", Name: "A", OrgId: q.OrgId}}
+	if q.IncludeTrashed {
+		res = append(res, &playlist.Playlist{UID: "trashed", Name: "Trashed", OrgId: q.OrgId, DeletedAt: 1})
+	}
+	return res, nil
+}
+
+func (s *trashPlaylistService) Get(_ context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.PlaylistDTO, error) {
+	if q.UID == "trashed" {
+		if !q.IncludeTrashed {
+			return nil, playlist.ErrPlaylistNotFound
+		}
+		return &playlist.PlaylistDTO{Uid: q.UID, Name: "Trashed", OrgID: q.OrgId, DeletedAt: 1}, nil
+	}
+	return &playlist.PlaylistDTO{Uid: q.UID, Name: "A", OrgID: q.OrgId}, nil
+}
+
+func TestLegacyStorageListTrashed(t *testing.T) {
+	storage := &legacyStorage{
+		service:    &trashPlaylistService{FakePlaylistService: playlisttest.NewPlaylistServiveFake()},
+		namespacer: request.GetNamespaceMapper(nil),
+	}
+	ctx := genericrequest.WithNamespace(context.Background(), "default")
+	list := func(t *testing.T, fieldSelector, labelSelector string) []string {
+		t.Helper()
+		options := &internalversion.ListOptions{
+			FieldSelector: fields.ParseSelectorOrDie(fieldSelector),
+			LabelSelector: labels.Everything(),
+		}
+		if labelSelector != "" {
+			selector, err := labels.Parse(labelSelector)
+			require.NoError(t, err)
+			options.LabelSelector = selector
+		}
+		obj, err := storage.List(ctx, options)
+		require.NoError(t, err)
+		names := []string{}
+		for _, item := range obj.(*PlaylistList).Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	t.Run("Trashed playlists are not listed by default", func(t *testing.T) {
+		require.Equal(t, []string{"a"}, list(t, "", ""))
+		// The label selector alone doesn't list them
+		require.Empty(t, list(t, "", TrashedLabel+"=true"))
+	})
+
+	t.Run("Trashed playlists are listed with the field selector", func(t *testing.T) {
+		require.Equal(t, []string{"a", "trashed"}, list(t, IncludeTrashedField+"=true", ""))
+		require.Equal(t, []string{"trashed"}, list(t, IncludeTrashedField+"=true", TrashedLabel+"=true"))
+		require.Equal(t, []string{"a"}, list(t, IncludeTrashedField+"=false", ""))
+	})
+}
+
+func TestInstallSchemaFieldSelectors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	gv := schema.GroupVersion{Group: GroupName, Version: VersionID}
+	builder := &PlaylistAPIBuilder{gv: gv}
+	require.NoError(t, builder.InstallSchema(scheme))
+	gvk := gv.WithKind("Playlist")
+
+	for _, label := range []string{"metadata.name", "metadata.namespace", IncludeTrashedField} {
+		_, _, err := scheme.ConvertFieldLabel(gvk, label, "true")
+		require.NoError(t, err, label)
+	}
+	_, _, err := scheme.ConvertFieldLabel(gvk, "spec.title", "A")
+	require.Error(t, err)
+}
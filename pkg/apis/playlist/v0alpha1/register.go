@@ -13,6 +13,7 @@ import (
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	common "k8s.io/kube-openapi/pkg/common"
 
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	grafanaapiserver "github.com/grafana/grafana/pkg/services/grafana-apiserver"
 	"github.com/grafana/grafana/pkg/services/grafana-apiserver/endpoints/request"
 	grafanarest "github.com/grafana/grafana/pkg/services/grafana-apiserver/rest"
@@ -32,16 +33,21 @@ type PlaylistAPIBuilder struct {
 	service    playlist.Service
 	namespacer request.NamespaceMapper
 	gv         schema.GroupVersion
+	validator  *playlist.ItemValidator
+	features   featuremgmt.FeatureToggles
 }
 
 func RegisterAPIService(p playlist.Service,
 	apiregistration grafanaapiserver.APIRegistrar,
 	cfg *setting.Cfg,
+	features featuremgmt.FeatureToggles,
 ) *PlaylistAPIBuilder {
 	builder := &PlaylistAPIBuilder{
 		service:    p,
 		namespacer: request.GetNamespaceMapper(cfg),
 		gv:         schema.GroupVersion{Group: GroupName, Version: VersionID},
+		validator:  playlist.NewItemValidator(cfg),
+		features:   features,
 	}
 	apiregistration.RegisterAPI(builder)
 	return builder
@@ -57,6 +63,17 @@ func (b *PlaylistAPIBuilder) InstallSchema(scheme *runtime.Scheme) error {
 		&PlaylistList{},
 	)
 	metav1.AddToGroupVersion(scheme, b.gv)
+	// The playlists can be selected by name, and the ones in the trash are listed with the IncludeTrashedField
+	err := scheme.AddFieldLabelConversionFunc(b.gv.WithKind("Playlist"), func(label, value string) (string, string, error) {
+		switch label {
+		case "metadata.name", "metadata.namespace", IncludeTrashedField:
+			return label, value, nil
+		}
+		return "", "", fmt.Errorf("field label not supported: %s", label)
+	})
+	if err != nil {
+		return err
+	}
 	return scheme.SetVersionPriority(b.gv)
 }
 
@@ -99,7 +116,7 @@ func (b *PlaylistAPIBuilder) GetAPIGroupInfo(
 
 	// enable dual writes if a RESTOptionsGetter is provided
 	if optsGetter != nil {
-		store, err := newStorage(scheme, optsGetter, legacyStore)
+		store, err := newStorage(scheme, optsGetter, legacyStore, b.validator, b.features)
 		if err != nil {
 			return nil, err
 		}
@@ -1,22 +1,36 @@
 package v0alpha1
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/registry/generic"
 	genericregistry "k8s.io/apiserver/pkg/registry/generic/registry"
+	"k8s.io/apiserver/pkg/registry/rest"
 
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	grafanaregistry "github.com/grafana/grafana/pkg/services/grafana-apiserver/registry/generic"
 	grafanarest "github.com/grafana/grafana/pkg/services/grafana-apiserver/rest"
+	"github.com/grafana/grafana/pkg/services/playlist"
 )
 
 var _ grafanarest.Storage = (*storage)(nil)
 
 type storage struct {
 	*genericregistry.Store
+	features featuremgmt.FeatureToggles
 }
 
-func newStorage(scheme *runtime.Scheme, optsGetter generic.RESTOptionsGetter, legacy *legacyStorage) (*storage, error) {
+func newStorage(scheme *runtime.Scheme, optsGetter generic.RESTOptionsGetter, legacy *legacyStorage, validator *playlist.ItemValidator, features featuremgmt.FeatureToggles) (*storage, error) {
 	strategy := grafanaregistry.NewStrategy(scheme)
+	// Playlists are checked like the ones saved with the legacy API
+	playlistStrategy := &strategyWithValidation{genericStrategy: strategy, validator: validator}
 
 	store := &genericregistry.Store{
 		NewFunc:                   func() runtime.Object { return &Playlist{} },
@@ -26,13 +40,159 @@ func newStorage(scheme *runtime.Scheme, optsGetter generic.RESTOptionsGetter, le
 		SingularQualifiedResource: legacy.SingularQualifiedResource,
 		TableConvertor:            legacy.tableConverter,
 
-		CreateStrategy: strategy,
-		UpdateStrategy: strategy,
+		CreateStrategy: playlistStrategy,
+		UpdateStrategy: playlistStrategy,
 		DeleteStrategy: strategy,
 	}
 	options := &generic.StoreOptions{RESTOptions: optsGetter, AttrFunc: grafanaregistry.GetAttrs}
 	if err := store.CompleteWithOptions(options); err != nil {
 		return nil, err
 	}
-	return &storage{Store: store}, nil
+	// The playlists referenced by the validated ones are read in the namespace of the request
+	playlistStrategy.getItems = func(ctx context.Context, uid string) ([]playlist.PlaylistItem, error) {
+		obj, err := store.Get(ctx, uid, &metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil, playlist.ErrPlaylistNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		p, ok := obj.(*Playlist)
+		if !ok {
+			return nil, fmt.Errorf("expected playlist")
+		}
+		return legacyItems(p.Spec.Items), nil
+	}
+	return &storage{Store: store, features: features}, nil
+}
+
+// checkWritable returns a service unavailable error if the playlists are in maintenance mode, like the legacy API.
+func (s *storage) checkWritable() error {
+	if s.features.IsEnabled(featuremgmt.FlagPlaylistMaintenanceMode) {
+		return apierrors.NewServiceUnavailable(playlist.ErrMaintenanceMode.Error())
+	}
+	return nil
+}
+
+// List lists the playlists without the IncludeTrashedField requirement of the field selector, which only the legacy
+// storage can select on, since this one keeps the playlists in the trash with their label.
+func (s *storage) List(ctx context.Context, options *metainternalversion.ListOptions) (runtime.Object, error) {
+	if options != nil && options.FieldSelector != nil {
+		selector, err := options.FieldSelector.Transform(func(field, value string) (string, string, error) {
+			if field == IncludeTrashedField {
+				return "", "", nil
+			}
+			return field, value, nil
+		})
+		if err != nil {
+			return nil, apierrors.NewBadRequest(err.Error())
+		}
+		options = options.DeepCopy()
+		options.FieldSelector = selector
+	}
+	return s.Store.List(ctx, options)
+}
+
+func (s *storage) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	return s.Store.Create(ctx, obj, createValidation, options)
+}
+
+func (s *storage) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, false, err
+	}
+	return s.Store.Update(ctx, name, objInfo, createValidation, updateValidation, forceAllowCreate, options)
+}
+
+func (s *storage) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, false, err
+	}
+	return s.Store.Delete(ctx, name, deleteValidation, options)
+}
+
+func (s *storage) DeleteCollection(ctx context.Context, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions, listOptions *metainternalversion.ListOptions) (runtime.Object, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	return s.Store.DeleteCollection(ctx, deleteValidation, options, listOptions)
+}
+
+type genericStrategy interface {
+	rest.RESTCreateStrategy
+	rest.RESTUpdateStrategy
+}
+
+// strategyWithValidation defaults the interval of the playlists saved without one, and validates it and the items
+// with the same rules as the legacy API. The names of the created playlists are their UIDs, so they're validated as such.
+type strategyWithValidation struct {
+	genericStrategy
+	validator *playlist.ItemValidator
+	// getItems returns the items of the playlists referenced by the validated ones
+	getItems playlist.ItemsGetter
+}
+
+func (s *strategyWithValidation) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	s.genericStrategy.PrepareForCreate(ctx, obj)
+	defaultInterval(obj)
+}
+
+func (s *strategyWithValidation) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	s.genericStrategy.PrepareForUpdate(ctx, obj, old)
+	defaultInterval(obj)
+}
+
+func (s *strategyWithValidation) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	errs := append(s.genericStrategy.Validate(ctx, obj), s.validatePlaylist(ctx, obj)...)
+	if p, ok := obj.(*Playlist); ok {
+		if err := playlist.ValidateUID(p.Name); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), p.Name, err.Error()))
+		}
+	}
+	return errs
+}
+
+func (s *strategyWithValidation) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	return append(s.genericStrategy.ValidateUpdate(ctx, obj, old), s.validatePlaylist(ctx, obj)...)
+}
+
+func defaultInterval(obj runtime.Object) {
+	if p, ok := obj.(*Playlist); ok && p.Spec.Interval == "" {
+		p.Spec.Interval = playlist.DefaultInterval
+	}
+}
+
+// validatePlaylist checks the interval and the items of the playlist, the external_url ones against the allowlist
+// of the [playlists] configuration section, and that the playlists it references don't reference it in turn.
+func (s *strategyWithValidation) validatePlaylist(ctx context.Context, obj runtime.Object) field.ErrorList {
+	p, ok := obj.(*Playlist)
+	if !ok {
+		return nil
+	}
+	if err := playlist.ValidateInterval(p.Spec.Interval); err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("spec", "interval"), p.Spec.Interval, err.Error())}
+	}
+	err := s.validator.Validate(ctx, p.Name, legacyItems(p.Spec.Items), s.getItems)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, playlist.ErrExternalURLNotAllowed), errors.Is(err, playlist.ErrInvalidItemInterval),
+		errors.Is(err, playlist.ErrInvalidRecentlyViewed), errors.Is(err, playlist.ErrInvalidSectionLabel),
+		errors.Is(err, playlist.ErrInvalidPlaylistRef):
+		return field.ErrorList{field.Invalid(field.NewPath("spec", "items"), p.Spec.Items, err.Error())}
+	default:
+		return field.ErrorList{field.InternalError(field.NewPath("spec", "items"), err)}
+	}
+}
+
+// legacyItems returns the items of a playlist as the ones of the legacy API.
+func legacyItems(items []Item) []playlist.PlaylistItem {
+	legacy := make([]playlist.PlaylistItem, 0, len(items))
+	for _, item := range items {
+		legacy = append(legacy, playlist.PlaylistItem{Type: string(item.Type), Value: item.Value, Interval: item.Interval})
+	}
+	return legacy
 }
@@ -0,0 +1,135 @@
+package v0alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	grafanaregistry "github.com/grafana/grafana/pkg/services/grafana-apiserver/registry/generic"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+func TestStrategyWithValidation(t *testing.T) {
+	cfg := setting.NewCfg()
+	cfg.AppURL = "https://grafana.example.com/"
+	cfg.Playlist.ExternalURLAllowedSchemes = []string{"https"}
+	cfg.Playlist.ExternalURLAllowedHosts = []string{"status.example.com", "grafana.example.com"}
+	// The other playlists of the namespace, by UID
+	stored := map[string][]playlist.PlaylistItem{}
+	strategy := &strategyWithValidation{
+		genericStrategy: grafanaregistry.NewStrategy(runtime.NewScheme()),
+		validator:       playlist.NewItemValidator(cfg),
+		getItems: func(_ context.Context, uid string) ([]playlist.PlaylistItem, error) {
+			if items, ok := stored[uid]; ok {
+				return items, nil
+			}
+			return nil, playlist.ErrPlaylistNotFound
+		},
+	}
+	newPlaylist := func(interval string) *Playlist {
+		return &Playlist{ObjectMeta: metav1.ObjectMeta{Name: "a-playlist"}, Spec: Spec{Title: "A title", Interval: interval}}
+	}
+
+	t.Run("Valid intervals are accepted", func(t *testing.T) {
+		for _, interval := range []string{"5m", "30s", "1h30m", "1d"} {
+			require.Empty(t, strategy.Validate(context.Background(), newPlaylist(interval)), interval)
+			require.Empty(t, strategy.ValidateUpdate(context.Background(), newPlaylist(interval), newPlaylist("5m")), interval)
+		}
+	})
+
+	t.Run("Invalid intervals are rejected", func(t *testing.T) {
+		for _, interval := range []string{"soon", "5", "-5m", "0s"} {
+			errs := strategy.Validate(context.Background(), newPlaylist(interval))
+			require.Len(t, errs, 1, interval)
+			require.Equal(t, "spec.interval", errs[0].Field)
+			require.Len(t, strategy.ValidateUpdate(context.Background(), newPlaylist(interval), newPlaylist("5m")), 1, interval)
+		}
+	})
+
+	t.Run("Names that aren't valid UIDs are rejected", func(t *testing.T) {
+		for _, name := range []string{"wallboard.v2", strings.Repeat("a", util.MaxUIDLength+1)} {
+			p := newPlaylist("5m")
+			p.Name = name
+			errs := strategy.Validate(context.Background(), p)
+			require.Len(t, errs, 1, name)
+			require.Equal(t, "metadata.name", errs[0].Field)
+		}
+	})
+
+	t.Run("Items are validated like with the legacy API", func(t *testing.T) {
+		valid := []Item{
+			{Type: ItemTypeSection, Value: "Prod"},
+			{Type: ItemTypeDashboardByUid, Value: "dash", Interval: "30s"},
+			{Type: ItemTypeExternalURL, Value: "https://status.example.com/page"},
+			{Type: ItemTypeRecentlyViewed, Value: "5"},
+		}
+		p := newPlaylist("5m")
+		p.Spec.Items = valid
+		require.Empty(t, strategy.Validate(context.Background(), p))
+		require.Empty(t, strategy.ValidateUpdate(context.Background(), p, newPlaylist("5m")))
+
+		for _, item := range []Item{
+			{Type: ItemTypeExternalURL, Value: "http://status.example.com/page"},
+			{Type: ItemTypeExternalURL, Value: "https://evil.example.com/page"},
+			{Type: ItemTypeExternalURL, Value: "javascript:alert(1)"},
+			{Type: ItemTypeSection, Value: " "},
+			{Type: ItemTypeDashboardByUid, Value: "dash", Interval: "-1s"},
+			{Type: ItemTypeRecentlyViewed, Value: "0"},
+		} {
+			p := newPlaylist("5m")
+			p.Spec.Items = append(append([]Item{}, valid...), item)
+			errs := strategy.Validate(context.Background(), p)
+			require.Len(t, errs, 1, item.Value)
+			require.Equal(t, "spec.items", errs[0].Field)
+			require.Len(t, strategy.ValidateUpdate(context.Background(), p, newPlaylist("5m")), 1, item.Value)
+		}
+	})
+
+	t.Run("Playlists referencing themselves in a cycle are rejected", func(t *testing.T) {
+		stored["other"] = []playlist.PlaylistItem{{Type: playlist.ItemTypeExternalURL, Value: "https://grafana.example.com/playlists/play/a-playlist"}}
+		t.Cleanup(func() { delete(stored, "other") })
+
+		p := newPlaylist("5m")
+		p.Spec.Items = []Item{{Type: ItemTypeExternalURL, Value: "https://grafana.example.com/playlists/play/other"}}
+		errs := strategy.ValidateUpdate(context.Background(), p, newPlaylist("5m"))
+		require.Len(t, errs, 1)
+		require.Equal(t, "spec.items", errs[0].Field)
+		require.Contains(t, errs[0].Detail, "a-playlist -> other -> a-playlist")
+
+		p.Spec.Items = []Item{{Type: ItemTypeExternalURL, Value: "https://grafana.example.com/playlists/play/missing"}}
+		require.Empty(t, strategy.Validate(context.Background(), p))
+	})
+
+	t.Run("Empty intervals are defaulted", func(t *testing.T) {
+		p := newPlaylist("")
+		strategy.PrepareForCreate(context.Background(), p)
+		require.Equal(t, "5m", p.Spec.Interval)
+
+		p = newPlaylist("")
+		strategy.PrepareForUpdate(context.Background(), p, newPlaylist("30s"))
+		require.Equal(t, "5m", p.Spec.Interval)
+	})
+}
+
+func TestStorageMaintenanceMode(t *testing.T) {
+	// The writes are rejected before reaching the underlying store
+	s := &storage{features: featuremgmt.WithFeatures(featuremgmt.FlagPlaylistMaintenanceMode)}
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, &Playlist{ObjectMeta: metav1.ObjectMeta{Name: "a-playlist"}}, nil, &metav1.CreateOptions{})
+	require.True(t, apierrors.IsServiceUnavailable(err), err)
+	_, _, err = s.Update(ctx, "a-playlist", nil, nil, nil, false, &metav1.UpdateOptions{})
+	require.True(t, apierrors.IsServiceUnavailable(err), err)
+	_, _, err = s.Delete(ctx, "a-playlist", nil, &metav1.DeleteOptions{})
+	require.True(t, apierrors.IsServiceUnavailable(err), err)
+	_, err = s.DeleteCollection(ctx, nil, &metav1.DeleteOptions{}, nil)
+	require.True(t, apierrors.IsServiceUnavailable(err), err)
+}
@@ -40,6 +40,9 @@ type Spec struct {
 const (
 	ItemTypeDashboardByTag ItemType = "dashboard_by_tag"
 	ItemTypeDashboardByUid ItemType = "dashboard_by_uid"
+	ItemTypeExternalURL    ItemType = "external_url"
+	ItemTypeRecentlyViewed ItemType = "recently_viewed"
+	ItemTypeSection        ItemType = "section"
 
 	// deprecated -- should use UID
 	ItemTypeDashboardById ItemType = "dashboard_by_id"
@@ -58,7 +61,16 @@ type Item struct {
 	//  - dashboard_by_tag: The value is a tag which is set on any number of dashboards. All
 	//  dashboards behind the tag will be added to the playlist.
 	//  - dashboard_by_uid: The value is the dashboard UID
+	//  - external_url: The value is the URL of a web page outside of Grafana. Its scheme and host
+	//  must be allowed in the [playlists] configuration section.
+	//  - recently_viewed: The value is the maximum number of dashboards to show, among the ones
+	//  the user playing the playlist viewed most recently. It resolves differently for each user.
+	//  - section: The value is the label of the section starting with this item, e.g. Prod.
+	//  It doesn't resolve to any dashboard.
 	Value string `json:"value"`
+
+	// Interval overrides the interval of the playlist for this item.
+	Interval string `json:"interval,omitempty"`
 }
 
 // Type of the item.
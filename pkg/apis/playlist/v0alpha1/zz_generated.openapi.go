@@ -40,12 +40,19 @@ func schema_pkg_apis_playlist_v0alpha1_Item(ref common.ReferenceCallback) common
 					},
 					"value": {
 						SchemaProps: spec.SchemaProps{
-							Description: "Value depends on type and describes the playlist item.\n\n - dashboard_by_id: The value is an internal numerical identifier set by Grafana. This\n is not portable as the numerical identifier is non-deterministic between different instances.\n Will be replaced by dashboard_by_uid in the future. (deprecated)\n - dashboard_by_tag: The value is a tag which is set on any number of dashboards. All\n dashboards behind the tag will be added to the playlist.\n - dashboard_by_uid: The value is the dashboard UID",
+							Description: "Value depends on type and describes the playlist item.\n\n - dashboard_by_id: The value is an internal numerical identifier set by Grafana. This\n is not portable as the numerical identifier is non-deterministic between different instances.\n Will be replaced by dashboard_by_uid in the future. (deprecated)\n - dashboard_by_tag: The value is a tag which is set on any number of dashboards. All\n dashboards behind the tag will be added to the playlist.\n - dashboard_by_uid: The value is the dashboard UID\n - external_url: The value is the URL of a web page outside of Grafana. Its scheme and host\n must be allowed in the [playlists] configuration section.\n - recently_viewed: The value is the maximum number of dashboards to show, among the ones\n the user playing the playlist viewed most recently. It resolves differently for each user.\n - section: The value is the label of the section starting with this item, e.g. Prod.\n It doesn't resolve to any dashboard.",
 							Default:     "",
 							Type:        []string{"string"},
 							Format:      "",
 						},
 					},
+					"interval": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Interval overrides the interval of the playlist for this item.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"type", "value"},
 			},
@@ -75,7 +75,7 @@ func (m *GrafanaResourceMetadata) GetUpdatedTimestamp() *time.Time {
 	v, ok := m.Annotations[annoKeyUpdatedTimestamp]
 	if ok {
 		t, err := time.Parse(time.RFC3339, v)
-		if err != nil {
+		if err == nil {
 			return &t
 		}
 	}
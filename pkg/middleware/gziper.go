@@ -4,16 +4,34 @@ import (
 	"bufio"
 	"compress/gzip"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
 	"github.com/grafana/grafana/pkg/web"
 )
 
 type gzipResponseWriter struct {
 	w *gzip.Writer
 	web.ResponseWriter
+	// uncompressed is the number of bytes written before compression
+	uncompressed *int64
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.Writer.Write(p)
+	cw.n += int64(n)
+	return n, err
 }
 
 func (grw *gzipResponseWriter) WriteHeader(c int) {
@@ -26,6 +44,7 @@ func (grw gzipResponseWriter) Write(p []byte) (int, error) {
 		grw.Header().Set("Content-Type", http.DetectContentType(p))
 	}
 	grw.Header().Del("Content-Length")
+	*grw.uncompressed += int64(len(p))
 	return grw.w.Write(p)
 }
 
@@ -52,7 +71,18 @@ var gzipIgnoredPaths = []matcher{
 	substr("/resources"),
 }
 
-func Gziper() func(http.Handler) http.Handler {
+// Gziper compresses the responses of the clients accepting gzip, except for the ignored paths.
+// The compression ratio of the responses of plugin requests is recorded by plugin, to judge the
+// CPU-vs-bandwidth trade-off of compressing them.
+func Gziper(promRegister prometheus.Registerer) func(http.Handler) http.Handler {
+	compressionRatio := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Name:      "plugin_response_compression_ratio",
+		Help:      "Histogram of the ratio of the uncompressed to the compressed size of the compressed plugin responses.",
+		Buckets:   []float64{1, 1.5, 2, 3, 5, 10, 20, 50},
+	}, []string{"plugin_id"})
+	promRegister.MustRegister(compressionRatio)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 			requestPath := req.URL.RequestURI()
@@ -69,13 +99,19 @@ func Gziper() func(http.Handler) http.Handler {
 				return
 			}
 
-			grw := &gzipResponseWriter{gzip.NewWriter(rw), rw.(web.ResponseWriter)}
+			ctx, called := pluginrequestmeta.WithCalledPlugin(req.Context())
+			compressed := &countingWriter{Writer: rw}
+			grw := &gzipResponseWriter{gzip.NewWriter(compressed), rw.(web.ResponseWriter), new(int64)}
 			grw.Header().Set("Content-Encoding", "gzip")
 			grw.Header().Set("Vary", "Accept-Encoding")
 
-			next.ServeHTTP(grw, req)
+			next.ServeHTTP(grw, req.WithContext(ctx))
 			// We can't really handle close errors at this point and we can't report them to the caller
 			_ = grw.w.Close()
+
+			if pluginID := called.ID(); pluginID != "" && *grw.uncompressed > 0 && compressed.n > 0 {
+				compressionRatio.WithLabelValues(pluginID).Observe(float64(*grw.uncompressed) / float64(compressed.n))
+			}
 		})
 	}
 }
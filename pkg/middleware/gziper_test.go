@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+func TestGziperPluginCompressionRatio(t *testing.T) {
+	body := strings.Repeat(`{"time": 1700000000000, "value": 1.5},`, 1000)
+	setup := func() (http.Handler, *prometheus.Registry) {
+		registry := prometheus.NewRegistry()
+		handler := Gziper(registry)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pluginrequestmeta.RecordCalledPlugin(r.Context(), "grafana-testdata-datasource")
+			_, err := w.Write([]byte(body))
+			require.NoError(t, err)
+		}))
+		return handler, registry
+	}
+	serve := func(handler http.Handler, path string, acceptEncoding string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		handler.ServeHTTP(web.NewResponseWriter(http.MethodPost, rec), req)
+		return rec
+	}
+
+	t.Run("Should record the ratio of a compressed plugin response", func(t *testing.T) {
+		handler, registry := setup()
+		rec := serve(handler, "/api/ds/query", "gzip, deflate")
+		require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+		metrics, err := registry.Gather()
+		require.NoError(t, err)
+		require.Len(t, metrics, 1)
+		require.Len(t, metrics[0].GetMetric(), 1)
+		metric := metrics[0].GetMetric()[0]
+		require.Equal(t, "grafana-testdata-datasource", metric.GetLabel()[0].GetValue())
+		require.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+		require.InDelta(t, float64(len(body))/float64(rec.Body.Len()), metric.GetHistogram().GetSampleSum(), 0.001)
+		require.Greater(t, metric.GetHistogram().GetSampleSum(), 10.0)
+	})
+
+	t.Run("Should not record the responses that aren't compressed", func(t *testing.T) {
+		handler, registry := setup()
+		rec := serve(handler, "/api/datasources/uid/abc/resources/series", "gzip")
+		require.Empty(t, rec.Header().Get("Content-Encoding"))
+		require.Equal(t, body, rec.Body.String())
+
+		rec = serve(handler, "/api/ds/query", "")
+		require.Empty(t, rec.Header().Get("Content-Encoding"))
+		require.Zero(t, testutil.CollectAndCount(registry))
+	})
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// PluginFanOut is a middleware handler that records the number of plugin calls made for each request,
+// to measure how many plugin calls a single request is amplified into. The requests that don't make any
+// plugin call are not recorded.
+func PluginFanOut(promRegister prometheus.Registerer) web.Middleware {
+	fanOut := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Name:      "plugin_request_fanout",
+		Help:      "Histogram of the number of plugin calls made for an HTTP request.",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 50, 100},
+	}, []string{"handler"})
+	promRegister.MustRegister(fanOut)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, calls := pluginrequestmeta.WithPluginCallCounter(r.Context())
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			count := calls.Load()
+			if count == 0 {
+				return
+			}
+			handler := "unknown"
+			if c := web.FromContext(ctx); c != nil {
+				if routeOperation, exists := RouteOperationName(c.Req); exists {
+					handler = routeOperation
+				}
+			}
+			fanOut.WithLabelValues(handler).Observe(float64(count))
+		})
+	}
+}
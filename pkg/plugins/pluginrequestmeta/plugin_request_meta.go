@@ -3,6 +3,8 @@ package pluginrequestmeta
 import (
 	"context"
 	"errors"
+	"sync"
+	"sync/atomic"
 )
 
 // StatusSource is an enum-like string value representing the source of a
@@ -16,29 +18,203 @@ const (
 
 type statusSourceCtxKey struct{}
 
+// statusSourceValue is the status source of a plugin request. It also keeps the first status source set by
+// SetStatusSource, so that the status source of the first attempt of a request that is retried, or that falls
+// back to another query, can be compared to the final one.
+type statusSourceValue struct {
+	mu      sync.Mutex
+	initial StatusSource
+	current StatusSource
+}
+
 // StatusSourceFromContext returns the plugin request status source stored in the context.
 // If no plugin request status source is stored in the context, [StatusSourcePlugin] is returned.
 func StatusSourceFromContext(ctx context.Context) StatusSource {
-	value, ok := ctx.Value(statusSourceCtxKey{}).(*StatusSource)
+	value, ok := ctx.Value(statusSourceCtxKey{}).(*statusSourceValue)
 	if ok {
-		return *value
+		value.mu.Lock()
+		defer value.mu.Unlock()
+		return value.current
 	}
 	return StatusSourcePlugin
 }
 
+// StatusSourceTransitionFromContext returns the first and the last status sources set by [SetStatusSource]
+// for the plugin request of the context. ok is false if none was set, e.g. if the request didn't return any
+// query data response.
+func StatusSourceTransitionFromContext(ctx context.Context) (initial StatusSource, final StatusSource, ok bool) {
+	value, ok := ctx.Value(statusSourceCtxKey{}).(*statusSourceValue)
+	if !ok {
+		return "", "", false
+	}
+	value.mu.Lock()
+	defer value.mu.Unlock()
+	if value.initial == "" {
+		return "", "", false
+	}
+	return value.initial, value.current, true
+}
+
 // WithStatusSource sets the plugin request status source for the context.
 func WithStatusSource(ctx context.Context, s StatusSource) context.Context {
-	return context.WithValue(ctx, statusSourceCtxKey{}, &s)
+	return context.WithValue(ctx, statusSourceCtxKey{}, &statusSourceValue{current: s})
+}
+
+// SetStatusSource mutates the provided context by setting the plugin request status source to s.
+// If the provided context does not have a plugin request status source, the context will not be mutated.
+// This means that [WithStatusSource] has to be called before this function.
+func SetStatusSource(ctx context.Context, s StatusSource) error {
+	v, ok := ctx.Value(statusSourceCtxKey{}).(*statusSourceValue)
+	if !ok {
+		return errors.New("the provided context does not have a plugin request status source")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.initial == "" {
+		v.initial = s
+	}
+	v.current = s
+	return nil
 }
 
 // WithDownstreamStatusSource mutates the provided context by setting the plugin request status source to
-// StatusSourceDownstream. If the provided context does not have a plugin request status source, the context
-// will not be mutated. This means that [WithStatusSource] has to be called before this function.
+// StatusSourceDownstream, like [SetStatusSource].
 func WithDownstreamStatusSource(ctx context.Context) error {
-	v, ok := ctx.Value(statusSourceCtxKey{}).(*StatusSource)
+	return SetStatusSource(ctx, StatusSourceDownstream)
+}
+
+type queuePositionCtxKey struct{}
+
+// WithQueuePosition returns a copy of the context with the position of the plugin request in the queue of the
+// plugin concurrency limit. The position is 0 if the request wasn't queued.
+func WithQueuePosition(ctx context.Context, position int64) context.Context {
+	v := &atomic.Int64{}
+	v.Store(position)
+	return context.WithValue(ctx, queuePositionCtxKey{}, v)
+}
+
+// SetQueuePosition mutates the provided context by setting the queue position of the plugin request, so that the
+// middlewares running before the concurrency limit can read it once the request is done.
+// Like [SetStatusSource], it returns an error if [WithQueuePosition] wasn't called before.
+func SetQueuePosition(ctx context.Context, position int64) error {
+	v, ok := ctx.Value(queuePositionCtxKey{}).(*atomic.Int64)
 	if !ok {
-		return errors.New("the provided context does not have a plugin request status source")
+		return errors.New("the provided context does not have a plugin request queue position")
 	}
-	*v = StatusSourceDownstream
+	v.Store(position)
 	return nil
 }
+
+// QueuePositionFromContext returns the position of the plugin request in the queue of the plugin concurrency
+// limit when it started waiting, with 1 being the first in line, or 0 if the request wasn't queued.
+func QueuePositionFromContext(ctx context.Context) int64 {
+	if v, ok := ctx.Value(queuePositionCtxKey{}).(*atomic.Int64); ok {
+		return v.Load()
+	}
+	return 0
+}
+
+type instrumentationOverridesCtxKey struct{}
+
+// WithInstrumentationOverrides returns a copy of the context with the given instrumentation feature toggles
+// enabled for the current plugin request only, regardless of their global value.
+func WithInstrumentationOverrides(ctx context.Context, flags ...string) context.Context {
+	return context.WithValue(ctx, instrumentationOverridesCtxKey{}, flags)
+}
+
+// InstrumentationOverridden returns true if the given instrumentation feature toggle has been enabled
+// for the current plugin request with [WithInstrumentationOverrides].
+func InstrumentationOverridden(ctx context.Context, flag string) bool {
+	flags, _ := ctx.Value(instrumentationOverridesCtxKey{}).([]string)
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestOrigin is an enum-like string value representing what a plugin request is made for.
+type RequestOrigin string
+
+const (
+	RequestOriginInteractive RequestOrigin = "interactive"
+	RequestOriginAlerting    RequestOrigin = "alerting"
+)
+
+type requestOriginCtxKey struct{}
+
+// RequestOriginFromContext returns the plugin request origin stored in the context.
+// If no plugin request origin is stored in the context, [RequestOriginInteractive] is returned.
+func RequestOriginFromContext(ctx context.Context) RequestOrigin {
+	value, ok := ctx.Value(requestOriginCtxKey{}).(RequestOrigin)
+	if ok {
+		return value
+	}
+	return RequestOriginInteractive
+}
+
+// WithRequestOrigin sets the plugin request origin for the context.
+func WithRequestOrigin(ctx context.Context, o RequestOrigin) context.Context {
+	return context.WithValue(ctx, requestOriginCtxKey{}, o)
+}
+
+type pluginCallsCtxKey struct{}
+
+// WithPluginCallCounter returns a copy of the context counting the plugin calls made with it, and the counter.
+// It's meant to be called by the origin of the plugin calls, e.g. for each HTTP request, to know how many
+// plugin calls it makes.
+func WithPluginCallCounter(ctx context.Context) (context.Context, *atomic.Int64) {
+	counter := &atomic.Int64{}
+	return context.WithValue(ctx, pluginCallsCtxKey{}, counter), counter
+}
+
+// CountPluginCall increments the plugin call counter of the context, if it has one.
+func CountPluginCall(ctx context.Context) {
+	if counter, ok := ctx.Value(pluginCallsCtxKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
+
+// CalledPluginMixed is the ID returned by CalledPlugin.ID when several plugins were called.
+const CalledPluginMixed = "mixed"
+
+type calledPluginCtxKey struct{}
+
+// CalledPlugin is the plugin called with a context, as recorded by RecordCalledPlugin.
+type CalledPlugin struct {
+	mu sync.Mutex
+	id string
+}
+
+// ID returns the ID of the plugin called with the context, CalledPluginMixed if several plugins were called,
+// or an empty string if no plugin was called.
+func (p *CalledPlugin) ID() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.id
+}
+
+// WithCalledPlugin returns a copy of the context recording the plugin called with it, and the recorder.
+// Like WithPluginCallCounter, it's meant to be called by the origin of the plugin calls.
+func WithCalledPlugin(ctx context.Context) (context.Context, *CalledPlugin) {
+	p := &CalledPlugin{}
+	return context.WithValue(ctx, calledPluginCtxKey{}, p), p
+}
+
+// RecordCalledPlugin records the ID of a plugin called with the context, if the context records them.
+func RecordCalledPlugin(ctx context.Context, pluginID string) {
+	p, ok := ctx.Value(calledPluginCtxKey{}).(*CalledPlugin)
+	if !ok || pluginID == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.id {
+	case "":
+		p.id = pluginID
+	case pluginID:
+	default:
+		p.id = CalledPluginMixed
+	}
+}
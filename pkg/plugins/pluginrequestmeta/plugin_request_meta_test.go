@@ -34,6 +34,26 @@ func TestStatusSource(t *testing.T) {
 		})
 	})
 
+	t.Run("StatusSourceTransitionFromContext", func(t *testing.T) {
+		t.Run("Returns nothing if no status source was set", func(t *testing.T) {
+			_, _, ok := StatusSourceTransitionFromContext(context.Background())
+			require.False(t, ok)
+			_, _, ok = StatusSourceTransitionFromContext(WithStatusSource(context.Background(), StatusSourcePlugin))
+			require.False(t, ok)
+		})
+
+		t.Run("Returns the first and the last status sources set", func(t *testing.T) {
+			ctx := WithStatusSource(context.Background(), StatusSourcePlugin)
+			require.NoError(t, WithDownstreamStatusSource(ctx))
+			require.NoError(t, SetStatusSource(ctx, StatusSourcePlugin))
+			initial, final, ok := StatusSourceTransitionFromContext(ctx)
+			require.True(t, ok)
+			require.Equal(t, StatusSourceDownstream, initial)
+			require.Equal(t, StatusSourcePlugin, final)
+			require.Equal(t, StatusSourcePlugin, StatusSourceFromContext(ctx))
+		})
+	})
+
 	t.Run("StatusSourceFromContext", func(t *testing.T) {
 		t.Run("Background returns StatusSourcePlugin", func(t *testing.T) {
 			ctx := context.Background()
@@ -48,3 +68,55 @@ func TestStatusSource(t *testing.T) {
 		})
 	})
 }
+
+func TestInstrumentationOverrides(t *testing.T) {
+	require.False(t, InstrumentationOverridden(context.Background(), "flagA"))
+
+	ctx := WithInstrumentationOverrides(context.Background(), "flagA")
+	require.True(t, InstrumentationOverridden(ctx, "flagA"))
+	require.False(t, InstrumentationOverridden(ctx, "flagB"))
+}
+
+func TestRequestOrigin(t *testing.T) {
+	require.Equal(t, RequestOriginInteractive, RequestOriginFromContext(context.Background()))
+
+	ctx := WithRequestOrigin(context.Background(), RequestOriginAlerting)
+	require.Equal(t, RequestOriginAlerting, RequestOriginFromContext(ctx))
+}
+
+func TestPluginCallCounter(t *testing.T) {
+	// Counting without a counter is a no-op
+	CountPluginCall(context.Background())
+
+	ctx, counter := WithPluginCallCounter(context.Background())
+	CountPluginCall(ctx)
+	CountPluginCall(context.WithValue(ctx, struct{}{}, "derived"))
+	require.Equal(t, int64(2), counter.Load())
+}
+
+func TestCalledPlugin(t *testing.T) {
+	// Recording without a recorder is a no-op
+	RecordCalledPlugin(context.Background(), "grafana-testdata-datasource")
+
+	ctx, called := WithCalledPlugin(context.Background())
+	require.Empty(t, called.ID())
+	RecordCalledPlugin(ctx, "grafana-testdata-datasource")
+	RecordCalledPlugin(ctx, "grafana-testdata-datasource")
+	require.Equal(t, "grafana-testdata-datasource", called.ID())
+	RecordCalledPlugin(ctx, "prometheus")
+	require.Equal(t, CalledPluginMixed, called.ID())
+}
+
+func TestQueuePosition(t *testing.T) {
+	t.Run("Returns 0 if no queue position is set", func(t *testing.T) {
+		require.Zero(t, QueuePositionFromContext(context.Background()))
+		require.Error(t, SetQueuePosition(context.Background(), 2))
+	})
+
+	t.Run("Should mutate context if queue position is set", func(t *testing.T) {
+		ctx := WithQueuePosition(context.Background(), 0)
+		require.Zero(t, QueuePositionFromContext(ctx))
+		require.NoError(t, SetQueuePosition(ctx, 2))
+		require.Equal(t, int64(2), QueuePositionFromContext(ctx))
+	})
+}
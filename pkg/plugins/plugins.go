@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 
@@ -64,6 +65,9 @@ type Plugin struct {
 	client         backendplugin.Plugin
 	log            log.Logger
 
+	// starts is the number of times the plugin has been started, so restarts can be detected.
+	starts atomic.Int64
+
 	mu sync.Mutex
 }
 
@@ -236,7 +240,17 @@ func (p *Plugin) Start(ctx context.Context) error {
 		return fmt.Errorf("could not start plugin %s as no plugin client exists", p.ID)
 	}
 
-	return p.client.Start(ctx)
+	if err := p.client.Start(ctx); err != nil {
+		return err
+	}
+	p.starts.Add(1)
+	return nil
+}
+
+// Starts returns the number of times the plugin has been successfully started.
+// It changes every time the plugin process is restarted.
+func (p *Plugin) Starts() int64 {
+	return p.starts.Load()
 }
 
 func (p *Plugin) Stop(ctx context.Context) error {
@@ -285,10 +299,18 @@ func (p *Plugin) Target() backendplugin.Target {
 	if !p.Backend {
 		return backendplugin.TargetNone
 	}
-	if p.client == nil {
-		return backendplugin.TargetUnknown
+	if p.client != nil {
+		return p.client.Target()
+	}
+	// The client isn't registered yet, but it's known where the plugin runs:
+	// core plugins run in memory, and external plugins run their executable locally
+	if p.IsCorePlugin() {
+		return backendplugin.TargetInMemory
+	}
+	if p.Executable != "" {
+		return backendplugin.TargetLocal
 	}
-	return p.client.Target()
+	return backendplugin.TargetUnknown
 }
 
 func (p *Plugin) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana/pkg/plugins/backendplugin"
 	"github.com/grafana/grafana/pkg/services/org"
 	"github.com/stretchr/testify/require"
 )
@@ -204,3 +205,37 @@ func Test_validatePluginJSON(t *testing.T) {
 		})
 	}
 }
+
+func Test_Target(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *Plugin
+		want   backendplugin.Target
+	}{
+		{
+			name:   "Frontend plugin",
+			plugin: &Plugin{JSONData: JSONData{ID: "panel"}, Class: ClassExternal},
+			want:   backendplugin.TargetNone,
+		},
+		{
+			name:   "Core backend plugin without client",
+			plugin: &Plugin{JSONData: JSONData{ID: "prometheus", Backend: true}, Class: ClassCore},
+			want:   backendplugin.TargetInMemory,
+		},
+		{
+			name:   "External backend plugin without client",
+			plugin: &Plugin{JSONData: JSONData{ID: "external", Backend: true, Executable: "gpx_external"}, Class: ClassExternal},
+			want:   backendplugin.TargetLocal,
+		},
+		{
+			name:   "Backend plugin without client nor executable",
+			plugin: &Plugin{JSONData: JSONData{ID: "external", Backend: true}, Class: ClassExternal},
+			want:   backendplugin.TargetUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.plugin.Target())
+		})
+	}
+}
@@ -2,6 +2,7 @@ package caching
 
 import (
 	"context"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
@@ -15,6 +16,20 @@ const (
 	StatusDisabled = "DISABLED"
 )
 
+type ttlKey struct{}
+
+// WithTTL returns a copy of ctx carrying the TTL suggested by the plugin for the response being cached.
+// Implementations of CacheQueryResponseFn and CacheResourceResponseFn should use it instead of their default TTL.
+func WithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlKey{}, ttl)
+}
+
+// TTLFromContext returns the TTL suggested by the plugin for the response being cached, if any.
+func TTLFromContext(ctx context.Context) (time.Duration, bool) {
+	ttl, ok := ctx.Value(ttlKey{}).(time.Duration)
+	return ttl, ok
+}
+
 type CacheQueryResponseFn func(context.Context, *backend.QueryDataResponse)
 type CacheResourceResponseFn func(context.Context, *backend.CallResourceResponse)
 
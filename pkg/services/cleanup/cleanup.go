@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/dashboardsnapshots"
 	dashver "github.com/grafana/grafana/pkg/services/dashboardversion"
 	"github.com/grafana/grafana/pkg/services/ngalert/image"
+	"github.com/grafana/grafana/pkg/services/playlist"
 	"github.com/grafana/grafana/pkg/services/queryhistory"
 	"github.com/grafana/grafana/pkg/services/shorturls"
 	tempuser "github.com/grafana/grafana/pkg/services/temp_user"
@@ -29,7 +30,8 @@ import (
 func ProvideService(cfg *setting.Cfg, serverLockService *serverlock.ServerLockService,
 	shortURLService shorturls.Service, sqlstore db.DB, queryHistoryService queryhistory.Service,
 	dashboardVersionService dashver.Service, dashSnapSvc dashboardsnapshots.Service, deleteExpiredImageService *image.DeleteExpiredService,
-	tempUserService tempuser.Service, tracer tracing.Tracer, annotationCleaner annotations.Cleaner) *CleanUpService {
+	tempUserService tempuser.Service, tracer tracing.Tracer, annotationCleaner annotations.Cleaner,
+	playlistService playlist.Service) *CleanUpService {
 	s := &CleanUpService{
 		Cfg:                       cfg,
 		ServerLockService:         serverLockService,
@@ -43,6 +45,7 @@ func ProvideService(cfg *setting.Cfg, serverLockService *serverlock.ServerLockSe
 		tempUserService:           tempUserService,
 		tracer:                    tracer,
 		annotationCleaner:         annotationCleaner,
+		playlistService:           playlistService,
 	}
 	return s
 }
@@ -60,6 +63,7 @@ type CleanUpService struct {
 	deleteExpiredImageService *image.DeleteExpiredService
 	tempUserService           tempuser.Service
 	annotationCleaner         annotations.Cleaner
+	playlistService           playlist.Service
 }
 
 type cleanUpJob struct {
@@ -102,6 +106,7 @@ func (srv *CleanUpService) clean(ctx context.Context) {
 		{"expire old user invites", srv.expireOldUserInvites},
 		{"delete stale short URLs", srv.deleteStaleShortURLs},
 		{"delete stale query history", srv.deleteStaleQueryHistory},
+		{"purge expired trashed playlists", srv.purgeTrashedPlaylists},
 	}
 
 	logger := srv.log.FromContext(ctx)
@@ -279,3 +284,15 @@ func (srv *CleanUpService) deleteStaleQueryHistory(ctx context.Context) {
 		logger.Debug("Enforced row limit for query_history_star", "rows affected", rowsCount)
 	}
 }
+
+func (srv *CleanUpService) purgeTrashedPlaylists(ctx context.Context) {
+	logger := srv.log.FromContext(ctx)
+	purged, err := srv.playlistService.PurgeTrash(ctx)
+	if errors.Is(err, playlist.ErrMaintenanceMode) {
+		logger.Debug("Skipped purging trashed playlists during maintenance")
+	} else if err != nil {
+		logger.Error("Problem purging trashed playlists", "error", err.Error())
+	} else {
+		logger.Debug("Purged trashed playlists", "rows affected", purged)
+	}
+}
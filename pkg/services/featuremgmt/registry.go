@@ -638,6 +638,12 @@ var (
 			Stage:       FeatureStagePublicPreview,
 			Owner:       awsDatasourcesSquad,
 		},
+		{
+			Name:        "queryCachingPartialHits",
+			Description: "Cache the queries of multi-query requests on their own, to serve the cached ones from the cache and only execute the others. Requires that the `useCachingService` feature toggle is enabled",
+			Stage:       FeatureStageExperimental,
+			Owner:       grafanaOperatorExperienceSquad,
+		},
 		{
 			Name:            "splitScopes",
 			Description:     "Support faster dashboard and folder search by splitting permission scopes into parts",
@@ -857,6 +863,25 @@ var (
 			Owner:           grafanaAppPlatformSquad,
 			RequiresRestart: true, // changes the API routing
 		},
+		{
+			Name:        "playlistResponseV2",
+			Description: "Allow clients to request the version 2 of the playlist API response, which includes additional metadata",
+			Stage:       FeatureStageExperimental,
+			Owner:       grafanaAppPlatformSquad,
+		},
+		{
+			Name:            "playlistPublicLinks",
+			Description:     "Allow editors to create signed, time-limited public links giving read-only access to a playlist and its dashboards",
+			Stage:           FeatureStageExperimental,
+			Owner:           grafanaAppPlatformSquad,
+			RequiresRestart: true, // changes the API routing
+		},
+		{
+			Name:        "playlistMaintenanceMode",
+			Description: "Reject playlist writes with a 503 while the playlist store is being migrated, reads keep working",
+			Stage:       FeatureStageExperimental,
+			Owner:       grafanaAppPlatformSquad,
+		},
 		{
 			Name:        "cloudWatchBatchQueries",
 			Description: "Runs CloudWatch metrics queries as separate batches",
@@ -913,6 +938,69 @@ var (
 			Stage:        FeatureStageExperimental,
 			Owner:        grafanaPluginsPlatformSquad,
 		},
+		{
+			Name:         "pluginsInstrumentationStatusCode",
+			Description:  "Count plugin request errors by their exact HTTP status code",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationOverrides",
+			Description:  "Allow Grafana server admins to enable plugin instrumentation feature toggles for a single request via the X-Grafana-Instrumentation-Override header",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationRangeRecency",
+			Description:  "Include a range_recency label in the plugin request counter, based on how close the end of the query time range is to now",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationResponseEncoding",
+			Description:  "Observe the JSON encoding time of the plugin query responses, from the encoding done to measure their size",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationAlertingHistogram",
+			Description:  "Observe the duration of the plugin requests made by alerting in a dedicated histogram, instead of the general ones",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationClientClass",
+			Description:  "Add a client_class label to the plugin request counter, derived from the User-Agent of the request",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationRegistryLookup",
+			Description:  "Observe the plugin registry lookups made by the plugin metrics middleware, and cache them for a few seconds",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationErrorCategory",
+			Description:  "Count the failed plugin requests by error category, such as timeout, auth or connection",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
+		{
+			Name:         "pluginsInstrumentationDatasourceUID",
+			Description:  "Add a datasource_uid label to the plugin request metrics, to tell apart the data sources of a plugin",
+			FrontendOnly: false,
+			Stage:        FeatureStageExperimental,
+			Owner:        grafanaPluginsPlatformSquad,
+		},
 		{
 			Name:         "costManagementUi",
 			Description:  "Toggles the display of the cost management ui plugin",
@@ -375,6 +375,10 @@ const (
 	// Enable caching for async queries for Redshift and Athena. Requires that the `useCachingService` feature toggle is enabled and the datasource has caching and async query support enabled
 	FlagAwsAsyncQueryCaching = "awsAsyncQueryCaching"
 
+	// FlagQueryCachingPartialHits
+	// Cache the queries of multi-query requests on their own, to serve the cached ones from the cache and only execute the others. Requires that the `useCachingService` feature toggle is enabled
+	FlagQueryCachingPartialHits = "queryCachingPartialHits"
+
 	// FlagSplitScopes
 	// Support faster dashboard and folder search by splitting permission scopes into parts
 	FlagSplitScopes = "splitScopes"
@@ -495,6 +499,18 @@ const (
 	// Route /api/playlist API to k8s handlers
 	FlagKubernetesPlaylistsAPI = "kubernetesPlaylistsAPI"
 
+	// FlagPlaylistResponseV2
+	// Allow clients to request the version 2 of the playlist API response, which includes additional metadata
+	FlagPlaylistResponseV2 = "playlistResponseV2"
+
+	// FlagPlaylistPublicLinks
+	// Allow editors to create signed, time-limited public links giving read-only access to a playlist and its dashboards
+	FlagPlaylistPublicLinks = "playlistPublicLinks"
+
+	// FlagPlaylistMaintenanceMode
+	// Reject playlist writes with a 503 while the playlist store is being migrated, reads keep working
+	FlagPlaylistMaintenanceMode = "playlistMaintenanceMode"
+
 	// FlagCloudWatchBatchQueries
 	// Runs CloudWatch metrics queries as separate batches
 	FlagCloudWatchBatchQueries = "cloudWatchBatchQueries"
@@ -527,6 +543,42 @@ const (
 	// Include a status source label for plugin request metrics and logs
 	FlagPluginsInstrumentationStatusSource = "pluginsInstrumentationStatusSource"
 
+	// FlagPluginsInstrumentationStatusCode
+	// Count plugin request errors by their exact HTTP status code
+	FlagPluginsInstrumentationStatusCode = "pluginsInstrumentationStatusCode"
+
+	// FlagPluginsInstrumentationOverrides
+	// Allow Grafana server admins to enable plugin instrumentation feature toggles for a single request via the X-Grafana-Instrumentation-Override header
+	FlagPluginsInstrumentationOverrides = "pluginsInstrumentationOverrides"
+
+	// FlagPluginsInstrumentationRangeRecency
+	// Include a range_recency label in the plugin request counter, based on how close the end of the query time range is to now
+	FlagPluginsInstrumentationRangeRecency = "pluginsInstrumentationRangeRecency"
+
+	// FlagPluginsInstrumentationResponseEncoding
+	// Observe the JSON encoding time of the plugin query responses, from the encoding done to measure their size
+	FlagPluginsInstrumentationResponseEncoding = "pluginsInstrumentationResponseEncoding"
+
+	// FlagPluginsInstrumentationAlertingHistogram
+	// Observe the duration of the plugin requests made by alerting in a dedicated histogram, instead of the general ones
+	FlagPluginsInstrumentationAlertingHistogram = "pluginsInstrumentationAlertingHistogram"
+
+	// FlagPluginsInstrumentationClientClass
+	// Add a client_class label to the plugin request counter, derived from the User-Agent of the request
+	FlagPluginsInstrumentationClientClass = "pluginsInstrumentationClientClass"
+
+	// FlagPluginsInstrumentationRegistryLookup
+	// Observe the plugin registry lookups made by the plugin metrics middleware, and cache them for a few seconds
+	FlagPluginsInstrumentationRegistryLookup = "pluginsInstrumentationRegistryLookup"
+
+	// FlagPluginsInstrumentationErrorCategory
+	// Count the failed plugin requests by error category, such as timeout, auth or connection
+	FlagPluginsInstrumentationErrorCategory = "pluginsInstrumentationErrorCategory"
+
+	// FlagPluginsInstrumentationDatasourceUID
+	// Add a datasource_uid label to the plugin request metrics, to tell apart the data sources of a plugin
+	FlagPluginsInstrumentationDatasourceUID = "pluginsInstrumentationDatasourceUID"
+
 	// FlagCostManagementUi
 	// Toggles the display of the cost management ui plugin
 	FlagCostManagementUi = "costManagementUi"
@@ -0,0 +1,19 @@
+package playlist
+
+import (
+	ac "github.com/grafana/grafana/pkg/services/accesscontrol"
+)
+
+const (
+	ScopePlaylistsRoot   = "playlists"
+	ScopePlaylistsPrefix = "playlists:uid:"
+
+	ActionPlaylistsRead   = "playlists:read"
+	ActionPlaylistsWrite  = "playlists:write"
+	ActionPlaylistsDelete = "playlists:delete"
+)
+
+var (
+	ScopePlaylistsProvider = ac.NewScopeProvider(ScopePlaylistsRoot)
+	ScopePlaylistsAll      = ScopePlaylistsProvider.GetResourceAllScope()
+)
@@ -2,12 +2,71 @@ package playlist
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/util"
 )
 
 // Typed errors
 var (
 	ErrPlaylistNotFound        = errors.New("Playlist not found")
+	ErrPlaylistAlreadyExists   = errors.New("a playlist with the same uid already exists")
 	ErrCommandValidationFailed = errors.New("command missing required fields")
+	ErrInvalidInterval         = errors.New("invalid playlist interval")
+	ErrExternalURLNotAllowed   = errors.New("external URL is not allowed")
+	ErrInvalidItemInterval     = errors.New("invalid playlist item interval")
+	ErrInvalidRecentlyViewed   = errors.New("invalid number of recently viewed dashboards")
+	ErrInvalidSectionLabel     = errors.New("invalid playlist section label")
+	ErrInvalidPlaylistRef      = errors.New("invalid playlist reference")
+	ErrInvalidPlaylistUID      = errors.New("invalid playlist uid")
+	ErrMaintenanceMode         = errors.New("playlists are read-only during maintenance")
+)
+
+const (
+	// ItemTypeExternalURL is the type of the items showing a web page outside of Grafana.
+	ItemTypeExternalURL = "external_url"
+	// ItemTypeRecentlyViewed is the type of the items showing the dashboards recently viewed by the user
+	// playing the playlist, so the playlist resolves to different dashboards for each user.
+	ItemTypeRecentlyViewed = "recently_viewed"
+	// ItemTypeSection is the type of the items labeling the items after them, up to the next section,
+	// e.g. to group the dashboards of an environment. They don't show anything.
+	ItemTypeSection = "section"
+)
+
+// DefaultInterval is the interval of the playlists created or updated without one.
+const DefaultInterval = "5m"
+
+// ValidateUID returns an ErrInvalidPlaylistUID error if uid doesn't follow the rules of the Grafana UIDs.
+func ValidateUID(uid string) error {
+	if err := util.ValidateUID(uid); err != nil {
+		return fmt.Errorf("%w %q: %s", ErrInvalidPlaylistUID, uid, err)
+	}
+	return nil
+}
+
+// ValidateInterval checks that the interval of a playlist is a positive duration, like 5m or 1h30m.
+func ValidateInterval(interval string) error {
+	if d, err := gtime.ParseDuration(interval); err != nil || d <= 0 {
+		return fmt.Errorf("%w: %q is not a positive duration, like 5m", ErrInvalidInterval, interval)
+	}
+	return nil
+}
+
+// MaxSectionLabelLength is the maximum number of characters of the label of a section item.
+const MaxSectionLabelLength = 64
+
+// MaxRefDepth is the maximum number of nested playlists followed when checking that the references of a
+// playlist to other playlists, like external_url items playing a playlist of this instance, don't form a cycle.
+const MaxRefDepth = 8
+
+const (
+	QuotaTargetSrv quota.TargetSrv = "playlist"
+	QuotaTarget    quota.Target    = "playlist"
 )
 
 // Playlist model
@@ -23,6 +82,9 @@ type Playlist struct {
 	// Using int64 rather than time.Time to avoid database issues with time support
 	CreatedAt int64 `json:"-" db:"created_at"`
 	UpdatedAt int64 `json:"-" db:"updated_at"`
+
+	// DeletedAt is when the playlist was moved to the trash, in milliseconds, or zero if it's not in the trash.
+	DeletedAt int64 `json:"deletedAt,omitempty" db:"deleted_at"`
 }
 
 type PlaylistDTO struct {
@@ -39,6 +101,18 @@ type PlaylistDTO struct {
 	// The ordered list of items that the playlist will iterate over.
 	Items []PlaylistItemDTO `json:"items,omitempty"`
 
+	// Created is when the playlist was created. It's not set if the backend doesn't know it.
+	Created *time.Time `json:"created,omitempty"`
+
+	// Updated is when the playlist was last updated. It's not set if the backend doesn't know it.
+	Updated *time.Time `json:"updated,omitempty"`
+
+	// CreatedBy is the user who created the playlist. It's only known by the Kubernetes backend.
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	// UpdatedBy is the user who last updated the playlist. It's only known by the Kubernetes backend.
+	UpdatedBy string `json:"updatedBy,omitempty"`
+
 	// Returned for k8s
 	CreatedAt int64 `json:"-"`
 
@@ -50,6 +124,9 @@ type PlaylistDTO struct {
 
 	// Returned for k8s and added as an annotation
 	Id int64 `json:"-"`
+
+	// Returned for k8s and added as a label and an annotation
+	DeletedAt int64 `json:"-"`
 }
 
 type PlaylistItemDTO struct {
@@ -67,7 +144,26 @@ type PlaylistItemDTO struct {
 	//  - dashboard_by_tag: The value is a tag which is set on any number of dashboards. All
 	//  dashboards behind the tag will be added to the playlist.
 	//  - dashboard_by_uid: The value is the dashboard UID
+	//  - external_url: The value is the URL of a web page outside of Grafana. Its scheme and host
+	//  must be allowed in the [playlists] configuration section.
+	//  - recently_viewed: The value is the maximum number of dashboards to show, among the ones
+	//  the user playing the playlist viewed most recently. It resolves differently for each user.
+	//  - section: The value is the label of the section starting with this item, e.g. Prod.
+	//  It doesn't resolve to any dashboard.
 	Value string `json:"value"`
+
+	// Interval overrides the interval of the playlist for this item, e.g. to show a dense
+	// dashboard for longer. The interval of the playlist is used when it's empty.
+	Interval string `json:"interval,omitempty"`
+}
+
+// ItemInterval returns the time the given item is shown: its own interval if it overrides
+// the playlist one, the playlist interval otherwise.
+func (p *PlaylistDTO) ItemInterval(item PlaylistItemDTO) string {
+	if item.Interval != "" {
+		return item.Interval
+	}
+	return p.Interval
 }
 
 type PlaylistItem struct {
@@ -77,10 +173,17 @@ type PlaylistItem struct {
 	Value      string `json:"value" db:"value"`
 	Order      int    `json:"order" db:"order"`
 	Title      string `json:"title" db:"title"`
+	Interval   string `json:"interval,omitempty" db:"interval"`
 }
 
 type Playlists []*Playlist
 
+// PlaylistSizeCount is the number of playlists with a given number of items.
+type PlaylistSizeCount struct {
+	Items     int64 `json:"items" xorm:"item_count"`
+	Playlists int64 `json:"playlists" xorm:"playlist_count"`
+}
+
 //
 // COMMANDS
 //
@@ -91,6 +194,8 @@ type UpdatePlaylistCommand struct {
 	Name     string         `json:"name" binding:"Required"`
 	Interval string         `json:"interval"`
 	Items    []PlaylistItem `json:"items"`
+	// Dedupe removes the items with the same type and value as a previous item before saving them.
+	Dedupe bool `json:"dedupe,omitempty"`
 }
 
 type CreatePlaylistCommand struct {
@@ -98,8 +203,29 @@ type CreatePlaylistCommand struct {
 	Interval string         `json:"interval"`
 	Items    []PlaylistItem `json:"items"`
 	OrgId    int64          `json:"-"`
-	// Used to create playlists from kubectl with a known uid/name
-	UID string `json:"-"`
+	// Optional, to create playlists with a known uid/name, e.g. from kubectl. It's generated if not set.
+	UID string `json:"uid,omitempty"`
+	// Dedupe removes the items with the same type and value as a previous item before saving them.
+	Dedupe bool `json:"dedupe,omitempty"`
+	// DryRun runs the checks of the creation without saving the playlist.
+	DryRun bool `json:"-"`
+}
+
+// DedupeItems returns the items without the ones with the same type and value as a previous item,
+// keeping the first occurrences in order.
+func DedupeItems(items []PlaylistItem) []PlaylistItem {
+	type key struct{ typ, value string }
+	seen := make(map[key]bool, len(items))
+	deduped := make([]PlaylistItem, 0, len(items))
+	for _, item := range items {
+		k := key{item.Type, item.Value}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		deduped = append(deduped, item)
+	}
+	return deduped
 }
 
 type DeletePlaylistCommand struct {
@@ -107,23 +233,75 @@ type DeletePlaylistCommand struct {
 	OrgId int64
 }
 
+// RestorePlaylistCommand moves a playlist out of the trash.
+type RestorePlaylistCommand struct {
+	UID   string
+	OrgId int64
+}
+
 //
 // QUERIES
 //
 
 type GetPlaylistsQuery struct {
 	// NOTE: the frontend never sends this query
-	Name  string
-	Limit int
-	OrgId int64
+	Name string
+	// NameRegex restricts the search to the playlists whose name matches it, if it's set. It's applied by the
+	// store after fetching the playlists, as the databases don't share a regular expression syntax.
+	NameRegex *regexp.Regexp
+	Limit     int
+	// Page is the 1-based page of Limit playlists to return, ordered by creation. The first page is returned if it's not set.
+	Page int
+	// ItemType restricts the search to the playlists with at least one item of this type, if it's set.
+	ItemType string
+	// Sort is the order of the playlists, one of the Sort constants. They're ordered by creation if it's not set.
+	Sort string
+	// IncludeTrashed includes the playlists in the trash, which are excluded otherwise.
+	IncludeTrashed bool
+	OrgId          int64
 }
 
+// The orders of the playlist searches. The playlists with the same name or creation time are ordered by ID.
+const (
+	SortNameAsc     = "name-asc"
+	SortNameDesc    = "name-desc"
+	SortCreatedAsc  = "created-asc"
+	SortCreatedDesc = "created-desc"
+)
+
 type GetPlaylistByUidQuery struct {
 	UID   string
 	OrgId int64
+	// IncludeTrashed finds the playlist even if it's in the trash.
+	IncludeTrashed bool
 }
 
 type GetPlaylistItemsByUidQuery struct {
 	PlaylistUID string
 	OrgId       int64
+	// IncludeTrashed finds the items of the playlist even if it's in the trash.
+	IncludeTrashed bool
+}
+
+// GetPlaylistsItemsByUidsQuery gets the items of several playlists at once.
+type GetPlaylistsItemsByUidsQuery struct {
+	PlaylistUIDs []string
+	OrgId        int64
+	// IncludeTrashed finds the items of the playlists even if they're in the trash.
+	IncludeTrashed bool
+}
+
+type GetPlaylistSizeDistributionQuery struct {
+	OrgId int64
+}
+
+type GetLastUpdatedQuery struct {
+	OrgId int64
+}
+
+// PlaylistSearchStats describe all the playlists matching a search, regardless of its limit and page.
+type PlaylistSearchStats struct {
+	Count int64
+	// LastUpdated is the most recent update time of the matching playlists, in milliseconds, or zero if there's none.
+	LastUpdated int64
 }
@@ -9,6 +9,24 @@ type Service interface {
 	Update(context.Context, *UpdatePlaylistCommand) (*PlaylistDTO, error)
 	GetWithoutItems(context.Context, *GetPlaylistByUidQuery) (*Playlist, error)
 	Get(context.Context, *GetPlaylistByUidQuery) (*PlaylistDTO, error)
+	// GetItemsByUIDs returns the items of the given playlists, keyed by playlist UID, in a single query.
+	// The playlists that are not found are left out.
+	GetItemsByUIDs(context.Context, *GetPlaylistsItemsByUidsQuery) (map[string][]PlaylistItemDTO, error)
 	Search(context.Context, *GetPlaylistsQuery) (Playlists, error)
+	// SearchCount returns the number of playlists matching the query, ignoring its limit and page.
+	SearchCount(context.Context, *GetPlaylistsQuery) (int64, error)
+	// SearchStats returns the number and last update time of the playlists matching the query, ignoring its limit
+	// and page, so that changes to any of them can be detected.
+	SearchStats(context.Context, *GetPlaylistsQuery) (PlaylistSearchStats, error)
+	// Delete moves the playlist to the trash if the [playlists] trash_retention setting is set, and deletes it otherwise.
 	Delete(ctx context.Context, cmd *DeletePlaylistCommand) error
+	// Restore moves a playlist out of the trash, if it was moved there less than the trash retention ago.
+	Restore(context.Context, *RestorePlaylistCommand) (*Playlist, error)
+	// PurgeTrash deletes the playlists moved to the trash more than the trash retention ago, and returns their number.
+	PurgeTrash(context.Context) (int64, error)
+	// GetLastUpdated returns the time of the most recent change to the playlists of the org, in milliseconds,
+	// deletions included, or zero if they never changed.
+	GetLastUpdated(context.Context, *GetLastUpdatedQuery) (int64, error)
+	// GetSizeDistribution returns the number of playlists of the org per number of items, ordered by number of items.
+	GetSizeDistribution(context.Context, *GetPlaylistSizeDistributionQuery) ([]PlaylistSizeCount, error)
 }
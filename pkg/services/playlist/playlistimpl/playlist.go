@@ -2,40 +2,172 @@ package playlistimpl
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/kvstore"
 	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/setting"
 )
 
 type Service struct {
-	store  store
-	tracer tracing.Tracer
+	store    store
+	tracer   tracing.Tracer
+	features featuremgmt.FeatureToggles
+	// kv keeps the time of the last deletion of each org, which the playlist table can't tell
+	kv kvstore.KVStore
+
+	// validator checks the items of the created and updated playlists
+	validator *playlist.ItemValidator
+	// trashRetention is how long the deleted playlists are kept in the trash, if they're moved there
+	trashRetention time.Duration
+	now            func() time.Time
 }
 
 var _ playlist.Service = &Service{}
 
-func ProvideService(db db.DB, tracer tracing.Tracer) playlist.Service {
-	return &Service{
-		tracer: tracer,
+func ProvideService(db db.DB, tracer tracing.Tracer, quotaService quota.Service, cfg *setting.Cfg, features featuremgmt.FeatureToggles) (playlist.Service, error) {
+	s := &Service{
+		tracer:   tracer,
+		features: features,
 		store: &sqlStore{
 			db: db,
 		},
+		validator: playlist.NewItemValidator(cfg),
+		kv:        kvstore.ProvideService(db),
+		now:       time.Now,
+	}
+	if cfg != nil {
+		s.trashRetention = cfg.Playlist.TrashRetention
+	}
+
+	defaultLimits, err := readQuotaConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := quotaService.RegisterQuotaReporter(&quota.NewUsageReporter{
+		TargetSrv:     playlist.QuotaTargetSrv,
+		DefaultLimits: defaultLimits,
+		Reporter:      s.Usage,
+	}); err != nil {
+		return nil, err
 	}
+	return s, nil
+}
+
+func (s *Service) Usage(ctx context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error) {
+	return s.store.Count(ctx, scopeParams)
+}
+
+func readQuotaConfig(cfg *setting.Cfg) (*quota.Map, error) {
+	limits := &quota.Map{}
+
+	if cfg == nil {
+		return limits, nil
+	}
+
+	globalQuotaTag, err := quota.NewTag(playlist.QuotaTargetSrv, playlist.QuotaTarget, quota.GlobalScope)
+	if err != nil {
+		return limits, err
+	}
+	orgQuotaTag, err := quota.NewTag(playlist.QuotaTargetSrv, playlist.QuotaTarget, quota.OrgScope)
+	if err != nil {
+		return limits, err
+	}
+
+	limits.Set(globalQuotaTag, cfg.Quota.Global.Playlist)
+	limits.Set(orgQuotaTag, cfg.Quota.Org.Playlist)
+	return limits, nil
 }
 
 func (s *Service) Create(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error) {
 	ctx, span := s.tracer.Start(ctx, "playlists.Create")
 	defer span.End()
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if cmd.Dedupe {
+		cmd.Items = playlist.DedupeItems(cmd.Items)
+	}
+	if cmd.UID != "" {
+		if err := playlist.ValidateUID(cmd.UID); err != nil {
+			return nil, err
+		}
+	}
+	if cmd.Interval == "" {
+		cmd.Interval = playlist.DefaultInterval
+	}
+	if err := playlist.ValidateInterval(cmd.Interval); err != nil {
+		return nil, err
+	}
+	if err := s.validator.Validate(ctx, cmd.UID, cmd.Items, s.itemsGetter(cmd.OrgId)); err != nil {
+		return nil, err
+	}
+	if cmd.DryRun {
+		return s.dryRunCreate(ctx, cmd)
+	}
 	return s.store.Insert(ctx, cmd)
 }
 
+// dryRunCreate returns the playlist cmd would create, or playlist.ErrPlaylistAlreadyExists if its UID is taken,
+// by a playlist in the trash too. The UID of the returned playlist is empty if cmd doesn't set it.
+func (s *Service) dryRunCreate(ctx context.Context, cmd *playlist.CreatePlaylistCommand) (*playlist.Playlist, error) {
+	if cmd.UID != "" {
+		_, err := s.store.Get(ctx, &playlist.GetPlaylistByUidQuery{UID: cmd.UID, OrgId: cmd.OrgId, IncludeTrashed: true})
+		if err == nil {
+			return nil, fmt.Errorf("%w: %q", playlist.ErrPlaylistAlreadyExists, cmd.UID)
+		}
+		if !errors.Is(err, playlist.ErrPlaylistNotFound) {
+			return nil, err
+		}
+	}
+	return &playlist.Playlist{UID: cmd.UID, Name: cmd.Name, Interval: cmd.Interval, OrgId: cmd.OrgId}, nil
+}
+
 func (s *Service) Update(ctx context.Context, cmd *playlist.UpdatePlaylistCommand) (*playlist.PlaylistDTO, error) {
 	ctx, span := s.tracer.Start(ctx, "playlists.Update")
 	defer span.End()
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if cmd.Dedupe {
+		cmd.Items = playlist.DedupeItems(cmd.Items)
+	}
+	if cmd.Interval == "" {
+		cmd.Interval = playlist.DefaultInterval
+	}
+	if err := playlist.ValidateInterval(cmd.Interval); err != nil {
+		return nil, err
+	}
+	if err := s.validator.Validate(ctx, cmd.UID, cmd.Items, s.itemsGetter(cmd.OrgId)); err != nil {
+		return nil, err
+	}
 	return s.store.Update(ctx, cmd)
 }
 
+// checkWritable returns playlist.ErrMaintenanceMode if the playlists are in maintenance mode.
+// The flag is checked for every write, so the maintenance mode can be toggled without restarting.
+func (s *Service) checkWritable() error {
+	if s.features.IsEnabled(featuremgmt.FlagPlaylistMaintenanceMode) {
+		return playlist.ErrMaintenanceMode
+	}
+	return nil
+}
+
+// itemsGetter returns a playlist.ItemsGetter of the playlists of the given org.
+func (s *Service) itemsGetter(orgID int64) playlist.ItemsGetter {
+	return func(ctx context.Context, uid string) ([]playlist.PlaylistItem, error) {
+		return s.store.GetItems(ctx, &playlist.GetPlaylistItemsByUidQuery{PlaylistUID: uid, OrgId: orgID})
+	}
+}
+
 func (s *Service) GetWithoutItems(ctx context.Context, q *playlist.GetPlaylistByUidQuery) (*playlist.Playlist, error) {
 	ctx, span := s.tracer.Start(ctx, "playlists.GetWithoutItems")
 	defer span.End()
@@ -50,16 +182,48 @@ func (s *Service) Get(ctx context.Context, q *playlist.GetPlaylistByUidQuery) (*
 		return nil, err
 	}
 	rawItems, err := s.store.GetItems(ctx, &playlist.GetPlaylistItemsByUidQuery{
-		PlaylistUID: v.UID,
-		OrgId:       q.OrgId,
+		PlaylistUID:    v.UID,
+		OrgId:          q.OrgId,
+		IncludeTrashed: q.IncludeTrashed,
 	})
 	if err != nil {
 		return nil, err
 	}
+	return &playlist.PlaylistDTO{
+		Id:        v.Id,
+		Uid:       v.UID,
+		Name:      v.Name,
+		Interval:  v.Interval,
+		Items:     itemDTOs(rawItems),
+		Created:   timeFromMillis(v.CreatedAt),
+		Updated:   timeFromMillis(v.UpdatedAt),
+		CreatedAt: v.CreatedAt,
+		UpdatedAt: v.UpdatedAt,
+		OrgID:     v.OrgId,
+		DeletedAt: v.DeletedAt,
+	}, nil
+}
+
+func (s *Service) GetItemsByUIDs(ctx context.Context, q *playlist.GetPlaylistsItemsByUidsQuery) (map[string][]playlist.PlaylistItemDTO, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.GetItemsByUIDs")
+	defer span.End()
+	rawItems, err := s.store.GetItemsByUIDs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]playlist.PlaylistItemDTO, len(rawItems))
+	for uid, playlistItems := range rawItems {
+		items[uid] = itemDTOs(playlistItems)
+	}
+	return items, nil
+}
+
+func itemDTOs(rawItems []playlist.PlaylistItem) []playlist.PlaylistItemDTO {
 	items := make([]playlist.PlaylistItemDTO, len(rawItems))
 	for i := 0; i < len(rawItems); i++ {
 		items[i].Type = rawItems[i].Type
 		items[i].Value = rawItems[i].Value
+		items[i].Interval = rawItems[i].Interval
 
 		// Add the unused title to the result
 		title := rawItems[i].Title
@@ -67,16 +231,16 @@ func (s *Service) Get(ctx context.Context, q *playlist.GetPlaylistByUidQuery) (*
 			items[i].Title = &title
 		}
 	}
-	return &playlist.PlaylistDTO{
-		Id:        v.Id,
-		Uid:       v.UID,
-		Name:      v.Name,
-		Interval:  v.Interval,
-		Items:     items,
-		CreatedAt: v.CreatedAt,
-		UpdatedAt: v.UpdatedAt,
-		OrgID:     v.OrgId,
-	}, nil
+	return items
+}
+
+// timeFromMillis returns the time of a timestamp column, or nil for the playlists created before it was added.
+func timeFromMillis(ms int64) *time.Time {
+	if ms <= 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms).UTC()
+	return &t
 }
 
 func (s *Service) Search(ctx context.Context, q *playlist.GetPlaylistsQuery) (playlist.Playlists, error) {
@@ -85,8 +249,107 @@ func (s *Service) Search(ctx context.Context, q *playlist.GetPlaylistsQuery) (pl
 	return s.store.List(ctx, q)
 }
 
+func (s *Service) SearchCount(ctx context.Context, q *playlist.GetPlaylistsQuery) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.SearchCount")
+	defer span.End()
+	stats, err := s.store.ListStats(ctx, q)
+	return stats.Count, err
+}
+
+func (s *Service) SearchStats(ctx context.Context, q *playlist.GetPlaylistsQuery) (playlist.PlaylistSearchStats, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.SearchStats")
+	defer span.End()
+	return s.store.ListStats(ctx, q)
+}
+
 func (s *Service) Delete(ctx context.Context, cmd *playlist.DeletePlaylistCommand) error {
 	ctx, span := s.tracer.Start(ctx, "playlists.Delete")
 	defer span.End()
-	return s.store.Delete(ctx, cmd)
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if s.trashRetention > 0 {
+		return s.store.Trash(ctx, cmd)
+	}
+	if err := s.store.Delete(ctx, cmd); err != nil {
+		return err
+	}
+	// The deleted playlist no longer counts in the last update time of the org, so the deletion is recorded apart
+	return s.kv.Set(ctx, cmd.OrgId, lastDeletedNamespace, lastDeletedKey, strconv.FormatInt(s.now().UnixMilli(), 10))
+}
+
+func (s *Service) Restore(ctx context.Context, cmd *playlist.RestorePlaylistCommand) (*playlist.Playlist, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.Restore")
+	defer span.End()
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	// The playlists in the trash for longer than the retention are about to be purged, so they're not restored
+	if err := s.store.Restore(ctx, cmd, s.trashCutoff()); err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, &playlist.GetPlaylistByUidQuery{UID: cmd.UID, OrgId: cmd.OrgId})
+}
+
+func (s *Service) PurgeTrash(ctx context.Context) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.PurgeTrash")
+	defer span.End()
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+	deleted, err := s.store.DeleteTrashed(ctx, s.trashCutoff())
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	now := strconv.FormatInt(s.now().UnixMilli(), 10)
+	for orgID, count := range deleted {
+		purged += count
+		// Like the other deletions, the purge changes the playlists of the org listed with the trash
+		if err := s.kv.Set(ctx, orgID, lastDeletedNamespace, lastDeletedKey, now); err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// trashCutoff returns the time, in milliseconds, before which the playlists moved to the trash have expired.
+// All of them have when the trash is disabled, so the ones left from when it was enabled get purged.
+func (s *Service) trashCutoff() int64 {
+	if s.trashRetention <= 0 {
+		return s.now().UnixMilli() + 1
+	}
+	return s.now().Add(-s.trashRetention).UnixMilli()
+}
+
+const (
+	lastDeletedNamespace = "playlist"
+	lastDeletedKey       = "last_deleted_at"
+)
+
+func (s *Service) GetLastUpdated(ctx context.Context, q *playlist.GetLastUpdatedQuery) (int64, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.GetLastUpdated")
+	defer span.End()
+	lastUpdated, err := s.store.GetLastUpdated(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	value, ok, err := s.kv.Get(ctx, q.OrgId, lastDeletedNamespace, lastDeletedKey)
+	if err != nil || !ok {
+		return lastUpdated, err
+	}
+	lastDeleted, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if lastDeleted > lastUpdated {
+		return lastDeleted, nil
+	}
+	return lastUpdated, nil
+}
+
+func (s *Service) GetSizeDistribution(ctx context.Context, q *playlist.GetPlaylistSizeDistributionQuery) ([]playlist.PlaylistSizeCount, error) {
+	ctx, span := s.tracer.Start(ctx, "playlists.GetSizeDistribution")
+	defer span.End()
+	return s.store.GetSizeDistribution(ctx, q)
 }
@@ -0,0 +1,613 @@
+package playlistimpl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/db"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/quota/quotaimpl"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+func TestIntegrationPlaylistQuota(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	cfg.Quota.Enabled = true
+	cfg.Quota.Org.Playlist = 2
+	cfg.Quota.Global.Playlist = -1
+
+	quotaService := quotaimpl.ProvideService(ss, cfg)
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaService, cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	items := []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "graphite"}}
+	quotaReached := func(t *testing.T, orgID int64) bool {
+		t.Helper()
+		reached, err := quotaService.CheckQuotaReached(context.Background(), playlist.QuotaTargetSrv, &quota.ScopeParameters{OrgID: orgID})
+		require.NoError(t, err)
+		return reached
+	}
+
+	p, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "first", Interval: "5m", OrgId: 1, Items: items})
+	require.NoError(t, err)
+	require.False(t, quotaReached(t, 1), "creation under quota should be allowed")
+
+	_, err = svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "second", Interval: "5m", OrgId: 1, Items: items})
+	require.NoError(t, err)
+	require.True(t, quotaReached(t, 1), "creation at quota should be rejected")
+	require.False(t, quotaReached(t, 2), "quota should be enforced per org")
+
+	require.NoError(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: 1}))
+	require.False(t, quotaReached(t, 1), "deletions should free up quota")
+}
+
+func TestIntegrationPlaylistExternalURLItems(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	cfg.Playlist.ExternalURLAllowedSchemes = []string{"https"}
+	cfg.Playlist.ExternalURLAllowedHosts = []string{"status.example.com"}
+
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	create := func(value string) (*playlist.Playlist, error) {
+		return svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: []playlist.PlaylistItem{
+			{Type: "dashboard_by_uid", Value: "abc"},
+			{Type: playlist.ItemTypeExternalURL, Value: value},
+		}})
+	}
+
+	t.Run("Allowed external URLs are stored as they are", func(t *testing.T) {
+		p, err := create("https://status.example.com/board?refresh=1")
+		require.NoError(t, err)
+
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+		require.NoError(t, err)
+		require.Len(t, dto.Items, 2)
+		require.Equal(t, playlist.ItemTypeExternalURL, dto.Items[1].Type)
+		require.Equal(t, "https://status.example.com/board?refresh=1", dto.Items[1].Value)
+	})
+
+	t.Run("Disallowed schemes are rejected", func(t *testing.T) {
+		for _, value := range []string{"http://status.example.com/board", "javascript://status.example.com/%0Aalert(1)"} {
+			_, err := create(value)
+			require.ErrorIs(t, err, playlist.ErrExternalURLNotAllowed, value)
+		}
+	})
+
+	t.Run("Disallowed hosts are rejected", func(t *testing.T) {
+		for _, value := range []string{"https://evil.example.com/board", "https://status.example.com.evil.com/", "/relative/path"} {
+			_, err := create(value)
+			require.ErrorIs(t, err, playlist.ErrExternalURLNotAllowed, value)
+		}
+	})
+
+	t.Run("Updates are validated too", func(t *testing.T) {
+		p, err := create("https://status.example.com/")
+		require.NoError(t, err)
+
+		_, err = svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "wallboard", Interval: "5m", OrgId: 1, Items: []playlist.PlaylistItem{
+			{Type: playlist.ItemTypeExternalURL, Value: "https://evil.example.com/"},
+		}})
+		require.ErrorIs(t, err, playlist.ErrExternalURLNotAllowed)
+	})
+}
+
+func TestIntegrationPlaylistIntervals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	items := []playlist.PlaylistItem{{Type: "dashboard_by_uid", Value: "abc"}}
+	create := func(interval string) (*playlist.Playlist, error) {
+		return svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: interval, OrgId: 1, Items: items})
+	}
+
+	t.Run("Valid intervals are stored as they are", func(t *testing.T) {
+		for _, interval := range []string{"5m", "30s", "1h30m", "1d"} {
+			p, err := create(interval)
+			require.NoError(t, err, interval)
+			require.Equal(t, interval, p.Interval)
+		}
+	})
+
+	t.Run("Invalid intervals are rejected", func(t *testing.T) {
+		for _, interval := range []string{"soon", "5", "-5m", "0s"} {
+			_, err := create(interval)
+			require.ErrorIs(t, err, playlist.ErrInvalidInterval, interval)
+		}
+	})
+
+	t.Run("Empty intervals default to 5m", func(t *testing.T) {
+		p, err := create("")
+		require.NoError(t, err)
+		require.Equal(t, playlist.DefaultInterval, p.Interval)
+
+		dto, err := svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "wallboard", OrgId: 1, Items: items})
+		require.NoError(t, err)
+		require.Equal(t, playlist.DefaultInterval, dto.Interval)
+	})
+
+	t.Run("Updates are validated too", func(t *testing.T) {
+		p, err := create("5m")
+		require.NoError(t, err)
+		_, err = svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "wallboard", Interval: "soon", OrgId: 1, Items: items})
+		require.ErrorIs(t, err, playlist.ErrInvalidInterval)
+	})
+}
+
+func TestIntegrationPlaylistUIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	items := []playlist.PlaylistItem{{Type: "dashboard_by_uid", Value: "abc"}}
+	create := func(uid string) (*playlist.Playlist, error) {
+		return svc.Create(context.Background(), &playlist.CreatePlaylistCommand{UID: uid, Name: "wallboard", Interval: "5m", OrgId: 1, Items: items})
+	}
+
+	t.Run("Custom UIDs are used instead of generated ones", func(t *testing.T) {
+		p, err := create("office-wallboard_1")
+		require.NoError(t, err)
+		require.Equal(t, "office-wallboard_1", p.UID)
+
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: "office-wallboard_1", OrgId: 1})
+		require.NoError(t, err)
+		require.Equal(t, "wallboard", dto.Name)
+	})
+
+	t.Run("Invalid UIDs are rejected", func(t *testing.T) {
+		for _, uid := range []string{"office wallboard", "wallboard.v2", strings.Repeat("a", util.MaxUIDLength+1)} {
+			_, err := create(uid)
+			require.ErrorIs(t, err, playlist.ErrInvalidPlaylistUID, uid)
+		}
+	})
+
+	t.Run("Duplicate UIDs are rejected", func(t *testing.T) {
+		_, err := create("lobby")
+		require.NoError(t, err)
+		_, err = create("lobby")
+		require.ErrorIs(t, err, playlist.ErrPlaylistAlreadyExists)
+	})
+}
+
+func TestIntegrationPlaylistItemIntervals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	create := func(interval string) (*playlist.Playlist, error) {
+		return svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: []playlist.PlaylistItem{
+			{Type: "dashboard_by_uid", Value: "abc", Interval: interval},
+			{Type: "dashboard_by_uid", Value: "def"},
+		}})
+	}
+
+	t.Run("Item intervals override the playlist interval", func(t *testing.T) {
+		p, err := create("30s")
+		require.NoError(t, err)
+
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+		require.NoError(t, err)
+		require.Len(t, dto.Items, 2)
+		require.Equal(t, "30s", dto.Items[0].Interval)
+		require.Equal(t, "30s", dto.ItemInterval(dto.Items[0]))
+		require.Empty(t, dto.Items[1].Interval)
+		require.Equal(t, "5m", dto.ItemInterval(dto.Items[1]))
+	})
+
+	t.Run("Invalid item intervals are rejected", func(t *testing.T) {
+		for _, interval := range []string{"soon", "-1m", "0s"} {
+			_, err := create(interval)
+			require.ErrorIs(t, err, playlist.ErrInvalidItemInterval, interval)
+		}
+	})
+
+	t.Run("Updates are validated too", func(t *testing.T) {
+		p, err := create("")
+		require.NoError(t, err)
+
+		_, err = svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "wallboard", Interval: "5m", OrgId: 1, Items: []playlist.PlaylistItem{
+			{Type: "dashboard_by_uid", Value: "abc", Interval: "soon"},
+		}})
+		require.ErrorIs(t, err, playlist.ErrInvalidItemInterval)
+	})
+}
+
+func TestIntegrationPlaylistRecentlyViewedItems(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	create := func(value string) (*playlist.Playlist, error) {
+		return svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: []playlist.PlaylistItem{
+			{Type: "dashboard_by_uid", Value: "abc"},
+			{Type: playlist.ItemTypeRecentlyViewed, Value: value},
+		}})
+	}
+
+	t.Run("The number of recently viewed dashboards is stored", func(t *testing.T) {
+		p, err := create("5")
+		require.NoError(t, err)
+
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+		require.NoError(t, err)
+		require.Len(t, dto.Items, 2)
+		require.Equal(t, playlist.ItemTypeRecentlyViewed, dto.Items[1].Type)
+		require.Equal(t, "5", dto.Items[1].Value)
+	})
+
+	t.Run("Invalid numbers of recently viewed dashboards are rejected", func(t *testing.T) {
+		for _, value := range []string{"", "many", "0", "-3", "1.5"} {
+			_, err := create(value)
+			require.ErrorIs(t, err, playlist.ErrInvalidRecentlyViewed, value)
+		}
+	})
+}
+
+func TestIntegrationPlaylistSectionItems(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	create := func(items ...playlist.PlaylistItem) (*playlist.Playlist, error) {
+		return svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: items})
+	}
+
+	t.Run("The sections are stored in order", func(t *testing.T) {
+		items := []playlist.PlaylistItem{
+			{Type: playlist.ItemTypeSection, Value: "Prod"},
+			{Type: "dashboard_by_uid", Value: "prod-overview"},
+			{Type: "dashboard_by_tag", Value: "prod"},
+			{Type: playlist.ItemTypeSection, Value: "Staging"},
+			{Type: "dashboard_by_uid", Value: "staging-overview"},
+		}
+		p, err := create(items...)
+		require.NoError(t, err)
+
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+		require.NoError(t, err)
+		require.Len(t, dto.Items, len(items))
+		for i, item := range items {
+			require.Equal(t, item.Type, dto.Items[i].Type)
+			require.Equal(t, item.Value, dto.Items[i].Value)
+		}
+	})
+
+	t.Run("Invalid section labels are rejected", func(t *testing.T) {
+		for _, label := range []string{"", "   ", strings.Repeat("a", playlist.MaxSectionLabelLength+1), "Prod\nStaging"} {
+			_, err := create(playlist.PlaylistItem{Type: playlist.ItemTypeSection, Value: label})
+			require.ErrorIs(t, err, playlist.ErrInvalidSectionLabel, label)
+		}
+		_, err := create(playlist.PlaylistItem{Type: playlist.ItemTypeSection, Value: strings.Repeat("é", playlist.MaxSectionLabelLength)})
+		require.NoError(t, err)
+	})
+}
+
+func TestIntegrationPlaylistDedupe(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	items := []playlist.PlaylistItem{
+		{Type: "dashboard_by_uid", Value: "overview"},
+		{Type: "dashboard_by_tag", Value: "overview"},
+		{Type: "dashboard_by_uid", Value: "details"},
+		{Type: "dashboard_by_uid", Value: "overview", Interval: "1m"},
+		{Type: "dashboard_by_tag", Value: "overview"},
+	}
+	requireItems := func(t *testing.T, uid string, expected ...playlist.PlaylistItem) {
+		t.Helper()
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: uid, OrgId: 1})
+		require.NoError(t, err)
+		require.Len(t, dto.Items, len(expected))
+		for i, item := range expected {
+			require.Equal(t, item.Type, dto.Items[i].Type)
+			require.Equal(t, item.Value, dto.Items[i].Value)
+			require.Equal(t, item.Interval, dto.Items[i].Interval)
+		}
+	}
+
+	t.Run("The first occurrences are kept in order", func(t *testing.T) {
+		p, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: items, Dedupe: true})
+		require.NoError(t, err)
+		requireItems(t, p.UID, items[0], items[1], items[2])
+	})
+
+	t.Run("Duplicates are kept without dedupe", func(t *testing.T) {
+		p, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: items})
+		require.NoError(t, err)
+		requireItems(t, p.UID, items...)
+	})
+
+	t.Run("Updates are deduplicated too", func(t *testing.T) {
+		p, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "wallboard", Interval: "5m", OrgId: 1, Items: items[:1]})
+		require.NoError(t, err)
+		_, err = svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "wallboard", Interval: "5m", OrgId: 1, Items: items, Dedupe: true})
+		require.NoError(t, err)
+		requireItems(t, p.UID, items[0], items[1], items[2])
+	})
+}
+
+func TestIntegrationPlaylistRefs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	cfg.AppURL = "https://grafana.example.com/"
+	cfg.Playlist.ExternalURLAllowedSchemes = []string{"https"}
+	cfg.Playlist.ExternalURLAllowedHosts = []string{"grafana.example.com"}
+
+	svc, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+
+	play := func(uid string) playlist.PlaylistItem {
+		return playlist.PlaylistItem{Type: playlist.ItemTypeExternalURL, Value: "https://grafana.example.com/playlists/play/" + uid + "?kiosk=true"}
+	}
+	create := func(uid string, items ...playlist.PlaylistItem) error {
+		items = append([]playlist.PlaylistItem{{Type: "dashboard_by_uid", Value: "abc"}}, items...)
+		_, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{UID: uid, Name: uid, Interval: "5m", OrgId: 1, Items: items})
+		return err
+	}
+	update := func(uid string, items ...playlist.PlaylistItem) error {
+		_, err := svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: uid, Name: uid, Interval: "5m", OrgId: 1, Items: items})
+		return err
+	}
+
+	t.Run("Self references are rejected", func(t *testing.T) {
+		require.NoError(t, create("self"))
+		err := update("self", play("self"))
+		require.ErrorIs(t, err, playlist.ErrInvalidPlaylistRef)
+		require.ErrorContains(t, err, `playlist "self" references itself`)
+	})
+
+	t.Run("Cycles are rejected", func(t *testing.T) {
+		require.NoError(t, create("first"))
+		require.NoError(t, create("second", play("first")))
+		err := update("first", play("second"))
+		require.ErrorIs(t, err, playlist.ErrInvalidPlaylistRef)
+		require.ErrorContains(t, err, "playlists first -> second -> first form a cycle")
+	})
+
+	t.Run("Nested references are allowed", func(t *testing.T) {
+		require.NoError(t, create("leaf"))
+		require.NoError(t, create("middle", play("leaf")))
+		require.NoError(t, create("root", play("middle"), play("leaf"), play("missing")))
+		// URLs of other instances aren't references
+		require.NoError(t, create("other", playlist.PlaylistItem{Type: playlist.ItemTypeExternalURL, Value: "https://grafana.example.com/d/other"}))
+	})
+
+	t.Run("Deeply nested references are rejected", func(t *testing.T) {
+		require.NoError(t, create("nested-0"))
+		for i := 1; i < playlist.MaxRefDepth; i++ {
+			require.NoError(t, create(fmt.Sprintf("nested-%d", i), play(fmt.Sprintf("nested-%d", i-1))))
+		}
+		err := create("too-deep", play(fmt.Sprintf("nested-%d", playlist.MaxRefDepth-1)))
+		require.ErrorIs(t, err, playlist.ErrInvalidPlaylistRef)
+	})
+}
+
+func TestIntegrationPlaylistTrash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	cfg.Playlist.TrashRetention = 24 * time.Hour
+	provided, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	svc := provided.(*Service)
+
+	items := []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "graphite"}}
+	create := func(t *testing.T, name string) *playlist.Playlist {
+		t.Helper()
+		p, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: name, Interval: "5m", OrgId: 1, Items: items})
+		require.NoError(t, err)
+		return p
+	}
+	search := func(t *testing.T, includeTrashed bool) []string {
+		t.Helper()
+		res, err := svc.Search(context.Background(), &playlist.GetPlaylistsQuery{OrgId: 1, Limit: 10, IncludeTrashed: includeTrashed})
+		require.NoError(t, err)
+		uids := []string{}
+		for _, p := range res {
+			uids = append(uids, p.UID)
+		}
+		return uids
+	}
+
+	t.Run("Deleted playlists can be restored", func(t *testing.T) {
+		p := create(t, "restored")
+		require.NoError(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: 1}))
+
+		_, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+		require.NotContains(t, search(t, false), p.UID)
+		require.Contains(t, search(t, true), p.UID)
+		trashed, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1, IncludeTrashed: true})
+		require.NoError(t, err)
+		require.NotZero(t, trashed.DeletedAt)
+		require.Len(t, trashed.Items, 1)
+
+		// Playlists are restored by the org they belong to
+		_, err = svc.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: 2})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+
+		restored, err := svc.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: 1})
+		require.NoError(t, err)
+		require.Equal(t, p.Id, restored.Id)
+		require.Zero(t, restored.DeletedAt)
+		dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+		require.NoError(t, err)
+		require.Len(t, dto.Items, 1)
+		require.Contains(t, search(t, false), p.UID)
+
+		// Only the playlists in the trash are restored
+		_, err = svc.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: 1})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+	})
+
+	t.Run("Deleted playlists are purged after the retention", func(t *testing.T) {
+		p := create(t, "purged")
+		require.NoError(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: 1}))
+
+		purged, err := svc.PurgeTrash(context.Background())
+		require.NoError(t, err)
+		require.Zero(t, purged)
+		require.Contains(t, search(t, true), p.UID)
+
+		purgedAt := time.Now().Add(25 * time.Hour)
+		svc.now = func() time.Time { return purgedAt }
+		t.Cleanup(func() { svc.now = time.Now })
+		// The expired playlists can't be restored anymore, even before they're purged
+		_, err = svc.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: 1})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+
+		purged, err = svc.PurgeTrash(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(1), purged)
+		require.NotContains(t, search(t, true), p.UID)
+		// The purge counts as a change of the playlists of the org
+		lastUpdated, err := svc.GetLastUpdated(context.Background(), &playlist.GetLastUpdatedQuery{OrgId: 1})
+		require.NoError(t, err)
+		require.Equal(t, purgedAt.UnixMilli(), lastUpdated)
+		_, err = svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1, IncludeTrashed: true})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+	})
+
+	t.Run("Playlists are deleted right away without retention", func(t *testing.T) {
+		svc.trashRetention = 0
+		t.Cleanup(func() { svc.trashRetention = cfg.Playlist.TrashRetention })
+		p := create(t, "deleted")
+		require.NoError(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: 1}))
+
+		require.NotContains(t, search(t, true), p.UID)
+		_, err := svc.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: 1})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+	})
+}
+
+func TestIntegrationPlaylistMaintenanceMode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	cfg.Playlist.TrashRetention = time.Hour
+	provided, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	svc := provided.(*Service)
+
+	items := []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "graphite"}}
+	p, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "first", Interval: "5m", OrgId: 1, Items: items})
+	require.NoError(t, err)
+
+	svc.features = featuremgmt.WithFeatures(featuremgmt.FlagPlaylistMaintenanceMode)
+	_, err = svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "second", Interval: "5m", OrgId: 1, Items: items})
+	require.ErrorIs(t, err, playlist.ErrMaintenanceMode)
+	_, err = svc.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "renamed", Interval: "5m", OrgId: 1, Items: items})
+	require.ErrorIs(t, err, playlist.ErrMaintenanceMode)
+	require.ErrorIs(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: 1}), playlist.ErrMaintenanceMode)
+	_, err = svc.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: 1})
+	require.ErrorIs(t, err, playlist.ErrMaintenanceMode)
+	_, err = svc.PurgeTrash(context.Background())
+	require.ErrorIs(t, err, playlist.ErrMaintenanceMode)
+
+	// Reads still succeed, and nothing was changed
+	dto, err := svc.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: 1})
+	require.NoError(t, err)
+	require.Equal(t, "first", dto.Name)
+
+	svc.features = featuremgmt.WithFeatures()
+	require.NoError(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: 1}))
+}
+
+func TestIntegrationPlaylistLastUpdated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	ss := db.InitTestDB(t)
+	cfg := setting.NewCfg()
+	provided, err := ProvideService(ss, tracing.InitializeTracerForTest(), quotaimpl.ProvideService(ss, cfg), cfg, featuremgmt.WithFeatures())
+	require.NoError(t, err)
+	svc := provided.(*Service)
+	lastUpdated := func(t *testing.T) int64 {
+		t.Helper()
+		lastUpdated, err := svc.GetLastUpdated(context.Background(), &playlist.GetLastUpdatedQuery{OrgId: 1})
+		require.NoError(t, err)
+		return lastUpdated
+	}
+
+	items := []playlist.PlaylistItem{{Type: "dashboard_by_tag", Value: "graphite"}}
+	first, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "first", Interval: "5m", OrgId: 1, Items: items})
+	require.NoError(t, err)
+	second, err := svc.Create(context.Background(), &playlist.CreatePlaylistCommand{Name: "second", Interval: "5m", OrgId: 1, Items: items})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, second.UpdatedAt, first.UpdatedAt)
+	require.Equal(t, second.UpdatedAt, lastUpdated(t))
+
+	// Deleting the most recently updated playlist would take the last update time back, so the deletion counts instead
+	deleted := time.Now().Add(time.Minute)
+	svc.now = func() time.Time { return deleted }
+	t.Cleanup(func() { svc.now = time.Now })
+	require.NoError(t, svc.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: second.UID, OrgId: 1}))
+	require.Equal(t, deleted.UnixMilli(), lastUpdated(t))
+
+	// The deletions of other orgs don't count
+	other, err := svc.GetLastUpdated(context.Background(), &playlist.GetLastUpdatedQuery{OrgId: 2})
+	require.NoError(t, err)
+	require.Zero(t, other)
+}
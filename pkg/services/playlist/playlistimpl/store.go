@@ -4,13 +4,29 @@ import (
 	"context"
 
 	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/quota"
 )
 
 type store interface {
 	Insert(context.Context, *playlist.CreatePlaylistCommand) (*playlist.Playlist, error)
 	Delete(context.Context, *playlist.DeletePlaylistCommand) error
+	// Trash moves a playlist to the trash, setting its deletion time.
+	Trash(context.Context, *playlist.DeletePlaylistCommand) error
+	// Restore moves a playlist out of the trash, if it was moved there after trashedAfter, in milliseconds.
+	Restore(ctx context.Context, cmd *playlist.RestorePlaylistCommand, trashedAfter int64) error
+	// DeleteTrashed deletes the playlists moved to the trash before trashedBefore, in milliseconds, and returns their
+	// number per org.
+	DeleteTrashed(ctx context.Context, trashedBefore int64) (map[int64]int64, error)
 	Get(context.Context, *playlist.GetPlaylistByUidQuery) (*playlist.Playlist, error)
 	GetItems(context.Context, *playlist.GetPlaylistItemsByUidQuery) ([]playlist.PlaylistItem, error)
+	// GetItemsByUIDs returns the items of several playlists in their persisted order, keyed by playlist UID.
+	GetItemsByUIDs(context.Context, *playlist.GetPlaylistsItemsByUidsQuery) (map[string][]playlist.PlaylistItem, error)
 	List(context.Context, *playlist.GetPlaylistsQuery) (playlist.Playlists, error)
+	// ListStats returns the number and last update time of the playlists matching the query, ignoring its limit and page.
+	ListStats(context.Context, *playlist.GetPlaylistsQuery) (playlist.PlaylistSearchStats, error)
 	Update(context.Context, *playlist.UpdatePlaylistCommand) (*playlist.PlaylistDTO, error)
+	// GetLastUpdated returns the most recent update time of the playlists of the org, in milliseconds, or zero if it has none.
+	GetLastUpdated(context.Context, *playlist.GetLastUpdatedQuery) (int64, error)
+	GetSizeDistribution(context.Context, *playlist.GetPlaylistSizeDistributionQuery) ([]playlist.PlaylistSizeCount, error)
+	Count(context.Context, *quota.ScopeParameters) (*quota.Map, error)
 }
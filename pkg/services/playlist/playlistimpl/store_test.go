@@ -2,6 +2,8 @@ package playlistimpl
 
 import (
 	"context"
+	"encoding/json"
+	"regexp"
 	"testing"
 	"time"
 
@@ -90,6 +92,14 @@ func testIntegrationPlaylistDataAccess(t *testing.T, fn getStore) {
 		require.NoError(t, err)
 		require.Equal(t, "abcd", p.UID)
 
+		// Should get a conflict with the same UID in the same org, but not in another one
+		_, err = playlistStore.Insert(context.Background(), &cmd)
+		require.ErrorIs(t, err, playlist.ErrPlaylistAlreadyExists)
+		otherOrg := cmd
+		otherOrg.OrgId = 2
+		_, err = playlistStore.Insert(context.Background(), &otherOrg)
+		require.NoError(t, err)
+
 		// Should get an error with an invalid UID
 		cmd.UID = "invalid uid"
 		_, err = playlistStore.Insert(context.Background(), &cmd)
@@ -101,6 +111,11 @@ func testIntegrationPlaylistDataAccess(t *testing.T, fn getStore) {
 			UID:   "abcd",
 		})
 		require.NoError(t, err)
+		err = playlistStore.Delete(context.Background(), &playlist.DeletePlaylistCommand{
+			OrgId: 2,
+			UID:   "abcd",
+		})
+		require.NoError(t, err)
 	})
 
 	t.Run("Search playlist", func(t *testing.T) {
@@ -111,9 +126,9 @@ func testIntegrationPlaylistDataAccess(t *testing.T, fn getStore) {
 		pl1 := playlist.CreatePlaylistCommand{Name: "NYC office", Interval: "10m", OrgId: 1, Items: items}
 		pl2 := playlist.CreatePlaylistCommand{Name: "NICE office", Interval: "10m", OrgId: 1, Items: items}
 		pl3 := playlist.CreatePlaylistCommand{Name: "NICE office", Interval: "10m", OrgId: 2, Items: items}
-		_, err := playlistStore.Insert(context.Background(), &pl1)
+		nyc, err := playlistStore.Insert(context.Background(), &pl1)
 		require.NoError(t, err)
-		_, err = playlistStore.Insert(context.Background(), &pl2)
+		nice, err := playlistStore.Insert(context.Background(), &pl2)
 		require.NoError(t, err)
 		_, err = playlistStore.Insert(context.Background(), &pl3)
 		require.NoError(t, err)
@@ -137,6 +152,289 @@ func testIntegrationPlaylistDataAccess(t *testing.T, fn getStore) {
 			require.NoError(t, err)
 			require.Equal(t, 2, len(res))
 		})
+		t.Run("With Page", func(t *testing.T) {
+			names := []string{}
+			for page := 1; page <= 3; page++ {
+				qr := playlist.GetPlaylistsQuery{Limit: 1, Page: page, Name: "office", OrgId: 1}
+				res, err := playlistStore.List(context.Background(), &qr)
+				require.NoError(t, err)
+				for _, p := range res {
+					names = append(names, p.Name)
+				}
+			}
+			require.Equal(t, []string{"NYC office", "NICE office"}, names)
+		})
+		t.Run("Stats", func(t *testing.T) {
+			stats, err := playlistStore.ListStats(context.Background(), &playlist.GetPlaylistsQuery{Limit: 1, Name: "office", OrgId: 1})
+			require.NoError(t, err)
+			require.Equal(t, int64(2), stats.Count)
+			require.Equal(t, nice.UpdatedAt, stats.LastUpdated)
+
+			stats, err = playlistStore.ListStats(context.Background(), &playlist.GetPlaylistsQuery{Name: "NYC", OrgId: 1})
+			require.NoError(t, err)
+			require.Equal(t, int64(1), stats.Count)
+			require.Equal(t, nyc.UpdatedAt, stats.LastUpdated)
+
+			stats, err = playlistStore.ListStats(context.Background(), &playlist.GetPlaylistsQuery{NameRegex: regexp.MustCompile("^NYC"), OrgId: 1})
+			require.NoError(t, err)
+			require.Equal(t, playlist.PlaylistSearchStats{Count: 1, LastUpdated: nyc.UpdatedAt}, stats)
+
+			stats, err = playlistStore.ListStats(context.Background(), &playlist.GetPlaylistsQuery{Name: "Paris", OrgId: 1})
+			require.NoError(t, err)
+			require.Equal(t, playlist.PlaylistSearchStats{}, stats)
+		})
+		t.Run("With Item Type", func(t *testing.T) {
+			const orgID = 30
+			for name, typ := range map[string]string{"Tags": "dashboard_by_tag", "UIDs": "dashboard_by_uid"} {
+				_, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{
+					Name: name, Interval: "10m", OrgId: orgID,
+					Items: []playlist.PlaylistItem{{Value: "value", Type: typ}, {Value: "value", Type: typ}},
+				})
+				require.NoError(t, err)
+			}
+
+			for itemType, expected := range map[string][]string{
+				"":                 {"Tags", "UIDs"},
+				"dashboard_by_tag": {"Tags"},
+				"dashboard_by_uid": {"UIDs"},
+				"dashboard_by_id":  {},
+			} {
+				qr := playlist.GetPlaylistsQuery{Limit: 100, ItemType: itemType, OrgId: orgID}
+				res, err := playlistStore.List(context.Background(), &qr)
+				require.NoError(t, err)
+				names := []string{}
+				for _, p := range res {
+					names = append(names, p.Name)
+				}
+				require.ElementsMatch(t, expected, names, itemType)
+
+				stats, err := playlistStore.ListStats(context.Background(), &qr)
+				require.NoError(t, err)
+				require.Equal(t, int64(len(expected)), stats.Count, itemType)
+			}
+		})
+		t.Run("With Name Regex", func(t *testing.T) {
+			const orgID = 31
+			for _, name := range []string{"team-a-prod", "team-b-prod", "team-a-dev", "my-team-c-prod", "team-d-prod-old"} {
+				_, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{
+					Name: name, Interval: "10m", OrgId: orgID,
+					Items: []playlist.PlaylistItem{{Value: "value", Type: "dashboard_by_tag"}},
+				})
+				require.NoError(t, err)
+			}
+
+			for pattern, expected := range map[string][]string{
+				`^team-.*-prod$`: {"team-a-prod", "team-b-prod"},
+				`team-.*-prod`:   {"my-team-c-prod", "team-a-prod", "team-b-prod", "team-d-prod-old"},
+				`^TEAM-A`:        {},
+				`(?i)^TEAM-A`:    {"team-a-dev", "team-a-prod"},
+			} {
+				qr := playlist.GetPlaylistsQuery{Limit: 100, NameRegex: regexp.MustCompile(pattern), Sort: playlist.SortNameAsc, OrgId: orgID}
+				res, err := playlistStore.List(context.Background(), &qr)
+				require.NoError(t, err)
+				names := []string{}
+				for _, p := range res {
+					names = append(names, p.Name)
+				}
+				require.Equal(t, expected, names, pattern)
+
+				stats, err := playlistStore.ListStats(context.Background(), &qr)
+				require.NoError(t, err)
+				require.Equal(t, int64(len(expected)), stats.Count, pattern)
+			}
+
+			// The matching playlists are paginated
+			names := []string{}
+			for page := 1; page <= 3; page++ {
+				qr := playlist.GetPlaylistsQuery{Limit: 1, Page: page, NameRegex: regexp.MustCompile(`^team-.*-prod$`), Sort: playlist.SortNameAsc, OrgId: orgID}
+				res, err := playlistStore.List(context.Background(), &qr)
+				require.NoError(t, err)
+				for _, p := range res {
+					names = append(names, p.Name)
+				}
+			}
+			require.Equal(t, []string{"team-a-prod", "team-b-prod"}, names)
+		})
+	})
+
+	t.Run("Get last updated", func(t *testing.T) {
+		const orgID = 20
+		lastUpdated, err := playlistStore.GetLastUpdated(context.Background(), &playlist.GetLastUpdatedQuery{OrgId: orgID})
+		require.NoError(t, err)
+		require.Zero(t, lastUpdated)
+
+		items := []playlist.PlaylistItem{{Title: "graphite", Value: "graphite", Type: "dashboard_by_tag"}}
+		p, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "first", Interval: "10m", OrgId: orgID, Items: items})
+		require.NoError(t, err)
+		_, err = playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "second", Interval: "10m", OrgId: orgID, Items: items})
+		require.NoError(t, err)
+
+		time.Sleep(2 * time.Millisecond)
+		_, err = playlistStore.Update(context.Background(), &playlist.UpdatePlaylistCommand{UID: p.UID, Name: "first", Interval: "5m", OrgId: orgID, Items: items})
+		require.NoError(t, err)
+		updated, err := playlistStore.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID})
+		require.NoError(t, err)
+		require.Greater(t, updated.UpdatedAt, p.UpdatedAt)
+
+		lastUpdated, err = playlistStore.GetLastUpdated(context.Background(), &playlist.GetLastUpdatedQuery{OrgId: orgID})
+		require.NoError(t, err)
+		require.Equal(t, updated.UpdatedAt, lastUpdated)
+	})
+
+	t.Run("Get size distribution", func(t *testing.T) {
+		const orgID = 10
+		for _, size := range []int{1, 3, 3, 1, 5, 1} {
+			items := make([]playlist.PlaylistItem, size)
+			for i := range items {
+				items[i] = playlist.PlaylistItem{Title: "graphite", Value: "graphite", Type: "dashboard_by_tag"}
+			}
+			_, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "sized", Interval: "10m", OrgId: orgID, Items: items})
+			require.NoError(t, err)
+		}
+
+		sizes, err := playlistStore.GetSizeDistribution(context.Background(), &playlist.GetPlaylistSizeDistributionQuery{OrgId: orgID})
+		require.NoError(t, err)
+		require.Equal(t, []playlist.PlaylistSizeCount{
+			{Items: 1, Playlists: 3},
+			{Items: 3, Playlists: 2},
+			{Items: 5, Playlists: 1},
+		}, sizes)
+
+		sizes, err = playlistStore.GetSizeDistribution(context.Background(), &playlist.GetPlaylistSizeDistributionQuery{OrgId: orgID + 1})
+		require.NoError(t, err)
+		require.Empty(t, sizes)
+
+		_, err = playlistStore.GetSizeDistribution(context.Background(), &playlist.GetPlaylistSizeDistributionQuery{})
+		require.ErrorIs(t, err, playlist.ErrCommandValidationFailed)
+	})
+
+	t.Run("Get items in persisted order", func(t *testing.T) {
+		const orgID = 30
+		items := []playlist.PlaylistItem{
+			{Title: "first", Value: "1", Type: "dashboard_by_id"},
+			{Title: "second", Value: "2", Type: "dashboard_by_id"},
+			{Title: "third", Value: "3", Type: "dashboard_by_id"},
+		}
+		p, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "ordered", Interval: "10m", OrgId: orgID, Items: items})
+		require.NoError(t, err)
+
+		// Reverse the persisted order, so it no longer follows the insertion order
+		err = ss.WithDbSession(context.Background(), func(sess *db.Session) error {
+			_, err := sess.Exec("UPDATE playlist_item SET "+ss.GetDialect().Quote("order")+" = 4 - "+ss.GetDialect().Quote("order")+" WHERE playlist_id = ?", p.Id)
+			return err
+		})
+		require.NoError(t, err)
+
+		var first []byte
+		for i := 0; i < 5; i++ {
+			stored, err := playlistStore.GetItems(context.Background(), &playlist.GetPlaylistItemsByUidQuery{PlaylistUID: p.UID, OrgId: orgID})
+			require.NoError(t, err)
+			titles := make([]string, len(stored))
+			for j, item := range stored {
+				titles[j] = item.Title
+			}
+			require.Equal(t, []string{"third", "second", "first"}, titles)
+
+			b, err := json.Marshal(stored)
+			require.NoError(t, err)
+			if first == nil {
+				first = b
+			}
+			require.Equal(t, string(first), string(b))
+		}
+	})
+
+	t.Run("Get items of several playlists", func(t *testing.T) {
+		const orgID = 35
+		first, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "first", Interval: "10m", OrgId: orgID, Items: []playlist.PlaylistItem{
+			{Title: "a", Value: "1", Type: "dashboard_by_id"},
+			{Title: "b", Value: "2", Type: "dashboard_by_id"},
+		}})
+		require.NoError(t, err)
+		second, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "second", Interval: "10m", OrgId: orgID, Items: []playlist.PlaylistItem{
+			{Title: "c", Value: "graphite", Type: "dashboard_by_tag"},
+		}})
+		require.NoError(t, err)
+		require.NoError(t, playlistStore.Trash(context.Background(), &playlist.DeletePlaylistCommand{UID: second.UID, OrgId: orgID}))
+
+		titles := func(items []playlist.PlaylistItem) []string {
+			res := []string{}
+			for _, item := range items {
+				res = append(res, item.Title)
+			}
+			return res
+		}
+		query := &playlist.GetPlaylistsItemsByUidsQuery{PlaylistUIDs: []string{first.UID, second.UID, "missing"}, OrgId: orgID}
+		items, err := playlistStore.GetItemsByUIDs(context.Background(), query)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		require.Equal(t, []string{"a", "b"}, titles(items[first.UID]))
+
+		query.IncludeTrashed = true
+		items, err = playlistStore.GetItemsByUIDs(context.Background(), query)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		require.Equal(t, []string{"c"}, titles(items[second.UID]))
+
+		items, err = playlistStore.GetItemsByUIDs(context.Background(), &playlist.GetPlaylistsItemsByUidsQuery{PlaylistUIDs: []string{first.UID}, OrgId: orgID + 1})
+		require.NoError(t, err)
+		require.Empty(t, items)
+
+		_, err = playlistStore.GetItemsByUIDs(context.Background(), &playlist.GetPlaylistsItemsByUidsQuery{PlaylistUIDs: []string{first.UID}})
+		require.ErrorIs(t, err, playlist.ErrCommandValidationFailed)
+
+		// The trashed playlist is deleted, so it's not purged with the ones of the next tests
+		require.NoError(t, playlistStore.Delete(context.Background(), &playlist.DeletePlaylistCommand{UID: second.UID, OrgId: orgID}))
+	})
+
+	t.Run("Trash playlist", func(t *testing.T) {
+		const orgID = 40
+		items := []playlist.PlaylistItem{{Title: "graphite", Value: "graphite", Type: "dashboard_by_tag"}}
+		p, err := playlistStore.Insert(context.Background(), &playlist.CreatePlaylistCommand{Name: "trashed", Interval: "10m", OrgId: orgID, Items: items})
+		require.NoError(t, err)
+		require.NoError(t, playlistStore.Trash(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: orgID}))
+
+		_, err = playlistStore.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+		trashed, err := playlistStore.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID, IncludeTrashed: true})
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, trashed.DeletedAt, p.CreatedAt)
+
+		stats, err := playlistStore.ListStats(context.Background(), &playlist.GetPlaylistsQuery{OrgId: orgID})
+		require.NoError(t, err)
+		require.Zero(t, stats.Count)
+		res, err := playlistStore.List(context.Background(), &playlist.GetPlaylistsQuery{OrgId: orgID, Limit: 10, IncludeTrashed: true})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		sizes, err := playlistStore.GetSizeDistribution(context.Background(), &playlist.GetPlaylistSizeDistributionQuery{OrgId: orgID})
+		require.NoError(t, err)
+		require.Empty(t, sizes)
+
+		// Only the playlists trashed after the given time are restored
+		err = playlistStore.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: orgID}, trashed.DeletedAt)
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+		require.NoError(t, playlistStore.Restore(context.Background(), &playlist.RestorePlaylistCommand{UID: p.UID, OrgId: orgID}, trashed.DeletedAt-1))
+		_, err = playlistStore.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID})
+		require.NoError(t, err)
+
+		// Only the playlists trashed before the given time are deleted, with their items
+		require.NoError(t, playlistStore.Trash(context.Background(), &playlist.DeletePlaylistCommand{UID: p.UID, OrgId: orgID}))
+		trashed, err = playlistStore.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID, IncludeTrashed: true})
+		require.NoError(t, err)
+		deleted, err := playlistStore.DeleteTrashed(context.Background(), trashed.DeletedAt)
+		require.NoError(t, err)
+		require.Empty(t, deleted)
+		deleted, err = playlistStore.DeleteTrashed(context.Background(), trashed.DeletedAt+1)
+		require.NoError(t, err)
+		require.Equal(t, map[int64]int64{orgID: 1}, deleted)
+		_, err = playlistStore.Get(context.Background(), &playlist.GetPlaylistByUidQuery{UID: p.UID, OrgId: orgID, IncludeTrashed: true})
+		require.ErrorIs(t, err, playlist.ErrPlaylistNotFound)
+		err = ss.WithDbSession(context.Background(), func(sess *db.Session) error {
+			count, err := sess.Where("playlist_id = ?", p.Id).Count(&playlist.PlaylistItem{})
+			require.Zero(t, count)
+			return err
+		})
+		require.NoError(t, err)
 	})
 
 	t.Run("Delete playlist that doesn't exist, should not return error", func(t *testing.T) {
@@ -2,10 +2,13 @@ package playlistimpl
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana/pkg/infra/db"
 	"github.com/grafana/grafana/pkg/services/playlist"
+	"github.com/grafana/grafana/pkg/services/quota"
 	"github.com/grafana/grafana/pkg/services/star"
 	"github.com/grafana/grafana/pkg/util"
 )
@@ -40,6 +43,9 @@ func (s *sqlStore) Insert(ctx context.Context, cmd *playlist.CreatePlaylistComma
 
 		_, err := sess.Insert(&p)
 		if err != nil {
+			if s.db.GetDialect().IsUniqueConstraintViolation(err) {
+				return fmt.Errorf("%w: %q", playlist.ErrPlaylistAlreadyExists, cmd.UID)
+			}
 			return err
 		}
 
@@ -51,6 +57,7 @@ func (s *sqlStore) Insert(ctx context.Context, cmd *playlist.CreatePlaylistComma
 				Value:      item.Value,
 				Order:      order + 1,
 				Title:      item.Title,
+				Interval:   item.Interval,
 			})
 		}
 
@@ -107,6 +114,7 @@ func (s *sqlStore) Update(ctx context.Context, cmd *playlist.UpdatePlaylistComma
 				Value:      item.Value,
 				Order:      index + 1,
 				Title:      item.Title,
+				Interval:   item.Interval,
 			})
 		}
 
@@ -124,6 +132,9 @@ func (s *sqlStore) Get(ctx context.Context, query *playlist.GetPlaylistByUidQuer
 	p := playlist.Playlist{}
 	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
 		p = playlist.Playlist{UID: query.UID, OrgId: query.OrgId}
+		if !query.IncludeTrashed {
+			sess.Where("deleted_at = 0")
+		}
 		exists, err := sess.Get(&p)
 		if !exists {
 			return playlist.ErrPlaylistNotFound
@@ -159,6 +170,72 @@ func (s *sqlStore) Delete(ctx context.Context, cmd *playlist.DeletePlaylistComma
 	})
 }
 
+func (s *sqlStore) Trash(ctx context.Context, cmd *playlist.DeletePlaylistCommand) error {
+	if cmd.UID == "" || cmd.OrgId == 0 {
+		return playlist.ErrCommandValidationFailed
+	}
+
+	return s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		// The update time changes too, so the searches of the org aren't considered unmodified
+		ts := time.Now().UnixMilli()
+		rawSQL := "UPDATE playlist SET deleted_at = ?, updated_at = ? WHERE uid = ? AND org_id = ? AND deleted_at = 0"
+		_, err := sess.Exec(rawSQL, ts, ts, cmd.UID, cmd.OrgId)
+		return err
+	})
+}
+
+func (s *sqlStore) Restore(ctx context.Context, cmd *playlist.RestorePlaylistCommand, trashedAfter int64) error {
+	if cmd.UID == "" || cmd.OrgId == 0 {
+		return playlist.ErrCommandValidationFailed
+	}
+
+	return s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		rawSQL := "UPDATE playlist SET deleted_at = 0, updated_at = ? WHERE uid = ? AND org_id = ? AND deleted_at > ?"
+		res, err := sess.Exec(rawSQL, time.Now().UnixMilli(), cmd.UID, cmd.OrgId, trashedAfter)
+		if err != nil {
+			return err
+		}
+		restored, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if restored == 0 {
+			return playlist.ErrPlaylistNotFound
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) DeleteTrashed(ctx context.Context, trashedBefore int64) (map[int64]int64, error) {
+	deleted := map[int64]int64{}
+	err := s.db.WithTransactionalDbSession(ctx, func(sess *db.Session) error {
+		var counts []struct {
+			OrgId int64
+			Count int64
+		}
+		rawCountSQL := "SELECT org_id, COUNT(*) AS count FROM playlist WHERE deleted_at > 0 AND deleted_at < ? GROUP BY org_id"
+		if err := sess.SQL(rawCountSQL, trashedBefore).Find(&counts); err != nil {
+			return err
+		}
+		if len(counts) == 0 {
+			return nil
+		}
+		for _, c := range counts {
+			deleted[c.OrgId] = c.Count
+		}
+
+		rawItemSQL := "DELETE FROM playlist_item WHERE playlist_id IN (SELECT id FROM playlist WHERE deleted_at > 0 AND deleted_at < ?)"
+		if _, err := sess.Exec(rawItemSQL, trashedBefore); err != nil {
+			return err
+		}
+
+		rawPlaylistSQL := "DELETE FROM playlist WHERE deleted_at > 0 AND deleted_at < ?"
+		_, err := sess.Exec(rawPlaylistSQL, trashedBefore)
+		return err
+	})
+	return deleted, err
+}
+
 func (s *sqlStore) List(ctx context.Context, query *playlist.GetPlaylistsQuery) (playlist.Playlists, error) {
 	playlists := make(playlist.Playlists, 0)
 	if query.OrgId == 0 {
@@ -166,20 +243,108 @@ func (s *sqlStore) List(ctx context.Context, query *playlist.GetPlaylistsQuery)
 	}
 
 	err := s.db.WithDbSession(ctx, func(dbSess *db.Session) error {
-		sess := dbSess.Limit(query.Limit)
+		offset := 0
+		if query.Page > 1 {
+			offset = (query.Page - 1) * query.Limit
+		}
+		sess := dbSess.Where("org_id = ?", query.OrgId)
+		// The names are matched with the regular expression after fetching all the other matching playlists,
+		// so they're paginated here too
+		if query.NameRegex == nil {
+			sess.Limit(query.Limit, offset)
+		}
 
 		if query.Name != "" {
 			sess.Where("name LIKE ?", "%"+query.Name+"%")
 		}
+		if query.ItemType != "" {
+			sess.Where("id IN (SELECT playlist_id FROM playlist_item WHERE type = ?)", query.ItemType)
+		}
+		if !query.IncludeTrashed {
+			sess.Where("deleted_at = 0")
+		}
 
-		sess.Where("org_id = ?", query.OrgId)
+		// The ID is always part of the order, so the pages are stable
+		switch query.Sort {
+		case playlist.SortNameAsc:
+			sess.Asc("name", "id")
+		case playlist.SortNameDesc:
+			sess.Desc("name", "id")
+		case playlist.SortCreatedAsc:
+			sess.Asc("created_at", "id")
+		case playlist.SortCreatedDesc:
+			sess.Desc("created_at", "id")
+		default:
+			sess.Asc("id")
+		}
 		err := sess.Find(&playlists)
+		if err != nil || query.NameRegex == nil {
+			return err
+		}
 
-		return err
+		matching := make(playlist.Playlists, 0, len(playlists))
+		for _, p := range playlists {
+			if query.NameRegex.MatchString(p.Name) {
+				matching = append(matching, p)
+			}
+		}
+		playlists = make(playlist.Playlists, 0)
+		if offset < len(matching) {
+			playlists = matching[offset:]
+		}
+		if query.Limit > 0 && len(playlists) > query.Limit {
+			playlists = playlists[:query.Limit]
+		}
+		return nil
 	})
 	return playlists, err
 }
 
+func (s *sqlStore) ListStats(ctx context.Context, query *playlist.GetPlaylistsQuery) (playlist.PlaylistSearchStats, error) {
+	stats := playlist.PlaylistSearchStats{}
+	if query.OrgId == 0 {
+		return stats, playlist.ErrCommandValidationFailed
+	}
+
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		sess.Where("org_id = ?", query.OrgId)
+		if query.Name != "" {
+			sess.Where("name LIKE ?", "%"+query.Name+"%")
+		}
+		if query.ItemType != "" {
+			sess.Where("id IN (SELECT playlist_id FROM playlist_item WHERE type = ?)", query.ItemType)
+		}
+		if !query.IncludeTrashed {
+			sess.Where("deleted_at = 0")
+		}
+		if query.NameRegex != nil {
+			playlists := []playlist.Playlist{}
+			if err := sess.Table("playlist").Cols("name", "updated_at").Find(&playlists); err != nil {
+				return err
+			}
+			for _, p := range playlists {
+				if query.NameRegex.MatchString(p.Name) {
+					stats.Count++
+					if p.UpdatedAt > stats.LastUpdated {
+						stats.LastUpdated = p.UpdatedAt
+					}
+				}
+			}
+			return nil
+		}
+		var r struct {
+			Count     int64
+			UpdatedAt int64
+		}
+		if _, err := sess.Table("playlist").Select("COUNT(*) AS count, COALESCE(MAX(updated_at), 0) AS updated_at").Get(&r); err != nil {
+			return err
+		}
+		stats.Count, stats.LastUpdated = r.Count, r.UpdatedAt
+		return nil
+	})
+	return stats, err
+}
+
 func (s *sqlStore) GetItems(ctx context.Context, query *playlist.GetPlaylistItemsByUidQuery) ([]playlist.PlaylistItem, error) {
 	var playlistItems = make([]playlist.PlaylistItem, 0)
 	if query.PlaylistUID == "" || query.OrgId == 0 {
@@ -187,15 +352,132 @@ func (s *sqlStore) GetItems(ctx context.Context, query *playlist.GetPlaylistItem
 	}
 	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
 		// getQuery the playlist Id
-		getQuery := &playlist.GetPlaylistByUidQuery{UID: query.PlaylistUID, OrgId: query.OrgId}
+		getQuery := &playlist.GetPlaylistByUidQuery{UID: query.PlaylistUID, OrgId: query.OrgId, IncludeTrashed: query.IncludeTrashed}
 		p, err := s.Get(ctx, getQuery)
 		if err != nil {
 			return err
 		}
 
-		err = sess.Where("playlist_id=?", p.Id).Find(&playlistItems)
+		// Items are returned in their persisted order, so repeated reads of a playlist are identical
+		err = sess.Where("playlist_id=?", p.Id).Asc("order", "id").Find(&playlistItems)
 
 		return err
 	})
 	return playlistItems, err
 }
+
+// playlistItemWithUID is a playlist item along with the UID of its playlist.
+type playlistItemWithUID struct {
+	playlist.PlaylistItem `xorm:"extends"`
+	PlaylistUID           string `xorm:"playlist_uid"`
+}
+
+func (s *sqlStore) GetItemsByUIDs(ctx context.Context, query *playlist.GetPlaylistsItemsByUidsQuery) (map[string][]playlist.PlaylistItem, error) {
+	items := make(map[string][]playlist.PlaylistItem, len(query.PlaylistUIDs))
+	if query.OrgId == 0 {
+		return items, playlist.ErrCommandValidationFailed
+	}
+	if len(query.PlaylistUIDs) == 0 {
+		return items, nil
+	}
+
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		args := make([]any, 0, len(query.PlaylistUIDs)+1)
+		args = append(args, query.OrgId)
+		for _, uid := range query.PlaylistUIDs {
+			args = append(args, uid)
+		}
+		rawSQL := `SELECT pi.*, p.uid AS playlist_uid FROM playlist_item pi
+			INNER JOIN playlist p ON p.id = pi.playlist_id
+			WHERE p.org_id = ? AND p.uid IN (?` + strings.Repeat(",?", len(query.PlaylistUIDs)-1) + `)`
+		if !query.IncludeTrashed {
+			rawSQL += " AND p.deleted_at = 0"
+		}
+		// Like GetItems, the items are returned in their persisted order
+		rawSQL += " ORDER BY pi.playlist_id, pi." + s.db.GetDialect().Quote("order") + ", pi.id"
+
+		var rows []playlistItemWithUID
+		if err := sess.SQL(rawSQL, args...).Find(&rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			items[row.PlaylistUID] = append(items[row.PlaylistUID], row.PlaylistItem)
+		}
+		return nil
+	})
+	return items, err
+}
+
+func (s *sqlStore) GetLastUpdated(ctx context.Context, query *playlist.GetLastUpdatedQuery) (int64, error) {
+	if query.OrgId == 0 {
+		return 0, playlist.ErrCommandValidationFailed
+	}
+
+	var r struct {
+		UpdatedAt int64
+	}
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		rawSQL := "SELECT COALESCE(MAX(updated_at), 0) AS updated_at FROM playlist WHERE org_id = ?"
+		_, err := sess.SQL(rawSQL, query.OrgId).Get(&r)
+		return err
+	})
+	return r.UpdatedAt, err
+}
+
+func (s *sqlStore) GetSizeDistribution(ctx context.Context, query *playlist.GetPlaylistSizeDistributionQuery) ([]playlist.PlaylistSizeCount, error) {
+	sizes := make([]playlist.PlaylistSizeCount, 0)
+	if query.OrgId == 0 {
+		return sizes, playlist.ErrCommandValidationFailed
+	}
+
+	err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		// The items are counted per playlist first, so the playlists without items are included
+		rawSQL := `SELECT s.item_count, COUNT(*) AS playlist_count FROM (
+			SELECT p.id, COUNT(pi.id) AS item_count FROM playlist p
+			LEFT JOIN playlist_item pi ON pi.playlist_id = p.id
+			WHERE p.org_id = ? AND p.deleted_at = 0
+			GROUP BY p.id
+		) s GROUP BY s.item_count ORDER BY s.item_count`
+		return sess.SQL(rawSQL, query.OrgId).Find(&sizes)
+	})
+	return sizes, err
+}
+
+func (s *sqlStore) Count(ctx context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error) {
+	u := &quota.Map{}
+	type result struct {
+		Count int64
+	}
+
+	r := result{}
+	if err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+		// The playlists in the trash don't count, so deleting playlists frees up quota either way
+		rawSQL := "SELECT COUNT(*) AS count FROM playlist WHERE deleted_at = 0"
+		_, err := sess.SQL(rawSQL).Get(&r)
+		return err
+	}); err != nil {
+		return u, err
+	}
+	tag, err := quota.NewTag(playlist.QuotaTargetSrv, playlist.QuotaTarget, quota.GlobalScope)
+	if err != nil {
+		return u, err
+	}
+	u.Set(tag, r.Count)
+
+	if scopeParams != nil && scopeParams.OrgID != 0 {
+		if err := s.db.WithDbSession(ctx, func(sess *db.Session) error {
+			rawSQL := "SELECT COUNT(*) AS count FROM playlist WHERE org_id = ? AND deleted_at = 0"
+			_, err := sess.SQL(rawSQL, scopeParams.OrgID).Get(&r)
+			return err
+		}); err != nil {
+			return u, err
+		}
+		tag, err := quota.NewTag(playlist.QuotaTargetSrv, playlist.QuotaTarget, quota.OrgScope)
+		if err != nil {
+			return u, err
+		}
+		u.Set(tag, r.Count)
+	}
+
+	return u, nil
+}
@@ -10,10 +10,16 @@ type FakePlaylistService struct {
 	ExpectedPlaylist      *playlist.Playlist
 	ExpectedPlaylistDTO   *playlist.PlaylistDTO
 	ExpectedPlaylistItems []playlist.PlaylistItem
+	ExpectedItemsByUID    map[string][]playlist.PlaylistItemDTO
 	ExpectedPlaylists     playlist.Playlists
+	ExpectedSizes         []playlist.PlaylistSizeCount
+	ExpectedLastUpdated   int64
+	ExpectedCount         int64
 	ExpectedError         error
 }
 
+var _ playlist.Service = &FakePlaylistService{}
+
 func NewPlaylistServiveFake() *FakePlaylistService {
 	return &FakePlaylistService{}
 }
@@ -26,18 +32,50 @@ func (f *FakePlaylistService) Update(context.Context, *playlist.UpdatePlaylistCo
 	return f.ExpectedPlaylistDTO, f.ExpectedError
 }
 
-func (f *FakePlaylistService) Get(context.Context, *playlist.GetPlaylistByUidQuery) (*playlist.Playlist, error) {
+func (f *FakePlaylistService) GetWithoutItems(context.Context, *playlist.GetPlaylistByUidQuery) (*playlist.Playlist, error) {
 	return f.ExpectedPlaylist, f.ExpectedError
 }
 
+func (f *FakePlaylistService) Get(context.Context, *playlist.GetPlaylistByUidQuery) (*playlist.PlaylistDTO, error) {
+	return f.ExpectedPlaylistDTO, f.ExpectedError
+}
+
 func (f *FakePlaylistService) GetItems(context.Context, *playlist.GetPlaylistItemsByUidQuery) ([]playlist.PlaylistItem, error) {
 	return f.ExpectedPlaylistItems, f.ExpectedError
 }
 
+func (f *FakePlaylistService) GetItemsByUIDs(context.Context, *playlist.GetPlaylistsItemsByUidsQuery) (map[string][]playlist.PlaylistItemDTO, error) {
+	return f.ExpectedItemsByUID, f.ExpectedError
+}
+
 func (f *FakePlaylistService) Search(context.Context, *playlist.GetPlaylistsQuery) (playlist.Playlists, error) {
 	return f.ExpectedPlaylists, f.ExpectedError
 }
 
+func (f *FakePlaylistService) SearchCount(context.Context, *playlist.GetPlaylistsQuery) (int64, error) {
+	return f.ExpectedCount, f.ExpectedError
+}
+
+func (f *FakePlaylistService) SearchStats(context.Context, *playlist.GetPlaylistsQuery) (playlist.PlaylistSearchStats, error) {
+	return playlist.PlaylistSearchStats{Count: f.ExpectedCount, LastUpdated: f.ExpectedLastUpdated}, f.ExpectedError
+}
+
 func (f *FakePlaylistService) Delete(ctx context.Context, cmd *playlist.DeletePlaylistCommand) error {
 	return f.ExpectedError
 }
+
+func (f *FakePlaylistService) Restore(context.Context, *playlist.RestorePlaylistCommand) (*playlist.Playlist, error) {
+	return f.ExpectedPlaylist, f.ExpectedError
+}
+
+func (f *FakePlaylistService) PurgeTrash(context.Context) (int64, error) {
+	return f.ExpectedCount, f.ExpectedError
+}
+
+func (f *FakePlaylistService) GetLastUpdated(context.Context, *playlist.GetLastUpdatedQuery) (int64, error) {
+	return f.ExpectedLastUpdated, f.ExpectedError
+}
+
+func (f *FakePlaylistService) GetSizeDistribution(context.Context, *playlist.GetPlaylistSizeDistributionQuery) ([]playlist.PlaylistSizeCount, error) {
+	return f.ExpectedSizes, f.ExpectedError
+}
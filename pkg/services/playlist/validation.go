@@ -0,0 +1,174 @@
+package playlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// ItemsGetter returns the items of the playlist with the given UID, in the org of the playlist being validated,
+// or ErrPlaylistNotFound if there's none.
+type ItemsGetter func(ctx context.Context, uid string) ([]PlaylistItem, error)
+
+// ItemValidator validates the items of the playlists saved with any API, so that the legacy API and the
+// Kubernetes one enforce the same rules.
+type ItemValidator struct {
+	// externalURLSchemes and externalURLHosts are the schemes and hosts allowed in external_url items
+	externalURLSchemes map[string]bool
+	externalURLHosts   map[string]bool
+	// appURL is the root URL of this instance, to find the external_url items playing one of its playlists
+	appURL *url.URL
+}
+
+// NewItemValidator returns an ItemValidator allowing the external_url schemes and hosts of the [playlists]
+// configuration section. No external_url item is allowed if cfg is nil.
+func NewItemValidator(cfg *setting.Cfg) *ItemValidator {
+	v := &ItemValidator{
+		externalURLSchemes: map[string]bool{},
+		externalURLHosts:   map[string]bool{},
+	}
+	if cfg == nil {
+		return v
+	}
+	for _, scheme := range cfg.Playlist.ExternalURLAllowedSchemes {
+		v.externalURLSchemes[strings.ToLower(scheme)] = true
+	}
+	for _, host := range cfg.Playlist.ExternalURLAllowedHosts {
+		v.externalURLHosts[strings.ToLower(host)] = true
+	}
+	if u, err := url.Parse(cfg.AppURL); err == nil && u.Host != "" {
+		v.appURL = u
+	}
+	return v
+}
+
+// Validate checks the items of the playlist with the given UID, and that the playlists they reference don't
+// reference it in turn. The items of the referenced playlists are returned by getItems.
+func (v *ItemValidator) Validate(ctx context.Context, uid string, items []PlaylistItem, getItems ItemsGetter) error {
+	if err := v.validateItems(items); err != nil {
+		return err
+	}
+	return v.validateRefs(ctx, uid, items, getItems)
+}
+
+// validateItems checks that the item intervals are positive durations, that the recently_viewed items
+// have a positive number of dashboards, that the section items have a printable label, and that the
+// external_url items are absolute URLs with an allowed scheme and host.
+func (v *ItemValidator) validateItems(items []PlaylistItem) error {
+	for _, item := range items {
+		if item.Interval != "" {
+			if d, err := gtime.ParseDuration(item.Interval); err != nil || d <= 0 {
+				return fmt.Errorf("%w: %q is not a positive duration", ErrInvalidItemInterval, item.Interval)
+			}
+		}
+		if item.Type == ItemTypeRecentlyViewed {
+			if n, err := strconv.Atoi(item.Value); err != nil || n <= 0 {
+				return fmt.Errorf("%w: %q is not a positive integer", ErrInvalidRecentlyViewed, item.Value)
+			}
+			continue
+		}
+		if item.Type == ItemTypeSection {
+			if err := validateSectionLabel(item.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if item.Type != ItemTypeExternalURL {
+			continue
+		}
+		u, err := url.Parse(item.Value)
+		if err != nil || !u.IsAbs() || u.Hostname() == "" {
+			return fmt.Errorf("%w: %q is not an absolute URL", ErrExternalURLNotAllowed, item.Value)
+		}
+		if !v.externalURLSchemes[strings.ToLower(u.Scheme)] {
+			return fmt.Errorf("%w: scheme %q is not allowed", ErrExternalURLNotAllowed, u.Scheme)
+		}
+		if !v.externalURLHosts[strings.ToLower(u.Hostname())] {
+			return fmt.Errorf("%w: host %q is not allowed", ErrExternalURLNotAllowed, u.Hostname())
+		}
+	}
+	return nil
+}
+
+// validateRefs checks that the playlists referenced by the items, and the ones they reference in turn, don't
+// reference the playlist with the given UID, and aren't nested more than MaxRefDepth levels deep.
+// The referenced playlists that don't exist are ignored.
+func (v *ItemValidator) validateRefs(ctx context.Context, uid string, items []PlaylistItem, getItems ItemsGetter) error {
+	var visit func(path []string, items []PlaylistItem) error
+	visited := map[string]bool{}
+	visit = func(path []string, items []PlaylistItem) error {
+		for _, item := range items {
+			ref, ok := v.playlistRef(item)
+			if !ok {
+				continue
+			}
+			if ref == uid {
+				if len(path) == 1 {
+					return fmt.Errorf("%w: playlist %q references itself", ErrInvalidPlaylistRef, uid)
+				}
+				return fmt.Errorf("%w: playlists %s form a cycle", ErrInvalidPlaylistRef, strings.Join(append(path, ref), " -> "))
+			}
+			if len(path) >= MaxRefDepth {
+				return fmt.Errorf("%w: playlists are nested more than %d levels deep", ErrInvalidPlaylistRef, MaxRefDepth)
+			}
+			// The playlists referenced more than once, or that are in a cycle that doesn't involve this
+			// playlist, are only followed once
+			if visited[ref] {
+				continue
+			}
+			visited[ref] = true
+			refItems, err := getItems(ctx, ref)
+			if errors.Is(err, ErrPlaylistNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := visit(append(path, ref), refItems); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit([]string{uid}, items)
+}
+
+// playlistRef returns the UID of the playlist of this instance that an item plays, if any:
+// the external_url items whose URL is the play page of a playlist, e.g. https://grafana.example.com/playlists/play/abc.
+func (v *ItemValidator) playlistRef(item PlaylistItem) (string, bool) {
+	if item.Type != ItemTypeExternalURL || v.appURL == nil {
+		return "", false
+	}
+	u, err := url.Parse(item.Value)
+	if err != nil || !strings.EqualFold(u.Host, v.appURL.Host) {
+		return "", false
+	}
+	uid, ok := strings.CutPrefix(u.Path, strings.TrimSuffix(v.appURL.Path, "/")+"/playlists/play/")
+	if !ok || uid == "" || strings.Contains(uid, "/") {
+		return "", false
+	}
+	return uid, true
+}
+
+// validateSectionLabel checks that a section label isn't blank, isn't too long and has no control characters.
+func validateSectionLabel(label string) error {
+	if strings.TrimSpace(label) == "" {
+		return fmt.Errorf("%w: the label is empty", ErrInvalidSectionLabel)
+	}
+	if utf8.RuneCountInString(label) > MaxSectionLabelLength {
+		return fmt.Errorf("%w: the label is longer than %d characters", ErrInvalidSectionLabel, MaxSectionLabelLength)
+	}
+	if strings.IndexFunc(label, unicode.IsControl) >= 0 {
+		return fmt.Errorf("%w: %q has control characters", ErrInvalidSectionLabel, label)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package clientmiddleware
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+var (
+	// ErrDuplicateMiddleware is returned by AssembleMiddlewares when two enabled middlewares have the same name.
+	ErrDuplicateMiddleware = errors.New("duplicate middleware")
+	// ErrMiddlewareOrderConflict is returned by AssembleMiddlewares when the order constraints can't all be satisfied.
+	ErrMiddlewareOrderConflict = errors.New("conflicting middleware order constraints")
+)
+
+// MiddlewareSpec declares a middleware to assemble with AssembleMiddlewares, and where it goes in the chain
+// relative to the other middlewares.
+// The first middleware in the chain is the outermost one, i.e. it sees the requests first and the responses last.
+type MiddlewareSpec struct {
+	// Name identifies the middleware in the constraints of the other middlewares.
+	Name string
+	// Enabled tells whether the middleware is part of the chain.
+	Enabled bool
+	// Middleware is the middleware itself.
+	Middleware plugins.ClientMiddleware
+	// After are the names of the middlewares that must come before this one, i.e. outside of it.
+	After []string
+	// Before are the names of the middlewares that must come after this one, i.e. inside of it.
+	Before []string
+}
+
+// AssembleMiddlewares returns the enabled middlewares, ordered so that all their constraints are satisfied.
+// Middlewares without constraints between them keep the order they are declared in.
+// Constraints on disabled or undeclared middlewares are ignored, so a middleware can be declared relative
+// to another one that is only enabled by some feature toggle.
+func AssembleMiddlewares(specs []MiddlewareSpec) ([]plugins.ClientMiddleware, error) {
+	enabled := make([]MiddlewareSpec, 0, len(specs))
+	index := map[string]int{}
+	for _, s := range specs {
+		if !s.Enabled {
+			continue
+		}
+		if _, ok := index[s.Name]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateMiddleware, s.Name)
+		}
+		index[s.Name] = len(enabled)
+		enabled = append(enabled, s)
+	}
+
+	// next[i] are the middlewares that must come after middleware i
+	next := make([][]int, len(enabled))
+	incoming := make([]int, len(enabled))
+	addEdge := func(from, to int) {
+		next[from] = append(next[from], to)
+		incoming[to]++
+	}
+	for i, s := range enabled {
+		for _, name := range s.After {
+			if j, ok := index[name]; ok {
+				addEdge(j, i)
+			}
+		}
+		for _, name := range s.Before {
+			if j, ok := index[name]; ok {
+				addEdge(i, j)
+			}
+		}
+	}
+
+	// Kahn's algorithm, always picking the first declared middleware among the ones that are ready
+	ready := []int{}
+	for i := range enabled {
+		if incoming[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	out := make([]plugins.ClientMiddleware, 0, len(enabled))
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		i := ready[0]
+		ready = ready[1:]
+		out = append(out, enabled[i].Middleware)
+		for _, j := range next[i] {
+			incoming[j]--
+			if incoming[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+
+	if len(out) < len(enabled) {
+		var names []string
+		for i, s := range enabled {
+			if incoming[i] > 0 {
+				names = append(names, s.Name)
+			}
+		}
+		return nil, fmt.Errorf("%w between %s", ErrMiddlewareOrderConflict, strings.Join(names, ", "))
+	}
+	return out, nil
+}
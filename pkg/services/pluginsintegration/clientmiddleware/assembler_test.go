@@ -0,0 +1,101 @@
+package clientmiddleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+// namedMiddleware is a no-op middleware identified by its name.
+type namedMiddleware string
+
+func (m namedMiddleware) CreateClientMiddleware(next plugins.Client) plugins.Client {
+	return next
+}
+
+func assembledNames(t *testing.T, specs []MiddlewareSpec) []string {
+	t.Helper()
+	middlewares, err := AssembleMiddlewares(specs)
+	require.NoError(t, err)
+	names := make([]string, 0, len(middlewares))
+	for _, mw := range middlewares {
+		names = append(names, string(mw.(namedMiddleware)))
+	}
+	return names
+}
+
+func middlewareSpec(name string, enabled bool, after []string, before []string) MiddlewareSpec {
+	return MiddlewareSpec{Name: name, Enabled: enabled, Middleware: namedMiddleware(name), After: after, Before: before}
+}
+
+func TestAssembleMiddlewares(t *testing.T) {
+	t.Run("Should keep the declaration order without constraints", func(t *testing.T) {
+		require.Equal(t, []string{"a", "b", "c"}, assembledNames(t, []MiddlewareSpec{
+			middlewareSpec("a", true, nil, nil),
+			middlewareSpec("b", true, nil, nil),
+			middlewareSpec("c", true, nil, nil),
+		}))
+	})
+
+	t.Run("Should satisfy the order constraints", func(t *testing.T) {
+		names := assembledNames(t, []MiddlewareSpec{
+			middlewareSpec("status-source", true, nil, nil),
+			middlewareSpec("logger", true, []string{"metrics"}, nil),
+			middlewareSpec("metrics", true, []string{"tracing", "request-meta"}, []string{"status-source"}),
+			middlewareSpec("tracing", true, nil, nil),
+			middlewareSpec("request-meta", true, nil, []string{"tracing"}),
+		})
+		require.Equal(t, []string{"request-meta", "tracing", "metrics", "status-source", "logger"}, names)
+
+		position := map[string]int{}
+		for i, name := range names {
+			position[name] = i
+		}
+		require.Less(t, position["request-meta"], position["tracing"])
+		require.Less(t, position["tracing"], position["metrics"])
+		require.Less(t, position["metrics"], position["status-source"])
+		require.Less(t, position["metrics"], position["logger"])
+	})
+
+	t.Run("Should skip disabled middlewares and ignore their constraints", func(t *testing.T) {
+		require.Equal(t, []string{"b", "c"}, assembledNames(t, []MiddlewareSpec{
+			middlewareSpec("a", false, []string{"c"}, []string{"b"}),
+			middlewareSpec("b", true, nil, nil),
+			middlewareSpec("c", true, []string{"b", "undeclared"}, nil),
+		}))
+	})
+
+	t.Run("Should reject cyclic constraints", func(t *testing.T) {
+		_, err := AssembleMiddlewares([]MiddlewareSpec{
+			middlewareSpec("a", true, nil, []string{"b"}),
+			middlewareSpec("b", true, nil, []string{"c"}),
+			middlewareSpec("c", true, nil, []string{"a"}),
+			middlewareSpec("d", true, nil, nil),
+		})
+		require.ErrorIs(t, err, ErrMiddlewareOrderConflict)
+		require.ErrorContains(t, err, "a, b, c")
+	})
+
+	t.Run("Should reject conflicting constraints", func(t *testing.T) {
+		_, err := AssembleMiddlewares([]MiddlewareSpec{
+			middlewareSpec("metrics", true, []string{"status-source"}, nil),
+			middlewareSpec("status-source", true, []string{"metrics"}, nil),
+		})
+		require.ErrorIs(t, err, ErrMiddlewareOrderConflict)
+	})
+
+	t.Run("Should reject duplicated middlewares", func(t *testing.T) {
+		_, err := AssembleMiddlewares([]MiddlewareSpec{
+			middlewareSpec("a", true, nil, nil),
+			middlewareSpec("a", true, nil, nil),
+		})
+		require.ErrorIs(t, err, ErrDuplicateMiddleware)
+
+		require.Equal(t, []string{"a"}, assembledNames(t, []MiddlewareSpec{
+			middlewareSpec("a", false, nil, nil),
+			middlewareSpec("a", true, nil, nil),
+		}))
+	})
+}
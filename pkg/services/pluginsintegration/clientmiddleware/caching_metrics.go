@@ -1,6 +1,9 @@
 package clientmiddleware
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/grafana/grafana/pkg/infra/metrics"
 	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,6 +12,9 @@ import (
 const (
 	QueryPubdash   = "pubdash"
 	QueryDashboard = "dashboard"
+
+	CachingRequestQuery    = "query"
+	CachingRequestResource = "resource"
 )
 
 var QueryCachingRequestHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -35,6 +41,68 @@ var ResourceCachingRequestHistogram = prometheus.NewHistogramVec(prometheus.Hist
 	Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25, 50, 100},
 }, []string{"plugin_id", "cache"})
 
+// CachingEligibleCounter, CachingHitCounter and CachingMissCounter count the requests that could be served from
+// the cache, and among them the ones that were. The ratio of hits to eligible requests is the ceiling of caching.
+var CachingEligibleCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.ExporterName,
+	Subsystem: "caching",
+	Name:      "eligible_requests_total",
+	Help:      "The total amount of plugin requests that could be served from the cache",
+}, []string{"plugin_id", "request_type"})
+
+var CachingHitCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.ExporterName,
+	Subsystem: "caching",
+	Name:      "eligible_request_hits_total",
+	Help:      "The total amount of plugin requests eligible for caching that were served from the cache",
+}, []string{"plugin_id", "request_type"})
+
+var CachingMissCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.ExporterName,
+	Subsystem: "caching",
+	Name:      "eligible_request_misses_total",
+	Help:      "The total amount of plugin requests eligible for caching that weren't served from the cache",
+}, []string{"plugin_id", "request_type"})
+
+// QueryCachingPartialHitCounter counts the multi-query requests that were partially served from the cache,
+// i.e. some of their queries were cached on their own and the other ones were executed.
+var QueryCachingPartialHitCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.ExporterName,
+	Subsystem: "caching",
+	Name:      "query_partial_hits_total",
+	Help:      "The total amount of query requests partially served from the cache",
+}, []string{"plugin_id"})
+
+// cachingEligible reports whether a request can be served from the cache: the incoming request must be idempotent,
+// and it must not forbid storing the response with a no-store Cache-Control directive.
+func cachingEligible(req *http.Request, idempotent bool) bool {
+	if req == nil || !idempotent {
+		return false
+	}
+	for _, value := range req.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// instrumentCachingEligibility increments the caching eligibility counters for a request.
+// Hits and misses are only counted for the eligible requests.
+func instrumentCachingEligibility(pluginID string, requestType string, eligible bool, hit bool) {
+	if !eligible {
+		return
+	}
+	CachingEligibleCounter.WithLabelValues(pluginID, requestType).Inc()
+	if hit {
+		CachingHitCounter.WithLabelValues(pluginID, requestType).Inc()
+	} else {
+		CachingMissCounter.WithLabelValues(pluginID, requestType).Inc()
+	}
+}
+
 func getQueryType(req *contextmodel.ReqContext) string {
 	if req.IsPublicDashboardView() {
 		return QueryPubdash
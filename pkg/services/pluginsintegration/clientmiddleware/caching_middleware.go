@@ -2,7 +2,9 @@ package clientmiddleware
 
 import (
 	"context"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana-aws-sdk/pkg/awsds"
@@ -27,6 +29,13 @@ func NewCachingMiddleware(cachingService caching.CachingService) plugins.ClientM
 // NewCachingMiddlewareWithFeatureManager creates a new plugins.ClientMiddleware that will
 // attempt to read and write query results to the cache with a feature manager
 func NewCachingMiddlewareWithFeatureManager(cachingService caching.CachingService, features *featuremgmt.FeatureManager) plugins.ClientMiddleware {
+	return NewCachingMiddlewareWithTTLBounds(cachingService, features, 0, 0)
+}
+
+// NewCachingMiddlewareWithTTLBounds creates a new plugins.ClientMiddleware that will
+// attempt to read and write query results to the cache with a feature manager.
+// The cache TTLs suggested by plugins are clamped between minTTL and maxTTL, and ignored if maxTTL is 0.
+func NewCachingMiddlewareWithTTLBounds(cachingService caching.CachingService, features *featuremgmt.FeatureManager, minTTL, maxTTL time.Duration) plugins.ClientMiddleware {
 	log := log.New("caching_middleware")
 	if err := prometheus.Register(QueryCachingRequestHistogram); err != nil {
 		log.Error("Error registering prometheus collector 'QueryRequestHistogram'", "error", err)
@@ -34,12 +43,26 @@ func NewCachingMiddlewareWithFeatureManager(cachingService caching.CachingServic
 	if err := prometheus.Register(ResourceCachingRequestHistogram); err != nil {
 		log.Error("Error registering prometheus collector 'ResourceRequestHistogram'", "error", err)
 	}
+	if err := prometheus.Register(CachingEligibleCounter); err != nil {
+		log.Error("Error registering prometheus collector 'CachingEligibleCounter'", "error", err)
+	}
+	if err := prometheus.Register(CachingHitCounter); err != nil {
+		log.Error("Error registering prometheus collector 'CachingHitCounter'", "error", err)
+	}
+	if err := prometheus.Register(CachingMissCounter); err != nil {
+		log.Error("Error registering prometheus collector 'CachingMissCounter'", "error", err)
+	}
+	if err := prometheus.Register(QueryCachingPartialHitCounter); err != nil {
+		log.Error("Error registering prometheus collector 'QueryCachingPartialHitCounter'", "error", err)
+	}
 	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
 		return &CachingMiddleware{
 			next:     next,
 			caching:  cachingService,
 			log:      log,
 			features: features,
+			minTTL:   minTTL,
+			maxTTL:   maxTTL,
 		}
 	})
 }
@@ -49,10 +72,93 @@ type CachingMiddleware struct {
 	caching  caching.CachingService
 	log      log.Logger
 	features *featuremgmt.FeatureManager
+	minTTL   time.Duration
+	maxTTL   time.Duration
+}
+
+// cacheMaxAgeMetaKey is the key of the custom frame metadata plugins can use to suggest a cache TTL, in seconds, for a query.
+const cacheMaxAgeMetaKey = "cacheMaxAge"
+
+// withTTLHint returns a copy of ctx with the TTL suggested by the plugin, clamped to the configured bounds.
+// If there's no hint or the TTL hints are disabled, ctx is returned as is.
+func (m *CachingMiddleware) withTTLHint(ctx context.Context, ttl time.Duration, ok bool) context.Context {
+	if !ok || m.maxTTL <= 0 {
+		return ctx
+	}
+	if ttl < m.minTTL {
+		ttl = m.minTTL
+	}
+	if ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+	return caching.WithTTL(ctx, ttl)
+}
+
+// queryTTLHint returns the lowest TTL suggested in the custom metadata of the response frames.
+func queryTTLHint(resp *backend.QueryDataResponse) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	var ttl time.Duration
+	var found bool
+	for _, r := range resp.Responses {
+		for _, frame := range r.Frames {
+			if frame == nil || frame.Meta == nil {
+				continue
+			}
+			custom, ok := frame.Meta.Custom.(map[string]any)
+			if !ok {
+				continue
+			}
+			var seconds float64
+			switch v := custom[cacheMaxAgeMetaKey].(type) {
+			case float64:
+				seconds = v
+			case int:
+				seconds = float64(v)
+			case int64:
+				seconds = float64(v)
+			default:
+				continue
+			}
+			if frameTTL := time.Duration(seconds * float64(time.Second)); !found || frameTTL < ttl {
+				ttl, found = frameTTL, true
+			}
+		}
+	}
+	return ttl, found
+}
+
+// resourceTTLHint returns the TTL suggested by the max-age directive of the Cache-Control response header.
+func resourceTTLHint(res *backend.CallResourceResponse) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	for name, values := range res.Headers {
+		if !strings.EqualFold(name, "Cache-Control") {
+			continue
+		}
+		for _, value := range values {
+			for _, directive := range strings.Split(value, ",") {
+				directive = strings.ToLower(strings.TrimSpace(directive))
+				if !strings.HasPrefix(directive, "max-age=") {
+					continue
+				}
+				seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil || seconds < 0 {
+					continue
+				}
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+	return 0, false
 }
 
 // QueryData receives a data request and attempts to access results already stored in the cache for that request.
 // If data is found, it will return it immediately. Otherwise, it will perform the queries as usual, then write the response to the cache.
+// With the queryCachingPartialHits feature toggle, the queries of a request that is not cached as a whole are looked up
+// in the cache one by one, and only the ones that aren't cached are performed.
 // If the cache service is implemented, we capture the request duration as a metric. The service is expected to write any response headers.
 func (m *CachingMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	if req == nil {
@@ -69,6 +175,8 @@ func (m *CachingMiddleware) QueryData(ctx context.Context, req *backend.QueryDat
 
 	// First look in the query cache if enabled
 	hit, cr := m.caching.HandleQueryRequest(ctx, req)
+	// Queries are idempotent, whatever the method of the incoming request
+	instrumentCachingEligibility(req.PluginContext.PluginID, CachingRequestQuery, cachingEligible(reqCtx.Req, true), hit)
 
 	// record request duration if caching was used
 	ch := reqCtx.Resp.Header().Get(caching.XCacheHeader)
@@ -87,14 +195,15 @@ func (m *CachingMiddleware) QueryData(ctx context.Context, req *backend.QueryDat
 		return cr.Response, nil
 	}
 
-	// Cache miss; do the actual queries
-	resp, err := m.next.QueryData(ctx, req)
-
-	// Update the query cache with the result for this metrics request
-	if err == nil && cr.UpdateCacheFn != nil {
+	updateCache := func(updateCacheFn caching.CacheQueryResponseFn, resp *backend.QueryDataResponse) {
+		if updateCacheFn == nil {
+			return
+		}
+		ttl, ok := queryTTLHint(resp)
+		ctx := m.withTTLHint(ctx, ttl, ok)
 		// If AWS async caching is not enabled, use the old code path
 		if m.features == nil || !m.features.IsEnabled(featuremgmt.FlagAwsAsyncQueryCaching) {
-			cr.UpdateCacheFn(ctx, resp)
+			updateCacheFn(ctx, resp)
 		} else {
 			// time how long shouldCacheQuery takes
 			startShouldCacheQuery := time.Now()
@@ -108,14 +217,93 @@ func (m *CachingMiddleware) QueryData(ctx context.Context, req *backend.QueryDat
 
 			// If AWS async caching is enabled and resp is for a running async query, don't cache it
 			if shouldCache {
-				cr.UpdateCacheFn(ctx, resp)
+				updateCacheFn(ctx, resp)
 			}
 		}
 	}
 
+	// Cache miss; do the actual queries, or only the ones that aren't cached on their own
+	var resp *backend.QueryDataResponse
+	var err error
+	if m.partialHitsEnabled(req) {
+		resp, err = m.queryDataPartially(ctx, req, updateCache)
+		// The lookups of the single queries overwrite the cache status of the whole request
+		if ch != "" {
+			reqCtx.Resp.Header().Set(caching.XCacheHeader, ch)
+		}
+	} else {
+		resp, err = m.next.QueryData(ctx, req)
+	}
+
+	// Update the query cache with the result for this metrics request
+	if err == nil {
+		updateCache(cr.UpdateCacheFn, resp)
+	}
+
 	return resp, err
 }
 
+// partialHitsEnabled reports whether the queries of req can be looked up in the cache one by one.
+// The queries must have distinct refIDs to merge their responses.
+func (m *CachingMiddleware) partialHitsEnabled(req *backend.QueryDataRequest) bool {
+	if m.features == nil || !m.features.IsEnabled(featuremgmt.FlagQueryCachingPartialHits) || len(req.Queries) < 2 {
+		return false
+	}
+	refIDs := make(map[string]struct{}, len(req.Queries))
+	for _, q := range req.Queries {
+		if _, ok := refIDs[q.RefID]; ok {
+			return false
+		}
+		refIDs[q.RefID] = struct{}{}
+	}
+	return true
+}
+
+// queryDataPartially serves the queries of req that are cached on their own from the cache, performs the other ones,
+// and merges the responses. The responses of the performed queries are cached on their own with updateCache,
+// so that the next requests sharing some of these queries can be partially served from the cache.
+func (m *CachingMiddleware) queryDataPartially(ctx context.Context, req *backend.QueryDataRequest, updateCache func(caching.CacheQueryResponseFn, *backend.QueryDataResponse)) (*backend.QueryDataResponse, error) {
+	cached := backend.Responses{}
+	missed := make([]backend.DataQuery, 0, len(req.Queries))
+	updateCacheFns := map[string]caching.CacheQueryResponseFn{}
+	for _, q := range req.Queries {
+		single := *req
+		single.Queries = []backend.DataQuery{q}
+		hit, cr := m.caching.HandleQueryRequest(ctx, &single)
+		if hit && cr.Response != nil {
+			if r, ok := cr.Response.Responses[q.RefID]; ok {
+				cached[q.RefID] = r
+				continue
+			}
+		}
+		missed = append(missed, q)
+		updateCacheFns[q.RefID] = cr.UpdateCacheFn
+	}
+	if len(cached) > 0 && len(missed) > 0 {
+		QueryCachingPartialHitCounter.WithLabelValues(req.PluginContext.PluginID).Inc()
+	}
+
+	resp := backend.NewQueryDataResponse()
+	if len(missed) > 0 {
+		partial := *req
+		partial.Queries = missed
+		executed, err := m.next.QueryData(ctx, &partial)
+		if err != nil || executed == nil {
+			return executed, err
+		}
+		for refID, r := range executed.Responses {
+			resp.Responses[refID] = r
+			if updateCacheFn, ok := updateCacheFns[refID]; ok {
+				updateCache(updateCacheFn, &backend.QueryDataResponse{Responses: backend.Responses{refID: r}})
+			}
+		}
+	}
+	for refID, r := range cached {
+		resp.Responses[refID] = r
+	}
+	return resp, nil
+}
+
 // CallResource receives a resource request and attempts to access results already stored in the cache for that request.
 // If data is found, it will return it immediately. Otherwise, it will perform the request as usual. The caller of CallResource is expected to explicitly update the cache with any responses.
 // If the cache service is implemented, we capture the request duration as a metric. The service is expected to write any response headers.
@@ -134,6 +322,8 @@ func (m *CachingMiddleware) CallResource(ctx context.Context, req *backend.CallR
 
 	// First look in the resource cache if enabled
 	hit, cr := m.caching.HandleResourceRequest(ctx, req)
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	instrumentCachingEligibility(req.PluginContext.PluginID, CachingRequestResource, cachingEligible(reqCtx.Req, idempotent), hit)
 
 	// record request duration if caching was used
 	if ch := reqCtx.Resp.Header().Get(caching.XCacheHeader); ch != "" {
@@ -156,8 +346,14 @@ func (m *CachingMiddleware) CallResource(ctx context.Context, req *backend.CallR
 		return m.next.CallResource(ctx, req, sender)
 	}
 	// Otherwise, intercept the responses in a wrapped sender so we can cache them first
+	// The TTL hint is sent with the headers of the first response
+	var ttlCtx context.Context
 	cacheSender := callResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
-		cr.UpdateCacheFn(ctx, res)
+		if ttlCtx == nil {
+			ttl, ok := resourceTTLHint(res)
+			ttlCtx = m.withTTLHint(ctx, ttl, ok)
+		}
+		cr.UpdateCacheFn(ttlCtx, res)
 		return sender.Send(res)
 	})
 
@@ -5,13 +5,16 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
 	"github.com/grafana/grafana/pkg/services/caching"
 	"github.com/grafana/grafana/pkg/services/contexthandler"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -322,3 +325,322 @@ func TestCachingMiddleware(t *testing.T) {
 		})
 	})
 }
+
+func TestCachingMiddlewareTTLHints(t *testing.T) {
+	const (
+		minTTL = 10 * time.Second
+		maxTTL = time.Minute
+	)
+
+	req, err := http.NewRequest(http.MethodGet, "/query", nil)
+	require.NoError(t, err)
+
+	pluginCtx := backend.PluginContext{
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{},
+	}
+
+	t.Run("When QueryData is called", func(t *testing.T) {
+		queryResponse := func(maxAge any) *backend.QueryDataResponse {
+			frame := data.NewFrame("A")
+			if maxAge != nil {
+				frame.SetMeta(&data.FrameMeta{Custom: map[string]any{"cacheMaxAge": maxAge}})
+			}
+			resp := backend.NewQueryDataResponse()
+			resp.Responses["A"] = backend.DataResponse{Frames: data.Frames{frame}}
+			return resp
+		}
+
+		for _, tc := range []struct {
+			desc        string
+			maxTTL      time.Duration
+			resp        *backend.QueryDataResponse
+			expectedTTL time.Duration
+			expectedOk  bool
+		}{
+			{
+				desc:        "TTL suggested by the plugin is honored within bounds",
+				maxTTL:      maxTTL,
+				resp:        queryResponse(float64(30)),
+				expectedTTL: 30 * time.Second,
+				expectedOk:  true,
+			},
+			{
+				desc:        "TTL suggested by the plugin is clamped to the max TTL",
+				maxTTL:      maxTTL,
+				resp:        queryResponse(3600),
+				expectedTTL: maxTTL,
+				expectedOk:  true,
+			},
+			{
+				desc:        "TTL suggested by the plugin is clamped to the min TTL",
+				maxTTL:      maxTTL,
+				resp:        queryResponse(float64(1)),
+				expectedTTL: minTTL,
+				expectedOk:  true,
+			},
+			{
+				desc:   "No TTL is set if the plugin doesn't suggest one",
+				maxTTL: maxTTL,
+				resp:   queryResponse(nil),
+			},
+			{
+				desc: "No TTL is set if the TTL hints are disabled",
+				resp: queryResponse(float64(30)),
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				var ttl time.Duration
+				var ok bool
+				cs := caching.NewFakeOSSCachingService()
+				cs.ReturnQueryResponse = caching.CachedQueryDataResponse{
+					UpdateCacheFn: func(ctx context.Context, qdr *backend.QueryDataResponse) {
+						ttl, ok = caching.TTLFromContext(ctx)
+					},
+				}
+				cdt := clienttest.NewClientDecoratorTest(t,
+					clienttest.WithReqContext(req, &user.SignedInUser{}),
+					clienttest.WithMiddlewares(NewCachingMiddlewareWithTTLBounds(cs, nil, minTTL, tc.maxTTL)),
+				)
+				cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+					return tc.resp, nil
+				}
+
+				_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{PluginContext: pluginCtx})
+				require.NoError(t, err)
+				cs.AssertCalls(t, "HandleQueryRequest", 1)
+				require.Equal(t, tc.expectedOk, ok)
+				require.Equal(t, tc.expectedTTL, ttl)
+			})
+		}
+	})
+
+	t.Run("When CallResource is called", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc         string
+			cacheControl string
+			expectedTTL  time.Duration
+			expectedOk   bool
+		}{
+			{
+				desc:         "TTL suggested by the plugin is honored within bounds",
+				cacheControl: "public, max-age=30",
+				expectedTTL:  30 * time.Second,
+				expectedOk:   true,
+			},
+			{
+				desc:         "TTL suggested by the plugin is clamped to the max TTL",
+				cacheControl: "max-age=86400",
+				expectedTTL:  maxTTL,
+				expectedOk:   true,
+			},
+			{
+				desc:         "TTL suggested by the plugin is clamped to the min TTL",
+				cacheControl: "max-age=0",
+				expectedTTL:  minTTL,
+				expectedOk:   true,
+			},
+			{
+				desc:         "No TTL is set if the plugin doesn't suggest one",
+				cacheControl: "no-cache",
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				var ttl time.Duration
+				var ok bool
+				cs := caching.NewFakeOSSCachingService()
+				cs.ReturnResourceResponse = caching.CachedResourceDataResponse{
+					UpdateCacheFn: func(ctx context.Context, rdr *backend.CallResourceResponse) {
+						ttl, ok = caching.TTLFromContext(ctx)
+					},
+				}
+				cdt := clienttest.NewClientDecoratorTest(t,
+					clienttest.WithReqContext(req, &user.SignedInUser{}),
+					clienttest.WithMiddlewares(NewCachingMiddlewareWithTTLBounds(cs, nil, minTTL, maxTTL)),
+					clienttest.WithResourceResponses([]*backend.CallResourceResponse{{
+						Status:  http.StatusOK,
+						Headers: map[string][]string{"Cache-Control": {tc.cacheControl}},
+					}}),
+				)
+
+				err := cdt.Decorator.CallResource(req.Context(), &backend.CallResourceRequest{PluginContext: pluginCtx}, nopCallResourceSender)
+				require.NoError(t, err)
+				cs.AssertCalls(t, "HandleResourceRequest", 1)
+				require.Equal(t, tc.expectedOk, ok)
+				require.Equal(t, tc.expectedTTL, ttl)
+			})
+		}
+	})
+}
+
+func TestCachingMiddlewareEligibility(t *testing.T) {
+	pluginCtx := backend.PluginContext{
+		PluginID:                   "test-datasource",
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{},
+	}
+	counts := func(requestType string) (eligible, hits, misses float64) {
+		return testutil.ToFloat64(CachingEligibleCounter.WithLabelValues(pluginCtx.PluginID, requestType)),
+			testutil.ToFloat64(CachingHitCounter.WithLabelValues(pluginCtx.PluginID, requestType)),
+			testutil.ToFloat64(CachingMissCounter.WithLabelValues(pluginCtx.PluginID, requestType))
+	}
+	reset := func() {
+		CachingEligibleCounter.Reset()
+		CachingHitCounter.Reset()
+		CachingMissCounter.Reset()
+	}
+
+	t.Run("When QueryData is called", func(t *testing.T) {
+		t.Cleanup(reset)
+		req, err := http.NewRequest(http.MethodPost, "/query", nil)
+		require.NoError(t, err)
+		cs := caching.NewFakeOSSCachingService()
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{}),
+			clienttest.WithMiddlewares(NewCachingMiddleware(cs)),
+		)
+		qdr := &backend.QueryDataRequest{PluginContext: pluginCtx}
+
+		t.Run("A no-store query is ineligible", func(t *testing.T) {
+			t.Cleanup(reset)
+			req.Header.Set("Cache-Control", "max-age=0, No-Store")
+			t.Cleanup(func() { req.Header.Del("Cache-Control") })
+
+			_, err := cdt.Decorator.QueryData(req.Context(), qdr)
+			require.NoError(t, err)
+			eligible, hits, misses := counts(CachingRequestQuery)
+			require.Zero(t, eligible)
+			require.Zero(t, hits)
+			require.Zero(t, misses)
+		})
+
+		t.Run("An eligible repeated query is a miss then a hit", func(t *testing.T) {
+			t.Cleanup(reset)
+			_, err := cdt.Decorator.QueryData(req.Context(), qdr)
+			require.NoError(t, err)
+			cs.ReturnHit = true
+			cs.ReturnQueryResponse = caching.CachedQueryDataResponse{Response: &backend.QueryDataResponse{}}
+			_, err = cdt.Decorator.QueryData(req.Context(), qdr)
+			require.NoError(t, err)
+
+			eligible, hits, misses := counts(CachingRequestQuery)
+			require.Equal(t, 2.0, eligible)
+			require.Equal(t, 1.0, hits)
+			require.Equal(t, 1.0, misses)
+		})
+	})
+
+	t.Run("When CallResource is called", func(t *testing.T) {
+		t.Cleanup(reset)
+		req, err := http.NewRequest(http.MethodGet, "/resource", nil)
+		require.NoError(t, err)
+		cs := caching.NewFakeOSSCachingService()
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{}),
+			clienttest.WithMiddlewares(NewCachingMiddleware(cs)),
+		)
+
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+			err := cdt.Decorator.CallResource(req.Context(), &backend.CallResourceRequest{PluginContext: pluginCtx, Method: method}, nopCallResourceSender)
+			require.NoError(t, err)
+		}
+		eligible, hits, misses := counts(CachingRequestResource)
+		require.Equal(t, 1.0, eligible)
+		require.Zero(t, hits)
+		require.Equal(t, 1.0, misses)
+	})
+}
+
+// refIDCachingService caches the responses of the single-query requests by refID.
+type refIDCachingService struct {
+	caching.OSSCachingService
+	cached  map[string]backend.DataResponse
+	updated map[string][]*backend.QueryDataResponse
+}
+
+func (s *refIDCachingService) HandleQueryRequest(_ context.Context, req *backend.QueryDataRequest) (bool, caching.CachedQueryDataResponse) {
+	key := ""
+	for _, q := range req.Queries {
+		key += q.RefID
+	}
+	if r, ok := s.cached[key]; ok && len(req.Queries) == 1 {
+		return true, caching.CachedQueryDataResponse{Response: &backend.QueryDataResponse{Responses: backend.Responses{key: r}}}
+	}
+	return false, caching.CachedQueryDataResponse{UpdateCacheFn: func(_ context.Context, resp *backend.QueryDataResponse) {
+		s.updated[key] = append(s.updated[key], resp)
+	}}
+}
+
+func TestCachingMiddlewarePartialHits(t *testing.T) {
+	pluginCtx := backend.PluginContext{
+		PluginID:                   "test-datasource",
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{},
+	}
+	cachedResponse := backend.DataResponse{Frames: data.Frames{data.NewFrame("cached")}}
+	executedResponse := backend.DataResponse{Frames: data.Frames{data.NewFrame("executed")}}
+
+	setup := func(t *testing.T, features *featuremgmt.FeatureManager) (*clienttest.ClientDecoratorTest, *refIDCachingService, *[]string) {
+		t.Helper()
+		t.Cleanup(QueryCachingPartialHitCounter.Reset)
+		req, err := http.NewRequest(http.MethodPost, "/query", nil)
+		require.NoError(t, err)
+		cs := &refIDCachingService{
+			cached:  map[string]backend.DataResponse{"A": cachedResponse},
+			updated: map[string][]*backend.QueryDataResponse{},
+		}
+		var executed []string
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{}),
+			clienttest.WithMiddlewares(NewCachingMiddlewareWithFeatureManager(cs, features)),
+		)
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			resp := backend.NewQueryDataResponse()
+			for _, q := range req.Queries {
+				executed = append(executed, q.RefID)
+				resp.Responses[q.RefID] = executedResponse
+			}
+			return resp, nil
+		}
+		return cdt, cs, &executed
+	}
+	query := func(t *testing.T, cdt *clienttest.ClientDecoratorTest, refIDs ...string) *backend.QueryDataResponse {
+		t.Helper()
+		qdr := &backend.QueryDataRequest{PluginContext: pluginCtx}
+		for _, refID := range refIDs {
+			qdr.Queries = append(qdr.Queries, backend.DataQuery{RefID: refID})
+		}
+		resp, err := cdt.Decorator.QueryData(cdt.ReqContext.Req.Context(), qdr)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("Cached queries are served from the cache and the other ones are executed", func(t *testing.T) {
+		cdt, cs, executed := setup(t, featuremgmt.WithFeatures(featuremgmt.FlagQueryCachingPartialHits))
+
+		resp := query(t, cdt, "A", "B")
+		require.Equal(t, backend.Responses{"A": cachedResponse, "B": executedResponse}, resp.Responses)
+		require.Equal(t, []string{"B"}, *executed)
+		require.Equal(t, 1.0, testutil.ToFloat64(QueryCachingPartialHitCounter.WithLabelValues(pluginCtx.PluginID)))
+
+		// The executed query is cached on its own, and the merged response as a whole
+		require.Equal(t, []*backend.QueryDataResponse{{Responses: backend.Responses{"B": executedResponse}}}, cs.updated["B"])
+		require.Equal(t, []*backend.QueryDataResponse{resp}, cs.updated["AB"])
+	})
+
+	t.Run("Requests without cached queries aren't partial hits", func(t *testing.T) {
+		cdt, _, executed := setup(t, featuremgmt.WithFeatures(featuremgmt.FlagQueryCachingPartialHits))
+
+		resp := query(t, cdt, "B", "C")
+		require.Equal(t, backend.Responses{"B": executedResponse, "C": executedResponse}, resp.Responses)
+		require.Equal(t, []string{"B", "C"}, *executed)
+		require.Zero(t, testutil.ToFloat64(QueryCachingPartialHitCounter.WithLabelValues(pluginCtx.PluginID)))
+	})
+
+	t.Run("Queries are executed as a whole without the feature toggle", func(t *testing.T) {
+		cdt, cs, executed := setup(t, featuremgmt.WithFeatures())
+
+		resp := query(t, cdt, "A", "B")
+		require.Equal(t, backend.Responses{"A": executedResponse, "B": executedResponse}, resp.Responses)
+		require.Equal(t, []string{"A", "B"}, *executed)
+		require.Empty(t, cs.updated["B"])
+		require.Zero(t, testutil.ToFloat64(QueryCachingPartialHitCounter.WithLabelValues(pluginCtx.PluginID)))
+	})
+}
@@ -0,0 +1,181 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/exp/slices"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+// errChaosInjected is the error returned for requests failed by the ChaosMiddleware.
+var errChaosInjected = errors.New("chaos: injected failure")
+
+// ChaosConfig configures the faults injected by the ChaosMiddleware.
+type ChaosConfig struct {
+	// Enabled must be explicitly set to true for any fault to be injected.
+	Enabled bool
+
+	// Seed is used to seed the random number generator, so runs can be reproduced.
+	Seed int64
+
+	// Latency is added to every affected request before calling the plugin.
+	Latency time.Duration
+
+	// ErrorRate is the probability, between 0 and 1, of an affected request failing.
+	ErrorRate float64
+
+	// ErrorSource is the source of the injected errors. Defaults to backend.ErrorSourcePlugin.
+	ErrorSource backend.ErrorSource
+
+	// Endpoints restricts the fault injection to the given endpoints (e.g. "queryData").
+	// If empty, all the endpoints are affected.
+	Endpoints []string
+
+	// PluginIDs restricts the fault injection to the given plugins.
+	// If empty, all the plugins are affected.
+	PluginIDs []string
+}
+
+// NewChaosMiddleware returns a new plugins.ClientMiddleware that deterministically injects latency and errors
+// into plugin requests, in order to validate the behavior of other middlewares under failure.
+// It is meant for resilience testing only, and it's a no-op unless ChaosConfig.Enabled is set.
+func NewChaosMiddleware(cfg ChaosConfig) plugins.ClientMiddleware {
+	if cfg.ErrorSource == "" {
+		cfg.ErrorSource = backend.ErrorSourcePlugin
+	}
+	// The middleware chain is built for every request, so the random source is shared between them.
+	rnd := &lockedRand{rand: rand.New(rand.NewSource(cfg.Seed))}
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		if !cfg.Enabled {
+			return next
+		}
+		return &ChaosMiddleware{
+			next: next,
+			cfg:  cfg,
+			rand: rnd,
+		}
+	})
+}
+
+// lockedRand is a *rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Float64()
+}
+
+type ChaosMiddleware struct {
+	next plugins.Client
+	cfg  ChaosConfig
+	rand *lockedRand
+}
+
+// affects returns true if the given request should be subject to fault injection.
+func (m *ChaosMiddleware) affects(pluginCtx backend.PluginContext, endpoint string) bool {
+	return (len(m.cfg.Endpoints) == 0 || slices.Contains(m.cfg.Endpoints, endpoint)) &&
+		(len(m.cfg.PluginIDs) == 0 || slices.Contains(m.cfg.PluginIDs, pluginCtx.PluginID))
+}
+
+// shouldFail returns true if the current request should fail, according to the configured error rate.
+func (m *ChaosMiddleware) shouldFail() bool {
+	return m.rand.Float64() < m.cfg.ErrorRate
+}
+
+// inject waits for the configured latency and returns true if the request should fail.
+// If the context is cancelled while waiting, its error is returned.
+func (m *ChaosMiddleware) inject(ctx context.Context, pluginCtx backend.PluginContext, endpoint string) (bool, error) {
+	if !m.affects(pluginCtx, endpoint) {
+		return false, nil
+	}
+	if m.cfg.Latency > 0 {
+		timer := time.NewTimer(m.cfg.Latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return m.shouldFail(), nil
+}
+
+// injectedError returns the error for a failed request, and marks the status source as downstream if configured.
+func (m *ChaosMiddleware) injectedError(ctx context.Context) error {
+	if m.cfg.ErrorSource == backend.ErrorSourceDownstream {
+		markDownstream(ctx)
+	}
+	return errChaosInjected
+}
+
+func (m *ChaosMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	fail, err := m.inject(ctx, req.PluginContext, endpointQueryData)
+	if err != nil {
+		return nil, err
+	}
+	if !fail {
+		return m.next.QueryData(ctx, req)
+	}
+
+	// Fail every query, so the error source is picked up like for any other data response.
+	resp := backend.NewQueryDataResponse()
+	for _, q := range req.Queries {
+		resp.Responses[q.RefID] = backend.ErrDataResponseWithSource(backend.StatusInternal, m.cfg.ErrorSource, errChaosInjected.Error())
+	}
+	return resp, nil
+}
+
+func (m *ChaosMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	fail, err := m.inject(ctx, req.PluginContext, endpointCallResource)
+	if err != nil {
+		return err
+	}
+	if fail {
+		return m.injectedError(ctx)
+	}
+	return m.next.CallResource(ctx, req, sender)
+}
+
+func (m *ChaosMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	fail, err := m.inject(ctx, req.PluginContext, endpointCheckHealth)
+	if err != nil {
+		return nil, err
+	}
+	if fail {
+		return nil, m.injectedError(ctx)
+	}
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *ChaosMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	fail, err := m.inject(ctx, req.PluginContext, endpointCollectMetrics)
+	if err != nil {
+		return nil, err
+	}
+	if fail {
+		return nil, m.injectedError(ctx)
+	}
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *ChaosMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *ChaosMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *ChaosMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,133 @@
+package clientmiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestChaosMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	t.Run("Should not inject anything if not enabled", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewChaosMiddleware(ChaosConfig{ErrorRate: 1, Latency: time.Hour}),
+		))
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.NoError(t, err)
+		require.NotNil(t, cdt.CallResourceReq)
+	})
+
+	t.Run("Should inject the configured latency", func(t *testing.T) {
+		const latency = 20 * time.Millisecond
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewChaosMiddleware(ChaosConfig{Enabled: true, Latency: latency}),
+		))
+		start := time.Now()
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, time.Since(start), latency)
+		require.NotNil(t, cdt.CheckHealthReq)
+	})
+
+	t.Run("Should stop waiting if the context is cancelled", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewChaosMiddleware(ChaosConfig{Enabled: true, Latency: time.Hour}),
+		))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Nil(t, cdt.CheckHealthReq)
+	})
+
+	t.Run("Should inject errors at the configured rate", func(t *testing.T) {
+		const (
+			requests  = 2000
+			errorRate = 0.3
+			tolerance = 0.05
+		)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewChaosMiddleware(ChaosConfig{Enabled: true, ErrorRate: errorRate, Seed: 42}),
+		))
+		var failures int
+		for i := 0; i < requests; i++ {
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			if err != nil {
+				require.ErrorIs(t, err, errChaosInjected)
+				failures++
+			}
+		}
+		require.InDelta(t, errorRate, float64(failures)/requests, tolerance)
+	})
+
+	t.Run("Should be reproducible with the same seed", func(t *testing.T) {
+		run := func() []bool {
+			cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+				NewChaosMiddleware(ChaosConfig{Enabled: true, ErrorRate: 0.5, Seed: 1234}),
+			))
+			results := make([]bool, 50)
+			for i := range results {
+				_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+				results[i] = err != nil
+			}
+			return results
+		}
+		require.Equal(t, run(), run())
+	})
+
+	t.Run("Should only affect the configured endpoints and plugins", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewChaosMiddleware(ChaosConfig{
+				Enabled:   true,
+				ErrorRate: 1,
+				Endpoints: []string{endpointCheckHealth},
+				PluginIDs: []string{pluginID},
+			}),
+		))
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errChaosInjected)
+
+		err = cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.NoError(t, err)
+
+		_, err = cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{
+			PluginContext: backend.PluginContext{PluginID: "other-plugin"},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Should fail every query with the configured error source", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewChaosMiddleware(ChaosConfig{Enabled: true, ErrorRate: 1, ErrorSource: backend.ErrorSourceDownstream}),
+		))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			require.FailNow(t, "plugin should not be called")
+			return nil, nil
+		}
+
+		resp, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: pCtx,
+			Queries:       []backend.DataQuery{{RefID: "A"}, {RefID: "B"}},
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Responses, 2)
+		for _, r := range resp.Responses {
+			require.Error(t, r.Error)
+			require.Equal(t, backend.ErrorSourceDownstream, r.ErrorSource)
+		}
+
+		// Downstream errors for other endpoints are reported through the status source
+		ctx := pluginrequestmeta.WithStatusSource(context.Background(), pluginrequestmeta.StatusSourcePlugin)
+		_, err = cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errChaosInjected)
+		require.Equal(t, pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourceFromContext(ctx))
+	})
+}
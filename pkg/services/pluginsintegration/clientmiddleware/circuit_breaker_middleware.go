@@ -0,0 +1,252 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+var errCircuitOpen = errutil.BadGateway("plugin.circuitOpen",
+	errutil.WithPublicMessage("Plugin is unavailable, too many of its recent requests failed"))
+
+const (
+	defaultCircuitBreakerFailureRatio = 0.5
+	defaultCircuitBreakerMinRequests  = 10
+	defaultCircuitBreakerWindow       = time.Minute
+	defaultCircuitBreakerOpenTimeout  = 30 * time.Second
+)
+
+// circuitState is the state of a circuit breaker. Its value is the one of the state gauge.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+// CircuitBreakerConfig configures the circuit breakers of the CircuitBreakerMiddleware.
+type CircuitBreakerConfig struct {
+	// FailureRatio is the ratio of failed requests within the window above which the breaker opens.
+	// Defaults to 0.5.
+	FailureRatio float64
+
+	// MinRequests is the number of requests within the window below which the breaker doesn't open,
+	// whatever their failure ratio. Defaults to 10.
+	MinRequests int
+
+	// Window is the duration over which the requests and their failures are counted. Defaults to 1m.
+	Window time.Duration
+
+	// OpenTimeout is how long the breaker stays open before letting a probe request through. Defaults to 30s.
+	OpenTimeout time.Duration
+}
+
+// circuitBreaker is the circuit breaker of a plugin.
+type circuitBreaker struct {
+	state       circuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+// circuitBreakers keeps the circuit breaker of each plugin. They're shared between the middleware instances,
+// since the middleware chain is built for every request.
+type circuitBreakers struct {
+	cfg   CircuitBreakerConfig
+	now   func() time.Time
+	state *prometheus.GaugeVec
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// allow returns true if a request to the plugin can go through, and if it's the probe of a half-open breaker.
+func (c *circuitBreakers) allow(pluginID string) (allowed bool, probe bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.breakers[pluginID]
+	if !ok {
+		b = &circuitBreaker{windowStart: c.now()}
+		c.breakers[pluginID] = b
+		c.setState(pluginID, b, circuitClosed)
+	}
+
+	switch b.state {
+	case circuitOpen:
+		if c.now().Sub(b.openedAt) < c.cfg.OpenTimeout {
+			return false, false
+		}
+		c.setState(pluginID, b, circuitHalfOpen)
+		fallthrough
+	case circuitHalfOpen:
+		// Only one probe at a time, the other requests keep failing fast until it's done
+		if b.probing {
+			return false, false
+		}
+		b.probing = true
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// done records the outcome of an allowed request to the plugin.
+func (c *circuitBreakers) done(pluginID string, probe bool, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.breakers[pluginID]
+	now := c.now()
+	if probe {
+		b.probing = false
+		if failed {
+			b.openedAt = now
+			c.setState(pluginID, b, circuitOpen)
+			return
+		}
+		b.windowStart, b.requests, b.failures = now, 0, 0
+		c.setState(pluginID, b, circuitClosed)
+		return
+	}
+	if b.state != circuitClosed {
+		// Finished after a concurrent request opened the breaker
+		return
+	}
+
+	if now.Sub(b.windowStart) >= c.cfg.Window {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= c.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= c.cfg.FailureRatio {
+		b.openedAt = now
+		c.setState(pluginID, b, circuitOpen)
+	}
+}
+
+// setState sets the state of the breaker and its gauge. c.mu must be held.
+func (c *circuitBreakers) setState(pluginID string, b *circuitBreaker, state circuitState) {
+	b.state = state
+	c.state.WithLabelValues(pluginID).Set(float64(state))
+}
+
+// NewCircuitBreakerMiddleware returns a new plugins.ClientMiddleware that stops calling a plugin once too many of
+// its requests failed, failing them fast with a downstream error instead. After the configured timeout, a single
+// probe request is let through: the breaker is closed again if it succeeds, or stays open otherwise.
+// Only QueryData, CallResource and CheckHealth requests are subject to the breaker, and cancelled requests aren't
+// counted as failures.
+func NewCircuitBreakerMiddleware(cfg CircuitBreakerConfig, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	return newCircuitBreakerMiddleware(cfg, promRegisterer, time.Now)
+}
+
+func newCircuitBreakerMiddleware(cfg CircuitBreakerConfig, promRegisterer prometheus.Registerer, now func() time.Time) plugins.ClientMiddleware {
+	if cfg.FailureRatio <= 0 {
+		cfg.FailureRatio = defaultCircuitBreakerFailureRatio
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = defaultCircuitBreakerMinRequests
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultCircuitBreakerWindow
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = defaultCircuitBreakerOpenTimeout
+	}
+
+	state := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Name:      "plugin_circuit_breaker_state",
+		Help:      "The state of the circuit breaker of the plugin: 0 if closed, 1 if half-open, 2 if open",
+	}, []string{"plugin_id"})
+	promRegisterer.MustRegister(state)
+
+	breakers := &circuitBreakers{
+		cfg:      cfg,
+		now:      now,
+		state:    state,
+		breakers: map[string]*circuitBreaker{},
+	}
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &CircuitBreakerMiddleware{
+			next:     next,
+			breakers: breakers,
+		}
+	})
+}
+
+type CircuitBreakerMiddleware struct {
+	next     plugins.Client
+	breakers *circuitBreakers
+}
+
+// guard calls fn if the circuit breaker of the plugin allows it, and records its outcome.
+func (m *CircuitBreakerMiddleware) guard(ctx context.Context, pluginCtx backend.PluginContext, fn func() error) error {
+	allowed, probe := m.breakers.allow(pluginCtx.PluginID)
+	if !allowed {
+		markDownstream(ctx)
+		return errorsource.DownstreamError(errCircuitOpen.Errorf("circuit breaker of plugin %s is open", pluginCtx.PluginID), false)
+	}
+
+	// Record the outcome of a request that panics too, so that a probe doesn't block the breaker forever
+	failed := true
+	defer func() { m.breakers.done(pluginCtx.PluginID, probe, failed) }()
+	err := fn()
+	failed = err != nil && !errors.Is(err, context.Canceled)
+	return err
+}
+
+func (m *CircuitBreakerMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	var resp *backend.QueryDataResponse
+	err := m.guard(ctx, req.PluginContext, func() error {
+		var err error
+		resp, err = m.next.QueryData(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *CircuitBreakerMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.guard(ctx, req.PluginContext, func() error {
+		return m.next.CallResource(ctx, req, sender)
+	})
+}
+
+func (m *CircuitBreakerMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	var resp *backend.CheckHealthResult
+	err := m.guard(ctx, req.PluginContext, func() error {
+		var err error
+		resp, err = m.next.CheckHealth(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *CircuitBreakerMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *CircuitBreakerMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *CircuitBreakerMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *CircuitBreakerMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
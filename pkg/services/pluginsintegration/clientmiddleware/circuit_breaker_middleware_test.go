@@ -0,0 +1,166 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	errPlugin := errors.New("plugin failed")
+
+	type circuitBreakerTest struct {
+		*clienttest.ClientDecoratorTest
+		registry *prometheus.Registry
+		now      time.Time
+		fail     bool
+		calls    int
+	}
+
+	setup := func(t *testing.T, cfg CircuitBreakerConfig) *circuitBreakerTest {
+		cbt := &circuitBreakerTest{
+			registry: prometheus.NewRegistry(),
+			now:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		cbt.ClientDecoratorTest = clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			newCircuitBreakerMiddleware(cfg, cbt.registry, func() time.Time { return cbt.now }),
+		))
+		cbt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			cbt.calls++
+			if cbt.fail {
+				return nil, errPlugin
+			}
+			return &backend.CheckHealthResult{Status: backend.HealthStatusOk}, nil
+		}
+		return cbt
+	}
+
+	checkHealth := func(cbt *circuitBreakerTest) error {
+		_, err := cbt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		return err
+	}
+
+	state := func(t *testing.T, cbt *circuitBreakerTest) circuitState {
+		g, err := cbt.registry.Gather()
+		require.NoError(t, err)
+		require.Len(t, g, 1)
+		for _, m := range g[0].GetMetric() {
+			if m.GetLabel()[0].GetValue() == pluginID {
+				return circuitState(m.GetGauge().GetValue())
+			}
+		}
+		require.FailNow(t, "no circuit breaker state for plugin")
+		return 0
+	}
+
+	t.Run("Should go through closed, open, half-open and closed states", func(t *testing.T) {
+		cbt := setup(t, CircuitBreakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: time.Minute, OpenTimeout: 10 * time.Second})
+
+		// Closed: two successes and one failure stay under the minimum number of requests
+		require.NoError(t, checkHealth(cbt))
+		require.NoError(t, checkHealth(cbt))
+		require.Equal(t, circuitClosed, state(t, cbt))
+		cbt.fail = true
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.Equal(t, circuitClosed, state(t, cbt))
+
+		// Open: the second failure reaches the failure ratio
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.Equal(t, circuitOpen, state(t, cbt))
+		require.Equal(t, 4, cbt.calls)
+
+		// Requests fail fast with a downstream error while the breaker is open
+		ctx := pluginrequestmeta.WithStatusSource(context.Background(), pluginrequestmeta.StatusSourcePlugin)
+		_, err := cbt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errCircuitOpen)
+		require.Equal(t, pluginrequestmeta.StatusSourceDownstream, errorStatusSource(err))
+		require.Equal(t, pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourceFromContext(ctx))
+		require.Equal(t, 4, cbt.calls)
+
+		// Half-open: a failed probe opens the breaker again
+		cbt.now = cbt.now.Add(10 * time.Second)
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.Equal(t, 5, cbt.calls)
+		require.Equal(t, circuitOpen, state(t, cbt))
+		require.ErrorIs(t, checkHealth(cbt), errCircuitOpen)
+		require.Equal(t, 5, cbt.calls)
+
+		// Closed: a successful probe closes the breaker
+		cbt.now = cbt.now.Add(10 * time.Second)
+		cbt.fail = false
+		require.NoError(t, checkHealth(cbt))
+		require.Equal(t, circuitClosed, state(t, cbt))
+		require.NoError(t, checkHealth(cbt))
+		require.Equal(t, 7, cbt.calls)
+	})
+
+	t.Run("Should let a single probe through while half-open", func(t *testing.T) {
+		cbt := setup(t, CircuitBreakerConfig{MinRequests: 1, OpenTimeout: time.Second})
+		cbt.fail = true
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.Equal(t, circuitOpen, state(t, cbt))
+
+		cbt.now = cbt.now.Add(time.Second)
+		probing := make(chan struct{})
+		release := make(chan struct{})
+		cbt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			close(probing)
+			<-release
+			return &backend.CheckHealthResult{}, nil
+		}
+		probeErr := make(chan error)
+		go func() { probeErr <- checkHealth(cbt) }()
+
+		<-probing
+		require.Equal(t, circuitHalfOpen, state(t, cbt))
+		require.ErrorIs(t, checkHealth(cbt), errCircuitOpen)
+		close(release)
+		require.NoError(t, <-probeErr)
+		require.Equal(t, circuitClosed, state(t, cbt))
+	})
+
+	t.Run("Should only count the failures within the window", func(t *testing.T) {
+		cbt := setup(t, CircuitBreakerConfig{FailureRatio: 1, MinRequests: 2, Window: time.Minute})
+		cbt.fail = true
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		cbt.now = cbt.now.Add(time.Minute)
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.Equal(t, circuitClosed, state(t, cbt))
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.Equal(t, circuitOpen, state(t, cbt))
+	})
+
+	t.Run("Should not count cancelled requests as failures", func(t *testing.T) {
+		cbt := setup(t, CircuitBreakerConfig{MinRequests: 1})
+		cbt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return nil, fmt.Errorf("query: %w", context.Canceled)
+		}
+		_, err := cbt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, circuitClosed, state(t, cbt))
+	})
+
+	t.Run("Should keep a breaker per plugin", func(t *testing.T) {
+		cbt := setup(t, CircuitBreakerConfig{MinRequests: 1})
+		cbt.fail = true
+		require.ErrorIs(t, checkHealth(cbt), errPlugin)
+		require.ErrorIs(t, checkHealth(cbt), errCircuitOpen)
+
+		_, err := cbt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{
+			PluginContext: backend.PluginContext{PluginID: "other-plugin"},
+		})
+		require.ErrorIs(t, err, errPlugin)
+		require.Equal(t, 2, testutil.CollectAndCount(cbt.registry, "grafana_plugin_circuit_breaker_state"))
+	})
+}
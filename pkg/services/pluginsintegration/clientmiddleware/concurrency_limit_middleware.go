@@ -0,0 +1,186 @@
+package clientmiddleware
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+var errConcurrencyLimitExceeded = errutil.TooManyRequests("plugin.concurrencyLimitExceeded",
+	errutil.WithPublicMessage("Too many concurrent requests to the plugin"))
+
+const defaultConcurrencyLimitMaxConcurrent = 10
+
+// ConcurrencyLimitConfig configures the limits of the ConcurrencyLimitMiddleware.
+type ConcurrencyLimitConfig struct {
+	// MaxConcurrent is the maximum number of concurrent QueryData and CallResource requests to a plugin.
+	// Defaults to 10.
+	MaxConcurrent int64
+
+	// MaxQueued is the maximum number of requests to a plugin waiting for one of the concurrent ones to end.
+	// The requests over it are rejected. Defaults to 0, so the requests over the limit are rejected right away.
+	MaxQueued int64
+}
+
+// concurrencyLimiter limits the concurrent requests to a plugin.
+type concurrencyLimiter struct {
+	sem *semaphore.Weighted
+
+	mu     sync.Mutex
+	queued int64
+}
+
+// concurrencyLimiters keeps the concurrency limiter of each plugin. Like the circuitBreakers, they're shared between
+// the middleware instances.
+type concurrencyLimiters struct {
+	cfg        ConcurrencyLimitConfig
+	queueDepth *prometheus.GaugeVec
+
+	mu       sync.Mutex
+	limiters map[string]*concurrencyLimiter
+}
+
+func (c *concurrencyLimiters) get(pluginID string) *concurrencyLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.limiters[pluginID]
+	if !ok {
+		l = &concurrencyLimiter{sem: semaphore.NewWeighted(c.cfg.MaxConcurrent)}
+		c.limiters[pluginID] = l
+	}
+	return l
+}
+
+// acquire acquires a slot for a request to the plugin, waiting in the queue if there's still room in it.
+// It returns the position of the request in the queue when it started waiting, or 0 if it didn't wait, and an
+// error if the request is rejected, or if ctx is done while waiting.
+func (c *concurrencyLimiters) acquire(ctx context.Context, pluginID string) (*concurrencyLimiter, int64, error) {
+	l := c.get(pluginID)
+	if l.sem.TryAcquire(1) {
+		return l, 0, nil
+	}
+
+	l.mu.Lock()
+	if l.queued >= c.cfg.MaxQueued {
+		l.mu.Unlock()
+		return nil, 0, errConcurrencyLimitExceeded.Errorf("plugin %s reached its limit of %d concurrent requests", pluginID, c.cfg.MaxConcurrent)
+	}
+	l.queued++
+	position := l.queued
+	c.queueDepth.WithLabelValues(pluginID).Inc()
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		c.queueDepth.WithLabelValues(pluginID).Dec()
+		l.mu.Unlock()
+	}()
+	if err := l.sem.Acquire(ctx, 1); err != nil {
+		return nil, position, err
+	}
+	return l, position, nil
+}
+
+// NewConcurrencyLimitMiddleware returns a new plugins.ClientMiddleware that limits the number of concurrent
+// QueryData and CallResource requests to each plugin, so that a flood of requests to one plugin doesn't starve the
+// other ones. The requests over the limit wait for a slot in a bounded queue, and are rejected with a downstream
+// "too many requests" error once the queue is full.
+func NewConcurrencyLimitMiddleware(cfg ConcurrencyLimitConfig, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = defaultConcurrencyLimitMaxConcurrent
+	}
+	if cfg.MaxQueued < 0 {
+		cfg.MaxQueued = 0
+	}
+
+	queueDepth := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Name:      "plugin_request_queue_depth",
+		Help:      "The number of plugin requests waiting for the plugin concurrency limit",
+	}, []string{"plugin_id"})
+	promRegisterer.MustRegister(queueDepth)
+
+	limiters := &concurrencyLimiters{
+		cfg:        cfg,
+		queueDepth: queueDepth,
+		limiters:   map[string]*concurrencyLimiter{},
+	}
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &ConcurrencyLimitMiddleware{
+			next:     next,
+			limiters: limiters,
+		}
+	})
+}
+
+type ConcurrencyLimitMiddleware struct {
+	next     plugins.Client
+	limiters *concurrencyLimiters
+}
+
+// limit calls fn once the concurrency limit of the plugin allows it, with the queue position of the request
+// set in the plugin request meta of its context.
+func (m *ConcurrencyLimitMiddleware) limit(ctx context.Context, pluginCtx backend.PluginContext, fn func(ctx context.Context) error) error {
+	l, position, err := m.limiters.acquire(ctx, pluginCtx.PluginID)
+	if position > 0 {
+		// Without the plugin request meta middleware, the queue position is only visible to the next middlewares
+		if err := pluginrequestmeta.SetQueuePosition(ctx, position); err != nil {
+			ctx = pluginrequestmeta.WithQueuePosition(ctx, position)
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		markDownstream(ctx)
+		return errorsource.DownstreamError(err, false)
+	}
+	// Deferred, so the slot isn't lost if the request panics
+	defer l.sem.Release(1)
+	return fn(ctx)
+}
+
+func (m *ConcurrencyLimitMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	var resp *backend.QueryDataResponse
+	err := m.limit(ctx, req.PluginContext, func(ctx context.Context) error {
+		var err error
+		resp, err = m.next.QueryData(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *ConcurrencyLimitMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.limit(ctx, req.PluginContext, func(ctx context.Context) error {
+		return m.next.CallResource(ctx, req, sender)
+	})
+}
+
+func (m *ConcurrencyLimitMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *ConcurrencyLimitMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *ConcurrencyLimitMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *ConcurrencyLimitMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *ConcurrencyLimitMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,192 @@
+package clientmiddleware
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	type concurrencyLimitTest struct {
+		*clienttest.ClientDecoratorTest
+		registry *prometheus.Registry
+		started  chan struct{}
+		release  chan struct{}
+	}
+
+	// setup returns a test whose QueryData requests block until release is closed.
+	setup := func(t *testing.T, cfg ConcurrencyLimitConfig) *concurrencyLimitTest {
+		clt := &concurrencyLimitTest{
+			registry: prometheus.NewRegistry(),
+			started:  make(chan struct{}, 10),
+			release:  make(chan struct{}),
+		}
+		clt.ClientDecoratorTest = clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewConcurrencyLimitMiddleware(cfg, clt.registry),
+		))
+		clt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			clt.started <- struct{}{}
+			<-clt.release
+			return backend.NewQueryDataResponse(), nil
+		}
+		return clt
+	}
+
+	queryData := func(ctx context.Context, clt *concurrencyLimitTest, pCtx backend.PluginContext) <-chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := clt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: pCtx})
+			errCh <- err
+		}()
+		return errCh
+	}
+
+	queueDepth := func(t *testing.T, clt *concurrencyLimitTest) float64 {
+		mfs, err := clt.registry.Gather()
+		require.NoError(t, err)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				if m.GetLabel()[0].GetValue() == pluginID {
+					return m.GetGauge().GetValue()
+				}
+			}
+		}
+		return 0
+	}
+
+	t.Run("Should reject the requests over the limit", func(t *testing.T) {
+		clt := setup(t, ConcurrencyLimitConfig{MaxConcurrent: 2})
+		first := queryData(context.Background(), clt, pCtx)
+		second := queryData(context.Background(), clt, pCtx)
+		<-clt.started
+		<-clt.started
+
+		ctx := pluginrequestmeta.WithStatusSource(context.Background(), pluginrequestmeta.StatusSourcePlugin)
+		err := clt.Decorator.CallResource(ctx, &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.ErrorIs(t, err, errConcurrencyLimitExceeded)
+		require.Equal(t, pluginrequestmeta.StatusSourceDownstream, errorStatusSource(err))
+		require.Equal(t, pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourceFromContext(ctx))
+
+		// Other plugins are not affected
+		err = clt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{
+			PluginContext: backend.PluginContext{PluginID: "other-plugin"},
+		}, nopCallResourceSender)
+		require.NoError(t, err)
+
+		close(clt.release)
+		require.NoError(t, <-first)
+		require.NoError(t, <-second)
+
+		// The slots are released once the requests are done
+		err = clt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.NoError(t, err)
+	})
+
+	t.Run("Should queue the requests over the limit while there's room in the queue", func(t *testing.T) {
+		clt := setup(t, ConcurrencyLimitConfig{MaxConcurrent: 1, MaxQueued: 1})
+		first := queryData(context.Background(), clt, pCtx)
+		<-clt.started
+
+		second := queryData(context.Background(), clt, pCtx)
+		require.Eventually(t, func() bool { return queueDepth(t, clt) == 1 }, time.Second, time.Millisecond)
+
+		_, err := clt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errConcurrencyLimitExceeded)
+
+		close(clt.release)
+		require.NoError(t, <-first)
+		require.NoError(t, <-second)
+		require.Len(t, clt.started, 1, "the queued request should have been run")
+		require.Equal(t, float64(0), queueDepth(t, clt))
+	})
+
+	t.Run("Should set the queue position of the queued requests", func(t *testing.T) {
+		clt := setup(t, ConcurrencyLimitConfig{MaxConcurrent: 1, MaxQueued: 3})
+		var mu sync.Mutex
+		positions := map[string]int64{}
+		clt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			mu.Lock()
+			positions[req.Queries[0].RefID] = pluginrequestmeta.QueuePositionFromContext(ctx)
+			mu.Unlock()
+			clt.started <- struct{}{}
+			<-clt.release
+			return backend.NewQueryDataResponse(), nil
+		}
+		queryData := func(ctx context.Context, refID string) <-chan error {
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := clt.Decorator.QueryData(ctx, &backend.QueryDataRequest{
+					PluginContext: pCtx,
+					Queries:       []backend.DataQuery{{RefID: refID}},
+				})
+				errCh <- err
+			}()
+			return errCh
+		}
+
+		first := queryData(context.Background(), "A")
+		<-clt.started
+
+		// The requests are queued one after the other, and their positions are kept in the plugin request
+		// meta of their context for the middlewares that ran before
+		refIDs := []string{"B", "C", "D"}
+		ctxs := make([]context.Context, len(refIDs))
+		errChs := make([]<-chan error, len(refIDs))
+		for i, refID := range refIDs {
+			ctxs[i] = pluginrequestmeta.WithQueuePosition(context.Background(), 0)
+			errChs[i] = queryData(ctxs[i], refID)
+			require.Eventually(t, func() bool { return queueDepth(t, clt) == float64(i+1) }, time.Second, time.Millisecond)
+		}
+
+		close(clt.release)
+		require.NoError(t, <-first)
+		for _, errCh := range errChs {
+			require.NoError(t, <-errCh)
+		}
+
+		require.Zero(t, positions["A"])
+		for i, refID := range refIDs {
+			require.Equal(t, int64(i+1), positions[refID])
+			require.Equal(t, int64(i+1), pluginrequestmeta.QueuePositionFromContext(ctxs[i]))
+		}
+	})
+
+	t.Run("Should stop waiting in the queue when the context is done", func(t *testing.T) {
+		clt := setup(t, ConcurrencyLimitConfig{MaxConcurrent: 1, MaxQueued: 1})
+		first := queryData(context.Background(), clt, pCtx)
+		<-clt.started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := clt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		close(clt.release)
+		require.NoError(t, <-first)
+	})
+
+	t.Run("Should release the slot if the request panics", func(t *testing.T) {
+		clt := setup(t, ConcurrencyLimitConfig{MaxConcurrent: 1})
+		clt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			panic("boom")
+		}
+
+		require.Panics(t, func() {
+			_ = clt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		})
+
+		close(clt.release)
+		_, err := clt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,108 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrorCategory is a normalized category of the errors returned by the plugins or their downstream services.
+// Unlike the raw error messages, the set of categories is bounded, so it can be used as a Prometheus label.
+type ErrorCategory string
+
+const (
+	ErrorCategoryTimeout     ErrorCategory = "timeout"
+	ErrorCategoryAuth        ErrorCategory = "auth"
+	ErrorCategoryConnection  ErrorCategory = "connection"
+	ErrorCategoryQuerySyntax ErrorCategory = "query-syntax"
+	ErrorCategoryRateLimited ErrorCategory = "rate-limited"
+	ErrorCategoryOther       ErrorCategory = "other"
+)
+
+// knownErrorCategories are the categories reported in the "error_category" label.
+var knownErrorCategories = map[ErrorCategory]struct{}{
+	ErrorCategoryTimeout:     {},
+	ErrorCategoryAuth:        {},
+	ErrorCategoryConnection:  {},
+	ErrorCategoryQuerySyntax: {},
+	ErrorCategoryRateLimited: {},
+	ErrorCategoryOther:       {},
+}
+
+// ErrorClassifier maps an error to its ErrorCategory.
+// It returns an empty category if it can't classify the error, to let the next classifier try.
+type ErrorClassifier func(err error) ErrorCategory
+
+// errorCategoryMessages are the substrings of the lowercased error messages that identify a category,
+// for the errors that lost their type on the way, e.g. because they went through gRPC or a downstream HTTP API.
+// The categories are checked in order, so that e.g. "authentication timed out" is a timeout.
+var errorCategoryMessages = []struct {
+	category ErrorCategory
+	messages []string
+}{
+	{ErrorCategoryTimeout, []string{"timeout", "timed out", "deadline exceeded"}},
+	{ErrorCategoryRateLimited, []string{"too many requests", "rate limit", "ratelimit", "throttl", "quota exceeded"}},
+	{ErrorCategoryAuth, []string{"unauthorized", "unauthorised", "forbidden", "permission denied", "access denied", "authentication", "invalid credentials", "invalid token"}},
+	{ErrorCategoryConnection, []string{"connection refused", "connection reset", "no such host", "broken pipe", "network is unreachable", "no route to host", "unexpected eof"}},
+	{ErrorCategoryQuerySyntax, []string{"syntax error", "parse error", "failed to parse", "invalid query", "unexpected token", "bad_data"}},
+}
+
+// DefaultErrorClassifier classifies the errors by their type first, and by their message otherwise.
+// It classifies all errors, falling back to ErrorCategoryOther.
+func DefaultErrorClassifier(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorCategoryTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorCategoryTimeout
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorCategoryConnection
+	}
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(err, &dnsErr) || errors.As(err, &opErr) {
+		return ErrorCategoryConnection
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, c := range errorCategoryMessages {
+		for _, m := range c.messages {
+			if strings.Contains(msg, m) {
+				return c.category
+			}
+		}
+	}
+	return ErrorCategoryOther
+}
+
+// newErrorClassifier returns an ErrorClassifier that tries the given classifiers in order,
+// and then DefaultErrorClassifier.
+// Categories returned by the given classifiers that aren't known are reported as ErrorCategoryOther,
+// to keep the label cardinality bounded.
+func newErrorClassifier(classifiers ...ErrorClassifier) ErrorClassifier {
+	return func(err error) ErrorCategory {
+		if err == nil {
+			return ""
+		}
+		for _, classify := range classifiers {
+			category := classify(err)
+			if category == "" {
+				continue
+			}
+			if _, ok := knownErrorCategories[category]; !ok {
+				return ErrorCategoryOther
+			}
+			return category
+		}
+		return DefaultErrorClassifier(err)
+	}
+}
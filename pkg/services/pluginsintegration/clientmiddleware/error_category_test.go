@@ -0,0 +1,82 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	for _, tc := range []struct {
+		err         error
+		expCategory ErrorCategory
+	}{
+		{err: nil, expCategory: ""},
+		{err: context.DeadlineExceeded, expCategory: ErrorCategoryTimeout},
+		{err: fmt.Errorf("query failed: %w", os.ErrDeadlineExceeded), expCategory: ErrorCategoryTimeout},
+		{err: &net.DNSError{Err: "i/o timeout", Name: "db", IsTimeout: true}, expCategory: ErrorCategoryTimeout},
+		{err: errors.New("rpc error: code = DeadlineExceeded desc = context deadline exceeded"), expCategory: ErrorCategoryTimeout},
+		{err: errors.New("request timed out after 30s"), expCategory: ErrorCategoryTimeout},
+		{err: errors.New("429 Too Many Requests"), expCategory: ErrorCategoryRateLimited},
+		{err: errors.New("Rate limit exceeded, retry later"), expCategory: ErrorCategoryRateLimited},
+		{err: errors.New("ThrottlingException: Rate exceeded"), expCategory: ErrorCategoryRateLimited},
+		{err: errors.New("401 Unauthorized"), expCategory: ErrorCategoryAuth},
+		{err: errors.New("pq: password authentication failed for user \"grafana\""), expCategory: ErrorCategoryAuth},
+		{err: errors.New("AccessDenied: Access Denied"), expCategory: ErrorCategoryAuth},
+		{err: syscall.ECONNREFUSED, expCategory: ErrorCategoryConnection},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNRESET}, expCategory: ErrorCategoryConnection},
+		{err: &net.DNSError{Err: "no such host", Name: "db"}, expCategory: ErrorCategoryConnection},
+		{err: fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), expCategory: ErrorCategoryConnection},
+		{err: errors.New("dial tcp 10.0.0.1:5432: connect: connection refused"), expCategory: ErrorCategoryConnection},
+		{err: errors.New("bad_data: 1:5: parse error: unexpected right parenthesis ')'"), expCategory: ErrorCategoryQuerySyntax},
+		{err: errors.New("Error 1064: You have an error in your SQL syntax; syntax error near 'FORM'"), expCategory: ErrorCategoryQuerySyntax},
+		{err: errors.New("something went wrong"), expCategory: ErrorCategoryOther},
+		{err: context.Canceled, expCategory: ErrorCategoryOther},
+	} {
+		name := "nil"
+		if tc.err != nil {
+			name = tc.err.Error()
+		}
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expCategory, DefaultErrorClassifier(tc.err))
+		})
+	}
+}
+
+func TestErrorClassifierExtension(t *testing.T) {
+	errVendor := errors.New("ORA-12541: TNS:no listener")
+	classify := newErrorClassifier(
+		func(err error) ErrorCategory {
+			if errors.Is(err, errVendor) {
+				return ErrorCategoryConnection
+			}
+			return ""
+		},
+		func(err error) ErrorCategory {
+			if err.Error() == "unbounded" {
+				return ErrorCategory("custom")
+			}
+			return ""
+		},
+	)
+
+	t.Run("Should use the first category returned by the classifiers", func(t *testing.T) {
+		require.Equal(t, ErrorCategoryConnection, classify(fmt.Errorf("health check: %w", errVendor)))
+	})
+
+	t.Run("Should fall back to the default classifier", func(t *testing.T) {
+		require.Equal(t, ErrorCategoryTimeout, classify(context.DeadlineExceeded))
+		require.Equal(t, ErrorCategoryOther, classify(errors.New("something went wrong")))
+	})
+
+	t.Run("Should fold unknown categories to other", func(t *testing.T) {
+		require.Equal(t, ErrorCategoryOther, classify(errors.New("unbounded")))
+	})
+}
@@ -0,0 +1,67 @@
+package clientmiddleware
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+// NewFanOutMiddleware returns a new plugins.ClientMiddleware that counts the QueryData, CallResource and
+// CheckHealth requests in the plugin call counter of their context, if it has one, to know how many plugin
+// calls the origin of the counter fans out into. The plugins called are recorded in the context too.
+func NewFanOutMiddleware() plugins.ClientMiddleware {
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &FanOutMiddleware{
+			next: next,
+		}
+	})
+}
+
+type FanOutMiddleware struct {
+	next plugins.Client
+}
+
+func (m *FanOutMiddleware) count(ctx context.Context, pCtx backend.PluginContext) {
+	pluginrequestmeta.CountPluginCall(ctx)
+	pluginrequestmeta.RecordCalledPlugin(ctx, pCtx.PluginID)
+}
+
+func (m *FanOutMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	if req != nil {
+		m.count(ctx, req.PluginContext)
+	}
+	return m.next.QueryData(ctx, req)
+}
+
+func (m *FanOutMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req != nil {
+		m.count(ctx, req.PluginContext)
+	}
+	return m.next.CallResource(ctx, req, sender)
+}
+
+func (m *FanOutMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	if req != nil {
+		m.count(ctx, req.PluginContext)
+	}
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *FanOutMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *FanOutMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *FanOutMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *FanOutMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,51 @@
+package clientmiddleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/middleware"
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestFanOutMiddleware(t *testing.T) {
+	t.Run("Should record the plugin calls made by a request", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewFanOutMiddleware()))
+		handler := middleware.PluginFanOut(registry)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 3; i++ {
+				_, err := cdt.Decorator.QueryData(r.Context(), &backend.QueryDataRequest{})
+				require.NoError(t, err)
+			}
+		}))
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/ds/query", nil))
+		// Requests without plugin calls aren't recorded
+		handler = middleware.PluginFanOut(prometheus.NewRegistry())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+		metrics, err := registry.Gather()
+		require.NoError(t, err)
+		require.Len(t, metrics, 1)
+		require.Equal(t, "grafana_plugin_request_fanout", metrics[0].GetName())
+		require.Len(t, metrics[0].GetMetric(), 1)
+		histogram := metrics[0].GetMetric()[0].GetHistogram()
+		require.Equal(t, uint64(1), histogram.GetSampleCount())
+		require.Equal(t, 3.0, histogram.GetSampleSum())
+	})
+
+	t.Run("Should record the plugin called", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewFanOutMiddleware()))
+		ctx, called := pluginrequestmeta.WithCalledPlugin(context.Background())
+		_, err := cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}})
+		require.NoError(t, err)
+		require.Equal(t, "prometheus", called.ID())
+	})
+}
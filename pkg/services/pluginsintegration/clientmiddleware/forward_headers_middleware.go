@@ -0,0 +1,111 @@
+package clientmiddleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/exp/slices"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	plog "github.com/grafana/grafana/pkg/plugins/log"
+	"github.com/grafana/grafana/pkg/services/contexthandler"
+)
+
+// NewForwardHeadersMiddleware creates a new plugins.ClientMiddleware that will forward the given headers of
+// the incoming HTTP request to the QueryData and CallResource requests, e.g. tenant headers needed by a data source.
+// Only the given headers are forwarded, and the cookies never are, as they are handled by the CookiesMiddleware.
+// Headers already set on a request are not overwritten. The values of the sensitive headers, such as Authorization,
+// are redacted from the logs.
+func NewForwardHeadersMiddleware(headers []string, logger plog.Logger) plugins.ClientMiddleware {
+	allowed := make([]string, 0, len(headers))
+	for _, h := range headers {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h == "" || h == "Cookie" || slices.Contains(allowed, h) {
+			continue
+		}
+		allowed = append(allowed, h)
+	}
+
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &ForwardHeadersMiddleware{
+			next:    next,
+			headers: allowed,
+			logger:  logger,
+		}
+	})
+}
+
+type ForwardHeadersMiddleware struct {
+	next    plugins.Client
+	headers []string
+	logger  plog.Logger
+}
+
+func (m *ForwardHeadersMiddleware) applyHeaders(ctx context.Context, pluginCtx backend.PluginContext, h backend.ForwardHTTPHeaders) {
+	reqCtx := contexthandler.FromContext(ctx)
+	// If no HTTP request context then skip middleware.
+	if h == nil || reqCtx == nil || reqCtx.Req == nil {
+		return
+	}
+
+	forwarded := map[string]string{}
+	for _, name := range m.headers {
+		values := reqCtx.Req.Header.Values(name)
+		if len(values) == 0 || h.GetHTTPHeader(name) != "" {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		h.SetHTTPHeader(name, value)
+
+		if isRedactedByDefault(name) {
+			value = redactedValue
+		}
+		forwarded[name] = value
+	}
+
+	if len(forwarded) > 0 {
+		m.logger.FromContext(ctx).Debug("Forwarding HTTP headers to plugin", "pluginId", pluginCtx.PluginID, "headers", forwarded)
+	}
+}
+
+func (m *ForwardHeadersMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	if req == nil {
+		return m.next.QueryData(ctx, req)
+	}
+
+	m.applyHeaders(ctx, req.PluginContext, req)
+
+	return m.next.QueryData(ctx, req)
+}
+
+func (m *ForwardHeadersMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req == nil {
+		return m.next.CallResource(ctx, req, sender)
+	}
+
+	m.applyHeaders(ctx, req.PluginContext, req)
+
+	return m.next.CallResource(ctx, req, sender)
+}
+
+func (m *ForwardHeadersMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *ForwardHeadersMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *ForwardHeadersMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *ForwardHeadersMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *ForwardHeadersMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,98 @@
+package clientmiddleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestForwardHeadersMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	setup := func(t *testing.T, headers []string) (*clienttest.ClientDecoratorTest, *http.Request, *capturingLogger) {
+		req, err := http.NewRequest(http.MethodGet, "/some/thing", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Scope-OrgID", "tenant-1")
+		req.Header.Add("X-Tenant", "a")
+		req.Header.Add("X-Tenant", "b")
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Other", "other")
+		req.AddCookie(&http.Cookie{Name: "grafana_session", Value: "session"})
+
+		logger := &capturingLogger{}
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{}),
+			clienttest.WithMiddlewares(NewForwardHeadersMiddleware(headers, logger)),
+		)
+		return cdt, req, logger
+	}
+
+	t.Run("Should forward the allowlisted headers when calling QueryData", func(t *testing.T) {
+		cdt, req, _ := setup(t, []string{"x-scope-orgid", " X-Tenant "})
+		_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.NotNil(t, cdt.QueryDataReq)
+		require.Equal(t, http.Header{
+			"X-Scope-Orgid": {"tenant-1"},
+			"X-Tenant":      {"a, b"},
+		}, cdt.QueryDataReq.GetHTTPHeaders())
+	})
+
+	t.Run("Should forward the allowlisted headers when calling CallResource", func(t *testing.T) {
+		cdt, req, _ := setup(t, []string{"X-Scope-OrgID"})
+		err := cdt.Decorator.CallResource(req.Context(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.NoError(t, err)
+		require.NotNil(t, cdt.CallResourceReq)
+		require.Equal(t, map[string][]string{
+			"X-Scope-Orgid": {"tenant-1"},
+		}, cdt.CallResourceReq.Headers)
+	})
+
+	t.Run("Should never forward the cookies", func(t *testing.T) {
+		cdt, req, _ := setup(t, []string{"Cookie", "X-Tenant"})
+		_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Empty(t, cdt.QueryDataReq.GetHTTPHeader("Cookie"))
+		require.Equal(t, "a, b", cdt.QueryDataReq.GetHTTPHeader("X-Tenant"))
+	})
+
+	t.Run("Should not overwrite the headers of the request", func(t *testing.T) {
+		cdt, req, _ := setup(t, []string{"X-Scope-OrgID"})
+		qdr := &backend.QueryDataRequest{PluginContext: pCtx}
+		qdr.SetHTTPHeader("X-Scope-OrgID", "set-by-grafana")
+		_, err := cdt.Decorator.QueryData(req.Context(), qdr)
+		require.NoError(t, err)
+		require.Equal(t, "set-by-grafana", cdt.QueryDataReq.GetHTTPHeader("X-Scope-OrgID"))
+	})
+
+	t.Run("Should redact the sensitive headers from the logs", func(t *testing.T) {
+		cdt, req, logger := setup(t, []string{"Authorization", "X-Scope-OrgID"})
+		_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Equal(t, "Bearer secret", cdt.QueryDataReq.GetHTTPHeader("Authorization"))
+
+		entries := logger.entries("debug")
+		require.Len(t, entries, 1)
+		require.Equal(t, map[string]string{
+			"Authorization": redactedValue,
+			"X-Scope-Orgid": "tenant-1",
+		}, entries[0].value("headers"))
+	})
+
+	t.Run("Should not forward anything without an HTTP request", func(t *testing.T) {
+		logger := &capturingLogger{}
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithMiddlewares(NewForwardHeadersMiddleware([]string{"X-Scope-OrgID"}, logger)),
+		)
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Empty(t, cdt.QueryDataReq.Headers)
+		require.Empty(t, logger.entries("debug"))
+	})
+}
@@ -0,0 +1,158 @@
+package clientmiddleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+// FrameContractMode is the strictness of the validation of the frames returned by plugins.
+type FrameContractMode string
+
+const (
+	// FrameContractModeOff disables the validation.
+	FrameContractModeOff FrameContractMode = "off"
+	// FrameContractModeWarn logs and counts the violations, and returns the responses as they are.
+	FrameContractModeWarn FrameContractMode = "warn"
+	// FrameContractModeStrict logs and counts the violations, and replaces the responses of the queries
+	// with violations with a plugin error.
+	FrameContractModeStrict FrameContractMode = "strict"
+)
+
+const (
+	frameViolationMissingTimeField   = "missing-time-field"
+	frameViolationInconsistentLength = "inconsistent-field-lengths"
+)
+
+// frameContractViolation is a frame of a query response that breaks the contract.
+type frameContractViolation struct {
+	kind    string
+	message string
+}
+
+// NewFrameContractMiddleware returns a new plugins.ClientMiddleware that validates the frames of the QueryData
+// responses against a minimal contract: time series frames must have a time field, and the fields of a frame
+// must have the same length. The violations are counted per plugin and kind, and handled according to mode.
+func NewFrameContractMiddleware(mode FrameContractMode, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	violations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_frame_contract_violations_total",
+		Help:      "The total amount of frames returned by plugins that break the frame contract",
+	}, []string{"plugin_id", "violation"})
+	promRegisterer.MustRegister(violations)
+
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &FrameContractMiddleware{
+			next:       next,
+			mode:       mode,
+			violations: violations,
+			logger:     log.New("plugin.frame_contract"),
+		}
+	})
+}
+
+type FrameContractMiddleware struct {
+	next       plugins.Client
+	mode       FrameContractMode
+	violations *prometheus.CounterVec
+	logger     log.Logger
+}
+
+// validateFrame returns the violations of the frame contract by frame, if any.
+func validateFrame(frame *data.Frame) []frameContractViolation {
+	if frame == nil {
+		return nil
+	}
+	var violations []frameContractViolation
+
+	if frame.Meta != nil && frame.Meta.Type.IsTimeSeries() {
+		hasTime := false
+		for _, field := range frame.Fields {
+			if field != nil && field.Type().Time() {
+				hasTime = true
+				break
+			}
+		}
+		if !hasTime {
+			violations = append(violations, frameContractViolation{
+				kind:    frameViolationMissingTimeField,
+				message: fmt.Sprintf("frame %q of type %s has no time field", frame.Name, frame.Meta.Type),
+			})
+		}
+	}
+
+	for i, field := range frame.Fields {
+		if field == nil || i == 0 || frame.Fields[0] == nil {
+			continue
+		}
+		if field.Len() != frame.Fields[0].Len() {
+			violations = append(violations, frameContractViolation{
+				kind: frameViolationInconsistentLength,
+				message: fmt.Sprintf("frame %q has fields of different lengths: %q has %d values, %q has %d",
+					frame.Name, frame.Fields[0].Name, frame.Fields[0].Len(), field.Name, field.Len()),
+			})
+			break
+		}
+	}
+	return violations
+}
+
+func (m *FrameContractMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	resp, err := m.next.QueryData(ctx, req)
+	if err != nil || resp == nil || m.mode == FrameContractModeOff {
+		return resp, err
+	}
+
+	for refID, r := range resp.Responses {
+		var violations []frameContractViolation
+		for _, frame := range r.Frames {
+			violations = append(violations, validateFrame(frame)...)
+		}
+		if len(violations) == 0 {
+			continue
+		}
+
+		for _, v := range violations {
+			m.violations.WithLabelValues(req.PluginContext.PluginID, v.kind).Inc()
+			m.logger.FromContext(ctx).Warn("Plugin returned a frame that breaks the frame contract", "refId", refID, "violation", v.kind, "error", v.message)
+		}
+		if m.mode == FrameContractModeStrict {
+			resp.Responses[refID] = backend.DataResponse{
+				Error:       fmt.Errorf("plugin returned an invalid frame: %s", violations[0].message),
+				Status:      backend.StatusInternal,
+				ErrorSource: backend.ErrorSourcePlugin,
+			}
+		}
+	}
+	return resp, err
+}
+
+func (m *FrameContractMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.next.CallResource(ctx, req, sender)
+}
+
+func (m *FrameContractMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *FrameContractMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *FrameContractMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *FrameContractMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *FrameContractMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,102 @@
+package clientmiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+)
+
+func TestFrameContractMiddleware(t *testing.T) {
+	timeSeries := func(times []time.Time, values []float64) *data.Frame {
+		frame := data.NewFrame("series", data.NewField("time", nil, times), data.NewField("value", nil, values))
+		frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide}
+		return frame
+	}
+	now := time.Now()
+	conforming := timeSeries([]time.Time{now, now.Add(time.Second)}, []float64{1, 2})
+	unequalLengths := timeSeries([]time.Time{now, now.Add(time.Second)}, []float64{1})
+	missingTime := data.NewFrame("series", data.NewField("value", nil, []float64{1, 2}))
+	missingTime.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide}
+	// Only the time series frames need a time field
+	table := data.NewFrame("table", data.NewField("value", nil, []float64{1, 2}))
+
+	setup := func(t *testing.T, mode FrameContractMode, responses backend.Responses) (*clienttest.ClientDecoratorTest, *prometheus.Registry) {
+		t.Helper()
+		registry := prometheus.NewRegistry()
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewFrameContractMiddleware(mode, registry)))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return &backend.QueryDataResponse{Responses: responses}, nil
+		}
+		return cdt, registry
+	}
+	violations := func(t *testing.T, registry *prometheus.Registry, kind string) float64 {
+		t.Helper()
+		metrics, err := registry.Gather()
+		require.NoError(t, err)
+		var total float64
+		for _, mf := range metrics {
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "violation" && l.GetValue() == kind {
+						total += m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return total
+	}
+	req := &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: "test-datasource"}}
+
+	t.Run("Conforming responses pass", func(t *testing.T) {
+		cdt, registry := setup(t, FrameContractModeStrict, backend.Responses{
+			"A": {Frames: data.Frames{conforming}},
+			"B": {Frames: data.Frames{table}},
+		})
+		resp, err := cdt.Decorator.QueryData(context.Background(), req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Responses["A"].Error)
+		require.Equal(t, data.Frames{conforming}, resp.Responses["A"].Frames)
+		require.NoError(t, resp.Responses["B"].Error)
+		require.Zero(t, testutil.CollectAndCount(registry))
+	})
+
+	t.Run("Malformed frames are flagged as plugin errors in strict mode", func(t *testing.T) {
+		cdt, registry := setup(t, FrameContractModeStrict, backend.Responses{
+			"A": {Frames: data.Frames{conforming}},
+			"B": {Frames: data.Frames{missingTime}},
+			"C": {Frames: data.Frames{unequalLengths}},
+		})
+		resp, err := cdt.Decorator.QueryData(context.Background(), req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Responses["A"].Error)
+
+		require.ErrorContains(t, resp.Responses["B"].Error, `frame "series" of type timeseries-wide has no time field`)
+		require.Equal(t, backend.ErrorSourcePlugin, resp.Responses["B"].ErrorSource)
+		require.Empty(t, resp.Responses["B"].Frames)
+
+		require.ErrorContains(t, resp.Responses["C"].Error, `"time" has 2 values, "value" has 1`)
+		require.Equal(t, backend.ErrorSourcePlugin, resp.Responses["C"].ErrorSource)
+
+		require.Equal(t, 1.0, violations(t, registry, frameViolationMissingTimeField))
+		require.Equal(t, 1.0, violations(t, registry, frameViolationInconsistentLength))
+	})
+
+	t.Run("Malformed frames are only counted in warn mode", func(t *testing.T) {
+		cdt, registry := setup(t, FrameContractModeWarn, backend.Responses{
+			"A": {Frames: data.Frames{missingTime}},
+		})
+		resp, err := cdt.Decorator.QueryData(context.Background(), req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Responses["A"].Error)
+		require.Equal(t, data.Frames{missingTime}, resp.Responses["A"].Frames)
+		require.Equal(t, 1.0, violations(t, registry, frameViolationMissingTimeField))
+	})
+}
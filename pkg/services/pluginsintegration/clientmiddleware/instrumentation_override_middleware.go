@@ -0,0 +1,97 @@
+package clientmiddleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/services/contexthandler"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+)
+
+// InstrumentationOverrideHeaderName is the name of the HTTP header listing the instrumentation feature toggles
+// to enable for a single request, as a comma separated list.
+const InstrumentationOverrideHeaderName = "X-Grafana-Instrumentation-Override"
+
+// overridableInstrumentationFlags are the feature toggles that can be enabled for a single request.
+var overridableInstrumentationFlags = []string{
+	featuremgmt.FlagPluginsInstrumentationStatusSource,
+}
+
+// NewInstrumentationOverrideMiddleware returns a new plugins.ClientMiddleware that enables instrumentation feature
+// toggles for a single request, according to the InstrumentationOverrideHeaderName header of the incoming HTTP request.
+// Only the toggles in overridableInstrumentationFlags can be enabled, any other value is ignored.
+// The header is only honored for the requests of Grafana server admins, it's ignored for any other user.
+func NewInstrumentationOverrideMiddleware() plugins.ClientMiddleware {
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &InstrumentationOverrideMiddleware{
+			next: next,
+		}
+	})
+}
+
+type InstrumentationOverrideMiddleware struct {
+	next plugins.Client
+}
+
+func (m *InstrumentationOverrideMiddleware) withOverrides(ctx context.Context) context.Context {
+	reqCtx := contexthandler.FromContext(ctx)
+	// If no HTTP request context then skip middleware.
+	if reqCtx == nil || reqCtx.Req == nil {
+		return ctx
+	}
+	// Enabling instrumentation changes the metrics of everyone, so it's restricted to the server admins
+	if reqCtx.SignedInUser == nil || !reqCtx.SignedInUser.GetIsGrafanaAdmin() {
+		return ctx
+	}
+
+	header := reqCtx.Req.Header.Get(InstrumentationOverrideHeaderName)
+	if header == "" {
+		return ctx
+	}
+
+	var flags []string
+	for _, flag := range strings.Split(header, ",") {
+		flag = strings.TrimSpace(flag)
+		for _, overridable := range overridableInstrumentationFlags {
+			if flag == overridable {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	if len(flags) == 0 {
+		return ctx
+	}
+	return pluginrequestmeta.WithInstrumentationOverrides(ctx, flags...)
+}
+
+func (m *InstrumentationOverrideMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	return m.next.QueryData(m.withOverrides(ctx), req)
+}
+
+func (m *InstrumentationOverrideMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.next.CallResource(m.withOverrides(ctx), req, sender)
+}
+
+func (m *InstrumentationOverrideMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	return m.next.CheckHealth(m.withOverrides(ctx), req)
+}
+
+func (m *InstrumentationOverrideMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(m.withOverrides(ctx), req)
+}
+
+func (m *InstrumentationOverrideMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(m.withOverrides(ctx), req)
+}
+
+func (m *InstrumentationOverrideMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(m.withOverrides(ctx), req)
+}
+
+func (m *InstrumentationOverrideMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(m.withOverrides(ctx), req, sender)
+}
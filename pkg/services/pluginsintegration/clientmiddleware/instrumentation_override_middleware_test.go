@@ -0,0 +1,108 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/backendplugin"
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/manager/fakes"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/org"
+	"github.com/grafana/grafana/pkg/services/user"
+)
+
+func TestInstrumentationOverrideMiddleware(t *testing.T) {
+	newRequest := func(t *testing.T, header string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "/some/thing", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set(InstrumentationOverrideHeaderName, header)
+		}
+		return req
+	}
+
+	t.Run("Should enable the overridable flags in the header", func(t *testing.T) {
+		req := newRequest(t, "someOtherFlag, "+featuremgmt.FlagPluginsInstrumentationStatusSource)
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{IsGrafanaAdmin: true}),
+			clienttest.WithMiddlewares(NewInstrumentationOverrideMiddleware()),
+		)
+		_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{})
+		require.NoError(t, err)
+		require.True(t, pluginrequestmeta.InstrumentationOverridden(cdt.QueryDataCtx, featuremgmt.FlagPluginsInstrumentationStatusSource))
+		require.False(t, pluginrequestmeta.InstrumentationOverridden(cdt.QueryDataCtx, "someOtherFlag"))
+	})
+
+	t.Run("Should ignore the header of the users who aren't server admins", func(t *testing.T) {
+		req := newRequest(t, featuremgmt.FlagPluginsInstrumentationStatusSource)
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{OrgRole: org.RoleAdmin}),
+			clienttest.WithMiddlewares(NewInstrumentationOverrideMiddleware()),
+		)
+		_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{})
+		require.NoError(t, err)
+		require.False(t, pluginrequestmeta.InstrumentationOverridden(cdt.QueryDataCtx, featuremgmt.FlagPluginsInstrumentationStatusSource))
+	})
+
+	t.Run("Should not enable anything without the header", func(t *testing.T) {
+		req := newRequest(t, "")
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{IsGrafanaAdmin: true}),
+			clienttest.WithMiddlewares(NewInstrumentationOverrideMiddleware()),
+		)
+		_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{})
+		require.NoError(t, err)
+		require.False(t, pluginrequestmeta.InstrumentationOverridden(cdt.QueryDataCtx, featuremgmt.FlagPluginsInstrumentationStatusSource))
+	})
+
+	t.Run("Should enable the status source label for the overridden request only", func(t *testing.T) {
+		pluginsRegistry := fakes.NewFakePluginRegistry()
+		require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+			JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+		}))
+		metricsMw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationOverrides))
+		middlewares := []plugins.ClientMiddleware{
+			NewPluginRequestMetaMiddleware(),
+			NewInstrumentationOverrideMiddleware(),
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				metricsMw.next = next
+				return metricsMw
+			}),
+			NewStatusSourceMiddleware(),
+		}
+		queryData := func(t *testing.T, req *http.Request) {
+			cdt := clienttest.NewClientDecoratorTest(t,
+				clienttest.WithReqContext(req, &user.SignedInUser{IsGrafanaAdmin: true}),
+				clienttest.WithMiddlewares(middlewares...),
+			)
+			cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+				return &backend.QueryDataResponse{Responses: map[string]backend.DataResponse{
+					"A": {Status: http.StatusBadGateway, Error: errors.New("bad gateway"), ErrorSource: backend.ErrorSourceDownstream},
+				}}, nil
+			}
+			_, err := cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
+			require.NoError(t, err)
+		}
+		counter := func(statusSource string) prometheus.Counter {
+			return metricsMw.pluginRequestCounter.WithLabelValues(pluginID, endpointQueryData, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal, statusSource)
+		}
+
+		queryData(t, newRequest(t, featuremgmt.FlagPluginsInstrumentationStatusSource))
+		require.Equal(t, 1.0, testutil.ToFloat64(counter(string(pluginrequestmeta.StatusSourceDownstream))))
+		require.Zero(t, testutil.ToFloat64(counter("")))
+
+		queryData(t, newRequest(t, ""))
+		require.Equal(t, 1.0, testutil.ToFloat64(counter(string(pluginrequestmeta.StatusSourceDownstream))))
+		require.Equal(t, 1.0, testutil.ToFloat64(counter("")))
+	})
+}
@@ -37,7 +37,7 @@ type LoggerMiddleware struct {
 	features featuremgmt.FeatureToggles
 }
 
-func (m *LoggerMiddleware) logRequest(ctx context.Context, fn func(ctx context.Context) error) error {
+func (m *LoggerMiddleware) logRequest(ctx context.Context, fn func(ctx context.Context) error, extraParams ...any) error {
 	status := statusOK
 	start := time.Now()
 	timeBeforePluginRequest := log.TimeSinceStart(ctx, start)
@@ -55,10 +55,14 @@ func (m *LoggerMiddleware) logRequest(ctx context.Context, fn func(ctx context.C
 		"eventName", "grafana-data-egress",
 		"time_before_plugin_request", timeBeforePluginRequest,
 	}
+	logParams = append(logParams, extraParams...)
 	if status == statusError {
 		logParams = append(logParams, "error", err)
 	}
-	if m.features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) {
+	if position := pluginrequestmeta.QueuePositionFromContext(ctx); position > 0 {
+		logParams = append(logParams, "queue_position", position)
+	}
+	if instrumentationEnabled(ctx, m.features, featuremgmt.FlagPluginsInstrumentationStatusSource) {
 		logParams = append(logParams, "status_source", pluginrequestmeta.StatusSourceFromContext(ctx))
 	}
 	m.logger.FromContext(ctx).Info("Plugin Request Completed", logParams...)
@@ -77,6 +81,9 @@ func (m *LoggerMiddleware) QueryData(ctx context.Context, req *backend.QueryData
 		if innerErr != nil {
 			return innerErr
 		}
+		if resp == nil {
+			return errNilQueryDataResponse
+		}
 
 		ctxLogger := m.logger.FromContext(ctx)
 		for refID, dr := range resp.Responses {
@@ -86,11 +93,25 @@ func (m *LoggerMiddleware) QueryData(ctx context.Context, req *backend.QueryData
 		}
 
 		return nil
-	})
+	}, queryDataLogParams(req)...)
 
 	return resp, err
 }
 
+// queryDataLogParams returns the log params describing the queries of the given request.
+func queryDataLogParams(req *backend.QueryDataRequest) []any {
+	if len(req.Queries) == 0 {
+		return nil
+	}
+	stats := newQueryDataStats(req.Queries)
+	return []any{
+		"queries", stats.queries,
+		"min_interval", stats.minInterval,
+		"max_interval", stats.maxInterval,
+		"total_max_data_points", stats.totalMaxDataPoints,
+	}
+}
+
 func (m *LoggerMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
 	if req == nil {
 		return m.next.CallResource(ctx, req, sender)
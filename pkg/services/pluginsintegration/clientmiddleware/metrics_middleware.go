@@ -3,6 +3,8 @@ package clientmiddleware
 import (
 	"context"
 	"errors"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -13,6 +15,8 @@ import (
 	"github.com/grafana/grafana/pkg/plugins/manager/registry"
 	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	ngalertmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/setting"
 )
 
 // pluginMetrics contains the prometheus metrics used by the MetricsMiddleware.
@@ -20,103 +24,488 @@ type pluginMetrics struct {
 	pluginRequestCounter         *prometheus.CounterVec
 	pluginRequestDuration        *prometheus.HistogramVec
 	pluginRequestSize            *prometheus.HistogramVec
+	pluginResponseSize           *prometheus.HistogramVec
 	pluginRequestDurationSeconds *prometheus.HistogramVec
+	pluginResourceSenderBlocked  *prometheus.HistogramVec
+	pluginRequestRestartFailures *prometheus.CounterVec
+	pluginRequestInFlight        *prometheus.GaugeVec
+	pluginStreamsStarted         *prometheus.CounterVec
+	pluginStreamsStopped         *prometheus.CounterVec
+
+	// pluginRequestErrors is only set if featuremgmt.FlagPluginsInstrumentationStatusCode is enabled.
+	pluginRequestErrors *prometheus.CounterVec
+
+	// pluginResponseEncode is only set if featuremgmt.FlagPluginsInstrumentationResponseEncoding is enabled.
+	pluginResponseEncode *prometheus.HistogramVec
+
+	// pluginAlertingRequestDuration is only set if featuremgmt.FlagPluginsInstrumentationAlertingHistogram is enabled.
+	pluginAlertingRequestDuration *prometheus.HistogramVec
+
+	// pluginRegistryLookupDuration and pluginRegistryLookupCacheMisses are only set if
+	// featuremgmt.FlagPluginsInstrumentationRegistryLookup is enabled.
+	pluginRegistryLookupDuration    prometheus.Histogram
+	pluginRegistryLookupCacheMisses prometheus.Counter
+
+	// pluginRequestErrorCategories is only set if featuremgmt.FlagPluginsInstrumentationErrorCategory is enabled.
+	pluginRequestErrorCategories *prometheus.CounterVec
+
+	// pluginRequestStatusSourceTransitions is only set if the status_source label is.
+	pluginRequestStatusSourceTransitions *prometheus.CounterVec
 }
 
 // MetricsMiddleware is a middleware that instruments plugin requests.
 // It tracks requests count, duration and size as prometheus metrics.
 type MetricsMiddleware struct {
 	pluginMetrics
-	pluginRegistry registry.Service
-	features       featuremgmt.FeatureToggles
-	next           plugins.Client
+	pluginRegistry    registry.Service
+	features          featuremgmt.FeatureToggles
+	statusSourceLabel bool
+	// datasourceUIDLabel is only set if featuremgmt.FlagPluginsInstrumentationDatasourceUID is enabled.
+	datasourceUIDLabel bool
+	rangeRecency       *rangeRecencyBuckets
+	clientClassLabel   bool
+	lookupCache        *pluginLookupCache
+	classifyError      ErrorClassifier
+	next               plugins.Client
+}
+
+// Default bucket boundaries of the plugin request duration histograms.
+var (
+	defaultRequestDurationBucketsMilliseconds = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25, 50, 100}
+	defaultRequestDurationBucketsSeconds      = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25}
+)
+
+// metricsMiddlewareOptions are the options of newMetricsMiddleware.
+type metricsMiddlewareOptions struct {
+	requestDurationBucketsMilliseconds []float64
+	requestDurationBucketsSeconds      []float64
 }
 
-func newMetricsMiddleware(promRegisterer prometheus.Registerer, pluginRegistry registry.Service, features featuremgmt.FeatureToggles) *MetricsMiddleware {
+// metricsMiddlewareOption sets an option of newMetricsMiddleware.
+type metricsMiddlewareOption func(*metricsMiddlewareOptions)
+
+// withRequestDurationBuckets sets the bucket boundaries of the grafana_plugin_request_duration_milliseconds and
+// grafana_plugin_request_duration_seconds histograms. The default buckets are kept for the empty ones.
+func withRequestDurationBuckets(milliseconds, seconds []float64) metricsMiddlewareOption {
+	return func(o *metricsMiddlewareOptions) {
+		if len(milliseconds) > 0 {
+			o.requestDurationBucketsMilliseconds = milliseconds
+		}
+		if len(seconds) > 0 {
+			o.requestDurationBucketsSeconds = seconds
+		}
+	}
+}
+
+func newMetricsMiddleware(promRegisterer prometheus.Registerer, pluginRegistry registry.Service, features featuremgmt.FeatureToggles, opts ...metricsMiddlewareOption) *MetricsMiddleware {
+	options := metricsMiddlewareOptions{
+		requestDurationBucketsMilliseconds: defaultRequestDurationBucketsMilliseconds,
+		requestDurationBucketsSeconds:      defaultRequestDurationBucketsSeconds,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
 	var additionalLabels []string
-	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) {
-		additionalLabels = []string{"status_source"}
+	// The label is also needed if the status source can be enabled for a single request. In that case,
+	// it's left empty for the other requests, which is the same as not having it in Prometheus.
+	statusSourceLabel := features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) || features.IsEnabled(featuremgmt.FlagPluginsInstrumentationOverrides)
+	if statusSourceLabel {
+		additionalLabels = append(additionalLabels, "status_source")
+	}
+	// Each data source instance is a label value, so the label is opt-in to keep the cardinality down
+	datasourceUIDLabel := features.IsEnabled(featuremgmt.FlagPluginsInstrumentationDatasourceUID)
+	if datasourceUIDLabel {
+		additionalLabels = append(additionalLabels, "datasource_uid")
+	}
+	counterLabels := append([]string{"plugin_id", "endpoint", "status", "target", "plugin_source"}, additionalLabels...)
+	var rangeRecency *rangeRecencyBuckets
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationRangeRecency) {
+		counterLabels = append(counterLabels, "range_recency")
+		rangeRecency = &rangeRecencyBuckets{realtime: defaultRangeRecencyRealtime, recent: defaultRangeRecencyRecent}
+	}
+	clientClass := features.IsEnabled(featuremgmt.FlagPluginsInstrumentationClientClass)
+	if clientClass {
+		counterLabels = append(counterLabels, "client_class")
 	}
 	pluginRequestCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "grafana",
 		Name:      "plugin_request_total",
 		Help:      "The total amount of plugin requests",
-	}, append([]string{"plugin_id", "endpoint", "status", "target"}, additionalLabels...))
+	}, counterLabels)
 	pluginRequestDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "grafana",
 		Name:      "plugin_request_duration_milliseconds",
 		Help:      "Plugin request duration",
-		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25, 50, 100},
-	}, append([]string{"plugin_id", "endpoint", "target"}, additionalLabels...))
+		Buckets:   options.requestDurationBucketsMilliseconds,
+	}, append([]string{"plugin_id", "endpoint", "target", "plugin_source"}, additionalLabels...))
 	pluginRequestSize := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: "grafana",
 			Name:      "plugin_request_size_bytes",
 			Help:      "histogram of plugin request sizes returned",
 			Buckets:   []float64{128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536, 131072, 262144, 524288, 1048576},
-		}, []string{"source", "plugin_id", "endpoint", "target"},
+		}, []string{"source", "plugin_id", "endpoint", "target", "plugin_source"},
+	)
+	pluginResponseSize := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "plugin_response_size_bytes",
+			Help:      "histogram of plugin response sizes returned",
+			Buckets:   []float64{128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536, 131072, 262144, 524288, 1048576},
+		}, []string{"plugin_id", "endpoint", "target", "plugin_source"},
 	)
 	pluginRequestDurationSeconds := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "grafana",
 		Name:      "plugin_request_duration_seconds",
 		Help:      "Plugin request duration in seconds",
-		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25},
-	}, append([]string{"source", "plugin_id", "endpoint", "status", "target"}, additionalLabels...))
+		Buckets:   options.requestDurationBucketsSeconds,
+	}, append([]string{"source", "plugin_id", "endpoint", "status", "target", "plugin_source"}, additionalLabels...))
+	pluginResourceSenderBlocked := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Name:      "plugin_resource_sender_blocked_seconds",
+		Help:      "Total time a plugin resource request spent blocked sending its responses to a slow client",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25},
+	}, []string{"plugin_id", "target", "plugin_source"})
+	pluginRequestRestartFailures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_request_restart_failures_total",
+		Help:      "The total amount of plugin requests that failed because the plugin restarted during the call",
+	}, []string{"plugin_id", "endpoint", "target", "plugin_source"})
+	pluginRequestInFlight := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "grafana",
+		Name:      "plugin_request_in_flight",
+		Help:      "The number of plugin requests currently being handled",
+	}, []string{"plugin_id", "endpoint"})
+	// Streams run for as long as they have subscribers, so their starts and stops are counted instead of their duration
+	pluginStreamsStarted := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_streams_started_total",
+		Help:      "The total amount of plugin streams started",
+	}, []string{"plugin_id", "target", "plugin_source"})
+	pluginStreamsStopped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_streams_stopped_total",
+		Help:      "The total amount of plugin streams stopped",
+	}, []string{"plugin_id", "status", "target", "plugin_source"})
 	promRegisterer.MustRegister(
 		pluginRequestCounter,
 		pluginRequestDuration,
 		pluginRequestSize,
+		pluginResponseSize,
 		pluginRequestDurationSeconds,
+		pluginResourceSenderBlocked,
+		pluginRequestRestartFailures,
+		pluginRequestInFlight,
+		pluginStreamsStarted,
+		pluginStreamsStopped,
 	)
+	var pluginRequestErrors *prometheus.CounterVec
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusCode) {
+		pluginRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grafana",
+			Name:      "plugin_request_errors_total",
+			Help:      "The total amount of plugin requests that returned an error HTTP status code",
+		}, []string{"plugin_id", "endpoint", "status_code", "target", "plugin_source"})
+		promRegisterer.MustRegister(pluginRequestErrors)
+	}
+	var pluginResponseEncode *prometheus.HistogramVec
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationResponseEncoding) {
+		pluginResponseEncode = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "plugin_response_encode_seconds",
+			Help:      "Time taken to JSON encode the plugin query responses",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"plugin_id", "target", "plugin_source"})
+		promRegisterer.MustRegister(pluginResponseEncode)
+	}
+	var pluginAlertingRequestDuration *prometheus.HistogramVec
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationAlertingHistogram) {
+		pluginAlertingRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "plugin_alerting_request_duration_seconds",
+			Help:      "Duration in seconds of the plugin requests made by alerting",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 25, 60},
+		}, append([]string{"source", "plugin_id", "endpoint", "status", "target", "plugin_source"}, additionalLabels...))
+		promRegisterer.MustRegister(pluginAlertingRequestDuration)
+	}
+	var pluginRegistryLookupDuration prometheus.Histogram
+	var pluginRegistryLookupCacheMisses prometheus.Counter
+	var lookupCache *pluginLookupCache
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationRegistryLookup) {
+		pluginRegistryLookupDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "grafana",
+			Name:      "plugin_registry_lookup_duration_seconds",
+			Help:      "Duration of the plugin registry lookups made to instrument plugin requests",
+			Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+		})
+		pluginRegistryLookupCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "grafana",
+			Name:      "plugin_registry_lookup_cache_misses_total",
+			Help:      "The total amount of plugin lookups to instrument plugin requests that weren't cached",
+		})
+		promRegisterer.MustRegister(pluginRegistryLookupDuration, pluginRegistryLookupCacheMisses)
+		lookupCache = newPluginLookupCache(defaultPluginLookupCacheTTL)
+	}
+	var pluginRequestErrorCategories *prometheus.CounterVec
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationErrorCategory) {
+		pluginRequestErrorCategories = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grafana",
+			Name:      "plugin_request_error_categories_total",
+			Help:      "The total amount of plugin request and query errors, by error category",
+		}, []string{"plugin_id", "endpoint", "error_category", "target", "plugin_source"})
+		promRegisterer.MustRegister(pluginRequestErrorCategories)
+	}
+	var pluginRequestStatusSourceTransitions *prometheus.CounterVec
+	if statusSourceLabel {
+		pluginRequestStatusSourceTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "grafana",
+			Name:      "plugin_request_status_source_transitions_total",
+			Help:      "The total amount of plugin requests by status source of their first attempt and final status source, e.g. downstream to plugin when a retry succeeds",
+		}, []string{"plugin_id", "endpoint", "initial_status_source", "final_status_source"})
+		promRegisterer.MustRegister(pluginRequestStatusSourceTransitions)
+	}
 	return &MetricsMiddleware{
 		pluginMetrics: pluginMetrics{
-			pluginRequestCounter:         pluginRequestCounter,
-			pluginRequestDuration:        pluginRequestDuration,
-			pluginRequestSize:            pluginRequestSize,
-			pluginRequestDurationSeconds: pluginRequestDurationSeconds,
+			pluginRequestCounter:            pluginRequestCounter,
+			pluginRequestDuration:           pluginRequestDuration,
+			pluginRequestSize:               pluginRequestSize,
+			pluginResponseSize:              pluginResponseSize,
+			pluginRequestDurationSeconds:    pluginRequestDurationSeconds,
+			pluginResourceSenderBlocked:     pluginResourceSenderBlocked,
+			pluginRequestRestartFailures:    pluginRequestRestartFailures,
+			pluginRequestInFlight:           pluginRequestInFlight,
+			pluginStreamsStarted:            pluginStreamsStarted,
+			pluginStreamsStopped:            pluginStreamsStopped,
+			pluginRequestErrors:             pluginRequestErrors,
+			pluginResponseEncode:            pluginResponseEncode,
+			pluginAlertingRequestDuration:   pluginAlertingRequestDuration,
+			pluginRegistryLookupDuration:    pluginRegistryLookupDuration,
+			pluginRegistryLookupCacheMisses: pluginRegistryLookupCacheMisses,
+			pluginRequestErrorCategories:    pluginRequestErrorCategories,
+
+			pluginRequestStatusSourceTransitions: pluginRequestStatusSourceTransitions,
 		},
-		pluginRegistry: pluginRegistry,
-		features:       features,
+		pluginRegistry:     pluginRegistry,
+		features:           features,
+		statusSourceLabel:  statusSourceLabel,
+		datasourceUIDLabel: datasourceUIDLabel,
+		rangeRecency:       rangeRecency,
+		clientClassLabel:   clientClass,
+		lookupCache:        lookupCache,
+		classifyError:      DefaultErrorClassifier,
 	}
 }
 
 // NewMetricsMiddleware returns a new MetricsMiddleware.
-func NewMetricsMiddleware(promRegisterer prometheus.Registerer, pluginRegistry registry.Service, features featuremgmt.FeatureToggles) plugins.ClientMiddleware {
-	imw := newMetricsMiddleware(promRegisterer, pluginRegistry, features)
+// The errorClassifiers are tried in order before DefaultErrorClassifier to fill the "error_category" label,
+// which is only added if featuremgmt.FlagPluginsInstrumentationErrorCategory is enabled.
+func NewMetricsMiddleware(cfg *setting.Cfg, promRegisterer prometheus.Registerer, pluginRegistry registry.Service, features featuremgmt.FeatureToggles, errorClassifiers ...ErrorClassifier) plugins.ClientMiddleware {
+	imw := newMetricsMiddleware(promRegisterer, pluginRegistry, features,
+		withRequestDurationBuckets(cfg.PluginRequestDurationBucketsMilliseconds, cfg.PluginRequestDurationBucketsSeconds))
+	if len(errorClassifiers) > 0 {
+		imw.classifyError = newErrorClassifier(errorClassifiers...)
+	}
+	if imw.rangeRecency != nil {
+		imw.rangeRecency.realtime = cfg.PluginRangeRecencyRealtime
+		imw.rangeRecency.recent = cfg.PluginRangeRecencyRecent
+	}
 	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
 		imw.next = next
 		return imw
 	})
 }
 
-// pluginTarget returns the value for the "target" Prometheus label for the given plugin ID.
-func (m *MetricsMiddleware) pluginTarget(ctx context.Context, pluginID string) (string, error) {
+// plugin returns the registered plugin with the given ID.
+// If featuremgmt.FlagPluginsInstrumentationRegistryLookup is enabled, the registry lookups are observed
+// and cached for a short time, since the plugin is looked up several times for each request.
+func (m *MetricsMiddleware) plugin(ctx context.Context, pluginID string) (*plugins.Plugin, error) {
+	if m.lookupCache == nil {
+		p, exists := m.pluginRegistry.Plugin(ctx, pluginID)
+		if !exists {
+			return nil, plugins.ErrPluginNotRegistered
+		}
+		return p, nil
+	}
+
+	now := time.Now()
+	if p, ok := m.lookupCache.get(pluginID, now); ok {
+		return p, nil
+	}
+	m.pluginRegistryLookupCacheMisses.Inc()
 	p, exists := m.pluginRegistry.Plugin(ctx, pluginID)
+	m.pluginRegistryLookupDuration.Observe(time.Since(now).Seconds())
 	if !exists {
-		return "", plugins.ErrPluginNotRegistered
+		// Missing plugins aren't cached, so they're found as soon as they're registered
+		return nil, plugins.ErrPluginNotRegistered
 	}
-	return string(p.Target()), nil
+	m.lookupCache.set(pluginID, p, now)
+	return p, nil
+}
+
+// defaultPluginLookupCacheTTL is how long plugins are cached by the pluginLookupCache.
+// It's short, so an updated plugin is only reported with the previous one's labels for a few seconds.
+const defaultPluginLookupCacheTTL = 5 * time.Second
+
+// pluginLookupCache is a short-lived cache of the plugins found in the registry, keyed by plugin ID.
+type pluginLookupCache struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]pluginLookupCacheEntry
+}
+
+type pluginLookupCacheEntry struct {
+	plugin  *plugins.Plugin
+	expires time.Time
+}
+
+func newPluginLookupCache(ttl time.Duration) *pluginLookupCache {
+	return &pluginLookupCache{ttl: ttl, entries: map[string]pluginLookupCacheEntry{}}
+}
+
+func (c *pluginLookupCache) get(pluginID string, now time.Time) (*plugins.Plugin, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[pluginID]
+	if !ok || !now.Before(e.expires) {
+		return nil, false
+	}
+	return e.plugin, true
+}
+
+func (c *pluginLookupCache) set(pluginID string, p *plugins.Plugin, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[pluginID] = pluginLookupCacheEntry{plugin: p, expires: now.Add(c.ttl)}
+}
+
+// pluginLabels returns the values for the "target" and "plugin_source" Prometheus labels for the given plugin ID.
+func (m *MetricsMiddleware) pluginLabels(ctx context.Context, pluginID string) (target string, source string, err error) {
+	p, err := m.plugin(ctx, pluginID)
+	if err != nil {
+		return "", "", err
+	}
+	return string(p.Target()), pluginSource(p), nil
+}
+
+// failedByRestart returns true if err is caused by the plugin being unavailable, and the plugin has exited
+// or restarted since the request started, i.e. since it had been started starts times.
+func failedByRestart(p *plugins.Plugin, starts int64, err error) bool {
+	return errors.Is(err, plugins.ErrPluginUnavailable) && (p.Exited() || p.Starts() != starts)
+}
+
+// pluginSource returns the value for the "plugin_source" Prometheus label for the given plugin.
+// Core and bundled plugins ship with Grafana and are reported as "core". External plugins are reported as "dev"
+// when unsigned, since those can only be loaded when explicitly allowed (e.g. while developing a plugin).
+func pluginSource(p *plugins.Plugin) string {
+	if p.IsCorePlugin() || p.IsBundledPlugin() {
+		return pluginSourceCore
+	}
+	if p.Signature == plugins.SignatureStatusUnsigned {
+		return pluginSourceDev
+	}
+	return pluginSourceExternal
 }
 
 // instrumentPluginRequestSize tracks the size of the given request in the m.pluginRequestSize metric.
 func (m *MetricsMiddleware) instrumentPluginRequestSize(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, requestSize float64) error {
-	target, err := m.pluginTarget(ctx, pluginCtx.PluginID)
+	target, source, err := m.pluginLabels(ctx, pluginCtx.PluginID)
 	if err != nil {
 		return err
 	}
-	m.pluginRequestSize.WithLabelValues("grafana-backend", pluginCtx.PluginID, endpoint, target).Observe(requestSize)
+	m.pluginRequestSize.WithLabelValues("grafana-backend", pluginCtx.PluginID, endpoint, target, source).Observe(requestSize)
+	return nil
+}
+
+// instrumentPluginResponseSize tracks the size of the given response in the m.pluginResponseSize metric.
+func (m *MetricsMiddleware) instrumentPluginResponseSize(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, responseSize float64) error {
+	target, source, err := m.pluginLabels(ctx, pluginCtx.PluginID)
+	if err != nil {
+		return err
+	}
+	m.pluginResponseSize.WithLabelValues(pluginCtx.PluginID, endpoint, target, source).Observe(responseSize)
+	return nil
+}
+
+// instrumentQueryDataResponseSize encodes the given query response to JSON and tracks the encoded size
+// in the m.pluginResponseSize metric. If featuremgmt.FlagPluginsInstrumentationResponseEncoding is enabled, the time
+// that encoding took is tracked in the m.pluginResponseEncode metric too. It's not the encoding of the response for
+// the clients, which happens later, but it's an estimate of its cost.
+func (m *MetricsMiddleware) instrumentQueryDataResponseSize(ctx context.Context, pluginCtx backend.PluginContext, resp *backend.QueryDataResponse) error {
+	target, source, err := m.pluginLabels(ctx, pluginCtx.PluginID)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	b, err := resp.MarshalJSON()
+	if err != nil {
+		// The response can't be encoded, which is reported to the client when it's encoded again
+		return nil
+	}
+	if m.pluginResponseEncode != nil {
+		m.pluginResponseEncode.WithLabelValues(pluginCtx.PluginID, target, source).Observe(time.Since(start).Seconds())
+	}
+	m.pluginResponseSize.WithLabelValues(pluginCtx.PluginID, endpointQueryData, target, source).Observe(float64(len(b)))
+	return nil
+}
+
+// instrumentPluginResourceSenderBlocked tracks the total time a resource request spent sending its responses
+// in the m.pluginResourceSenderBlocked metric.
+func (m *MetricsMiddleware) instrumentPluginResourceSenderBlocked(ctx context.Context, pluginCtx backend.PluginContext, blocked time.Duration) error {
+	target, source, err := m.pluginLabels(ctx, pluginCtx.PluginID)
+	if err != nil {
+		return err
+	}
+	m.pluginResourceSenderBlocked.WithLabelValues(pluginCtx.PluginID, target, source).Observe(blocked.Seconds())
+	return nil
+}
+
+// instrumentPluginRequestError increments the m.pluginRequestErrors metric if the given HTTP status code is an error.
+// It's a no-op if featuremgmt.FlagPluginsInstrumentationStatusCode is not enabled.
+func (m *MetricsMiddleware) instrumentPluginRequestError(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, statusCode int) error {
+	if m.pluginRequestErrors == nil || statusCode < http.StatusBadRequest {
+		return nil
+	}
+	target, source, err := m.pluginLabels(ctx, pluginCtx.PluginID)
+	if err != nil {
+		return err
+	}
+	m.pluginRequestErrors.WithLabelValues(pluginCtx.PluginID, endpoint, statusCodeLabel(statusCode), target, source).Inc()
+	return nil
+}
+
+// instrumentPluginRequestErrorCategory increments the m.pluginRequestErrorCategories metric with the category of the given error.
+// It's a no-op if featuremgmt.FlagPluginsInstrumentationErrorCategory is not enabled, or if the error is nil.
+func (m *MetricsMiddleware) instrumentPluginRequestErrorCategory(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, err error) error {
+	if m.pluginRequestErrorCategories == nil || err == nil {
+		return nil
+	}
+	target, source, lerr := m.pluginLabels(ctx, pluginCtx.PluginID)
+	if lerr != nil {
+		return lerr
+	}
+	m.pluginRequestErrorCategories.WithLabelValues(pluginCtx.PluginID, endpoint, string(m.classifyError(err)), target, source).Inc()
 	return nil
 }
 
 // instrumentPluginRequest increments the m.pluginRequestCounter metric and tracks the duration of the given request.
-func (m *MetricsMiddleware) instrumentPluginRequest(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, fn func(context.Context) error) error {
-	target, err := m.pluginTarget(ctx, pluginCtx.PluginID)
+// rangeRecency is the value of the "range_recency" label, which is only added if
+// featuremgmt.FlagPluginsInstrumentationRangeRecency is enabled.
+func (m *MetricsMiddleware) instrumentPluginRequest(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, rangeRecency string, fn func(context.Context) error) error {
+	p, err := m.plugin(ctx, pluginCtx.PluginID)
 	if err != nil {
 		return err
 	}
+	target, source := string(p.Target()), pluginSource(p)
 
 	status := statusOK
 	start := time.Now()
+	starts := p.Starts()
+
+	// Deferred, so the request isn't counted as in flight forever if it panics
+	inFlight := m.pluginRequestInFlight.WithLabelValues(pluginCtx.PluginID, endpoint)
+	inFlight.Inc()
+	defer inFlight.Dec()
 
 	err = fn(ctx)
 	if err != nil {
@@ -124,33 +513,69 @@ func (m *MetricsMiddleware) instrumentPluginRequest(ctx context.Context, pluginC
 		if errors.Is(err, context.Canceled) {
 			status = statusCancelled
 		}
+		if failedByRestart(p, starts, err) {
+			m.pluginRequestRestartFailures.WithLabelValues(pluginCtx.PluginID, endpoint, target, source).Inc()
+		}
+		if m.pluginRequestErrorCategories != nil && status == statusError {
+			m.pluginRequestErrorCategories.WithLabelValues(pluginCtx.PluginID, endpoint, string(m.classifyError(err)), target, source).Inc()
+		}
 	}
 	elapsed := time.Since(start)
 
-	pluginRequestDurationLabels := []string{pluginCtx.PluginID, endpoint, target}
-	pluginRequestCounterLabels := []string{pluginCtx.PluginID, endpoint, status, target}
-	pluginRequestDurationSecondsLabels := []string{"grafana-backend", pluginCtx.PluginID, endpoint, status, target}
-	if m.features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) {
-		statusSource := pluginrequestmeta.StatusSourceFromContext(ctx)
+	pluginRequestDurationLabels := []string{pluginCtx.PluginID, endpoint, target, source}
+	pluginRequestCounterLabels := []string{pluginCtx.PluginID, endpoint, status, target, source}
+	pluginRequestDurationSecondsLabels := []string{"grafana-backend", pluginCtx.PluginID, endpoint, status, target, source}
+	if m.statusSourceLabel {
+		var statusSource pluginrequestmeta.StatusSource
+		if instrumentationEnabled(ctx, m.features, featuremgmt.FlagPluginsInstrumentationStatusSource) {
+			statusSource = pluginrequestmeta.StatusSourceFromContext(ctx)
+			if initial, final, ok := pluginrequestmeta.StatusSourceTransitionFromContext(ctx); ok {
+				m.pluginRequestStatusSourceTransitions.WithLabelValues(pluginCtx.PluginID, endpoint, string(initial), string(final)).Inc()
+			}
+		}
 		pluginRequestDurationLabels = append(pluginRequestDurationLabels, string(statusSource))
 		pluginRequestCounterLabels = append(pluginRequestCounterLabels, string(statusSource))
 		pluginRequestDurationSecondsLabels = append(pluginRequestDurationSecondsLabels, string(statusSource))
 	}
+	if m.datasourceUIDLabel {
+		uid := datasourceUID(pluginCtx)
+		pluginRequestDurationLabels = append(pluginRequestDurationLabels, uid)
+		pluginRequestCounterLabels = append(pluginRequestCounterLabels, uid)
+		pluginRequestDurationSecondsLabels = append(pluginRequestDurationSecondsLabels, uid)
+	}
+	if m.rangeRecency != nil {
+		pluginRequestCounterLabels = append(pluginRequestCounterLabels, rangeRecency)
+	}
+	if m.clientClassLabel {
+		pluginRequestCounterLabels = append(pluginRequestCounterLabels, clientClassFromContext(ctx))
+	}
+
+	// Requests made by alerting are kept out of the general duration histograms if they have a dedicated one
+	alerting := m.pluginAlertingRequestDuration != nil && pluginrequestmeta.RequestOriginFromContext(ctx) == pluginrequestmeta.RequestOriginAlerting
+	var pluginRequestDurationWithLabels prometheus.Observer
+	durationSeconds := m.pluginAlertingRequestDuration
+	if !alerting {
+		pluginRequestDurationWithLabels = m.pluginRequestDuration.WithLabelValues(pluginRequestDurationLabels...)
+		durationSeconds = m.pluginRequestDurationSeconds
+	}
 
-	pluginRequestDurationWithLabels := m.pluginRequestDuration.WithLabelValues(pluginRequestDurationLabels...)
 	pluginRequestCounterWithLabels := m.pluginRequestCounter.WithLabelValues(pluginRequestCounterLabels...)
-	pluginRequestDurationSecondsWithLabels := m.pluginRequestDurationSeconds.WithLabelValues(pluginRequestDurationSecondsLabels...)
+	pluginRequestDurationSecondsWithLabels := durationSeconds.WithLabelValues(pluginRequestDurationSecondsLabels...)
 
 	if traceID := tracing.TraceIDFromContext(ctx, true); traceID != "" {
-		pluginRequestDurationWithLabels.(prometheus.ExemplarObserver).ObserveWithExemplar(
-			float64(elapsed/time.Millisecond), prometheus.Labels{"traceID": traceID},
-		)
+		if pluginRequestDurationWithLabels != nil {
+			pluginRequestDurationWithLabels.(prometheus.ExemplarObserver).ObserveWithExemplar(
+				float64(elapsed/time.Millisecond), prometheus.Labels{"traceID": traceID},
+			)
+		}
 		pluginRequestCounterWithLabels.(prometheus.ExemplarAdder).AddWithExemplar(1, prometheus.Labels{"traceID": traceID})
 		pluginRequestDurationSecondsWithLabels.(prometheus.ExemplarObserver).ObserveWithExemplar(
 			elapsed.Seconds(), prometheus.Labels{"traceID": traceID},
 		)
 	} else {
-		pluginRequestDurationWithLabels.Observe(float64(elapsed / time.Millisecond))
+		if pluginRequestDurationWithLabels != nil {
+			pluginRequestDurationWithLabels.Observe(float64(elapsed / time.Millisecond))
+		}
 		pluginRequestCounterWithLabels.Inc()
 		pluginRequestDurationSecondsWithLabels.Observe(elapsed.Seconds())
 	}
@@ -166,11 +591,34 @@ func (m *MetricsMiddleware) QueryData(ctx context.Context, req *backend.QueryDat
 	if err := m.instrumentPluginRequestSize(ctx, req.PluginContext, endpointQueryData, requestSize); err != nil {
 		return nil, err
 	}
+	var rangeRecency string
+	if m.rangeRecency != nil {
+		rangeRecency = m.rangeRecency.label(time.Now(), req.Queries)
+	}
+	if _, fromAlert := req.Headers[ngalertmodels.FromAlertHeaderName]; fromAlert {
+		ctx = pluginrequestmeta.WithRequestOrigin(ctx, pluginrequestmeta.RequestOriginAlerting)
+	}
 	var resp *backend.QueryDataResponse
-	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointQueryData, func(ctx context.Context) (innerErr error) {
+	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointQueryData, rangeRecency, func(ctx context.Context) (innerErr error) {
 		resp, innerErr = m.next.QueryData(ctx, req)
+		if innerErr == nil && resp == nil {
+			innerErr = errNilQueryDataResponse
+		}
 		return
 	})
+	if resp != nil {
+		for _, r := range resp.Responses {
+			if err := m.instrumentPluginRequestError(ctx, req.PluginContext, endpointQueryData, int(r.Status)); err != nil {
+				return nil, err
+			}
+			if err := m.instrumentPluginRequestErrorCategory(ctx, req.PluginContext, endpointQueryData, r.Error); err != nil {
+				return nil, err
+			}
+		}
+		if err := m.instrumentQueryDataResponseSize(ctx, req.PluginContext, resp); err != nil {
+			return nil, err
+		}
+	}
 	return resp, err
 }
 
@@ -178,14 +626,42 @@ func (m *MetricsMiddleware) CallResource(ctx context.Context, req *backend.CallR
 	if err := m.instrumentPluginRequestSize(ctx, req.PluginContext, endpointCallResource, float64(len(req.Body))); err != nil {
 		return err
 	}
-	return m.instrumentPluginRequest(ctx, req.PluginContext, endpointCallResource, func(ctx context.Context) error {
-		return m.next.CallResource(ctx, req, sender)
+	return m.instrumentPluginRequest(ctx, req.PluginContext, endpointCallResource, "", func(ctx context.Context) error {
+		var statusCode int
+		var blocked time.Duration
+		var responses, responseSize int
+		err := m.next.CallResource(ctx, req, callResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+			if res != nil && statusCode == 0 {
+				statusCode = res.Status
+			}
+			// Streamed responses are sent in several parts, which are all counted in the size
+			if res != nil {
+				responses++
+				responseSize += len(res.Body)
+			}
+			// Sending blocks while the client doesn't consume the previous responses
+			start := time.Now()
+			defer func() { blocked += time.Since(start) }()
+			return sender.Send(res)
+		}))
+		if instrErr := m.instrumentPluginResourceSenderBlocked(ctx, req.PluginContext, blocked); instrErr != nil {
+			return instrErr
+		}
+		if responses > 0 {
+			if instrErr := m.instrumentPluginResponseSize(ctx, req.PluginContext, endpointCallResource, float64(responseSize)); instrErr != nil {
+				return instrErr
+			}
+		}
+		if instrErr := m.instrumentPluginRequestError(ctx, req.PluginContext, endpointCallResource, statusCode); instrErr != nil {
+			return instrErr
+		}
+		return err
 	})
 }
 
 func (m *MetricsMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	var result *backend.CheckHealthResult
-	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointCheckHealth, func(ctx context.Context) (innerErr error) {
+	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointCheckHealth, "", func(ctx context.Context) (innerErr error) {
 		result, innerErr = m.next.CheckHealth(ctx, req)
 		return
 	})
@@ -194,7 +670,7 @@ func (m *MetricsMiddleware) CheckHealth(ctx context.Context, req *backend.CheckH
 
 func (m *MetricsMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
 	var result *backend.CollectMetricsResult
-	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointCollectMetrics, func(ctx context.Context) (innerErr error) {
+	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointCollectMetrics, "", func(ctx context.Context) (innerErr error) {
 		result, innerErr = m.next.CollectMetrics(ctx, req)
 		return
 	})
@@ -202,13 +678,40 @@ func (m *MetricsMiddleware) CollectMetrics(ctx context.Context, req *backend.Col
 }
 
 func (m *MetricsMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
-	return m.next.SubscribeStream(ctx, req)
+	var resp *backend.SubscribeStreamResponse
+	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointSubscribeStream, "", func(ctx context.Context) (innerErr error) {
+		resp, innerErr = m.next.SubscribeStream(ctx, req)
+		return
+	})
+	return resp, err
 }
 
 func (m *MetricsMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
-	return m.next.PublishStream(ctx, req)
+	var resp *backend.PublishStreamResponse
+	err := m.instrumentPluginRequest(ctx, req.PluginContext, endpointPublishStream, "", func(ctx context.Context) (innerErr error) {
+		resp, innerErr = m.next.PublishStream(ctx, req)
+		return
+	})
+	return resp, err
 }
 
+// RunStream counts the start and the stop of the stream in the m.pluginStreamsStarted and m.pluginStreamsStopped
+// metrics, rather than observing its duration, as it runs for as long as the stream has subscribers.
 func (m *MetricsMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
-	return m.next.RunStream(ctx, req, sender)
+	target, source, err := m.pluginLabels(ctx, req.PluginContext.PluginID)
+	if err != nil {
+		return err
+	}
+	m.pluginStreamsStarted.WithLabelValues(req.PluginContext.PluginID, target, source).Inc()
+
+	err = m.next.RunStream(ctx, req, sender)
+	status := statusOK
+	if err != nil {
+		status = statusError
+		if errors.Is(err, context.Canceled) {
+			status = statusCancelled
+		}
+	}
+	m.pluginStreamsStopped.WithLabelValues(req.PluginContext.PluginID, status, target, source).Inc()
+	return err
 }
@@ -4,9 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
@@ -14,10 +21,15 @@ import (
 
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/plugins/backendplugin"
+	plog "github.com/grafana/grafana/pkg/plugins/log"
 	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
 	"github.com/grafana/grafana/pkg/plugins/manager/fakes"
+	"github.com/grafana/grafana/pkg/plugins/manager/registry"
 	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	ngalertmodels "github.com/grafana/grafana/pkg/services/ngalert/models"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
 )
 
 const (
@@ -27,6 +39,7 @@ const (
 	metricRequestDurationMs = "grafana_plugin_request_duration_milliseconds"
 	metricRequestDurationS  = "grafana_plugin_request_duration_seconds"
 	metricRequestSize       = "grafana_plugin_request_size_bytes"
+	metricResponseSize      = "grafana_plugin_response_size_bytes"
 )
 
 func TestInstrumentationMiddleware(t *testing.T) {
@@ -55,6 +68,9 @@ func TestInstrumentationMiddleware(t *testing.T) {
 			{
 				expEndpoint: endpointQueryData,
 				fn: func(cdt *clienttest.ClientDecoratorTest) error {
+					cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+						return backend.NewQueryDataResponse(), nil
+					}
 					_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
 					return err
 				},
@@ -68,6 +84,22 @@ func TestInstrumentationMiddleware(t *testing.T) {
 				},
 				shouldInstrumentRequestSize: false,
 			},
+			{
+				expEndpoint: endpointSubscribeStream,
+				fn: func(cdt *clienttest.ClientDecoratorTest) error {
+					_, err := cdt.Decorator.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{PluginContext: pCtx})
+					return err
+				},
+				shouldInstrumentRequestSize: false,
+			},
+			{
+				expEndpoint: endpointPublishStream,
+				fn: func(cdt *clienttest.ClientDecoratorTest) error {
+					_, err := cdt.Decorator.PublishStream(context.Background(), &backend.PublishStreamRequest{PluginContext: pCtx})
+					return err
+				},
+				shouldInstrumentRequestSize: false,
+			},
 		} {
 			t.Run(tc.expEndpoint, func(t *testing.T) {
 				promRegistry := prometheus.NewRegistry()
@@ -90,7 +122,7 @@ func TestInstrumentationMiddleware(t *testing.T) {
 				require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricRequestDurationMs))
 				require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricRequestDurationS))
 
-				counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, tc.expEndpoint, statusOK, string(backendplugin.TargetUnknown))
+				counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, tc.expEndpoint, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal)
 				require.Equal(t, 1.0, testutil.ToFloat64(counter))
 				for _, m := range []string{metricRequestDurationMs, metricRequestDurationS} {
 					require.NoError(t, checkHistogram(promRegistry, m, map[string]string{
@@ -113,13 +145,1113 @@ func TestInstrumentationMiddleware(t *testing.T) {
 	})
 }
 
+func TestInstrumentationMiddlewareResponseSize(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	newClient := func(t *testing.T, features ...any) (*MetricsMiddleware, *prometheus.Registry, *clienttest.ClientDecoratorTest) {
+		promRegistry := prometheus.NewRegistry()
+		pluginsRegistry := fakes.NewFakePluginRegistry()
+		require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+			JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+		}))
+		mw := newMetricsMiddleware(promRegistry, pluginsRegistry, featuremgmt.WithFeatures(features...))
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return mw, promRegistry, cdt
+	}
+	responseSize := func(t *testing.T, mw *MetricsMiddleware, endpoint string) (uint64, float64) {
+		t.Helper()
+		var m dto.Metric
+		observer := mw.pluginMetrics.pluginResponseSize.WithLabelValues(pluginID, endpoint, string(backendplugin.TargetUnknown), pluginSourceExternal)
+		require.NoError(t, observer.(prometheus.Metric).Write(&m))
+		return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+	}
+
+	t.Run("Should observe the size of the encoded query data response", func(t *testing.T) {
+		mw, _, cdt := newClient(t)
+		resp := &backend.QueryDataResponse{Responses: backend.Responses{
+			"A": {Frames: data.Frames{data.NewFrame("A", data.NewField("value", nil, []float64{1, 2, 3}))}},
+		}}
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return resp, nil
+		}
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+
+		b, err := resp.MarshalJSON()
+		require.NoError(t, err)
+		count, sum := responseSize(t, mw, endpointQueryData)
+		require.Equal(t, uint64(1), count)
+		require.Equal(t, float64(len(b)), sum)
+	})
+
+	t.Run("Should observe the size of the query data response regardless of the response encoding instrumentation", func(t *testing.T) {
+		for _, features := range [][]any{nil, {featuremgmt.FlagPluginsInstrumentationResponseEncoding}} {
+			mw, _, cdt := newClient(t, features...)
+			cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+				return backend.NewQueryDataResponse(), nil
+			}
+			_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+			require.NoError(t, err)
+
+			count, _ := responseSize(t, mw, endpointQueryData)
+			require.Equal(t, uint64(1), count)
+		}
+	})
+
+	t.Run("Should sum the size of the streamed resource responses", func(t *testing.T) {
+		mw, _, cdt := newClient(t)
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			for _, body := range []string{"first", "second", "third"} {
+				if err := sender.Send(&backend.CallResourceResponse{Status: http.StatusOK, Body: []byte(body)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		require.NoError(t, cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender))
+
+		count, sum := responseSize(t, mw, endpointCallResource)
+		require.Equal(t, uint64(1), count)
+		require.Equal(t, float64(len("first")+len("second")+len("third")), sum)
+	})
+
+	t.Run("Should not observe the check health responses", func(t *testing.T) {
+		_, promRegistry, cdt := newClient(t)
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricResponseSize))
+	})
+}
+
+func TestInstrumentationMiddlewareRequestDurationBuckets(t *testing.T) {
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+	newClient := func(t *testing.T, opts ...metricsMiddlewareOption) (*prometheus.Registry, *clienttest.ClientDecoratorTest) {
+		promRegistry := prometheus.NewRegistry()
+		mw := newMetricsMiddleware(promRegistry, pluginsRegistry, featuremgmt.WithFeatures(), opts...)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return promRegistry, cdt
+	}
+	buckets := func(t *testing.T, promRegistry *prometheus.Registry, metricName string) []float64 {
+		t.Helper()
+		metrics, err := promRegistry.Gather()
+		require.NoError(t, err)
+		for _, mf := range metrics {
+			if mf.GetName() != metricName {
+				continue
+			}
+			result := []float64{}
+			for _, b := range mf.GetMetric()[0].GetHistogram().GetBucket() {
+				result = append(result, b.GetUpperBound())
+			}
+			return result
+		}
+		require.Failf(t, "metric not found", metricName)
+		return nil
+	}
+	checkHealth := func(t *testing.T, cdt *clienttest.ClientDecoratorTest) {
+		t.Helper()
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
+		require.NoError(t, err)
+	}
+
+	t.Run("Should use the default buckets", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, withRequestDurationBuckets(nil, nil))
+		checkHealth(t, cdt)
+		require.Equal(t, defaultRequestDurationBucketsMilliseconds, buckets(t, promRegistry, metricRequestDurationMs))
+		require.Equal(t, defaultRequestDurationBucketsSeconds, buckets(t, promRegistry, metricRequestDurationS))
+	})
+
+	t.Run("Should use the custom buckets", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, withRequestDurationBuckets([]float64{1, 10, 100}, []float64{30, 60, 300}))
+		checkHealth(t, cdt)
+		require.Equal(t, []float64{1, 10, 100}, buckets(t, promRegistry, metricRequestDurationMs))
+		require.Equal(t, []float64{30, 60, 300}, buckets(t, promRegistry, metricRequestDurationS))
+	})
+
+	t.Run("Should keep the default buckets of the histograms without custom ones", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, withRequestDurationBuckets(nil, []float64{30, 60, 300}))
+		checkHealth(t, cdt)
+		require.Equal(t, defaultRequestDurationBucketsMilliseconds, buckets(t, promRegistry, metricRequestDurationMs))
+		require.Equal(t, []float64{30, 60, 300}, buckets(t, promRegistry, metricRequestDurationS))
+	})
+}
+
+func TestInstrumentationMiddlewareRunStream(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	for _, tc := range []struct {
+		desc      string
+		err       error
+		expStatus string
+	}{
+		{desc: "stream stopped by the plugin", expStatus: statusOK},
+		{desc: "stream failed", err: errors.New("boom"), expStatus: statusError},
+		{desc: "stream without subscribers", err: context.Canceled, expStatus: statusCancelled},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			promRegistry := prometheus.NewRegistry()
+			pluginsRegistry := fakes.NewFakePluginRegistry()
+			require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+				JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+			}))
+			mw := newMetricsMiddleware(promRegistry, pluginsRegistry, featuremgmt.WithFeatures())
+			cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+				plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+					mw.next = next
+					return mw
+				}),
+			))
+			started := func() float64 {
+				return testutil.ToFloat64(mw.pluginMetrics.pluginStreamsStarted.WithLabelValues(pluginID, string(backendplugin.TargetUnknown), pluginSourceExternal))
+			}
+
+			cdt.TestClient.RunStreamFunc = func(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+				// The start is recorded while the stream is running
+				require.Equal(t, 1.0, started())
+				require.Equal(t, 0, testutil.CollectAndCount(promRegistry, "grafana_plugin_streams_stopped_total"))
+				return tc.err
+			}
+			err := cdt.Decorator.RunStream(context.Background(), &backend.RunStreamRequest{PluginContext: pCtx}, &backend.StreamSender{})
+			require.Equal(t, tc.err, err)
+
+			require.Equal(t, 1.0, started())
+			stopped := mw.pluginMetrics.pluginStreamsStopped.WithLabelValues(pluginID, tc.expStatus, string(backendplugin.TargetUnknown), pluginSourceExternal)
+			require.Equal(t, 1.0, testutil.ToFloat64(stopped))
+			// The stream duration isn't observed
+			require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricRequestTotal))
+			require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricRequestDurationS))
+		})
+	}
+}
+
+func TestInstrumentationMiddlewareInFlight(t *testing.T) {
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+	mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, featuremgmt.WithFeatures())
+	cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+		plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+			mw.next = next
+			return mw
+		}),
+	))
+	inFlight := func(endpoint string) float64 {
+		return testutil.ToFloat64(mw.pluginMetrics.pluginRequestInFlight.WithLabelValues(pluginID, endpoint))
+	}
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	t.Run("Should count the concurrent requests", func(t *testing.T) {
+		const concurrency = 5
+		started := make(chan struct{})
+		release := make(chan struct{})
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			started <- struct{}{}
+			<-release
+			return backend.NewQueryDataResponse(), nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+				require.NoError(t, err)
+			}()
+		}
+		for i := 0; i < concurrency; i++ {
+			<-started
+		}
+		require.Equal(t, float64(concurrency), inFlight(endpointQueryData))
+		require.Equal(t, 0.0, inFlight(endpointCheckHealth))
+
+		close(release)
+		wg.Wait()
+		require.Equal(t, 0.0, inFlight(endpointQueryData))
+	})
+
+	t.Run("Should not count the requests that panicked", func(t *testing.T) {
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			panic("boom")
+		}
+		require.Panics(t, func() {
+			_, _ = cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		})
+		require.Equal(t, 0.0, inFlight(endpointCheckHealth))
+	})
+}
+
+func TestInstrumentationMiddlewarePluginSource(t *testing.T) {
+	promRegistry := prometheus.NewRegistry()
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	for _, p := range []*plugins.Plugin{
+		{JSONData: plugins.JSONData{ID: "core-plugin", Backend: true}, Class: plugins.ClassCore},
+		{JSONData: plugins.JSONData{ID: "bundled-plugin", Backend: true}, Class: plugins.ClassBundled},
+		{JSONData: plugins.JSONData{ID: "external-plugin", Backend: true}, Class: plugins.ClassExternal, Signature: plugins.SignatureStatusValid},
+		{JSONData: plugins.JSONData{ID: "dev-plugin", Backend: true}, Class: plugins.ClassExternal, Signature: plugins.SignatureStatusUnsigned},
+	} {
+		require.NoError(t, pluginsRegistry.Add(context.Background(), p))
+	}
+
+	mw := newMetricsMiddleware(promRegistry, pluginsRegistry, featuremgmt.WithFeatures())
+	cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+		plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+			mw.next = next
+			return mw
+		}),
+	))
+	cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+		return backend.NewQueryDataResponse(), nil
+	}
+
+	for _, tc := range []struct {
+		pluginID     string
+		expTarget    backendplugin.Target
+		expSource    string
+		otherSources []string
+	}{
+		{pluginID: "core-plugin", expTarget: backendplugin.TargetInMemory, expSource: pluginSourceCore, otherSources: []string{pluginSourceExternal, pluginSourceDev}},
+		{pluginID: "bundled-plugin", expTarget: backendplugin.TargetUnknown, expSource: pluginSourceCore, otherSources: []string{pluginSourceExternal, pluginSourceDev}},
+		{pluginID: "external-plugin", expTarget: backendplugin.TargetUnknown, expSource: pluginSourceExternal, otherSources: []string{pluginSourceCore, pluginSourceDev}},
+		{pluginID: "dev-plugin", expTarget: backendplugin.TargetUnknown, expSource: pluginSourceDev, otherSources: []string{pluginSourceCore, pluginSourceExternal}},
+	} {
+		t.Run(tc.pluginID, func(t *testing.T) {
+			_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+				PluginContext: backend.PluginContext{PluginID: tc.pluginID},
+			})
+			require.NoError(t, err)
+
+			counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(tc.pluginID, endpointQueryData, statusOK, string(tc.expTarget), tc.expSource)
+			require.Equal(t, 1.0, testutil.ToFloat64(counter))
+			for _, other := range tc.otherSources {
+				counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(tc.pluginID, endpointQueryData, statusOK, string(tc.expTarget), other)
+				require.Zero(t, testutil.ToFloat64(counter))
+			}
+		})
+	}
+}
+
+func TestInstrumentationMiddlewareStatusCode(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*MetricsMiddleware, *clienttest.ClientDecoratorTest) {
+		mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return mw, cdt
+	}
+
+	errorsCounter := func(mw *MetricsMiddleware, endpoint string, statusCode string) prometheus.Counter {
+		return mw.pluginMetrics.pluginRequestErrors.WithLabelValues(pluginID, endpoint, statusCode, string(backendplugin.TargetUnknown), pluginSourceExternal)
+	}
+
+	t.Run("Should not register the errors counter if feature flag is disabled", func(t *testing.T) {
+		mw, _ := newClient(t, featuremgmt.WithFeatures())
+		require.Nil(t, mw.pluginMetrics.pluginRequestErrors)
+	})
+
+	t.Run("QueryData", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusCode))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return &backend.QueryDataResponse{Responses: map[string]backend.DataResponse{
+				"A": {Status: http.StatusTooManyRequests, Error: errors.New("too many requests")},
+				"B": {Status: http.StatusServiceUnavailable, Error: errors.New("service unavailable")},
+				"C": {Status: 599, Error: errors.New("network connect timeout")},
+				"D": {Status: http.StatusOK},
+			}}, nil
+		}
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+
+		require.Equal(t, 1.0, testutil.ToFloat64(errorsCounter(mw, endpointQueryData, "429")))
+		require.Equal(t, 1.0, testutil.ToFloat64(errorsCounter(mw, endpointQueryData, "503")))
+		require.Equal(t, 1.0, testutil.ToFloat64(errorsCounter(mw, endpointQueryData, statusCodeOther)))
+		require.Equal(t, 3, testutil.CollectAndCount(mw.pluginMetrics.pluginRequestErrors))
+	})
+
+	t.Run("CallResource", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusCode))
+		for _, tc := range []struct {
+			status   int
+			expLabel string
+		}{
+			{status: http.StatusTooManyRequests, expLabel: "429"},
+			{status: http.StatusServiceUnavailable, expLabel: "503"},
+			{status: http.StatusTeapot, expLabel: statusCodeOther},
+		} {
+			cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+				return sender.Send(&backend.CallResourceResponse{Status: tc.status})
+			}
+			err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+			require.NoError(t, err)
+			require.Equal(t, 1.0, testutil.ToFloat64(errorsCounter(mw, endpointCallResource, tc.expLabel)))
+		}
+
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			return sender.Send(&backend.CallResourceResponse{Status: http.StatusOK})
+		}
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.NoError(t, err)
+		require.Equal(t, 3, testutil.CollectAndCount(mw.pluginMetrics.pluginRequestErrors))
+	})
+}
+
+func TestInstrumentationMiddlewareResourceSenderBlocked(t *testing.T) {
+	const (
+		responses = 3
+		delay     = 20 * time.Millisecond
+	)
+
+	promRegistry := prometheus.NewRegistry()
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+	mw := newMetricsMiddleware(promRegistry, pluginsRegistry, featuremgmt.WithFeatures())
+	cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+		plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+			mw.next = next
+			return mw
+		}),
+	))
+	cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+		for i := 0; i < responses; i++ {
+			if err := sender.Send(&backend.CallResourceResponse{Status: http.StatusOK, Body: []byte("chunk")}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	slowSender := callResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		time.Sleep(delay)
+		return nil
+	})
+	err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: backend.PluginContext{PluginID: pluginID}}, slowSender)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, testutil.CollectAndCount(promRegistry, "grafana_plugin_resource_sender_blocked_seconds"))
+	metrics, err := promRegistry.Gather()
+	require.NoError(t, err)
+	var histogram *dto.Histogram
+	for _, m := range metrics {
+		if m.GetName() == "grafana_plugin_resource_sender_blocked_seconds" {
+			histogram = m.GetMetric()[0].GetHistogram()
+		}
+	}
+	require.NotNil(t, histogram)
+	require.Equal(t, uint64(1), histogram.GetSampleCount())
+	require.GreaterOrEqual(t, histogram.GetSampleSum(), (responses * delay).Seconds())
+}
+
+func TestInstrumentationMiddlewareResponseEncoding(t *testing.T) {
+	const metricResponseEncode = "grafana_plugin_response_encode_seconds"
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*prometheus.Registry, *clienttest.ClientDecoratorTest) {
+		promRegistry := prometheus.NewRegistry()
+		pluginsRegistry := fakes.NewFakePluginRegistry()
+		require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+			JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+		}))
+		mw := newMetricsMiddleware(promRegistry, pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			resp := backend.NewQueryDataResponse()
+			resp.Responses["A"] = backend.DataResponse{Frames: data.Frames{
+				data.NewFrame("A", data.NewField("value", nil, []float64{1, 2, 3})),
+			}}
+			return resp, nil
+		}
+		return promRegistry, cdt
+	}
+
+	t.Run("Should observe the encoding time of the response", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationResponseEncoding))
+		resp, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
+		require.NoError(t, err)
+		encoded, err := resp.MarshalJSON()
+		require.NoError(t, err)
+
+		require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricResponseEncode))
+		metrics, err := promRegistry.Gather()
+		require.NoError(t, err)
+		var encodeHistogram, sizeHistogram *dto.Histogram
+		for _, m := range metrics {
+			switch m.GetName() {
+			case metricResponseEncode:
+				encodeHistogram = m.GetMetric()[0].GetHistogram()
+			case "grafana_plugin_response_size_bytes":
+				sizeHistogram = m.GetMetric()[0].GetHistogram()
+			case "grafana_plugin_request_size_bytes":
+				for _, metric := range m.GetMetric() {
+					for _, label := range metric.GetLabel() {
+						if label.GetName() == "source" {
+							require.NotEqual(t, "plugin", label.GetValue(), "the response size must not be observed as a request size")
+						}
+					}
+				}
+			}
+		}
+		require.NotNil(t, encodeHistogram)
+		require.Equal(t, uint64(1), encodeHistogram.GetSampleCount())
+		require.NotNil(t, sizeHistogram)
+		require.Equal(t, uint64(1), sizeHistogram.GetSampleCount())
+		require.Equal(t, float64(len(encoded)), sizeHistogram.GetSampleSum())
+	})
+
+	t.Run("Should not observe anything if not enabled", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, featuremgmt.WithFeatures())
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
+		require.NoError(t, err)
+		require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricResponseEncode))
+	})
+}
+
+func TestInstrumentationMiddlewareAlertingHistogram(t *testing.T) {
+	const metricAlertingRequestDuration = "grafana_plugin_alerting_request_duration_seconds"
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	alertingHeaders := map[string]string{ngalertmodels.FromAlertHeaderName: "true"}
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*prometheus.Registry, *clienttest.ClientDecoratorTest) {
+		promRegistry := prometheus.NewRegistry()
+		pluginsRegistry := fakes.NewFakePluginRegistry()
+		require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+			JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+		}))
+		mw := newMetricsMiddleware(promRegistry, pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return backend.NewQueryDataResponse(), nil
+		}
+		return promRegistry, cdt
+	}
+
+	t.Run("Should observe alerting requests only in the alerting histogram", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationAlertingHistogram))
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx, Headers: alertingHeaders})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricAlertingRequestDuration))
+		require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricRequestDurationS))
+		require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricRequestDurationMs))
+		require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricRequestTotal))
+	})
+
+	t.Run("Should observe interactive requests only in the general histograms", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationAlertingHistogram))
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+
+		require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricAlertingRequestDuration))
+		require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricRequestDurationS))
+		require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricRequestDurationMs))
+	})
+
+	t.Run("Should observe alerting requests in the general histograms if not enabled", func(t *testing.T) {
+		promRegistry, cdt := newClient(t, featuremgmt.WithFeatures())
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx, Headers: alertingHeaders})
+		require.NoError(t, err)
+
+		require.Equal(t, 0, testutil.CollectAndCount(promRegistry, metricAlertingRequestDuration))
+		require.Equal(t, 1, testutil.CollectAndCount(promRegistry, metricRequestDurationS))
+	})
+}
+
+func TestInstrumentationMiddlewareRestartFailures(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	newClient := func(t *testing.T) (*MetricsMiddleware, *clienttest.ClientDecoratorTest, *plugins.Plugin, *fakes.FakeBackendPlugin) {
+		backendPlugin := fakes.NewFakeBackendPlugin(true)
+		p := &plugins.Plugin{JSONData: plugins.JSONData{ID: pluginID, Backend: true}}
+		p.RegisterClient(localBackendPlugin{backendPlugin})
+		require.NoError(t, p.Start(context.Background()))
+
+		pluginsRegistry := fakes.NewFakePluginRegistry()
+		require.NoError(t, pluginsRegistry.Add(context.Background(), p))
+		mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, featuremgmt.WithFeatures())
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return mw, cdt, p, backendPlugin
+	}
+
+	restartFailures := func(mw *MetricsMiddleware, endpoint string) float64 {
+		return testutil.ToFloat64(mw.pluginMetrics.pluginRequestRestartFailures.WithLabelValues(pluginID, endpoint, string(backendplugin.TargetLocal), pluginSourceExternal))
+	}
+
+	t.Run("Should count failures caused by a restart during the call", func(t *testing.T) {
+		mw, cdt, p, backendPlugin := newClient(t)
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			// The plugin process crashes and is restarted while the request is in flight
+			backendPlugin.Kill()
+			require.NoError(t, p.Start(context.Background()))
+			return nil, plugins.ErrPluginUnavailable
+		}
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, plugins.ErrPluginUnavailable)
+		require.Equal(t, 1.0, restartFailures(mw, endpointQueryData))
+	})
+
+	t.Run("Should count failures of plugins that exited during the call", func(t *testing.T) {
+		mw, cdt, _, backendPlugin := newClient(t)
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			backendPlugin.Kill()
+			return nil, plugins.ErrPluginUnavailable
+		}
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, plugins.ErrPluginUnavailable)
+		require.Equal(t, 1.0, restartFailures(mw, endpointCheckHealth))
+	})
+
+	t.Run("Should not count steady-state failures", func(t *testing.T) {
+		mw, cdt, _, _ := newClient(t)
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, plugins.ErrPluginUnavailable
+		}
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, plugins.ErrPluginUnavailable)
+
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, errors.New("boom")
+		}
+		_, err = cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.Error(t, err)
+		require.Equal(t, 0, testutil.CollectAndCount(mw.pluginMetrics.pluginRequestRestartFailures))
+	})
+}
+
+// localBackendPlugin is a fakes.FakeBackendPlugin running locally.
+type localBackendPlugin struct {
+	*fakes.FakeBackendPlugin
+}
+
+func (localBackendPlugin) Target() backendplugin.Target {
+	return backendplugin.TargetLocal
+}
+
+func TestInstrumentationMiddlewareTarget(t *testing.T) {
+	for _, tc := range []struct {
+		desc      string
+		plugin    *plugins.Plugin
+		expTarget backendplugin.Target
+	}{
+		{
+			desc:      "core plugin without registered client",
+			plugin:    &plugins.Plugin{JSONData: plugins.JSONData{ID: pluginID, Backend: true}, Class: plugins.ClassCore},
+			expTarget: backendplugin.TargetInMemory,
+		},
+		{
+			desc:      "external plugin without registered client",
+			plugin:    &plugins.Plugin{JSONData: plugins.JSONData{ID: pluginID, Backend: true, Executable: "gpx_plugin"}, Class: plugins.ClassExternal},
+			expTarget: backendplugin.TargetLocal,
+		},
+		{
+			desc: "plugin with registered client",
+			plugin: func() *plugins.Plugin {
+				p := &plugins.Plugin{JSONData: plugins.JSONData{ID: pluginID, Backend: true}, Class: plugins.ClassExternal}
+				p.RegisterClient(localBackendPlugin{FakeBackendPlugin: &fakes.FakeBackendPlugin{}})
+				return p
+			}(),
+			expTarget: backendplugin.TargetLocal,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			pluginsRegistry := fakes.NewFakePluginRegistry()
+			require.NoError(t, pluginsRegistry.Add(context.Background(), tc.plugin))
+			mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, featuremgmt.WithFeatures())
+			cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+				plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+					mw.next = next
+					return mw
+				}),
+			))
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
+			require.NoError(t, err)
+
+			source := pluginSourceExternal
+			if tc.plugin.IsCorePlugin() {
+				source = pluginSourceCore
+			}
+			counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointCheckHealth, statusOK, string(tc.expTarget), source)
+			require.Equal(t, 1.0, testutil.ToFloat64(counter))
+			require.Equal(t, 1, testutil.CollectAndCount(mw.pluginMetrics.pluginRequestCounter))
+		})
+	}
+}
+
+func TestInstrumentationMiddlewareRangeRecency(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*MetricsMiddleware, *clienttest.ClientDecoratorTest) {
+		mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return backend.NewQueryDataResponse(), nil
+		}
+		return mw, cdt
+	}
+
+	queryEndingAt := func(to time.Time) backend.DataQuery {
+		return backend.DataQuery{TimeRange: backend.TimeRange{From: to.Add(-time.Hour), To: to}}
+	}
+
+	t.Run("Should not add the label if feature flag is disabled", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures())
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Nil(t, mw.rangeRecency)
+		counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointQueryData, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal)
+		require.Equal(t, 1.0, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Should label requests by the recency of the query time range", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationRangeRecency))
+		mw.rangeRecency.realtime = time.Minute
+		mw.rangeRecency.recent = time.Hour
+
+		now := time.Now()
+		for _, tc := range []struct {
+			desc     string
+			queries  []backend.DataQuery
+			expLabel string
+		}{
+			{desc: "ending now", queries: []backend.DataQuery{queryEndingAt(now)}, expLabel: rangeRecencyRealtime},
+			{desc: "ending in the future", queries: []backend.DataQuery{queryEndingAt(now.Add(time.Hour))}, expLabel: rangeRecencyRealtime},
+			{desc: "ending 30 minutes ago", queries: []backend.DataQuery{queryEndingAt(now.Add(-30 * time.Minute))}, expLabel: rangeRecencyRecent},
+			{desc: "ending a week ago", queries: []backend.DataQuery{queryEndingAt(now.Add(-7 * 24 * time.Hour))}, expLabel: rangeRecencyHistorical},
+			{
+				desc:     "with the most recent query ending 30 minutes ago",
+				queries:  []backend.DataQuery{queryEndingAt(now.Add(-7 * 24 * time.Hour)), queryEndingAt(now.Add(-30 * time.Minute))},
+				expLabel: rangeRecencyRecent,
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointQueryData, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal, tc.expLabel)
+				before := testutil.ToFloat64(counter)
+				_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx, Queries: tc.queries})
+				require.NoError(t, err)
+				require.Equal(t, before+1, testutil.ToFloat64(counter))
+			})
+		}
+
+		t.Run("other endpoints have an empty label", func(t *testing.T) {
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			require.NoError(t, err)
+			counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointCheckHealth, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal, "")
+			require.Equal(t, 1.0, testutil.ToFloat64(counter))
+		})
+	})
+
+	t.Run("Should use the configured bucket boundaries", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.PluginRangeRecencyRealtime = 2 * time.Hour
+		cfg.PluginRangeRecencyRecent = 4 * time.Hour
+		mw := NewMetricsMiddleware(cfg, prometheus.NewRegistry(), pluginsRegistry, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationRangeRecency))
+		client := mw.CreateClientMiddleware(&clienttest.TestClient{})
+		require.Equal(t, &rangeRecencyBuckets{realtime: 2 * time.Hour, recent: 4 * time.Hour}, client.(*MetricsMiddleware).rangeRecency)
+	})
+}
+
+func TestInstrumentationMiddlewareClientClass(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+
+	t.Run("Should bucket User-Agents into client classes", func(t *testing.T) {
+		for _, tc := range []struct {
+			userAgent string
+			expLabel  string
+		}{
+			{userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36", expLabel: clientClassBrowser},
+			{userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0", expLabel: clientClassBrowser},
+			{userAgent: "Go-http-client/1.1", expLabel: clientClassSDK},
+			{userAgent: "python-requests/2.32.3", expLabel: clientClassSDK},
+			{userAgent: "curl/8.7.1", expLabel: clientClassSDK},
+			{userAgent: "axios/1.7.2", expLabel: clientClassSDK},
+			{userAgent: "GrafanaAgent/v0.40.0", expLabel: clientClassAgent},
+			{userAgent: "grafana-agent/v0.40.0 (static; linux; binary)", expLabel: clientClassAgent},
+			{userAgent: "Alloy/v1.2.0 (linux; docker)", expLabel: clientClassAgent},
+			{userAgent: "Prometheus/2.53.0", expLabel: clientClassAgent},
+			{userAgent: "Terraform/1.8.5 (+https://www.terraform.io) terraform-provider-grafana/3.0.0", expLabel: clientClassAgent},
+			{userAgent: "", expLabel: clientClassOther},
+			{userAgent: "my-custom-script", expLabel: clientClassOther},
+		} {
+			require.Equal(t, tc.expLabel, clientClassLabel(tc.userAgent), tc.userAgent)
+		}
+	})
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles, opts ...clienttest.ClientDecoratorTestOption) (*MetricsMiddleware, *clienttest.ClientDecoratorTest) {
+		mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, append([]clienttest.ClientDecoratorTestOption{clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		)}, opts...)...)
+		return mw, cdt
+	}
+
+	t.Run("Should not add the label if feature flag is disabled", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures())
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.False(t, mw.clientClassLabel)
+		counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointCheckHealth, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal)
+		require.Equal(t, 1.0, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Should label requests by the User-Agent of the HTTP request", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/api/ds/query", nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", "python-requests/2.32.3")
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationClientClass), clienttest.WithReqContext(req, &user.SignedInUser{}))
+		_, err = cdt.Decorator.CheckHealth(req.Context(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointCheckHealth, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal, clientClassSDK)
+		require.Equal(t, 1.0, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Should label requests without an HTTP request as other", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationClientClass))
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		counter := mw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointCheckHealth, statusOK, string(backendplugin.TargetUnknown), pluginSourceExternal, clientClassOther)
+		require.Equal(t, 1.0, testutil.ToFloat64(counter))
+	})
+}
+
+// countingPluginRegistry is a plugin registry counting the plugin lookups.
+type countingPluginRegistry struct {
+	registry.Service
+	lookups int
+}
+
+func (r *countingPluginRegistry) Plugin(ctx context.Context, id string) (*plugins.Plugin, bool) {
+	r.lookups++
+	return r.Service.Plugin(ctx, id)
+}
+
+func TestInstrumentationMiddlewareDatasourceUID(t *testing.T) {
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*MetricsMiddleware, *prometheus.Registry, *clienttest.ClientDecoratorTest) {
+		promRegistry := prometheus.NewRegistry()
+		mw := newMetricsMiddleware(promRegistry, pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return mw, promRegistry, cdt
+	}
+	counterLabels := prometheus.Labels{
+		"plugin_id":     pluginID,
+		"endpoint":      endpointCheckHealth,
+		"status":        statusOK,
+		"target":        string(backendplugin.TargetUnknown),
+		"plugin_source": pluginSourceExternal,
+	}
+	datasourceCtx := backend.PluginContext{PluginID: pluginID, DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "prometheus-eu"}}
+
+	t.Run("Should not add the label if feature flag is disabled", func(t *testing.T) {
+		mw, _, cdt := newClient(t, featuremgmt.WithFeatures())
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: datasourceCtx})
+		require.NoError(t, err)
+
+		_, err = mw.pluginMetrics.pluginRequestCounter.GetMetricWith(newLabels(counterLabels, prometheus.Labels{"datasource_uid": "prometheus-eu"}))
+		require.ErrorContains(t, err, "inconsistent label cardinality")
+		counter, err := mw.pluginMetrics.pluginRequestCounter.GetMetricWith(counterLabels)
+		require.NoError(t, err)
+		require.Equal(t, 1.0, testutil.ToFloat64(counter))
+	})
+
+	t.Run("Should label requests by data source", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc   string
+			pCtx   backend.PluginContext
+			expUID string
+		}{
+			{desc: "data source", pCtx: datasourceCtx, expUID: "prometheus-eu"},
+			{desc: "app plugin", pCtx: backend.PluginContext{PluginID: pluginID, AppInstanceSettings: &backend.AppInstanceSettings{}}, expUID: datasourceUIDNone},
+			{desc: "data source without UID", pCtx: backend.PluginContext{PluginID: pluginID, DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{}}, expUID: datasourceUIDNone},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				mw, promRegistry, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationDatasourceUID))
+				_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: tc.pCtx})
+				require.NoError(t, err)
+
+				counter, err := mw.pluginMetrics.pluginRequestCounter.GetMetricWith(newLabels(counterLabels, prometheus.Labels{"datasource_uid": tc.expUID}))
+				require.NoError(t, err)
+				require.Equal(t, 1.0, testutil.ToFloat64(counter))
+				histogramLabels := map[string]string{"plugin_id": pluginID, "endpoint": endpointCheckHealth, "datasource_uid": tc.expUID}
+				require.NoError(t, checkHistogram(promRegistry, metricRequestDurationMs, histogramLabels))
+				require.NoError(t, checkHistogram(promRegistry, metricRequestDurationS, histogramLabels))
+			})
+		}
+	})
+
+	t.Run("Should be compatible with the status source label", func(t *testing.T) {
+		features := featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationDatasourceUID, featuremgmt.FlagPluginsInstrumentationStatusSource)
+		mw, _, cdt := newClient(t, features)
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: datasourceCtx})
+		require.NoError(t, err)
+
+		counter, err := mw.pluginMetrics.pluginRequestCounter.GetMetricWith(newLabels(counterLabels, prometheus.Labels{
+			"datasource_uid": "prometheus-eu",
+			"status_source":  string(pluginrequestmeta.StatusSourcePlugin),
+		}))
+		require.NoError(t, err)
+		require.Equal(t, 1.0, testutil.ToFloat64(counter))
+	})
+}
+
+func TestInstrumentationMiddlewareRegistryLookup(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*MetricsMiddleware, *countingPluginRegistry, *clienttest.ClientDecoratorTest) {
+		pluginsRegistry := &countingPluginRegistry{Service: fakes.NewFakePluginRegistry()}
+		require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+			JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+		}))
+		mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return mw, pluginsRegistry, cdt
+	}
+
+	t.Run("Should not cache lookups if feature flag is disabled", func(t *testing.T) {
+		mw, pluginsRegistry, cdt := newClient(t, featuremgmt.WithFeatures())
+		for i := 0; i < 2; i++ {
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			require.NoError(t, err)
+		}
+		require.Nil(t, mw.lookupCache)
+		require.Nil(t, mw.pluginRegistryLookupDuration)
+		require.Equal(t, 2, pluginsRegistry.lookups)
+	})
+
+	t.Run("Should observe the lookups and cache them", func(t *testing.T) {
+		mw, pluginsRegistry, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationRegistryLookup))
+		for i := 0; i < 3; i++ {
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			require.NoError(t, err)
+		}
+		require.Equal(t, 1, pluginsRegistry.lookups)
+		require.Equal(t, 1.0, testutil.ToFloat64(mw.pluginRegistryLookupCacheMisses))
+		require.Equal(t, 1, testutil.CollectAndCount(mw.pluginRegistryLookupDuration))
+		var m dto.Metric
+		require.NoError(t, mw.pluginRegistryLookupDuration.Write(&m))
+		require.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+
+		t.Run("missing plugins are not cached", func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{PluginID: "missing"}})
+				require.ErrorIs(t, err, plugins.ErrPluginNotRegistered)
+			}
+			require.Equal(t, 3.0, testutil.ToFloat64(mw.pluginRegistryLookupCacheMisses))
+		})
+	})
+
+	t.Run("Should expire cached lookups", func(t *testing.T) {
+		c := newPluginLookupCache(time.Second)
+		p := &plugins.Plugin{JSONData: plugins.JSONData{ID: pluginID}}
+		now := time.Now()
+		c.set(pluginID, p, now)
+		cached, ok := c.get(pluginID, now.Add(500*time.Millisecond))
+		require.True(t, ok)
+		require.Same(t, p, cached)
+		_, ok = c.get(pluginID, now.Add(time.Second))
+		require.False(t, ok)
+	})
+}
+
+func TestInstrumentationMiddlewareErrorCategory(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+
+	newClient := func(t *testing.T, features featuremgmt.FeatureToggles) (*MetricsMiddleware, *clienttest.ClientDecoratorTest) {
+		mw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+				mw.next = next
+				return mw
+			}),
+		))
+		return mw, cdt
+	}
+
+	categoryCounter := func(mw *MetricsMiddleware, endpoint string, category ErrorCategory) prometheus.Counter {
+		return mw.pluginMetrics.pluginRequestErrorCategories.WithLabelValues(pluginID, endpoint, string(category), string(backendplugin.TargetUnknown), pluginSourceExternal)
+	}
+
+	t.Run("Should not register the error categories counter if feature flag is disabled", func(t *testing.T) {
+		mw, _ := newClient(t, featuremgmt.WithFeatures())
+		require.Nil(t, mw.pluginMetrics.pluginRequestErrorCategories)
+	})
+
+	t.Run("Should count the query errors by category", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationErrorCategory))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return &backend.QueryDataResponse{Responses: map[string]backend.DataResponse{
+				"A": {Status: http.StatusTooManyRequests, Error: errors.New("too many requests")},
+				"B": {Status: http.StatusBadRequest, Error: errors.New("bad_data: parse error at char 4")},
+				"C": {Status: http.StatusBadRequest, Error: errors.New("syntax error near SELECT")},
+				"D": {Status: http.StatusOK},
+			}}, nil
+		}
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+
+		require.Equal(t, 1.0, testutil.ToFloat64(categoryCounter(mw, endpointQueryData, ErrorCategoryRateLimited)))
+		require.Equal(t, 2.0, testutil.ToFloat64(categoryCounter(mw, endpointQueryData, ErrorCategoryQuerySyntax)))
+		require.Equal(t, 2, testutil.CollectAndCount(mw.pluginMetrics.pluginRequestErrorCategories))
+	})
+
+	t.Run("Should count the failed requests by category", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationErrorCategory))
+		for _, err := range []error{context.DeadlineExceeded, syscall.ECONNREFUSED, errors.New("boom")} {
+			cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+				return nil, err
+			}
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			require.Error(t, err)
+		}
+
+		require.Equal(t, 1.0, testutil.ToFloat64(categoryCounter(mw, endpointCheckHealth, ErrorCategoryTimeout)))
+		require.Equal(t, 1.0, testutil.ToFloat64(categoryCounter(mw, endpointCheckHealth, ErrorCategoryConnection)))
+		require.Equal(t, 1.0, testutil.ToFloat64(categoryCounter(mw, endpointCheckHealth, ErrorCategoryOther)))
+	})
+
+	t.Run("Should not count the cancelled requests", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationErrorCategory))
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, context.Canceled
+		}
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Zero(t, testutil.CollectAndCount(mw.pluginMetrics.pluginRequestErrorCategories))
+	})
+
+	t.Run("Should use the custom classifiers first", func(t *testing.T) {
+		mw, cdt := newClient(t, featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationErrorCategory))
+		mw.classifyError = newErrorClassifier(func(err error) ErrorCategory {
+			if strings.Contains(err.Error(), "ORA-01017") {
+				return ErrorCategoryAuth
+			}
+			return ""
+		})
+		for _, err := range []error{errors.New("ORA-01017: invalid username/password"), errors.New("i/o timeout")} {
+			cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+				return nil, err
+			}
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			require.Error(t, err)
+		}
+
+		require.Equal(t, 1.0, testutil.ToFloat64(categoryCounter(mw, endpointCheckHealth, ErrorCategoryAuth)))
+		require.Equal(t, 1.0, testutil.ToFloat64(categoryCounter(mw, endpointCheckHealth, ErrorCategoryTimeout)))
+	})
+}
+
+func TestInstrumentationMiddlewareNilQueryDataResponse(t *testing.T) {
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+	features := featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusSource)
+	metricsMw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+	cfg := setting.NewCfg()
+	cfg.PluginLogBackendRequests = true
+	cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+		NewPluginRequestMetaMiddleware(),
+		plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+			metricsMw.next = next
+			return metricsMw
+		}),
+		NewLoggerMiddleware(cfg, plog.NewTestLogger(), features),
+		NewStatusSourceMiddleware(),
+	))
+	cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+		return nil, nil
+	}
+
+	require.NotPanics(t, func() {
+		resp, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{PluginID: pluginID},
+		})
+		require.ErrorIs(t, err, errNilQueryDataResponse)
+		require.Nil(t, resp)
+	})
+
+	counter := metricsMw.pluginMetrics.pluginRequestCounter.WithLabelValues(
+		pluginID, endpointQueryData, statusError, string(backendplugin.TargetUnknown), pluginSourceExternal,
+		string(pluginrequestmeta.StatusSourcePlugin),
+	)
+	require.Equal(t, 1.0, testutil.ToFloat64(counter))
+}
+
 func TestInstrumentationMiddlewareStatusSource(t *testing.T) {
 	const labelStatusSource = "status_source"
 	queryDataCounterLabels := prometheus.Labels{
-		"plugin_id": pluginID,
-		"endpoint":  endpointQueryData,
-		"status":    statusOK,
-		"target":    string(backendplugin.TargetUnknown),
+		"plugin_id":     pluginID,
+		"endpoint":      endpointQueryData,
+		"status":        statusOK,
+		"target":        string(backendplugin.TargetUnknown),
+		"plugin_source": pluginSourceExternal,
 	}
 	downstreamErrorResponse := backend.DataResponse{
 		Frames:      nil,
@@ -282,6 +1414,80 @@ func TestInstrumentationMiddlewareStatusSource(t *testing.T) {
 	})
 }
 
+func TestInstrumentationMiddlewareCheckHealthStatusSource(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+
+	for _, tc := range []struct {
+		name            string
+		features        featuremgmt.FeatureToggles
+		result          *backend.CheckHealthResult
+		err             error
+		expStatus       string
+		expStatusSource pluginrequestmeta.StatusSource
+	}{
+		{
+			name:            "Downstream error",
+			features:        featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusSource),
+			err:             errorsource.DownstreamError(errors.New("connection refused"), false),
+			expStatus:       statusError,
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+		},
+		{
+			name:            "Plugin error",
+			features:        featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusSource),
+			err:             errors.New("nil pointer dereference"),
+			expStatus:       statusError,
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            "Unhealthy downstream",
+			features:        featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusSource),
+			result:          &backend.CheckHealthResult{Status: backend.HealthStatusError, JSONDetails: []byte(`{"errorSource": "downstream"}`)},
+			expStatus:       statusOK,
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+		},
+		{
+			name:            "Unhealthy plugin",
+			features:        featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusSource),
+			result:          &backend.CheckHealthResult{Status: backend.HealthStatusError},
+			expStatus:       statusOK,
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			// The label is only added for the per-request overrides, so it's left empty
+			name:            "Downstream error with feature flag disabled",
+			features:        featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationOverrides),
+			err:             errorsource.DownstreamError(errors.New("connection refused"), false),
+			expStatus:       statusError,
+			expStatusSource: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			metricsMw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, tc.features)
+			cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+				NewPluginRequestMetaMiddleware(),
+				plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+					metricsMw.next = next
+					return metricsMw
+				}),
+				NewStatusSourceMiddleware(),
+			))
+			cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+				return tc.result, tc.err
+			}
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+			require.Equal(t, tc.err, err)
+
+			counter := metricsMw.pluginMetrics.pluginRequestCounter.WithLabelValues(pluginID, endpointCheckHealth, tc.expStatus, string(backendplugin.TargetUnknown), pluginSourceExternal, string(tc.expStatusSource))
+			require.Equal(t, 1.0, testutil.ToFloat64(counter))
+		})
+	}
+}
+
 // checkHistogram is a utility function that checks if a histogram with the given name and label values exists
 // and has been observed at least once.
 func checkHistogram(promRegistry *prometheus.Registry, expMetricName string, expLabels map[string]string) error {
@@ -347,3 +1553,67 @@ func newLabels(initialLabels prometheus.Labels, additional ...prometheus.Labels)
 	}
 	return r
 }
+
+// retryClient retries the query data requests that failed because of a downstream error once.
+type retryClient struct {
+	plugins.Client
+}
+
+func (c *retryClient) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	resp, err := c.Client.QueryData(ctx, req)
+	if err == nil && pluginrequestmeta.StatusSourceFromContext(ctx) == pluginrequestmeta.StatusSourceDownstream {
+		return c.Client.QueryData(ctx, req)
+	}
+	return resp, err
+}
+
+func TestInstrumentationMiddlewareStatusSourceTransitions(t *testing.T) {
+	pluginsRegistry := fakes.NewFakePluginRegistry()
+	require.NoError(t, pluginsRegistry.Add(context.Background(), &plugins.Plugin{
+		JSONData: plugins.JSONData{ID: pluginID, Backend: true},
+	}))
+	features := featuremgmt.WithFeatures(featuremgmt.FlagPluginsInstrumentationStatusSource)
+	metricsMw := newMetricsMiddleware(prometheus.NewRegistry(), pluginsRegistry, features)
+	cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+		NewPluginRequestMetaMiddleware(),
+		plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+			metricsMw.next = next
+			return metricsMw
+		}),
+		plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+			return &retryClient{Client: next}
+		}),
+		NewStatusSourceMiddleware(),
+	))
+	downstreamErrorResponse := backend.DataResponse{Error: errors.New("bad gateway"), Status: 502, ErrorSource: backend.ErrorSourceDownstream}
+	okResponse := backend.DataResponse{Status: 200}
+	transitions := func(initial, final pluginrequestmeta.StatusSource) float64 {
+		return testutil.ToFloat64(metricsMw.pluginRequestStatusSourceTransitions.WithLabelValues(pluginID, endpointQueryData, string(initial), string(final)))
+	}
+	queryData := func(t *testing.T, responses ...backend.DataResponse) {
+		t.Helper()
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			resp := responses[0]
+			responses = responses[1:]
+			return &backend.QueryDataResponse{Responses: map[string]backend.DataResponse{"A": resp}}, nil
+		}
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
+		require.NoError(t, err)
+	}
+
+	t.Run("Should record a downstream error turned into a success by a retry", func(t *testing.T) {
+		queryData(t, downstreamErrorResponse, okResponse)
+		require.Equal(t, 1.0, transitions(pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourcePlugin))
+		require.Equal(t, 0.0, transitions(pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourceDownstream))
+	})
+
+	t.Run("Should record the status source of the requests that aren't retried", func(t *testing.T) {
+		queryData(t, okResponse)
+		require.Equal(t, 1.0, transitions(pluginrequestmeta.StatusSourcePlugin, pluginrequestmeta.StatusSourcePlugin))
+	})
+
+	t.Run("Should record the retries that fail too", func(t *testing.T) {
+		queryData(t, downstreamErrorResponse, downstreamErrorResponse)
+		require.Equal(t, 1.0, transitions(pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourceDownstream))
+	})
+}
@@ -3,8 +3,11 @@ package clientmiddleware
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/services/contexthandler"
@@ -15,11 +18,22 @@ import (
 // NewOAuthTokenMiddleware creates a new plugins.ClientMiddleware that will
 // set OAuth token headers on outgoing plugins.Client requests if the
 // datasource has enabled Forward OAuth Identity (oauthPassThru).
-func NewOAuthTokenMiddleware(oAuthTokenService oauthtoken.OAuthTokenService) plugins.ClientMiddleware {
+// The time spent getting the token, which includes refreshing it if it has expired,
+// is observed per plugin so it can be told apart from the time spent in the plugin.
+func NewOAuthTokenMiddleware(oAuthTokenService oauthtoken.OAuthTokenService, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	tokenDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Name:      "plugin_request_oauth_token_duration_seconds",
+		Help:      "Time spent getting the OAuth token forwarded to a plugin, including refreshing it",
+		Buckets:   []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"plugin_id"})
+	promRegisterer.MustRegister(tokenDuration)
+
 	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
 		return &OAuthTokenMiddleware{
 			next:              next,
 			oAuthTokenService: oAuthTokenService,
+			tokenDuration:     tokenDuration,
 		}
 	})
 }
@@ -31,6 +45,7 @@ const (
 
 type OAuthTokenMiddleware struct {
 	oAuthTokenService oauthtoken.OAuthTokenService
+	tokenDuration     *prometheus.HistogramVec
 	next              plugins.Client
 }
 
@@ -55,7 +70,10 @@ func (m *OAuthTokenMiddleware) applyToken(ctx context.Context, pCtx backend.Plug
 	}
 
 	if m.oAuthTokenService.IsOAuthPassThruEnabled(ds) {
-		if token := m.oAuthTokenService.GetCurrentOAuthToken(ctx, reqCtx.SignedInUser); token != nil {
+		start := time.Now()
+		token := m.oAuthTokenService.GetCurrentOAuthToken(ctx, reqCtx.SignedInUser)
+		m.tokenDuration.WithLabelValues(pCtx.PluginID).Observe(time.Since(start).Seconds())
+		if token != nil {
 			authorizationHeader := fmt.Sprintf("%s %s", token.Type(), token.AccessToken)
 			idTokenHeader := ""
 
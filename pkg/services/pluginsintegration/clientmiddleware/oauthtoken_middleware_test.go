@@ -1,14 +1,19 @@
 package clientmiddleware
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/services/auth/identity"
+	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/oauthtoken/oauthtokentest"
 	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 )
@@ -25,7 +30,7 @@ func TestOAuthTokenMiddleware(t *testing.T) {
 		oAuthTokenService := &oauthtokentest.Service{}
 		cdt := clienttest.NewClientDecoratorTest(t,
 			clienttest.WithReqContext(req, &user.SignedInUser{}),
-			clienttest.WithMiddlewares(NewOAuthTokenMiddleware(oAuthTokenService)),
+			clienttest.WithMiddlewares(NewOAuthTokenMiddleware(oAuthTokenService, prometheus.NewRegistry())),
 		)
 
 		jsonDataMap := map[string]any{}
@@ -88,7 +93,7 @@ func TestOAuthTokenMiddleware(t *testing.T) {
 		}
 		cdt := clienttest.NewClientDecoratorTest(t,
 			clienttest.WithReqContext(req, &user.SignedInUser{}),
-			clienttest.WithMiddlewares(NewOAuthTokenMiddleware(oAuthTokenService)),
+			clienttest.WithMiddlewares(NewOAuthTokenMiddleware(oAuthTokenService, prometheus.NewRegistry())),
 		)
 
 		jsonDataMap := map[string]any{
@@ -144,4 +149,53 @@ func TestOAuthTokenMiddleware(t *testing.T) {
 			require.Equal(t, "id-token", cdt.CheckHealthReq.Headers[idTokenHeaderName])
 		})
 	})
+
+	t.Run("Should observe the time spent getting the token separately from the plugin request", func(t *testing.T) {
+		const refreshLatency = 20 * time.Millisecond
+		req, err := http.NewRequest(http.MethodGet, "/some/thing", nil)
+		require.NoError(t, err)
+
+		// Simulates a token refresh
+		oAuthTokenService := &oauthtokentest.MockOauthTokenService{
+			GetCurrentOauthTokenFunc: func(ctx context.Context, usr identity.Requester) *oauth2.Token {
+				time.Sleep(refreshLatency)
+				return &oauth2.Token{TokenType: "bearer", AccessToken: "access-token"}
+			},
+			IsOAuthPassThruEnabledFunc: func(ds *datasources.DataSource) bool {
+				return true
+			},
+		}
+		promRegistry := prometheus.NewRegistry()
+		cdt := clienttest.NewClientDecoratorTest(t,
+			clienttest.WithReqContext(req, &user.SignedInUser{}),
+			clienttest.WithMiddlewares(NewOAuthTokenMiddleware(oAuthTokenService, promRegistry)),
+		)
+		var authorizationHeader string
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			authorizationHeader = req.Headers[tokenHeaderName]
+			time.Sleep(5 * refreshLatency)
+			return nil, nil
+		}
+
+		_, err = cdt.Decorator.QueryData(req.Context(), &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{
+				PluginID:                   pluginID,
+				DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{JSONData: []byte(`{}`)},
+			},
+			Headers: map[string]string{},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "Bearer access-token", authorizationHeader)
+
+		metricFamilies, err := promRegistry.Gather()
+		require.NoError(t, err)
+		require.Len(t, metricFamilies, 1)
+		require.Equal(t, "grafana_plugin_request_oauth_token_duration_seconds", metricFamilies[0].GetName())
+		require.Len(t, metricFamilies[0].GetMetric(), 1)
+		metric := metricFamilies[0].GetMetric()[0]
+		require.Equal(t, pluginID, metric.GetLabel()[0].GetValue())
+		require.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+		require.GreaterOrEqual(t, metric.GetHistogram().GetSampleSum(), refreshLatency.Seconds())
+		require.Less(t, metric.GetHistogram().GetSampleSum(), (5 * refreshLatency).Seconds())
+	})
 }
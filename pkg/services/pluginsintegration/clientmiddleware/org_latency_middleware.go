@@ -0,0 +1,277 @@
+package clientmiddleware
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+const (
+	// orgLatencyCandidatesFactor is the number of orgs whose request volume is counted, relative to the number of
+	// tracked orgs, so that an org whose volume grows can take the place of a tracked one.
+	orgLatencyCandidatesFactor = 8
+	// orgLatencySamples is the number of latest durations kept for each tracked org to compute the percentiles.
+	orgLatencySamples = 512
+	// orgLatencyQueueSize is the number of durations waiting to be added to the summaries.
+	// Durations observed while the queue is full are dropped.
+	orgLatencyQueueSize = 4096
+)
+
+// OrgLatencySummary is the latency of the plugin requests of an org, in seconds.
+// The percentiles are computed over the latest requests only.
+type OrgLatencySummary struct {
+	// OrgID is 0 for the summary of the orgs that aren't tracked on their own.
+	OrgID    int64   `json:"orgId"`
+	Requests int64   `json:"requests"`
+	P50      float64 `json:"p50"`
+	P90      float64 `json:"p90"`
+	P99      float64 `json:"p99"`
+}
+
+// OrgLatencies are the latency summaries of the orgs with the most plugin requests, from the most to the least
+// requested one, and the summary of all the other orgs.
+type OrgLatencies struct {
+	Orgs  []OrgLatencySummary `json:"orgs"`
+	Other OrgLatencySummary   `json:"other"`
+}
+
+type orgLatencyObservation struct {
+	orgID    int64
+	duration time.Duration
+}
+
+// OrgLatencyTracker keeps the latency summaries of the plugin requests of the size orgs with the most requests.
+// The requests of the other orgs are folded into an aggregate summary, so the memory used is bounded whatever the
+// number of orgs. Unlike a Prometheus org_id label, this doesn't create a series per org.
+//
+// The request volumes are counted with the Space-Saving algorithm over a bounded set of candidate orgs, and
+// the durations are added to the summaries by a goroutine, off the request path.
+type OrgLatencyTracker struct {
+	size         int
+	observations chan orgLatencyObservation
+
+	mu      sync.Mutex
+	counts  map[int64]int64
+	tracked map[int64]*latencySummary
+	other   *latencySummary
+}
+
+// NewOrgLatencyTracker returns a new OrgLatencyTracker tracking the size most requested orgs.
+// It doesn't track anything if size is 0.
+func NewOrgLatencyTracker(size int) *OrgLatencyTracker {
+	t := &OrgLatencyTracker{
+		size:         size,
+		observations: make(chan orgLatencyObservation, orgLatencyQueueSize),
+		counts:       map[int64]int64{},
+		tracked:      map[int64]*latencySummary{},
+		other:        newLatencySummary(),
+	}
+	if size > 0 {
+		go func() {
+			for o := range t.observations {
+				t.add(o.orgID, o.duration)
+			}
+		}()
+	}
+	return t
+}
+
+// Observe queues the duration of a plugin request of the given org.
+func (t *OrgLatencyTracker) Observe(orgID int64, duration time.Duration) {
+	if t.size <= 0 {
+		return
+	}
+	select {
+	case t.observations <- orgLatencyObservation{orgID: orgID, duration: duration}:
+	default:
+	}
+}
+
+// Latencies returns the current latency summaries.
+func (t *OrgLatencyTracker) Latencies() OrgLatencies {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	latencies := OrgLatencies{Orgs: make([]OrgLatencySummary, 0, len(t.tracked)), Other: t.other.summary(0)}
+	for orgID, s := range t.tracked {
+		latencies.Orgs = append(latencies.Orgs, s.summary(orgID))
+	}
+	sort.Slice(latencies.Orgs, func(i, j int) bool {
+		if latencies.Orgs[i].Requests != latencies.Orgs[j].Requests {
+			return latencies.Orgs[i].Requests > latencies.Orgs[j].Requests
+		}
+		return latencies.Orgs[i].OrgID < latencies.Orgs[j].OrgID
+	})
+	return latencies
+}
+
+func (t *OrgLatencyTracker) add(orgID int64, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.count(orgID)
+
+	if s, ok := t.tracked[orgID]; ok {
+		s.add(duration)
+		return
+	}
+	if len(t.tracked) < t.size {
+		t.track(orgID).add(duration)
+		return
+	}
+
+	// Take the place of the least requested tracked org if this org is now requested more
+	var leastID int64
+	least := int64(-1)
+	for id := range t.tracked {
+		if c := t.counts[id]; least < 0 || c < least || (c == least && id > leastID) {
+			leastID, least = id, c
+		}
+	}
+	if t.counts[orgID] > least {
+		t.other.merge(t.tracked[leastID])
+		delete(t.tracked, leastID)
+		t.track(orgID).add(duration)
+		return
+	}
+	t.other.add(duration)
+}
+
+func (t *OrgLatencyTracker) track(orgID int64) *latencySummary {
+	s := newLatencySummary()
+	t.tracked[orgID] = s
+	return s
+}
+
+// count increments the request volume of an org. If there are too many candidates, the least requested one that
+// isn't tracked is replaced, and its count is inherited, as in the Space-Saving algorithm.
+func (t *OrgLatencyTracker) count(orgID int64) {
+	if _, ok := t.counts[orgID]; ok || len(t.counts) < t.size*orgLatencyCandidatesFactor {
+		t.counts[orgID]++
+		return
+	}
+	var leastID int64
+	least := int64(-1)
+	for id, c := range t.counts {
+		if _, ok := t.tracked[id]; ok {
+			continue
+		}
+		if least < 0 || c < least {
+			leastID, least = id, c
+		}
+	}
+	delete(t.counts, leastID)
+	t.counts[orgID] = least + 1
+}
+
+// latencySummary counts requests and keeps their latest durations in a fixed size ring buffer.
+type latencySummary struct {
+	requests int64
+	buf      []float64
+	next     int
+}
+
+func newLatencySummary() *latencySummary {
+	return &latencySummary{buf: make([]float64, 0, orgLatencySamples)}
+}
+
+func (s *latencySummary) add(duration time.Duration) {
+	s.requests++
+	s.addSample(duration.Seconds())
+}
+
+func (s *latencySummary) addSample(seconds float64) {
+	if len(s.buf) < cap(s.buf) {
+		s.buf = append(s.buf, seconds)
+		return
+	}
+	s.buf[s.next] = seconds
+	s.next = (s.next + 1) % len(s.buf)
+}
+
+func (s *latencySummary) merge(other *latencySummary) {
+	s.requests += other.requests
+	for _, seconds := range other.buf {
+		s.addSample(seconds)
+	}
+}
+
+func (s *latencySummary) summary(orgID int64) OrgLatencySummary {
+	summary := OrgLatencySummary{OrgID: orgID, Requests: s.requests}
+	if len(s.buf) == 0 {
+		return summary
+	}
+	sorted := append([]float64(nil), s.buf...)
+	sort.Float64s(sorted)
+	quantile := func(q float64) float64 {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	summary.P50, summary.P90, summary.P99 = quantile(0.5), quantile(0.9), quantile(0.99)
+	return summary
+}
+
+// NewOrgLatencyMiddleware returns a new plugins.ClientMiddleware that observes the duration of the QueryData,
+// CallResource and CheckHealth requests in the given OrgLatencyTracker, by org.
+func NewOrgLatencyMiddleware(tracker *OrgLatencyTracker) plugins.ClientMiddleware {
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &OrgLatencyMiddleware{
+			next:    next,
+			tracker: tracker,
+		}
+	})
+}
+
+type OrgLatencyMiddleware struct {
+	next    plugins.Client
+	tracker *OrgLatencyTracker
+}
+
+func (m *OrgLatencyMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	if req == nil {
+		return m.next.QueryData(ctx, req)
+	}
+	start := time.Now()
+	resp, err := m.next.QueryData(ctx, req)
+	m.tracker.Observe(req.PluginContext.OrgID, time.Since(start))
+	return resp, err
+}
+
+func (m *OrgLatencyMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req == nil {
+		return m.next.CallResource(ctx, req, sender)
+	}
+	start := time.Now()
+	err := m.next.CallResource(ctx, req, sender)
+	m.tracker.Observe(req.PluginContext.OrgID, time.Since(start))
+	return err
+}
+
+func (m *OrgLatencyMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	if req == nil {
+		return m.next.CheckHealth(ctx, req)
+	}
+	start := time.Now()
+	result, err := m.next.CheckHealth(ctx, req)
+	m.tracker.Observe(req.PluginContext.OrgID, time.Since(start))
+	return result, err
+}
+
+func (m *OrgLatencyMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *OrgLatencyMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *OrgLatencyMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *OrgLatencyMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,101 @@
+package clientmiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+)
+
+func TestOrgLatencyTracker(t *testing.T) {
+	orgIDs := func(latencies OrgLatencies) []int64 {
+		ids := []int64{}
+		for _, o := range latencies.Orgs {
+			ids = append(ids, o.OrgID)
+		}
+		return ids
+	}
+
+	t.Run("Should track the most requested orgs and fold the other ones", func(t *testing.T) {
+		tracker := NewOrgLatencyTracker(2)
+		// Interleave the requests, so that the order of the first requests doesn't matter
+		for i := 0; i < 10; i++ {
+			tracker.add(3, 3*time.Second)
+			tracker.add(1, time.Second)
+			if i < 5 {
+				tracker.add(2, 2*time.Second)
+				tracker.add(4, 4*time.Second)
+			}
+			if i < 8 {
+				tracker.add(1, time.Second)
+			}
+		}
+
+		latencies := tracker.Latencies()
+		require.Equal(t, []int64{1, 3}, orgIDs(latencies))
+		require.Equal(t, OrgLatencySummary{OrgID: 1, Requests: 18, P50: 1, P90: 1, P99: 1}, latencies.Orgs[0])
+		require.Equal(t, OrgLatencySummary{OrgID: 3, Requests: 10, P50: 3, P90: 3, P99: 3}, latencies.Orgs[1])
+		require.Equal(t, int64(10), latencies.Other.Requests)
+		require.Equal(t, int64(0), latencies.Other.OrgID)
+		require.Equal(t, 2.0, latencies.Other.P50)
+		require.Equal(t, 4.0, latencies.Other.P99)
+	})
+
+	t.Run("Should replace a tracked org by one that becomes more requested", func(t *testing.T) {
+		tracker := NewOrgLatencyTracker(1)
+		for i := 0; i < 3; i++ {
+			tracker.add(1, time.Second)
+		}
+		for i := 0; i < 4; i++ {
+			tracker.add(2, 2*time.Second)
+		}
+
+		latencies := tracker.Latencies()
+		require.Equal(t, []int64{2}, orgIDs(latencies))
+		// Org 2 is tracked on its own from the request that made it the most requested one
+		require.Equal(t, int64(1), latencies.Orgs[0].Requests)
+		require.Equal(t, int64(6), latencies.Other.Requests)
+	})
+
+	t.Run("Should bound the number of counted orgs", func(t *testing.T) {
+		tracker := NewOrgLatencyTracker(1)
+		for i := 0; i < 50; i++ {
+			tracker.add(1, time.Second)
+		}
+		for orgID := int64(2); orgID < 100; orgID++ {
+			tracker.add(orgID, time.Second)
+		}
+
+		require.Len(t, tracker.counts, orgLatencyCandidatesFactor)
+		require.Equal(t, []int64{1}, orgIDs(tracker.Latencies()))
+	})
+}
+
+func TestOrgLatencyMiddleware(t *testing.T) {
+	t.Run("Should observe the requests of each org", func(t *testing.T) {
+		tracker := NewOrgLatencyTracker(2)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewOrgLatencyMiddleware(tracker)))
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: backend.PluginContext{OrgID: 1}})
+		require.NoError(t, err)
+		_, err = cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{OrgID: 2}})
+		require.NoError(t, err)
+		err = cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: backend.PluginContext{OrgID: 1}}, nopCallResourceSender)
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			latencies := tracker.Latencies()
+			return len(latencies.Orgs) == 2 && latencies.Orgs[0].OrgID == 1 && latencies.Orgs[0].Requests == 2 && latencies.Orgs[1].Requests == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Should not track anything if the size is zero", func(t *testing.T) {
+		tracker := NewOrgLatencyTracker(0)
+		tracker.Observe(1, time.Second)
+		require.Equal(t, OrgLatencies{Orgs: []OrgLatencySummary{}}, tracker.Latencies())
+	})
+}
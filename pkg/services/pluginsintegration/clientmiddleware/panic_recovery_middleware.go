@@ -0,0 +1,129 @@
+package clientmiddleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	plog "github.com/grafana/grafana/pkg/plugins/log"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+var errPluginRequestPanic = errutil.Internal("plugin.requestPanic",
+	errutil.WithPublicMessage("An unexpected error happened while handling the plugin request"))
+
+// NewPanicRecoveryMiddleware returns a new plugins.ClientMiddleware that recovers the panics of the next middlewares
+// and of the plugin client, so that a panic fails the request with a plugin error instead of crashing the
+// goroutine serving it. The panics are logged with their stack trace and counted.
+func NewPanicRecoveryMiddleware(logger plog.Logger, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	panics := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_request_panic_total",
+		Help:      "The total amount of plugin requests that panicked",
+	}, []string{"plugin_id", "endpoint"})
+	promRegisterer.MustRegister(panics)
+
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &PanicRecoveryMiddleware{
+			next:   next,
+			logger: logger,
+			panics: panics,
+		}
+	})
+}
+
+type PanicRecoveryMiddleware struct {
+	next   plugins.Client
+	logger plog.Logger
+	panics *prometheus.CounterVec
+}
+
+// recoverRequest calls fn, and returns the panic it raises, if any, as a plugin error.
+func (m *PanicRecoveryMiddleware) recoverRequest(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, fn func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		m.panics.WithLabelValues(pluginCtx.PluginID, endpoint).Inc()
+		m.logger.FromContext(ctx).Error("Plugin request panicked", "pluginId", pluginCtx.PluginID, "endpoint", endpoint,
+			"panic", r, "stack", string(debug.Stack()))
+
+		panicErr, ok := r.(error)
+		if !ok {
+			panicErr = fmt.Errorf("%v", r)
+		}
+		markStatusSource(ctx, pluginrequestmeta.StatusSourcePlugin)
+		err = errorsource.PluginError(errPluginRequestPanic.Errorf("%s request to plugin %s panicked: %w", endpoint, pluginCtx.PluginID, panicErr), true)
+	}()
+	return fn()
+}
+
+func (m *PanicRecoveryMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	var resp *backend.QueryDataResponse
+	err := m.recoverRequest(ctx, req.PluginContext, endpointQueryData, func() error {
+		var err error
+		resp, err = m.next.QueryData(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *PanicRecoveryMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.recoverRequest(ctx, req.PluginContext, endpointCallResource, func() error {
+		return m.next.CallResource(ctx, req, sender)
+	})
+}
+
+func (m *PanicRecoveryMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	var resp *backend.CheckHealthResult
+	err := m.recoverRequest(ctx, req.PluginContext, endpointCheckHealth, func() error {
+		var err error
+		resp, err = m.next.CheckHealth(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *PanicRecoveryMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	var resp *backend.CollectMetricsResult
+	err := m.recoverRequest(ctx, req.PluginContext, endpointCollectMetrics, func() error {
+		var err error
+		resp, err = m.next.CollectMetrics(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *PanicRecoveryMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	var resp *backend.SubscribeStreamResponse
+	err := m.recoverRequest(ctx, req.PluginContext, endpointSubscribeStream, func() error {
+		var err error
+		resp, err = m.next.SubscribeStream(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *PanicRecoveryMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	var resp *backend.PublishStreamResponse
+	err := m.recoverRequest(ctx, req.PluginContext, endpointPublishStream, func() error {
+		var err error
+		resp, err = m.next.PublishStream(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *PanicRecoveryMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.recoverRequest(ctx, req.PluginContext, endpointRunStream, func() error {
+		return m.next.RunStream(ctx, req, sender)
+	})
+}
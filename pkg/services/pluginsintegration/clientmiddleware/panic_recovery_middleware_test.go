@@ -0,0 +1,167 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	plog "github.com/grafana/grafana/pkg/plugins/log"
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestPanicRecoveryMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	setup := func(t *testing.T) (*clienttest.ClientDecoratorTest, *capturingLogger, *prometheus.Registry) {
+		logger := &capturingLogger{}
+		registry := prometheus.NewRegistry()
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPanicRecoveryMiddleware(logger, registry)))
+		return cdt, logger, registry
+	}
+
+	t.Run("Should convert a panic into a plugin error", func(t *testing.T) {
+		cdt, logger, registry := setup(t)
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			// The status source of a request that panics is the plugin, whatever was set before
+			require.NoError(t, pluginrequestmeta.SetStatusSource(ctx, pluginrequestmeta.StatusSourceDownstream))
+			panic("boom")
+		}
+
+		ctx := pluginrequestmeta.WithStatusSource(context.Background(), pluginrequestmeta.StatusSourcePlugin)
+		var err error
+		require.NotPanics(t, func() {
+			_, err = cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: pCtx})
+		})
+		require.ErrorIs(t, err, errPluginRequestPanic)
+		require.ErrorContains(t, err, "boom")
+		require.Equal(t, pluginrequestmeta.StatusSourcePlugin, errorStatusSource(err))
+		require.Equal(t, pluginrequestmeta.StatusSourcePlugin, pluginrequestmeta.StatusSourceFromContext(ctx))
+
+		require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP grafana_plugin_request_panic_total The total amount of plugin requests that panicked
+# TYPE grafana_plugin_request_panic_total counter
+grafana_plugin_request_panic_total{endpoint="queryData",plugin_id="plugin-id"} 1
+`), "grafana_plugin_request_panic_total"))
+
+		entries := logger.entries("error")
+		require.Len(t, entries, 1)
+		require.Equal(t, "Plugin request panicked", entries[0].msg)
+		require.Equal(t, pluginID, entries[0].value("pluginId"))
+		require.Equal(t, endpointQueryData, entries[0].value("endpoint"))
+		require.Equal(t, "boom", entries[0].value("panic"))
+		require.Contains(t, entries[0].value("stack"), "runtime/debug.Stack")
+	})
+
+	t.Run("Should wrap an error panic value", func(t *testing.T) {
+		cdt, _, _ := setup(t)
+		errPanic := errors.New("nil map")
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			panic(errPanic)
+		}
+
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.ErrorIs(t, err, errPluginRequestPanic)
+		require.ErrorIs(t, err, errPanic)
+	})
+
+	t.Run("Should recover the panics of every endpoint", func(t *testing.T) {
+		cdt, _, registry := setup(t)
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			panic("boom")
+		}
+		cdt.TestClient.CollectMetricsFunc = func(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+			panic("boom")
+		}
+		cdt.TestClient.SubscribeStreamFunc = func(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+			panic("boom")
+		}
+		cdt.TestClient.PublishStreamFunc = func(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+			panic("boom")
+		}
+		cdt.TestClient.RunStreamFunc = func(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+			panic("boom")
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errPluginRequestPanic)
+		_, err = cdt.Decorator.CollectMetrics(context.Background(), &backend.CollectMetricsRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errPluginRequestPanic)
+		_, err = cdt.Decorator.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errPluginRequestPanic)
+		_, err = cdt.Decorator.PublishStream(context.Background(), &backend.PublishStreamRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errPluginRequestPanic)
+		err = cdt.Decorator.RunStream(context.Background(), &backend.RunStreamRequest{PluginContext: pCtx}, &backend.StreamSender{})
+		require.ErrorIs(t, err, errPluginRequestPanic)
+
+		require.Equal(t, 5, testutil.CollectAndCount(registry, "grafana_plugin_request_panic_total"))
+	})
+
+	t.Run("Should not change the requests that don't panic", func(t *testing.T) {
+		cdt, logger, registry := setup(t)
+		errPlugin := errors.New("plugin failed")
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, errPlugin
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.Equal(t, errPlugin, err)
+		require.Empty(t, logger.entries("error"))
+		require.Equal(t, 0, testutil.CollectAndCount(registry, "grafana_plugin_request_panic_total"))
+	})
+}
+
+// capturingLogger is a plog.Logger keeping all its log entries, including the ones of its contextual loggers.
+type capturingLogger struct {
+	mu   sync.Mutex
+	logs []capturedLog
+}
+
+type capturedLog struct {
+	level string
+	msg   string
+	ctx   []any
+}
+
+// value returns the value of the given key in the log entry, or nil if there's none.
+func (l capturedLog) value(key string) any {
+	for i := 0; i+1 < len(l.ctx); i += 2 {
+		if l.ctx[i] == key {
+			return l.ctx[i+1]
+		}
+	}
+	return nil
+}
+
+func (l *capturingLogger) log(level, msg string, ctx []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, capturedLog{level: level, msg: msg, ctx: ctx})
+}
+
+// entries returns the log entries with the given level.
+func (l *capturingLogger) entries(level string) []capturedLog {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var entries []capturedLog
+	for _, e := range l.logs {
+		if e.level == level {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (l *capturingLogger) New(_ ...any) plog.Logger                  { return l }
+func (l *capturingLogger) FromContext(_ context.Context) plog.Logger { return l }
+func (l *capturingLogger) Debug(msg string, ctx ...any)              { l.log("debug", msg, ctx) }
+func (l *capturingLogger) Info(msg string, ctx ...any)               { l.log("info", msg, ctx) }
+func (l *capturingLogger) Warn(msg string, ctx ...any)               { l.log("warn", msg, ctx) }
+func (l *capturingLogger) Error(msg string, ctx ...any)              { l.log("error", msg, ctx) }
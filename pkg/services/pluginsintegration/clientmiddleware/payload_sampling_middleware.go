@@ -0,0 +1,307 @@
+package clientmiddleware
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/exp/slices"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+const redactedValue = "[REDACTED]"
+
+// defaultRedactedKeys are always redacted from the sampled payloads, in addition to the configured ones.
+var defaultRedactedKeys = []string{"authorization", "cookie", "set-cookie", "x-id-token", "x-grafana-id"}
+
+// forwardedHTTPHeaderPrefix is the prefix of the keys of the HTTP headers set on the plugin requests
+// with SetHTTPHeader, e.g. http_Authorization.
+const forwardedHTTPHeaderPrefix = "http_"
+
+// redactionKey returns the form of key matched against the redacted keys: lowercase and without the prefix
+// of the forwarded HTTP headers, so that http_Authorization matches authorization.
+func redactionKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return strings.TrimPrefix(key, forwardedHTTPHeaderPrefix)
+}
+
+// isRedactedByDefault reports whether the value of the given key is always redacted.
+func isRedactedByDefault(key string) bool {
+	return slices.Contains(defaultRedactedKeys, redactionKey(key))
+}
+
+// PayloadSample is a plugin request/response pair captured by the PayloadSamplingMiddleware.
+// Request and Response are JSON encoded and redacted, and they're truncated if they exceed the configured size.
+type PayloadSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	PluginID  string    `json:"pluginId"`
+	Endpoint  string    `json:"endpoint"`
+	Request   string    `json:"request"`
+	Response  string    `json:"response,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// PayloadSampler stores the last plugin request/response pairs, per plugin and endpoint, in fixed size ring buffers.
+type PayloadSampler struct {
+	size         int
+	maxBytes     int
+	redactedKeys map[string]struct{}
+
+	mu    sync.Mutex
+	rings map[string]*payloadRing
+}
+
+// NewPayloadSampler returns a new PayloadSampler keeping the last size samples for each plugin and endpoint.
+// Payloads bigger than maxBytes are truncated, and the values of the JSON object keys and headers matching
+// redactedKeys (case-insensitive, with or without the http_ prefix of the forwarded headers) are replaced.
+func NewPayloadSampler(size int, maxBytes int, redactedKeys []string) *PayloadSampler {
+	keys := make(map[string]struct{}, len(defaultRedactedKeys)+len(redactedKeys))
+	for _, k := range append(defaultRedactedKeys, redactedKeys...) {
+		keys[redactionKey(k)] = struct{}{}
+	}
+	return &PayloadSampler{
+		size:         size,
+		maxBytes:     maxBytes,
+		redactedKeys: keys,
+		rings:        map[string]*payloadRing{},
+	}
+}
+
+// Samples returns the captured samples for the given plugin and endpoint, from the oldest to the newest one.
+// If endpoint is empty, the samples for all the endpoints are returned.
+func (s *PayloadSampler) Samples(pluginID string, endpoint string) []PayloadSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := []PayloadSample{}
+	for _, e := range []string{endpointQueryData, endpointCallResource, endpointCheckHealth} {
+		if endpoint != "" && endpoint != e {
+			continue
+		}
+		if ring, ok := s.rings[payloadRingKey(pluginID, e)]; ok {
+			samples = append(samples, ring.samples()...)
+		}
+	}
+	return samples
+}
+
+func (s *PayloadSampler) add(pluginID string, endpoint string, req any, resp any, err error) {
+	if s.size <= 0 {
+		return
+	}
+
+	sample := PayloadSample{
+		Timestamp: time.Now(),
+		PluginID:  pluginID,
+		Endpoint:  endpoint,
+	}
+	var truncated bool
+	sample.Request, truncated = s.encode(req)
+	sample.Truncated = sample.Truncated || truncated
+	if resp != nil {
+		sample.Response, truncated = s.encode(resp)
+		sample.Truncated = sample.Truncated || truncated
+	}
+	if err != nil {
+		sample.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := payloadRingKey(pluginID, endpoint)
+	ring, ok := s.rings[key]
+	if !ok {
+		ring = &payloadRing{buf: make([]PayloadSample, 0, s.size)}
+		s.rings[key] = ring
+	}
+	ring.add(sample)
+}
+
+// encode returns the redacted JSON encoding of v, truncated to s.maxBytes.
+func (s *PayloadSampler) encode(v any) (string, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	var generic any
+	if err := json.Unmarshal(b, &generic); err == nil {
+		if redacted, err := json.Marshal(s.redact(generic)); err == nil {
+			b = redacted
+		}
+	}
+	if s.maxBytes > 0 && len(b) > s.maxBytes {
+		return string(b[:s.maxBytes]), true
+	}
+	return string(b), false
+}
+
+func (s *PayloadSampler) redact(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if _, ok := s.redactedKeys[redactionKey(k)]; ok {
+				t[k] = redactedValue
+				continue
+			}
+			t[k] = s.redact(val)
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = s.redact(val)
+		}
+	}
+	return v
+}
+
+func payloadRingKey(pluginID string, endpoint string) string {
+	return pluginID + "/" + endpoint
+}
+
+// payloadRing is a fixed size ring buffer of samples, evicting the oldest sample when full.
+type payloadRing struct {
+	buf  []PayloadSample
+	next int
+}
+
+func (r *payloadRing) add(sample PayloadSample) {
+	if len(r.buf) < cap(r.buf) {
+		r.buf = append(r.buf, sample)
+		return
+	}
+	r.buf[r.next] = sample
+	r.next = (r.next + 1) % len(r.buf)
+}
+
+func (r *payloadRing) samples() []PayloadSample {
+	samples := make([]PayloadSample, 0, len(r.buf))
+	samples = append(samples, r.buf[r.next:]...)
+	return append(samples, r.buf[:r.next]...)
+}
+
+// NewPayloadSamplingMiddleware returns a new plugins.ClientMiddleware that captures the payloads of the
+// QueryData, CallResource and CheckHealth requests and responses in the given PayloadSampler, for debugging.
+func NewPayloadSamplingMiddleware(sampler *PayloadSampler) plugins.ClientMiddleware {
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &PayloadSamplingMiddleware{
+			next:    next,
+			sampler: sampler,
+		}
+	})
+}
+
+type PayloadSamplingMiddleware struct {
+	next    plugins.Client
+	sampler *PayloadSampler
+}
+
+type sampledQueryDataRequest struct {
+	Headers map[string]string   `json:"headers,omitempty"`
+	Queries []backend.DataQuery `json:"queries"`
+}
+
+type sampledCallResourceRequest struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    json.RawMessage     `json:"body,omitempty"`
+}
+
+type sampledCallResourceResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    json.RawMessage     `json:"body,omitempty"`
+}
+
+// rawBody returns body as a json.RawMessage, so it can be redacted, or as a JSON string if it's not valid JSON.
+func rawBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	b, _ := json.Marshal(string(body))
+	return b
+}
+
+func (m *PayloadSamplingMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	resp, err := m.next.QueryData(ctx, req)
+	if req != nil {
+		var sampledResp any
+		if resp != nil {
+			sampledResp = resp
+		}
+		m.sampler.add(req.PluginContext.PluginID, endpointQueryData, sampledQueryDataRequest{
+			Headers: req.Headers,
+			Queries: req.Queries,
+		}, sampledResp, err)
+	}
+	return resp, err
+}
+
+func (m *PayloadSamplingMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req == nil {
+		return m.next.CallResource(ctx, req, sender)
+	}
+
+	var sampledResp *sampledCallResourceResponse
+	var body []byte
+	err := m.next.CallResource(ctx, req, callResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		if res != nil {
+			if sampledResp == nil {
+				sampledResp = &sampledCallResourceResponse{Status: res.Status, Headers: res.Headers}
+			}
+			// Stop buffering streamed bodies once they're bigger than what's kept
+			if m.sampler.maxBytes <= 0 || len(body) <= m.sampler.maxBytes {
+				body = append(body, res.Body...)
+			}
+		}
+		return sender.Send(res)
+	}))
+
+	var resp any
+	if sampledResp != nil {
+		sampledResp.Body = rawBody(body)
+		resp = sampledResp
+	}
+	m.sampler.add(req.PluginContext.PluginID, endpointCallResource, sampledCallResourceRequest{
+		Method:  req.Method,
+		URL:     req.URL,
+		Headers: req.Headers,
+		Body:    rawBody(req.Body),
+	}, resp, err)
+	return err
+}
+
+func (m *PayloadSamplingMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	result, err := m.next.CheckHealth(ctx, req)
+	if req != nil {
+		var resp any
+		if result != nil {
+			resp = result
+		}
+		m.sampler.add(req.PluginContext.PluginID, endpointCheckHealth, req.Headers, resp, err)
+	}
+	return result, err
+}
+
+func (m *PayloadSamplingMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *PayloadSamplingMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *PayloadSamplingMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *PayloadSamplingMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
@@ -0,0 +1,164 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+)
+
+func TestPayloadSamplingMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	t.Run("Should capture redacted query data payloads", func(t *testing.T) {
+		sampler := NewPayloadSampler(10, 0, []string{"apiKey"})
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPayloadSamplingMiddleware(sampler)))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return backend.NewQueryDataResponse(), nil
+		}
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: pCtx,
+			Headers:       map[string]string{"Authorization": "Bearer secret", "X-Custom": "value"},
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(`{"expr":"up","apiKey":"secret"}`)}},
+		})
+		require.NoError(t, err)
+
+		samples := sampler.Samples(pluginID, endpointQueryData)
+		require.Len(t, samples, 1)
+		require.Equal(t, pluginID, samples[0].PluginID)
+		require.Equal(t, endpointQueryData, samples[0].Endpoint)
+		require.NotContains(t, samples[0].Request, "secret")
+		require.Contains(t, samples[0].Request, `"Authorization":"[REDACTED]"`)
+		require.Contains(t, samples[0].Request, `"apiKey":"[REDACTED]"`)
+		require.Contains(t, samples[0].Request, `"expr":"up"`)
+		require.Contains(t, samples[0].Request, `"X-Custom":"value"`)
+		require.NotEmpty(t, samples[0].Response)
+		require.False(t, samples[0].Truncated)
+	})
+
+	t.Run("Should redact the forwarded HTTP headers", func(t *testing.T) {
+		sampler := NewPayloadSampler(10, 0, []string{"X-Api-Key"})
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPayloadSamplingMiddleware(sampler)))
+
+		qdr := &backend.QueryDataRequest{PluginContext: pCtx}
+		qdr.SetHTTPHeader("Authorization", "Bearer secret")
+		qdr.SetHTTPHeader(forwardIDHeaderName, "id-token-secret")
+		qdr.SetHTTPHeader("X-Api-Key", "key-secret")
+		qdr.SetHTTPHeader("X-Custom", "value")
+		_, err := cdt.Decorator.QueryData(context.Background(), qdr)
+		require.NoError(t, err)
+
+		crr := &backend.CallResourceRequest{PluginContext: pCtx}
+		crr.SetHTTPHeader("Authorization", "Bearer secret")
+		crr.SetHTTPHeader(forwardIDHeaderName, "id-token-secret")
+		err = cdt.Decorator.CallResource(context.Background(), crr, nopCallResourceSender)
+		require.NoError(t, err)
+
+		chr := &backend.CheckHealthRequest{PluginContext: pCtx, Headers: map[string]string{}}
+		chr.SetHTTPHeader(forwardIDHeaderName, "id-token-secret")
+		_, err = cdt.Decorator.CheckHealth(context.Background(), chr)
+		require.NoError(t, err)
+
+		samples := sampler.Samples(pluginID, "")
+		require.Len(t, samples, 3)
+		for _, sample := range samples {
+			require.NotContains(t, sample.Request, "secret", sample.Endpoint)
+		}
+		require.Contains(t, samples[0].Request, `"http_Authorization":"[REDACTED]"`)
+		require.Contains(t, samples[0].Request, `"http_X-Grafana-Id":"[REDACTED]"`)
+		require.Contains(t, samples[0].Request, `"http_X-Api-Key":"[REDACTED]"`)
+		require.Contains(t, samples[0].Request, `"http_X-Custom":"value"`)
+	})
+
+	t.Run("Should capture call resource payloads and errors", func(t *testing.T) {
+		sampler := NewPayloadSampler(10, 0, nil)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPayloadSamplingMiddleware(sampler)))
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			return sender.Send(&backend.CallResourceResponse{
+				Status: 200,
+				Headers: map[string][]string{
+					"Set-Cookie": {"session=secret"},
+				},
+				Body: []byte(`{"result":"ok"}`),
+			})
+		}
+
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{
+			PluginContext: pCtx,
+			Method:        "POST",
+			URL:           "/resource",
+			Headers:       map[string][]string{"Cookie": {"session=secret"}},
+			Body:          []byte(`not json`),
+		}, nopCallResourceSender)
+		require.NoError(t, err)
+
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, errors.New("boom")
+		}
+		_, err = cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.Error(t, err)
+
+		samples := sampler.Samples(pluginID, endpointCallResource)
+		require.Len(t, samples, 1)
+		require.Contains(t, samples[0].Request, `"Cookie":"[REDACTED]"`)
+		require.Contains(t, samples[0].Request, `"body":"not json"`)
+		require.Contains(t, samples[0].Response, `"status":200`)
+		require.Contains(t, samples[0].Response, `"result":"ok"`)
+
+		samples = sampler.Samples(pluginID, endpointCheckHealth)
+		require.Len(t, samples, 1)
+		require.Equal(t, "boom", samples[0].Error)
+		require.Empty(t, samples[0].Response)
+
+		require.Len(t, sampler.Samples(pluginID, ""), 2)
+		require.Empty(t, sampler.Samples("other-plugin", ""))
+	})
+
+	t.Run("Should truncate payloads bigger than the max size", func(t *testing.T) {
+		sampler := NewPayloadSampler(10, 16, nil)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPayloadSamplingMiddleware(sampler)))
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: pCtx,
+			Queries:       []backend.DataQuery{{RefID: "A", JSON: []byte(`{"expr":"a very long query expression"}`)}},
+		})
+		require.NoError(t, err)
+
+		samples := sampler.Samples(pluginID, endpointQueryData)
+		require.Len(t, samples, 1)
+		require.Len(t, samples[0].Request, 16)
+		require.True(t, samples[0].Truncated)
+	})
+
+	t.Run("Should evict the oldest samples", func(t *testing.T) {
+		sampler := NewPayloadSampler(2, 0, nil)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPayloadSamplingMiddleware(sampler)))
+
+		for _, refID := range []string{"A", "B", "C"} {
+			_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+				PluginContext: pCtx,
+				Queries:       []backend.DataQuery{{RefID: refID}},
+			})
+			require.NoError(t, err)
+		}
+
+		samples := sampler.Samples(pluginID, endpointQueryData)
+		require.Len(t, samples, 2)
+		require.Contains(t, samples[0].Request, `"RefID":"B"`)
+		require.Contains(t, samples[1].Request, `"RefID":"C"`)
+	})
+
+	t.Run("Should not capture anything if the size is zero", func(t *testing.T) {
+		sampler := NewPayloadSampler(0, 0, nil)
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewPayloadSamplingMiddleware(sampler)))
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Empty(t, sampler.Samples(pluginID, ""))
+	})
+}
@@ -30,6 +30,9 @@ func (m *PluginRequestMetaMiddleware) withDefaultPluginRequestMeta(ctx context.C
 	// Setup plugin request status source
 	ctx = pluginrequestmeta.WithStatusSource(ctx, m.defaultStatusSource)
 
+	// Setup plugin request queue position, set by the concurrency limit middleware if the request waits for it
+	ctx = pluginrequestmeta.WithQueuePosition(ctx, 0)
+
 	return ctx
 }
 
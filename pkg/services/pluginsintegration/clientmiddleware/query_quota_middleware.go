@@ -0,0 +1,192 @@
+package clientmiddleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/services/contexthandler"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+const (
+	// QueryQuotaTargetSrv is the quota service the daily plugin query quotas are registered for.
+	QueryQuotaTargetSrv quota.TargetSrv = "plugin"
+	// QueryQuotaTarget is the quota target of the daily plugin query quotas.
+	QueryQuotaTarget quota.Target = "plugin_query"
+)
+
+var errQueryQuotaExceeded = errutil.TooManyRequests("plugin.queryQuotaExceeded",
+	errutil.WithPublicMessage("Daily query quota exceeded"))
+
+type queryQuotaKey struct {
+	orgID  int64
+	userID int64
+}
+
+// QueryQuotaTracker counts the QueryData requests of each user in each org since the last daily reset, and reports
+// them to the quota service as the usage of the QueryQuotaTarget in the user scope.
+type QueryQuotaTracker struct {
+	resetHour int
+	now       func() time.Time
+
+	mu      sync.Mutex
+	resetAt time.Time
+	usage   map[queryQuotaKey]int64
+}
+
+// NewQueryQuotaTracker returns a new QueryQuotaTracker whose counts are reset every day at the given hour, in UTC.
+func NewQueryQuotaTracker(resetHour int) *QueryQuotaTracker {
+	return &QueryQuotaTracker{
+		resetHour: resetHour,
+		now:       time.Now,
+		usage:     map[queryQuotaKey]int64{},
+	}
+}
+
+// QueryQuotaLimits returns the default limits of the daily plugin query quotas.
+func QueryQuotaLimits(cfg *setting.Cfg) (*quota.Map, error) {
+	limits := &quota.Map{}
+	if cfg == nil {
+		return limits, nil
+	}
+
+	userTag, err := quota.NewTag(QueryQuotaTargetSrv, QueryQuotaTarget, quota.UserScope)
+	if err != nil {
+		return limits, err
+	}
+	limits.Set(userTag, cfg.Quota.User.PluginQuery)
+	return limits, nil
+}
+
+// Usage is the quota.UsageReporterFunc of the daily plugin query quotas. The usage of a user is the one in the
+// org of the scope parameters, or the sum over all the orgs if there's none.
+func (t *QueryQuotaTracker) Usage(_ context.Context, scopeParams *quota.ScopeParameters) (*quota.Map, error) {
+	u := &quota.Map{}
+	if scopeParams == nil || scopeParams.UserID == 0 {
+		return u, nil
+	}
+
+	userTag, err := quota.NewTag(QueryQuotaTargetSrv, QueryQuotaTarget, quota.UserScope)
+	if err != nil {
+		return u, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resetIfDue()
+
+	var used int64
+	for k, count := range t.usage {
+		if k.userID == scopeParams.UserID && (scopeParams.OrgID == 0 || k.orgID == scopeParams.OrgID) {
+			used += count
+		}
+	}
+	u.Set(userTag, used)
+	return u, nil
+}
+
+// add counts a QueryData request of the user in the org.
+func (t *QueryQuotaTracker) add(orgID, userID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resetIfDue()
+	t.usage[queryQuotaKey{orgID: orgID, userID: userID}]++
+}
+
+// resetIfDue clears the counts once the reset hour is passed. t.mu must be held.
+func (t *QueryQuotaTracker) resetIfDue() {
+	now := t.now().UTC()
+	if now.Before(t.resetAt) {
+		return
+	}
+	if len(t.usage) > 0 {
+		t.usage = map[queryQuotaKey]int64{}
+	}
+	t.resetAt = time.Date(now.Year(), now.Month(), now.Day(), t.resetHour, 0, 0, 0, time.UTC)
+	if !t.resetAt.After(now) {
+		t.resetAt = t.resetAt.AddDate(0, 0, 1)
+	}
+}
+
+// NewQueryQuotaMiddleware returns a new plugins.ClientMiddleware that rejects the QueryData requests of the users who
+// reached their daily plugin query quota, and counts the other ones in the QueryQuotaTracker.
+// The requests without a signed in user, such as the alerting ones, are neither checked nor counted.
+func NewQueryQuotaMiddleware(tracker *QueryQuotaTracker, quotaService quota.Service, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	exceeded := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_query_quota_exceeded_total",
+		Help:      "The total amount of plugin queries rejected because the user reached their daily query quota",
+	}, []string{"plugin_id"})
+	promRegisterer.MustRegister(exceeded)
+
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &QueryQuotaMiddleware{
+			next:         next,
+			tracker:      tracker,
+			quotaService: quotaService,
+			exceeded:     exceeded,
+		}
+	})
+}
+
+type QueryQuotaMiddleware struct {
+	next         plugins.Client
+	tracker      *QueryQuotaTracker
+	quotaService quota.Service
+	exceeded     *prometheus.CounterVec
+}
+
+func (m *QueryQuotaMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	if req == nil {
+		return m.next.QueryData(ctx, req)
+	}
+	reqCtx := contexthandler.FromContext(ctx)
+	if reqCtx == nil || reqCtx.SignedInUser == nil || reqCtx.SignedInUser.UserID == 0 {
+		return m.next.QueryData(ctx, req)
+	}
+
+	orgID, userID := reqCtx.SignedInUser.OrgID, reqCtx.SignedInUser.UserID
+	// Concurrent requests can go slightly over the quota, as they're checked before any of them is counted
+	reached, err := m.quotaService.CheckQuotaReached(ctx, QueryQuotaTargetSrv, &quota.ScopeParameters{OrgID: orgID, UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	if reached {
+		m.exceeded.WithLabelValues(req.PluginContext.PluginID).Inc()
+		return nil, errQueryQuotaExceeded.Errorf("user %d reached their daily query quota in org %d", userID, orgID)
+	}
+
+	m.tracker.add(orgID, userID)
+	return m.next.QueryData(ctx, req)
+}
+
+func (m *QueryQuotaMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.next.CallResource(ctx, req, sender)
+}
+
+func (m *QueryQuotaMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *QueryQuotaMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *QueryQuotaMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *QueryQuotaMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *QueryQuotaMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
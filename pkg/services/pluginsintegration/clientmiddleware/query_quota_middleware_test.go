@@ -0,0 +1,154 @@
+package clientmiddleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/services/contexthandler/ctxkey"
+	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
+	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/services/user"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// fakeLimitQuotaService checks the usage reported by the registered reporter against the default limits,
+// as the quota service does when there's no custom limit.
+type fakeLimitQuotaService struct {
+	*quotatest.FakeQuotaService
+	limits   *quota.Map
+	reporter quota.UsageReporterFunc
+}
+
+func (s *fakeLimitQuotaService) RegisterQuotaReporter(e *quota.NewUsageReporter) error {
+	s.limits, s.reporter = e.DefaultLimits, e.Reporter
+	return nil
+}
+
+func (s *fakeLimitQuotaService) CheckQuotaReached(ctx context.Context, _ quota.TargetSrv, scopeParams *quota.ScopeParameters) (bool, error) {
+	usage, err := s.reporter(ctx, scopeParams)
+	if err != nil {
+		return false, err
+	}
+	for item := range s.limits.Iter() {
+		if used, _ := usage.Get(item.Tag); item.Value >= 0 && used >= item.Value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestQueryQuotaMiddleware(t *testing.T) {
+	userCtx := func(orgID, userID int64) context.Context {
+		req, err := http.NewRequest(http.MethodPost, "/api/ds/query", nil)
+		require.NoError(t, err)
+		reqCtx := &contextmodel.ReqContext{
+			Context:      &web.Context{Req: req},
+			SignedInUser: &user.SignedInUser{OrgID: orgID, UserID: userID},
+		}
+		return ctxkey.Set(context.Background(), reqCtx)
+	}
+
+	setup := func(t *testing.T, limit int64, resetHour int, now *time.Time) (*clienttest.ClientDecoratorTest, *QueryQuotaTracker, *prometheus.Registry) {
+		cfg := setting.NewCfg()
+		cfg.Quota.User.PluginQuery = limit
+		tracker := NewQueryQuotaTracker(resetHour)
+		tracker.now = func() time.Time { return *now }
+
+		limits, err := QueryQuotaLimits(cfg)
+		require.NoError(t, err)
+		quotaService := &fakeLimitQuotaService{FakeQuotaService: quotatest.New(false, nil)}
+		require.NoError(t, quotaService.RegisterQuotaReporter(&quota.NewUsageReporter{
+			TargetSrv:     QueryQuotaTargetSrv,
+			DefaultLimits: limits,
+			Reporter:      tracker.Usage,
+		}))
+
+		registry := prometheus.NewRegistry()
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewQueryQuotaMiddleware(tracker, quotaService, registry)))
+		return cdt, tracker, registry
+	}
+
+	query := func(cdt *clienttest.ClientDecoratorTest, ctx context.Context) error {
+		_, err := cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}})
+		return err
+	}
+
+	t.Run("Should allow the queries under the daily quota and reject them at the quota", func(t *testing.T) {
+		now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+		cdt, _, registry := setup(t, 3, 0, &now)
+		calls := 0
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			calls++
+			return &backend.QueryDataResponse{}, nil
+		}
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, query(cdt, userCtx(1, 1)))
+		}
+		err := query(cdt, userCtx(1, 1))
+		require.ErrorIs(t, err, errQueryQuotaExceeded)
+		var gfErr errutil.Error
+		require.ErrorAs(t, err, &gfErr)
+		require.Equal(t, http.StatusTooManyRequests, gfErr.Reason.Status().HTTPStatus())
+		require.Equal(t, 3, calls)
+		require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP grafana_plugin_query_quota_exceeded_total The total amount of plugin queries rejected because the user reached their daily query quota
+# TYPE grafana_plugin_query_quota_exceeded_total counter
+grafana_plugin_query_quota_exceeded_total{plugin_id="prometheus"} 1
+`)))
+
+		// The usage is tracked per user and org
+		require.NoError(t, query(cdt, userCtx(1, 2)))
+		require.NoError(t, query(cdt, userCtx(2, 1)))
+	})
+
+	t.Run("Should reset the usage after the reset hour", func(t *testing.T) {
+		now := time.Date(2024, 1, 10, 5, 0, 0, 0, time.UTC)
+		cdt, tracker, _ := setup(t, 1, 6, &now)
+
+		require.NoError(t, query(cdt, userCtx(1, 1)))
+		require.ErrorIs(t, query(cdt, userCtx(1, 1)), errQueryQuotaExceeded)
+
+		now = now.Add(59 * time.Minute)
+		require.ErrorIs(t, query(cdt, userCtx(1, 1)), errQueryQuotaExceeded)
+
+		now = now.Add(time.Minute)
+		require.NoError(t, query(cdt, userCtx(1, 1)))
+		require.ErrorIs(t, query(cdt, userCtx(1, 1)), errQueryQuotaExceeded)
+
+		// The next reset is a day later
+		now = now.Add(23 * time.Hour)
+		require.ErrorIs(t, query(cdt, userCtx(1, 1)), errQueryQuotaExceeded)
+		now = now.Add(time.Hour)
+		require.NoError(t, query(cdt, userCtx(1, 1)))
+
+		usage, err := tracker.Usage(context.Background(), &quota.ScopeParameters{UserID: 1})
+		require.NoError(t, err)
+		userTag, err := quota.NewTag(QueryQuotaTargetSrv, QueryQuotaTarget, quota.UserScope)
+		require.NoError(t, err)
+		used, ok := usage.Get(userTag)
+		require.True(t, ok)
+		require.Equal(t, int64(1), used)
+	})
+
+	t.Run("Should not check nor count the queries without a signed in user", func(t *testing.T) {
+		now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+		cdt, tracker, _ := setup(t, 0, 0, &now)
+
+		require.NoError(t, query(cdt, context.Background()))
+		require.ErrorIs(t, query(cdt, userCtx(1, 1)), errQueryQuotaExceeded)
+		require.Empty(t, tracker.usage)
+	})
+}
@@ -0,0 +1,126 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	plog "github.com/grafana/grafana/pkg/plugins/log"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+// NewRequestLoggerMiddleware returns a new plugins.ClientMiddleware that logs every completed plugin request, at
+// debug level if it succeeded or at warn level if it failed. Unlike the LoggerMiddleware, which logs the data
+// egress at info level when enabled, it's always on and meant for debugging the plugins.
+// The status source is the one set in the plugin request meta by the StatusSourceMiddleware, and the queue
+// position, if the request waited for the ConcurrencyLimitMiddleware, is logged too.
+func NewRequestLoggerMiddleware(logger plog.Logger) plugins.ClientMiddleware {
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &RequestLoggerMiddleware{
+			next:   next,
+			logger: logger,
+		}
+	})
+}
+
+type RequestLoggerMiddleware struct {
+	next   plugins.Client
+	logger plog.Logger
+}
+
+func (m *RequestLoggerMiddleware) logRequest(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, fn func() error, extraParams ...any) error {
+	start := time.Now()
+	err := fn()
+
+	status := statusOK
+	if err != nil {
+		status = statusError
+		if errors.Is(err, context.Canceled) {
+			status = statusCancelled
+		}
+	}
+	logParams := []any{
+		"plugin_id", pluginCtx.PluginID,
+		"endpoint", endpoint,
+		"duration", time.Since(start),
+		"status", status,
+		"status_source", pluginrequestmeta.StatusSourceFromContext(ctx),
+	}
+	if position := pluginrequestmeta.QueuePositionFromContext(ctx); position > 0 {
+		logParams = append(logParams, "queue_position", position)
+	}
+	logParams = append(logParams, extraParams...)
+
+	logger := m.logger.FromContext(ctx)
+	if err != nil {
+		logger.Warn("Plugin request completed", append(logParams, "error", err)...)
+		return err
+	}
+	logger.Debug("Plugin request completed", logParams...)
+	return nil
+}
+
+func (m *RequestLoggerMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	var resp *backend.QueryDataResponse
+	err := m.logRequest(ctx, req.PluginContext, endpointQueryData, func() error {
+		var err error
+		resp, err = m.next.QueryData(ctx, req)
+		return err
+	}, "queries", len(req.Queries))
+	return resp, err
+}
+
+func (m *RequestLoggerMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.logRequest(ctx, req.PluginContext, endpointCallResource, func() error {
+		return m.next.CallResource(ctx, req, sender)
+	})
+}
+
+func (m *RequestLoggerMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	var resp *backend.CheckHealthResult
+	err := m.logRequest(ctx, req.PluginContext, endpointCheckHealth, func() error {
+		var err error
+		resp, err = m.next.CheckHealth(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *RequestLoggerMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	var resp *backend.CollectMetricsResult
+	err := m.logRequest(ctx, req.PluginContext, endpointCollectMetrics, func() error {
+		var err error
+		resp, err = m.next.CollectMetrics(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *RequestLoggerMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	var resp *backend.SubscribeStreamResponse
+	err := m.logRequest(ctx, req.PluginContext, endpointSubscribeStream, func() error {
+		var err error
+		resp, err = m.next.SubscribeStream(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *RequestLoggerMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	var resp *backend.PublishStreamResponse
+	err := m.logRequest(ctx, req.PluginContext, endpointPublishStream, func() error {
+		var err error
+		resp, err = m.next.PublishStream(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *RequestLoggerMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.logRequest(ctx, req.PluginContext, endpointRunStream, func() error {
+		return m.next.RunStream(ctx, req, sender)
+	})
+}
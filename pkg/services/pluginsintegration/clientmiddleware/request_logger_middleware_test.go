@@ -0,0 +1,145 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestRequestLoggerMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	setup := func(t *testing.T) (*clienttest.ClientDecoratorTest, *capturingLogger) {
+		logger := &capturingLogger{}
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewPluginRequestMetaMiddleware(),
+			NewRequestLoggerMiddleware(logger),
+			NewStatusSourceMiddleware(),
+		))
+		return cdt, logger
+	}
+
+	t.Run("Should log successful requests at debug level", func(t *testing.T) {
+		cdt, logger := setup(t)
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: pCtx,
+			Queries:       []backend.DataQuery{{RefID: "A"}, {RefID: "B"}},
+		})
+		require.NoError(t, err)
+
+		require.Empty(t, logger.entries("warn"))
+		entries := logger.entries("debug")
+		require.Len(t, entries, 1)
+		require.Equal(t, "Plugin request completed", entries[0].msg)
+		require.Equal(t, pluginID, entries[0].value("plugin_id"))
+		require.Equal(t, endpointQueryData, entries[0].value("endpoint"))
+		require.Equal(t, statusOK, entries[0].value("status"))
+		require.Equal(t, pluginrequestmeta.StatusSourcePlugin, entries[0].value("status_source"))
+		require.Equal(t, 2, entries[0].value("queries"))
+		require.IsType(t, time.Duration(0), entries[0].value("duration"))
+		require.Nil(t, entries[0].value("error"))
+	})
+
+	t.Run("Should log failed requests at warn level with their status source", func(t *testing.T) {
+		cdt, logger := setup(t)
+		errDownstream := errors.New("bad gateway")
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return &backend.QueryDataResponse{Responses: backend.Responses{
+				"A": backend.ErrDataResponseWithSource(backend.StatusBadGateway, backend.ErrorSourceDownstream, "bad gateway"),
+			}}, errDownstream
+		}
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: pCtx,
+			Queries:       []backend.DataQuery{{RefID: "A"}},
+		})
+		require.ErrorIs(t, err, errDownstream)
+
+		require.Empty(t, logger.entries("debug"))
+		entries := logger.entries("warn")
+		require.Len(t, entries, 1)
+		require.Equal(t, statusError, entries[0].value("status"))
+		require.Equal(t, pluginrequestmeta.StatusSourceDownstream, entries[0].value("status_source"))
+		require.Equal(t, errDownstream, entries[0].value("error"))
+	})
+
+	t.Run("Should log cancelled requests as such", func(t *testing.T) {
+		cdt, logger := setup(t)
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, context.Canceled
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, context.Canceled)
+
+		entries := logger.entries("warn")
+		require.Len(t, entries, 1)
+		require.Equal(t, endpointCheckHealth, entries[0].value("endpoint"))
+		require.Equal(t, statusCancelled, entries[0].value("status"))
+		require.Nil(t, entries[0].value("queries"), "only query data requests have a number of queries")
+	})
+
+	t.Run("Should log the requests of every endpoint", func(t *testing.T) {
+		cdt, logger := setup(t)
+		ctx := context.Background()
+		require.NoError(t, cdt.Decorator.CallResource(ctx, &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender))
+		_, err := cdt.Decorator.CollectMetrics(ctx, &backend.CollectMetricsRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		_, err = cdt.Decorator.SubscribeStream(ctx, &backend.SubscribeStreamRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		_, err = cdt.Decorator.PublishStream(ctx, &backend.PublishStreamRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.NoError(t, cdt.Decorator.RunStream(ctx, &backend.RunStreamRequest{PluginContext: pCtx}, &backend.StreamSender{}))
+
+		var endpoints []any
+		for _, e := range logger.entries("debug") {
+			endpoints = append(endpoints, e.value("endpoint"))
+		}
+		require.Equal(t, []any{endpointCallResource, endpointCollectMetrics, endpointSubscribeStream, endpointPublishStream, endpointRunStream}, endpoints)
+	})
+	t.Run("Should log the queue position of the queued requests", func(t *testing.T) {
+		logger := &capturingLogger{}
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(
+			NewPluginRequestMetaMiddleware(),
+			NewRequestLoggerMiddleware(logger),
+			NewConcurrencyLimitMiddleware(ConcurrencyLimitConfig{MaxConcurrent: 1, MaxQueued: 1}, prometheus.NewRegistry()),
+		))
+		started := make(chan struct{}, 2)
+		release := make(chan struct{})
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			started <- struct{}{}
+			<-release
+			return backend.NewQueryDataResponse(), nil
+		}
+		done := make(chan struct{})
+		go func() {
+			_, _ = cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+			close(done)
+		}()
+		<-started
+
+		go func() {
+			<-time.After(10 * time.Millisecond)
+			close(release)
+		}()
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		<-done
+
+		entries := logger.entries("debug")
+		require.Len(t, entries, 2)
+		var positions []any
+		for _, e := range entries {
+			positions = append(positions, e.value("queue_position"))
+		}
+		require.ElementsMatch(t, []any{nil, int64(1)}, positions, "only the queued request has a queue position")
+	})
+}
@@ -0,0 +1,194 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 2 * time.Second
+)
+
+// RetryConfig configures the requests retried by the RetryMiddleware.
+type RetryConfig struct {
+	// MaxAttempts is the maximum number of attempts of a request, including the first one. Defaults to 3.
+	MaxAttempts int
+
+	// InitialBackoff is the time waited before the first retry, doubled before every following one.
+	// Defaults to 100ms.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the time waited between two attempts. Defaults to 2s.
+	MaxBackoff time.Duration
+
+	// Codes are the gRPC status codes of the errors that are retried. Defaults to codes.Unavailable.
+	Codes []codes.Code
+
+	// Endpoints are the endpoints whose requests are retried. Defaults to "queryData" and "checkHealth".
+	// "callResource" can be retried too, but only as long as the plugin didn't send any response.
+	// The streaming endpoints are never retried.
+	Endpoints []string
+}
+
+// NewRetryMiddleware returns a new plugins.ClientMiddleware that retries the requests failing with a transient
+// gRPC error, or because the plugin is unavailable, e.g. while it's restarting, waiting for an exponential backoff between the attempts.
+// Downstream errors are not retried, and the retries stop as soon as the request context is done.
+// The retries, and the retried requests that eventually succeeded, are counted per plugin and endpoint.
+func NewRetryMiddleware(cfg RetryConfig, promRegisterer prometheus.Registerer) plugins.ClientMiddleware {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultRetryMaxBackoff
+	}
+	if len(cfg.Codes) == 0 {
+		cfg.Codes = []codes.Code{codes.Unavailable}
+	}
+	if len(cfg.Endpoints) == 0 {
+		cfg.Endpoints = []string{endpointQueryData, endpointCheckHealth}
+	}
+
+	retries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_retry_total",
+		Help:      "The number of retries of the plugin requests",
+	}, []string{"plugin_id", "endpoint"})
+	retrySuccesses := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Name:      "plugin_retry_success_total",
+		Help:      "The number of retried plugin requests that eventually succeeded",
+	}, []string{"plugin_id", "endpoint"})
+	promRegisterer.MustRegister(retries, retrySuccesses)
+
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &RetryMiddleware{
+			next:           next,
+			cfg:            cfg,
+			retries:        retries,
+			retrySuccesses: retrySuccesses,
+		}
+	})
+}
+
+type RetryMiddleware struct {
+	next plugins.Client
+	cfg  RetryConfig
+
+	retries        *prometheus.CounterVec
+	retrySuccesses *prometheus.CounterVec
+}
+
+// retryable returns true if the given error is a transient plugin error with one of the configured gRPC codes,
+// or if the plugin is unavailable, e.g. while its process is restarting.
+func (m *RetryMiddleware) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errorStatusSource(err) == pluginrequestmeta.StatusSourceDownstream {
+		return false
+	}
+	if errors.Is(err, plugins.ErrPluginUnavailable) {
+		return true
+	}
+	s, ok := status.FromError(err)
+	return ok && slices.Contains(m.cfg.Codes, s.Code())
+}
+
+// retry calls fn until it succeeds, returns an error that can't be retried, or the maximum number of attempts is
+// reached. fn returns false if the request can't be retried anymore regardless of its error.
+// If ctx is done while waiting for the next attempt, the error of the last attempt is returned.
+func (m *RetryMiddleware) retry(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, fn func() (bool, error)) error {
+	if !slices.Contains(m.cfg.Endpoints, endpoint) {
+		_, err := fn()
+		return err
+	}
+
+	backoff := m.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		canRetry, err := fn()
+		if err == nil && attempt > 1 {
+			m.retrySuccesses.WithLabelValues(pluginCtx.PluginID, endpoint).Inc()
+		}
+		if err == nil || !canRetry || attempt >= m.cfg.MaxAttempts || !m.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		m.retries.WithLabelValues(pluginCtx.PluginID, endpoint).Inc()
+
+		backoff *= 2
+		if backoff > m.cfg.MaxBackoff {
+			backoff = m.cfg.MaxBackoff
+		}
+	}
+}
+
+func (m *RetryMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	var resp *backend.QueryDataResponse
+	err := m.retry(ctx, req.PluginContext, endpointQueryData, func() (bool, error) {
+		var err error
+		resp, err = m.next.QueryData(ctx, req)
+		return true, err
+	})
+	return resp, err
+}
+
+func (m *RetryMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	// A resource request can't be retried once a response has been sent, as it can't be taken back
+	var sent bool
+	retrySender := callResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		sent = true
+		return sender.Send(res)
+	})
+	return m.retry(ctx, req.PluginContext, endpointCallResource, func() (bool, error) {
+		err := m.next.CallResource(ctx, req, retrySender)
+		return !sent, err
+	})
+}
+
+func (m *RetryMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	var resp *backend.CheckHealthResult
+	err := m.retry(ctx, req.PluginContext, endpointCheckHealth, func() (bool, error) {
+		var err error
+		resp, err = m.next.CheckHealth(ctx, req)
+		return true, err
+	})
+	return resp, err
+}
+
+func (m *RetryMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *RetryMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *RetryMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *RetryMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
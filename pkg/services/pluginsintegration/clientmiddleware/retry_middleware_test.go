@@ -0,0 +1,219 @@
+package clientmiddleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+	// Wrapped like the errors returned by the gRPC plugin client
+	errUnavailable := fmt.Errorf("%v: %w", "Failed to query data", status.Error(codes.Unavailable, "connection refused"))
+
+	newRetryTest := func(t *testing.T, cfg RetryConfig) *clienttest.ClientDecoratorTest {
+		if cfg.InitialBackoff == 0 {
+			cfg.InitialBackoff = time.Millisecond
+		}
+		return clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewRetryMiddleware(cfg, prometheus.NewRegistry())))
+	}
+
+	// failingN returns a function that returns err for its n first calls, and counts the calls.
+	failingN := func(n int, err error) (func() error, *int) {
+		var calls int
+		return func() error {
+			calls++
+			if calls <= n {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	t.Run("Should retry transient errors until the request succeeds", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{MaxAttempts: 3})
+		fail, calls := failingN(2, errUnavailable)
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			if err := fail(); err != nil {
+				return nil, err
+			}
+			return backend.NewQueryDataResponse(), nil
+		}
+
+		resp, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, 3, *calls)
+	})
+
+	t.Run("Should give up after the maximum number of attempts", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{MaxAttempts: 3})
+		fail, calls := failingN(5, errUnavailable)
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return &backend.CheckHealthResult{}, fail()
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errUnavailable)
+		require.Equal(t, 3, *calls)
+	})
+
+	t.Run("Should not retry errors with other codes", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{})
+		fail, calls := failingN(1, status.Error(codes.InvalidArgument, "bad request"))
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, fail()
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.Error(t, err)
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("Should retry the configured codes", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{Codes: []codes.Code{codes.ResourceExhausted}})
+		fail, calls := failingN(1, status.Error(codes.ResourceExhausted, "too many requests"))
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, fail()
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Equal(t, 2, *calls)
+	})
+
+	t.Run("Should retry the requests to unavailable plugins", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{})
+		fail, calls := failingN(2, plugins.ErrPluginUnavailable)
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			if err := fail(); err != nil {
+				return nil, err
+			}
+			return backend.NewQueryDataResponse(), nil
+		}
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.Equal(t, 3, *calls)
+	})
+
+	t.Run("Should not retry downstream errors", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{})
+		fail, calls := failingN(1, errorsource.DownstreamError(errUnavailable, false))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return nil, fail()
+		}
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errUnavailable)
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("Should stop retrying when the context deadline is exceeded", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{MaxAttempts: 10, InitialBackoff: time.Hour})
+		fail, calls := failingN(10, errUnavailable)
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return nil, fail()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, errUnavailable)
+		require.Equal(t, 1, *calls)
+		require.Less(t, time.Since(start), time.Minute)
+	})
+
+	t.Run("Should not retry cancelled requests", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{})
+		fail, calls := failingN(1, fmt.Errorf("failed: %w", context.Canceled))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return nil, fail()
+		}
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("Should not retry resource requests by default", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{})
+		fail, calls := failingN(1, errUnavailable)
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			return fail()
+		}
+
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.ErrorIs(t, err, errUnavailable)
+		require.Equal(t, 1, *calls)
+	})
+
+	t.Run("Should retry resource requests only until a response is sent", func(t *testing.T) {
+		cdt := newRetryTest(t, RetryConfig{Endpoints: []string{endpointCallResource}})
+		var calls int
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			calls++
+			if calls == 2 {
+				require.NoError(t, sender.Send(&backend.CallResourceResponse{Status: 200}))
+			}
+			return errUnavailable
+		}
+
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+		require.ErrorIs(t, err, errUnavailable)
+		require.Equal(t, 2, calls)
+	})
+	t.Run("Should count the retries and the retried requests that succeeded", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewRetryMiddleware(RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+		}, registry)))
+		var fail func() error
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			return backend.NewQueryDataResponse(), fail()
+		}
+		queryData := func() {
+			_, _ = cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: pCtx})
+		}
+		metrics := func(retries, successes int) string {
+			return fmt.Sprintf(`
+# HELP grafana_plugin_retry_success_total The number of retried plugin requests that eventually succeeded
+# TYPE grafana_plugin_retry_success_total counter
+grafana_plugin_retry_success_total{endpoint="queryData",plugin_id="%[1]s"} %[3]d
+# HELP grafana_plugin_retry_total The number of retries of the plugin requests
+# TYPE grafana_plugin_retry_total counter
+grafana_plugin_retry_total{endpoint="queryData",plugin_id="%[1]s"} %[2]d
+`, pluginID, retries, successes)
+		}
+
+		// Succeeds on the first attempt, so nothing is retried
+		fail, _ = failingN(0, errUnavailable)
+		queryData()
+		require.Equal(t, 0, testutil.CollectAndCount(registry))
+
+		// Succeeds on the third attempt
+		fail, _ = failingN(2, errUnavailable)
+		queryData()
+		require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(metrics(2, 1))))
+
+		// Never succeeds
+		fail, _ = failingN(5, errUnavailable)
+		queryData()
+		require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(metrics(4, 1))))
+	})
+}
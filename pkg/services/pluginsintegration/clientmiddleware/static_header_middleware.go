@@ -0,0 +1,88 @@
+package clientmiddleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins"
+)
+
+// NewStaticHeaderMiddleware creates a new plugins.ClientMiddleware that will
+// add the static headers configured for a plugin to its outgoing plugins.Client requests.
+// Headers already set on a request are not overwritten.
+func NewStaticHeaderMiddleware(perPlugin map[string]http.Header) plugins.ClientMiddleware {
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &StaticHeaderMiddleware{
+			next:      next,
+			perPlugin: perPlugin,
+		}
+	})
+}
+
+type StaticHeaderMiddleware struct {
+	next      plugins.Client
+	perPlugin map[string]http.Header
+}
+
+func (m *StaticHeaderMiddleware) applyStaticHeaders(pluginCtx backend.PluginContext, h backend.ForwardHTTPHeaders) {
+	headers, ok := m.perPlugin[pluginCtx.PluginID]
+	if h == nil || !ok {
+		return
+	}
+
+	for k, values := range headers {
+		if len(values) == 0 || h.GetHTTPHeader(k) != "" {
+			continue
+		}
+		h.SetHTTPHeader(k, strings.Join(values, ", "))
+	}
+}
+
+func (m *StaticHeaderMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	if req == nil {
+		return m.next.QueryData(ctx, req)
+	}
+
+	m.applyStaticHeaders(req.PluginContext, req)
+
+	return m.next.QueryData(ctx, req)
+}
+
+func (m *StaticHeaderMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if req == nil {
+		return m.next.CallResource(ctx, req, sender)
+	}
+
+	m.applyStaticHeaders(req.PluginContext, req)
+
+	return m.next.CallResource(ctx, req, sender)
+}
+
+func (m *StaticHeaderMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	if req == nil {
+		return m.next.CheckHealth(ctx, req)
+	}
+
+	m.applyStaticHeaders(req.PluginContext, req)
+
+	return m.next.CheckHealth(ctx, req)
+}
+
+func (m *StaticHeaderMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *StaticHeaderMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *StaticHeaderMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *StaticHeaderMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
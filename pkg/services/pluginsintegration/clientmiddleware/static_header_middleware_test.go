@@ -0,0 +1,84 @@
+package clientmiddleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+)
+
+func TestStaticHeaderMiddleware(t *testing.T) {
+	cdt := clienttest.NewClientDecoratorTest(t,
+		clienttest.WithMiddlewares(NewStaticHeaderMiddleware(map[string]http.Header{
+			"configured-plugin": {
+				"X-Api-Version": []string{"2024-01-01"},
+				"X-Tenant":      []string{"static"},
+			},
+		})),
+	)
+
+	configured := backend.PluginContext{PluginID: "configured-plugin"}
+	other := backend.PluginContext{PluginID: "other-plugin"}
+
+	t.Run("Should add the static headers when calling QueryData", func(t *testing.T) {
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: configured,
+			Headers:       map[string]string{},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, cdt.QueryDataReq)
+		require.Equal(t, "2024-01-01", cdt.QueryDataReq.GetHTTPHeader("X-Api-Version"))
+		require.Equal(t, "static", cdt.QueryDataReq.GetHTTPHeader("X-Tenant"))
+	})
+
+	t.Run("Should add the static headers when calling CallResource", func(t *testing.T) {
+		err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{
+			PluginContext: configured,
+			Headers:       map[string][]string{},
+		}, nopCallResourceSender)
+		require.NoError(t, err)
+		require.NotNil(t, cdt.CallResourceReq)
+		require.Equal(t, "2024-01-01", cdt.CallResourceReq.GetHTTPHeader("X-Api-Version"))
+		require.Equal(t, "static", cdt.CallResourceReq.GetHTTPHeader("X-Tenant"))
+	})
+
+	t.Run("Should add the static headers when calling CheckHealth", func(t *testing.T) {
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{
+			PluginContext: configured,
+			Headers:       map[string]string{},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, cdt.CheckHealthReq)
+		require.Equal(t, "2024-01-01", cdt.CheckHealthReq.GetHTTPHeader("X-Api-Version"))
+		require.Equal(t, "static", cdt.CheckHealthReq.GetHTTPHeader("X-Tenant"))
+	})
+
+	t.Run("Should not overwrite the headers of the request", func(t *testing.T) {
+		req := &backend.QueryDataRequest{PluginContext: configured}
+		req.SetHTTPHeader("X-Tenant", "from-request")
+		_, err := cdt.Decorator.QueryData(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, "2024-01-01", cdt.QueryDataReq.GetHTTPHeader("X-Api-Version"))
+		require.Equal(t, "from-request", cdt.QueryDataReq.GetHTTPHeader("X-Tenant"))
+	})
+
+	t.Run("Should not add the static headers for other plugins", func(t *testing.T) {
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: other,
+			Headers:       map[string]string{},
+		})
+		require.NoError(t, err)
+		require.Empty(t, cdt.QueryDataReq.Headers)
+
+		err = cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{
+			PluginContext: other,
+			Headers:       map[string][]string{},
+		}, nopCallResourceSender)
+		require.NoError(t, err)
+		require.Empty(t, cdt.CallResourceReq.Headers)
+	})
+}
@@ -2,9 +2,13 @@ package clientmiddleware
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
 
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
@@ -13,7 +17,9 @@ import (
 // NewStatusSourceMiddleware returns a new plugins.ClientMiddleware that sets the status source in the
 // plugin request meta stored in the context.Context, according to the query data responses returned by QueryError.
 // If at least one query data response has a "downstream" status source and there isn't one with a "plugin" status source,
-// the plugin request meta in the context is set to "downstream".
+// the plugin request meta in the context is set to "downstream", otherwise it's set to "plugin". It's set for each
+// response, so the status source of a request that is retried is the one of its last attempt.
+// The status source of the health checks and resource requests is set from their errors and results too.
 func NewStatusSourceMiddleware() plugins.ClientMiddleware {
 	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
 		return &StatusSourceMiddleware{
@@ -28,6 +34,7 @@ type StatusSourceMiddleware struct {
 
 func (m *StatusSourceMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	resp, err := m.next.QueryData(ctx, req)
+	// A nil response is a plugin error, so keep the default "plugin" status source
 	if resp == nil || len(resp.Responses) == 0 {
 		return resp, err
 	}
@@ -49,21 +56,93 @@ func (m *StatusSourceMiddleware) QueryData(ctx context.Context, req *backend.Que
 
 	// A plugin error has higher priority than a downstream error,
 	// so set to downstream only if there's no plugin error
+	statusSource := pluginrequestmeta.StatusSourcePlugin
 	if hasDownstreamError && !hasPluginError {
-		if err := pluginrequestmeta.WithDownstreamStatusSource(ctx); err != nil {
-			return resp, fmt.Errorf("failed to set downstream status source: %w", err)
-		}
+		statusSource = pluginrequestmeta.StatusSourceDownstream
+	}
+	if err := setStatusSource(ctx, statusSource); err != nil {
+		return resp, err
 	}
 
 	return resp, err
 }
 
+// setStatusSource sets the status source in the plugin request meta stored in ctx.
+// Without a status source in the context, the "plugin" one is returned anyway, so only failing
+// to set the "downstream" one is an error.
+func setStatusSource(ctx context.Context, statusSource pluginrequestmeta.StatusSource) error {
+	if err := pluginrequestmeta.SetStatusSource(ctx, statusSource); err != nil && statusSource == pluginrequestmeta.StatusSourceDownstream {
+		return fmt.Errorf("failed to set downstream status source: %w", err)
+	}
+	return nil
+}
+
+// errorStatusSource returns the status source of the given error: "downstream" if it's marked as a downstream
+// error with the errorsource package, "plugin" otherwise.
+func errorStatusSource(err error) pluginrequestmeta.StatusSource {
+	var sourceErr errorsource.Error
+	if errors.As(err, &sourceErr) && sourceErr.Source == backend.ErrorSourceDownstream {
+		return pluginrequestmeta.StatusSourceDownstream
+	}
+	return pluginrequestmeta.StatusSourcePlugin
+}
+
+// downstreamResourceStatusCodes are the HTTP status codes of the resource responses reporting that a server
+// behind the plugin, e.g. the data source, failed or is unreachable.
+var downstreamResourceStatusCodes = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// CallResource sets the "downstream" status source if the request failed with a downstream error, or if the
+// first response has a status code reporting that a server behind the plugin failed, like 502 Bad Gateway.
 func (m *StatusSourceMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
-	return m.next.CallResource(ctx, req, sender)
+	var statusCode int
+	err := m.next.CallResource(ctx, req, callResourceResponseSenderFunc(func(res *backend.CallResourceResponse) error {
+		if res != nil && statusCode == 0 {
+			statusCode = res.Status
+		}
+		return sender.Send(res)
+	}))
+
+	statusSource := pluginrequestmeta.StatusSourcePlugin
+	if err != nil {
+		statusSource = errorStatusSource(err)
+	} else if downstreamResourceStatusCodes[statusCode] {
+		statusSource = pluginrequestmeta.StatusSourceDownstream
+	}
+	if setErr := setStatusSource(ctx, statusSource); setErr != nil {
+		return setErr
+	}
+	return err
+}
+
+// checkHealthDetails are the JSON details of a health check result that are relevant to its status source.
+// The health check results have no error source, so the plugins can report it in their details instead.
+type checkHealthDetails struct {
+	ErrorSource backend.ErrorSource `json:"errorSource"`
 }
 
+// CheckHealth sets the "downstream" status source if the health check failed with a downstream error, or if
+// the result has an error status and its details have a "downstream" errorSource, e.g. when the data source
+// is unreachable.
 func (m *StatusSourceMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
-	return m.next.CheckHealth(ctx, req)
+	result, err := m.next.CheckHealth(ctx, req)
+
+	statusSource := pluginrequestmeta.StatusSourcePlugin
+	if err != nil {
+		statusSource = errorStatusSource(err)
+	} else if result != nil && result.Status == backend.HealthStatusError && len(result.JSONDetails) > 0 {
+		var details checkHealthDetails
+		if jsonErr := json.Unmarshal(result.JSONDetails, &details); jsonErr == nil && details.ErrorSource == backend.ErrorSourceDownstream {
+			statusSource = pluginrequestmeta.StatusSourceDownstream
+		}
+	}
+	if setErr := setStatusSource(ctx, statusSource); setErr != nil {
+		return result, setErr
+	}
+	return result, err
 }
 
 func (m *StatusSourceMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
@@ -3,9 +3,11 @@ package clientmiddleware
 import (
 	"context"
 	"errors"
+	"net/http"
 	"testing"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
@@ -86,3 +88,124 @@ func TestStatusSourceMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusSourceMiddlewareCheckHealth(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		result          *backend.CheckHealthResult
+		err             error
+		expStatusSource pluginrequestmeta.StatusSource
+	}{
+		{
+			name:            `healthy plugin should be "plugin" status source`,
+			result:          &backend.CheckHealthResult{Status: backend.HealthStatusOk},
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            `plugin error should be "plugin" status source`,
+			err:             errors.New("oops"),
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            `downstream error should be "downstream" status source`,
+			err:             errorsource.DownstreamError(errors.New("connection refused"), false),
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+		},
+		{
+			name:            `unhealthy result without error source should be "plugin" status source`,
+			result:          &backend.CheckHealthResult{Status: backend.HealthStatusError, JSONDetails: []byte(`{"verboseMessage": "oops"}`)},
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            `unhealthy result with downstream error source should be "downstream" status source`,
+			result:          &backend.CheckHealthResult{Status: backend.HealthStatusError, JSONDetails: []byte(`{"errorSource": "downstream"}`)},
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+		},
+		{
+			name:            `healthy result with downstream error source should be "plugin" status source`,
+			result:          &backend.CheckHealthResult{Status: backend.HealthStatusOk, JSONDetails: []byte(`{"errorSource": "downstream"}`)},
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cdt := clienttest.NewClientDecoratorTest(t,
+				clienttest.WithMiddlewares(
+					NewPluginRequestMetaMiddleware(),
+					NewStatusSourceMiddleware(),
+				),
+			)
+			var checkHealthCtx context.Context
+			cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+				checkHealthCtx = ctx
+				return tc.result, tc.err
+			}
+
+			_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.expStatusSource, pluginrequestmeta.StatusSourceFromContext(checkHealthCtx))
+		})
+	}
+}
+
+func TestStatusSourceMiddlewareCallResource(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		statusCodes     []int
+		err             error
+		expStatusSource pluginrequestmeta.StatusSource
+	}{
+		{
+			name:            `ok response should be "plugin" status source`,
+			statusCodes:     []int{http.StatusOK},
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            `internal server error should be "plugin" status source`,
+			statusCodes:     []int{http.StatusInternalServerError},
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            `bad gateway should be "downstream" status source`,
+			statusCodes:     []int{http.StatusBadGateway},
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+		},
+		{
+			name:            `only the first streamed response should be considered`,
+			statusCodes:     []int{http.StatusOK, http.StatusGatewayTimeout},
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+		{
+			name:            `downstream error should be "downstream" status source`,
+			err:             errorsource.DownstreamError(errors.New("connection refused"), false),
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+		},
+		{
+			name:            `plugin error should be "plugin" status source`,
+			err:             errors.New("oops"),
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cdt := clienttest.NewClientDecoratorTest(t,
+				clienttest.WithMiddlewares(
+					NewPluginRequestMetaMiddleware(),
+					NewStatusSourceMiddleware(),
+				),
+			)
+			var callResourceCtx context.Context
+			cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+				callResourceCtx = ctx
+				for _, statusCode := range tc.statusCodes {
+					if err := sender.Send(&backend.CallResourceResponse{Status: statusCode}); err != nil {
+						return err
+					}
+				}
+				return tc.err
+			}
+
+			err := cdt.Decorator.CallResource(context.Background(), &backend.CallResourceRequest{}, nopCallResourceSender)
+			require.Equal(t, tc.err, err)
+			require.Equal(t, tc.expStatusSource, pluginrequestmeta.StatusSourceFromContext(callResourceCtx))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/experimental/errorsource"
+
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/util/errutil"
+)
+
+var errPluginRequestTimeout = errutil.GatewayTimeout("plugin.requestTimeout",
+	errutil.WithPublicMessage("Plugin request timed out"))
+
+const (
+	defaultCheckHealthTimeout  = 30 * time.Second
+	defaultQueryDataTimeout    = 5 * time.Minute
+	defaultCallResourceTimeout = time.Minute
+)
+
+// TimeoutConfig configures the timeouts of the TimeoutMiddleware.
+type TimeoutConfig struct {
+	// CheckHealth is the timeout of the health checks. Defaults to 30s.
+	CheckHealth time.Duration
+
+	// QueryData is the timeout of the query data requests. Defaults to 5m.
+	QueryData time.Duration
+
+	// CallResource is the timeout of the resource requests. Defaults to 1m.
+	CallResource time.Duration
+}
+
+// NewTimeoutMiddleware returns a new plugins.ClientMiddleware that cancels the CheckHealth, QueryData and
+// CallResource requests taking longer than the timeout of their endpoint, and fails them with a downstream
+// timeout error. A shorter deadline of the request context is kept, and its expiry is reported as is.
+func NewTimeoutMiddleware(cfg TimeoutConfig) plugins.ClientMiddleware {
+	if cfg.CheckHealth <= 0 {
+		cfg.CheckHealth = defaultCheckHealthTimeout
+	}
+	if cfg.QueryData <= 0 {
+		cfg.QueryData = defaultQueryDataTimeout
+	}
+	if cfg.CallResource <= 0 {
+		cfg.CallResource = defaultCallResourceTimeout
+	}
+	return plugins.ClientMiddlewareFunc(func(next plugins.Client) plugins.Client {
+		return &TimeoutMiddleware{
+			next: next,
+			cfg:  cfg,
+		}
+	})
+}
+
+type TimeoutMiddleware struct {
+	next plugins.Client
+	cfg  TimeoutConfig
+}
+
+// withTimeout calls fn with a context cancelled after the given timeout. If the request failed because of that
+// timeout, rather than because of the deadline of ctx, a downstream timeout error is returned.
+func (m *TimeoutMiddleware) withTimeout(ctx context.Context, pluginCtx backend.PluginContext, endpoint string, timeout time.Duration, fn func(context.Context) error) error {
+	// context.WithTimeout keeps the deadline of ctx if it's earlier
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(timeoutCtx)
+	if err == nil || !errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) || ctx.Err() != nil {
+		return err
+	}
+
+	markDownstream(ctx)
+	return errorsource.DownstreamError(errPluginRequestTimeout.Errorf("%s request to plugin %s timed out after %s: %w", endpoint, pluginCtx.PluginID, timeout, err), false)
+}
+
+func (m *TimeoutMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	var resp *backend.QueryDataResponse
+	err := m.withTimeout(ctx, req.PluginContext, endpointQueryData, m.cfg.QueryData, func(ctx context.Context) error {
+		var err error
+		resp, err = m.next.QueryData(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *TimeoutMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return m.withTimeout(ctx, req.PluginContext, endpointCallResource, m.cfg.CallResource, func(ctx context.Context) error {
+		return m.next.CallResource(ctx, req, sender)
+	})
+}
+
+func (m *TimeoutMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	var resp *backend.CheckHealthResult
+	err := m.withTimeout(ctx, req.PluginContext, endpointCheckHealth, m.cfg.CheckHealth, func(ctx context.Context) error {
+		var err error
+		resp, err = m.next.CheckHealth(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (m *TimeoutMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return m.next.CollectMetrics(ctx, req)
+}
+
+func (m *TimeoutMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	return m.next.SubscribeStream(ctx, req)
+}
+
+func (m *TimeoutMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return m.next.PublishStream(ctx, req)
+}
+
+func (m *TimeoutMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	return m.next.RunStream(ctx, req, sender)
+}
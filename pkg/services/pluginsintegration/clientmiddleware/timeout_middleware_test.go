@@ -0,0 +1,123 @@
+package clientmiddleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	pCtx := backend.PluginContext{PluginID: pluginID}
+
+	// setup returns a test whose requests block until their context is done.
+	setup := func(t *testing.T, cfg TimeoutConfig) *clienttest.ClientDecoratorTest {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewTimeoutMiddleware(cfg)))
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		cdt.TestClient.CallResourceFunc = func(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return cdt
+	}
+
+	t.Run("Should fail the requests with a downstream timeout error once their endpoint timeout is exceeded", func(t *testing.T) {
+		cdt := setup(t, TimeoutConfig{
+			CheckHealth:  10 * time.Millisecond,
+			QueryData:    20 * time.Millisecond,
+			CallResource: 30 * time.Millisecond,
+		})
+
+		for _, tc := range []struct {
+			endpoint string
+			timeout  time.Duration
+			run      func(ctx context.Context) error
+		}{
+			{
+				endpoint: endpointCheckHealth,
+				timeout:  10 * time.Millisecond,
+				run: func(ctx context.Context) error {
+					_, err := cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{PluginContext: pCtx})
+					return err
+				},
+			},
+			{
+				endpoint: endpointQueryData,
+				timeout:  20 * time.Millisecond,
+				run: func(ctx context.Context) error {
+					_, err := cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: pCtx})
+					return err
+				},
+			},
+			{
+				endpoint: endpointCallResource,
+				timeout:  30 * time.Millisecond,
+				run: func(ctx context.Context) error {
+					return cdt.Decorator.CallResource(ctx, &backend.CallResourceRequest{PluginContext: pCtx}, nopCallResourceSender)
+				},
+			},
+		} {
+			t.Run(tc.endpoint, func(t *testing.T) {
+				ctx := pluginrequestmeta.WithStatusSource(context.Background(), pluginrequestmeta.StatusSourcePlugin)
+				start := time.Now()
+				err := tc.run(ctx)
+				require.GreaterOrEqual(t, time.Since(start), tc.timeout)
+				require.ErrorIs(t, err, errPluginRequestTimeout)
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+				require.Equal(t, pluginrequestmeta.StatusSourceDownstream, errorStatusSource(err))
+				require.Equal(t, pluginrequestmeta.StatusSourceDownstream, pluginrequestmeta.StatusSourceFromContext(ctx))
+			})
+		}
+	})
+
+	t.Run("Should keep a shorter deadline of the request context", func(t *testing.T) {
+		cdt := setup(t, TimeoutConfig{QueryData: time.Hour})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{PluginContext: pCtx})
+		require.Less(t, time.Since(start), time.Minute)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.False(t, errors.Is(err, errPluginRequestTimeout), "the request context deadline is not a plugin timeout")
+	})
+
+	t.Run("Should apply the endpoint timeout over a longer deadline of the request context", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewTimeoutMiddleware(TimeoutConfig{CheckHealth: time.Hour})))
+		var deadline time.Time
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			deadline, _ = ctx.Deadline()
+			return &backend.CheckHealthResult{}, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+		defer cancel()
+		_, err := cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+	})
+
+	t.Run("Should not change the errors of the requests that didn't time out", func(t *testing.T) {
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewTimeoutMiddleware(TimeoutConfig{})))
+		errPlugin := errors.New("plugin failed")
+		cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			return nil, errPlugin
+		}
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: pCtx})
+		require.Equal(t, errPlugin, err)
+	})
+}
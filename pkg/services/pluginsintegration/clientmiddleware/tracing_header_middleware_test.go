@@ -1,6 +1,7 @@
 package clientmiddleware
 
 import (
+	"context"
 	"net/http"
 	"testing"
 
@@ -167,4 +168,19 @@ func TestTracingHeaderMiddleware(t *testing.T) {
 			require.Equal(t, `true`, cdt.CheckHealthReq.GetHTTPHeader(`X-Grafana-From-Expr`))
 		})
 	})
+	t.Run("When a request comes in without an HTTP request context", func(t *testing.T) {
+		// e.g. alerting queries, which don't originate from a dashboard panel
+		cdt := clienttest.NewClientDecoratorTest(t, clienttest.WithMiddlewares(NewTracingHeaderMiddleware()))
+
+		_, err := cdt.Decorator.QueryData(context.Background(), &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{
+				DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{},
+			},
+		})
+		require.NoError(t, err)
+
+		require.Empty(t, cdt.QueryDataReq.GetHTTPHeader(`X-Dashboard-Uid`))
+		require.Empty(t, cdt.QueryDataReq.GetHTTPHeader(`X-Panel-Id`))
+		require.Len(t, cdt.QueryDataReq.GetHTTPHeaders(), 0)
+	})
 }
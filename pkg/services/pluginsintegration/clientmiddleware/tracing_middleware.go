@@ -2,16 +2,20 @@ package clientmiddleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
 	"github.com/grafana/grafana/pkg/services/contexthandler"
 	"github.com/grafana/grafana/pkg/services/query"
 )
@@ -40,18 +44,62 @@ func setSpanAttributeFromHTTPHeader(headers http.Header, span trace.Span, attrib
 	}
 }
 
+// metadataCarrier adapts the gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// injectTraceContext returns a copy of ctx whose outgoing gRPC metadata contains the span context of ctx,
+// so that the plugin can continue the trace.
+func injectTraceContext(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
 // traceWrap returns a new context.Context which wraps a newly created span. The span will also contain attributes for
-// plugin id, org id, user login, ds, dashboard and panel info. The second function returned is a cleanup function,
-// which should be called by the caller (deferred) and will set the span status/error and end the span.
+// plugin id, org id, endpoint, user login, ds, dashboard and panel info, and its span context is propagated in the
+// outgoing gRPC metadata. The second function returned is a cleanup function, which should be called by the caller
+// (deferred) and will set the span status/error, the request status and status source, and end the span.
 func (m *TracingMiddleware) traceWrap(
-	ctx context.Context, pluginContext backend.PluginContext, opName string,
+	ctx context.Context, pluginContext backend.PluginContext, endpoint string,
 ) (context.Context, func(error)) {
-	// Start span
-	ctx, span := m.tracer.Start(ctx, "PluginClient."+opName, trace.WithAttributes(
+	opts := []trace.SpanStartOption{trace.WithAttributes(
 		// Attach some plugin context information to span
 		attribute.String("plugin_id", pluginContext.PluginID),
 		attribute.Int64("org_id", pluginContext.OrgID),
-	))
+		attribute.String("endpoint", endpoint),
+	)}
+	// Link the span to the originating request span, so that the plugin calls a request fans out to
+	// can be found from it even when they end up in separate traces
+	if parent := trace.SpanContextFromContext(ctx); parent.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: parent}))
+	}
+
+	// Start span
+	ctx, span := m.tracer.Start(ctx, "PluginClient."+endpoint, opts...)
 
 	if settings := pluginContext.DataSourceInstanceSettings; settings != nil {
 		span.SetAttributes(attribute.String("datasource_name", settings.Name))
@@ -71,26 +119,44 @@ func (m *TracingMiddleware) traceWrap(
 	}
 
 	// Return ctx with span + cleanup func
-	return ctx, func(err error) {
+	return injectTraceContext(ctx), func(err error) {
+		status := statusOK
 		if err != nil {
+			status = statusError
+			if errors.Is(err, context.Canceled) {
+				status = statusCancelled
+			}
 			span.SetStatus(codes.Error, err.Error())
 			span.RecordError(err)
 		}
+		span.SetAttributes(
+			attribute.String("status", status),
+			attribute.String("status_source", string(pluginrequestmeta.StatusSourceFromContext(ctx))),
+		)
 		span.End()
 	}
 }
 
 func (m *TracingMiddleware) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "queryData")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointQueryData)
 	defer func() { end(err) }()
+	if len(req.Queries) > 0 {
+		stats := newQueryDataStats(req.Queries)
+		trace.SpanFromContext(ctx).SetAttributes(
+			attribute.Int("queries", stats.queries),
+			attribute.Int64("min_interval_ms", stats.minInterval.Milliseconds()),
+			attribute.Int64("max_interval_ms", stats.maxInterval.Milliseconds()),
+			attribute.Int64("total_max_data_points", stats.totalMaxDataPoints),
+		)
+	}
 	resp, err := m.next.QueryData(ctx, req)
 	return resp, err
 }
 
 func (m *TracingMiddleware) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "callResource")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointCallResource)
 	defer func() { end(err) }()
 	err = m.next.CallResource(ctx, req, sender)
 	return err
@@ -98,7 +164,7 @@ func (m *TracingMiddleware) CallResource(ctx context.Context, req *backend.CallR
 
 func (m *TracingMiddleware) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "checkHealth")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointCheckHealth)
 	defer func() { end(err) }()
 	resp, err := m.next.CheckHealth(ctx, req)
 	return resp, err
@@ -106,7 +172,7 @@ func (m *TracingMiddleware) CheckHealth(ctx context.Context, req *backend.CheckH
 
 func (m *TracingMiddleware) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "collectMetrics")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointCollectMetrics)
 	defer func() { end(err) }()
 	resp, err := m.next.CollectMetrics(ctx, req)
 	return resp, err
@@ -114,7 +180,7 @@ func (m *TracingMiddleware) CollectMetrics(ctx context.Context, req *backend.Col
 
 func (m *TracingMiddleware) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "subscribeStream")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointSubscribeStream)
 	defer func() { end(err) }()
 	resp, err := m.next.SubscribeStream(ctx, req)
 	return resp, err
@@ -122,7 +188,7 @@ func (m *TracingMiddleware) SubscribeStream(ctx context.Context, req *backend.Su
 
 func (m *TracingMiddleware) PublishStream(ctx context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "publishStream")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointPublishStream)
 	defer func() { end(err) }()
 	resp, err := m.next.PublishStream(ctx, req)
 	return resp, err
@@ -130,7 +196,7 @@ func (m *TracingMiddleware) PublishStream(ctx context.Context, req *backend.Publ
 
 func (m *TracingMiddleware) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
 	var err error
-	ctx, end := m.traceWrap(ctx, req.PluginContext, "runStream")
+	ctx, end := m.traceWrap(ctx, req.PluginContext, endpointRunStream)
 	defer func() { end(err) }()
 	err = m.next.RunStream(ctx, req, sender)
 	return err
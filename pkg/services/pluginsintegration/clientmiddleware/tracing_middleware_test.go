@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/stretchr/testify/assert"
@@ -14,10 +15,13 @@ import (
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
 	"github.com/grafana/grafana/pkg/services/contexthandler/ctxkey"
 	contextmodel "github.com/grafana/grafana/pkg/services/contexthandler/model"
 	"github.com/grafana/grafana/pkg/web"
@@ -194,9 +198,12 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 			},
 			assert: func(t *testing.T, span trace.ReadOnlySpan) {
 				attribs := span.Attributes()
-				require.Len(t, attribs, 2, "should have correct number of span attributes")
+				require.Len(t, attribs, 5, "should have correct number of span attributes")
 				require.True(t, spanAttributesContains(attribs, attribute.String("plugin_id", "my_plugin_id")))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("org_id", 1337)))
+				require.True(t, spanAttributesContains(attribs, attribute.String("endpoint", endpointQueryData)))
+				require.True(t, spanAttributesContains(attribs, attribute.String("status", statusOK)))
+				require.True(t, spanAttributesContains(attribs, attribute.String("status_source", string(pluginrequestmeta.StatusSourcePlugin))))
 			},
 		},
 		{
@@ -209,7 +216,7 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 			},
 			assert: func(t *testing.T, span trace.ReadOnlySpan) {
 				attribs := span.Attributes()
-				assert.Len(t, attribs, 3, "should have correct number of span attributes")
+				assert.Len(t, attribs, 6, "should have correct number of span attributes")
 				require.True(t, spanAttributesContains(attribs, attribute.String("plugin_id", "my_plugin_id")))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("org_id", 1337)))
 				require.True(t, spanAttributesContains(attribs, attribute.String("user", "admin")))
@@ -220,7 +227,7 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 			requestMut: []func(ctx *context.Context, req *backend.QueryDataRequest){},
 			assert: func(t *testing.T, span trace.ReadOnlySpan) {
 				attribs := span.Attributes()
-				require.Len(t, attribs, 2, "should have correct number of span attributes")
+				require.Len(t, attribs, 5, "should have correct number of span attributes")
 				require.True(t, spanAttributesContains(attribs, attribute.String("plugin_id", "")))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("org_id", 0)))
 			},
@@ -251,7 +258,7 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 			},
 			assert: func(t *testing.T, span trace.ReadOnlySpan) {
 				attribs := span.Attributes()
-				require.Len(t, attribs, 4)
+				require.Len(t, attribs, 7)
 				require.True(t, spanAttributesContains(attribs, attribute.String("plugin_id", "")))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("org_id", 0)))
 				require.True(t, spanAttributesContains(attribs, attribute.String("datasource_uid", "uid")))
@@ -274,7 +281,7 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 			},
 			assert: func(t *testing.T, span trace.ReadOnlySpan) {
 				attribs := span.Attributes()
-				require.Len(t, attribs, 5)
+				require.Len(t, attribs, 8)
 				require.True(t, spanAttributesContains(attribs, attribute.String("plugin_id", "")))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("org_id", 0)))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("panel_id", 10)))
@@ -282,6 +289,26 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 				require.True(t, spanAttributesContains(attribs, attribute.String("dashboard_uid", "dashboard uid")))
 			},
 		},
+		{
+			name: "queries",
+			requestMut: []func(ctx *context.Context, req *backend.QueryDataRequest){
+				func(ctx *context.Context, req *backend.QueryDataRequest) {
+					req.Queries = []backend.DataQuery{
+						{RefID: "A", Interval: 15 * time.Second, MaxDataPoints: 100},
+						{RefID: "B", Interval: time.Minute, MaxDataPoints: 200},
+						{RefID: "C", Interval: 30 * time.Second, MaxDataPoints: 300},
+					}
+				},
+			},
+			assert: func(t *testing.T, span trace.ReadOnlySpan) {
+				attribs := span.Attributes()
+				require.Len(t, attribs, 9)
+				require.True(t, spanAttributesContains(attribs, attribute.Int("queries", 3)))
+				require.True(t, spanAttributesContains(attribs, attribute.Int64("min_interval_ms", 15000)))
+				require.True(t, spanAttributesContains(attribs, attribute.Int64("max_interval_ms", 60000)))
+				require.True(t, spanAttributesContains(attribs, attribute.Int64("total_max_data_points", 600)))
+			},
+		},
 		{
 			name: "single http headers are skipped if not present or empty",
 			requestMut: []func(ctx *context.Context, req *backend.QueryDataRequest){
@@ -296,7 +323,7 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 			},
 			assert: func(t *testing.T, span trace.ReadOnlySpan) {
 				attribs := span.Attributes()
-				require.Len(t, attribs, 2)
+				require.Len(t, attribs, 5)
 				require.True(t, spanAttributesContains(attribs, attribute.String("plugin_id", "")))
 				require.True(t, spanAttributesContains(attribs, attribute.Int("org_id", 0)))
 			},
@@ -334,6 +361,164 @@ func TestTracingMiddlewareAttributes(t *testing.T) {
 	}
 }
 
+func TestTracingMiddlewareLinks(t *testing.T) {
+	t.Run("Should link the plugin span to the parent span", func(t *testing.T) {
+		spanRecorder := tracetest.NewSpanRecorder()
+		tracer := tracing.InitializeTracerForTest(tracing.WithSpanProcessor(spanRecorder))
+
+		cdt := clienttest.NewClientDecoratorTest(
+			t,
+			clienttest.WithMiddlewares(NewTracingMiddleware(tracer)),
+		)
+
+		ctx, parent := tracer.Start(context.Background(), "request")
+		_, err := cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		parent.End()
+
+		spans := spanRecorder.Ended()
+		require.Len(t, spans, 2)
+		span := spans[0]
+		require.Equal(t, "PluginClient.checkHealth", span.Name())
+		require.Equal(t, parent.SpanContext(), span.Parent())
+		require.Len(t, span.Links(), 1)
+		require.Equal(t, parent.SpanContext(), span.Links()[0].SpanContext)
+	})
+
+	t.Run("Should not link the plugin span without a parent span", func(t *testing.T) {
+		spanRecorder := tracetest.NewSpanRecorder()
+		tracer := tracing.InitializeTracerForTest(tracing.WithSpanProcessor(spanRecorder))
+
+		cdt := clienttest.NewClientDecoratorTest(
+			t,
+			clienttest.WithMiddlewares(NewTracingMiddleware(tracer)),
+		)
+
+		_, err := cdt.Decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+
+		spans := spanRecorder.Ended()
+		require.Len(t, spans, 1)
+		require.False(t, spans[0].Parent().IsValid())
+		require.Empty(t, spans[0].Links())
+	})
+
+	t.Run("Should not link the plugin span to an invalid parent span", func(t *testing.T) {
+		spanRecorder := tracetest.NewSpanRecorder()
+		tracer := tracing.InitializeTracerForTest(tracing.WithSpanProcessor(spanRecorder))
+
+		cdt := clienttest.NewClientDecoratorTest(
+			t,
+			clienttest.WithMiddlewares(NewTracingMiddleware(tracer)),
+		)
+
+		ctx := oteltrace.ContextWithSpanContext(context.Background(), oteltrace.SpanContext{})
+		_, err := cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+
+		spans := spanRecorder.Ended()
+		require.Len(t, spans, 1)
+		require.Empty(t, spans[0].Links())
+	})
+}
+
+func TestTracingMiddlewareStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		err             error
+		statusSource    pluginrequestmeta.StatusSource
+		expStatus       string
+		expStatusSource pluginrequestmeta.StatusSource
+		expCode         codes.Code
+	}{
+		{
+			name:            "ok",
+			expStatus:       statusOK,
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+			expCode:         codes.Unset,
+		},
+		{
+			name:            "plugin error",
+			err:             errors.New("boom"),
+			statusSource:    pluginrequestmeta.StatusSourcePlugin,
+			expStatus:       statusError,
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+			expCode:         codes.Error,
+		},
+		{
+			name:            "downstream error",
+			err:             errors.New("bad gateway"),
+			statusSource:    pluginrequestmeta.StatusSourceDownstream,
+			expStatus:       statusError,
+			expStatusSource: pluginrequestmeta.StatusSourceDownstream,
+			expCode:         codes.Error,
+		},
+		{
+			name:            "cancelled",
+			err:             context.Canceled,
+			expStatus:       statusCancelled,
+			expStatusSource: pluginrequestmeta.StatusSourcePlugin,
+			expCode:         codes.Error,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spanRecorder := tracetest.NewSpanRecorder()
+			tracer := tracing.InitializeTracerForTest(tracing.WithSpanProcessor(spanRecorder))
+
+			cdt := clienttest.NewClientDecoratorTest(
+				t,
+				clienttest.WithMiddlewares(NewTracingMiddleware(tracer)),
+			)
+			cdt.TestClient.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+				if tc.statusSource != "" {
+					require.NoError(t, pluginrequestmeta.SetStatusSource(ctx, tc.statusSource))
+				}
+				return nil, tc.err
+			}
+
+			ctx := pluginrequestmeta.WithStatusSource(context.Background(), pluginrequestmeta.StatusSourcePlugin)
+			_, _ = cdt.Decorator.CheckHealth(ctx, &backend.CheckHealthRequest{})
+
+			spans := spanRecorder.Ended()
+			require.Len(t, spans, 1)
+			attribs := spans[0].Attributes()
+			require.True(t, spanAttributesContains(attribs, attribute.String("endpoint", endpointCheckHealth)))
+			require.True(t, spanAttributesContains(attribs, attribute.String("status", tc.expStatus)))
+			require.True(t, spanAttributesContains(attribs, attribute.String("status_source", string(tc.expStatusSource))))
+			require.Equal(t, tc.expCode, spans[0].Status().Code)
+		})
+	}
+}
+
+func TestTracingMiddlewarePropagation(t *testing.T) {
+	t.Run("Should propagate the plugin span context in the outgoing gRPC metadata", func(t *testing.T) {
+		spanRecorder := tracetest.NewSpanRecorder()
+		tracer := tracing.InitializeTracerForTest(tracing.WithSpanProcessor(spanRecorder))
+
+		cdt := clienttest.NewClientDecoratorTest(
+			t,
+			clienttest.WithMiddlewares(NewTracingMiddleware(tracer)),
+		)
+		var md metadata.MD
+		cdt.TestClient.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			md, _ = metadata.FromOutgoingContext(ctx)
+			return nil, nil
+		}
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-existing", "value")
+		_, err := cdt.Decorator.QueryData(ctx, &backend.QueryDataRequest{})
+		require.NoError(t, err)
+
+		spans := spanRecorder.Ended()
+		require.Len(t, spans, 1)
+		require.Equal(t, []string{"value"}, md.Get("x-existing"))
+		traceparent := md.Get("traceparent")
+		require.Len(t, traceparent, 1)
+		require.Contains(t, traceparent[0], spans[0].SpanContext().TraceID().String())
+		require.Contains(t, traceparent[0], spans[0].SpanContext().SpanID().String())
+	})
+}
+
 func spanAttributesContains(attribs []attribute.KeyValue, attrib attribute.KeyValue) bool {
 	for _, v := range attribs {
 		if v.Key == attrib.Key && v.Value == attrib.Value {
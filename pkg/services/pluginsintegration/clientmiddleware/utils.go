@@ -1,7 +1,17 @@
 package clientmiddleware
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/grafana/grafana/pkg/plugins/pluginrequestmeta"
+	"github.com/grafana/grafana/pkg/services/contexthandler"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 )
 
 const (
@@ -16,10 +26,171 @@ const (
 	endpointSubscribeStream = "subscribeStream"
 	endpointPublishStream   = "publishStream"
 	endpointRunStream       = "runStream"
+
+	pluginSourceCore     = "core"
+	pluginSourceExternal = "external"
+	pluginSourceDev      = "dev"
+
+	statusCodeOther = "other"
+
+	rangeRecencyRealtime   = "realtime"
+	rangeRecencyRecent     = "recent"
+	rangeRecencyHistorical = "historical"
+
+	clientClassBrowser = "browser"
+	clientClassSDK     = "sdk"
+	clientClassAgent   = "agent"
+	clientClassOther   = "other"
+
+	datasourceUIDNone = "none"
+
+	defaultRangeRecencyRealtime = 5 * time.Minute
+	defaultRangeRecencyRecent   = 24 * time.Hour
 )
 
+// knownErrorStatusCodes are the HTTP status codes reported as-is in the "status_code" label.
+// Any other code is folded to statusCodeOther, to keep the label cardinality bounded.
+var knownErrorStatusCodes = map[int]string{
+	http.StatusBadRequest:            "400",
+	http.StatusUnauthorized:          "401",
+	http.StatusForbidden:             "403",
+	http.StatusNotFound:              "404",
+	http.StatusRequestTimeout:        "408",
+	http.StatusRequestEntityTooLarge: "413",
+	http.StatusTooManyRequests:       "429",
+	http.StatusInternalServerError:   "500",
+	http.StatusNotImplemented:        "501",
+	http.StatusBadGateway:            "502",
+	http.StatusServiceUnavailable:    "503",
+	http.StatusGatewayTimeout:        "504",
+}
+
+// statusCodeLabel returns the value for the "status_code" Prometheus label for the given HTTP status code.
+func statusCodeLabel(statusCode int) string {
+	if label, ok := knownErrorStatusCodes[statusCode]; ok {
+		return label
+	}
+	return statusCodeOther
+}
+
+// rangeRecencyBuckets are the boundaries of the values of the "range_recency" Prometheus label.
+type rangeRecencyBuckets struct {
+	realtime time.Duration
+	recent   time.Duration
+}
+
+// label returns the value for the "range_recency" Prometheus label for the given queries,
+// based on the most recent end of their time ranges. It's empty if there are no queries.
+func (b rangeRecencyBuckets) label(now time.Time, queries []backend.DataQuery) string {
+	if len(queries) == 0 {
+		return ""
+	}
+	to := queries[0].TimeRange.To
+	for _, q := range queries[1:] {
+		if q.TimeRange.To.After(to) {
+			to = q.TimeRange.To
+		}
+	}
+	switch age := now.Sub(to); {
+	case age <= b.realtime:
+		return rangeRecencyRealtime
+	case age <= b.recent:
+		return rangeRecencyRecent
+	default:
+		return rangeRecencyHistorical
+	}
+}
+
+// agentUserAgents are the lowercase User-Agent prefixes of the agents and tools that scrape or provision Grafana.
+var agentUserAgents = []string{"grafana-agent", "grafanaagent", "alloy", "prometheus", "terraform", "k6"}
+
+// sdkUserAgents are the lowercase User-Agent prefixes of the common HTTP client libraries and command line tools.
+var sdkUserAgents = []string{"go-http-client", "python-requests", "python-urllib", "python-httpx", "aiohttp", "axios", "node-fetch", "undici", "okhttp", "java", "apache-httpclient", "curl", "wget", "grafana-api-golang-client", "grafana-openapi-client-go"}
+
+// clientClassLabel returns the value for the "client_class" Prometheus label for the given User-Agent.
+// User-Agents are bucketed into a few classes, to keep the label cardinality bounded.
+func clientClassLabel(userAgent string) string {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	for _, prefix := range agentUserAgents {
+		if strings.HasPrefix(ua, prefix) {
+			return clientClassAgent
+		}
+	}
+	for _, prefix := range sdkUserAgents {
+		if strings.HasPrefix(ua, prefix) {
+			return clientClassSDK
+		}
+	}
+	if strings.HasPrefix(ua, "mozilla/") {
+		return clientClassBrowser
+	}
+	return clientClassOther
+}
+
+// clientClassFromContext returns the value for the "client_class" Prometheus label for the HTTP request in the context.
+// Requests without an HTTP request context, such as the ones made by background services, are reported as clientClassOther.
+func clientClassFromContext(ctx context.Context) string {
+	reqCtx := contexthandler.FromContext(ctx)
+	if reqCtx == nil || reqCtx.Req == nil {
+		return clientClassOther
+	}
+	return clientClassLabel(reqCtx.Req.UserAgent())
+}
+
+// errNilQueryDataResponse is returned in place of a nil QueryDataResponse returned by a plugin without an error.
+var errNilQueryDataResponse = errors.New("plugin returned a nil query data response")
+
+// instrumentationEnabled returns true if the given instrumentation feature toggle is enabled globally,
+// or if it has been enabled for the current request by the InstrumentationOverrideMiddleware.
+func instrumentationEnabled(ctx context.Context, features featuremgmt.FeatureToggles, flag string) bool {
+	return features.IsEnabled(flag) || pluginrequestmeta.InstrumentationOverridden(ctx, flag)
+}
+
+// markStatusSource sets the status source of the plugin request in ctx. It does nothing if the plugin request meta
+// middleware isn't used, since the status source is only tracked by it.
+func markStatusSource(ctx context.Context, statusSource pluginrequestmeta.StatusSource) {
+	_ = pluginrequestmeta.SetStatusSource(ctx, statusSource)
+}
+
+// markDownstream sets the status source of the plugin request in ctx to downstream, like markStatusSource.
+func markDownstream(ctx context.Context) {
+	markStatusSource(ctx, pluginrequestmeta.StatusSourceDownstream)
+}
+
 type callResourceResponseSenderFunc func(res *backend.CallResourceResponse) error
 
 func (fn callResourceResponseSenderFunc) Send(res *backend.CallResourceResponse) error {
 	return fn(res)
 }
+
+// queryDataStats is a summary of the queries in a backend.QueryDataRequest.
+// Values are aggregated across all the queries, so its size is bounded regardless of the number of queries.
+type queryDataStats struct {
+	queries            int
+	minInterval        time.Duration
+	maxInterval        time.Duration
+	totalMaxDataPoints int64
+}
+
+func newQueryDataStats(queries []backend.DataQuery) queryDataStats {
+	stats := queryDataStats{queries: len(queries)}
+	for i, q := range queries {
+		if i == 0 || q.Interval < stats.minInterval {
+			stats.minInterval = q.Interval
+		}
+		if q.Interval > stats.maxInterval {
+			stats.maxInterval = q.Interval
+		}
+		stats.totalMaxDataPoints += q.MaxDataPoints
+	}
+	return stats
+}
+
+// datasourceUID returns the value of the "datasource_uid" label for the given plugin context: the UID of its
+// data source, or datasourceUIDNone if it has none, e.g. for the requests to app plugins.
+func datasourceUID(pCtx backend.PluginContext) string {
+	if pCtx.DataSourceInstanceSettings == nil || pCtx.DataSourceInstanceSettings.UID == "" {
+		return datasourceUIDNone
+	}
+	return pCtx.DataSourceInstanceSettings.UID
+}
@@ -2,6 +2,7 @@ package pluginsintegration
 
 import (
 	"github.com/google/wire"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/grafana/grafana/pkg/infra/tracing"
@@ -48,6 +49,7 @@ import (
 	pluginSettings "github.com/grafana/grafana/pkg/services/pluginsintegration/pluginsettings/service"
 	"github.com/grafana/grafana/pkg/services/pluginsintegration/pluginstore"
 	"github.com/grafana/grafana/pkg/services/pluginsintegration/serviceregistration"
+	"github.com/grafana/grafana/pkg/services/quota"
 	"github.com/grafana/grafana/pkg/setting"
 )
 
@@ -122,10 +124,43 @@ var WireExtensionSet = wire.NewSet(
 	wire.Bind(new(plugins.PluginLoaderAuthorizer), new(*signature.UnsignedPluginAuthorizer)),
 	wire.Bind(new(finder.Finder), new(*finder.Local)),
 	finder.ProvideLocalFinder,
+	ProvidePayloadSampler,
+	ProvideOrgLatencyTracker,
+	ProvideQueryQuotaTracker,
 	ProvideClientDecorator,
 	wire.Bind(new(plugins.Client), new(*client.Decorator)),
 )
 
+// ProvidePayloadSampler returns the clientmiddleware.PayloadSampler storing the plugin payloads sampled for debugging.
+// Payloads are only sampled if enabled in the configuration.
+func ProvidePayloadSampler(cfg *setting.Cfg) *clientmiddleware.PayloadSampler {
+	return clientmiddleware.NewPayloadSampler(cfg.PluginPayloadSamplingSize, cfg.PluginPayloadSamplingMaxBytes, cfg.PluginPayloadSamplingRedactKeys)
+}
+
+// ProvideOrgLatencyTracker returns the clientmiddleware.OrgLatencyTracker keeping the latency of the plugin
+// requests of the most requested orgs. Latencies are only tracked if enabled in the configuration.
+func ProvideOrgLatencyTracker(cfg *setting.Cfg) *clientmiddleware.OrgLatencyTracker {
+	return clientmiddleware.NewOrgLatencyTracker(cfg.PluginOrgLatencyTrackingSize)
+}
+
+// ProvideQueryQuotaTracker returns the clientmiddleware.QueryQuotaTracker counting the daily plugin queries of
+// each user, and registers it as the usage reporter of the daily plugin query quotas.
+func ProvideQueryQuotaTracker(cfg *setting.Cfg, quotaService quota.Service) (*clientmiddleware.QueryQuotaTracker, error) {
+	tracker := clientmiddleware.NewQueryQuotaTracker(cfg.Quota.PluginQueryResetHour)
+	defaultLimits, err := clientmiddleware.QueryQuotaLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := quotaService.RegisterQuotaReporter(&quota.NewUsageReporter{
+		TargetSrv:     clientmiddleware.QueryQuotaTargetSrv,
+		DefaultLimits: defaultLimits,
+		Reporter:      tracker.Usage,
+	}); err != nil {
+		return nil, err
+	}
+	return tracker, nil
+}
+
 func ProvideClientDecorator(
 	cfg *setting.Cfg, pCfg *pCfg.Cfg,
 	pluginRegistry registry.Service,
@@ -134,63 +169,214 @@ func ProvideClientDecorator(
 	cachingService caching.CachingService,
 	features *featuremgmt.FeatureManager,
 	promRegisterer prometheus.Registerer,
+	payloadSampler *clientmiddleware.PayloadSampler,
+	orgLatencyTracker *clientmiddleware.OrgLatencyTracker,
+	quotaService quota.Service,
+	queryQuotaTracker *clientmiddleware.QueryQuotaTracker,
 ) (*client.Decorator, error) {
-	return NewClientDecorator(cfg, pCfg, pluginRegistry, oAuthTokenService, tracer, cachingService, features, promRegisterer, pluginRegistry)
+	return NewClientDecorator(cfg, pCfg, pluginRegistry, oAuthTokenService, tracer, cachingService, features, promRegisterer, pluginRegistry, payloadSampler, orgLatencyTracker, quotaService, queryQuotaTracker)
 }
 
 func NewClientDecorator(
 	cfg *setting.Cfg, pCfg *pCfg.Cfg,
 	pluginRegistry registry.Service, oAuthTokenService oauthtoken.OAuthTokenService,
 	tracer tracing.Tracer, cachingService caching.CachingService, features *featuremgmt.FeatureManager,
-	promRegisterer prometheus.Registerer, registry registry.Service,
+	promRegisterer prometheus.Registerer, registry registry.Service, payloadSampler *clientmiddleware.PayloadSampler,
+	orgLatencyTracker *clientmiddleware.OrgLatencyTracker, quotaService quota.Service,
+	queryQuotaTracker *clientmiddleware.QueryQuotaTracker,
 ) (*client.Decorator, error) {
 	c := client.ProvideService(pluginRegistry, pCfg)
-	middlewares := CreateMiddlewares(cfg, oAuthTokenService, tracer, cachingService, features, promRegisterer, registry)
+	middlewares, err := CreateMiddlewares(cfg, oAuthTokenService, tracer, cachingService, features, promRegisterer, registry, payloadSampler, orgLatencyTracker, quotaService, queryQuotaTracker)
+	if err != nil {
+		return nil, err
+	}
 	return client.NewDecorator(c, middlewares...)
 }
 
-func CreateMiddlewares(cfg *setting.Cfg, oAuthTokenService oauthtoken.OAuthTokenService, tracer tracing.Tracer, cachingService caching.CachingService, features *featuremgmt.FeatureManager, promRegisterer prometheus.Registerer, registry registry.Service) []plugins.ClientMiddleware {
-	var middlewares []plugins.ClientMiddleware
+// CreateMiddlewares returns the plugin client middlewares enabled by the configuration and feature toggles. They're
+// assembled with clientmiddleware.AssembleMiddlewares, so that their order constraints are checked rather than only
+// following from the order they're declared in.
+func CreateMiddlewares(cfg *setting.Cfg, oAuthTokenService oauthtoken.OAuthTokenService, tracer tracing.Tracer, cachingService caching.CachingService, features *featuremgmt.FeatureManager, promRegisterer prometheus.Registerer, registry registry.Service, payloadSampler *clientmiddleware.PayloadSampler, orgLatencyTracker *clientmiddleware.OrgLatencyTracker, quotaService quota.Service, queryQuotaTracker *clientmiddleware.QueryQuotaTracker) ([]plugins.ClientMiddleware, error) {
+	var specs []clientmiddleware.MiddlewareSpec
+	add := func(spec clientmiddleware.MiddlewareSpec) {
+		spec.Enabled = true
+		specs = append(specs, spec)
+	}
+
+	statusSource := features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) || features.IsEnabled(featuremgmt.FlagPluginsInstrumentationOverrides)
+	// The queue position set by the concurrency limit is in the plugin request meta too
+	if statusSource || cfg.PluginMaxConcurrentRequests > 0 {
+		add(clientmiddleware.MiddlewareSpec{
+			Name:       "plugin-request-meta",
+			Middleware: clientmiddleware.NewPluginRequestMetaMiddleware(),
+			// The plugin request meta is set up in the context before any middleware reads or sets it
+			Before: []string{"instrumentation-override", "metrics", "logger", "request-logger", "status-source"},
+		})
+	}
 
-	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) {
-		middlewares = []plugins.ClientMiddleware{
-			clientmiddleware.NewPluginRequestMetaMiddleware(),
-		}
+	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationOverrides) {
+		add(clientmiddleware.MiddlewareSpec{
+			Name:       "instrumentation-override",
+			Middleware: clientmiddleware.NewInstrumentationOverrideMiddleware(),
+			Before:     []string{"metrics", "logger"},
+		})
 	}
 
 	skipCookiesNames := []string{cfg.LoginCookieName}
-	middlewares = append(middlewares,
-		clientmiddleware.NewTracingMiddleware(tracer),
-		clientmiddleware.NewMetricsMiddleware(promRegisterer, registry, features),
-		clientmiddleware.NewContextualLoggerMiddleware(),
-		clientmiddleware.NewLoggerMiddleware(cfg, log.New("plugin.instrumentation"), features),
-		clientmiddleware.NewTracingHeaderMiddleware(),
-		clientmiddleware.NewClearAuthHeadersMiddleware(),
-		clientmiddleware.NewOAuthTokenMiddleware(oAuthTokenService),
-		clientmiddleware.NewCookiesMiddleware(skipCookiesNames),
-		clientmiddleware.NewResourceResponseMiddleware(),
-	)
+	add(clientmiddleware.MiddlewareSpec{Name: "tracing", Middleware: clientmiddleware.NewTracingMiddleware(tracer)})
+	add(clientmiddleware.MiddlewareSpec{Name: "metrics", Middleware: clientmiddleware.NewMetricsMiddleware(cfg, promRegisterer, registry, features)})
+	add(clientmiddleware.MiddlewareSpec{Name: "contextual-logger", Middleware: clientmiddleware.NewContextualLoggerMiddleware()})
+	add(clientmiddleware.MiddlewareSpec{Name: "logger", Middleware: clientmiddleware.NewLoggerMiddleware(cfg, log.New("plugin.instrumentation"), features)})
+	add(clientmiddleware.MiddlewareSpec{Name: "request-logger", Middleware: clientmiddleware.NewRequestLoggerMiddleware(log.New("plugin.request"))})
+	add(clientmiddleware.MiddlewareSpec{
+		Name:       "panic-recovery",
+		Middleware: clientmiddleware.NewPanicRecoveryMiddleware(log.New("plugin.recovery"), promRegisterer),
+		// After the instrumentation middlewares, so that the requests that panic are instrumented as errors
+		After: []string{"tracing", "metrics", "logger", "request-logger"},
+	})
+	if cfg.PluginCircuitBreakerEnabled {
+		add(clientmiddleware.MiddlewareSpec{
+			Name: "circuit-breaker",
+			Middleware: clientmiddleware.NewCircuitBreakerMiddleware(clientmiddleware.CircuitBreakerConfig{
+				FailureRatio: cfg.PluginCircuitBreakerFailureRatio,
+				MinRequests:  cfg.PluginCircuitBreakerMinRequests,
+				Window:       cfg.PluginCircuitBreakerWindow,
+				OpenTimeout:  cfg.PluginCircuitBreakerOpenTimeout,
+			}, promRegisterer),
+			// The short-circuited requests are instrumented, and a request is only counted as failed once it
+			// exhausted its retries
+			After:  []string{"metrics", "logger", "request-logger", "panic-recovery"},
+			Before: []string{"retry"},
+		})
+	}
+
+	if cfg.PluginRequestTimeoutsEnabled {
+		add(clientmiddleware.MiddlewareSpec{
+			Name: "timeout",
+			Middleware: clientmiddleware.NewTimeoutMiddleware(clientmiddleware.TimeoutConfig{
+				CheckHealth:  cfg.PluginCheckHealthTimeout,
+				QueryData:    cfg.PluginQueryDataTimeout,
+				CallResource: cfg.PluginCallResourceTimeout,
+			}),
+			// The timeouts are counted as failures by the circuit breaker, and they cover the time waited for the
+			// concurrency limit and all the attempts of the retried requests
+			After:  []string{"metrics", "logger", "request-logger", "panic-recovery", "circuit-breaker"},
+			Before: []string{"concurrency-limit", "retry"},
+		})
+	}
+
+	if cfg.PluginMaxConcurrentRequests > 0 {
+		add(clientmiddleware.MiddlewareSpec{
+			Name: "concurrency-limit",
+			Middleware: clientmiddleware.NewConcurrencyLimitMiddleware(clientmiddleware.ConcurrencyLimitConfig{
+				MaxConcurrent: cfg.PluginMaxConcurrentRequests,
+				MaxQueued:     cfg.PluginMaxQueuedRequests,
+			}, promRegisterer),
+			// The loggers log the queue position once the request is done, and the short-circuited requests don't
+			// take a slot
+			After:  []string{"plugin-request-meta", "metrics", "logger", "request-logger", "panic-recovery", "circuit-breaker"},
+			Before: []string{"retry"},
+		})
+	}
+
+	if cfg.PluginRetryEnabled {
+		add(clientmiddleware.MiddlewareSpec{
+			Name: "retry",
+			Middleware: clientmiddleware.NewRetryMiddleware(clientmiddleware.RetryConfig{
+				MaxAttempts:    cfg.PluginRetryMaxAttempts,
+				InitialBackoff: cfg.PluginRetryInitialBackoff,
+				MaxBackoff:     cfg.PluginRetryMaxBackoff,
+			}, promRegisterer),
+			// The retried requests are instrumented once, while the status source is set for every attempt
+			After:  []string{"metrics", "logger", "request-logger", "panic-recovery"},
+			Before: []string{"status-source"},
+		})
+	}
+	add(clientmiddleware.MiddlewareSpec{Name: "tracing-header", Middleware: clientmiddleware.NewTracingHeaderMiddleware()})
+	add(clientmiddleware.MiddlewareSpec{Name: "clear-auth-headers", Middleware: clientmiddleware.NewClearAuthHeadersMiddleware()})
+	add(clientmiddleware.MiddlewareSpec{Name: "oauth-token", Middleware: clientmiddleware.NewOAuthTokenMiddleware(oAuthTokenService, promRegisterer)})
+	add(clientmiddleware.MiddlewareSpec{Name: "cookies", Middleware: clientmiddleware.NewCookiesMiddleware(skipCookiesNames)})
+	add(clientmiddleware.MiddlewareSpec{Name: "resource-response", Middleware: clientmiddleware.NewResourceResponseMiddleware()})
 
 	// Placing the new service implementation behind a feature flag until it is known to be stable
 	if features.IsEnabled(featuremgmt.FlagUseCachingService) {
-		middlewares = append(middlewares, clientmiddleware.NewCachingMiddlewareWithFeatureManager(cachingService, features))
+		add(clientmiddleware.MiddlewareSpec{
+			Name:       "caching",
+			Middleware: clientmiddleware.NewCachingMiddlewareWithTTLBounds(cachingService, features, cfg.PluginCachingMinTTL, cfg.PluginCachingMaxTTL),
+			// The cached responses aren't counted as plugin calls
+			Before: []string{"fan-out", "query-quota"},
+		})
 	}
 
 	if features.IsEnabled(featuremgmt.FlagIdForwarding) {
-		middlewares = append(middlewares, clientmiddleware.NewForwardIDMiddleware())
+		add(clientmiddleware.MiddlewareSpec{Name: "forward-id", Middleware: clientmiddleware.NewForwardIDMiddleware()})
 	}
 
 	if cfg.SendUserHeader {
-		middlewares = append(middlewares, clientmiddleware.NewUserHeaderMiddleware())
+		add(clientmiddleware.MiddlewareSpec{Name: "user-header", Middleware: clientmiddleware.NewUserHeaderMiddleware()})
+	}
+
+	if len(cfg.PluginStaticHeaders) > 0 {
+		add(clientmiddleware.MiddlewareSpec{Name: "static-header", Middleware: clientmiddleware.NewStaticHeaderMiddleware(cfg.PluginStaticHeaders)})
+	}
+
+	if len(cfg.PluginForwardHeaders) > 0 {
+		add(clientmiddleware.MiddlewareSpec{
+			Name:       "forward-headers",
+			Middleware: clientmiddleware.NewForwardHeadersMiddleware(cfg.PluginForwardHeaders, log.New("plugin.forward_headers")),
+		})
+	}
+
+	add(clientmiddleware.MiddlewareSpec{Name: "fan-out", Middleware: clientmiddleware.NewFanOutMiddleware()})
+	if cfg.Quota.Enabled {
+		add(clientmiddleware.MiddlewareSpec{Name: "query-quota", Middleware: clientmiddleware.NewQueryQuotaMiddleware(queryQuotaTracker, quotaService, promRegisterer)})
+	}
+	add(clientmiddleware.MiddlewareSpec{Name: "http-client", Middleware: clientmiddleware.NewHTTPClientMiddleware()})
+
+	if cfg.PluginPayloadSamplingEnabled {
+		add(clientmiddleware.MiddlewareSpec{Name: "payload-sampling", Middleware: clientmiddleware.NewPayloadSamplingMiddleware(payloadSampler)})
+	}
+
+	if cfg.PluginOrgLatencyTrackingSize > 0 {
+		add(clientmiddleware.MiddlewareSpec{Name: "org-latency", Middleware: clientmiddleware.NewOrgLatencyMiddleware(orgLatencyTracker)})
+	}
+
+	if statusSource {
+		add(clientmiddleware.MiddlewareSpec{
+			Name:       "status-source",
+			Middleware: clientmiddleware.NewStatusSourceMiddleware(),
+			// StatusSourceMiddleware should be below the middlewares reading the status source, or they won't see
+			// the correct status source in their context.Context
+			After: []string{"metrics", "logger", "request-logger", "payload-sampling", "org-latency"},
+		})
 	}
 
-	middlewares = append(middlewares, clientmiddleware.NewHTTPClientMiddleware())
+	if mode := clientmiddleware.FrameContractMode(cfg.PluginFrameContractValidation); mode != "" && mode != clientmiddleware.FrameContractModeOff {
+		add(clientmiddleware.MiddlewareSpec{
+			Name:       "frame-contract",
+			Middleware: clientmiddleware.NewFrameContractMiddleware(mode, promRegisterer),
+			// Below StatusSourceMiddleware, so that the errors it returns for the invalid frames are seen as plugin errors
+			After: []string{"status-source"},
+		})
+	}
 
-	if features.IsEnabled(featuremgmt.FlagPluginsInstrumentationStatusSource) {
-		// StatusSourceMiddleware should be at the very bottom, or any middlewares below it won't see the
-		// correct status source in their context.Context
-		middlewares = append(middlewares, clientmiddleware.NewStatusSourceMiddleware())
+	if cfg.PluginChaosEnabled {
+		add(clientmiddleware.MiddlewareSpec{
+			Name: "chaos",
+			Middleware: clientmiddleware.NewChaosMiddleware(clientmiddleware.ChaosConfig{
+				Enabled:     true,
+				Seed:        cfg.PluginChaosSeed,
+				Latency:     cfg.PluginChaosLatency,
+				ErrorRate:   cfg.PluginChaosErrorRate,
+				ErrorSource: backend.ErrorSource(cfg.PluginChaosErrorSource),
+				Endpoints:   cfg.PluginChaosEndpoints,
+				PluginIDs:   cfg.PluginChaosPlugins,
+			}),
+			// Right before the plugin, so that the injected faults go through the resilience middlewares, and the
+			// failed queries are seen by the status source middleware
+			After: []string{"circuit-breaker", "timeout", "concurrency-limit", "retry", "status-source", "frame-contract"},
+		})
 	}
 
-	return middlewares
+	return clientmiddleware.AssembleMiddlewares(specs)
 }
@@ -0,0 +1,182 @@
+package pluginsintegration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/plugins"
+	"github.com/grafana/grafana/pkg/plugins/manager/client"
+	"github.com/grafana/grafana/pkg/plugins/manager/client/clienttest"
+	"github.com/grafana/grafana/pkg/plugins/manager/registry"
+	"github.com/grafana/grafana/pkg/services/caching"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/oauthtoken/oauthtokentest"
+	"github.com/grafana/grafana/pkg/services/pluginsintegration/clientmiddleware"
+	"github.com/grafana/grafana/pkg/services/quota/quotatest"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestCreateMiddlewares(t *testing.T) {
+	createMiddlewares := func(t *testing.T, cfg *setting.Cfg, features *featuremgmt.FeatureManager) []plugins.ClientMiddleware {
+		t.Helper()
+		pluginRegistry := registry.NewInMemory()
+		require.NoError(t, pluginRegistry.Add(context.Background(), &plugins.Plugin{
+			JSONData: plugins.JSONData{ID: "prometheus", Backend: true},
+		}))
+		middlewares, err := CreateMiddlewares(cfg, &oauthtokentest.Service{}, tracing.InitializeTracerForTest(), &caching.OSSCachingService{},
+			features, prometheus.NewRegistry(), pluginRegistry, clientmiddleware.NewPayloadSampler(0, 0, nil),
+			clientmiddleware.NewOrgLatencyTracker(0), quotatest.New(false, nil), clientmiddleware.NewQueryQuotaTracker(0))
+		require.NoError(t, err)
+		return middlewares
+	}
+
+	t.Run("Should assemble the middlewares enabled by default", func(t *testing.T) {
+		middlewares := createMiddlewares(t, setting.NewCfg(), featuremgmt.WithFeatures())
+		require.Len(t, middlewares, 13)
+	})
+
+	t.Run("Should assemble the middlewares when all of them are enabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.SendUserHeader = true
+		cfg.PluginStaticHeaders = map[string]http.Header{"prometheus": {"X-Tenant": []string{"a"}}}
+		cfg.PluginForwardHeaders = []string{"X-Tenant"}
+		cfg.Quota.Enabled = true
+		cfg.PluginPayloadSamplingEnabled = true
+		cfg.PluginOrgLatencyTrackingSize = 10
+		cfg.PluginFrameContractValidation = string(clientmiddleware.FrameContractModeWarn)
+		cfg.PluginRetryEnabled = true
+		cfg.PluginCircuitBreakerEnabled = true
+		cfg.PluginMaxConcurrentRequests = 10
+		cfg.PluginRequestTimeoutsEnabled = true
+		cfg.PluginChaosEnabled = true
+		features := featuremgmt.WithFeatures(
+			featuremgmt.FlagPluginsInstrumentationStatusSource,
+			featuremgmt.FlagPluginsInstrumentationOverrides,
+			featuremgmt.FlagUseCachingService,
+			featuremgmt.FlagIdForwarding,
+		)
+
+		middlewares := createMiddlewares(t, cfg, features)
+		require.Len(t, middlewares, 30)
+	})
+
+	t.Run("Should retry the transient errors if enabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.PluginRetryEnabled = true
+		cfg.PluginRetryMaxAttempts = 3
+		cfg.PluginRetryInitialBackoff = time.Millisecond
+		c := newDecorator(t, createMiddlewares(t, cfg, featuremgmt.WithFeatures()))
+		var calls int
+		c.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			calls++
+			return nil, status.Error(codes.Unavailable, "connection refused")
+		}
+
+		_, err := c.decorator.QueryData(context.Background(), &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}})
+		require.ErrorContains(t, err, "connection refused")
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("Should short-circuit the requests to a failing plugin if enabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.PluginCircuitBreakerEnabled = true
+		cfg.PluginCircuitBreakerMinRequests = 2
+		cfg.PluginCircuitBreakerFailureRatio = 0.5
+		cfg.PluginCircuitBreakerOpenTimeout = time.Hour
+		c := newDecorator(t, createMiddlewares(t, cfg, featuremgmt.WithFeatures()))
+		var calls int
+		c.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			calls++
+			return nil, errors.New("plugin failed")
+		}
+
+		for i := 0; i < 3; i++ {
+			_, err := c.decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}})
+			require.Error(t, err)
+		}
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("Should limit the concurrent requests if enabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.PluginMaxConcurrentRequests = 1
+		c := newDecorator(t, createMiddlewares(t, cfg, featuremgmt.WithFeatures()))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		c.QueryDataFunc = func(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+			close(started)
+			<-release
+			return backend.NewQueryDataResponse(), nil
+		}
+		req := &backend.QueryDataRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}}
+		done := make(chan error, 1)
+		go func() {
+			_, err := c.decorator.QueryData(context.Background(), req)
+			done <- err
+		}()
+		<-started
+
+		_, err := c.decorator.QueryData(context.Background(), req)
+		require.ErrorContains(t, err, "concurrent requests")
+
+		close(release)
+		require.NoError(t, <-done)
+	})
+
+	t.Run("Should time out the plugin requests if enabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.PluginRequestTimeoutsEnabled = true
+		cfg.PluginCheckHealthTimeout = 10 * time.Millisecond
+		c := newDecorator(t, createMiddlewares(t, cfg, featuremgmt.WithFeatures()))
+		c.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		_, err := c.decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.ErrorContains(t, err, "timed out")
+	})
+
+	t.Run("Should inject faults if chaos is enabled", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		cfg.PluginChaosEnabled = true
+		cfg.PluginChaosErrorRate = 1
+		cfg.PluginChaosErrorSource = "plugin"
+		cfg.PluginChaosEndpoints = []string{"checkHealth"}
+		c := newDecorator(t, createMiddlewares(t, cfg, featuremgmt.WithFeatures()))
+		var calls int
+		c.CheckHealthFunc = func(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+			calls++
+			return &backend.CheckHealthResult{Status: backend.HealthStatusOk}, nil
+		}
+
+		_, err := c.decorator.CheckHealth(context.Background(), &backend.CheckHealthRequest{PluginContext: backend.PluginContext{PluginID: "prometheus"}})
+		require.ErrorContains(t, err, "chaos")
+		require.Zero(t, calls)
+	})
+}
+
+// decoratorTest is a plugin client decorated with the middlewares under test, calling the TestClient.
+type decoratorTest struct {
+	*clienttest.TestClient
+	decorator *client.Decorator
+}
+
+func newDecorator(t *testing.T, middlewares []plugins.ClientMiddleware) *decoratorTest {
+	t.Helper()
+	tc := &clienttest.TestClient{}
+	d, err := client.NewDecorator(tc, middlewares...)
+	require.NoError(t, err)
+	return &decoratorTest{TestClient: tc, decorator: d}
+}
@@ -41,6 +41,16 @@ func addPlaylistMigrations(mg *Migrator) {
 	mg.AddMigration("Add playlist column updated_at", NewAddColumnMigration(playlistV2(), &Column{
 		Name: "updated_at", Type: DB_BigInt, Nullable: false, Default: "0",
 	}))
+
+	// Per item interval, overriding the playlist one
+	mg.AddMigration("Add playlist_item column interval", NewAddColumnMigration(playlistItemV2, &Column{
+		Name: "interval", Type: DB_NVarchar, Length: 255, Nullable: true,
+	}))
+
+	// Deletion time of the playlists in the trash, zero for the other ones
+	mg.AddMigration("Add playlist column deleted_at", NewAddColumnMigration(playlistV2(), &Column{
+		Name: "deleted_at", Type: DB_BigInt, Nullable: false, Default: "0",
+	}))
 }
 
 func addPlaylistUIDMigration(mg *Migrator) {
@@ -251,6 +251,67 @@ type Cfg struct {
 	PluginsCDNURLTemplate    string
 	PluginLogBackendRequests bool
 
+	PluginPayloadSamplingEnabled    bool
+	PluginPayloadSamplingSize       int
+	PluginPayloadSamplingMaxBytes   int
+	PluginPayloadSamplingRedactKeys []string
+
+	// Bounds of the cache TTLs suggested by plugins
+	PluginCachingMinTTL time.Duration
+	PluginCachingMaxTTL time.Duration
+
+	// Bucket boundaries of the range_recency label of the plugin request metrics
+	PluginRangeRecencyRealtime time.Duration
+	PluginRangeRecencyRecent   time.Duration
+
+	// Bucket boundaries of the plugin request duration histograms, the default ones if empty
+	PluginRequestDurationBucketsMilliseconds []float64
+	PluginRequestDurationBucketsSeconds      []float64
+
+	// Static headers added to the requests to each plugin, by plugin ID
+	PluginStaticHeaders map[string]http.Header
+
+	// Headers of the incoming HTTP requests forwarded to the plugin requests
+	PluginForwardHeaders []string
+
+	// Validation of the frames returned by plugins: off, warn or strict
+	PluginFrameContractValidation string
+
+	// Number of orgs with the most plugin requests whose latency is tracked on their own
+	PluginOrgLatencyTrackingSize int
+
+	// Retries of the plugin requests failing with a transient error
+	PluginRetryEnabled        bool
+	PluginRetryMaxAttempts    int
+	PluginRetryInitialBackoff time.Duration
+	PluginRetryMaxBackoff     time.Duration
+
+	// Circuit breakers of the plugins whose requests keep failing
+	PluginCircuitBreakerEnabled      bool
+	PluginCircuitBreakerFailureRatio float64
+	PluginCircuitBreakerMinRequests  int
+	PluginCircuitBreakerWindow       time.Duration
+	PluginCircuitBreakerOpenTimeout  time.Duration
+
+	// Limit of the concurrent requests to each plugin, disabled if 0, and of the requests waiting for it
+	PluginMaxConcurrentRequests int64
+	PluginMaxQueuedRequests     int64
+
+	// Timeouts of the plugin requests, per endpoint
+	PluginRequestTimeoutsEnabled bool
+	PluginCheckHealthTimeout     time.Duration
+	PluginQueryDataTimeout       time.Duration
+	PluginCallResourceTimeout    time.Duration
+
+	// Faults injected into the plugin requests, for resilience testing only
+	PluginChaosEnabled     bool
+	PluginChaosSeed        int64
+	PluginChaosLatency     time.Duration
+	PluginChaosErrorRate   float64
+	PluginChaosErrorSource string
+	PluginChaosEndpoints   []string
+	PluginChaosPlugins     []string
+
 	// Panels
 	DisableSanitizeHtml bool
 
@@ -524,6 +585,8 @@ type Cfg struct {
 
 	Search SearchSettings
 
+	Playlist PlaylistSettings
+
 	SecureSocksDSProxy SecureSocksDSProxySettings
 
 	// SAML Auth
@@ -1190,6 +1253,10 @@ func (cfg *Cfg) Load(args CommandLineArgs) error {
 
 	cfg.Storage = readStorageSettings(iniFile)
 	cfg.Search = readSearchSettings(iniFile)
+	cfg.Playlist, err = readPlaylistSettings(iniFile)
+	if err != nil {
+		return err
+	}
 
 	cfg.SecureSocksDSProxy, err = readSecureSocksDSProxySettings(iniFile)
 	if err != nil {
@@ -0,0 +1,47 @@
+package setting
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/util"
+)
+
+type PlaylistSettings struct {
+	// ExternalURLAllowedSchemes and ExternalURLAllowedHosts restrict the URLs of the external_url items.
+	ExternalURLAllowedSchemes []string
+	ExternalURLAllowedHosts   []string
+	// SearchMaxLimit is the maximum and default number of playlists returned by a search.
+	SearchMaxLimit int
+	// TrashRetention is how long the deleted playlists are kept in the trash, where they can be restored,
+	// before they're purged. The playlists are deleted right away when it's zero.
+	TrashRetention time.Duration
+	// TagMaxDashboards is the maximum number of dashboards a dashboard_by_tag item resolves to on the server,
+	// selected in the order of TagDashboardsSelection, a search sort option: alpha-asc or alpha-desc.
+	TagMaxDashboards       int
+	TagDashboardsSelection string
+}
+
+func readPlaylistSettings(iniFile *ini.File) (PlaylistSettings, error) {
+	s := PlaylistSettings{}
+
+	playlistsSection := iniFile.Section("playlists")
+	s.ExternalURLAllowedSchemes = util.SplitString(playlistsSection.Key("external_url_allowed_schemes").MustString("https"))
+	s.ExternalURLAllowedHosts = util.SplitString(playlistsSection.Key("external_url_allowed_hosts").MustString(""))
+	s.SearchMaxLimit = playlistsSection.Key("search_max_limit").MustInt(1000)
+	s.TagMaxDashboards = playlistsSection.Key("tag_max_dashboards").MustInt(100)
+	s.TagDashboardsSelection = valueAsString(playlistsSection, "tag_dashboards_selection", "alpha-asc")
+	if s.TagDashboardsSelection != "alpha-asc" && s.TagDashboardsSelection != "alpha-desc" {
+		return s, fmt.Errorf("invalid [playlists] tag_dashboards_selection %q, expected alpha-asc or alpha-desc", s.TagDashboardsSelection)
+	}
+
+	var err error
+	s.TrashRetention, err = gtime.ParseDuration(valueAsString(playlistsSection, "trash_retention", "0"))
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
@@ -1,9 +1,15 @@
 package setting
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/util"
 )
 
 // PluginSettings maps plugin id to map of key/value settings.
@@ -24,12 +30,33 @@ func extractPluginSettings(sections []*ini.Section) PluginSettings {
 	return psMap
 }
 
+// extractPluginStaticHeaders returns the static headers configured for each plugin, in sections named
+// [plugin_headers.<plugin id>] with one key per header.
+func extractPluginStaticHeaders(sections []*ini.Section) map[string]http.Header {
+	headers := map[string]http.Header{}
+	for _, section := range sections {
+		pluginID, ok := strings.CutPrefix(section.Name(), "plugin_headers.")
+		if !ok || pluginID == "" {
+			continue
+		}
+		h := http.Header{}
+		for _, key := range section.Keys() {
+			h.Add(key.Name(), key.Value())
+		}
+		if len(h) > 0 {
+			headers[pluginID] = h
+		}
+	}
+	return headers
+}
+
 func (cfg *Cfg) readPluginSettings(iniFile *ini.File) error {
 	pluginsSection := iniFile.Section("plugins")
 
 	cfg.PluginsEnableAlpha = pluginsSection.Key("enable_alpha").MustBool(false)
 	cfg.PluginsAppsSkipVerifyTLS = pluginsSection.Key("app_tls_skip_verify_insecure").MustBool(false)
 	cfg.PluginSettings = extractPluginSettings(iniFile.Sections())
+	cfg.PluginStaticHeaders = extractPluginStaticHeaders(iniFile.Sections())
 	cfg.PluginSkipPublicKeyDownload = pluginsSection.Key("public_key_retrieval_disabled").MustBool(false)
 	cfg.PluginForcePublicKeyDownload = pluginsSection.Key("public_key_retrieval_on_startup").MustBool(false)
 
@@ -64,8 +91,104 @@ func (cfg *Cfg) readPluginSettings(iniFile *ini.File) error {
 	cfg.PluginsCDNURLTemplate = strings.TrimRight(pluginsSection.Key("cdn_base_url").MustString(""), "/")
 	cfg.PluginLogBackendRequests = pluginsSection.Key("log_backend_requests").MustBool(false)
 
+	// Payload sampling for debugging
+	cfg.PluginPayloadSamplingEnabled = pluginsSection.Key("payload_sampling_enabled").MustBool(false)
+	cfg.PluginPayloadSamplingSize = pluginsSection.Key("payload_sampling_size").MustInt(10)
+	cfg.PluginPayloadSamplingMaxBytes = pluginsSection.Key("payload_sampling_max_bytes").MustInt(65536)
+	for _, key := range strings.Split(pluginsSection.Key("payload_sampling_redact_keys").MustString(""), ",") {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			cfg.PluginPayloadSamplingRedactKeys = append(cfg.PluginPayloadSamplingRedactKeys, key)
+		}
+	}
+
+	// Bounds of the cache TTLs suggested by plugins
+	cfg.PluginCachingMinTTL = pluginsSection.Key("caching_min_ttl").MustDuration(time.Second)
+	cfg.PluginCachingMaxTTL = pluginsSection.Key("caching_max_ttl").MustDuration(time.Hour)
+
+	// Bucket boundaries of the range_recency label of the plugin request metrics
+	cfg.PluginRangeRecencyRealtime = pluginsSection.Key("range_recency_realtime").MustDuration(5 * time.Minute)
+	cfg.PluginRangeRecencyRecent = pluginsSection.Key("range_recency_recent").MustDuration(24 * time.Hour)
+
+	// Bucket boundaries of the plugin request duration histograms
+	var err error
+	if cfg.PluginRequestDurationBucketsMilliseconds, err = readHistogramBuckets(pluginsSection, "request_duration_buckets_milliseconds"); err != nil {
+		return err
+	}
+	if cfg.PluginRequestDurationBucketsSeconds, err = readHistogramBuckets(pluginsSection, "request_duration_buckets_seconds"); err != nil {
+		return err
+	}
+
+	// Validation of the frames returned by plugins
+	cfg.PluginFrameContractValidation = strings.ToLower(pluginsSection.Key("frame_contract_validation").MustString("off"))
+	switch cfg.PluginFrameContractValidation {
+	case "off", "warn", "strict":
+	default:
+		return fmt.Errorf("invalid frame_contract_validation %q in [plugins], must be off, warn or strict", cfg.PluginFrameContractValidation)
+	}
+
+	// Per org latency of the plugin requests
+	cfg.PluginOrgLatencyTrackingSize = pluginsSection.Key("org_latency_tracking_size").MustInt(0)
+
+	// Retries of the plugin requests failing with a transient error
+	cfg.PluginRetryEnabled = pluginsSection.Key("retry_enabled").MustBool(false)
+	cfg.PluginRetryMaxAttempts = pluginsSection.Key("retry_max_attempts").MustInt(3)
+	cfg.PluginRetryInitialBackoff = pluginsSection.Key("retry_initial_backoff").MustDuration(100 * time.Millisecond)
+	cfg.PluginRetryMaxBackoff = pluginsSection.Key("retry_max_backoff").MustDuration(2 * time.Second)
+
+	// Circuit breakers of the plugins whose requests keep failing
+	cfg.PluginCircuitBreakerEnabled = pluginsSection.Key("circuit_breaker_enabled").MustBool(false)
+	cfg.PluginCircuitBreakerFailureRatio = pluginsSection.Key("circuit_breaker_failure_ratio").MustFloat64(0.5)
+	cfg.PluginCircuitBreakerMinRequests = pluginsSection.Key("circuit_breaker_min_requests").MustInt(10)
+	cfg.PluginCircuitBreakerWindow = pluginsSection.Key("circuit_breaker_window").MustDuration(time.Minute)
+	cfg.PluginCircuitBreakerOpenTimeout = pluginsSection.Key("circuit_breaker_open_timeout").MustDuration(30 * time.Second)
+
+	// Limit of the concurrent requests to each plugin
+	cfg.PluginMaxConcurrentRequests = pluginsSection.Key("max_concurrent_requests").MustInt64(0)
+	cfg.PluginMaxQueuedRequests = pluginsSection.Key("max_queued_requests").MustInt64(0)
+
+	// Timeouts of the plugin requests, per endpoint
+	cfg.PluginRequestTimeoutsEnabled = pluginsSection.Key("request_timeouts_enabled").MustBool(false)
+	cfg.PluginCheckHealthTimeout = pluginsSection.Key("check_health_timeout").MustDuration(30 * time.Second)
+	cfg.PluginQueryDataTimeout = pluginsSection.Key("query_data_timeout").MustDuration(5 * time.Minute)
+	cfg.PluginCallResourceTimeout = pluginsSection.Key("call_resource_timeout").MustDuration(time.Minute)
+
+	// Faults injected into the plugin requests, for resilience testing only
+	cfg.PluginChaosEnabled = pluginsSection.Key("chaos_enabled").MustBool(false)
+	cfg.PluginChaosSeed = pluginsSection.Key("chaos_seed").MustInt64(0)
+	cfg.PluginChaosLatency = pluginsSection.Key("chaos_latency").MustDuration(0)
+	cfg.PluginChaosErrorRate = pluginsSection.Key("chaos_error_rate").MustFloat64(0)
+	cfg.PluginChaosErrorSource = strings.ToLower(pluginsSection.Key("chaos_error_source").MustString("plugin"))
+	switch cfg.PluginChaosErrorSource {
+	case "plugin", "downstream":
+	default:
+		return fmt.Errorf("invalid chaos_error_source %q in [plugins], must be plugin or downstream", cfg.PluginChaosErrorSource)
+	}
+	cfg.PluginChaosEndpoints = util.SplitString(pluginsSection.Key("chaos_endpoints").MustString(""))
+	cfg.PluginChaosPlugins = util.SplitString(pluginsSection.Key("chaos_plugins").MustString(""))
+
+	// Headers of the incoming HTTP requests forwarded to the plugin requests
+	cfg.PluginForwardHeaders = util.SplitString(pluginsSection.Key("forward_headers").MustString(""))
+
 	// Installation token for managed plugins
 	cfg.PluginInstallToken = pluginsSection.Key("install_token").MustString("")
 
 	return nil
 }
+
+// readHistogramBuckets reads the comma-separated bucket boundaries of a histogram from the given key,
+// which must be in increasing order. It returns nil if the key isn't set.
+func readHistogramBuckets(section *ini.Section, key string) ([]float64, error) {
+	var buckets []float64
+	for _, value := range util.SplitString(section.Key(key).MustString("")) {
+		bucket, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q in [%s]: %w", key, value, section.Name(), err)
+		}
+		if len(buckets) > 0 && bucket <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("invalid %s in [%s], the buckets must be in increasing order", key, section.Name())
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
@@ -1,7 +1,9 @@
 package setting
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -57,4 +59,176 @@ func Test_readPluginSettings(t *testing.T) {
 		require.Equal(t, []string{"plugin1", "plugin2"}, cfg.DisablePlugins)
 		require.Equal(t, []string{"plugin3", "plugin1", "plugin2"}, cfg.PluginCatalogHiddenPlugins)
 	})
+
+	t.Run("should parse the static headers of plugins", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugin_headers.plugin1")
+		require.NoError(t, err)
+		_, err = sec.NewKey("X-Api-Version", "2024-01-01")
+		require.NoError(t, err)
+		_, err = cfg.Raw.NewSection("plugin_headers.plugin2")
+		require.NoError(t, err)
+		_, err = cfg.Raw.NewSection("plugin.plugin3")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.Equal(t, map[string]http.Header{
+			"plugin1": {"X-Api-Version": []string{"2024-01-01"}},
+		}, cfg.PluginStaticHeaders)
+	})
+
+	t.Run("should parse the forwarded headers", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		_, err = sec.NewKey("forward_headers", "X-Scope-OrgID, X-Tenant")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.Equal(t, []string{"X-Scope-OrgID", "X-Tenant"}, cfg.PluginForwardHeaders)
+	})
+
+	t.Run("should parse the retry settings", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		_, err = sec.NewKey("retry_enabled", "true")
+		require.NoError(t, err)
+		_, err = sec.NewKey("retry_max_attempts", "5")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.True(t, cfg.PluginRetryEnabled)
+		require.Equal(t, 5, cfg.PluginRetryMaxAttempts)
+		require.Equal(t, 100*time.Millisecond, cfg.PluginRetryInitialBackoff)
+		require.Equal(t, 2*time.Second, cfg.PluginRetryMaxBackoff)
+	})
+
+	t.Run("should parse the circuit breaker settings", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		_, err = sec.NewKey("circuit_breaker_enabled", "true")
+		require.NoError(t, err)
+		_, err = sec.NewKey("circuit_breaker_failure_ratio", "0.25")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.True(t, cfg.PluginCircuitBreakerEnabled)
+		require.Equal(t, 0.25, cfg.PluginCircuitBreakerFailureRatio)
+		require.Equal(t, 10, cfg.PluginCircuitBreakerMinRequests)
+		require.Equal(t, time.Minute, cfg.PluginCircuitBreakerWindow)
+		require.Equal(t, 30*time.Second, cfg.PluginCircuitBreakerOpenTimeout)
+	})
+
+	t.Run("should parse the concurrency limit settings", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		_, err = sec.NewKey("max_concurrent_requests", "20")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.Equal(t, int64(20), cfg.PluginMaxConcurrentRequests)
+		require.Zero(t, cfg.PluginMaxQueuedRequests)
+	})
+
+	t.Run("should parse the request timeout settings", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		_, err = sec.NewKey("request_timeouts_enabled", "true")
+		require.NoError(t, err)
+		_, err = sec.NewKey("query_data_timeout", "2m")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.True(t, cfg.PluginRequestTimeoutsEnabled)
+		require.Equal(t, 30*time.Second, cfg.PluginCheckHealthTimeout)
+		require.Equal(t, 2*time.Minute, cfg.PluginQueryDataTimeout)
+		require.Equal(t, time.Minute, cfg.PluginCallResourceTimeout)
+	})
+
+	t.Run("should parse the chaos settings", func(t *testing.T) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		_, err = sec.NewKey("chaos_enabled", "true")
+		require.NoError(t, err)
+		_, err = sec.NewKey("chaos_error_rate", "0.1")
+		require.NoError(t, err)
+		_, err = sec.NewKey("chaos_error_source", "Downstream")
+		require.NoError(t, err)
+		_, err = sec.NewKey("chaos_endpoints", "queryData, checkHealth")
+		require.NoError(t, err)
+
+		err = cfg.readPluginSettings(cfg.Raw)
+		require.NoError(t, err)
+		require.True(t, cfg.PluginChaosEnabled)
+		require.Equal(t, 0.1, cfg.PluginChaosErrorRate)
+		require.Equal(t, "downstream", cfg.PluginChaosErrorSource)
+		require.Equal(t, []string{"queryData", "checkHealth"}, cfg.PluginChaosEndpoints)
+		require.Empty(t, cfg.PluginChaosPlugins)
+
+		_, err = sec.NewKey("chaos_error_source", "upstream")
+		require.NoError(t, err)
+		require.ErrorContains(t, cfg.readPluginSettings(cfg.Raw), "chaos_error_source")
+	})
+}
+
+func Test_readPluginSettingsFrameContractValidation(t *testing.T) {
+	read := func(value string) (*Cfg, error) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		if value != "" {
+			_, err = sec.NewKey("frame_contract_validation", value)
+			require.NoError(t, err)
+		}
+		return cfg, cfg.readPluginSettings(cfg.Raw)
+	}
+
+	cfg, err := read("")
+	require.NoError(t, err)
+	require.Equal(t, "off", cfg.PluginFrameContractValidation)
+
+	cfg, err = read("Strict")
+	require.NoError(t, err)
+	require.Equal(t, "strict", cfg.PluginFrameContractValidation)
+
+	_, err = read("loose")
+	require.ErrorContains(t, err, "frame_contract_validation")
+}
+
+func Test_readPluginSettingsRequestDurationBuckets(t *testing.T) {
+	read := func(value string) (*Cfg, error) {
+		cfg := NewCfg()
+		sec, err := cfg.Raw.NewSection("plugins")
+		require.NoError(t, err)
+		if value != "" {
+			_, err = sec.NewKey("request_duration_buckets_seconds", value)
+			require.NoError(t, err)
+		}
+		return cfg, cfg.readPluginSettings(cfg.Raw)
+	}
+
+	cfg, err := read("")
+	require.NoError(t, err)
+	require.Nil(t, cfg.PluginRequestDurationBucketsSeconds)
+
+	cfg, err = read("0.001, 0.01 ,0.1,1")
+	require.NoError(t, err)
+	require.Equal(t, []float64{0.001, 0.01, 0.1, 1}, cfg.PluginRequestDurationBucketsSeconds)
+
+	_, err = read("0.1,fast")
+	require.ErrorContains(t, err, "request_duration_buckets_seconds")
+
+	_, err = read("1,0.1")
+	require.ErrorContains(t, err, "increasing order")
 }
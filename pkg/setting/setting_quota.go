@@ -6,10 +6,12 @@ type OrgQuota struct {
 	Dashboard  int64 `target:"dashboard"`
 	ApiKey     int64 `target:"api_key"`
 	AlertRule  int64 `target:"alert_rule"`
+	Playlist   int64 `target:"playlist"`
 }
 
 type UserQuota struct {
-	Org int64 `target:"org_user"`
+	Org         int64 `target:"org_user"`
+	PluginQuery int64 `target:"plugin_query"`
 }
 
 type GlobalQuota struct {
@@ -22,6 +24,7 @@ type GlobalQuota struct {
 	AlertRule    int64 `target:"alert_rule"`
 	File         int64 `target:"file"`
 	Correlations int64 `target:"correlations"`
+	Playlist     int64 `target:"playlist"`
 }
 
 type QuotaSettings struct {
@@ -29,6 +32,9 @@ type QuotaSettings struct {
 	Org     OrgQuota
 	User    UserQuota
 	Global  GlobalQuota
+
+	// PluginQueryResetHour is the hour of the day, in UTC, at which the daily plugin query quotas are reset.
+	PluginQueryResetHour int
 }
 
 func (cfg *Cfg) readQuotaSettings() {
@@ -49,11 +55,19 @@ func (cfg *Cfg) readQuotaSettings() {
 		Dashboard:  quota.Key("org_dashboard").MustInt64(10),
 		ApiKey:     quota.Key("org_api_key").MustInt64(10),
 		AlertRule:  alertOrgQuota,
+		Playlist:   quota.Key("org_playlist").MustInt64(100),
 	}
 
 	// per User limits
 	cfg.Quota.User = UserQuota{
-		Org: quota.Key("user_org").MustInt64(10),
+		Org:         quota.Key("user_org").MustInt64(10),
+		PluginQuery: quota.Key("user_plugin_query").MustInt64(-1),
+	}
+
+	cfg.Quota.PluginQueryResetHour = quota.Key("plugin_query_reset_hour").MustInt(0)
+	if cfg.Quota.PluginQueryResetHour < 0 || cfg.Quota.PluginQueryResetHour > 23 {
+		cfg.Logger.Warn("Invalid plugin_query_reset_hour, the daily plugin query quotas are reset at midnight UTC", "hour", cfg.Quota.PluginQueryResetHour)
+		cfg.Quota.PluginQueryResetHour = 0
 	}
 
 	// Global Limits
@@ -67,5 +81,6 @@ func (cfg *Cfg) readQuotaSettings() {
 		File:         quota.Key("global_file").MustInt64(-1),
 		AlertRule:    alertGlobalQuota,
 		Correlations: quota.Key("global_correlations").MustInt64(-1),
+		Playlist:     quota.Key("global_playlist").MustInt64(-1),
 	}
 }
@@ -139,4 +139,18 @@ describe('PlaylistSrv', () => {
     expect((srv as any).validPlaylistUrl).toBe('/url/to/bbb');
     expect(srv.isPlaying).toBe(true);
   });
+
+  it('starts at the given dashboard', async () => {
+    await srv.start('foo', 1);
+
+    // eslint-disable-next-line
+    expect((srv as any).validPlaylistUrl).toBe('/url/to/bbb');
+  });
+
+  it('starts at the first dashboard if the given one is out of range', async () => {
+    await srv.start('foo', 5);
+
+    // eslint-disable-next-line
+    expect((srv as any).validPlaylistUrl).toBe('/url/to/aaa');
+  });
 });
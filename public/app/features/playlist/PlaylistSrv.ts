@@ -71,7 +71,8 @@ export class PlaylistSrv {
     }
   }
 
-  async start(playlistUid: string) {
+  // startItem is the index of the dashboard to start at, among the dashboards the playlist items resolve to
+  async start(playlistUid: string, startItem = 0) {
     this.stop();
 
     this.startUrl = window.location.href;
@@ -104,6 +105,9 @@ export class PlaylistSrv {
     }
     this.urls = urls;
     this.isPlaying = true;
+    if (startItem > 0 && startItem < urls.length) {
+      this.index = startItem;
+    }
     this.next();
     return;
   }
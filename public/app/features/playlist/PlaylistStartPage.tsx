@@ -2,10 +2,10 @@ import { GrafanaRouteComponentProps } from 'app/core/navigation/types';
 
 import { playlistSrv } from './PlaylistSrv';
 
-interface Props extends GrafanaRouteComponentProps<{ uid: string }> {}
+interface Props extends GrafanaRouteComponentProps<{ uid: string }, { item?: string }> {}
 
 // This is a react page that just redirects to new URLs
-export default function PlaylistStartPage({ match }: Props) {
-  playlistSrv.start(match.params.uid);
+export default function PlaylistStartPage({ match, queryParams }: Props) {
+  playlistSrv.start(match.params.uid, Number(queryParams.item) || 0);
   return null;
 }
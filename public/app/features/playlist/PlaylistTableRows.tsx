@@ -33,7 +33,10 @@ export const PlaylistTableRows = ({ items, onDelete }: Props) => {
     const info: ReactNode[] = [];
 
     const first = item.dashboards?.[0];
-    if (!item.dashboards) {
+    if (item.type === 'section') {
+      icon = 'bookmark';
+      info.push(<strong key="info">{item.value}</strong>);
+    } else if (!item.dashboards) {
       info.push(<Spinner key="spinner" />);
     } else if (item.type === 'dashboard_by_tag') {
       info.push(<TagBadge key={item.value} label={item.value} removeIcon={false} count={0} />);
@@ -5,6 +5,7 @@ import { getBackendSrv, config } from '@grafana/runtime';
 import { notifyApp } from 'app/core/actions';
 import { createErrorNotification, createSuccessNotification } from 'app/core/copy/appNotification';
 import { contextSrv } from 'app/core/services/context_srv';
+import impressionSrv from 'app/core/services/impression_srv';
 import { getGrafanaDatasource } from 'app/plugins/datasource/grafana/datasource';
 import { GrafanaQuery, GrafanaQueryType } from 'app/plugins/datasource/grafana/types';
 import { dispatch } from 'app/store/store';
@@ -158,13 +159,51 @@ async function withErrorHandling(apiCall: () => Promise<void>, message = 'Playli
   }
 }
 
-/** Returns a copy with the dashboards loaded */
+/** Returns the UIDs of the dashboards of a recently_viewed item, from the most recently viewed one */
+function recentlyViewedUIDs(item: PlaylistItem, recent: string[]): string[] {
+  const count = parseInt(item.value, 10);
+  return count > 0 ? recent.slice(0, count) : [];
+}
+
+/** Returns a copy of the item with the given dashboards, in the order they were viewed for recently_viewed items */
+function withDashboards(item: PlaylistItem, dashboards: DashboardQueryResult[], recent: string[]): PlaylistItem {
+  if (item.type === 'recently_viewed') {
+    const order = recentlyViewedUIDs(item, recent);
+    dashboards = [...dashboards].sort((a, b) => order.indexOf(a.uid) - order.indexOf(b.uid));
+  }
+  return { ...item, dashboards };
+}
+
+/**
+ * Returns a copy with the dashboards loaded.
+ *
+ * The recently_viewed items are resolved from the dashboards viewed by the current user, so the same
+ * playlist shows different dashboards to each user. The items without any recently viewed dashboard are left out.
+ * The section items only label the items after them, so they have no dashboards.
+ */
 export async function loadDashboards(items: PlaylistItem[]): Promise<PlaylistItem[]> {
-  let idx = 0;
   if (!items?.length) {
     return [];
   }
 
+  let recent: string[] = [];
+  if (items.some((item) => item.type === 'recently_viewed')) {
+    recent = await impressionSrv.getDashboardOpened();
+    items = items.filter((item) => item.type !== 'recently_viewed' || recentlyViewedUIDs(item, recent).length);
+    if (!items.length) {
+      return [];
+    }
+  }
+
+  const resolvable = items.filter((item) => item.type !== 'section');
+  const loaded = resolvable.length ? await searchDashboards(resolvable, recent) : [];
+  let next = 0;
+  return items.map((item) => (item.type === 'section' ? { ...item, dashboards: [] } : loaded[next++]));
+}
+
+/** Returns a copy of the items with the dashboards found by searching for each of them */
+async function searchDashboards(items: PlaylistItem[], recent: string[]): Promise<PlaylistItem[]> {
+  let idx = 0;
   const targets: GrafanaQuery[] = [];
   for (const item of items) {
     const query: SearchQuery = {
@@ -184,6 +223,10 @@ export async function loadDashboards(items: PlaylistItem[]): Promise<PlaylistIte
       case 'dashboard_by_tag':
         query.tags = [item.value];
         break;
+
+      case 'recently_viewed':
+        query.uid = recentlyViewedUIDs(item, recent);
+        break;
     }
     targets.push({
       refId: `${idx++}`,
@@ -198,7 +241,7 @@ export async function loadDashboards(items: PlaylistItem[]): Promise<PlaylistIte
     const res: PlaylistItem[] = [];
     for (let i = 0; i < targets.length; i++) {
       const view = (await searcher.search(targets[i].search!)).view;
-      res.push({ ...items[i], dashboards: view.map((v) => ({ ...v })) });
+      res.push(withDashboards(items[i], view.map((v) => ({ ...v })), recent));
     }
     return res;
   }
@@ -212,7 +255,7 @@ export async function loadDashboards(items: PlaylistItem[]): Promise<PlaylistIte
   }
   return items.map((item, idx) => {
     const view = new DataFrameView<DashboardQueryResult>(rsp.data[idx]);
-    return { ...item, dashboards: view.map((v) => ({ ...v })) };
+    return withDashboards(item, view.map((v) => ({ ...v })), recent);
   });
 }
 
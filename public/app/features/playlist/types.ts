@@ -41,6 +41,10 @@ export interface PlaylistItem {
   | 'dashboard_by_uid'
     // find all dashboards with a given tag
     | 'dashboard_by_tag'
+    // show the dashboards recently viewed by the current user
+    | 'recently_viewed'
+    // label the items after it, up to the next section
+    | 'section'
     // @deprecated use a dashboard with a given internal id
     | 'dashboard_by_id';
 
@@ -53,6 +57,10 @@ export interface PlaylistItem {
    *  - dashboard_by_tag: The value is a tag which is set on any number of dashboards. All
    *  dashboards behind the tag will be added to the playlist.
    *  - dashboard_by_uid: The value is the dashboard UID
+   *  - recently_viewed: The value is the maximum number of dashboards to show, among the ones
+   *  the user playing the playlist viewed most recently. It resolves differently for each user.
+   *  - section: The value is the label of the section starting with this item, e.g. Prod.
+   *  It doesn't resolve to any dashboard.
    */
   value: string;
 